@@ -0,0 +1,211 @@
+// Package builder generates synthetic captures, e.g. deterministic test
+// fixtures and load test inputs.
+package builder
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/RajeshGottlieb/go/packet"
+	"github.com/RajeshGottlieb/go/pcapng"
+)
+
+// BuilderError
+type BuilderError struct {
+	errorString string
+}
+
+func (be *BuilderError) Error() string {
+	return be.errorString
+}
+
+// FlowSpec describes the packets of one flow.
+type FlowSpec struct {
+	Flow    packet.Flow   // TCP, UDP, ICMP or ICMPv6; IPv4 mapped addresses make IPv4 packets
+	Packets int           // number of packets
+	MinSize int           // smallest payload in bytes
+	MaxSize int           // largest payload, sizes are uniform between the two
+	Rate    float64       // packets per second, 1 if 0
+	Start   time.Duration // of the first packet, after Builder.Start
+}
+
+// Packet is a generated Ethernet frame.
+type Packet struct {
+	Timestamp time.Time
+	Data      []byte
+}
+
+// Builder generates the packets of its flows. The same Seed generates the
+// same packets every time.
+type Builder struct {
+	Start  time.Time
+	Seed   int64
+	Jitter time.Duration // every timestamp moves by up to this much either way
+	Flows  []FlowSpec
+}
+
+// New returns a Builder for flows starting at start.
+func New(start time.Time, seed int64) *Builder {
+	return &Builder{Start: start, Seed: seed}
+}
+
+// AddFlow adds one flow.
+func (b *Builder) AddFlow(spec FlowSpec) *Builder {
+	b.Flows = append(b.Flows, spec)
+	return b
+}
+
+// AddFlows adds n flows like spec, the source port of each one higher
+// than that of the previous one.
+func (b *Builder) AddFlows(n int, spec FlowSpec) *Builder {
+	for i := 0; i < n; i++ {
+		s := spec
+		s.Flow.SrcPort += uint16(i)
+		b.Flows = append(b.Flows, s)
+	}
+	return b
+}
+
+// Packets returns the packets of all flows in timestamp order.
+func (b *Builder) Packets() ([]Packet, error) {
+
+	rng := rand.New(rand.NewSource(b.Seed))
+
+	var packets []Packet
+	for i, spec := range b.Flows {
+		if spec.MaxSize < spec.MinSize {
+			return nil, &BuilderError{fmt.Sprintf("flow %v: maximum size %v is below the minimum %v", i, spec.MaxSize, spec.MinSize)}
+		}
+		rate := spec.Rate
+		if rate <= 0 {
+			rate = 1
+		}
+		interval := time.Duration(float64(time.Second) / rate)
+
+		seq := rng.Uint32()
+		for n := 0; n < spec.Packets; n++ {
+			size := spec.MinSize
+			if spec.MaxSize > spec.MinSize {
+				size += rng.Intn(spec.MaxSize - spec.MinSize + 1)
+			}
+			payload := make([]byte, size)
+			rng.Read(payload)
+
+			data, err := frame(spec.Flow, seq, uint16(n), payload)
+			if err != nil {
+				return nil, err
+			}
+			seq += uint32(size)
+
+			ts := b.Start.Add(spec.Start + time.Duration(n)*interval)
+			if b.Jitter > 0 {
+				ts = ts.Add(time.Duration(rng.Int63n(int64(2*b.Jitter)+1)) - b.Jitter)
+			}
+			packets = append(packets, Packet{ts, data})
+		}
+	}
+
+	sort.SliceStable(packets, func(i, j int) bool { return packets[i].Timestamp.Before(packets[j].Timestamp) })
+	return packets, nil
+}
+
+// Write writes the packets to sink as interface 0.
+func (b *Builder) Write(sink pcapng.PacketSink) error {
+
+	packets, err := b.Packets()
+	if err != nil {
+		return err
+	}
+	for _, p := range packets {
+		if err := sink.WritePacket(pcapng.PacketMeta{Timestamp: p.Timestamp}, p.Data); err != nil {
+			return err
+		}
+	}
+	return sink.Flush()
+}
+
+// WritePcapng writes a Section Header Block and an Ethernet interface,
+// then the packets.
+func (b *Builder) WritePcapng(pw *pcapng.PcapngWriter) error {
+
+	if err := pw.Write(&pcapng.SectionBlock{MajorVersion: 1, SectionLength: -1}); err != nil {
+		return err
+	}
+	ifb := &pcapng.InterfaceBlock{
+		LinkType: 1,
+		Options:  []pcapng.Option{&pcapng.If_Name{Value: "builder"}, &pcapng.If_Tsresol{Value: 9}},
+	}
+	if err := pw.Write(ifb); err != nil {
+		return err
+	}
+	return b.Write(pw)
+}
+
+// frame builds an Ethernet frame carrying payload in the flow. seq is the
+// TCP sequence number, n the IP ID and ICMP sequence number.
+func frame(flow packet.Flow, seq uint32, n uint16, payload []byte) ([]byte, error) {
+
+	var transport []byte
+	switch flow.Protocol {
+	case packet.ProtocolTCP:
+		transport = make([]byte, 20)
+		binary.BigEndian.PutUint16(transport[0:], flow.SrcPort)
+		binary.BigEndian.PutUint16(transport[2:], flow.DstPort)
+		binary.BigEndian.PutUint32(transport[4:], seq)
+		transport[12] = 5 << 4 // data offset
+		transport[13] = packet.TCPFlagPSH | packet.TCPFlagACK
+		binary.BigEndian.PutUint16(transport[14:], 65535) // window
+	case packet.ProtocolUDP:
+		transport = make([]byte, 8)
+		binary.BigEndian.PutUint16(transport[0:], flow.SrcPort)
+		binary.BigEndian.PutUint16(transport[2:], flow.DstPort)
+		binary.BigEndian.PutUint16(transport[4:], uint16(8+len(payload)))
+	case packet.ProtocolICMP, packet.ProtocolICMPv6:
+		// echo request, the source port is the identifier
+		transport = make([]byte, 8)
+		transport[0] = 8
+		if flow.Protocol == packet.ProtocolICMPv6 {
+			transport[0] = 128
+		}
+		binary.BigEndian.PutUint16(transport[4:], flow.SrcPort)
+		binary.BigEndian.PutUint16(transport[6:], n)
+	default:
+		return nil, &BuilderError{fmt.Sprintf("unsupported protocol %v", flow.Protocol)}
+	}
+	segment := append(transport, payload...)
+
+	src, dst := net.IP(flow.SrcIP[:]), net.IP(flow.DstIP[:])
+	var ip []byte
+	etherType := uint16(0x0800)
+	if src.To4() != nil && dst.To4() != nil {
+		ip = make([]byte, 20)
+		ip[0] = 0x45
+		binary.BigEndian.PutUint16(ip[2:], uint16(20+len(segment)))
+		binary.BigEndian.PutUint16(ip[4:], n)
+		ip[8] = 64 // TTL
+		ip[9] = flow.Protocol
+		copy(ip[12:16], src.To4())
+		copy(ip[16:20], dst.To4())
+	} else {
+		etherType = 0x86dd
+		ip = make([]byte, 40)
+		ip[0] = 0x60
+		binary.BigEndian.PutUint16(ip[4:], uint16(len(segment)))
+		ip[6] = flow.Protocol
+		ip[7] = 64 // hop limit
+		copy(ip[8:24], src.To16())
+		copy(ip[24:40], dst.To16())
+	}
+
+	// locally administered MAC addresses
+	eth := []byte{0x02, 0, 0, 0, 0, 2, 0x02, 0, 0, 0, 0, 1, 0, 0}
+	binary.BigEndian.PutUint16(eth[12:], etherType)
+
+	data := append(append(eth, ip...), segment...)
+	packet.Decode(1, data).FixChecksums()
+	return data, nil
+}
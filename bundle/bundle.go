@@ -0,0 +1,257 @@
+// Package bundle reads and writes captures inside zip archives, such as
+// evidence bundles of rotated capture files with an index of their
+// digests, without extracting them first.
+package bundle
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// IndexName is the name of the index inside a bundle.
+const IndexName = "index.json"
+
+// BundleError
+type BundleError struct {
+	errorString string
+}
+
+func (be *BundleError) Error() string {
+	return be.errorString
+}
+
+// Entry describes one capture of a bundle.
+type Entry struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Index lists the captures of a bundle in order, e.g. of rotation.
+type Index struct {
+	Files []Entry `json:"files"`
+}
+
+// Writer writes captures to a zip archive and, on Close, their index.
+type Writer struct {
+	Store bool // store the captures rather than deflate them
+
+	zw      *zip.Writer
+	index   Index
+	current *entryWriter
+}
+
+// NewWriter returns a Writer writing a zip archive to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{zw: zip.NewWriter(w)}
+}
+
+// entryWriter counts and digests what is written to a capture.
+type entryWriter struct {
+	w    io.Writer
+	h    hash.Hash
+	size int64
+}
+
+func (ew *entryWriter) Write(b []byte) (int, error) {
+	n, err := ew.w.Write(b)
+	ew.h.Write(b[:n])
+	ew.size += int64(n)
+	return n, err
+}
+
+// Create adds a capture named name, to be written to the returned writer
+// until the next Create or Close.
+func (bw *Writer) Create(name string) (io.Writer, error) {
+	return bw.create(name, time.Now())
+}
+
+func (bw *Writer) create(name string, modified time.Time) (io.Writer, error) {
+
+	if name == IndexName {
+		return nil, &BundleError{fmt.Sprintf("%v is reserved for the index", IndexName)}
+	}
+	bw.finish()
+
+	method := zip.Deflate
+	if bw.Store {
+		method = zip.Store
+	}
+	w, err := bw.zw.CreateHeader(&zip.FileHeader{Name: name, Method: method, Modified: modified})
+	if err != nil {
+		return nil, err
+	}
+	bw.index.Files = append(bw.index.Files, Entry{Name: name})
+	bw.current = &entryWriter{w: w, h: sha256.New()}
+	return bw.current, nil
+}
+
+// finish records the size and digest of the capture being written.
+func (bw *Writer) finish() {
+	if bw.current == nil {
+		return
+	}
+	e := &bw.index.Files[len(bw.index.Files)-1]
+	e.Size = bw.current.size
+	e.SHA256 = hex.EncodeToString(bw.current.h.Sum(nil))
+	bw.current = nil
+}
+
+// AddFile copies the file at name into the bundle under its base name.
+func (bw *Writer) AddFile(name string) error {
+
+	fh, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	info, err := fh.Stat()
+	if err != nil {
+		return err
+	}
+	w, err := bw.create(filepath.Base(name), info.ModTime())
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, fh)
+	return err
+}
+
+// Close writes the index and the zip directory. It does not close the
+// underlying writer.
+func (bw *Writer) Close() error {
+
+	bw.finish()
+	w, err := bw.zw.CreateHeader(&zip.FileHeader{Name: IndexName, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(&bw.index); err != nil {
+		return err
+	}
+	return bw.zw.Close()
+}
+
+// Bundle is a zip archive of captures opened for reading.
+type Bundle struct {
+	Index Index // from the archive's index, else its captures by name
+
+	zr    *zip.Reader
+	files map[string]*zip.File
+	fh    *os.File // if opened by OpenFile
+}
+
+// captureExtensions are what a capture is named without an index.
+var captureExtensions = map[string]bool{".pcap": true, ".pcapng": true, ".cap": true}
+
+// NewReader opens the zip archive in the first size bytes of r.
+func NewReader(r io.ReaderAt, size int64) (*Bundle, error) {
+
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Bundle{zr: zr, files: make(map[string]*zip.File)}
+	for _, f := range zr.File {
+		b.files[f.Name] = f
+	}
+
+	if f, ok := b.files[IndexName]; ok {
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		if err := json.NewDecoder(rc).Decode(&b.Index); err != nil {
+			return nil, &BundleError{fmt.Sprintf("%v: %v", IndexName, err)}
+		}
+		return b, nil
+	}
+
+	// without an index, the captures sorted by name
+	for _, f := range zr.File {
+		if captureExtensions[strings.ToLower(path.Ext(f.Name))] {
+			b.Index.Files = append(b.Index.Files, Entry{Name: f.Name, Size: int64(f.UncompressedSize64)})
+		}
+	}
+	sort.Slice(b.Index.Files, func(i, j int) bool { return b.Index.Files[i].Name < b.Index.Files[j].Name })
+	return b, nil
+}
+
+// Open returns the content of the capture named name, e.g. for
+// pcapng.Reader.
+func (b *Bundle) Open(name string) (io.ReadCloser, error) {
+	f, ok := b.files[name]
+	if !ok {
+		return nil, &BundleError{fmt.Sprintf("%v is not in the bundle", name)}
+	}
+	return f.Open()
+}
+
+// Verify checks the size and digest of every capture in the index.
+func (b *Bundle) Verify() error {
+
+	for _, e := range b.Index.Files {
+		rc, err := b.Open(e.Name)
+		if err != nil {
+			return err
+		}
+		h := sha256.New()
+		n, err := io.Copy(h, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+		if n != e.Size {
+			return &BundleError{fmt.Sprintf("%v has %v bytes, the index says %v", e.Name, n, e.Size)}
+		}
+		if e.SHA256 != "" && hex.EncodeToString(h.Sum(nil)) != e.SHA256 {
+			return &BundleError{fmt.Sprintf("%v does not match its digest in the index", e.Name)}
+		}
+	}
+	return nil
+}
+
+// OpenFile opens the bundle at name. Close it when done.
+func OpenFile(name string) (*Bundle, error) {
+
+	fh, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := fh.Stat()
+	if err != nil {
+		fh.Close()
+		return nil, err
+	}
+	b, err := NewReader(fh, info.Size())
+	if err != nil {
+		fh.Close()
+		return nil, err
+	}
+	b.fh = fh
+	return b, nil
+}
+
+// Close closes the file of a bundle opened with OpenFile.
+func (b *Bundle) Close() error {
+	if b.fh == nil {
+		return nil
+	}
+	return b.fh.Close()
+}
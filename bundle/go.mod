@@ -0,0 +1,3 @@
+module github.com/RajeshGottlieb/go/bundle
+
+go 1.15
@@ -0,0 +1,139 @@
+package capture
+
+import (
+	"net"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	ethPAll             = 0x0003 // ETH_P_ALL
+	solPacket           = 263    // SOL_PACKET
+	packetAddMembership = 1      // PACKET_ADD_MEMBERSHIP
+	packetMrPromisc     = 1      // PACKET_MR_PROMISC
+	packetStatistics    = 6      // PACKET_STATISTICS
+)
+
+// AFPacket captures and sends packets on a Linux network device using an
+// AF_PACKET socket. It needs CAP_NET_RAW. Only Ethernet devices are supported.
+type AFPacket struct {
+	fd      int
+	ifindex int
+	name    string
+	snapLen uint32
+	buf     []byte
+	drops   uint64 // PACKET_STATISTICS resets the counters on every read
+}
+
+// htons converts a short to network byte order.
+func htons(v uint16) uint16 {
+	return v<<8 | v>>8
+}
+
+// OpenAFPacket opens an AF_PACKET socket bound to device. Packets are
+// captured up to snapLen bytes, 262144 if snapLen is 0 or less.
+func OpenAFPacket(device string, snapLen int, promisc bool) (*AFPacket, error) {
+
+	ifc, err := net.InterfaceByName(device)
+	if err != nil {
+		return nil, err
+	}
+
+	fd, err := syscall.Socket(syscall.AF_PACKET, syscall.SOCK_RAW, int(htons(ethPAll)))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := syscall.Bind(fd, &syscall.SockaddrLinklayer{Protocol: htons(ethPAll), Ifindex: ifc.Index}); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+
+	if promisc {
+		// struct packet_mreq
+		mreq := struct {
+			ifindex int32
+			mrType  uint16
+			alen    uint16
+			address [8]byte
+		}{ifindex: int32(ifc.Index), mrType: packetMrPromisc}
+		mreqBytes := (*[unsafe.Sizeof(mreq)]byte)(unsafe.Pointer(&mreq))[:]
+		if err := syscall.SetsockoptString(fd, solPacket, packetAddMembership, string(mreqBytes)); err != nil {
+			syscall.Close(fd)
+			return nil, err
+		}
+	}
+
+	// the buffer holds a whole snapLen, however large
+	if snapLen <= 0 {
+		snapLen = 262144
+	}
+	return &AFPacket{fd, ifc.Index, device, uint32(snapLen), make([]byte, snapLen), 0}, nil
+}
+
+// ReadPacket blocks until the next packet arrives.
+func (a *AFPacket) ReadPacket() ([]byte, CaptureInfo, error) {
+
+	n, from, err := syscall.Recvfrom(a.fd, a.buf, syscall.MSG_TRUNC)
+	if err != nil {
+		return nil, CaptureInfo{}, err
+	}
+	ci := CaptureInfo{Timestamp: time.Now(), Length: n}
+	if sll, ok := from.(*syscall.SockaddrLinklayer); ok {
+		ci.Direction = packetTypeDirection(uint16(sll.Pkttype))
+	}
+
+	captured := n
+	if captured > len(a.buf) {
+		captured = len(a.buf)
+	}
+	if captured > int(a.snapLen) {
+		captured = int(a.snapLen)
+	}
+	ci.CaptureLength = captured
+	return append([]byte(nil), a.buf[:captured]...), ci, nil
+}
+
+// WritePacket transmits a complete link layer frame.
+func (a *AFPacket) WritePacket(data []byte) error {
+	return syscall.Sendto(a.fd, data, 0, &syscall.SockaddrLinklayer{Protocol: htons(ethPAll), Ifindex: a.ifindex})
+}
+
+// Name returns the name of the device.
+func (a *AFPacket) Name() string {
+	return a.name
+}
+
+// LinkType returns LINKTYPE_ETHERNET.
+func (a *AFPacket) LinkType() uint16 {
+	return 1
+}
+
+// SnapLen returns the maximum number of bytes captured per packet.
+func (a *AFPacket) SnapLen() uint32 {
+	return a.snapLen
+}
+
+// Drops returns the packets the kernel dropped since the socket was opened.
+func (a *AFPacket) Drops() (uint64, error) {
+
+	// struct tpacket_stats
+	var stats struct {
+		packets uint32
+		drops   uint32
+	}
+	size := uint32(unsafe.Sizeof(stats))
+	_, _, errno := syscall.Syscall6(syscall.SYS_GETSOCKOPT, uintptr(a.fd), solPacket, packetStatistics,
+		uintptr(unsafe.Pointer(&stats)), uintptr(unsafe.Pointer(&size)), 0)
+	if errno != 0 {
+		return 0, errno
+	}
+	a.drops += uint64(stats.drops)
+	return a.drops, nil
+}
+
+// Close closes the socket.
+func (a *AFPacket) Close() error {
+	return syscall.Close(a.fd)
+}
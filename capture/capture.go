@@ -0,0 +1,159 @@
+// Package capture reads packets from live network interfaces.
+package capture
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/RajeshGottlieb/go/pcapng"
+)
+
+// CaptureError
+type CaptureError struct {
+	errorString string
+}
+
+func (ce *CaptureError) Error() string {
+	return ce.errorString
+}
+
+// CaptureInfo describes a captured packet.
+type CaptureInfo struct {
+	Timestamp     time.Time
+	CaptureLength int // number of bytes captured
+	Length        int // length of the packet on the wire
+	// pcapng.DirectionInbound or DirectionOutbound if the source knows it,
+	// else pcapng.DirectionUnknown
+	Direction uint32
+}
+
+// Meta returns the metadata of the packet for a pcapng.PacketSink, the
+// direction in the epb_flags.
+func (ci CaptureInfo) Meta(interfaceID uint32) pcapng.PacketMeta {
+	return pcapng.PacketMeta{
+		Timestamp:      ci.Timestamp,
+		InterfaceID:    interfaceID,
+		OriginalLength: ci.Length,
+		Flags:          ci.Direction,
+	}
+}
+
+// Linux packet types, of AF_PACKET addresses and cooked capture headers
+const (
+	packetOtherHost = 3 // PACKET_OTHERHOST
+	packetOutgoing  = 4 // PACKET_OUTGOING
+)
+
+// packetTypeDirection maps a Linux packet type to a pcapng direction.
+func packetTypeDirection(packetType uint16) uint32 {
+	switch {
+	case packetType == packetOutgoing:
+		return pcapng.DirectionOutbound
+	case packetType <= packetOtherHost:
+		return pcapng.DirectionInbound // to us, broadcast, multicast or promiscuous
+	}
+	return pcapng.DirectionUnknown
+}
+
+// cookedDirection returns the direction in the header of a Linux cooked
+// capture, as libpcap captures on the "any" device.
+func cookedDirection(linkType uint16, data []byte) uint32 {
+	switch {
+	case linkType == 113 && len(data) >= 16: // LINKTYPE_LINUX_SLL
+		return packetTypeDirection(binary.BigEndian.Uint16(data[0:2]))
+	case linkType == 276 && len(data) >= 20: // LINKTYPE_LINUX_SLL2
+		return packetTypeDirection(uint16(data[10]))
+	}
+	return pcapng.DirectionUnknown
+}
+
+// Source is a live capture.
+type Source interface {
+	// ReadPacket blocks until the next packet arrives.
+	ReadPacket() ([]byte, CaptureInfo, error)
+	Name() string
+	LinkType() uint16
+	SnapLen() uint32
+	Close() error
+}
+
+// DropCounter is implemented by sources that know how many packets the
+// kernel or driver dropped.
+type DropCounter interface {
+	Drops() (uint64, error) // since the source was opened
+}
+
+// DropPollInterval is how often Capture asks a DropCounter for its drops,
+// a system call too costly to make for every packet.
+var DropPollInterval = time.Second
+
+// Capture reads count packets from src, or forever if count is 0, and
+// writes them to pw after a Section Header Block and an Interface Description Block.
+// The drops of a DropCounter source, polled every DropPollInterval, are
+// written as epb_dropcount and, at the end, isb_ifdrop. A known direction
+// is written in the epb_flags.
+func Capture(src Source, pw *pcapng.PcapngWriter, count int) error {
+
+	if err := pw.Write(&pcapng.SectionBlock{}); err != nil {
+		return err
+	}
+
+	ifb := &pcapng.InterfaceBlock{
+		LinkType: src.LinkType(),
+		SnapLen:  src.SnapLen(),
+		Options: []pcapng.Option{
+			&pcapng.If_Name{Value: src.Name()},
+			&pcapng.If_Tsresol{Value: 9},
+		},
+	}
+	if err := pw.Write(ifb); err != nil {
+		return err
+	}
+
+	dc, _ := src.(DropCounter)
+	pollDrops := func() error {
+		drops, err := dc.Drops()
+		if err != nil {
+			return err
+		}
+		return pw.ReportDrops(0, drops)
+	}
+	var polled time.Time
+
+	for n := 0; count == 0 || n < count; n++ {
+		data, ci, err := src.ReadPacket()
+		if err != nil {
+			return err
+		}
+		if dc != nil {
+			if now := time.Now(); now.Sub(polled) >= DropPollInterval {
+				if err := pollDrops(); err != nil {
+					return err
+				}
+				polled = now
+			}
+		}
+
+		high, low := pcapng.SplitTimestamp(ci.Timestamp, 9)
+		epb := &pcapng.EnhancedPacketBlock{
+			TimestampHigh:        high,
+			TimestampLow:         low,
+			CapturedPacketLength: uint32(len(data)),
+			OriginalPacketLength: uint32(ci.Length),
+			PacketData:           data,
+		}
+		if ci.Direction != pcapng.DirectionUnknown {
+			epb.WithFlags(ci.Direction)
+		}
+		if err := pw.Write(epb); err != nil {
+			return err
+		}
+	}
+	// the statistics have the total at the end
+	if dc != nil {
+		if err := pollDrops(); err != nil {
+			return err
+		}
+	}
+	return pw.WriteDropStatistics()
+}
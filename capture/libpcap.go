@@ -0,0 +1,124 @@
+//go:build libpcap
+// +build libpcap
+
+package capture
+
+/*
+#cgo !windows LDFLAGS: -lpcap
+#cgo windows LDFLAGS: -lwpcap
+#include <stdlib.h>
+#include <pcap.h>
+*/
+import "C"
+
+import (
+	"io"
+	"time"
+	"unsafe"
+)
+
+// Handle is a libpcap live capture.
+type Handle struct {
+	p       *C.pcap_t
+	name    string
+	snapLen uint32
+}
+
+// OpenLive opens a network device for capturing. timeout is how long the
+// kernel may buffer packets before delivering them.
+func OpenLive(device string, snapLen int, promisc bool, timeout time.Duration) (*Handle, error) {
+
+	errbuf := (*C.char)(C.calloc(C.PCAP_ERRBUF_SIZE, 1))
+	defer C.free(unsafe.Pointer(errbuf))
+
+	cdevice := C.CString(device)
+	defer C.free(unsafe.Pointer(cdevice))
+
+	var cpromisc C.int
+	if promisc {
+		cpromisc = 1
+	}
+
+	p := C.pcap_open_live(cdevice, C.int(snapLen), cpromisc, C.int(timeout/time.Millisecond), errbuf)
+	if p == nil {
+		return nil, &CaptureError{C.GoString(errbuf)}
+	}
+	return &Handle{p, device, uint32(snapLen)}, nil
+}
+
+// SetBPFFilter compiles a tcpdump style filter expression and applies it.
+func (h *Handle) SetBPFFilter(expr string) error {
+
+	cexpr := C.CString(expr)
+	defer C.free(unsafe.Pointer(cexpr))
+
+	var program C.struct_bpf_program
+	if C.pcap_compile(h.p, &program, cexpr, 1, C.PCAP_NETMASK_UNKNOWN) != 0 {
+		return &CaptureError{C.GoString(C.pcap_geterr(h.p))}
+	}
+	defer C.pcap_freecode(&program)
+
+	if C.pcap_setfilter(h.p, &program) != 0 {
+		return &CaptureError{C.GoString(C.pcap_geterr(h.p))}
+	}
+	return nil
+}
+
+// ReadPacket blocks until the next packet arrives.
+func (h *Handle) ReadPacket() ([]byte, CaptureInfo, error) {
+
+	var hdr *C.struct_pcap_pkthdr
+	var data *C.u_char
+
+	for {
+		switch C.pcap_next_ex(h.p, &hdr, &data) {
+		case 1:
+			ci := CaptureInfo{
+				Timestamp:     time.Unix(int64(hdr.ts.tv_sec), int64(hdr.ts.tv_usec)*1000),
+				CaptureLength: int(hdr.caplen),
+				Length:        int(hdr.len),
+			}
+			// pcap_pkthdr has no direction, only cooked captures do
+			packet := C.GoBytes(unsafe.Pointer(data), C.int(hdr.caplen))
+			ci.Direction = cookedDirection(h.LinkType(), packet)
+			return packet, ci, nil
+		case 0:
+			continue // the timeout expired without a packet
+		case -2:
+			return nil, CaptureInfo{}, io.EOF
+		default:
+			return nil, CaptureInfo{}, &CaptureError{C.GoString(C.pcap_geterr(h.p))}
+		}
+	}
+}
+
+// Name returns the name of the device.
+func (h *Handle) Name() string {
+	return h.name
+}
+
+// LinkType returns the link type of the device.
+func (h *Handle) LinkType() uint16 {
+	return uint16(C.pcap_datalink(h.p))
+}
+
+// SnapLen returns the maximum number of bytes captured per packet.
+func (h *Handle) SnapLen() uint32 {
+	return h.snapLen
+}
+
+// Drops returns the packets dropped by the kernel or driver since the
+// device was opened, as pcap_stats reports them.
+func (h *Handle) Drops() (uint64, error) {
+	var stats C.struct_pcap_stat
+	if C.pcap_stats(h.p, &stats) != 0 {
+		return 0, &CaptureError{C.GoString(C.pcap_geterr(h.p))}
+	}
+	return uint64(stats.ps_drop) + uint64(stats.ps_ifdrop), nil
+}
+
+// Close closes the device.
+func (h *Handle) Close() error {
+	C.pcap_close(h.p)
+	return nil
+}
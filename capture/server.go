@@ -0,0 +1,182 @@
+package capture
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/RajeshGottlieb/go/pcapng"
+)
+
+// packetInfo is a captured packet queued for a client.
+type packetInfo struct {
+	data    []byte
+	ci      CaptureInfo
+	dropped uint64 // packets dropped for the client just before this one
+}
+
+// client is one HTTP client streaming the capture.
+type client struct {
+	filter  func(data []byte) bool
+	packets chan packetInfo
+	dropped uint64 // packets that did not fit in the queue since the last one that did
+}
+
+// Server streams a live capture to HTTP clients as pcapng, e.g.
+//
+//	curl -s http://probe:8080/ | wireshark -k -i -
+//
+// Each client gets its own queue. When a client can't keep up the packets
+// that don't fit in its queue are dropped rather than stalling the capture
+// or the other clients. The client is told how many as epb_dropcount on the
+// next packet it gets and as isb_ifdrop when the capture ends.
+type Server struct {
+	Source      Source
+	QueueLength int // packets buffered per client
+	// NewFilter compiles the filter query parameter of a request.
+	// If nil requests with a filter are rejected.
+	NewFilter func(expr string) (func(data []byte) bool, error)
+
+	mu      sync.Mutex
+	clients map[*client]bool
+	err     error // why Run returned
+}
+
+// NewServer returns a Server for src. Call Run to start capturing.
+func NewServer(src Source) *Server {
+	return &Server{
+		Source:      src,
+		QueueLength: 1000,
+		clients:     make(map[*client]bool),
+	}
+}
+
+// Run reads packets from the source and hands them to the clients until the source fails.
+func (s *Server) Run() error {
+	for {
+		data, ci, err := s.Source.ReadPacket()
+		if err != nil {
+			s.mu.Lock()
+			s.err = err
+			for c := range s.clients {
+				close(c.packets)
+				delete(s.clients, c)
+			}
+			s.mu.Unlock()
+			return err
+		}
+
+		s.mu.Lock()
+		for c := range s.clients {
+			if c.filter != nil && !c.filter(data) {
+				continue
+			}
+			select {
+			case c.packets <- packetInfo{data, ci, c.dropped}:
+				c.dropped = 0
+			default:
+				// the client is too slow, drop the packet for this client only
+				c.dropped++
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// ServeHTTP streams the capture to one client until it disconnects or the
+// capture ends. Once Run has returned new clients are turned away.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	c := &client{packets: make(chan packetInfo, s.QueueLength)}
+
+	if expr := r.URL.Query().Get("filter"); expr != "" {
+		if s.NewFilter == nil {
+			http.Error(w, "filters are not supported", http.StatusBadRequest)
+			return
+		}
+		filter, err := s.NewFilter(expr)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		c.filter = filter
+	}
+
+	s.mu.Lock()
+	if err := s.err; err != nil {
+		s.mu.Unlock()
+		http.Error(w, "capture stopped: "+err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	s.clients[c] = true
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, c)
+		s.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "application/x-pcapng")
+	flusher, _ := w.(http.Flusher)
+	pw := pcapng.Writer(w)
+
+	if err := pw.Write(&pcapng.SectionBlock{}); err != nil {
+		return
+	}
+	ifb := &pcapng.InterfaceBlock{
+		LinkType: s.Source.LinkType(),
+		SnapLen:  s.Source.SnapLen(),
+		Options: []pcapng.Option{
+			&pcapng.If_Name{Value: s.Source.Name()},
+			&pcapng.If_Tsresol{Value: 9},
+		},
+	}
+	if err := pw.Write(ifb); err != nil {
+		return
+	}
+
+	var dropped uint64 // total for the client
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case p, ok := <-c.packets:
+			if !ok {
+				// the capture ended, Run has let go of the client
+				if dropped += c.dropped; dropped == 0 {
+					return
+				}
+				if pw.ReportDrops(0, dropped) != nil || pw.WriteDropStatistics() != nil {
+					return
+				}
+				if flusher != nil {
+					flusher.Flush()
+				}
+				return
+			}
+			if p.dropped > 0 {
+				dropped += p.dropped
+				if err := pw.ReportDrops(0, dropped); err != nil {
+					return
+				}
+			}
+			high, low := pcapng.SplitTimestamp(p.ci.Timestamp, 9)
+			epb := &pcapng.EnhancedPacketBlock{
+				TimestampHigh:        high,
+				TimestampLow:         low,
+				CapturedPacketLength: uint32(len(p.data)),
+				OriginalPacketLength: uint32(p.ci.Length),
+				PacketData:           p.data,
+			}
+			if p.ci.Direction != pcapng.DirectionUnknown {
+				epb.WithFlags(p.ci.Direction)
+			}
+			if err := pw.Write(epb); err != nil {
+				return
+			}
+			if flusher != nil && len(c.packets) == 0 {
+				flusher.Flush()
+			}
+		}
+	}
+}
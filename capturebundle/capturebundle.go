@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"github.com/RajeshGottlieb/go/bundle"
+	"io"
+	"os"
+)
+
+func main() {
+
+	create := flag.Bool("create", false, "create the bundle from the capture files that follow it")
+	store := flag.Bool("store", false, "with -create, store the captures rather than compress them")
+	verify := flag.Bool("verify", false, "check every capture against the digests of the index")
+	cat := flag.String("cat", "", "write this capture of the bundle to stdout")
+	flag.Parse()
+
+	if flag.NArg() < 1 || (*create && flag.NArg() < 2) {
+		fmt.Printf("usage: %v [-verify] [-cat name] <bundle-zip>\n", os.Args[0])
+		fmt.Printf("       %v -create [-store] <bundle-zip> <capture>...\n", os.Args[0])
+		os.Exit(2)
+	}
+
+	if *create {
+		fh, err := os.Create(flag.Arg(0))
+		if err != nil {
+			panic(err)
+		}
+		defer fh.Close()
+
+		bw := bundle.NewWriter(fh)
+		bw.Store = *store
+		for _, name := range flag.Args()[1:] {
+			if err := bw.AddFile(name); err != nil {
+				panic(err)
+			}
+		}
+		if err := bw.Close(); err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	b, err := bundle.OpenFile(flag.Arg(0))
+	if err != nil {
+		panic(err)
+	}
+	defer b.Close()
+
+	if *cat != "" {
+		rc, err := b.Open(*cat)
+		if err != nil {
+			panic(err)
+		}
+		defer rc.Close()
+		w := bufio.NewWriter(os.Stdout)
+		if _, err := io.Copy(w, rc); err != nil {
+			panic(err)
+		}
+		if err := w.Flush(); err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	for _, e := range b.Index.Files {
+		fmt.Printf("%v %v %v\n", e.Name, e.Size, e.SHA256)
+	}
+	if *verify {
+		if err := b.Verify(); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Println("ok")
+	}
+}
@@ -0,0 +1,7 @@
+module github.com/RajeshGottlieb/go/capturebundle
+
+go 1.15
+
+require github.com/RajeshGottlieb/go/bundle v0.0.0-00010101000000-000000000000
+
+replace github.com/RajeshGottlieb/go/bundle => ../bundle
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"github.com/RajeshGottlieb/go/pcapng"
+	"github.com/RajeshGottlieb/go/stats"
+	"os"
+	"time"
+)
+
+func main() {
+
+	factor := flag.Float64("factor", 20, "report gaps this many times longer than the usual time between packets")
+	minGap := flag.Duration("mingap", time.Second, "and at least this long")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Printf("usage: %v [-factor f] [-mingap duration] <input-pcapng>\n", os.Args[0])
+		return
+	}
+
+	rfh, err := os.Open(flag.Arg(0))
+	if err != nil {
+		panic(err)
+	}
+	defer rfh.Close()
+
+	gaps := stats.NewCaptureGaps()
+	gaps.Factor = *factor
+	gaps.MinGap = *minGap
+	if err := stats.Scan(pcapng.Reader(bufio.NewReader(rfh)), gaps); err != nil {
+		panic(err)
+	}
+
+	for _, i := range gaps.Interruptions {
+		fmt.Printf("%v %v section %v interface %v %v for %v (usually %v), %v drops\n",
+			i.Start.UTC().Format(time.RFC3339Nano), i.End.UTC().Format(time.RFC3339Nano),
+			i.Section, i.InterfaceID, i.Kind, i.Gap(), i.Expected, i.Drops)
+	}
+}
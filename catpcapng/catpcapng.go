@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"github.com/RajeshGottlieb/go/pcapng"
+	"os"
+)
+
+func main() {
+
+	merge := flag.Bool("merge", false, "put everything in one section, renumbering the interfaces")
+	timeline := flag.Bool("timeline", false, "merge the packets of all inputs into one section in timestamp order")
+	sync := flag.Bool("sync", false, "with -timeline, correct the clocks of the other inputs to the first from the packets they have in common")
+	names := flag.String("names", "", "coalesce the Name Resolution Blocks of each section, keeping all, first or latest names of an address")
+	flag.Parse()
+
+	if flag.NArg() < 2 {
+		fmt.Printf("usage: %v [-merge|-timeline [-sync]] [-names all|first|latest] <output-pcapng> <input-pcapng>...\n", os.Args[0])
+		return
+	}
+
+	var policy pcapng.NamePolicy
+	if *names != "" {
+		var err error
+		if policy, err = pcapng.ParseNamePolicy(*names); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	var clocks []*pcapng.Clock
+	if *sync {
+		clocks = estimateClocks(flag.Args()[1:])
+	}
+
+	var inputs []*pcapng.PcapngReader
+	for _, name := range flag.Args()[1:] {
+		fh, err := os.Open(name)
+		if err != nil {
+			panic(err)
+		}
+		defer fh.Close()
+		inputs = append(inputs, pcapng.Reader(bufio.NewReader(fh)))
+	}
+
+	wfh, err := os.Create(flag.Arg(0))
+	if err != nil {
+		panic(err)
+	}
+	defer wfh.Close()
+
+	bw := bufio.NewWriter(wfh)
+	pw := pcapng.Writer(bw)
+	pw.CoalesceNames = *names != ""
+	pw.NamePolicy = policy
+	if *timeline {
+		err = pcapng.Merge(pw, inputs, clocks)
+	} else {
+		err = pcapng.Concat(pw, inputs, *merge)
+	}
+	if err != nil {
+		panic(err)
+	}
+	// writes the held back names and flushes, bw itself is not closed
+	if err := pw.Close(); err != nil {
+		panic(err)
+	}
+}
+
+// estimateClocks estimates the clock of every input after the first
+// against the first, from the packets they have in common.
+func estimateClocks(names []string) []*pcapng.Clock {
+
+	open := func(name string) (*os.File, *pcapng.PcapngReader) {
+		fh, err := os.Open(name)
+		if err != nil {
+			panic(err)
+		}
+		return fh, pcapng.Reader(bufio.NewReader(fh))
+	}
+
+	clocks := make([]*pcapng.Clock, len(names))
+	for i, name := range names[1:] {
+		rfh, ref := open(names[0])
+		ofh, other := open(name)
+		pairs, err := pcapng.CommonPackets(ref, other, nil)
+		rfh.Close()
+		ofh.Close()
+		if err != nil {
+			panic(err)
+		}
+
+		clock, err := pcapng.EstimateClock(pairs)
+		if err != nil {
+			fmt.Printf("%v: %v\n", name, err)
+			continue
+		}
+		fmt.Printf("%v: offset %v drift %.3f ppm from %v packets\n", name, clock.Offset, clock.PPM, len(pairs))
+		clocks[i+1] = clock
+	}
+	return clocks
+}
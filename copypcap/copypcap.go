@@ -1,54 +1,135 @@
 package main
 
 import (
+	"bufio"
+	"flag"
 	"fmt"
+	"github.com/RajeshGottlieb/go/pcap"
+	"github.com/RajeshGottlieb/go/pcapng"
 	"io"
 	"os"
-	"github.com/RajeshGottlieb/go/pcap"
 )
 
 func main() {
 
-	if len(os.Args) != 3 {
-		fmt.Printf("usage: %v <input-pcap> <output-pcap>\n", os.Args[0])
-		return
-	}
+	precision := flag.String("precision", "", "write timestamps in usec or nsec instead of the input's resolution")
+	round := flag.Bool("round", false, "round timestamps to the nearest micro second instead of truncating them")
+	roundMode := flag.String("rounding", "", "truncate, up or even, how to drop digits converting to micro seconds; -round is -rounding up")
+	flag.Parse()
 
-	rfh, err := os.Open(os.Args[1])
-	if err != nil {
-		panic(err)
+	if flag.NArg() < 2 || stdinCount(flag.Args()[:flag.NArg()-1]) > 1 {
+		fmt.Fprintf(os.Stderr, "usage: %v [-precision usec|nsec [-round|-rounding mode]] <input-pcap|->... <output-pcap|->\n", os.Args[0])
+		os.Exit(2)
 	}
-	defer rfh.Close()
-
-	pr, err := pcap.Reader(rfh)
-	if err != nil {
-		panic(err)
+	if *precision != "" && *precision != "usec" && *precision != "nsec" {
+		fmt.Fprintf(os.Stderr, "invalid precision %q\n", *precision)
+		os.Exit(2)
 	}
 
-	wfh, err := os.Create(os.Args[2])
-	if err != nil {
-		panic(err)
+	rounding := pcapng.Truncate
+	if *round {
+		rounding = pcapng.RoundHalfUp
+	}
+	if *roundMode != "" {
+		r, err := pcapng.ParseRounding(*roundMode)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		rounding = r
 	}
-	defer wfh.Close()
 
-	pw, err := pcap.Writer(wfh)
+	inputs := flag.Args()[:flag.NArg()-1]
+	output := flag.Arg(flag.NArg() - 1)
+
+	wfh, err := create(output)
 	if err != nil {
-		panic(err)
+		fail("%v", err)
 	}
+	bw := bufio.NewWriter(wfh)
 
-	for count := 0; true; count++ {
+	var pw *pcap.PcapWriter
+	for _, input := range inputs {
 
-		ts, pkt, err := pr.Read()
-		if err == io.EOF {
-			break
-		} else if err != nil {
-			panic(err)
+		rfh, err := open(input)
+		if err != nil {
+			fail("%v", err)
 		}
 
-		err = pw.Write(ts, pkt)
+		pr, err := pcap.Reader(bufio.NewReader(rfh))
 		if err != nil {
-			panic(err)
+			fail("%v: %v", input, err)
+		}
+
+		if pw == nil {
+			// the first input decides the header of the output
+			header := pr.Header
+			header.MagicNumber = pcap.MagicMicroseconds
+			if (*precision == "" && pr.NanoSecond) || *precision == "nsec" {
+				header.MagicNumber = pcap.MagicNanoseconds
+			}
+			if pw, err = pcap.NewWriter(bw, header); err != nil {
+				fail("%v: %v", output, err)
+			}
+		} else if pr.Header.Network != pw.Header.Network {
+			fail("%v: link type %v differs from the %v of %v", input, pr.Header.Network, pw.Header.Network, inputs[0])
+		}
+
+		for {
+			rec, pkt, err := pr.ReadRecord()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				fail("%v: %v", input, err)
+			}
+
+			rec = pcap.ConvertPrecision(rec, pr.NanoSecond, pw.NanoSecond, rounding)
+
+			if err := pw.WriteRecord(rec, pkt); err != nil {
+				fail("%v: %v", output, err)
+			}
 		}
+		rfh.Close()
+	}
+
+	if err := bw.Flush(); err != nil {
+		fail("%v: %v", output, err)
+	}
+	if err := wfh.Close(); err != nil {
+		fail("%v: %v", output, err)
+	}
+}
+
+// open opens an input file, "-" is the standard input.
+func open(name string) (*os.File, error) {
+	if name == "-" {
+		return os.Stdin, nil
+	}
+	return os.Open(name)
+}
+
+// create creates the output file, "-" is the standard output.
+func create(name string) (*os.File, error) {
+	if name == "-" {
+		return os.Stdout, nil
+	}
+	return os.Create(name)
+}
 
+// stdinCount counts the inputs naming the standard input, which can only
+// be read once.
+func stdinCount(names []string) int {
+	count := 0
+	for _, name := range names {
+		if name == "-" {
+			count++
+		}
 	}
+	return count
+}
+
+// fail reports an error on the standard error and exits with status 1.
+func fail(format string, a ...interface{}) {
+	fmt.Fprintf(os.Stderr, "%v: %v\n", os.Args[0], fmt.Sprintf(format, a...))
+	os.Exit(1)
 }
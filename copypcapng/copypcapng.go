@@ -1,178 +1,453 @@
 package main
 
 import (
+	"bufio"
+	"crypto/sha256"
+	"flag"
 	"fmt"
 	"github.com/RajeshGottlieb/go/pcapng"
+	"github.com/RajeshGottlieb/go/transform"
 	"io"
 	"os"
+	"strings"
 )
 
 func main() {
 
-	if len(os.Args) != 3 {
-		fmt.Printf("usage: %v <input-pcapng> <output-text>\n", os.Args[0])
-		return
+	tsresol := flag.Int("tsresol", -1, "change the timestamp resolution of every interface to this if_tsresol value, e.g. 6 for micro and 9 for nano seconds")
+	round := flag.Bool("round", false, "round timestamps to the nearest value instead of truncating them when lowering the resolution")
+	roundMode := flag.String("rounding", "", "truncate, up or even, how to drop digits when lowering the resolution; -round is -rounding up")
+	shift := flag.Duration("shift", 0, "add this to every timestamp, e.g. -1h30m or 2.5s")
+	ppm := flag.Float64("drift", 0, "correct clock drift by this many parts per million since the first packet")
+	keep := flag.Int("keep", 0, "keep only one packet in this many")
+	perFlow := flag.Bool("perflow", false, "with -keep, count the packets of each flow separately")
+	probability := flag.Float64("sample", 1, "keep each packet with this probability")
+	seed := flag.Int64("seed", 1, "random seed for -sample, the same seed selects the same packets")
+	complement := flag.String("complement", "", "with -sample, write the packets not kept to this file")
+	wifi := flag.Bool("wifi", false, "convert 802.11 data frames to Ethernet, dropping the other frames")
+	radiotap := flag.Bool("radiotap", false, "with -wifi, keep the channel, signal and MCS in a comment")
+	ppp := flag.Bool("ppp", false, "strip PPPoE, L2TP and PPP headers down to IP, making the interfaces raw IP")
+	decap := flag.Bool("decap", false, "replace GRE, IP in IP, VXLAN, GENEVE and ERSPAN packets by the packets they carry")
+	outer := flag.Bool("outer", false, "with -decap, keep the removed headers in a comment")
+	var specs specList
+	flag.Var(&specs, "transform", "apply the registered transform name[:args], e.g. slice:128 or exec:./mytransform, may be repeated")
+	flag.Parse()
+
+	if flag.NArg() < 2 || stdinCount(flag.Args()[:flag.NArg()-1]) > 1 {
+		fmt.Fprintf(os.Stderr, "usage: %v [-tsresol n [-round|-rounding mode]] [-shift duration] [-drift ppm] [-keep n [-perflow]] [-sample p [-seed n] [-complement file]] [-wifi [-radiotap]] [-ppp] [-decap [-outer]] [-transform name[:args]]... <input-pcapng|->... <output-pcapng|->\n", os.Args[0])
+		os.Exit(2)
 	}
 
-	rfh, err := os.Open(os.Args[1])
-	if err != nil {
-		panic(err)
+	rounding := pcapng.Truncate
+	if *round {
+		rounding = pcapng.RoundHalfUp
 	}
-	defer rfh.Close()
-
-	pr := pcapng.Reader(rfh)
-
-	wfh, err := os.Create(os.Args[2])
-	if err != nil {
-		panic(err)
+	if *roundMode != "" {
+		r, err := pcapng.ParseRounding(*roundMode)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		rounding = r
 	}
-	defer wfh.Close()
 
-	pw := pcapng.Writer(wfh)
+	var transforms []transform.Transform
+	if *wifi {
+		transforms = append(transforms, &transform.WifiToEthernet{KeepRadiotap: *radiotap})
+	}
+	if *ppp {
+		transforms = append(transforms, &transform.StripPPP{})
+	}
+	if *decap {
+		transforms = append(transforms, &transform.Decap{KeepOuter: *outer})
+	}
+	if *keep > 1 {
+		transforms = append(transforms, &transform.Decimate{N: *keep, PerFlow: *perFlow})
+	}
 
-	for count := 0; true; count++ {
+	var sample *transform.Sample
+	if *probability < 1 {
+		sample = &transform.Sample{Probability: *probability, Seed: *seed}
+		if *complement != "" {
+			cfh, err := os.Create(*complement)
+			if err != nil {
+				fail("%v", err)
+			}
+			defer cfh.Close()
+			sample.Complement = pcapng.Writer(cfh)
+		}
+	}
+	if *shift != 0 || *ppm != 0 {
+		transforms = append(transforms, &transform.TimeShift{Offset: *shift, PPM: *ppm})
+	}
+	for _, spec := range specs {
+		t, err := transform.New(spec)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		transforms = append(transforms, t)
+	}
 
-		block, err := pr.Read()
-		if err == io.EOF {
-			break
-		} else if err != nil {
-			panic(err)
+	report := &pcapng.FidelityReport{}
+	if *tsresol >= 0 {
+		if *tsresol > 0xff || !pcapng.ValidTsresol(uint8(*tsresol)) {
+			fail("-tsresol %v is out of range, at most %v or 0x80|63", *tsresol, pcapng.MaxTsresol)
 		}
+		transforms = append(transforms, &transform.Precision{Tsresol: uint8(*tsresol), Rounding: rounding, Report: report})
+	}
+	if sample != nil {
+		// last, so the output and the complement split the same transformed packets
+		transforms = append(transforms, sample)
+	}
 
-		if b, ok := block.(*pcapng.SectionBlock); ok {
+	inputs := flag.Args()[:flag.NArg()-1]
+	output := flag.Arg(flag.NArg() - 1)
 
-			fmt.Printf("# SectionBlock %v: Type=0x%08x TotalLength=%v\n", count+1, b.Type, b.TotalLength)
+	// the block listing goes to the standard error when the copy takes the standard output
+	var info io.Writer = os.Stdout
+	if output == "-" {
+		info = os.Stderr
+	}
 
-			for _, opt := range b.Options {
-				switch option := opt.(type) {
-				case *pcapng.Opt_Comment:
-					fmt.Printf("#  opt_comment=%v\n", option.Value)
-				case *pcapng.Shb_Hardware:
-					fmt.Printf("#  shb_hardware=%v\n", option.Value)
-				case *pcapng.Shb_Os:
-					fmt.Printf("#  shb_os=%v\n", option.Value)
-				case *pcapng.Shb_Userappl:
-					fmt.Printf("#  shb_userappl=%v\n", option.Value)
-				default:
-				}
+	wfh, err := create(output)
+	if err != nil {
+		fail("%v", err)
+	}
+	bw := bufio.NewWriter(wfh)
+
+	if len(transforms) == 0 {
+		// nothing to change, keep every byte
+		all := sha256.New()
+		for _, input := range inputs {
+			rfh, err := open(input)
+			if err != nil {
+				fail("%v", err)
+			}
+			sum, err := pcapng.CopyLossless(bw, io.TeeReader(rfh, all))
+			if err != nil {
+				fail("%v: %v", input, err)
 			}
+			rfh.Close()
+			fmt.Fprintf(info, "# copied %v byte for byte, sha256 %x\n", input, sum)
+		}
+		finish(bw, wfh, output)
 
-			if err = pw.Write(b); err != nil {
-				panic(err)
+		// read the output back, unless it went to the standard output
+		if output != "-" {
+			rfh, err := os.Open(output)
+			if err != nil {
+				fail("%v", err)
 			}
+			if err := pcapng.VerifyCopy(rfh, all.Sum(nil)); err != nil {
+				fail("%v: %v", output, err)
+			}
+			rfh.Close()
+			fmt.Fprintf(info, "# verified %v against the input\n", output)
+		}
+		return
+	}
+
+	pw := pcapng.Writer(bw)
+
+	var interfaces []*pcapng.InterfaceBlock
+	number := 0
 
-		} else if b, ok := block.(*pcapng.InterfaceBlock); ok {
+	// the sections of each input follow those of the one before
+	for _, input := range inputs {
 
-			fmt.Printf("# InterfaceBlock %v: Type=0x%08x TotalLength=%v LinkType=%v SnapLen=%v\n", count+1, b.Type, b.TotalLength, b.LinkType, b.SnapLen)
+		rfh, err := open(input)
+		if err != nil {
+			fail("%v", err)
+		}
+		pr := pcapng.Reader(bufio.NewReader(rfh))
+
+		for count := 0; true; count++ {
 
-			for _, opt := range b.Options {
-				switch option := opt.(type) {
-				case *pcapng.Opt_Comment:
-					fmt.Printf("#  opt_comment=%v\n", option.Value)
-				case *pcapng.If_Name:
-					fmt.Printf("#  if_name=%v\n", option.Value)
-				case *pcapng.If_Tsresol:
-					fmt.Printf("#  if_tsresol=%v\n", option.Value)
-				case *pcapng.If_Os:
-					fmt.Printf("#  if_os=%v\n", option.Value)
-				default:
+			block, err := pr.Read()
+			if err == io.EOF {
+				if sample != nil && sample.Err != nil {
+					fail("%v: %v", *complement, sample.Err)
+				}
+				if stats := pr.Stats(); !stats.Clean() {
+					fmt.Fprintf(info, "# %v: %v\n", input, stats)
 				}
+				break
+			} else if err != nil {
+				fail("%v: %v", input, err)
 			}
 
-			if err = pw.Write(b); err != nil {
-				panic(err)
-			}
-
-		} else if b, ok := block.(*pcapng.InterfaceStatisticsBlock); ok {
-
-			fmt.Printf("# InterfaceStatisticsBlock %v: Type=0x%08x TotalLength=%v\n", count+1, b.Type, b.TotalLength)
-
-			for _, opt := range b.Options {
-				switch option := opt.(type) {
-				case *pcapng.Opt_Comment:
-					fmt.Printf("#  opt_comment=%v\n", option.Value)
-				case *pcapng.Isb_Starttime:
-					fmt.Printf("#  isb_starttime=%v,%v\n", option.TimestampHigh, option.TimestampLow)
-				case *pcapng.Isb_Endtime:
-					fmt.Printf("#  isb_endtime=%v,%v\n", option.TimestampHigh, option.TimestampLow)
-				case *pcapng.Isb_Ifrecv:
-					fmt.Printf("#  isb_ifrecv=%v\n", option.Value)
-				case *pcapng.Isb_Ifdrop:
-					fmt.Printf("#  isb_ifdrop=%v\n", option.Value)
-				case *pcapng.Isb_Filteraccept:
-					fmt.Printf("#  isb_filteraccept=%v\n", option.Value)
-				case *pcapng.Isb_Osdrop:
-					fmt.Printf("#  isb_osdrop=%v\n", option.Value)
-				case *pcapng.Isb_Usrdeliv:
-					fmt.Printf("#  isb_usrdeliv=%v\n", option.Value)
+			switch b := block.(type) {
+			case *pcapng.SectionBlock:
+				interfaces = nil
+			case *pcapng.InterfaceBlock:
+				interfaces = append(interfaces, b)
+			case *pcapng.EnhancedPacketBlock:
+				number++
+			}
+
+			dropped := false
+			for _, t := range transforms {
+				if b, ok := block.(*pcapng.EnhancedPacketBlock); ok {
+					p := transform.Packet{Number: number, Block: b}
+					if int(b.InterfaceID) < len(interfaces) {
+						p.Interface = interfaces[b.InterfaceID]
+					}
+					dropped = !t.Apply(&p)
+				} else if bt, ok := t.(transform.BlockTransform); ok {
+					dropped = !bt.ApplyBlock(block.(pcapng.Block))
+				}
+				if dropped {
+					break
 				}
 			}
+			if dropped {
+				continue
+			}
 
-			if err = pw.Write(b); err != nil {
-				panic(err)
+			if err := writeBlock(pw, info, block, count); err != nil {
+				fail("%v: %v", output, err)
 			}
+		}
 
-		} else if b, ok := block.(*pcapng.EnhancedPacketBlock); ok {
+		rfh.Close()
+	}
 
-			fmt.Printf("# EnhancedPacketBlock %v: Type=0x%08x TotalLength=%v InterfaceID=%v\n", count+1, b.Type, b.TotalLength, b.InterfaceID)
+	if *tsresol >= 0 {
+		fmt.Fprintf(info, "# %v timestamps truncated\n", report.Truncated)
+	}
+	for _, t := range transforms {
+		// e.g. the subprocess of an exec transform
+		if c, ok := t.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				fail("%v", err)
+			}
+		}
+	}
+	finish(bw, wfh, output)
+}
 
-			for _, opt := range b.Options {
-				switch option := opt.(type) {
-				case *pcapng.Opt_Comment:
-					fmt.Printf("#  opt_comment=%v\n", option.Value)
-				case *pcapng.Epb_Flags:
-					fmt.Printf("#  epb_flags=%v\n", option.Value)
-				case *pcapng.Epb_Hash:
-					fmt.Printf("#  epb_hash=%v,%v\n", option.Value)
-				case *pcapng.Epb_Dropcount:
-					fmt.Printf("#  epb_dropcount=%v\n", option.Value)
-				case *pcapng.Epb_Packetid:
-					fmt.Printf("#  epb_packetid=%v\n", option.Value)
-				case *pcapng.Epb_Queue:
-					fmt.Printf("#  epb_queue=%v\n", option.Value)
-				}
+// writeBlock lists block on info and writes it to pw. count is its index
+// in the input.
+func writeBlock(pw *pcapng.PcapngWriter, info io.Writer, block interface{}, count int) error {
+
+	if b, ok := block.(*pcapng.SectionBlock); ok {
+
+		fmt.Fprintf(info, "# SectionBlock %v: Type=0x%08x TotalLength=%v\n", count+1, b.Type, b.TotalLength)
+
+		for _, opt := range b.Options {
+			switch option := opt.(type) {
+			case *pcapng.Opt_Comment:
+				fmt.Fprintf(info, "#  opt_comment=%v\n", option.Value)
+			case *pcapng.Shb_Hardware:
+				fmt.Fprintf(info, "#  shb_hardware=%v\n", option.Value)
+			case *pcapng.Shb_Os:
+				fmt.Fprintf(info, "#  shb_os=%v\n", option.Value)
+			case *pcapng.Shb_Userappl:
+				fmt.Fprintf(info, "#  shb_userappl=%v\n", option.Value)
+			default:
 			}
+		}
+
+		if err := pw.Write(b); err != nil {
+			return err
+		}
 
-			if err = pw.Write(b); err != nil {
-				panic(err)
+	} else if b, ok := block.(*pcapng.InterfaceBlock); ok {
+
+		fmt.Fprintf(info, "# InterfaceBlock %v: Type=0x%08x TotalLength=%v LinkType=%v SnapLen=%v\n", count+1, b.Type, b.TotalLength, b.LinkType, b.SnapLen)
+
+		for _, opt := range b.Options {
+			switch option := opt.(type) {
+			case *pcapng.Opt_Comment:
+				fmt.Fprintf(info, "#  opt_comment=%v\n", option.Value)
+			case *pcapng.If_Name:
+				fmt.Fprintf(info, "#  if_name=%v\n", option.Value)
+			case *pcapng.If_Tsresol:
+				fmt.Fprintf(info, "#  if_tsresol=%v\n", option.Value)
+			case *pcapng.If_Os:
+				fmt.Fprintf(info, "#  if_os=%v\n", option.Value)
+			default:
 			}
+		}
 
-		} else if b, ok := block.(*pcapng.NameResolutionBlock); ok {
+		if err := pw.Write(b); err != nil {
+			return err
+		}
 
-			fmt.Printf("# NameResolutionBlock %v: Type=0x%08x TotalLength=%v\n", count+1, b.Type, b.TotalLength)
-			if err = pw.Write(b); err != nil {
-				panic(err)
+	} else if b, ok := block.(*pcapng.InterfaceStatisticsBlock); ok {
+
+		fmt.Fprintf(info, "# InterfaceStatisticsBlock %v: Type=0x%08x TotalLength=%v\n", count+1, b.Type, b.TotalLength)
+
+		for _, opt := range b.Options {
+			switch option := opt.(type) {
+			case *pcapng.Opt_Comment:
+				fmt.Fprintf(info, "#  opt_comment=%v\n", option.Value)
+			case *pcapng.Isb_Starttime:
+				fmt.Fprintf(info, "#  isb_starttime=%v,%v\n", option.TimestampHigh, option.TimestampLow)
+			case *pcapng.Isb_Endtime:
+				fmt.Fprintf(info, "#  isb_endtime=%v,%v\n", option.TimestampHigh, option.TimestampLow)
+			case *pcapng.Isb_Ifrecv:
+				fmt.Fprintf(info, "#  isb_ifrecv=%v\n", option.Value)
+			case *pcapng.Isb_Ifdrop:
+				fmt.Fprintf(info, "#  isb_ifdrop=%v\n", option.Value)
+			case *pcapng.Isb_Filteraccept:
+				fmt.Fprintf(info, "#  isb_filteraccept=%v\n", option.Value)
+			case *pcapng.Isb_Osdrop:
+				fmt.Fprintf(info, "#  isb_osdrop=%v\n", option.Value)
+			case *pcapng.Isb_Usrdeliv:
+				fmt.Fprintf(info, "#  isb_usrdeliv=%v\n", option.Value)
 			}
+		}
 
-			for _, rec := range b.Records {
-				switch record := rec.(type) {
-				case *pcapng.Nrb_Record_ipv4:
-					fmt.Printf("#  nrb_record_ipv4=%x\n", record.Value)
-				case *pcapng.Nrb_Record_ipv6:
-					fmt.Printf("#  nrb_record_ipv6=%x\n", record.Value)
-				}
+		if err := pw.Write(b); err != nil {
+			return err
+		}
+
+	} else if b, ok := block.(*pcapng.EnhancedPacketBlock); ok {
+
+		fmt.Fprintf(info, "# EnhancedPacketBlock %v: Type=0x%08x TotalLength=%v InterfaceID=%v\n", count+1, b.Type, b.TotalLength, b.InterfaceID)
+
+		for _, opt := range b.Options {
+			switch option := opt.(type) {
+			case *pcapng.Opt_Comment:
+				fmt.Fprintf(info, "#  opt_comment=%v\n", option.Value)
+			case *pcapng.Epb_Flags:
+				fmt.Fprintf(info, "#  epb_flags=%v\n", option.Value)
+			case *pcapng.Epb_Hash:
+				fmt.Fprintf(info, "#  epb_hash=%x\n", option.Value)
+			case *pcapng.Epb_Dropcount:
+				fmt.Fprintf(info, "#  epb_dropcount=%v\n", option.Value)
+			case *pcapng.Epb_Packetid:
+				fmt.Fprintf(info, "#  epb_packetid=%v\n", option.Value)
+			case *pcapng.Epb_Queue:
+				fmt.Fprintf(info, "#  epb_queue=%v\n", option.Value)
+			case *pcapng.Epb_Verdict:
+				fmt.Fprintf(info, "#  epb_verdict=%v %x\n", option.Type, option.Value)
+			case *pcapng.Epb_Processid_Threadid:
+				fmt.Fprintf(info, "#  epb_processid_threadid=%v %v\n", option.ProcessID, option.ThreadID)
 			}
+		}
 
-			for _, opt := range b.Options {
-				switch option := opt.(type) {
-				case *pcapng.Opt_Comment:
-					fmt.Printf("#  opt_comment=%v\n", option.Value)
-				case *pcapng.Ns_Dnsname:
-					fmt.Printf("#  ns_dnsname=%v\n", option.Value)
-				case *pcapng.Ns_DnsIP4addr:
-					fmt.Printf("#  ns_dnsIP4addr=%x\n", option.Value)
-				case *pcapng.Ns_DnsIP6addr:
-					fmt.Printf("#  ns_dnsIP6addr=%x\n", option.Value)
-				}
+		if err := pw.Write(b); err != nil {
+			return err
+		}
+
+	} else if b, ok := block.(*pcapng.NameResolutionBlock); ok {
+
+		fmt.Fprintf(info, "# NameResolutionBlock %v: Type=0x%08x TotalLength=%v\n", count+1, b.Type, b.TotalLength)
+		if err := pw.Write(b); err != nil {
+			return err
+		}
+
+		for _, rec := range b.Records {
+			switch record := rec.(type) {
+			case *pcapng.Nrb_Record_ipv4:
+				fmt.Fprintf(info, "#  nrb_record_ipv4=%x\n", record.Value)
+			case *pcapng.Nrb_Record_ipv6:
+				fmt.Fprintf(info, "#  nrb_record_ipv6=%x\n", record.Value)
+			}
+		}
+
+		for _, opt := range b.Options {
+			switch option := opt.(type) {
+			case *pcapng.Opt_Comment:
+				fmt.Fprintf(info, "#  opt_comment=%v\n", option.Value)
+			case *pcapng.Ns_Dnsname:
+				fmt.Fprintf(info, "#  ns_dnsname=%v\n", option.Value)
+			case *pcapng.Ns_DnsIP4addr:
+				fmt.Fprintf(info, "#  ns_dnsIP4addr=%x\n", option.Value)
+			case *pcapng.Ns_DnsIP6addr:
+				fmt.Fprintf(info, "#  ns_dnsIP6addr=%x\n", option.Value)
 			}
+		}
+
+	} else if b, ok := block.(*pcapng.DecryptionSecretsBlock); ok {
 
-		} else if b, ok := block.(*pcapng.GenericBlock); ok {
+		fmt.Fprintf(info, "# DecryptionSecretsBlock %v: Type=0x%08x TotalLength=%v SecretsType=0x%08x SecretsLength=%v\n", count+1, b.Type, b.TotalLength, b.SecretsType, b.SecretsLength)
 
-			fmt.Printf("# GenericBlock %v: Type=0x%08x TotalLength=%v len(Data)=%v\n", count+1, b.Type, b.TotalLength, len(b.Data))
-			if err = pw.Write(b); err != nil {
-				panic(err)
+		for _, opt := range b.Options {
+			switch option := opt.(type) {
+			case *pcapng.Opt_Comment:
+				fmt.Fprintf(info, "#  opt_comment=%v\n", option.Value)
 			}
+		}
+
+		if err := pw.Write(b); err != nil {
+			return err
+		}
 
+	} else if b, ok := block.(*pcapng.GenericBlock); ok {
+
+		fmt.Fprintf(info, "# GenericBlock %v: Type=0x%08x TotalLength=%v len(Data)=%v\n", count+1, b.Type, b.TotalLength, len(b.Data))
+		if err := pw.Write(b); err != nil {
+			return err
 		}
+
+	} else if b, ok := block.(pcapng.Block); ok {
+
+		// a block type this listing does not know, still copied
+		fmt.Fprintf(info, "# %T %v\n", b, count+1)
+		if err := pw.Write(b); err != nil {
+			return err
+		}
+
+	}
+	return nil
+}
+
+// specList collects the values of a repeated flag.
+type specList []string
+
+func (s *specList) String() string {
+	return strings.Join(*s, " ")
+}
+
+func (s *specList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// finish flushes and closes the output.
+func finish(bw *bufio.Writer, wfh *os.File, output string) {
+	if err := bw.Flush(); err != nil {
+		fail("%v: %v", output, err)
+	}
+	if err := wfh.Close(); err != nil {
+		fail("%v: %v", output, err)
 	}
 }
+
+// open opens an input file, "-" is the standard input.
+func open(name string) (*os.File, error) {
+	if name == "-" {
+		return os.Stdin, nil
+	}
+	return os.Open(name)
+}
+
+// create creates the output file, "-" is the standard output.
+func create(name string) (*os.File, error) {
+	if name == "-" {
+		return os.Stdout, nil
+	}
+	return os.Create(name)
+}
+
+// stdinCount counts the inputs naming the standard input, which can only
+// be read once.
+func stdinCount(names []string) int {
+	count := 0
+	for _, name := range names {
+		if name == "-" {
+			count++
+		}
+	}
+	return count
+}
+
+// fail reports an error on the standard error and exits with status 1.
+func fail(format string, a ...interface{}) {
+	fmt.Fprintf(os.Stderr, "%v: %v\n", os.Args[0], fmt.Sprintf(format, a...))
+	os.Exit(1)
+}
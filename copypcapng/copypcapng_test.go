@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/RajeshGottlieb/go/pcapng"
+)
+
+func TestWriteBlock(t *testing.T) {
+
+	blocks := []pcapng.Block{
+		&pcapng.SectionBlock{MajorVersion: 1, SectionLength: -1},
+		&pcapng.DecryptionSecretsBlock{
+			SecretsType: pcapng.SECRETS_TYPE_TLS,
+			SecretsData: []byte("CLIENT_RANDOM 0011 2233\n"),
+			Options:     []pcapng.Option{&pcapng.Opt_Comment{Value: "keys"}},
+		},
+		&pcapng.InterfaceBlock{LinkType: 1},
+	}
+	var input bytes.Buffer
+	pw := pcapng.Writer(&input)
+	pw.Endian = binary.LittleEndian
+	for _, b := range blocks {
+		if err := pw.Write(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// every block read is copied unchanged
+	var output bytes.Buffer
+	pw = pcapng.Writer(&output)
+	pw.Endian = binary.LittleEndian
+	pr := pcapng.Reader(bytes.NewReader(input.Bytes()))
+	for count := 0; ; count++ {
+		block, err := pr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := writeBlock(pw, ioutil.Discard, block, count); err != nil {
+			t.Fatalf("block %v: %v", count, err)
+		}
+	}
+	if !bytes.Equal(output.Bytes(), input.Bytes()) {
+		t.Errorf("copied %v bytes to %v bytes that differ", input.Len(), output.Len())
+	}
+
+	// a block type without its own branch is still written
+	output.Reset()
+	if err := writeBlock(pw, ioutil.Discard, &pcapng.CustomBlock{Type: pcapng.CUSTOM_BLOCK, PEN: 32473, Data: []byte{1, 2, 3, 4}}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if output.Len() != 20 {
+		t.Errorf("custom block written as %v bytes, want 20", output.Len())
+	}
+}
@@ -2,4 +2,17 @@ module github.com/RajeshGottlieb/go/copypcapng
 
 go 1.15
 
-require github.com/RajeshGottlieb/go/pcapng v0.0.0-20220111023523-36ac2320516d
+require (
+	github.com/RajeshGottlieb/go/geoip v0.0.0-00010101000000-000000000000 // indirect
+	github.com/RajeshGottlieb/go/packet v0.0.0-00010101000000-000000000000 // indirect
+	github.com/RajeshGottlieb/go/pcapng v0.0.0-00010101000000-000000000000
+	github.com/RajeshGottlieb/go/transform v0.0.0-00010101000000-000000000000
+)
+
+replace github.com/RajeshGottlieb/go/geoip => ../geoip
+
+replace github.com/RajeshGottlieb/go/packet => ../packet
+
+replace github.com/RajeshGottlieb/go/pcapng => ../pcapng
+
+replace github.com/RajeshGottlieb/go/transform => ../transform
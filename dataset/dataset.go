@@ -0,0 +1,309 @@
+// Package dataset reads an ordered list of capture files, such as the
+// files of a rotated capture, as one stream of packets.
+package dataset
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/RajeshGottlieb/go/pcapng"
+)
+
+// DatasetError
+type DatasetError struct {
+	errorString string
+}
+
+func (de *DatasetError) Error() string {
+	return de.errorString
+}
+
+// Packet is an Enhanced Packet Block of the dataset.
+type Packet struct {
+	File      int // index into Dataset.Files
+	Timestamp time.Time
+	Interface *pcapng.InterfaceBlock
+	Block     *pcapng.EnhancedPacketBlock
+}
+
+// FileInfo is the entry of one file in the combined index.
+type FileInfo struct {
+	Name    string
+	Size    int64
+	Packets int
+	First   time.Time // of the first packet, zero if there are none
+	Last    time.Time // of the last packet
+}
+
+// fileIndex is the block index of one file.
+type fileIndex struct {
+	info    FileInfo
+	idx     *pcapng.Index
+	packets []int // entries of the Enhanced Packet Blocks
+}
+
+// Dataset reads the packets of Files in order, continuing from the end of
+// one file with the start of the next.
+type Dataset struct {
+	Files []string
+
+	indexes    []*fileIndex // built by Index
+	file       int          // being read, len(Files) at the end
+	fh         *os.File
+	pr         *pcapng.PcapngReader
+	interfaces []*pcapng.InterfaceBlock // of the current section
+}
+
+// Open returns a Dataset of the named files, in the order given.
+func Open(names ...string) *Dataset {
+	return &Dataset{Files: names}
+}
+
+// Glob returns a Dataset of the files matching pattern, sorted by name,
+// which orders the files of a rotated capture named by time or sequence.
+func Glob(pattern string) (*Dataset, error) {
+	names, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return nil, &DatasetError{fmt.Sprintf("no files match %v", pattern)}
+	}
+	sort.Strings(names)
+	return Open(names...), nil
+}
+
+// ReadPacket returns the next packet, opening the next file at the end of
+// each, and io.EOF after the last.
+func (d *Dataset) ReadPacket() (*Packet, error) {
+
+	for d.file < len(d.Files) {
+		if d.pr == nil {
+			if err := d.open(d.file, 0, 0); err != nil {
+				return nil, err
+			}
+		}
+
+		block, err := d.pr.Read()
+		if err == io.EOF {
+			d.closeFile()
+			d.file++
+			continue
+		}
+		if err != nil {
+			return nil, &DatasetError{fmt.Sprintf("%v: %v", d.Files[d.file], err)}
+		}
+
+		switch b := block.(type) {
+		case *pcapng.SectionBlock:
+			d.interfaces = nil
+		case *pcapng.InterfaceBlock:
+			d.interfaces = append(d.interfaces, b)
+		case *pcapng.EnhancedPacketBlock:
+			if int(b.InterfaceID) >= len(d.interfaces) {
+				return nil, &DatasetError{fmt.Sprintf("%v: packet references undefined interface %v", d.Files[d.file], b.InterfaceID)}
+			}
+			ifb := d.interfaces[b.InterfaceID]
+			return &Packet{d.file, ifb.PacketTime(b.TimestampHigh, b.TimestampLow), ifb, b}, nil
+		}
+	}
+	return nil, io.EOF
+}
+
+// open starts reading file i at offset, in the section with the given
+// byte order index of its block index.
+func (d *Dataset) open(i int, offset int64, section int) error {
+
+	d.closeFile()
+	fh, err := os.Open(d.Files[i])
+	if err != nil {
+		return err
+	}
+	info, err := fh.Stat()
+	if err != nil {
+		fh.Close()
+		return err
+	}
+
+	d.file, d.fh = i, fh
+	d.pr = pcapng.Reader(io.NewSectionReader(fh, offset, info.Size()-offset))
+	d.interfaces = nil
+	if offset > 0 {
+		d.pr.Endian = d.indexes[i].idx.Endians[section]
+	}
+	return nil
+}
+
+func (d *Dataset) closeFile() {
+	if d.fh != nil {
+		d.fh.Close()
+	}
+	d.fh, d.pr = nil, nil
+}
+
+// Close closes the file being read.
+func (d *Dataset) Close() error {
+	d.closeFile()
+	d.file = len(d.Files)
+	return nil
+}
+
+// Index returns the combined index of the files, building it the first
+// time from the block headers and the first and last packet of each file.
+func (d *Dataset) Index() ([]FileInfo, error) {
+
+	if d.indexes == nil {
+		indexes := make([]*fileIndex, len(d.Files))
+		for i, name := range d.Files {
+			fi, err := buildFileIndex(name)
+			if err != nil {
+				return nil, &DatasetError{fmt.Sprintf("%v: %v", name, err)}
+			}
+			indexes[i] = fi
+		}
+		d.indexes = indexes
+	}
+
+	infos := make([]FileInfo, len(d.indexes))
+	for i, fi := range d.indexes {
+		infos[i] = fi.info
+	}
+	return infos, nil
+}
+
+func buildFileIndex(name string) (*fileIndex, error) {
+
+	fh, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	info, err := fh.Stat()
+	if err != nil {
+		return nil, err
+	}
+	idx, err := pcapng.BuildIndex(fh, info.Size())
+	if err != nil {
+		return nil, err
+	}
+
+	fi := &fileIndex{info: FileInfo{Name: name, Size: info.Size()}, idx: idx}
+	for i, e := range idx.Entries {
+		if e.Type == pcapng.ENHANCED_PACKET_BLOCK {
+			fi.packets = append(fi.packets, i)
+		}
+	}
+	fi.info.Packets = len(fi.packets)
+	if len(fi.packets) > 0 {
+		if fi.info.First, err = fi.packetTime(fh, 0); err != nil {
+			return nil, err
+		}
+		if fi.info.Last, err = fi.packetTime(fh, len(fi.packets)-1); err != nil {
+			return nil, err
+		}
+	}
+	return fi, nil
+}
+
+// interfaces reads the Interface Description Blocks of the section of
+// entry that come before it.
+func (fi *fileIndex) interfaces(r io.ReaderAt, entry int) ([]*pcapng.InterfaceBlock, error) {
+	var ifbs []*pcapng.InterfaceBlock
+	section := fi.idx.Entries[entry].Section
+	for i := entry - 1; i >= 0 && fi.idx.Entries[i].Section == section; i-- {
+		if fi.idx.Entries[i].Type != pcapng.INTERFACE_DESCRIPTION_BLOCK {
+			continue
+		}
+		block, err := fi.idx.ReadBlock(r, i)
+		if err != nil {
+			return nil, err
+		}
+		ifbs = append([]*pcapng.InterfaceBlock{block.(*pcapng.InterfaceBlock)}, ifbs...)
+	}
+	return ifbs, nil
+}
+
+// packetTime returns the timestamp of the n'th packet of the file.
+func (fi *fileIndex) packetTime(r io.ReaderAt, n int) (time.Time, error) {
+	entry := fi.packets[n]
+	block, err := fi.idx.ReadBlock(r, entry)
+	if err != nil {
+		return time.Time{}, err
+	}
+	b := block.(*pcapng.EnhancedPacketBlock)
+	ifbs, err := fi.interfaces(r, entry)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if int(b.InterfaceID) >= len(ifbs) {
+		return time.Time{}, &DatasetError{fmt.Sprintf("packet references undefined interface %v", b.InterfaceID)}
+	}
+	return ifbs[b.InterfaceID].PacketTime(b.TimestampHigh, b.TimestampLow), nil
+}
+
+// SeekTime positions the dataset so the next ReadPacket returns the first
+// packet at or after t, or io.EOF if there is none. The files must be in
+// time order and their packets too, as a capture writes them.
+func (d *Dataset) SeekTime(t time.Time) error {
+
+	if _, err := d.Index(); err != nil {
+		return err
+	}
+
+	// the first file whose last packet is not before t. An empty file
+	// takes the last packet of the file before it, so the predicate stays
+	// monotonic.
+	lasts := make([]time.Time, len(d.indexes))
+	var last time.Time
+	for i, fi := range d.indexes {
+		if fi.info.Packets > 0 {
+			last = fi.info.Last
+		}
+		lasts[i] = last
+	}
+	file := sort.Search(len(d.indexes), func(i int) bool {
+		return !lasts[i].Before(t)
+	})
+	for file < len(d.indexes) && d.indexes[file].info.Packets == 0 {
+		file++ // leading empty files, for a t before any packet
+	}
+	if file == len(d.indexes) {
+		d.Close()
+		return nil
+	}
+
+	fi := d.indexes[file]
+	fh, err := os.Open(fi.info.Name)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	var searchErr error
+	n := sort.Search(len(fi.packets), func(n int) bool {
+		pt, err := fi.packetTime(fh, n)
+		if err != nil {
+			searchErr = err
+			return true
+		}
+		return !pt.Before(t)
+	})
+	if searchErr != nil {
+		return &DatasetError{fmt.Sprintf("%v: %v", fi.info.Name, searchErr)}
+	}
+
+	entry := fi.idx.Entries[fi.packets[n]]
+	ifbs, err := fi.interfaces(fh, fi.packets[n])
+	if err != nil {
+		return err
+	}
+	if err := d.open(file, entry.Offset, entry.Section); err != nil {
+		return err
+	}
+	d.interfaces = ifbs
+	return nil
+}
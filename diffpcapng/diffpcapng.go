@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"github.com/RajeshGottlieb/go/pcapng"
+	"os"
+)
+
+func main() {
+
+	max := flag.Int("max", 100, "stop after this many differences, 0 for all")
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		fmt.Printf("usage: %v [-max n] <first-pcapng> <second-pcapng>\n", os.Args[0])
+		os.Exit(2)
+	}
+
+	a, err := os.Open(flag.Arg(0))
+	if err != nil {
+		panic(err)
+	}
+	defer a.Close()
+
+	b, err := os.Open(flag.Arg(1))
+	if err != nil {
+		panic(err)
+	}
+	defer b.Close()
+
+	diffs, err := pcapng.Diff(bufio.NewReader(a), bufio.NewReader(b), *max)
+	if err != nil {
+		panic(err)
+	}
+	for _, d := range diffs {
+		fmt.Println(d)
+	}
+	if len(diffs) > 0 {
+		os.Exit(1)
+	}
+}
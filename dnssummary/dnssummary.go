@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/RajeshGottlieb/go/pcapng"
+	"github.com/RajeshGottlieb/go/stats"
+	"io"
+	"os"
+)
+
+func main() {
+
+	format := flag.String("format", "json", "output format, json or csv")
+	nrb := flag.String("nrb", "", "also copy the input to this pcapng file with a Name Resolution Block of the answers appended")
+	flag.Parse()
+
+	if flag.NArg() != 1 || (*format != "json" && *format != "csv") {
+		fmt.Printf("usage: %v [-format json|csv] [-nrb output-pcapng] <input-pcapng>\n", os.Args[0])
+		return
+	}
+
+	rfh, err := os.Open(flag.Arg(0))
+	if err != nil {
+		panic(err)
+	}
+	defer rfh.Close()
+
+	dns := stats.NewDNS()
+	if err := stats.Scan(pcapng.Reader(rfh), dns); err != nil {
+		panic(err)
+	}
+
+	if *format == "csv" {
+		err = dns.WriteCSV(os.Stdout)
+	} else {
+		err = dns.WriteJSON(os.Stdout)
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	if *nrb == "" {
+		return
+	}
+
+	if _, err := rfh.Seek(0, io.SeekStart); err != nil {
+		panic(err)
+	}
+	pr := pcapng.Reader(rfh)
+
+	wfh, err := os.Create(*nrb)
+	if err != nil {
+		panic(err)
+	}
+	defer wfh.Close()
+
+	pw := pcapng.Writer(wfh)
+
+	for {
+		block, err := pr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			panic(err)
+		}
+		if err := pw.Write(block.(pcapng.Block)); err != nil {
+			panic(err)
+		}
+	}
+
+	if err := pw.Write(dns.NameResolutionBlock()); err != nil {
+		panic(err)
+	}
+}
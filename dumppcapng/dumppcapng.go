@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"github.com/RajeshGottlieb/go/geoip"
+	"github.com/RajeshGottlieb/go/oui"
+	"github.com/RajeshGottlieb/go/pcapng"
+	"github.com/RajeshGottlieb/go/stats"
+	"io"
+	"os"
+	"strings"
+)
+
+const defaultTemplate = `{{.Number}} {{printf "%.6f" .Relative.Seconds}} {{.Src}} > {{.Dst}} {{.Protocol}} {{.Length}}`
+
+func main() {
+
+	text := flag.String("template", defaultTemplate, "text/template over the fields of each packet")
+	fields := flag.String("fields", "", "comma separated fields to write instead of a template, e.g. number,time,src,dst,length")
+	separator := flag.String("separator", "\t", "between the -fields")
+	follow := flag.Bool("follow", false, "keep reading as the file grows, like tail -f")
+	idle := flag.Duration("idle", 0, "with -follow, stop once the file has not grown for this long")
+	presetName := flag.String("preset", "lenient", "how to treat problems in the input: strict, lenient or forensic")
+	geo := flag.String("geoip", "", "comma separated MaxMind DB files for the srccountry, srcasn, srcorg and dst fields")
+	ouiFiles := flag.String("oui", "", "comma separated manuf or IEEE OUI files to add to the built-in vendor table")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Printf("usage: %v [-template text | -fields names [-separator s]] [-follow [-idle d]] [-preset name] [-geoip files] [-oui files] <input-pcapng>\n", os.Args[0])
+		return
+	}
+
+	preset, err := pcapng.ParsePreset(*presetName)
+	if err != nil {
+		panic(err)
+	}
+
+	if *fields != "" {
+		var err error
+		if *text, err = stats.FieldsTemplate(strings.Split(*fields, ","), *separator); err != nil {
+			panic(err)
+		}
+	}
+
+	var in io.ReadCloser
+	var out io.Writer
+	if *follow {
+		fl, err := pcapng.Follow(flag.Arg(0))
+		if err != nil {
+			panic(err)
+		}
+		fl.Idle = *idle
+		// unbuffered, so each packet shows up as it is captured
+		in, out = fl, os.Stdout
+	} else {
+		fh, err := os.Open(flag.Arg(0))
+		if err != nil {
+			panic(err)
+		}
+		w := bufio.NewWriter(os.Stdout)
+		defer w.Flush()
+		in, out = fh, w
+	}
+	defer in.Close()
+
+	t, err := stats.NewTemplate(out, *text)
+	if err != nil {
+		panic(err)
+	}
+	if *ouiFiles != "" {
+		if t.OUI, err = oui.Load(strings.Split(*ouiFiles, ",")...); err != nil {
+			panic(err)
+		}
+	}
+	if *geo != "" {
+		if t.GeoIP, err = geoip.OpenSet(strings.Split(*geo, ",")...); err != nil {
+			panic(err)
+		}
+	}
+	pr := pcapng.ReaderWithPreset(bufio.NewReader(in), preset)
+	if err := stats.Scan(pr, t); err != nil {
+		panic(err)
+	}
+	for _, warning := range pr.Warnings {
+		fmt.Fprintln(os.Stderr, warning)
+	}
+	if lost := pr.Stats().LostWarnings; lost > 0 {
+		fmt.Fprintf(os.Stderr, "%v more warnings not kept\n", lost)
+	}
+	if t.Err != nil {
+		panic(t.Err)
+	}
+}
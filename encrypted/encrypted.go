@@ -0,0 +1,243 @@
+// Package encrypted stores captures at rest in an AES-GCM encrypted
+// container, encrypted and decrypted as a stream of chunks like age does.
+//
+// The file starts with a magic number and a random 16 byte nonce. The key
+// of the payload is derived from the file key and the nonce with
+// HKDF-SHA256, so a key can be reused for many files. The payload is split
+// into chunks of ChunkSize bytes, each sealed with AES-256-GCM under a
+// nonce of an 11 byte chunk counter and a byte that is 1 for the last
+// chunk only, so reordered, dropped or truncated chunks are detected.
+package encrypted
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+)
+
+// EncryptedError is returned for a bad key, a file that is not an encrypted
+// capture or whose chunks do not authenticate, and for writes to a closed
+// Writer.
+type EncryptedError struct {
+	errorString string
+}
+
+func (ee *EncryptedError) Error() string {
+	return ee.errorString
+}
+
+const (
+	magic     = "pcapenc\x01"
+	nonceSize = 16
+	// ChunkSize is the amount of plaintext in every chunk but the last.
+	ChunkSize = 64 << 10
+	// KeySize is the length of a file key.
+	KeySize = 32
+)
+
+// GenerateKey returns a new random file key.
+func GenerateKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// payloadCipher derives the payload key from key and nonce with HKDF-SHA256.
+func payloadCipher(key, nonce []byte) (cipher.AEAD, error) {
+
+	if len(key) != KeySize {
+		return nil, &EncryptedError{fmt.Sprintf("key of %v bytes, not %v", len(key), KeySize)}
+	}
+
+	extract := hmac.New(sha256.New, nonce)
+	extract.Write(key)
+	expand := hmac.New(sha256.New, extract.Sum(nil))
+	expand.Write([]byte("payload\x01"))
+
+	block, err := aes.NewCipher(expand.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce returns the GCM nonce of chunk counter.
+func chunkNonce(counter uint64, last bool) []byte {
+	nonce := make([]byte, 12)
+	for i := 10; i >= 3; i-- {
+		nonce[i] = byte(counter)
+		counter >>= 8
+	}
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// Writer encrypts what is written to it. Close must be called to write
+// the last chunk, without it the file cannot be decrypted.
+type Writer struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	buf     []byte
+	counter uint64
+	err     error
+}
+
+// NewWriter writes the header to w and returns a Writer encrypting with key.
+func NewWriter(w io.Writer, key []byte) (*Writer, error) {
+
+	nonce := make([]byte, nonceSize)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	aead, err := payloadCipher(key, nonce)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(append([]byte(magic), nonce...)); err != nil {
+		return nil, err
+	}
+	return &Writer{w: w, aead: aead, buf: make([]byte, 0, ChunkSize)}, nil
+}
+
+// Write encrypts b, writing each chunk once it is full and more follows.
+func (ew *Writer) Write(b []byte) (int, error) {
+
+	n := 0
+	for ew.err == nil && len(b) > 0 {
+		// a full chunk is only sealed once more data shows it is not the last
+		if len(ew.buf) == ChunkSize {
+			ew.seal(false)
+			continue
+		}
+		m := copy(ew.buf[len(ew.buf):ChunkSize], b)
+		ew.buf = ew.buf[:len(ew.buf)+m]
+		b = b[m:]
+		n += m
+	}
+	return n, ew.err
+}
+
+// seal writes the buffered chunk.
+func (ew *Writer) seal(last bool) {
+	if ew.counter == 1<<64-1 {
+		ew.err = &EncryptedError{"too many chunks"}
+		return
+	}
+	chunk := ew.aead.Seal(nil, chunkNonce(ew.counter, last), ew.buf, nil)
+	if _, err := ew.w.Write(chunk); err != nil {
+		ew.err = err
+		return
+	}
+	ew.counter++
+	ew.buf = ew.buf[:0]
+}
+
+// Close writes the last chunk. It does not close the underlying writer.
+func (ew *Writer) Close() error {
+	if ew.err != nil {
+		return ew.err
+	}
+	ew.seal(true)
+	if ew.err == nil {
+		ew.err = &EncryptedError{"writer is closed"}
+		return nil
+	}
+	return ew.err
+}
+
+// Reader decrypts a file written by Writer as it is read.
+type Reader struct {
+	r       io.Reader
+	aead    cipher.AEAD
+	chunk   []byte // encrypted
+	data    []byte // decrypted and not yet read
+	counter uint64
+	last    bool
+}
+
+// NewReader reads the header from r and returns a Reader decrypting with key.
+func NewReader(r io.Reader, key []byte) (*Reader, error) {
+
+	header := make([]byte, len(magic)+nonceSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, &EncryptedError{"file is too short for a header"}
+		}
+		return nil, err
+	}
+	if string(header[:len(magic)]) != magic {
+		return nil, &EncryptedError{"not an encrypted capture"}
+	}
+	aead, err := payloadCipher(key, header[len(magic):])
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{r: r, aead: aead, chunk: make([]byte, ChunkSize+aead.Overhead()+1)}, nil
+}
+
+// Read decrypts the next chunk when the previous one has been read. A
+// chunk that does not authenticate is an error, as is a file that ends
+// before its last chunk.
+func (er *Reader) Read(b []byte) (int, error) {
+
+	for len(er.data) == 0 {
+		if er.last {
+			return 0, io.EOF
+		}
+		if err := er.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(b, er.data)
+	er.data = er.data[n:]
+	return n, nil
+}
+
+// next decrypts the next chunk. One byte past a full chunk is read ahead,
+// as only the end of the file tells the last chunk from the others.
+func (er *Reader) next() error {
+
+	full := ChunkSize + er.aead.Overhead()
+	have := 0
+	if er.counter > 0 {
+		have = 1 // the byte read ahead of the previous chunk
+	}
+	n, err := io.ReadFull(er.r, er.chunk[have:])
+	n += have
+	switch {
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		er.last = true
+	case err != nil:
+		return err
+	}
+	if n > full {
+		n = full
+	}
+	if n < er.aead.Overhead() {
+		return &EncryptedError{fmt.Sprintf("chunk %v is truncated", er.counter)}
+	}
+
+	data, err := er.aead.Open(er.chunk[:0], chunkNonce(er.counter, er.last), er.chunk[:n], nil)
+	if err != nil {
+		if !er.last {
+			return &EncryptedError{fmt.Sprintf("chunk %v does not authenticate", er.counter)}
+		}
+		return &EncryptedError{fmt.Sprintf("chunk %v does not authenticate or the file is truncated", er.counter)}
+	}
+	er.data = data
+	if !er.last {
+		// keep the byte read ahead at the start of the buffer
+		er.data = append([]byte(nil), data...)
+		er.chunk[0] = er.chunk[full]
+	}
+	er.counter++
+	return nil
+}
@@ -0,0 +1,177 @@
+package encrypted
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+)
+
+const (
+	headerSize = len(magic) + nonceSize
+	sealedSize = ChunkSize + 16 // a full chunk with its GCM tag
+)
+
+func encrypt(t *testing.T, key, data []byte) []byte {
+	var buf bytes.Buffer
+	ew, err := NewWriter(&buf, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// uneven writes that do not line up with the chunks
+	for b := data; len(b) > 0; {
+		n := 10000
+		if n > len(b) {
+			n = len(b)
+		}
+		if m, err := ew.Write(b[:n]); m != n || err != nil {
+			t.Fatalf("Write: %v, %v", m, err)
+		}
+		b = b[n:]
+	}
+	if err := ew.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func decrypt(key, file []byte) ([]byte, error) {
+	er, err := NewReader(bytes.NewReader(file), key)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(er)
+}
+
+func TestRoundTrip(t *testing.T) {
+
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rnd := rand.New(rand.NewSource(1))
+
+	tests := []struct {
+		name   string
+		size   int
+		chunks int
+	}{
+		{"empty", 0, 1},
+		{"one byte", 1, 1},
+		{"a byte short of a chunk", ChunkSize - 1, 1},
+		{"one chunk", ChunkSize, 1},
+		{"a chunk and a byte", ChunkSize + 1, 2},
+		{"three chunks", 3 * ChunkSize, 3},
+		{"three and a half chunks", 3*ChunkSize + ChunkSize/2, 4},
+	}
+
+	for _, tt := range tests {
+		data := make([]byte, tt.size)
+		rnd.Read(data)
+		file := encrypt(t, key, data)
+		if want := headerSize + tt.size + tt.chunks*16; len(file) != want {
+			t.Errorf("%v: file of %v bytes, want %v", tt.name, len(file), want)
+		}
+		got, err := decrypt(key, file)
+		if err != nil {
+			t.Errorf("%v: %v", tt.name, err)
+			continue
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("%v: decrypted %v bytes that differ from the %v written", tt.name, len(got), len(data))
+		}
+	}
+}
+
+func TestTampered(t *testing.T) {
+
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make([]byte, 3*ChunkSize+100)
+	rand.New(rand.NewSource(1)).Read(data)
+	file := encrypt(t, key, data)
+
+	chunk := func(i int) []byte {
+		start := headerSize + i*sealedSize
+		end := start + sealedSize
+		if end > len(file) {
+			end = len(file)
+		}
+		return file[start:end]
+	}
+	join := func(parts ...[]byte) []byte {
+		return bytes.Join(parts, nil)
+	}
+	flipped := func(at int) []byte {
+		b := append([]byte(nil), file...)
+		b[at] ^= 1
+		return b
+	}
+	header := file[:headerSize]
+	otherKey, _ := GenerateKey()
+
+	tests := []struct {
+		name string
+		key  []byte
+		file []byte
+	}{
+		{"empty", key, nil},
+		{"header only", key, header},
+		{"short header", key, header[:headerSize-1]},
+		{"bad magic", key, flipped(0)},
+		{"flipped nonce", key, flipped(len(magic))},
+		{"truncated at a chunk boundary", key, join(header, chunk(0), chunk(1))},
+		{"truncated in a chunk", key, file[:len(file)-1]},
+		{"truncated in the tag of the last chunk", key, file[:len(file)-10]},
+		{"dropped chunk", key, join(header, chunk(0), chunk(2), chunk(3))},
+		{"reordered chunks", key, join(header, chunk(1), chunk(0), chunk(2), chunk(3))},
+		{"last chunk moved", key, join(header, chunk(0), chunk(1), chunk(3))},
+		{"flipped first chunk", key, flipped(headerSize + 5)},
+		{"flipped last chunk", key, flipped(len(file) - 5)},
+		{"appended", key, join(file, []byte{0})},
+		{"wrong key", otherKey, file},
+		{"short key", key[:16], file},
+	}
+
+	for _, tt := range tests {
+		if got, err := decrypt(tt.key, tt.file); err == nil {
+			t.Errorf("%v: decrypted %v bytes without an error", tt.name, len(got))
+		}
+	}
+}
+
+func TestWriterClosed(t *testing.T) {
+
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	ew, err := NewWriter(&buf, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ew.Write([]byte("packet")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ew.Close(); err != nil {
+		t.Fatal(err)
+	}
+	size := buf.Len()
+
+	if n, err := ew.Write([]byte("more")); n != 0 || err == nil {
+		t.Errorf("Write after Close: %v, %v", n, err)
+	}
+	if err := ew.Close(); err == nil {
+		t.Errorf("second Close succeeded")
+	}
+	if buf.Len() != size {
+		t.Errorf("%v bytes written after Close", buf.Len()-size)
+	}
+
+	if _, err := NewWriter(&buf, key[:KeySize-1]); err == nil {
+		t.Errorf("NewWriter accepted a short key")
+	}
+}
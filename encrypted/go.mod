@@ -0,0 +1,3 @@
+module github.com/RajeshGottlieb/go/encrypted
+
+go 1.15
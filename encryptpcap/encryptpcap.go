@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"github.com/RajeshGottlieb/go/encrypted"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+func main() {
+
+	keyFile := flag.String("key", "", "file holding the key as 64 hex digits")
+	decrypt := flag.Bool("d", false, "decrypt rather than encrypt")
+	genKey := flag.Bool("genkey", false, "write a new random key to the -key file and exit")
+	flag.Parse()
+
+	if *keyFile == "" || (!*genKey && flag.NArg() != 2) {
+		fmt.Printf("usage: %v -key <key-file> [-d] <input> <output>\n", os.Args[0])
+		fmt.Printf("       %v -key <key-file> -genkey\n", os.Args[0])
+		return
+	}
+
+	if *genKey {
+		key, err := encrypted.GenerateKey()
+		if err != nil {
+			panic(err)
+		}
+		if err := ioutil.WriteFile(*keyFile, []byte(hex.EncodeToString(key)+"\n"), 0600); err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	text, err := ioutil.ReadFile(*keyFile)
+	if err != nil {
+		panic(err)
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(text)))
+	if err != nil {
+		panic(err)
+	}
+
+	rfh, err := os.Open(flag.Arg(0))
+	if err != nil {
+		panic(err)
+	}
+	defer rfh.Close()
+
+	wfh, err := os.Create(flag.Arg(1))
+	if err != nil {
+		panic(err)
+	}
+	defer wfh.Close()
+
+	bw := bufio.NewWriter(wfh)
+
+	if *decrypt {
+		er, err := encrypted.NewReader(bufio.NewReader(rfh), key)
+		if err != nil {
+			panic(err)
+		}
+		if _, err := io.Copy(bw, er); err != nil {
+			panic(err)
+		}
+	} else {
+		ew, err := encrypted.NewWriter(bw, key)
+		if err != nil {
+			panic(err)
+		}
+		if _, err := io.Copy(ew, rfh); err != nil {
+			panic(err)
+		}
+		if err := ew.Close(); err != nil {
+			panic(err)
+		}
+	}
+
+	if err := bw.Flush(); err != nil {
+		panic(err)
+	}
+}
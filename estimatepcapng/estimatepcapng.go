@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/RajeshGottlieb/go/pcapng"
+	"os"
+)
+
+func main() {
+
+	format := flag.String("format", "pcapng", "output format, pcapng or pcap")
+	snapLen := flag.Int("snaplen", 0, "truncate packets to this many bytes, 0 to keep them whole")
+	ratio := flag.Float64("ratio", 0, "expected compressed size over uncompressed size, e.g. 0.4")
+	sample := flag.Int("sample", 0, "scan only this many blocks and extrapolate, 0 to scan all")
+	quota := flag.Int64("quota", 0, "exit with status 1 if the output would be larger than this many bytes")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Printf("usage: %v [-format pcapng|pcap] [-snaplen n] [-ratio r] [-sample n] [-quota bytes] <input-pcapng>\n", os.Args[0])
+		os.Exit(2)
+	}
+
+	fh, err := os.Open(flag.Arg(0))
+	if err != nil {
+		panic(err)
+	}
+	defer fh.Close()
+
+	info, err := fh.Stat()
+	if err != nil {
+		panic(err)
+	}
+
+	opts := pcapng.EstimateOptions{Format: *format, SnapLen: uint32(*snapLen), Ratio: *ratio, Sample: *sample}
+	est, err := pcapng.EstimateSize(fh, info.Size(), opts)
+	if err != nil {
+		panic(err)
+	}
+
+	exact := "estimated"
+	if est.Exact && *ratio == 0 {
+		exact = "exact"
+	}
+	fmt.Printf("packets %v\nbytes %v\ncompressed %v\n%v\n", est.Packets, est.Bytes, est.Compressed, exact)
+
+	if *quota > 0 && est.Compressed > *quota {
+		fmt.Printf("over the quota of %v bytes\n", *quota)
+		os.Exit(1)
+	}
+}
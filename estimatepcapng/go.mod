@@ -0,0 +1,7 @@
+module github.com/RajeshGottlieb/go/estimatepcapng
+
+go 1.15
+
+require github.com/RajeshGottlieb/go/pcapng v0.0.0-00010101000000-000000000000
+
+replace github.com/RajeshGottlieb/go/pcapng => ../pcapng
@@ -0,0 +1,124 @@
+// Package extcap implements the Wireshark extcap interface so Go capture
+// sources can appear as Wireshark capture interfaces.
+package extcap
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/RajeshGottlieb/go/capture"
+	"github.com/RajeshGottlieb/go/pcapng"
+)
+
+// ExtcapError
+type ExtcapError struct {
+	errorString string
+}
+
+func (ee *ExtcapError) Error() string {
+	return ee.errorString
+}
+
+// Interface is one capture interface offered to Wireshark.
+type Interface struct {
+	Value   string // name passed back in --extcap-interface
+	Display string // name shown in Wireshark
+	DLT     uint16 // link type of the captured packets
+	DLTName string // libpcap name of the link type, e.g. EN10MB
+	// Open starts a capture with the capture filter entered in Wireshark.
+	Open func(filter string) (capture.Source, error)
+}
+
+// Extcap describes the interfaces of an extcap program.
+type Extcap struct {
+	Version    string
+	Help       string // URL shown by Wireshark
+	Interfaces []Interface
+	Output     io.Writer // where the handshake replies go, os.Stdout if nil
+}
+
+// Main handles one invocation by Wireshark. args are the command line arguments without the program name.
+func (e *Extcap) Main(args []string) error {
+
+	fs := flag.NewFlagSet("extcap", flag.ContinueOnError)
+	listInterfaces := fs.Bool("extcap-interfaces", false, "list the interfaces")
+	fs.String("extcap-version", "", "Wireshark version")
+	listDLTs := fs.Bool("extcap-dlts", false, "list the link types of an interface")
+	listConfig := fs.Bool("extcap-config", false, "list the configuration options of an interface")
+	ifname := fs.String("extcap-interface", "", "interface to use")
+	doCapture := fs.Bool("capture", false, "start capturing")
+	fifo := fs.String("fifo", "", "FIFO to write the capture to")
+	filter := fs.String("extcap-capture-filter", "", "capture filter")
+	fs.String("extcap-control-in", "", "control pipe from Wireshark")
+	fs.String("extcap-control-out", "", "control pipe to Wireshark")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	out := e.Output
+	if out == nil {
+		out = os.Stdout
+	}
+
+	if *listInterfaces {
+		fmt.Fprintf(out, "extcap {version=%v}{help=%v}\n", e.Version, e.Help)
+		for _, ifc := range e.Interfaces {
+			fmt.Fprintf(out, "interface {value=%v}{display=%v}\n", ifc.Value, ifc.Display)
+		}
+		return nil
+	}
+
+	ifc, err := e.lookup(*ifname)
+	if err != nil {
+		return err
+	}
+
+	if *listDLTs {
+		fmt.Fprintf(out, "dlt {number=%v}{name=%v}{display=%v}\n", ifc.DLT, ifc.DLTName, ifc.Display)
+		return nil
+	}
+	if *listConfig {
+		return nil // no configuration options
+	}
+	if *doCapture {
+		if *fifo == "" {
+			return &ExtcapError{"--capture needs --fifo"}
+		}
+		return e.capture(ifc, *fifo, *filter)
+	}
+	return &ExtcapError{"nothing to do"}
+}
+
+func (e *Extcap) lookup(name string) (*Interface, error) {
+	for i := range e.Interfaces {
+		if e.Interfaces[i].Value == name {
+			return &e.Interfaces[i], nil
+		}
+	}
+	return nil, &ExtcapError{fmt.Sprintf("unknown interface %q", name)}
+}
+
+// capture streams packets into the FIFO until the source fails or Wireshark closes the FIFO.
+func (e *Extcap) capture(ifc *Interface, fifo, filter string) error {
+
+	src, err := ifc.Open(filter)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	fh, err := os.OpenFile(fifo, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	err = capture.Capture(src, pcapng.Writer(fh), 0)
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/RajeshGottlieb/go/packet"
+	"github.com/RajeshGottlieb/go/pcapng"
+	"github.com/RajeshGottlieb/go/transform"
+	"os"
+)
+
+func main() {
+
+	flowArg := flag.String("flow", "", "the connection to extract, e.g. \"tcp 10.0.0.1:40000 > 10.0.0.2:80\"")
+	number := flag.Int("packet", 0, "extract the connection containing this packet number (1 based)")
+	payload := flag.String("payload", "", "also write the payload of each direction to <prefix>.client and <prefix>.server")
+	flag.Parse()
+
+	if flag.NArg() != 2 || (*flowArg == "") == (*number == 0) {
+		fmt.Printf("usage: %v -flow <flow> | -packet n [-payload prefix] <input-pcapng> <output-pcapng>\n", os.Args[0])
+		return
+	}
+
+	rfh, err := os.Open(flag.Arg(0))
+	if err != nil {
+		panic(err)
+	}
+	defer rfh.Close()
+
+	var flow packet.Flow
+	if *flowArg != "" {
+		if flow, err = packet.ParseFlow(*flowArg); err != nil {
+			panic(err)
+		}
+	} else {
+		if flow, err = transform.FindFlow(pcapng.Reader(rfh), *number); err != nil {
+			panic(err)
+		}
+		if _, err := rfh.Seek(0, 0); err != nil {
+			panic(err)
+		}
+	}
+	fmt.Printf("following %v\n", flow)
+
+	follow := &transform.Follow{Flow: flow}
+	if *payload != "" {
+		cfh, err := os.Create(*payload + ".client")
+		if err != nil {
+			panic(err)
+		}
+		defer cfh.Close()
+		sfh, err := os.Create(*payload + ".server")
+		if err != nil {
+			panic(err)
+		}
+		defer sfh.Close()
+		follow.Client, follow.Server = cfh, sfh
+	}
+
+	wfh, err := os.Create(flag.Arg(1))
+	if err != nil {
+		panic(err)
+	}
+	defer wfh.Close()
+
+	if err := transform.Copy(pcapng.Reader(rfh), pcapng.Writer(wfh), follow); err != nil {
+		panic(err)
+	}
+	if follow.Err != nil {
+		panic(follow.Err)
+	}
+}
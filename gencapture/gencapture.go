@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"github.com/RajeshGottlieb/go/builder"
+	"github.com/RajeshGottlieb/go/packet"
+	"github.com/RajeshGottlieb/go/pcap"
+	"github.com/RajeshGottlieb/go/pcapng"
+	"os"
+	"time"
+)
+
+func main() {
+
+	flows := flag.Int("flows", 1, "number of flows, the source port counts up from that of -flow")
+	flow := flag.String("flow", "udp 10.0.0.1:40000 > 10.0.0.2:9", "the first flow")
+	packets := flag.Int("packets", 100, "packets per flow")
+	minSize := flag.Int("min", 64, "smallest payload in bytes")
+	maxSize := flag.Int("max", 1400, "largest payload in bytes")
+	rate := flag.Float64("rate", 10, "packets per second per flow")
+	jitter := flag.Duration("jitter", 0, "move every timestamp by up to this much either way")
+	seed := flag.Int64("seed", 1, "the same seed generates the same capture")
+	start := flag.String("start", "2020-01-01T00:00:00Z", "time of the first packet, RFC 3339")
+	writePcap := flag.Bool("pcap", false, "write pcap instead of pcapng")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Printf("usage: %v [-flows n] [-flow \"udp 10.0.0.1:40000 > 10.0.0.2:9\"] [-packets n] [-min bytes] [-max bytes] [-rate pps] [-jitter duration] [-seed n] [-start time] [-pcap] <output>\n", os.Args[0])
+		return
+	}
+
+	f, err := packet.ParseFlow(*flow)
+	if err != nil {
+		panic(err)
+	}
+	t, err := time.Parse(time.RFC3339Nano, *start)
+	if err != nil {
+		panic(err)
+	}
+
+	b := builder.New(t, *seed)
+	b.Jitter = *jitter
+	b.AddFlows(*flows, builder.FlowSpec{Flow: f, Packets: *packets, MinSize: *minSize, MaxSize: *maxSize, Rate: *rate})
+
+	fh, err := os.Create(flag.Arg(0))
+	if err != nil {
+		panic(err)
+	}
+	defer fh.Close()
+	w := bufio.NewWriter(fh)
+
+	if *writePcap {
+		header := pcap.PcapHdr{MagicNumber: pcap.MagicNanoseconds, VersionMajor: 2, VersionMinor: 4, Snaplen: 65535, Network: 1}
+		pw, err := pcap.NewWriter(w, header)
+		if err != nil {
+			panic(err)
+		}
+		err = b.Write(pw)
+	} else {
+		err = b.WritePcapng(pcapng.Writer(w))
+	}
+	if err != nil {
+		panic(err)
+	}
+	if err := w.Flush(); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,117 @@
+package geoip
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Info is what the databases know about an address.
+type Info struct {
+	Country     string `json:"country,omitempty"`      // ISO 3166-1 code, e.g. "DE"
+	CountryName string `json:"country_name,omitempty"` // in English
+	City        string `json:"city,omitempty"`         // in English
+	ASN         uint32 `json:"asn,omitempty"`
+	Org         string `json:"org,omitempty"` // of the autonomous system
+}
+
+// Empty reports whether nothing is known.
+func (i Info) Empty() bool {
+	return i == Info{}
+}
+
+// String formats the info like "DE Berlin AS3320 Deutsche Telekom AG".
+func (i Info) String() string {
+	var parts []string
+	if i.Country != "" {
+		parts = append(parts, i.Country)
+	}
+	if i.City != "" {
+		parts = append(parts, i.City)
+	}
+	if i.ASN != 0 {
+		parts = append(parts, fmt.Sprintf("AS%v", i.ASN))
+	}
+	if i.Org != "" {
+		parts = append(parts, i.Org)
+	}
+	return strings.Join(parts, " ")
+}
+
+// Info returns what the record of ip says about its country, city and
+// autonomous system, in the layout of the GeoIP2 and GeoLite2 Country,
+// City and ASN databases.
+func (db *DB) Info(ip net.IP) (Info, error) {
+
+	var info Info
+	v, _, err := db.Lookup(ip)
+	if err != nil || v == nil {
+		return info, err
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return info, nil
+	}
+
+	// the country of the address, or else of the network's registration
+	for _, key := range []string{"country", "registered_country"} {
+		if c, ok := m[key].(map[string]interface{}); ok && info.Country == "" {
+			info.Country, _ = c["iso_code"].(string)
+			info.CountryName = englishName(c)
+		}
+	}
+	if c, ok := m["city"].(map[string]interface{}); ok {
+		info.City = englishName(c)
+	}
+	if asn, ok := m["autonomous_system_number"].(uint64); ok {
+		info.ASN = uint32(asn)
+	}
+	info.Org, _ = m["autonomous_system_organization"].(string)
+	return info, nil
+}
+
+func englishName(m map[string]interface{}) string {
+	names, _ := m["names"].(map[string]interface{})
+	name, _ := names["en"].(string)
+	return name
+}
+
+// Set combines several databases, e.g. a country or city database with
+// an ASN one.
+type Set []*DB
+
+// OpenSet opens each of the files.
+func OpenSet(names ...string) (Set, error) {
+	var s Set
+	for _, name := range names {
+		db, err := Open(name)
+		if err != nil {
+			return nil, err
+		}
+		s = append(s, db)
+	}
+	return s, nil
+}
+
+// Info returns the fields found for ip in any of the databases, the first
+// database that has a field winning.
+func (s Set) Info(ip net.IP) (Info, error) {
+
+	var info Info
+	for _, db := range s {
+		i, err := db.Info(ip)
+		if err != nil {
+			return info, err
+		}
+		if info.Country == "" {
+			info.Country, info.CountryName = i.Country, i.CountryName
+		}
+		if info.City == "" {
+			info.City = i.City
+		}
+		if info.ASN == 0 {
+			info.ASN, info.Org = i.ASN, i.Org
+		}
+	}
+	return info, nil
+}
@@ -0,0 +1,3 @@
+module github.com/RajeshGottlieb/go/geoip
+
+go 1.15
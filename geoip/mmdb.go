@@ -0,0 +1,349 @@
+// Package geoip looks up IP addresses in MaxMind DB files, the format of
+// the GeoLite2 and GeoIP2 country, city and ASN databases.
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/big"
+	"net"
+)
+
+// GeoError is returned for files that are not MaxMind DBs or are corrupt.
+type GeoError struct {
+	errorString string
+}
+
+func (e *GeoError) Error() string {
+	return e.errorString
+}
+
+// metadataStart marks the metadata at the end of the file.
+var metadataStart = []byte("\xab\xcd\xefMaxMind.com")
+
+// Metadata describes a database.
+type Metadata struct {
+	DatabaseType string // e.g. "GeoLite2-Country"
+	Description  string // in English, if there is one
+	IPVersion    int    // 4 or 6
+	RecordSize   int    // 24, 28 or 32 bits
+	NodeCount    uint32
+	BuildEpoch   uint64 // seconds since 1970
+	Languages    []string
+}
+
+// DB is a MaxMind DB file read into memory.
+type DB struct {
+	Metadata Metadata
+
+	buf       []byte
+	data      []byte // the data section
+	ipv4Start uint32 // node of ::/96, where IPv4 lookups start
+}
+
+// Open reads the database in the file.
+func Open(name string) (*DB, error) {
+	buf, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	db, err := FromBytes(buf)
+	if err != nil {
+		return nil, &GeoError{fmt.Sprintf("%v: %v", name, err)}
+	}
+	return db, nil
+}
+
+// FromBytes uses the database in buf, which it keeps.
+func FromBytes(buf []byte) (*DB, error) {
+
+	i := bytes.LastIndex(buf, metadataStart)
+	if i < 0 {
+		return nil, &GeoError{"no MaxMind DB metadata"}
+	}
+	d := &decoder{buf: buf[i+len(metadataStart):]}
+	v, _, err := d.decode(0, 0)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, &GeoError{"the metadata is not a map"}
+	}
+
+	db := &DB{buf: buf}
+	md := &db.Metadata
+	md.DatabaseType, _ = m["database_type"].(string)
+	if desc, ok := m["description"].(map[string]interface{}); ok {
+		md.Description, _ = desc["en"].(string)
+	}
+	if langs, ok := m["languages"].([]interface{}); ok {
+		for _, l := range langs {
+			if s, ok := l.(string); ok {
+				md.Languages = append(md.Languages, s)
+			}
+		}
+	}
+	ipVersion, _ := m["ip_version"].(uint64)
+	recordSize, _ := m["record_size"].(uint64)
+	nodeCount, _ := m["node_count"].(uint64)
+	md.BuildEpoch, _ = m["build_epoch"].(uint64)
+	md.IPVersion, md.RecordSize, md.NodeCount = int(ipVersion), int(recordSize), uint32(nodeCount)
+
+	if major, _ := m["binary_format_major_version"].(uint64); major != 2 {
+		return nil, &GeoError{fmt.Sprintf("binary format version %v is not supported", major)}
+	}
+	if md.RecordSize != 24 && md.RecordSize != 28 && md.RecordSize != 32 {
+		return nil, &GeoError{fmt.Sprintf("record size %v is not supported", md.RecordSize)}
+	}
+	if md.IPVersion != 4 && md.IPVersion != 6 {
+		return nil, &GeoError{fmt.Sprintf("IP version %v is not supported", md.IPVersion)}
+	}
+
+	// the search tree, 16 bytes of zeros, then the data section
+	treeSize := uint64(md.NodeCount) * uint64(md.RecordSize) / 4
+	if treeSize+16 > uint64(i) {
+		return nil, &GeoError{fmt.Sprintf("search tree of %v nodes is larger than the file", md.NodeCount)}
+	}
+	db.data = buf[treeSize+16 : i]
+
+	if md.IPVersion == 6 {
+		node := uint32(0)
+		for bit := 0; bit < 96 && node < md.NodeCount; bit++ {
+			node = db.record(node, 0)
+		}
+		db.ipv4Start = node
+	}
+	return db, nil
+}
+
+// record returns the left (0) or right (1) record of a node.
+func (db *DB) record(node uint32, side int) uint32 {
+
+	switch db.Metadata.RecordSize {
+	case 24:
+		b := db.buf[node*6+uint32(side)*3:]
+		return uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2])
+	case 28:
+		b := db.buf[node*7:]
+		if side == 0 {
+			return uint32(b[3]&0xf0)<<20 | uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2])
+		}
+		return uint32(b[3]&0x0f)<<24 | uint32(b[4])<<16 | uint32(b[5])<<8 | uint32(b[6])
+	}
+	return binary.BigEndian.Uint32(db.buf[node*8+uint32(side)*4:])
+}
+
+// Lookup returns the record of the network ip is in, usually a map, and
+// its prefix length. It returns nil if ip is in no network of the database.
+func (db *DB) Lookup(ip net.IP) (interface{}, int, error) {
+
+	node := uint32(0)
+	bits := 128
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits = ip4, 32
+		if db.Metadata.IPVersion == 6 {
+			node = db.ipv4Start
+		}
+	} else if db.Metadata.IPVersion == 4 {
+		return nil, 0, nil
+	}
+	if ip == nil || len(ip) != bits/8 {
+		return nil, 0, &GeoError{fmt.Sprintf("%v is not an IP address", ip)}
+	}
+
+	bit := 0
+	for ; bit < bits && node < db.Metadata.NodeCount; bit++ {
+		node = db.record(node, int(ip[bit/8]>>(7-uint(bit%8)))&1)
+	}
+	if db.Metadata.IPVersion == 6 && bits == 32 {
+		bit += 96
+	}
+
+	switch {
+	case node == db.Metadata.NodeCount:
+		return nil, bit, nil
+	case node < db.Metadata.NodeCount:
+		return nil, 0, &GeoError{fmt.Sprintf("search tree deeper than %v bits", bits)}
+	}
+
+	offset := node - db.Metadata.NodeCount - 16
+	d := &decoder{buf: db.data}
+	v, _, err := d.decode(int(offset), 0)
+	return v, bit, err
+}
+
+// decoder decodes the values of the data section or the metadata.
+type decoder struct {
+	buf []byte
+}
+
+// maxDepth bounds the nesting of maps, arrays and pointers.
+const maxDepth = 32
+
+// decode returns the value at offset and the offset after it.
+func (d *decoder) decode(offset int, depth int) (interface{}, int, error) {
+
+	if depth > maxDepth {
+		return nil, 0, &GeoError{"data nested too deeply"}
+	}
+	if offset >= len(d.buf) {
+		return nil, 0, &GeoError{fmt.Sprintf("data offset %v is past the end", offset)}
+	}
+
+	ctrl := d.buf[offset]
+	offset++
+	kind := int(ctrl >> 5)
+
+	if kind == 1 {
+		// a pointer, followed to its value
+		ptr, next, err := d.pointer(ctrl, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		v, _, err := d.decode(ptr, depth+1)
+		return v, next, err
+	}
+
+	if kind == 0 {
+		// extended type
+		if offset >= len(d.buf) {
+			return nil, 0, &GeoError{"truncated extended type"}
+		}
+		kind = 7 + int(d.buf[offset])
+		offset++
+	}
+
+	size := int(ctrl & 0x1f)
+	if size >= 29 {
+		n := size - 28
+		if offset+n > len(d.buf) {
+			return nil, 0, &GeoError{"truncated size"}
+		}
+		ext := 0
+		for _, b := range d.buf[offset : offset+n] {
+			ext = ext<<8 | int(b)
+		}
+		offset += n
+		switch size {
+		case 29:
+			size = 29 + ext
+		case 30:
+			size = 285 + ext
+		default:
+			size = 65821 + ext
+		}
+	}
+
+	switch kind {
+	case 7: // map
+		m := make(map[string]interface{}, size)
+		for i := 0; i < size; i++ {
+			k, next, err := d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, 0, &GeoError{fmt.Sprintf("map key at offset %v is not a string", offset)}
+			}
+			v, next, err := d.decode(next, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			m[key] = v
+			offset = next
+		}
+		return m, offset, nil
+	case 11: // array
+		a := make([]interface{}, 0, size)
+		for i := 0; i < size; i++ {
+			v, next, err := d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, v)
+			offset = next
+		}
+		return a, offset, nil
+	case 14: // boolean, the value is the size
+		return size != 0, offset, nil
+	case 13: // end marker
+		return nil, offset, nil
+	}
+
+	if offset+size > len(d.buf) {
+		return nil, 0, &GeoError{fmt.Sprintf("value of %v bytes at offset %v overruns the data", size, offset)}
+	}
+	b := d.buf[offset : offset+size]
+	offset += size
+
+	switch kind {
+	case 2: // UTF-8 string
+		return string(b), offset, nil
+	case 3: // double
+		if size != 8 {
+			return nil, 0, &GeoError{fmt.Sprintf("double of %v bytes", size)}
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), offset, nil
+	case 4: // bytes
+		return append([]byte(nil), b...), offset, nil
+	case 5, 6, 9: // uint16, uint32, uint64
+		if size > 8 {
+			return nil, 0, &GeoError{fmt.Sprintf("unsigned integer of %v bytes", size)}
+		}
+		n := uint64(0)
+		for _, c := range b {
+			n = n<<8 | uint64(c)
+		}
+		return n, offset, nil
+	case 8: // int32
+		if size > 4 {
+			return nil, 0, &GeoError{fmt.Sprintf("int32 of %v bytes", size)}
+		}
+		n := uint32(0)
+		for _, c := range b {
+			n = n<<8 | uint32(c)
+		}
+		if size == 4 {
+			return int64(int32(n)), offset, nil
+		}
+		return int64(n), offset, nil
+	case 10: // uint128
+		return new(big.Int).SetBytes(b), offset, nil
+	case 15: // float
+		if size != 4 {
+			return nil, 0, &GeoError{fmt.Sprintf("float of %v bytes", size)}
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), offset, nil
+	}
+	return nil, 0, &GeoError{fmt.Sprintf("unknown data type %v at offset %v", kind, offset)}
+}
+
+// pointer decodes a pointer whose control byte is ctrl, returning the
+// offset it points to and the offset after it.
+func (d *decoder) pointer(ctrl byte, offset int) (int, int, error) {
+
+	n := int(ctrl>>3)&3 + 1
+	if offset+n > len(d.buf) {
+		return 0, 0, &GeoError{"truncated pointer"}
+	}
+	b := d.buf[offset : offset+n]
+	v := int(ctrl & 7)
+
+	var ptr int
+	switch n {
+	case 1:
+		ptr = v<<8 | int(b[0])
+	case 2:
+		ptr = (v<<16 | int(b[0])<<8 | int(b[1])) + 2048
+	case 3:
+		ptr = (v<<24 | int(b[0])<<16 | int(b[1])<<8 | int(b[2])) + 526336
+	default:
+		ptr = int(binary.BigEndian.Uint32(b))
+	}
+	return ptr, offset + n, nil
+}
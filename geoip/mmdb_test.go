@@ -0,0 +1,222 @@
+package geoip
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+// mmdbWriter writes MaxMind DB data fields.
+type mmdbWriter struct {
+	bytes.Buffer
+}
+
+func (w *mmdbWriter) control(kind, size int) {
+	ext := -1
+	if size >= 29 {
+		size, ext = 29, size-29
+	}
+	if kind > 7 {
+		w.WriteByte(byte(size))
+		w.WriteByte(byte(kind - 7))
+	} else {
+		w.WriteByte(byte(kind<<5 | size))
+	}
+	if ext >= 0 {
+		w.WriteByte(byte(ext))
+	}
+}
+
+func (w *mmdbWriter) str(s string) {
+	w.control(2, len(s))
+	w.WriteString(s)
+}
+
+func (w *mmdbWriter) uint(kind int, v uint64) {
+	var b []byte
+	for ; v > 0; v >>= 8 {
+		b = append([]byte{byte(v)}, b...)
+	}
+	w.control(kind, len(b))
+	w.Write(b)
+}
+
+func (w *mmdbWriter) mapping(n int) {
+	w.control(7, n)
+}
+
+func (w *mmdbWriter) pointer(offset int) {
+	w.WriteByte(byte(1<<5 | offset>>8))
+	w.WriteByte(byte(offset))
+}
+
+// metadata writes the metadata of an IPv6 database with 24 bit records.
+func metadata(major uint64, nodes int) []byte {
+	var w mmdbWriter
+	w.Write(metadataStart)
+	w.mapping(6)
+	w.str("binary_format_major_version")
+	w.uint(5, major)
+	w.str("ip_version")
+	w.uint(5, 6)
+	w.str("record_size")
+	w.uint(5, 24)
+	w.str("node_count")
+	w.uint(6, uint64(nodes))
+	w.str("database_type")
+	w.str("Test-City")
+	w.str("build_epoch")
+	w.uint(9, 1700000000)
+	return w.Bytes()
+}
+
+// testDB returns a database with 8.8.8.0/24 in the US and AS15169, and
+// 2001:db8::/32 in Berlin, whose country is a pointer to the other's.
+func testDB() []byte {
+
+	var d mmdbWriter
+	google := d.Len()
+	d.mapping(3)
+	d.str("country")
+	country := d.Len()
+	d.mapping(2)
+	d.str("iso_code")
+	d.str("US")
+	d.str("names")
+	d.mapping(1)
+	d.str("en")
+	d.str("United States")
+	d.str("autonomous_system_number")
+	d.uint(6, 15169)
+	d.str("autonomous_system_organization")
+	d.str("Google LLC")
+	berlin := d.Len()
+	d.mapping(2)
+	d.str("registered_country")
+	d.pointer(country)
+	d.str("city")
+	d.mapping(1)
+	d.str("names")
+	d.mapping(1)
+	d.str("en")
+	d.str("Berlin")
+
+	// the tree, -1 for no data and below for data at -(offset+2)
+	nodes := [][2]int{{-1, -1}}
+	insert := func(ip net.IP, prefix int, data int) {
+		n := 0
+		for bit := 0; bit < prefix; bit++ {
+			side := int(ip[bit/8]>>(7-uint(bit%8))) & 1
+			if bit == prefix-1 {
+				nodes[n][side] = -(data + 2)
+				return
+			}
+			if nodes[n][side] < 0 {
+				nodes = append(nodes, [2]int{-1, -1})
+				nodes[n][side] = len(nodes) - 1
+			}
+			n = nodes[n][side]
+		}
+	}
+	insert(net.ParseIP("::8.8.8.0").To16(), 96+24, google)
+	insert(net.ParseIP("2001:db8::"), 32, berlin)
+
+	var out bytes.Buffer
+	for _, n := range nodes {
+		for _, r := range n {
+			v := r
+			if r == -1 {
+				v = len(nodes)
+			} else if r < -1 {
+				v = len(nodes) + 16 + (-r - 2)
+			}
+			out.Write([]byte{byte(v >> 16), byte(v >> 8), byte(v)})
+		}
+	}
+	out.Write(make([]byte, 16))
+	out.Write(d.Bytes())
+	out.Write(metadata(2, len(nodes)))
+	return out.Bytes()
+}
+
+func TestInfo(t *testing.T) {
+
+	db, err := FromBytes(testDB())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.Metadata.DatabaseType != "Test-City" || db.Metadata.IPVersion != 6 || db.Metadata.BuildEpoch != 1700000000 {
+		t.Errorf("Metadata = %+v", db.Metadata)
+	}
+
+	tests := []struct {
+		ip   string
+		info string
+	}{
+		{"8.8.8.8", "US AS15169 Google LLC"},
+		{"8.8.8.255", "US AS15169 Google LLC"},
+		{"8.8.9.1", ""},
+		{"1.1.1.1", ""},
+		{"2001:db8::5", "US Berlin"},
+		{"2001:db9::1", ""},
+	}
+
+	for _, tt := range tests {
+		info, err := db.Info(net.ParseIP(tt.ip))
+		if err != nil {
+			t.Errorf("%v: %v", tt.ip, err)
+			continue
+		}
+		if info.String() != tt.info {
+			t.Errorf("%v: Info = %q, want %q", tt.ip, info, tt.info)
+		}
+	}
+}
+
+func TestFromBytesCorrupt(t *testing.T) {
+
+	valid := testDB()
+	start := bytes.LastIndex(valid, metadataStart)
+
+	// a single node whose records lead to a pointer to itself
+	loop := []byte{0, 0, 17, 0, 0, 17}
+	loop = append(loop, make([]byte, 16)...)
+	loop = append(loop, 1<<5, 0)
+	loop = append(loop, metadata(2, 1)...)
+
+	tests := []struct {
+		name string
+		buf  []byte
+	}{
+		{"empty", nil},
+		{"no metadata", valid[:start]},
+		{"metadata truncated", valid[:start+len(metadataStart)+5]},
+		{"metadata not a map", append(append([]byte(nil), metadataStart...), 0x43, 'a', 'b', 'c')},
+		{"version 3", metadata(3, 0)},
+		{"tree larger than the file", metadata(2, 1000)},
+	}
+
+	for _, tt := range tests {
+		if db, err := FromBytes(tt.buf); err == nil {
+			t.Errorf("%v: FromBytes = %+v, want an error", tt.name, db.Metadata)
+		}
+	}
+
+	db, err := FromBytes(loop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := db.Lookup(net.ParseIP("8.8.8.8")); err == nil {
+		t.Error("a pointer to itself was not an error")
+	}
+
+	// no prefix of the file may panic
+	for n := range valid {
+		db, err := FromBytes(valid[:n])
+		if err != nil {
+			continue
+		}
+		db.Info(net.ParseIP("8.8.8.8"))
+		db.Info(net.ParseIP("2001:db8::1"))
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/RajeshGottlieb/go/pcapjson"
+	"github.com/RajeshGottlieb/go/pcapng"
+	"io"
+	"os"
+)
+
+func main() {
+
+	if len(os.Args) != 3 {
+		fmt.Printf("usage: %v <input-ndjson|-> <output-pcapng>\n", os.Args[0])
+		return
+	}
+
+	var r io.Reader = os.Stdin
+	if os.Args[1] != "-" {
+		fh, err := os.Open(os.Args[1])
+		if err != nil {
+			panic(err)
+		}
+		defer fh.Close()
+		r = fh
+	}
+
+	wfh, err := os.Create(os.Args[2])
+	if err != nil {
+		panic(err)
+	}
+	defer wfh.Close()
+
+	bw := bufio.NewWriter(wfh)
+	if err := pcapjson.Import(r, pcapng.Writer(bw)); err != nil {
+		panic(err)
+	}
+	if err := bw.Flush(); err != nil {
+		panic(err)
+	}
+}
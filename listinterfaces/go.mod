@@ -0,0 +1,12 @@
+module github.com/RajeshGottlieb/go/listinterfaces
+
+go 1.15
+
+require (
+	github.com/RajeshGottlieb/go/oui v0.0.0-00010101000000-000000000000
+	github.com/RajeshGottlieb/go/pcapng v0.0.0-00010101000000-000000000000
+)
+
+replace github.com/RajeshGottlieb/go/oui => ../oui
+
+replace github.com/RajeshGottlieb/go/pcapng => ../pcapng
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"github.com/RajeshGottlieb/go/oui"
+	"github.com/RajeshGottlieb/go/pcapng"
+	"net"
+	"os"
+	"strings"
+)
+
+// describe formats the options of an interface, one per entry.
+func describe(ifb *pcapng.InterfaceBlock) []string {
+
+	var lines []string
+	for _, opt := range ifb.Options {
+		switch o := opt.(type) {
+		case *pcapng.If_Description:
+			lines = append(lines, "description "+o.Value)
+		case *pcapng.If_IPv4addr:
+			mask := net.IPMask(o.Netmask[:])
+			ones, _ := mask.Size()
+			lines = append(lines, fmt.Sprintf("address %v/%v", net.IP(o.Address[:]), ones))
+		case *pcapng.If_IPv6addr:
+			lines = append(lines, fmt.Sprintf("address %v/%v", net.IP(o.Address[:]), o.PrefixLength))
+		case *pcapng.If_MACaddr:
+			lines = append(lines, "mac "+withVendor(net.HardwareAddr(o.Value[:])))
+		case *pcapng.If_EUIaddr:
+			lines = append(lines, "eui "+withVendor(net.HardwareAddr(o.Value[:])))
+		case *pcapng.If_Speed:
+			lines = append(lines, fmt.Sprintf("speed %v bps", o.Value))
+		case *pcapng.If_Txspeed:
+			lines = append(lines, fmt.Sprintf("tx speed %v bps", o.Value))
+		case *pcapng.If_Rxspeed:
+			lines = append(lines, fmt.Sprintf("rx speed %v bps", o.Value))
+		case *pcapng.If_Tzone:
+			lines = append(lines, fmt.Sprintf("tzone %v", o.Value))
+		case *pcapng.If_Iana_Tzname:
+			lines = append(lines, "timezone "+o.Value)
+		case *pcapng.If_Filter:
+			if o.Type == pcapng.FILTER_STRING {
+				lines = append(lines, "filter "+string(o.Value))
+			} else {
+				lines = append(lines, fmt.Sprintf("filter type %v, %v bytes", o.Type, len(o.Value)))
+			}
+		case *pcapng.If_Fcslen:
+			lines = append(lines, fmt.Sprintf("fcs length %v", o.Value))
+		case *pcapng.If_Tsoffset:
+			lines = append(lines, fmt.Sprintf("timestamp offset %v s", o.Value))
+		case *pcapng.If_Os:
+			lines = append(lines, "os "+o.Value)
+		case *pcapng.If_Hardware:
+			lines = append(lines, "hardware "+o.Value)
+		case *pcapng.Opt_Comment:
+			lines = append(lines, "comment "+o.Value)
+		}
+	}
+	return lines
+}
+
+// withVendor formats an address with the vendor of its prefix, if known.
+func withVendor(mac net.HardwareAddr) string {
+	if name := oui.Builtin().Name(mac); name != "" {
+		return fmt.Sprintf("%v (%v)", mac, name)
+	}
+	return mac.String()
+}
+
+func main() {
+
+	stats := flag.Bool("stats", false, "list the Interface Statistics Blocks of each interface added up instead")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Printf("usage: %v [-stats] <input-pcapng>\n", os.Args[0])
+		return
+	}
+
+	fh, err := os.Open(flag.Arg(0))
+	if err != nil {
+		panic(err)
+	}
+	defer fh.Close()
+
+	// the packets are passed over without being parsed
+	pr := pcapng.Reader(bufio.NewReader(fh))
+	pr.Keep = pcapng.MetadataBlocks
+
+	if *stats {
+		interfaces, err := pcapng.InterfaceStatistics(pr)
+		if err != nil {
+			panic(err)
+		}
+		for _, s := range interfaces {
+			fmt.Printf("%v\n", s)
+			if s.Has(pcapng.ISB_IFDROP) {
+				fmt.Printf("  %v blocks in %v sections, %.3f%% dropped\n", s.Blocks, len(s.Interfaces), 100*s.DropRate())
+			} else {
+				fmt.Printf("  %v blocks in %v sections\n", s.Blocks, len(s.Interfaces))
+			}
+		}
+		return
+	}
+
+	sections, err := pcapng.SectionInterfaces(pr)
+	if err != nil {
+		panic(err)
+	}
+
+	for s, interfaces := range sections {
+		fmt.Printf("section %v\n", s)
+		for id, ifb := range interfaces {
+			name := ifb.Name()
+			if name == "" {
+				name = "-"
+			}
+			tsresol := ifb.Tsresol()
+			fmt.Printf("  %v. %v %v snaplen %v tsresol %v (%v ticks/s)\n", id, name, pcapng.LinkTypeName(ifb.LinkType), ifb.SnapLen, tsresol, pcapng.TicksPerSecond(tsresol))
+			if lines := describe(ifb); len(lines) > 0 {
+				fmt.Printf("     %v\n", strings.Join(lines, "\n     "))
+			}
+		}
+	}
+}
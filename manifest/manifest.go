@@ -0,0 +1,287 @@
+// Package manifest records digests of the packets of a pcapng file so a
+// recipient can check that a capture was not modified in transit.
+package manifest
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"strings"
+
+	"github.com/RajeshGottlieb/go/pcapng"
+)
+
+// Per packet digest algorithms
+const (
+	CRC32  = "crc32"
+	SHA256 = "sha256"
+)
+
+// embeddedPrefix starts the data of a Custom Block holding a manifest.
+const embeddedPrefix = "pcapng-manifest\n"
+
+// Manifest holds a digest of the captured data of every Enhanced Packet
+// Block and a SHA-256 of the file itself.
+type Manifest struct {
+	Algorithm string   `json:"algorithm"` // of the packet digests
+	Length    int64    `json:"length"`    // bytes of the file covered by SHA256
+	SHA256    string   `json:"sha256"`
+	Packets   []string `json:"packets"` // hex digests in file order
+}
+
+// ManifestError
+type ManifestError struct {
+	errorString string
+}
+
+func (me *ManifestError) Error() string {
+	return me.errorString
+}
+
+func newHash(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case CRC32:
+		return crc32.NewIEEE(), nil
+	case SHA256:
+		return sha256.New(), nil
+	}
+	return nil, &ManifestError{fmt.Sprintf("unknown digest algorithm %q, expected crc32 or sha256", algorithm)}
+}
+
+// customData returns the data following prefix in a Custom Block, without
+// the padding, or false if b is not such a block.
+func customData(b *pcapng.GenericBlock, endian binary.ByteOrder, prefix string) ([]byte, bool) {
+	if b.Type != pcapng.CUSTOM_BLOCK && b.Type != pcapng.CUSTOM_BLOCK_NOCOPY {
+		return nil, false
+	}
+	cb, err := pcapng.ParseCustomBlock(b, endian)
+	if err != nil || !bytes.HasPrefix(cb.Data, []byte(prefix)) {
+		return nil, false
+	}
+	return bytes.TrimRight(cb.Data[len(prefix):], "\x00"), true
+}
+
+// scanResult is what scan finds in a file.
+type scanResult struct {
+	manifest      *Manifest           // without Algorithm and Packets
+	packets       map[string][]string // digests by algorithm
+	embedded      *Manifest           // first manifest found in a Custom Block
+	trailing      int                 // blocks after the embedded manifest
+	endian        binary.ByteOrder    // of the last section
+	sectionLength int64               // of the last section, -1 if unspecified
+}
+
+// scan digests the packets of r with each algorithm. Hashing stops at the
+// first Custom Block holding a manifest, which is returned as well.
+func scan(r io.Reader, algorithms ...string) (*scanResult, error) {
+
+	res := &scanResult{manifest: new(Manifest), packets: make(map[string][]string)}
+	for _, algorithm := range algorithms {
+		if _, err := newHash(algorithm); err != nil {
+			return nil, err
+		}
+		res.packets[algorithm] = []string{}
+	}
+
+	file := sha256.New()
+	pr := pcapng.Reader(r)
+
+	for {
+		b, err := pr.ReadRaw()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if res.embedded != nil {
+			// a signature may cover the manifest
+			if _, ok := customData(b, pr.Endian, signaturePrefix); !ok {
+				res.trailing++
+			}
+			continue
+		}
+
+		switch b.Type {
+		case pcapng.SECTION_HEADER_BLOCK:
+			res.sectionLength = -1
+			if len(b.Data) >= 24 {
+				res.sectionLength = int64(pr.Endian.Uint64(b.Data[16:24]))
+			}
+
+		case pcapng.ENHANCED_PACKET_BLOCK:
+			if len(b.Data) < 32 {
+				return nil, &ManifestError{fmt.Sprintf("Enhanced Packet Block at offset %v is too short", pr.Metadata().Offset)}
+			}
+			capLen := int(pr.Endian.Uint32(b.Data[20:24]))
+			if 28+capLen > len(b.Data)-4 {
+				return nil, &ManifestError{fmt.Sprintf("Captured Packet Length %v at offset %v does not fit in the block", capLen, pr.Metadata().Offset)}
+			}
+			for _, algorithm := range algorithms {
+				h, _ := newHash(algorithm)
+				h.Write(b.Data[28 : 28+capLen])
+				res.packets[algorithm] = append(res.packets[algorithm], hex.EncodeToString(h.Sum(nil)))
+			}
+
+		case pcapng.CUSTOM_BLOCK, pcapng.CUSTOM_BLOCK_NOCOPY:
+			if data, ok := customData(b, pr.Endian, embeddedPrefix); ok {
+				m := new(Manifest)
+				if err := json.Unmarshal(data, m); err != nil {
+					return nil, &ManifestError{fmt.Sprintf("embedded manifest at offset %v: %v", pr.Metadata().Offset, err)}
+				}
+				res.embedded = m
+				continue
+			}
+		}
+
+		file.Write(b.Data)
+		res.manifest.Length += int64(len(b.Data))
+	}
+
+	res.manifest.SHA256 = hex.EncodeToString(file.Sum(nil))
+	res.endian = pr.Endian
+	return res, nil
+}
+
+// Build computes the manifest of the file read from r with the given per
+// packet algorithm. A manifest embedded in the file ends what is covered.
+func Build(r io.Reader, algorithm string) (*Manifest, error) {
+	res, err := scan(r, algorithm)
+	if err != nil {
+		return nil, err
+	}
+	m := res.manifest
+	m.Algorithm = algorithm
+	m.Packets = res.packets[algorithm]
+	return m, nil
+}
+
+// Verify checks the file read from r against m, or against the manifest
+// embedded in the file if m is nil. It returns a ManifestError listing
+// every difference.
+func Verify(r io.Reader, m *Manifest) error {
+
+	// the algorithm of an embedded manifest is only known at its end
+	algorithms := []string{CRC32, SHA256}
+	if m != nil {
+		algorithms = []string{m.Algorithm}
+	}
+	res, err := scan(r, algorithms...)
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		if res.embedded == nil {
+			return &ManifestError{"the file does not hold a manifest"}
+		}
+		m = res.embedded
+		if _, err := newHash(m.Algorithm); err != nil {
+			return err
+		}
+	}
+	got := res.manifest
+	got.Packets = res.packets[m.Algorithm]
+
+	var problems []string
+	if got.Length != m.Length || got.SHA256 != m.SHA256 {
+		problems = append(problems, fmt.Sprintf("file has %v bytes with sha256 %v, expected %v bytes with sha256 %v", got.Length, got.SHA256, m.Length, m.SHA256))
+	}
+	if len(got.Packets) != len(m.Packets) {
+		problems = append(problems, fmt.Sprintf("file has %v packets, expected %v", len(got.Packets), len(m.Packets)))
+	}
+	for i := 0; i < len(got.Packets) && i < len(m.Packets); i++ {
+		if got.Packets[i] != m.Packets[i] {
+			problems = append(problems, fmt.Sprintf("packet %v differs", i+1))
+		}
+	}
+	if res.trailing > 0 {
+		problems = append(problems, fmt.Sprintf("%v blocks follow the embedded manifest", res.trailing))
+	}
+
+	if len(problems) > 0 {
+		return &ManifestError{strings.Join(problems, "; ")}
+	}
+	return nil
+}
+
+// WriteJSON writes the manifest as indented JSON, e.g. to a sidecar file.
+func (m *Manifest) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(m)
+}
+
+// ReadJSON reads a manifest written by WriteJSON.
+func ReadJSON(r io.Reader) (*Manifest, error) {
+	m := new(Manifest)
+	if err := json.NewDecoder(r).Decode(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Block returns the manifest as a Custom Block under the Private
+// Enterprise Number pen. It is marked not to be copied, since a tool
+// changing the file would make it stale.
+func (m *Manifest) Block(pen uint32) (*pcapng.CustomBlock, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return &pcapng.CustomBlock{
+		Type: pcapng.CUSTOM_BLOCK_NOCOPY,
+		PEN:  pen,
+		Data: append([]byte(embeddedPrefix), data...),
+	}, nil
+}
+
+// Embed copies r to w unchanged and appends the manifest of the copy as a
+// Custom Block at the end of the last section, followed by a signature
+// covering both if key is not nil. The last section must not have a
+// Section Length, which the blocks would invalidate, and the input must
+// not already hold a manifest. Both only show at the end of the input, so
+// on an error w has a partial copy the caller should discard.
+func Embed(w io.Writer, r io.Reader, algorithm string, pen uint32, key ed25519.PrivateKey) (*Manifest, error) {
+
+	var s *Signer
+	if key != nil {
+		s = NewSigner(w, key, pen)
+		w = s
+	}
+
+	res, err := scan(io.TeeReader(r, w), algorithm)
+	if err != nil {
+		return nil, err
+	}
+	if res.embedded != nil {
+		return nil, &ManifestError{"the input already holds a manifest"}
+	}
+	if res.sectionLength != -1 {
+		return nil, &ManifestError{fmt.Sprintf("the last section has a Section Length of %v", res.sectionLength)}
+	}
+
+	m := res.manifest
+	m.Algorithm = algorithm
+	m.Packets = res.packets[algorithm]
+
+	b, err := m.Block(pen)
+	if err != nil {
+		return nil, err
+	}
+	if err := pcapng.Write(w, b, res.endian); err != nil {
+		return nil, err
+	}
+	if s != nil {
+		s.Endian = res.endian
+		if err := s.Sign(); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
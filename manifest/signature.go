@@ -0,0 +1,162 @@
+package manifest
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/RajeshGottlieb/go/pcapng"
+)
+
+// signaturePrefix starts the data of a Custom Block holding a signature.
+const signaturePrefix = "pcapng-signature\n"
+
+// Signature is the content of a signature Custom Block. It covers every
+// byte of the file before the block.
+type Signature struct {
+	Length    int64  `json:"length"`
+	SHA256    string `json:"sha256"`
+	PublicKey string `json:"public_key"` // hex, informational only
+	Signature string `json:"signature"`  // hex Ed25519 signature of signedMessage
+}
+
+// signedMessage is what gets signed: the prefix, the length as 8 big
+// endian bytes and the SHA-256 of the covered bytes.
+func signedMessage(length int64, sum []byte) []byte {
+	msg := []byte(signaturePrefix)
+	var l [8]byte
+	binary.BigEndian.PutUint64(l[:], uint64(length))
+	msg = append(msg, l[:]...)
+	return append(msg, sum...)
+}
+
+// Signer hashes a capture as it is written through it, e.g. by a
+// pcapng.PcapngWriter, and appends a signature Custom Block when Sign is
+// called. The sections written must not have a Section Length.
+type Signer struct {
+	Endian binary.ByteOrder // of the last section, the host byte order unless set
+
+	w      io.Writer
+	key    ed25519.PrivateKey
+	pen    uint32
+	h      hash.Hash
+	length int64
+}
+
+// NewSigner returns a Signer writing to w and signing with key under the
+// Private Enterprise Number pen.
+func NewSigner(w io.Writer, key ed25519.PrivateKey, pen uint32) *Signer {
+	return &Signer{Endian: pcapng.HostByteOrder(), w: w, key: key, pen: pen, h: sha256.New()}
+}
+
+func (s *Signer) Write(p []byte) (int, error) {
+	n, err := s.w.Write(p)
+	s.h.Write(p[:n])
+	s.length += int64(n)
+	return n, err
+}
+
+// Sign appends the signature of everything written so far.
+func (s *Signer) Sign() error {
+
+	sum := s.h.Sum(nil)
+	sig := &Signature{
+		Length:    s.length,
+		SHA256:    hex.EncodeToString(sum),
+		PublicKey: hex.EncodeToString(s.key.Public().(ed25519.PublicKey)),
+		Signature: hex.EncodeToString(ed25519.Sign(s.key, signedMessage(s.length, sum))),
+	}
+	data, err := json.Marshal(sig)
+	if err != nil {
+		return err
+	}
+
+	b := &pcapng.CustomBlock{
+		Type: pcapng.CUSTOM_BLOCK_NOCOPY,
+		PEN:  s.pen,
+		Data: append([]byte(signaturePrefix), data...),
+	}
+	return pcapng.Write(s.w, b, s.Endian)
+}
+
+// SignCopy copies r to w unchanged and appends a signature made with key.
+// The last section must not have a Section Length, which the block would
+// invalidate.
+func SignCopy(w io.Writer, r io.Reader, key ed25519.PrivateKey, pen uint32) error {
+
+	s := NewSigner(w, key, pen)
+	pr := pcapng.Reader(r)
+	sectionLength := int64(-1)
+
+	for {
+		b, err := pr.ReadRaw()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if b.Type == pcapng.SECTION_HEADER_BLOCK && len(b.Data) >= 24 {
+			sectionLength = int64(pr.Endian.Uint64(b.Data[16:24]))
+		}
+		if _, err := s.Write(b.Data); err != nil {
+			return err
+		}
+	}
+
+	if sectionLength != -1 {
+		return &ManifestError{fmt.Sprintf("the last section has a Section Length of %v", sectionLength)}
+	}
+	s.Endian = pr.Endian
+	return s.Sign()
+}
+
+// VerifySignature checks the first signature Custom Block of the file read
+// from r with the trusted key. The key stored in the block is ignored.
+func VerifySignature(r io.Reader, key ed25519.PublicKey) error {
+
+	h := sha256.New()
+	var length int64
+	pr := pcapng.Reader(r)
+
+	for {
+		b, err := pr.ReadRaw()
+		if err == io.EOF {
+			return &ManifestError{"the file is not signed"}
+		} else if err != nil {
+			return err
+		}
+
+		if data, ok := customData(b, pr.Endian, signaturePrefix); ok {
+			sig := new(Signature)
+			if err := json.Unmarshal(data, sig); err != nil {
+				return &ManifestError{fmt.Sprintf("signature at offset %v: %v", pr.Metadata().Offset, err)}
+			}
+			return checkSignature(pr, sig, length, h.Sum(nil), key)
+		}
+
+		h.Write(b.Data)
+		length += int64(len(b.Data))
+	}
+}
+
+func checkSignature(pr *pcapng.PcapngReader, sig *Signature, length int64, sum []byte, key ed25519.PublicKey) error {
+
+	signature, err := hex.DecodeString(sig.Signature)
+	if err != nil {
+		return &ManifestError{fmt.Sprintf("bad signature: %v", err)}
+	}
+	if !ed25519.Verify(key, signedMessage(length, sum), signature) {
+		return &ManifestError{fmt.Sprintf("the signature does not match the %v bytes before it", length)}
+	}
+
+	// nothing may follow the signature unsigned
+	if _, err := pr.ReadRaw(); err != io.EOF {
+		return &ManifestError{"blocks follow the signature"}
+	}
+	return nil
+}
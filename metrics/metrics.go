@@ -0,0 +1,90 @@
+// Package metrics exports the counts of pcapng readers and writers in the
+// Prometheus text format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/RajeshGottlieb/go/pcapng"
+)
+
+// Collector is a pcapng.Metrics for one stage of a capture pipeline. Give
+// it to the Metrics field of readers and writers and register it.
+type Collector struct {
+	pcapng.Counters
+	Name string // value of the pipeline label
+}
+
+// NewCollector returns a Collector labelled name.
+func NewCollector(name string) *Collector {
+	return &Collector{Name: name}
+}
+
+// Registry serves the counters of its collectors.
+type Registry struct {
+	mu         sync.Mutex
+	collectors []*Collector
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds collectors to those served.
+func (r *Registry) Register(collectors ...*Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, collectors...)
+}
+
+// metric describes one exported counter.
+type metric struct {
+	name  string
+	help  string
+	value func(c pcapng.Counters) uint64
+}
+
+var metricList = []metric{
+	{"pcapng_packets_total", "Packet blocks read or written.", func(c pcapng.Counters) uint64 { return c.Packets }},
+	{"pcapng_bytes_total", "Block bytes read or written.", func(c pcapng.Counters) uint64 { return c.Bytes }},
+	{"pcapng_parse_errors_total", "Reads that failed other than at the end of input.", func(c pcapng.Counters) uint64 { return c.ParseErrors }},
+	{"pcapng_drops_total", "Packets the capture reported dropped.", func(c pcapng.Counters) uint64 { return c.Drops }},
+	{"pcapng_rotations_total", "Output files completed by rotation.", func(c pcapng.Counters) uint64 { return c.Rotations }},
+}
+
+// ServeHTTP writes every counter of every collector in the Prometheus
+// text exposition format, so the Registry can be served as /metrics.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+
+	r.mu.Lock()
+	collectors := append([]*Collector(nil), r.collectors...)
+	r.mu.Unlock()
+	sort.SliceStable(collectors, func(i, j int) bool { return collectors[i].Name < collectors[j].Name })
+
+	snapshots := make([]pcapng.Counters, len(collectors))
+	for i, c := range collectors {
+		snapshots[i] = c.Snapshot()
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	bw := bufio.NewWriter(w)
+	for _, m := range metricList {
+		fmt.Fprintf(bw, "# HELP %v %v\n", m.name, m.help)
+		fmt.Fprintf(bw, "# TYPE %v counter\n", m.name)
+		for i, c := range collectors {
+			fmt.Fprintf(bw, "%v{pipeline=\"%v\"} %v\n", m.name, escape(c.Name), m.value(snapshots[i]))
+		}
+	}
+	bw.Flush()
+}
+
+// escape escapes a label value.
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
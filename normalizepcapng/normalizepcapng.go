@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/RajeshGottlieb/go/pcapng"
+	"github.com/RajeshGottlieb/go/transform"
+	"os"
+)
+
+func main() {
+
+	if len(os.Args) != 3 {
+		fmt.Printf("usage: %v <input-pcapng> <output-pcapng>\n", os.Args[0])
+		return
+	}
+
+	rfh, err := os.Open(os.Args[1])
+	if err != nil {
+		panic(err)
+	}
+	defer rfh.Close()
+
+	wfh, err := os.Create(os.Args[2])
+	if err != nil {
+		panic(err)
+	}
+	defer wfh.Close()
+
+	w := bufio.NewWriter(wfh)
+	if err := transform.Normalize(pcapng.Reader(bufio.NewReader(rfh)), pcapng.Writer(w)); err != nil {
+		panic(err)
+	}
+	if err := w.Flush(); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/RajeshGottlieb/go/oui
+
+go 1.15
@@ -0,0 +1,322 @@
+// Package oui maps MAC addresses to the vendors the IEEE assigned their
+// prefixes to, from a small built-in table or from Wireshark's manuf file
+// and the IEEE registries.
+package oui
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// OUIError is returned for lines of a vendor file that cannot be parsed.
+type OUIError struct {
+	errorString string
+}
+
+func (e *OUIError) Error() string {
+	return e.errorString
+}
+
+// Vendor is the holder of a prefix.
+type Vendor struct {
+	Short string // e.g. "Cisco"
+	Name  string // e.g. "Cisco Systems, Inc", the short name if unknown
+}
+
+// DB maps prefixes of MAC addresses to vendors. The longest prefix an
+// address has wins, so the MA-M and MA-S blocks carved out of a MA-L one
+// are told apart.
+type DB struct {
+	prefixes map[int]map[uint64]Vendor // by prefix length in bits
+	lengths  []int                     // the prefix lengths in use, longest first
+}
+
+// New returns an empty DB.
+func New() *DB {
+	return &DB{prefixes: make(map[int]map[uint64]Vendor)}
+}
+
+var (
+	builtin     *DB
+	builtinOnce sync.Once
+)
+
+// Builtin returns the DB of the table compiled in, a selection of common
+// network, server, virtualization and consumer device vendors. It is
+// shared, so Add to a Copy of it.
+func Builtin() *DB {
+	builtinOnce.Do(func() {
+		builtin = New()
+		if err := builtin.Parse(strings.NewReader(table)); err != nil {
+			panic(err)
+		}
+	})
+	return builtin
+}
+
+// Load returns the built-in table with the vendors of each file added,
+// the files taking precedence. A file is Wireshark's manuf or an IEEE
+// oui.txt or CSV registry.
+func Load(names ...string) (*DB, error) {
+
+	db := Builtin().Copy()
+	for _, name := range names {
+		fh, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		err = db.Parse(fh)
+		fh.Close()
+		if err != nil {
+			return nil, &OUIError{fmt.Sprintf("%v: %v", name, err)}
+		}
+	}
+	return db, nil
+}
+
+// Copy returns a DB with the same vendors.
+func (db *DB) Copy() *DB {
+	c := New()
+	for bits, m := range db.prefixes {
+		for prefix, v := range m {
+			c.add(bits, prefix, v)
+		}
+	}
+	return c
+}
+
+// Len returns the number of prefixes.
+func (db *DB) Len() int {
+	n := 0
+	for _, m := range db.prefixes {
+		n += len(m)
+	}
+	return n
+}
+
+// Add assigns the first bits of prefix, at most 48, to v.
+func (db *DB) Add(prefix net.HardwareAddr, bits int, v Vendor) error {
+
+	if bits <= 0 || bits > 48 || bits > 8*len(prefix) {
+		return &OUIError{fmt.Sprintf("prefix length %v of %v is out of range", bits, prefix)}
+	}
+	if v.Name == "" {
+		v.Name = v.Short
+	}
+	db.add(bits, key(prefix, bits), v)
+	return nil
+}
+
+func (db *DB) add(bits int, prefix uint64, v Vendor) {
+	m := db.prefixes[bits]
+	if m == nil {
+		m = make(map[uint64]Vendor)
+		db.prefixes[bits] = m
+		db.lengths = append(db.lengths, bits)
+		sort.Sort(sort.Reverse(sort.IntSlice(db.lengths)))
+	}
+	m[prefix] = v
+}
+
+// key returns the first bits of mac as a number.
+func key(mac net.HardwareAddr, bits int) uint64 {
+	var b [6]byte
+	copy(b[:], mac)
+	n := uint64(0)
+	for _, c := range b {
+		n = n<<8 | uint64(c)
+	}
+	return n >> uint(48-bits)
+}
+
+// Lookup returns the vendor of the longest prefix of mac, and the length
+// of that prefix. EUI-64 addresses are looked up by their first 48 bits.
+func (db *DB) Lookup(mac net.HardwareAddr) (Vendor, int, bool) {
+
+	if db == nil || len(mac) < 6 {
+		return Vendor{}, 0, false
+	}
+	for _, bits := range db.lengths {
+		if v, ok := db.prefixes[bits][key(mac, bits)]; ok {
+			return v, bits, true
+		}
+	}
+	return Vendor{}, 0, false
+}
+
+// Name returns the full name of the vendor of mac, or "" if it is unknown.
+func (db *DB) Name(mac net.HardwareAddr) string {
+	v, _, _ := db.Lookup(mac)
+	return v.Name
+}
+
+// Identity formats mac the way Wireshark resolves it, the short name of
+// its vendor followed by the bytes after the prefix, e.g.
+// "Cisco_12:34:56" or "Broadcast". Addresses of unknown vendors are
+// formatted as usual.
+func (db *DB) Identity(mac net.HardwareAddr) string {
+
+	v, bits, ok := db.Lookup(mac)
+	if !ok {
+		return mac.String()
+	}
+	rest := mac[bits/8 : 6]
+	if len(rest) == 0 {
+		return v.Short
+	}
+	return v.Short + "_" + rest.String()
+}
+
+// Parse adds the vendors read from r, which holds lines of Wireshark's
+// manuf file,
+//
+//	00:00:0C	Cisco	Cisco Systems, Inc
+//	00:50:C2:00:00:00/36	Short	Long name
+//
+// of the IEEE oui.txt,
+//
+//	00-00-0C   (hex)		Cisco Systems, Inc
+//
+// or of the IEEE MA-L, MA-M and MA-S CSV files.
+//
+//	MA-L,00000C,"Cisco Systems, Inc",170 WEST TASMAN DRIVE ...
+//
+// Comments starting with # and the lines of oui.txt without "(hex)" are
+// skipped.
+func (db *DB) Parse(r io.Reader) error {
+
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var err error
+		switch {
+		case strings.Contains(line, "(hex)"):
+			err = db.parseOUITxt(line)
+		case strings.HasPrefix(line, "MA-") || strings.HasPrefix(line, "Registry,"):
+			err = db.parseCSV(line)
+		case strings.Contains(line, "(base 16)") || strings.HasPrefix(line, "OUI/MA-L"):
+			// the other lines of an oui.txt entry
+		default:
+			err = db.parseManuf(line)
+		}
+		if err != nil {
+			return &OUIError{fmt.Sprintf("line %v: %v", n, err)}
+		}
+	}
+	return s.Err()
+}
+
+// parseManuf parses "prefix[/bits]<tab>short[<tab>name]".
+func (db *DB) parseManuf(line string) error {
+
+	fields := strings.Split(line, "\t")
+	if len(fields) < 2 {
+		fields = strings.Fields(line)
+		if len(fields) < 2 {
+			return &OUIError{fmt.Sprintf("no vendor in %q", line)}
+		}
+		fields = []string{fields[0], fields[1], strings.Join(fields[2:], " ")}
+	}
+	v := Vendor{Short: strings.TrimSpace(fields[1])}
+	if len(fields) > 2 {
+		v.Name = strings.TrimSpace(fields[2])
+		if i := strings.Index(v.Name, "#"); i >= 0 {
+			v.Name = strings.TrimSpace(v.Name[:i])
+		}
+	}
+
+	text, bits := fields[0], -1
+	if i := strings.Index(text, "/"); i >= 0 {
+		var err error
+		if bits, err = strconv.Atoi(text[i+1:]); err != nil {
+			return &OUIError{fmt.Sprintf("bad prefix length in %q", text)}
+		}
+		text = text[:i]
+	}
+	prefix, err := parseHex(text)
+	if err != nil {
+		return err
+	}
+	if bits < 0 {
+		bits = 8 * len(prefix)
+	}
+	return db.Add(prefix, bits, v)
+}
+
+// parseOUITxt parses "00-00-0C   (hex)		Cisco Systems, Inc".
+func (db *DB) parseOUITxt(line string) error {
+
+	i := strings.Index(line, "(hex)")
+	prefix, err := parseHex(strings.TrimSpace(line[:i]))
+	if err != nil {
+		return err
+	}
+	name := strings.TrimSpace(line[i+len("(hex)"):])
+	return db.Add(prefix, 8*len(prefix), Vendor{Short: shortName(name), Name: name})
+}
+
+// parseCSV parses a line of an IEEE registry, skipping the header.
+func (db *DB) parseCSV(line string) error {
+
+	fields, err := csv.NewReader(strings.NewReader(line)).Read()
+	if err != nil {
+		return err
+	}
+	if len(fields) < 3 || fields[0] == "Registry" {
+		return nil
+	}
+	assignment := fields[1]
+	bits := 4 * len(assignment)
+	if len(assignment)%2 == 1 {
+		// MA-M assignments are 7 hex digits
+		assignment += "0"
+	}
+	prefix, err := parseHex(assignment)
+	if err != nil {
+		return err
+	}
+	name := strings.TrimSpace(fields[2])
+	return db.Add(prefix, bits, Vendor{Short: shortName(name), Name: name})
+}
+
+// parseHex parses hex digits, optionally separated by ':', '-' or '.'.
+func parseHex(text string) (net.HardwareAddr, error) {
+
+	digits := strings.NewReplacer(":", "", "-", "", ".", "").Replace(text)
+	if len(digits) == 0 || len(digits)%2 == 1 || len(digits) > 12 {
+		return nil, &OUIError{fmt.Sprintf("bad prefix %q", text)}
+	}
+	prefix := make(net.HardwareAddr, len(digits)/2)
+	for i := range prefix {
+		b, err := strconv.ParseUint(digits[2*i:2*i+2], 16, 8)
+		if err != nil {
+			return nil, &OUIError{fmt.Sprintf("bad prefix %q", text)}
+		}
+		prefix[i] = byte(b)
+	}
+	return prefix, nil
+}
+
+// shortName makes a short name of the first word of a vendor's name, as
+// the IEEE files only have the full one.
+func shortName(name string) string {
+
+	word := strings.FieldsFunc(name, func(r rune) bool {
+		return r == ' ' || r == ',' || r == '.'
+	})
+	if len(word) == 0 {
+		return name
+	}
+	return word[0]
+}
@@ -0,0 +1,87 @@
+package oui
+
+// table is the built-in vendor table, in the format of Wireshark's manuf
+// file. Load a manuf file or an IEEE registry for the rest.
+const table = `
+FF:FF:FF:FF:FF:FF	Broadcast	Broadcast
+01:00:5E	IPv4mcast	IPv4 multicast
+33:33	IPv6mcast	IPv6 multicast
+01:80:C2:00:00:00	STP	Spanning Tree, IEEE 802.1D
+01:80:C2:00:00:0E	LLDP	LLDP, IEEE 802.1AB
+01:00:0C:CC:CC:CC	CDP	Cisco CDP, VTP, DTP, PAgP and UDLD
+00:00:0C	Cisco	Cisco Systems, Inc
+00:40:96	Cisco	Cisco Systems, Inc
+00:60:2F	Cisco	Cisco Systems, Inc
+00:18:0A	Meraki	Cisco Meraki
+00:13:10	Linksys	Cisco-Linksys, LLC
+00:05:85	Juniper	Juniper Networks
+00:1C:73	Arista	Arista Networks
+00:04:96	Extreme	Extreme Networks, Inc
+00:0B:86	Aruba	Aruba Networks
+00:1A:1E	Aruba	Aruba Networks
+00:1B:17	PaloAlto	Palo Alto Networks
+00:09:0F	Fortinet	Fortinet, Inc
+00:0C:42	Mikrotik	Routerboard.com
+4C:5E:0C	Mikrotik	Routerboard.com
+00:15:6D	Ubiquiti	Ubiquiti Networks Inc
+00:27:22	Ubiquiti	Ubiquiti Networks Inc
+24:A4:3C	Ubiquiti	Ubiquiti Networks Inc
+00:09:5B	Netgear	Netgear
+00:14:6C	Netgear	Netgear
+00:05:5D	D-Link	D-Link Systems, Inc
+00:1E:58	D-Link	D-Link Corporation
+00:1D:0F	TP-Link	TP-Link Technologies Co, Ltd
+00:1D:AA	DrayTek	DrayTek Corp
+00:0D:B9	PCEngine	PC Engines GmbH
+00:07:E9	Intel	Intel Corporation
+00:90:27	Intel	Intel Corporation
+00:A0:C9	Intel	Intel Corporation
+00:1B:21	Intel	Intel Corporate
+00:24:D7	Intel	Intel Corporate
+3C:FD:FE	Intel	Intel Corporate
+00:10:18	Broadcom	Broadcom
+00:0A:F7	Broadcom	Broadcom
+00:E0:4C	Realtek	Realtek Semiconductor Corp
+00:50:43	Marvell	Marvell Semiconductor, Inc
+00:02:C9	Mellanox	Mellanox Technologies, Inc
+00:04:4B	Nvidia	NVIDIA
+00:0E:C6	ASIX	ASIX Electronics Corp
+00:06:5B	Dell	Dell Inc
+00:08:74	Dell	Dell Inc
+00:14:22	Dell	Dell Inc
+B8:AC:6F	Dell	Dell Inc
+00:01:E6	HP	Hewlett Packard
+00:25:90	Supermicro	Super Micro Computer, Inc
+00:30:48	Supermicro	Super Micro Computer, Inc
+0C:C4:7A	Supermicro	Super Micro Computer, Inc
+00:11:32	Synology	Synology Incorporated
+00:C0:B7	APC	American Power Conversion Corp
+00:50:56	VMware	VMware, Inc
+00:0C:29	VMware	VMware, Inc
+00:05:69	VMware	VMware, Inc
+08:00:27	VirtualBox	PCS Systemtechnik GmbH
+00:15:5D	Microsoft	Microsoft Corporation
+00:0D:3A	Microsoft	Microsoft Corp
+00:50:F2	Microsoft	Microsoft Corp
+00:16:3E	Xen	Xensource, Inc
+00:1C:42	Parallels	Parallels, Inc
+00:03:93	Apple	Apple, Inc
+00:0A:95	Apple	Apple, Inc
+00:14:51	Apple	Apple, Inc
+00:17:F2	Apple	Apple, Inc
+00:1B:63	Apple	Apple, Inc
+00:1E:C2	Apple	Apple, Inc
+00:25:00	Apple	Apple, Inc
+00:26:BB	Apple	Apple, Inc
+3C:22:FB	Apple	Apple, Inc
+F0:18:98	Apple	Apple, Inc
+00:1A:11	Google	Google, Inc
+3C:5A:B4	Google	Google, Inc
+F4:F5:D8	Google	Google, Inc
+18:B4:30	Nest	Nest Labs Inc
+44:65:0D	Amazon	Amazon Technologies Inc
+00:17:88	Philips	Philips Lighting BV
+B8:27:EB	RaspberryPi	Raspberry Pi Foundation
+DC:A6:32	RaspberryPi	Raspberry Pi Trading Ltd
+E4:5F:01	RaspberryPi	Raspberry Pi Trading Ltd
+`
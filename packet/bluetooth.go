@@ -0,0 +1,51 @@
+package packet
+
+import (
+	"encoding/binary"
+)
+
+// Link type of Bluetooth HCI packets with a direction pseudo-header, as
+// btmon and hcidump write them
+const LinkTypeBluetoothHCIH4WithPHDR = 201
+
+// HCI packet types
+const (
+	HCICommand = 1
+	HCIACL     = 2
+	HCISCO     = 3
+	HCIEvent   = 4
+	HCIISO     = 5
+)
+
+// length of the header of each HCI packet type
+var hciHeaderLengths = map[uint8]int{
+	HCICommand: 3, // opcode and parameter length
+	HCIACL:     4, // handle and data length
+	HCISCO:     3,
+	HCIEvent:   2, // event code and parameter length
+	HCIISO:     4,
+}
+
+// HCI is the pseudo-header and packet type of a Bluetooth HCI packet.
+type HCI struct {
+	Received bool  // from the controller to the host, else sent to it
+	Type     uint8 // HCICommand, HCIACL, ...
+	Payload  int   // offset of the parameters or data after the HCI header
+}
+
+// decodeHCI decodes the 4 byte direction pseudo-header, the H4 packet type
+// and the length of the HCI header, leaving the rest alone.
+func (p *Packet) decodeHCI() {
+
+	if len(p.Data) < 5 {
+		return
+	}
+	h := &HCI{Received: binary.BigEndian.Uint32(p.Data[0:4])&1 != 0, Type: p.Data[4], Payload: -1}
+	p.HCI = h
+	p.headerEnd = 5
+
+	if length, ok := hciHeaderLengths[h.Type]; ok && len(p.Data) >= 5+length {
+		h.Payload = 5 + length
+		p.headerEnd = h.Payload
+	}
+}
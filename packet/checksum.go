@@ -0,0 +1,147 @@
+package packet
+
+import (
+	"encoding/binary"
+)
+
+// Checksum returns the internet checksum (RFC 1071) of data added to an initial sum.
+func Checksum(data []byte, sum uint32) uint16 {
+	for len(data) >= 2 {
+		sum += uint32(data[0])<<8 | uint32(data[1])
+		data = data[2:]
+	}
+	if len(data) == 1 {
+		sum += uint32(data[0]) << 8
+	}
+	for sum > 0xffff {
+		sum = (sum >> 16) + (sum & 0xffff)
+	}
+	return ^uint16(sum)
+}
+
+// NetworkEnd returns the offset just past the IP packet as given by its
+// length field, which excludes any Ethernet padding.
+// It returns -1 if the packet is not IP.
+func (p *Packet) NetworkEnd() int {
+	data := p.Data[p.NetworkOffset:]
+	switch p.IPVersion {
+	case 4:
+		return p.NetworkOffset + int(binary.BigEndian.Uint16(data[2:4]))
+	case 6:
+		return p.NetworkOffset + 40 + int(binary.BigEndian.Uint16(data[4:6]))
+	}
+	return -1
+}
+
+// FixChecksums recomputes the IPv4 header checksum and the TCP, UDP and
+// ICMP checksums after the packet has been edited. A transport checksum is
+// recomputed when the whole segment was captured and is not split into
+// fragments. Otherwise, e.g. for the first fragment of a datagram, it is
+// updated incrementally (RFC 1624) for the changes to the addresses and the
+// captured part of the segment since the packet was decoded.
+func (p *Packet) FixChecksums() {
+
+	if p.IPVersion == 4 {
+		hdr := p.Data[p.NetworkOffset : p.NetworkOffset+int(p.Data[p.NetworkOffset]&0x0f)*4]
+		hdr[10], hdr[11] = 0, 0
+		binary.BigEndian.PutUint16(hdr[10:12], Checksum(hdr, 0))
+	}
+
+	if p.TransportOffset < 0 {
+		return
+	}
+	end := p.NetworkEnd()
+	if end < p.TransportOffset {
+		return
+	}
+	partial := p.MoreFragments || end > len(p.Data)
+	if end > len(p.Data) {
+		end = len(p.Data)
+	}
+	segment := p.Data[p.TransportOffset:end]
+
+	field := p.checksumField()
+	if field < 0 || len(segment) < field+2 || p.Protocol == ProtocolUDP && p.IPVersion == 4 && segment[6] == 0 && segment[7] == 0 {
+		return // no checksum or not in use
+	}
+
+	var checksum uint16
+	if partial {
+		sum := p.partialSum()
+		if sum == p.decodedSum {
+			return
+		}
+		// HC' = ~(~HC + ~m + m')
+		old := binary.BigEndian.Uint16(segment[field : field+2])
+		checksum = Checksum(nil, uint32(^old)+uint32(^p.decodedSum)+uint32(sum))
+		p.decodedSum = sum
+	} else {
+		segment[field], segment[field+1] = 0, 0
+		var sum uint32
+		if p.Protocol != ProtocolICMP {
+			sum = p.pseudoHeaderSum(len(segment))
+		}
+		checksum = Checksum(segment, sum)
+	}
+	if checksum == 0 && p.Protocol == ProtocolUDP {
+		checksum = 0xffff
+	}
+	binary.BigEndian.PutUint16(segment[field:field+2], checksum)
+}
+
+// checksumField returns the offset of the checksum within the transport
+// header or -1 if the protocol has none that FixChecksums knows of.
+func (p *Packet) checksumField() int {
+	switch p.Protocol {
+	case ProtocolTCP:
+		return 16
+	case ProtocolUDP:
+		return 6
+	case ProtocolICMP, ProtocolICMPv6:
+		return 2
+	}
+	return -1
+}
+
+// partialSum returns the ones' complement sum of what the transport checksum
+// covers within the packet, less the checksum itself: the addresses of the
+// pseudo header and the captured part of the segment up to the end of the
+// IP packet. The protocol and length of the pseudo header never change so
+// they are left out.
+func (p *Packet) partialSum() uint16 {
+	end := p.NetworkEnd()
+	if end > len(p.Data) {
+		end = len(p.Data)
+	}
+	segment := p.Data[p.TransportOffset:end]
+	field := p.checksumField()
+	if field < 0 || len(segment) < field+2 {
+		return 0
+	}
+
+	var sum uint32
+	if p.Protocol != ProtocolICMP {
+		sum = uint32(^Checksum(p.SrcIP, 0)) + uint32(^Checksum(p.DstIP, 0))
+	}
+	sum += uint32(^Checksum(segment[:field], 0))
+	return ^Checksum(segment[field+2:], sum)
+}
+
+func (p *Packet) pseudoHeaderSum(length int) uint32 {
+	var sum uint32
+	add := func(b []byte) {
+		for i := 0; i+1 < len(b); i += 2 {
+			sum += uint32(b[i])<<8 | uint32(b[i+1])
+		}
+	}
+	if p.IPVersion == 4 {
+		add(p.SrcIP.To4())
+		add(p.DstIP.To4())
+	} else {
+		add(p.SrcIP.To16())
+		add(p.DstIP.To16())
+	}
+	sum += uint32(p.Protocol)
+	sum += uint32(length)
+	return sum
+}
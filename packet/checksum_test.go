@@ -0,0 +1,85 @@
+package packet
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// udpDatagram returns a raw IPv4 packet from 10.0.0.1:1000 to 10.0.0.2:2000
+// with a correct UDP checksum over the payload.
+func udpDatagram(payload []byte) []byte {
+	data := make([]byte, 28+len(payload))
+	copy(data, []byte{0x45, 0, 0, 0, 0, 1, 0, 0, 64, ProtocolUDP, 0, 0, 10, 0, 0, 1, 10, 0, 0, 2, 0x03, 0xe8, 0x07, 0xd0})
+	binary.BigEndian.PutUint16(data[2:4], uint16(len(data)))
+	binary.BigEndian.PutUint16(data[24:26], uint16(8+len(payload)))
+	copy(data[28:], payload)
+	Decode(LinkTypeRaw, data).FixChecksums()
+	return data
+}
+
+// fragment returns the part of an IPv4 datagram from offset to end of its
+// data as a fragment of its own.
+func fragment(datagram []byte, offset, end int, more bool) []byte {
+	data := append(append([]byte(nil), datagram[:20]...), datagram[20+offset:20+end]...)
+	binary.BigEndian.PutUint16(data[2:4], uint16(len(data)))
+	flags := uint16(offset / 8)
+	if more {
+		flags |= 0x2000
+	}
+	binary.BigEndian.PutUint16(data[6:8], flags)
+	return data
+}
+
+func TestFixChecksumsPartial(t *testing.T) {
+
+	payload := []byte("0123456789abcdefghijklmnopqrstuv")
+
+	edit := func(d *Packet) {
+		copy(d.SrcIP, []byte{192, 168, 7, 9})
+		d.DstIP[3] = 200
+		binary.BigEndian.PutUint16(d.Data[d.TransportOffset+2:], 53)
+		d.Data[d.PayloadOffset] = 'X'
+	}
+
+	// the same edit of the whole datagram gives the checksum to expect
+	want := udpDatagram(payload)
+	d := Decode(LinkTypeRaw, want)
+	edit(d)
+	d.FixChecksums()
+	wantSum := binary.BigEndian.Uint16(want[26:28])
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"first fragment", fragment(udpDatagram(payload), 0, 24, true)},
+		{"truncated", udpDatagram(payload)[:33]},
+		{"odd truncated first fragment", fragment(udpDatagram(payload), 0, 24, true)[:37]},
+	}
+
+	for _, tt := range tests {
+		d := Decode(LinkTypeRaw, tt.data)
+		edit(d)
+		d.FixChecksums()
+		if got := binary.BigEndian.Uint16(tt.data[26:28]); got != wantSum {
+			t.Errorf("%v: UDP checksum %#04x, want %#04x", tt.name, got, wantSum)
+		}
+		if Checksum(tt.data[:20], 0) != 0 {
+			t.Errorf("%v: bad IPv4 header checksum", tt.name)
+		}
+		// a second fix changes nothing
+		d.FixChecksums()
+		if got := binary.BigEndian.Uint16(tt.data[26:28]); got != wantSum {
+			t.Errorf("%v: UDP checksum %#04x after fixing twice, want %#04x", tt.name, got, wantSum)
+		}
+	}
+
+	// a later fragment has no transport header to fix
+	later := fragment(udpDatagram(payload), 24, 40, false)
+	d = Decode(LinkTypeRaw, later)
+	before := string(later[20:])
+	d.FixChecksums()
+	if string(later[20:]) != before {
+		t.Error("later fragment was changed")
+	}
+}
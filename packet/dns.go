@@ -0,0 +1,256 @@
+package packet
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// DNS resource record types
+const (
+	DNSTypeA     = 1
+	DNSTypeNS    = 2
+	DNSTypeCNAME = 5
+	DNSTypeSOA   = 6
+	DNSTypePTR   = 12
+	DNSTypeMX    = 15
+	DNSTypeTXT   = 16
+	DNSTypeAAAA  = 28
+	DNSTypeSRV   = 33
+	DNSTypeHTTPS = 65
+	DNSTypeANY   = 255
+)
+
+// DNS response codes
+const (
+	DNSRcodeNoError  = 0
+	DNSRcodeFormErr  = 1
+	DNSRcodeServFail = 2
+	DNSRcodeNXDomain = 3
+	DNSRcodeNotImp   = 4
+	DNSRcodeRefused  = 5
+)
+
+// DNSQuestion is an entry of the question section.
+type DNSQuestion struct {
+	Name  string
+	Type  uint16
+	Class uint16
+}
+
+// DNSRecord is a resource record of the answer, authority or additional section.
+type DNSRecord struct {
+	Name  string
+	Type  uint16
+	Class uint16
+	TTL   uint32
+	Data  []byte
+	IP    net.IP // address of A and AAAA records
+	Host  string // target of CNAME, NS and PTR records
+}
+
+// DNS is a decoded DNS message.
+type DNS struct {
+	ID         uint16
+	Response   bool
+	Opcode     uint8
+	Rcode      uint8
+	Questions  []DNSQuestion
+	Answers    []DNSRecord
+	Authority  []DNSRecord
+	Additional []DNSRecord
+}
+
+// DNSError
+type DNSError struct {
+	errorString string
+}
+
+func (de *DNSError) Error() string {
+	return de.errorString
+}
+
+// DecodeDNS decodes a DNS message as carried by UDP, without the TCP length prefix.
+func DecodeDNS(data []byte) (*DNS, error) {
+
+	if len(data) < 12 {
+		return nil, &DNSError{fmt.Sprintf("DNS message of %v bytes is shorter than its header", len(data))}
+	}
+
+	flags := binary.BigEndian.Uint16(data[2:4])
+	m := &DNS{
+		ID:       binary.BigEndian.Uint16(data[0:2]),
+		Response: flags&0x8000 != 0,
+		Opcode:   uint8(flags>>11) & 0xf,
+		Rcode:    uint8(flags) & 0xf,
+	}
+	qdcount := int(binary.BigEndian.Uint16(data[4:6]))
+	ancount := int(binary.BigEndian.Uint16(data[6:8]))
+	nscount := int(binary.BigEndian.Uint16(data[8:10]))
+	arcount := int(binary.BigEndian.Uint16(data[10:12]))
+
+	offset := 12
+	for i := 0; i < qdcount; i++ {
+		name, n, err := dnsName(data, offset)
+		if err != nil {
+			return nil, err
+		}
+		offset = n
+		if offset+4 > len(data) {
+			return nil, &DNSError{"DNS question is truncated"}
+		}
+		m.Questions = append(m.Questions, DNSQuestion{
+			Name:  name,
+			Type:  binary.BigEndian.Uint16(data[offset:]),
+			Class: binary.BigEndian.Uint16(data[offset+2:]),
+		})
+		offset += 4
+	}
+
+	var err error
+	if m.Answers, offset, err = dnsRecords(data, offset, ancount); err != nil {
+		return nil, err
+	}
+	if m.Authority, offset, err = dnsRecords(data, offset, nscount); err != nil {
+		return nil, err
+	}
+	if m.Additional, _, err = dnsRecords(data, offset, arcount); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func dnsRecords(data []byte, offset int, count int) ([]DNSRecord, int, error) {
+
+	var records []DNSRecord
+	for i := 0; i < count; i++ {
+		name, n, err := dnsName(data, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		offset = n
+		if offset+10 > len(data) {
+			return nil, 0, &DNSError{"DNS resource record is truncated"}
+		}
+		rr := DNSRecord{
+			Name:  name,
+			Type:  binary.BigEndian.Uint16(data[offset:]),
+			Class: binary.BigEndian.Uint16(data[offset+2:]),
+			TTL:   binary.BigEndian.Uint32(data[offset+4:]),
+		}
+		rdlength := int(binary.BigEndian.Uint16(data[offset+8:]))
+		offset += 10
+		if offset+rdlength > len(data) {
+			return nil, 0, &DNSError{"DNS resource record data is truncated"}
+		}
+		rr.Data = data[offset : offset+rdlength]
+
+		switch rr.Type {
+		case DNSTypeA, DNSTypeAAAA:
+			if rdlength == 4 || rdlength == 16 {
+				rr.IP = net.IP(rr.Data)
+			}
+		case DNSTypeCNAME, DNSTypeNS, DNSTypePTR:
+			if host, _, err := dnsName(data, offset); err == nil {
+				rr.Host = host
+			}
+		}
+
+		offset += rdlength
+		records = append(records, rr)
+	}
+	return records, offset, nil
+}
+
+// dnsName reads a possibly compressed name at offset. It returns the name
+// and the offset following it in the original position.
+func dnsName(data []byte, offset int) (string, int, error) {
+
+	var labels []string
+	end := -1
+
+	// every pointer must go backwards, which also bounds the loop
+	for limit := offset; ; {
+		if offset >= len(data) {
+			return "", 0, &DNSError{"DNS name is truncated"}
+		}
+		length := int(data[offset])
+
+		switch {
+		case length == 0:
+			if end < 0 {
+				end = offset + 1
+			}
+			return strings.Join(labels, "."), end, nil
+		case length&0xc0 == 0xc0:
+			if offset+2 > len(data) {
+				return "", 0, &DNSError{"DNS name pointer is truncated"}
+			}
+			pointer := int(binary.BigEndian.Uint16(data[offset:]) & 0x3fff)
+			if pointer >= limit {
+				return "", 0, &DNSError{fmt.Sprintf("DNS name pointer %v does not point backwards", pointer)}
+			}
+			if end < 0 {
+				end = offset + 2
+			}
+			offset, limit = pointer, pointer
+		case length&0xc0 != 0:
+			return "", 0, &DNSError{fmt.Sprintf("unsupported DNS label type 0x%02x", length&0xc0)}
+		default:
+			if offset+1+length > len(data) {
+				return "", 0, &DNSError{"DNS label is truncated"}
+			}
+			labels = append(labels, string(data[offset+1:offset+1+length]))
+			offset += 1 + length
+		}
+	}
+}
+
+// DNSTypeName returns the mnemonic of a record type, e.g. "AAAA".
+func DNSTypeName(t uint16) string {
+	switch t {
+	case DNSTypeA:
+		return "A"
+	case DNSTypeNS:
+		return "NS"
+	case DNSTypeCNAME:
+		return "CNAME"
+	case DNSTypeSOA:
+		return "SOA"
+	case DNSTypePTR:
+		return "PTR"
+	case DNSTypeMX:
+		return "MX"
+	case DNSTypeTXT:
+		return "TXT"
+	case DNSTypeAAAA:
+		return "AAAA"
+	case DNSTypeSRV:
+		return "SRV"
+	case DNSTypeHTTPS:
+		return "HTTPS"
+	case DNSTypeANY:
+		return "ANY"
+	}
+	return fmt.Sprintf("TYPE%v", t)
+}
+
+// DNSRcodeName returns the mnemonic of a response code, e.g. "NXDOMAIN".
+func DNSRcodeName(rcode uint8) string {
+	switch rcode {
+	case DNSRcodeNoError:
+		return "NOERROR"
+	case DNSRcodeFormErr:
+		return "FORMERR"
+	case DNSRcodeServFail:
+		return "SERVFAIL"
+	case DNSRcodeNXDomain:
+		return "NXDOMAIN"
+	case DNSRcodeNotImp:
+		return "NOTIMP"
+	case DNSRcodeRefused:
+		return "REFUSED"
+	}
+	return fmt.Sprintf("RCODE%v", rcode)
+}
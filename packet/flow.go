@@ -0,0 +1,133 @@
+package packet
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Flow identifies one direction of a conversation by its 5-tuple.
+// It is comparable so it can be used as a map key.
+type Flow struct {
+	Protocol uint8
+	SrcIP    [16]byte
+	DstIP    [16]byte
+	SrcPort  uint16
+	DstPort  uint16
+}
+
+// Flow returns the 5-tuple of an IP packet. Ports are zero for protocols
+// without them. It returns false if the packet is not IP.
+func (p *Packet) Flow() (Flow, bool) {
+	var f Flow
+	if p.IPVersion == 0 {
+		return f, false
+	}
+	f.Protocol = p.Protocol
+	copy(f.SrcIP[:], p.SrcIP.To16())
+	copy(f.DstIP[:], p.DstIP.To16())
+	f.SrcPort = p.SrcPort
+	f.DstPort = p.DstPort
+	return f, true
+}
+
+// Reverse returns the flow of the opposite direction.
+func (f Flow) Reverse() Flow {
+	return Flow{f.Protocol, f.DstIP, f.SrcIP, f.DstPort, f.SrcPort}
+}
+
+// Canonical returns the same value for both directions of a conversation.
+func (f Flow) Canonical() Flow {
+	if c := bytes.Compare(f.SrcIP[:], f.DstIP[:]); c > 0 || (c == 0 && f.SrcPort > f.DstPort) {
+		return f.Reverse()
+	}
+	return f
+}
+
+func (f Flow) String() string {
+	src := net.IP(f.SrcIP[:])
+	dst := net.IP(f.DstIP[:])
+	return fmt.Sprintf("%v %v:%v > %v:%v", ProtocolName(f.Protocol), src, f.SrcPort, dst, f.DstPort)
+}
+
+// ProtocolName returns the name of an IP protocol, e.g. "tcp".
+func ProtocolName(protocol uint8) string {
+	switch protocol {
+	case ProtocolTCP:
+		return "tcp"
+	case ProtocolUDP:
+		return "udp"
+	case ProtocolICMP:
+		return "icmp"
+	case ProtocolICMPv6:
+		return "icmpv6"
+	}
+	return fmt.Sprintf("proto-%v", protocol)
+}
+
+// FlowError
+type FlowError struct {
+	errorString string
+}
+
+func (fe *FlowError) Error() string {
+	return fe.errorString
+}
+
+// ParseFlow parses a flow in the format written by String, e.g.
+// "tcp 10.0.0.1:40000 > 10.0.0.2:443". IPv6 addresses may be bracketed.
+func ParseFlow(s string) (Flow, error) {
+
+	var f Flow
+	fields := strings.Fields(s)
+	if len(fields) != 4 || fields[2] != ">" {
+		return f, &FlowError{fmt.Sprintf("flow %q is not of the form \"proto src:port > dst:port\"", s)}
+	}
+
+	switch fields[0] {
+	case "tcp":
+		f.Protocol = ProtocolTCP
+	case "udp":
+		f.Protocol = ProtocolUDP
+	case "icmp":
+		f.Protocol = ProtocolICMP
+	case "icmpv6":
+		f.Protocol = ProtocolICMPv6
+	default:
+		n, err := strconv.ParseUint(strings.TrimPrefix(fields[0], "proto-"), 10, 8)
+		if err != nil {
+			return f, &FlowError{fmt.Sprintf("unknown protocol %q", fields[0])}
+		}
+		f.Protocol = uint8(n)
+	}
+
+	var err error
+	if f.SrcIP, f.SrcPort, err = parseEndpoint(fields[1]); err != nil {
+		return f, err
+	}
+	if f.DstIP, f.DstPort, err = parseEndpoint(fields[3]); err != nil {
+		return f, err
+	}
+	return f, nil
+}
+
+// parseEndpoint parses address:port, splitting at the last colon.
+func parseEndpoint(s string) (ip [16]byte, port uint16, err error) {
+
+	i := strings.LastIndex(s, ":")
+	if i < 0 {
+		return ip, 0, &FlowError{fmt.Sprintf("endpoint %q has no port", s)}
+	}
+	addr := net.ParseIP(strings.TrimSuffix(strings.TrimPrefix(s[:i], "["), "]"))
+	if addr == nil {
+		return ip, 0, &FlowError{fmt.Sprintf("invalid address in endpoint %q", s)}
+	}
+	n, perr := strconv.ParseUint(s[i+1:], 10, 16)
+	if perr != nil {
+		return ip, 0, &FlowError{fmt.Sprintf("invalid port in endpoint %q", s)}
+	}
+	copy(ip[:], addr.To16())
+	return ip, uint16(n), nil
+}
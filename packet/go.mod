@@ -0,0 +1,3 @@
+module github.com/RajeshGottlieb/go/packet
+
+go 1.15
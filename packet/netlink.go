@@ -0,0 +1,55 @@
+package packet
+
+import (
+	"encoding/binary"
+)
+
+// Link type of netlink messages captured on an nlmon interface
+const LinkTypeNetlink = 253
+
+// Netlink is the cooked header and first message header of a netlink
+// packet. The cooked header is a Linux cooked capture header with the
+// netlink family in place of the protocol.
+type Netlink struct {
+	PacketType uint16 // of the cooked header
+	Family     uint16 // e.g. 0 for NETLINK_ROUTE
+	Length     uint32 // of the first message including its header
+	Type       uint16
+	Flags      uint16
+	Seq        uint32
+	PID        uint32
+	BigEndian  bool // the messages use the byte order of the capturing machine
+}
+
+// decodeNetlink decodes the 16 byte cooked header and, if there is one,
+// the header of the first message, leaving the message itself alone.
+func (p *Packet) decodeNetlink() {
+
+	data := p.Data
+	if len(data) < 16 {
+		return
+	}
+	n := &Netlink{
+		PacketType: binary.BigEndian.Uint16(data[0:2]),
+		Family:     binary.BigEndian.Uint16(data[14:16]),
+	}
+	p.Netlink = n
+	p.headerEnd = 16
+
+	msg := data[16:]
+	if len(msg) < 16 {
+		return
+	}
+	// like LinkTypeNull, guess the byte order from a length that fits
+	var order binary.ByteOrder = binary.LittleEndian
+	if length := order.Uint32(msg[0:4]); length < 16 || int(length) > len(msg) {
+		order = binary.BigEndian
+		n.BigEndian = true
+	}
+	n.Length = order.Uint32(msg[0:4])
+	n.Type = order.Uint16(msg[4:6])
+	n.Flags = order.Uint16(msg[6:8])
+	n.Seq = order.Uint32(msg[8:12])
+	n.PID = order.Uint32(msg[12:16])
+	p.headerEnd = 32
+}
@@ -0,0 +1,388 @@
+// Package packet decodes the link, network and transport headers of captured packets.
+package packet
+
+import (
+	"encoding/binary"
+	"net"
+)
+
+// Link types (https://www.tcpdump.org/linktypes.html)
+const (
+	LinkTypeNull      = 0
+	LinkTypeEthernet  = 1
+	LinkTypeRaw       = 101
+	LinkTypeLinuxSLL  = 113
+	LinkTypeIPv4      = 228
+	LinkTypeIPv6      = 229
+	LinkTypeLinuxSLL2 = 276
+)
+
+// EtherTypes
+const (
+	EtherTypeIPv4  = 0x0800
+	EtherTypeARP   = 0x0806
+	EtherTypeVLAN  = 0x8100
+	EtherTypeIPv6  = 0x86DD
+	EtherTypeQinQ  = 0x88A8
+	EtherTypePPPoE = 0x8864
+)
+
+// IP protocol numbers
+const (
+	ProtocolHopByHop = 0
+	ProtocolICMP     = 1
+	ProtocolTCP      = 6
+	ProtocolUDP      = 17
+	ProtocolRouting  = 43
+	ProtocolFragment = 44
+	ProtocolGRE      = 47
+	ProtocolESP      = 50
+	ProtocolAH       = 51
+	ProtocolICMPv6   = 58
+	ProtocolNoNext   = 59
+	ProtocolDestOpts = 60
+	ProtocolMobility = 135
+	ProtocolHIP      = 139
+	ProtocolShim6    = 140
+)
+
+// TCP flags
+const (
+	TCPFlagFIN = 0x01
+	TCPFlagSYN = 0x02
+	TCPFlagRST = 0x04
+	TCPFlagPSH = 0x08
+	TCPFlagACK = 0x10
+	TCPFlagURG = 0x20
+)
+
+// Packet holds the decoded headers of a packet. Offsets index into Data and
+// are -1 when the corresponding layer is absent or could not be decoded.
+type Packet struct {
+	Data     []byte
+	LinkType uint16
+
+	Radiotap *Radiotap // of radiotap packets
+	HCI      *HCI      // of Bluetooth HCI packets
+	USB      *USBPcap  // of USBPcap packets
+	Netlink  *Netlink  // of nlmon packets
+
+	SrcMAC    net.HardwareAddr
+	DstMAC    net.HardwareAddr
+	VLANs     []uint16 // VLAN IDs outermost first
+	EtherType uint16
+
+	vlanOffsets []int
+
+	NetworkOffset int
+	IPVersion     int // 4, 6 or 0 when not IP
+	SrcIP         net.IP
+	DstIP         net.IP
+	TTL           uint8 // IPv4 TTL or IPv6 hop limit
+	TOS           uint8 // IPv4 TOS or IPv6 traffic class
+	FlowLabel     uint32
+	Fragment      bool  // true if this is not the first fragment
+	MoreFragments bool  // true if fragments follow, the IPv4 MF or IPv6 M flag
+	Protocol      uint8 // transport protocol after any IPv6 extension headers
+	ExtHeaders    []uint8
+
+	TransportOffset int
+	SrcPort         uint16
+	DstPort         uint16
+	TCPFlags        uint8
+	TCPSeq          uint32
+	TCPAck          uint32
+	ICMPType        uint8
+	ICMPCode        uint8
+
+	PayloadOffset int
+
+	headerEnd  int
+	decodedSum uint16 // partialSum of a first fragment or truncated segment, for FixChecksums
+}
+
+// HeaderLength returns the length of all the headers that were decoded.
+func (p *Packet) HeaderLength() int {
+	return p.headerEnd
+}
+
+// Payload returns the bytes after the transport header or nil if the
+// transport header was not decoded.
+func (p *Packet) Payload() []byte {
+	if p.PayloadOffset < 0 {
+		return nil
+	}
+	return p.Data[p.PayloadOffset:]
+}
+
+// SetVLAN changes the ID of the i'th VLAN tag leaving its priority bits alone.
+func (p *Packet) SetVLAN(i int, id uint16) {
+	tci := p.Data[p.vlanOffsets[i] : p.vlanOffsets[i]+2]
+	binary.BigEndian.PutUint16(tci, binary.BigEndian.Uint16(tci)&0xf000|id&0x0fff)
+	p.VLANs[i] = id
+}
+
+// SetTTL sets the IPv4 TTL or IPv6 hop limit.
+func (p *Packet) SetTTL(ttl uint8) {
+	switch p.IPVersion {
+	case 4:
+		p.Data[p.NetworkOffset+8] = ttl
+	case 6:
+		p.Data[p.NetworkOffset+7] = ttl
+	}
+	p.TTL = ttl
+}
+
+// SetTOS sets the IPv4 TOS or IPv6 traffic class.
+func (p *Packet) SetTOS(tos uint8) {
+	hdr := p.Data[p.NetworkOffset:]
+	switch p.IPVersion {
+	case 4:
+		hdr[1] = tos
+	case 6:
+		hdr[0] = hdr[0]&0xf0 | tos>>4
+		hdr[1] = tos<<4 | hdr[1]&0x0f
+	}
+	p.TOS = tos
+}
+
+// Decode decodes as many headers of data as it can.
+func Decode(linkType uint16, data []byte) *Packet {
+
+	p := &Packet{
+		Data:            data,
+		LinkType:        linkType,
+		NetworkOffset:   -1,
+		TransportOffset: -1,
+		PayloadOffset:   -1,
+	}
+
+	switch linkType {
+	case LinkTypeEthernet:
+		p.decodeEthernet(0)
+	case LinkTypeLinuxSLL:
+		if len(data) >= 16 {
+			p.decodeEtherType(binary.BigEndian.Uint16(data[14:16]), 16)
+		}
+	case LinkTypeLinuxSLL2:
+		if len(data) >= 20 {
+			p.decodeEtherType(binary.BigEndian.Uint16(data[0:2]), 20)
+		}
+	case LinkTypeNull:
+		// the address family is in host byte order of the capturing machine
+		if len(data) >= 4 {
+			p.headerEnd = 4
+			family := binary.LittleEndian.Uint32(data[0:4])
+			if family > 0xffff {
+				family = binary.BigEndian.Uint32(data[0:4])
+			}
+			switch family {
+			case 2, 24, 28, 30: // AF_INET and the various AF_INET6 values
+				p.decodeIP(4)
+			}
+		}
+	case LinkTypeRaw, LinkTypeIPv4, LinkTypeIPv6:
+		p.decodeIP(0)
+	case LinkTypeRadiotap:
+		if p.Radiotap = DecodeRadiotap(data); p.Radiotap != nil {
+			p.headerEnd = p.Radiotap.Length
+			p.decodeDot11()
+		}
+	case LinkTypeIEEE80211:
+		p.decodeDot11()
+	case LinkTypeBluetoothHCIH4WithPHDR:
+		p.decodeHCI()
+	case LinkTypeUSBPcap:
+		p.decodeUSBPcap()
+	case LinkTypeNetlink:
+		p.decodeNetlink()
+	}
+	return p
+}
+
+func (p *Packet) decodeEthernet(offset int) {
+	data := p.Data[offset:]
+	if len(data) < 14 {
+		return
+	}
+	p.DstMAC = net.HardwareAddr(data[0:6])
+	p.SrcMAC = net.HardwareAddr(data[6:12])
+	p.decodeEtherType(binary.BigEndian.Uint16(data[12:14]), offset+14)
+}
+
+func (p *Packet) decodeEtherType(etherType uint16, offset int) {
+	for etherType == EtherTypeVLAN || etherType == EtherTypeQinQ {
+		if len(p.Data) < offset+4 {
+			return
+		}
+		p.VLANs = append(p.VLANs, binary.BigEndian.Uint16(p.Data[offset:offset+2])&0x0fff)
+		p.vlanOffsets = append(p.vlanOffsets, offset)
+		etherType = binary.BigEndian.Uint16(p.Data[offset+2 : offset+4])
+		offset += 4
+	}
+	p.EtherType = etherType
+	p.headerEnd = offset
+
+	switch etherType {
+	case EtherTypeIPv4, EtherTypeIPv6:
+		p.decodeIP(offset)
+	case EtherTypeARP:
+		if len(p.Data) >= offset+28 {
+			p.headerEnd = offset + 28
+		}
+	}
+}
+
+// decodeIP looks at the version nibble to decide between IPv4 and IPv6.
+func (p *Packet) decodeIP(offset int) {
+	if len(p.Data) <= offset {
+		return
+	}
+	switch p.Data[offset] >> 4 {
+	case 4:
+		p.decodeIPv4(offset)
+	case 6:
+		p.decodeIPv6(offset)
+	}
+}
+
+func (p *Packet) decodeIPv4(offset int) {
+	data := p.Data[offset:]
+	if len(data) < 20 {
+		return
+	}
+	ihl := int(data[0]&0x0f) * 4
+	if ihl < 20 || len(data) < ihl {
+		return
+	}
+
+	p.NetworkOffset = offset
+	p.IPVersion = 4
+	p.TOS = data[1]
+	p.TTL = data[8]
+	p.Protocol = data[9]
+	p.SrcIP = net.IP(data[12:16])
+	p.DstIP = net.IP(data[16:20])
+	p.Fragment = binary.BigEndian.Uint16(data[6:8])&0x1fff != 0
+	p.MoreFragments = data[6]&0x20 != 0
+	p.headerEnd = offset + ihl
+
+	if !p.Fragment {
+		p.decodeTransport(offset + ihl)
+	}
+}
+
+func (p *Packet) decodeIPv6(offset int) {
+	data := p.Data[offset:]
+	if len(data) < 40 {
+		return
+	}
+
+	p.NetworkOffset = offset
+	p.IPVersion = 6
+	word := binary.BigEndian.Uint32(data[0:4])
+	p.TOS = uint8(word >> 20)
+	p.FlowLabel = word & 0xfffff
+	p.TTL = data[7]
+	p.SrcIP = net.IP(data[8:24])
+	p.DstIP = net.IP(data[24:40])
+
+	next := data[6]
+	offset += 40
+	p.headerEnd = offset
+
+	// walk the extension header chain
+	for {
+		switch next {
+		case ProtocolHopByHop, ProtocolRouting, ProtocolDestOpts, ProtocolMobility, ProtocolHIP, ProtocolShim6:
+			if len(p.Data) < offset+8 {
+				return
+			}
+			p.ExtHeaders = append(p.ExtHeaders, next)
+			next, offset = p.Data[offset], offset+8+int(p.Data[offset+1])*8
+			p.setHeaderEnd(offset)
+			continue
+		case ProtocolFragment:
+			if len(p.Data) < offset+8 {
+				return
+			}
+			p.ExtHeaders = append(p.ExtHeaders, next)
+			p.Fragment = binary.BigEndian.Uint16(p.Data[offset+2:offset+4])&0xfff8 != 0
+			p.MoreFragments = p.Data[offset+3]&1 != 0
+			next, offset = p.Data[offset], offset+8
+			p.setHeaderEnd(offset)
+			continue
+		case ProtocolAH:
+			if len(p.Data) < offset+8 {
+				return
+			}
+			p.ExtHeaders = append(p.ExtHeaders, next)
+			next, offset = p.Data[offset], offset+(int(p.Data[offset+1])+2)*4
+			p.setHeaderEnd(offset)
+			continue
+		}
+		break
+	}
+
+	p.Protocol = next
+	if !p.Fragment && offset <= len(p.Data) {
+		p.decodeTransport(offset)
+	}
+}
+
+func (p *Packet) decodeTransport(offset int) {
+	data := p.Data[offset:]
+
+	switch p.Protocol {
+	case ProtocolTCP:
+		if len(data) < 20 {
+			return
+		}
+		dataOffset := int(data[12]>>4) * 4
+		if dataOffset < 20 || len(data) < dataOffset {
+			return
+		}
+		p.TransportOffset = offset
+		p.SrcPort = binary.BigEndian.Uint16(data[0:2])
+		p.DstPort = binary.BigEndian.Uint16(data[2:4])
+		p.TCPSeq = binary.BigEndian.Uint32(data[4:8])
+		p.TCPAck = binary.BigEndian.Uint32(data[8:12])
+		p.TCPFlags = data[13]
+		p.PayloadOffset = offset + dataOffset
+		p.headerEnd = p.PayloadOffset
+	case ProtocolUDP:
+		if len(data) < 8 {
+			return
+		}
+		p.TransportOffset = offset
+		p.SrcPort = binary.BigEndian.Uint16(data[0:2])
+		p.DstPort = binary.BigEndian.Uint16(data[2:4])
+		p.PayloadOffset = offset + 8
+		p.headerEnd = p.PayloadOffset
+	case ProtocolICMP, ProtocolICMPv6:
+		if len(data) < 8 {
+			return
+		}
+		p.TransportOffset = offset
+		p.ICMPType = data[0]
+		p.ICMPCode = data[1]
+		p.PayloadOffset = offset + 8
+		p.headerEnd = p.PayloadOffset
+	default:
+		return
+	}
+
+	// a checksum that cannot be recomputed is updated from this
+	if end := p.NetworkEnd(); end >= offset && (p.MoreFragments || end > len(p.Data)) {
+		p.decodedSum = p.partialSum()
+	}
+}
+
+// setHeaderEnd records the end of an extension header which may claim to be
+// longer than what was captured.
+func (p *Packet) setHeaderEnd(offset int) {
+	if offset > len(p.Data) {
+		offset = len(p.Data)
+	}
+	p.headerEnd = offset
+}
@@ -0,0 +1,108 @@
+package packet
+
+import (
+	"encoding/binary"
+)
+
+// Link type of PPP frames, possibly starting with the HDLC address and control bytes
+const LinkTypePPP = 9
+
+// UDP port of L2TP
+const PortL2TP = 1701
+
+// PPP protocols
+const (
+	PPPProtocolIPv4 = 0x0021
+	PPPProtocolIPv6 = 0x0057
+)
+
+// PPPSession is a PPP frame carried by PPPoE or L2TP, or captured as it is.
+type PPPSession struct {
+	Kind     string // "pppoe", "l2tp" or "ppp"
+	Session  uint16 // PPPoE or L2TP session ID
+	Tunnel   uint16 // L2TP tunnel ID
+	Protocol uint16 // PPP protocol, e.g. PPPProtocolIPv4
+	Inner    int    // offset of what the PPP frame carries
+}
+
+// PPP finds the PPP frame of a PPPoE session packet, an L2TPv2 data
+// message or a packet of link type PPP. It returns false for any other
+// packet, L2TP control messages included.
+func (p *Packet) PPP() (PPPSession, bool) {
+
+	switch {
+	case p.LinkType == LinkTypePPP:
+		return pppFrame(p.Data, PPPSession{Kind: "ppp"}, 0)
+	case p.EtherType == EtherTypePPPoE && p.NetworkOffset < 0:
+		// version, type, code, session ID and length
+		data := p.Data[p.headerEnd:]
+		if len(data) < 6 || data[0] != 0x11 || data[1] != 0 {
+			return PPPSession{}, false
+		}
+		s := PPPSession{Kind: "pppoe", Session: binary.BigEndian.Uint16(data[2:4])}
+		return pppFrame(p.Data, s, p.headerEnd+6)
+	case p.Protocol == ProtocolUDP && p.PayloadOffset >= 0 && (p.DstPort == PortL2TP || p.SrcPort == PortL2TP):
+		return p.l2tp(p.PayloadOffset)
+	}
+	return PPPSession{}, false
+}
+
+// l2tp decodes an L2TPv2 header (RFC 2661).
+func (p *Packet) l2tp(offset int) (PPPSession, bool) {
+
+	data := p.Data[offset:]
+	if len(data) < 6 {
+		return PPPSession{}, false
+	}
+	flags := binary.BigEndian.Uint16(data[0:2])
+	if flags&0x8000 != 0 || flags&0xf != 2 {
+		return PPPSession{}, false // control message or not version 2
+	}
+
+	n := 2
+	if flags&0x4000 != 0 { // length
+		n += 2
+	}
+	if len(data) < n+4 {
+		return PPPSession{}, false
+	}
+	s := PPPSession{
+		Kind:    "l2tp",
+		Tunnel:  binary.BigEndian.Uint16(data[n:]),
+		Session: binary.BigEndian.Uint16(data[n+2:]),
+	}
+	n += 4
+	if flags&0x0800 != 0 { // Ns and Nr
+		n += 4
+	}
+	if flags&0x0200 != 0 { // offset size and padding
+		if len(data) < n+2 {
+			return PPPSession{}, false
+		}
+		n += 2 + int(binary.BigEndian.Uint16(data[n:]))
+	}
+	return pppFrame(p.Data, s, offset+n)
+}
+
+// pppFrame decodes the PPP header at offset, with or without the HDLC
+// address and control bytes and with a one or two byte protocol.
+func pppFrame(data []byte, s PPPSession, offset int) (PPPSession, bool) {
+
+	if len(data) >= offset+2 && data[offset] == 0xff && data[offset+1] == 0x03 {
+		offset += 2
+	}
+	if len(data) < offset+1 {
+		return PPPSession{}, false
+	}
+	if data[offset]&1 != 0 { // compressed protocol field
+		s.Protocol = uint16(data[offset])
+		s.Inner = offset + 1
+		return s, true
+	}
+	if len(data) < offset+2 {
+		return PPPSession{}, false
+	}
+	s.Protocol = binary.BigEndian.Uint16(data[offset:])
+	s.Inner = offset + 2
+	return s, true
+}
@@ -0,0 +1,214 @@
+package packet
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// Link types of 802.11 captures
+const (
+	LinkTypeIEEE80211 = 105
+	LinkTypeRadiotap  = 127
+)
+
+// radiotap flags
+const (
+	RadiotapFlagFCS    = 0x10 // the frame ends with its FCS
+	RadiotapFlagBadFCS = 0x40
+)
+
+// Radiotap holds the fields of a radiotap header (https://www.radiotap.org)
+// the package knows, from the first presence bitmap. Present tells which
+// were there.
+type Radiotap struct {
+	Length  int    // of the header, where the 802.11 frame starts
+	Present uint32 // first presence bitmap
+
+	TSFT         uint64 // microseconds
+	Flags        uint8
+	Rate         uint8  // 500 kbps units
+	ChannelFreq  uint16 // MHz
+	ChannelFlags uint16
+	Signal       int8 // dBm antenna signal
+	Noise        int8 // dBm antenna noise
+	Antenna      uint8
+	MCSKnown     uint8
+	MCSFlags     uint8
+	MCS          uint8 // MCS index
+}
+
+// alignment and size of the radiotap fields, by presence bit
+var radiotapFields = [][2]int{
+	{8, 8}, {1, 1}, {1, 1}, {2, 4}, {1, 2}, {1, 1}, {1, 1}, {2, 2},
+	{2, 2}, {2, 2}, {1, 1}, {1, 1}, {1, 1}, {1, 1}, {2, 2}, {2, 2},
+	{1, 1}, {1, 1}, {4, 8}, {1, 3}, {4, 8}, {2, 12}, {8, 12}, {2, 12},
+	{2, 12}, {2, 6}, {1, 1}, {2, 4},
+}
+
+// Has returns true if the field of presence bit was present.
+func (r *Radiotap) Has(bit uint) bool {
+	return r.Present&(1<<bit) != 0
+}
+
+// String returns the channel, signal, noise and MCS index, those present.
+func (r *Radiotap) String() string {
+	var parts []string
+	if r.Has(3) {
+		parts = append(parts, fmt.Sprintf("%v MHz", r.ChannelFreq))
+	}
+	if r.Has(5) {
+		parts = append(parts, fmt.Sprintf("signal %v dBm", r.Signal))
+	}
+	if r.Has(6) {
+		parts = append(parts, fmt.Sprintf("noise %v dBm", r.Noise))
+	}
+	if r.Has(19) && r.MCSKnown&0x02 != 0 {
+		parts = append(parts, fmt.Sprintf("MCS %v", r.MCS))
+	}
+	return strings.Join(parts, " ")
+}
+
+// DecodeRadiotap decodes a radiotap header. It returns nil if data does
+// not start with one. Fields after one the package does not know are left
+// out.
+func DecodeRadiotap(data []byte) *Radiotap {
+
+	if len(data) < 8 || data[0] != 0 {
+		return nil
+	}
+	length := int(binary.LittleEndian.Uint16(data[2:4]))
+	if length < 8 || length > len(data) {
+		return nil
+	}
+	data = data[:length]
+
+	r := &Radiotap{Length: length, Present: binary.LittleEndian.Uint32(data[4:8])}
+
+	// the fields follow the last presence bitmap
+	offset := 8
+	for word := r.Present; word&(1<<31) != 0; offset += 4 {
+		if offset+4 > length {
+			return nil
+		}
+		word = binary.LittleEndian.Uint32(data[offset:])
+	}
+
+	for bit := uint(0); bit < 29; bit++ {
+		if !r.Has(bit) {
+			continue
+		}
+		if int(bit) >= len(radiotapFields) {
+			r.Present &= 1<<bit - 1
+			break
+		}
+		align, size := radiotapFields[bit][0], radiotapFields[bit][1]
+		offset = (offset + align - 1) &^ (align - 1)
+		if offset+size > length {
+			r.Present &= 1<<bit - 1
+			break
+		}
+		f := data[offset : offset+size]
+		offset += size
+
+		switch bit {
+		case 0:
+			r.TSFT = binary.LittleEndian.Uint64(f)
+		case 1:
+			r.Flags = f[0]
+		case 2:
+			r.Rate = f[0]
+		case 3:
+			r.ChannelFreq = binary.LittleEndian.Uint16(f[0:])
+			r.ChannelFlags = binary.LittleEndian.Uint16(f[2:])
+		case 5:
+			r.Signal = int8(f[0])
+		case 6:
+			r.Noise = int8(f[0])
+		case 11:
+			r.Antenna = f[0]
+		case 19:
+			r.MCSKnown, r.MCSFlags, r.MCS = f[0], f[1], f[2]
+		}
+	}
+	return r
+}
+
+// Dot11Data locates the payload of an 802.11 data frame.
+type Dot11Data struct {
+	DstMAC  []byte
+	SrcMAC  []byte
+	Payload int // offset of the LLC header
+	End     int // of the frame body, before any FCS
+}
+
+// Dot11 decodes the 802.11 header of a radiotap or 802.11 packet. It returns false for management and control frames,
+// frames without a body and protected frames, whose body is encrypted.
+func (p *Packet) Dot11() (Dot11Data, bool) {
+
+	offset, end := 0, len(p.Data)
+	switch p.LinkType {
+	case LinkTypeRadiotap:
+		if p.Radiotap == nil {
+			return Dot11Data{}, false
+		}
+		offset = p.Radiotap.Length
+		if p.Radiotap.Flags&RadiotapFlagFCS != 0 {
+			end -= 4
+		}
+	case LinkTypeIEEE80211:
+	default:
+		return Dot11Data{}, false
+	}
+
+	if end < offset+24 {
+		return Dot11Data{}, false
+	}
+	data := p.Data[offset:end]
+	fc0, fc1 := data[0], data[1]
+	if fc0>>2&3 != 2 || fc0&0x40 != 0 || fc1&0x40 != 0 {
+		return Dot11Data{}, false // not data, no body or protected
+	}
+
+	length := 24
+	toDS, fromDS := fc1&1 != 0, fc1&2 != 0
+	if toDS && fromDS {
+		length += 6
+	}
+	if fc0&0x80 != 0 { // QoS
+		length += 2
+		if fc1&0x80 != 0 { // HT control
+			length += 4
+		}
+	}
+	if len(data) < length {
+		return Dot11Data{}, false
+	}
+
+	d := Dot11Data{DstMAC: data[4:10], SrcMAC: data[10:16], Payload: offset + length, End: end}
+	switch {
+	case toDS && fromDS:
+		d.DstMAC, d.SrcMAC = data[16:22], data[24:30]
+	case toDS:
+		d.DstMAC = data[16:22]
+	case fromDS:
+		d.SrcMAC = data[16:22]
+	}
+	return d, true
+}
+
+// decodeDot11 decodes the 802.11 data frame and its LLC/SNAP header.
+func (p *Packet) decodeDot11() {
+
+	d, ok := p.Dot11()
+	if !ok {
+		return
+	}
+	p.DstMAC, p.SrcMAC = d.DstMAC, d.SrcMAC
+
+	llc := p.Data[d.Payload:d.End]
+	if len(llc) < 8 || llc[0] != 0xaa || llc[1] != 0xaa || llc[2] != 0x03 {
+		return
+	}
+	p.decodeEtherType(binary.BigEndian.Uint16(llc[6:8]), d.Payload+8)
+}
@@ -0,0 +1,84 @@
+package packet
+
+import "testing"
+
+func TestDecodeRadiotap(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		data    []byte
+		ok      bool
+		present uint32
+		freq    uint16
+		signal  int8
+	}{
+		{"short", []byte{0, 0, 8, 0, 0, 0, 0}, false, 0, 0, 0},
+		{"version", []byte{1, 0, 8, 0, 0, 0, 0, 0}, false, 0, 0, 0},
+		{"length below header", []byte{0, 0, 4, 0, 0, 0, 0, 0}, false, 0, 0, 0},
+		{"length beyond data", []byte{0, 0, 16, 0, 0, 0, 0, 0}, false, 0, 0, 0},
+		{"extended bitmap truncated", []byte{0, 0, 8, 0, 0, 0, 0, 0x80}, false, 0, 0, 0},
+		{"no fields", []byte{0, 0, 8, 0, 0, 0, 0, 0}, true, 0, 0, 0},
+		{"flags channel signal", []byte{0, 0, 15, 0, 0x2a, 0, 0, 0, 0x00, 0, 0x6c, 0x09, 0xa0, 0x00, 0xc4}, true, 0x2a, 2412, -60},
+		{"channel cut short", []byte{0, 0, 12, 0, 0x2a, 0, 0, 0, 0x00, 0, 0x6c, 0x09}, true, 0x02, 0, 0},
+	}
+
+	for _, tt := range tests {
+		r := DecodeRadiotap(tt.data)
+		if (r != nil) != tt.ok {
+			t.Errorf("%v: DecodeRadiotap = %v, want ok %v", tt.name, r, tt.ok)
+			continue
+		}
+		if r == nil {
+			continue
+		}
+		if r.Present != tt.present || r.ChannelFreq != tt.freq || r.Signal != tt.signal {
+			t.Errorf("%v: present %#x freq %v signal %v, want %#x %v %v", tt.name, r.Present, r.ChannelFreq, r.Signal, tt.present, tt.freq, tt.signal)
+		}
+	}
+}
+
+func TestDot11(t *testing.T) {
+
+	// an 802.11 data frame from the distribution system, with an LLC header
+	frame := []byte{
+		0x08, 0x02, 0, 0, // data, from DS
+		1, 2, 3, 4, 5, 6, // addr1, the destination
+		0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, // addr2, the BSSID
+		7, 8, 9, 10, 11, 12, // addr3, the source
+		0, 0, // sequence control
+		0xaa, 0xaa, 0x03, 0, 0, 0, 0x08, 0x00,
+	}
+	radiotap := func(flags byte, frame []byte) []byte {
+		return append([]byte{0, 0, 10, 0, 0x02, 0, 0, 0, flags, 0}, frame...)
+	}
+	fcs := append(append([]byte(nil), frame...), 0xde, 0xad, 0xbe, 0xef)
+
+	tests := []struct {
+		name     string
+		linkType uint16
+		data     []byte
+		ok       bool
+		payload  int
+		end      int
+	}{
+		{"802.11", LinkTypeIEEE80211, frame, true, 24, 32},
+		{"radiotap", LinkTypeRadiotap, radiotap(0, frame), true, 34, 42},
+		{"radiotap with FCS", LinkTypeRadiotap, radiotap(0x10, fcs), true, 34, 42},
+		{"radiotap header only with FCS", LinkTypeRadiotap, []byte{0, 0, 10, 0, 0x02, 0, 0, 0, 0x10, 0}, false, 0, 0},
+		{"radiotap FCS only", LinkTypeRadiotap, radiotap(0x10, []byte{1, 2, 3, 4}), false, 0, 0},
+		{"truncated header", LinkTypeIEEE80211, frame[:20], false, 0, 0},
+		{"bad radiotap", LinkTypeRadiotap, []byte{0, 0, 0xff, 0, 0, 0, 0, 0}, false, 0, 0},
+	}
+
+	for _, tt := range tests {
+		p := Decode(tt.linkType, tt.data)
+		d, ok := p.Dot11()
+		if ok != tt.ok {
+			t.Errorf("%v: Dot11 ok %v, want %v", tt.name, ok, tt.ok)
+			continue
+		}
+		if ok && (d.Payload != tt.payload || d.End != tt.end) {
+			t.Errorf("%v: payload %v end %v, want %v %v", tt.name, d.Payload, d.End, tt.payload, tt.end)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package packet
+
+// Reassembler reassembles one direction of a TCP connection.
+// The zero value is ready to use.
+type Reassembler struct {
+	started bool
+	next    uint32            // next expected sequence number
+	pending map[uint32][]byte // out of order segments by sequence number
+}
+
+// Add accepts a segment and returns the bytes that are now contiguous.
+func (h *Reassembler) Add(seq uint32, syn bool, payload []byte) []byte {
+
+	if !h.started {
+		h.started = true
+		h.next = seq
+		h.pending = make(map[uint32][]byte)
+	}
+	if syn {
+		h.next = seq + 1
+		return nil
+	}
+	if len(payload) == 0 {
+		return nil
+	}
+
+	if int32(seq-h.next) > 0 {
+		// a gap, keep it until the missing bytes arrive
+		h.pending[seq] = append([]byte(nil), payload...)
+		return nil
+	}
+
+	var out []byte
+	out = h.deliver(out, seq, payload)
+
+	for progress := true; progress; {
+		progress = false
+		for s, data := range h.pending {
+			if int32(s-h.next) <= 0 {
+				delete(h.pending, s)
+				out = h.deliver(out, s, data)
+				progress = true
+			}
+		}
+	}
+	return out
+}
+
+// deliver appends the part of the segment not yet seen.
+func (h *Reassembler) deliver(out []byte, seq uint32, payload []byte) []byte {
+	overlap := int(h.next - seq)
+	if overlap >= len(payload) {
+		return out // retransmission
+	}
+	payload = payload[overlap:]
+	h.next += uint32(len(payload))
+	return append(out, payload...)
+}
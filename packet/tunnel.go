@@ -0,0 +1,186 @@
+package packet
+
+import (
+	"encoding/binary"
+)
+
+// EtherTypes of what GRE and GENEVE carry
+const (
+	EtherTypeTEB       = 0x6558 // Ethernet frames
+	EtherTypeERSPAN    = 0x88BE // ERSPAN type I and II
+	EtherTypeERSPANIII = 0x22EB
+)
+
+// IP protocol numbers of IP in IP
+const (
+	ProtocolIPIP = 4
+	ProtocolIPv6 = 41
+)
+
+// UDP ports of the UDP tunnels
+const (
+	PortVXLAN  = 4789
+	PortGENEVE = 6081
+)
+
+// Tunnel is an encapsulation carrying another packet.
+type Tunnel struct {
+	Kind      string // "gre", "ipip", "vxlan", "geneve", "erspan1", "erspan2" or "erspan3"
+	Offset    int    // of the encapsulation header, the carried packet for ipip
+	Inner     int    // offset of the carried packet
+	InnerType uint16 // EtherType of the carried packet, EtherTypeTEB for Ethernet frames
+	ID        uint32 // GRE key, VXLAN or GENEVE VNI, ERSPAN session ID, 0 if there is none
+
+	// ERSPAN type III only
+	Timestamp   uint32 // hardware timestamp of the mirrored frame
+	Granularity uint8  // of Timestamp: 0 100 microseconds, 1 100 nanoseconds, 2 IEEE 1588, 3 platform specific
+}
+
+// Tunnel finds the encapsulation of a GRE, IP in IP, VXLAN, GENEVE or
+// ERSPAN packet. It returns false for any other packet or if the encapsulation
+// header is truncated.
+func (p *Packet) Tunnel() (Tunnel, bool) {
+
+	if p.IPVersion == 0 || p.Fragment {
+		return Tunnel{}, false
+	}
+
+	switch p.Protocol {
+	case ProtocolGRE:
+		return p.greTunnel(p.headerEnd)
+	case ProtocolIPIP, ProtocolIPv6:
+		t := Tunnel{Kind: "ipip", Offset: p.headerEnd, Inner: p.headerEnd, InnerType: EtherTypeIPv4}
+		if p.Protocol == ProtocolIPv6 {
+			t.InnerType = EtherTypeIPv6
+		}
+		return t, p.headerEnd < len(p.Data)
+	case ProtocolUDP:
+		if p.PayloadOffset < 0 {
+			break
+		}
+		switch p.DstPort {
+		case PortVXLAN:
+			return p.vxlanTunnel(p.PayloadOffset)
+		case PortGENEVE:
+			return p.geneveTunnel(p.PayloadOffset)
+		}
+	}
+	return Tunnel{}, false
+}
+
+// greTunnel decodes a GRE header (RFC 2784, RFC 2890).
+func (p *Packet) greTunnel(offset int) (Tunnel, bool) {
+
+	data := p.Data[offset:]
+	if len(data) < 4 {
+		return Tunnel{}, false
+	}
+	flags := binary.BigEndian.Uint16(data[0:2])
+	if flags&7 != 0 {
+		return Tunnel{}, false // version 1 is PPTP
+	}
+
+	t := Tunnel{Kind: "gre", Offset: offset, InnerType: binary.BigEndian.Uint16(data[2:4])}
+	length := 4
+	if flags&0x8000 != 0 { // checksum
+		length += 4
+	}
+	if flags&0x2000 != 0 { // key
+		if len(data) < length+4 {
+			return Tunnel{}, false
+		}
+		t.ID = binary.BigEndian.Uint32(data[length:])
+		length += 4
+	}
+	if flags&0x1000 != 0 { // sequence number
+		length += 4
+	}
+	if len(data) < length {
+		return Tunnel{}, false
+	}
+	t.Inner = offset + length
+
+	switch t.InnerType {
+	case EtherTypeERSPAN:
+		if flags&0x1000 == 0 {
+			// type I has no header of its own
+			t.Kind, t.ID, t.InnerType = "erspan1", 0, EtherTypeTEB
+			return t, true
+		}
+		return p.erspanTunnel(t)
+	case EtherTypeERSPANIII:
+		return p.erspanTunnel(t)
+	}
+	return t, true
+}
+
+// erspanTunnel decodes the ERSPAN type II or III header that follows a GRE
+// header.
+func (p *Packet) erspanTunnel(t Tunnel) (Tunnel, bool) {
+
+	data := p.Data[t.Inner:]
+	if len(data) < 8 {
+		return Tunnel{}, false
+	}
+	t.ID = uint32(binary.BigEndian.Uint16(data[2:4]) & 0x3ff)
+
+	switch data[0] >> 4 {
+	case 1:
+		t.Kind = "erspan2"
+		t.Inner += 8
+	case 2:
+		if len(data) < 12 {
+			return Tunnel{}, false
+		}
+		t.Kind = "erspan3"
+		t.Timestamp = binary.BigEndian.Uint32(data[4:8])
+		t.Granularity = data[11] >> 1 & 3
+		t.Inner += 12
+		if data[11]&1 != 0 { // platform specific subheader
+			if len(data) < 20 {
+				return Tunnel{}, false
+			}
+			t.Inner += 8
+		}
+	default:
+		return Tunnel{}, false
+	}
+	t.InnerType = EtherTypeTEB
+	return t, true
+}
+
+// vxlanTunnel decodes a VXLAN header (RFC 7348).
+func (p *Packet) vxlanTunnel(offset int) (Tunnel, bool) {
+
+	data := p.Data[offset:]
+	if len(data) < 8 || data[0]&0x08 == 0 {
+		return Tunnel{}, false
+	}
+	return Tunnel{
+		Kind:      "vxlan",
+		Offset:    offset,
+		Inner:     offset + 8,
+		InnerType: EtherTypeTEB,
+		ID:        binary.BigEndian.Uint32(data[4:8]) >> 8,
+	}, true
+}
+
+// geneveTunnel decodes a GENEVE header (RFC 8926).
+func (p *Packet) geneveTunnel(offset int) (Tunnel, bool) {
+
+	data := p.Data[offset:]
+	if len(data) < 8 || data[0]>>6 != 0 {
+		return Tunnel{}, false
+	}
+	length := 8 + int(data[0]&0x3f)*4
+	if len(data) < length {
+		return Tunnel{}, false
+	}
+	return Tunnel{
+		Kind:      "geneve",
+		Offset:    offset,
+		Inner:     offset + length,
+		InnerType: binary.BigEndian.Uint16(data[2:4]),
+		ID:        binary.BigEndian.Uint32(data[4:8]) >> 8,
+	}, true
+}
@@ -0,0 +1,72 @@
+package packet
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Link type of USB packets captured by USBPcap on Windows
+const LinkTypeUSBPcap = 249
+
+// USBPcap transfer types
+const (
+	USBTransferIsochronous = 0
+	USBTransferInterrupt   = 1
+	USBTransferControl     = 2
+	USBTransferBulk        = 3
+)
+
+// USBPcap is the pseudo-header of a USBPcap packet, all little endian.
+type USBPcap struct {
+	HeaderLength uint16 // where the transfer data starts
+	IRPID        uint64
+	Status       uint32 // USBD_STATUS
+	Function     uint16 // URB function
+	Info         uint8  // bit 0 set if the IRP goes from the device to the host
+	Bus          uint16
+	Device       uint16
+	Endpoint     uint8 // bit 7 set for IN endpoints
+	Transfer     uint8 // USBTransferIsochronous, ...
+	DataLength   uint32
+	Stage        uint8 // of control transfers
+}
+
+// FromDevice returns true if the IRP goes from the device to the host.
+func (u *USBPcap) FromDevice() bool {
+	return u.Info&1 != 0
+}
+
+// Address returns the bus, device and endpoint number, as Wireshark shows
+// them, e.g. "1.2.1".
+func (u *USBPcap) Address() string {
+	return fmt.Sprintf("%v.%v.%v", u.Bus, u.Device, u.Endpoint&0x0f)
+}
+
+// decodeUSBPcap decodes the pseudo-header, leaving the transfer data alone.
+func (p *Packet) decodeUSBPcap() {
+
+	data := p.Data
+	if len(data) < 27 {
+		return
+	}
+	u := &USBPcap{
+		HeaderLength: binary.LittleEndian.Uint16(data[0:2]),
+		IRPID:        binary.LittleEndian.Uint64(data[2:10]),
+		Status:       binary.LittleEndian.Uint32(data[10:14]),
+		Function:     binary.LittleEndian.Uint16(data[14:16]),
+		Info:         data[16],
+		Bus:          binary.LittleEndian.Uint16(data[17:19]),
+		Device:       binary.LittleEndian.Uint16(data[19:21]),
+		Endpoint:     data[21],
+		Transfer:     data[22],
+		DataLength:   binary.LittleEndian.Uint32(data[23:27]),
+	}
+	if int(u.HeaderLength) < 27 || int(u.HeaderLength) > len(data) {
+		return
+	}
+	if u.Transfer == USBTransferControl && u.HeaderLength >= 28 {
+		u.Stage = data[27]
+	}
+	p.USB = u
+	p.headerEnd = int(u.HeaderLength)
+}
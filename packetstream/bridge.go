@@ -0,0 +1,219 @@
+package packetstream
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/RajeshGottlieb/go/capture"
+	"github.com/RajeshGottlieb/go/pcapng"
+	"github.com/RajeshGottlieb/go/transform"
+)
+
+// archiveTsresol is the resolution of the interfaces Archive writes, that
+// of Packet.TimestampNs.
+const archiveTsresol = 9
+
+// Forward sends the interfaces and packets pr reads to s, naming the agent
+// in the first message, until the end of the input. Interfaces are
+// numbered across the sections of the input. The blocks other than
+// interfaces and Enhanced Packet Blocks are not sent.
+func Forward(pr *pcapng.PcapngReader, agent string, s Sender) (Summary, error) {
+	return forward(pr, agent, s, nil)
+}
+
+// ForwardSubscription sends s the interfaces pr reads and the packets
+// that match the filter expression of the subscription, all of them if it
+// is empty, until the end of the input. The filter is a transform.Expr,
+// e.g. "tcp.port == 443".
+func ForwardSubscription(pr *pcapng.PcapngReader, sub *Subscription, s Sender) (Summary, error) {
+	if sub.Filter == "" {
+		return forward(pr, "", s, nil)
+	}
+	e, err := transform.ParseExpr(sub.Filter)
+	if err != nil {
+		return Summary{}, err
+	}
+	return forward(pr, "", s, e)
+}
+
+// forward is Forward, sending only the packets filter matches if it is
+// not nil.
+func forward(pr *pcapng.PcapngReader, agent string, s Sender, filter *transform.Expr) (Summary, error) {
+
+	var sum Summary
+	number := 0
+	var section []*pcapng.InterfaceBlock // of the current section
+	var ids []uint32                     // stream ID of each
+	next := uint32(0)
+
+	send := func(m *Message) error {
+		m.Agent, agent = agent, ""
+		return s.Send(m)
+	}
+
+	for {
+		block, err := pr.Read()
+		if err == io.EOF {
+			return sum, nil
+		}
+		if err != nil {
+			return sum, err
+		}
+
+		switch b := block.(type) {
+		case *pcapng.SectionBlock:
+			section, ids = nil, nil
+		case *pcapng.InterfaceBlock:
+			i := &Interface{ID: next, LinkType: uint32(b.LinkType), SnapLen: b.SnapLen, Name: b.Name()}
+			for _, opt := range b.Options {
+				if o, ok := opt.(*pcapng.If_Description); ok {
+					i.Description = o.Value
+				}
+			}
+			section, ids = append(section, b), append(ids, next)
+			next++
+			if err := send(&Message{Interface: i}); err != nil {
+				return sum, err
+			}
+		case *pcapng.EnhancedPacketBlock:
+			if int(b.InterfaceID) >= len(section) {
+				return sum, &StreamError{fmt.Sprintf("packet on undeclared interface %v", b.InterfaceID)}
+			}
+			number++
+			if filter != nil && !filter.Match(&transform.Packet{Number: number, Block: b, Interface: section[b.InterfaceID]}) {
+				continue
+			}
+			p := &Packet{
+				InterfaceID:    ids[b.InterfaceID],
+				TimestampNs:    section[b.InterfaceID].PacketTime(b.TimestampHigh, b.TimestampLow).UnixNano(),
+				OriginalLength: b.OriginalPacketLength,
+				Data:           b.PacketData,
+			}
+			for _, opt := range b.Options {
+				switch o := opt.(type) {
+				case *pcapng.Epb_Flags:
+					p.Flags = o.Value
+				case *pcapng.Opt_Comment:
+					p.Comments = append(p.Comments, o.Value)
+				}
+			}
+			if err := send(&Message{Packet: p}); err != nil {
+				return sum, err
+			}
+			sum.Packets++
+			sum.Bytes += uint64(len(p.Data))
+		}
+	}
+}
+
+// ForwardSource sends the packets src captures to s as interface 0, after
+// describing it, until count packets have been sent or, if count is 0,
+// src fails.
+func ForwardSource(src capture.Source, agent string, s Sender, count int) (Summary, error) {
+
+	var sum Summary
+	i := &Interface{LinkType: uint32(src.LinkType()), SnapLen: src.SnapLen(), Name: src.Name()}
+	if err := s.Send(&Message{Agent: agent, Interface: i}); err != nil {
+		return sum, err
+	}
+
+	for n := 0; count == 0 || n < count; n++ {
+		data, ci, err := src.ReadPacket()
+		if err != nil {
+			return sum, err
+		}
+		p := &Packet{
+			TimestampNs:    ci.Timestamp.UnixNano(),
+			OriginalLength: uint32(ci.Length),
+			Data:           data,
+			Flags:          ci.Direction,
+		}
+		if err := s.Send(&Message{Packet: p}); err != nil {
+			return sum, err
+		}
+		sum.Packets++
+		sum.Bytes += uint64(len(data))
+	}
+	return sum, nil
+}
+
+// Archive writes the messages r receives to pw until the end of the
+// stream: a section naming the agent in a comment, an interface with
+// nanosecond timestamps for each Interface and an Enhanced Packet Block for
+// each Packet.
+func Archive(r Receiver, pw *pcapng.PcapngWriter) (Summary, error) {
+
+	var sum Summary
+	started := false
+	interfaces := make(map[uint32]uint32) // stream ID to the ID in the output
+
+	for {
+		m, err := r.Recv()
+		if err == io.EOF {
+			return sum, nil
+		}
+		if err != nil {
+			return sum, err
+		}
+
+		if !started {
+			shb := &pcapng.SectionBlock{}
+			if m.Agent != "" {
+				shb.Options = append(shb.Options, &pcapng.Opt_Comment{Value: "agent " + m.Agent})
+			}
+			if err := pw.Write(shb); err != nil {
+				return sum, err
+			}
+			started = true
+		}
+
+		if i := m.Interface; i != nil {
+			if _, ok := interfaces[i.ID]; ok {
+				return sum, &StreamError{fmt.Sprintf("interface %v declared twice", i.ID)}
+			}
+			ifb := &pcapng.InterfaceBlock{
+				LinkType: uint16(i.LinkType),
+				SnapLen:  i.SnapLen,
+				Options:  []pcapng.Option{&pcapng.If_Tsresol{Value: archiveTsresol}},
+			}
+			if i.Name != "" {
+				ifb.Options = append(ifb.Options, &pcapng.If_Name{Value: i.Name})
+			}
+			if i.Description != "" {
+				ifb.Options = append(ifb.Options, &pcapng.If_Description{Value: i.Description})
+			}
+			if err := pw.Write(ifb); err != nil {
+				return sum, err
+			}
+			interfaces[i.ID] = uint32(len(interfaces))
+		}
+
+		if p := m.Packet; p != nil {
+			id, ok := interfaces[p.InterfaceID]
+			if !ok {
+				return sum, &StreamError{fmt.Sprintf("packet on undeclared interface %v", p.InterfaceID)}
+			}
+			high, low := pcapng.SplitTimestamp(time.Unix(0, p.TimestampNs), archiveTsresol)
+			epb := &pcapng.EnhancedPacketBlock{
+				InterfaceID:          id,
+				TimestampHigh:        high,
+				TimestampLow:         low,
+				CapturedPacketLength: uint32(len(p.Data)),
+				OriginalPacketLength: p.OriginalLength,
+				PacketData:           p.Data,
+			}
+			if p.Flags != 0 {
+				epb.WithFlags(p.Flags)
+			}
+			for _, c := range p.Comments {
+				epb.WithComment(c)
+			}
+			if err := pw.Write(epb); err != nil {
+				return sum, err
+			}
+			sum.Packets++
+			sum.Bytes += uint64(len(p.Data))
+		}
+	}
+}
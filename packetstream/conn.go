@@ -0,0 +1,181 @@
+package packetstream
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Sender sends messages. The client stream of Upload and the server
+// stream of Subscribe gRPC generates have this method, as do Conn and
+// StreamSender.
+type Sender interface {
+	Send(*Message) error
+}
+
+// Receiver receives messages, returning io.EOF at the end of the stream.
+// The server stream of Upload and the client stream of Subscribe gRPC
+// generates have this method, as do Conn and StreamReceiver.
+type Receiver interface {
+	Recv() (*Message, error)
+}
+
+// marshaler is implemented by the messages.
+type marshaler interface {
+	Marshal() []byte
+	Unmarshal([]byte) error
+}
+
+// Codec encodes the messages for gRPC without generated code; it is a
+// grpc/encoding.Codec. Register it with encoding.RegisterCodec and select
+// it with grpc.CallContentSubtype(Codec{}.Name()).
+type Codec struct{}
+
+// Marshal encodes a *Message, *Interface, *Packet, *Summary or
+// *Subscription.
+func (Codec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(marshaler)
+	if !ok {
+		return nil, &StreamError{fmt.Sprintf("cannot encode %T", v)}
+	}
+	return m.Marshal(), nil
+}
+
+// Unmarshal decodes data into a *Message, *Interface, *Packet, *Summary or
+// *Subscription.
+func (Codec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(marshaler)
+	if !ok {
+		return &StreamError{fmt.Sprintf("cannot decode into %T", v)}
+	}
+	return m.Unmarshal(data)
+}
+
+// Name is the content subtype of the codec.
+func (Codec) Name() string {
+	return "packetstream"
+}
+
+// Conn carries messages over a byte stream, e.g. a TCP connection, each
+// preceded by its length as a varint, the protobuf delimited format. It is
+// a ClientStream, so ServeUpload, ServeSubscribe, CloseUpload and
+// Subscribe work over it as they do over gRPC.
+type Conn struct {
+	// MaxMessage, if not 0, makes longer messages an error rather than
+	// reading them into memory, like PcapngReader.MaxBlock does for blocks.
+	// Messages are read as their bytes arrive, so without a limit a length
+	// that no data follows costs no memory.
+	MaxMessage uint64
+
+	r *bufio.Reader
+	w io.Writer
+}
+
+// NewConn returns a Conn reading from r and writing to w. Either may be
+// nil for a Conn that only sends or only receives.
+func NewConn(r io.Reader, w io.Writer) *Conn {
+	c := &Conn{w: w}
+	if r != nil {
+		c.r = bufio.NewReader(r)
+	}
+	return c
+}
+
+// Send writes the message.
+func (c *Conn) Send(m *Message) error {
+	return c.write(m)
+}
+
+// Recv reads the next message, returning io.EOF at the end of the input.
+func (c *Conn) Recv() (*Message, error) {
+	m := &Message{}
+	if err := c.read(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// SendMsg writes a *Message, *Summary or *Subscription.
+func (c *Conn) SendMsg(m interface{}) error {
+	mm, ok := m.(marshaler)
+	if !ok {
+		return &StreamError{fmt.Sprintf("cannot encode %T", m)}
+	}
+	return c.write(mm)
+}
+
+// RecvMsg reads into a *Message, *Summary or *Subscription.
+func (c *Conn) RecvMsg(m interface{}) error {
+	mm, ok := m.(marshaler)
+	if !ok {
+		return &StreamError{fmt.Sprintf("cannot decode into %T", m)}
+	}
+	return c.read(mm)
+}
+
+// CloseSend ends the messages sent, so the other end reads io.EOF. A TCP
+// or Unix connection has its write side shut down, other writers that are
+// an io.Closer are closed.
+func (c *Conn) CloseSend() error {
+	switch w := c.w.(type) {
+	case interface{ CloseWrite() error }:
+		return w.CloseWrite()
+	case io.Closer:
+		return w.Close()
+	}
+	return nil
+}
+
+// SendSummary writes the reply to an upload.
+func (c *Conn) SendSummary(s *Summary) error {
+	return c.write(s)
+}
+
+// RecvSummary reads the reply to an upload.
+func (c *Conn) RecvSummary() (*Summary, error) {
+	s := &Summary{}
+	if err := c.read(s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (c *Conn) write(m marshaler) error {
+	buf := m.Marshal()
+	_, err := c.w.Write(append(appendUvarint(nil, uint64(len(buf))), buf...))
+	return err
+}
+
+func (c *Conn) read(m marshaler) error {
+	length, err := binary.ReadUvarint(c.r)
+	if err != nil {
+		if err == io.EOF {
+			return io.EOF
+		}
+		return err
+	}
+	if c.MaxMessage != 0 && length > c.MaxMessage {
+		return &StreamError{fmt.Sprintf("message of %v bytes is larger than %v", length, c.MaxMessage)}
+	}
+	if length > math.MaxInt64 {
+		return &StreamError{fmt.Sprintf("message of %v bytes is too large", length)}
+	}
+
+	// the buffer grows as the bytes arrive rather than by the length alone
+	var buf bytes.Buffer
+	if length < 1<<20 {
+		buf.Grow(int(length))
+	} else {
+		buf.Grow(1 << 20)
+	}
+	if _, err := io.CopyN(&buf, c.r, int64(length)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	return m.Unmarshal(buf.Bytes())
+}
@@ -0,0 +1,301 @@
+// Package packetstream streams captured packets between capture agents and
+// a central pcapng archiver, in the messages of packetstream.proto.
+//
+// The messages encode themselves in the protobuf wire format, so they can
+// be carried by gRPC through Codec or over any connection through Conn,
+// without generated code.
+package packetstream
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// StreamError is returned for messages that cannot be decoded.
+type StreamError struct {
+	errorString string
+}
+
+func (e *StreamError) Error() string {
+	return e.errorString
+}
+
+// Interface describes a capture interface, mirroring an Interface
+// Description Block.
+type Interface struct {
+	ID          uint32 // interface ID within the stream
+	LinkType    uint32
+	SnapLen     uint32
+	Name        string // if_name
+	Description string
+}
+
+// Packet is one captured packet, mirroring an Enhanced Packet Block.
+type Packet struct {
+	InterfaceID    uint32
+	TimestampNs    int64 // nanoseconds since the Unix epoch
+	OriginalLength uint32
+	Data           []byte
+	Flags          uint32 // epb_flags
+	Comments       []string
+}
+
+// Message is what an agent sends, an Interface or a Packet. Interfaces
+// must be sent before the packets that reference them.
+type Message struct {
+	Agent     string // sent once in the first message
+	Interface *Interface
+	Packet    *Packet
+}
+
+// Summary is what the archiver replies to an upload.
+type Summary struct {
+	Packets uint64
+	Bytes   uint64
+}
+
+// Subscription asks the archiver for packets.
+type Subscription struct {
+	Filter string
+}
+
+// the protobuf wire types used
+const (
+	wireVarint = 0
+	wire64     = 1
+	wireBytes  = 2
+	wire32     = 5
+)
+
+// encoder appends fields in the protobuf wire format, leaving out zero
+// values like proto3 does.
+type encoder []byte
+
+func (e *encoder) tag(field int, wireType int) {
+	*e = appendUvarint(*e, uint64(field<<3|wireType))
+}
+
+func (e *encoder) uint(field int, v uint64) {
+	if v != 0 {
+		e.tag(field, wireVarint)
+		*e = appendUvarint(*e, v)
+	}
+}
+
+func (e *encoder) bytes(field int, v []byte) {
+	if len(v) != 0 {
+		e.tag(field, wireBytes)
+		*e = appendUvarint(*e, uint64(len(v)))
+		*e = append(*e, v...)
+	}
+}
+
+// message appends an embedded message, even if it is empty, as a oneof
+// field is set by being present.
+func (e *encoder) message(field int, v []byte) {
+	e.tag(field, wireBytes)
+	*e = appendUvarint(*e, uint64(len(v)))
+	*e = append(*e, v...)
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
+
+// decodeFields calls f with each field of buf, the value of varint fields
+// in v and of length delimited ones in data. Fixed width fields are passed
+// over.
+func decodeFields(buf []byte, f func(field int, wireType int, v uint64, data []byte) error) error {
+
+	for len(buf) > 0 {
+		key, n := binary.Uvarint(buf)
+		if n <= 0 {
+			return &StreamError{"truncated field key"}
+		}
+		buf = buf[n:]
+		field, wireType := int(key>>3), int(key&7)
+
+		var v uint64
+		var data []byte
+		switch wireType {
+		case wireVarint:
+			if v, n = binary.Uvarint(buf); n <= 0 {
+				return &StreamError{fmt.Sprintf("truncated varint in field %v", field)}
+			}
+			buf = buf[n:]
+		case wireBytes:
+			length, n := binary.Uvarint(buf)
+			if n <= 0 || length > uint64(len(buf)-n) {
+				return &StreamError{fmt.Sprintf("truncated bytes in field %v", field)}
+			}
+			data = buf[n : n+int(length)]
+			buf = buf[n+int(length):]
+		case wire64, wire32:
+			size := 8
+			if wireType == wire32 {
+				size = 4
+			}
+			if len(buf) < size {
+				return &StreamError{fmt.Sprintf("truncated fixed field %v", field)}
+			}
+			buf = buf[size:]
+			continue
+		default:
+			return &StreamError{fmt.Sprintf("field %v has unsupported wire type %v", field, wireType)}
+		}
+		if err := f(field, wireType, v, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// uint32Field checks that a varint fits in 32 bits.
+func uint32Field(field int, v uint64) (uint32, error) {
+	if v > math.MaxUint32 {
+		return 0, &StreamError{fmt.Sprintf("field %v value %v does not fit in 32 bits", field, v)}
+	}
+	return uint32(v), nil
+}
+
+// Marshal encodes the interface in the protobuf wire format.
+func (i *Interface) Marshal() []byte {
+	var e encoder
+	e.uint(1, uint64(i.ID))
+	e.uint(2, uint64(i.LinkType))
+	e.uint(3, uint64(i.SnapLen))
+	e.bytes(4, []byte(i.Name))
+	e.bytes(5, []byte(i.Description))
+	return e
+}
+
+// Unmarshal decodes an interface encoded by Marshal.
+func (i *Interface) Unmarshal(buf []byte) error {
+	*i = Interface{}
+	return decodeFields(buf, func(field int, wireType int, v uint64, data []byte) (err error) {
+		switch field {
+		case 1:
+			i.ID, err = uint32Field(field, v)
+		case 2:
+			i.LinkType, err = uint32Field(field, v)
+		case 3:
+			i.SnapLen, err = uint32Field(field, v)
+		case 4:
+			i.Name = string(data)
+		case 5:
+			i.Description = string(data)
+		}
+		return err
+	})
+}
+
+// Marshal encodes the packet in the protobuf wire format.
+func (p *Packet) Marshal() []byte {
+	var e encoder
+	e.uint(1, uint64(p.InterfaceID))
+	e.uint(2, uint64(p.TimestampNs))
+	e.uint(3, uint64(p.OriginalLength))
+	e.bytes(4, p.Data)
+	e.uint(5, uint64(p.Flags))
+	for _, c := range p.Comments {
+		// repeated fields keep empty elements
+		e.tag(6, wireBytes)
+		e = appendUvarint(e, uint64(len(c)))
+		e = append(e, c...)
+	}
+	return e
+}
+
+// Unmarshal decodes a packet encoded by Marshal. Data refers to buf.
+func (p *Packet) Unmarshal(buf []byte) error {
+	*p = Packet{}
+	return decodeFields(buf, func(field int, wireType int, v uint64, data []byte) (err error) {
+		switch field {
+		case 1:
+			p.InterfaceID, err = uint32Field(field, v)
+		case 2:
+			p.TimestampNs = int64(v)
+		case 3:
+			p.OriginalLength, err = uint32Field(field, v)
+		case 4:
+			p.Data = data
+		case 5:
+			p.Flags, err = uint32Field(field, v)
+		case 6:
+			p.Comments = append(p.Comments, string(data))
+		}
+		return err
+	})
+}
+
+// Marshal encodes the message in the protobuf wire format.
+func (m *Message) Marshal() []byte {
+	var e encoder
+	e.bytes(1, []byte(m.Agent))
+	if m.Interface != nil {
+		e.message(2, m.Interface.Marshal())
+	} else if m.Packet != nil {
+		e.message(3, m.Packet.Marshal())
+	}
+	return e
+}
+
+// Unmarshal decodes a message encoded by Marshal.
+func (m *Message) Unmarshal(buf []byte) error {
+	*m = Message{}
+	return decodeFields(buf, func(field int, wireType int, v uint64, data []byte) error {
+		switch field {
+		case 1:
+			m.Agent = string(data)
+		case 2:
+			m.Interface, m.Packet = &Interface{}, nil
+			return m.Interface.Unmarshal(data)
+		case 3:
+			m.Packet, m.Interface = &Packet{}, nil
+			return m.Packet.Unmarshal(data)
+		}
+		return nil
+	})
+}
+
+// Marshal encodes the summary in the protobuf wire format.
+func (s *Summary) Marshal() []byte {
+	var e encoder
+	e.uint(1, s.Packets)
+	e.uint(2, s.Bytes)
+	return e
+}
+
+// Unmarshal decodes a summary encoded by Marshal.
+func (s *Summary) Unmarshal(buf []byte) error {
+	*s = Summary{}
+	return decodeFields(buf, func(field int, wireType int, v uint64, data []byte) error {
+		switch field {
+		case 1:
+			s.Packets = v
+		case 2:
+			s.Bytes = v
+		}
+		return nil
+	})
+}
+
+// Marshal encodes the subscription in the protobuf wire format.
+func (s *Subscription) Marshal() []byte {
+	var e encoder
+	e.bytes(1, []byte(s.Filter))
+	return e
+}
+
+// Unmarshal decodes a subscription encoded by Marshal.
+func (s *Subscription) Unmarshal(buf []byte) error {
+	*s = Subscription{}
+	return decodeFields(buf, func(field int, wireType int, v uint64, data []byte) error {
+		if field == 1 {
+			s.Filter = string(data)
+		}
+		return nil
+	})
+}
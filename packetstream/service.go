@@ -0,0 +1,102 @@
+package packetstream
+
+import (
+	"github.com/RajeshGottlieb/go/pcapng"
+)
+
+// The full names of the service and its methods, for a grpc.ServiceDesc.
+const (
+	ServiceName     = "packetstream.PacketStream"
+	UploadMethod    = "/" + ServiceName + "/Upload"
+	SubscribeMethod = "/" + ServiceName + "/Subscribe"
+)
+
+// Stream is a stream of messages in both directions. grpc.ServerStream and
+// grpc.ClientStream are one, as is Conn, so the adapters below serve and
+// call the service without this module depending on gRPC.
+type Stream interface {
+	SendMsg(m interface{}) error
+	RecvMsg(m interface{}) error
+}
+
+// ClientStream is the stream of a call, grpc.ClientStream or a Conn.
+type ClientStream interface {
+	Stream
+	CloseSend() error
+}
+
+// streamSender and streamReceiver adapt a Stream to Sender and Receiver.
+type streamSender struct{ s Stream }
+type streamReceiver struct{ s Stream }
+
+func (ss streamSender) Send(m *Message) error {
+	return ss.s.SendMsg(m)
+}
+
+func (sr streamReceiver) Recv() (*Message, error) {
+	m := &Message{}
+	if err := sr.s.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// StreamSender returns a Sender of messages over s, e.g. the client
+// stream of an Upload call for Forward or ForwardSource.
+func StreamSender(s Stream) Sender {
+	return streamSender{s}
+}
+
+// StreamReceiver returns a Receiver of messages over s, e.g. the stream
+// Subscribe returns for Archive.
+func StreamReceiver(s Stream) Receiver {
+	return streamReceiver{s}
+}
+
+// ServeUpload handles an Upload call: it writes the messages of the
+// stream to pw with Archive and replies with the summary.
+func ServeUpload(s Stream, pw *pcapng.PcapngWriter) error {
+	sum, err := Archive(StreamReceiver(s), pw)
+	if err != nil {
+		return err
+	}
+	return s.SendMsg(&sum)
+}
+
+// ServeSubscribe handles a Subscribe call: it receives the subscription
+// and sends the interfaces pr reads and the packets its filter matches
+// with ForwardSubscription. pr may follow a growing file to serve packets
+// as they are archived.
+func ServeSubscribe(s Stream, pr *pcapng.PcapngReader) error {
+	sub := &Subscription{}
+	if err := s.RecvMsg(sub); err != nil {
+		return err
+	}
+	_, err := ForwardSubscription(pr, sub, StreamSender(s))
+	return err
+}
+
+// CloseUpload ends the messages sent on the client stream of an Upload
+// call and returns the summary the archiver replies with.
+func CloseUpload(cs ClientStream) (*Summary, error) {
+	if err := cs.CloseSend(); err != nil {
+		return nil, err
+	}
+	sum := &Summary{}
+	if err := cs.RecvMsg(sum); err != nil {
+		return nil, err
+	}
+	return sum, nil
+}
+
+// Subscribe sends the subscription on the client stream of a Subscribe
+// call and returns a Receiver of the messages the archiver sends back.
+func Subscribe(cs ClientStream, sub *Subscription) (Receiver, error) {
+	if err := cs.SendMsg(sub); err != nil {
+		return nil, err
+	}
+	if err := cs.CloseSend(); err != nil {
+		return nil, err
+	}
+	return StreamReceiver(cs), nil
+}
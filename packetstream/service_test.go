@@ -0,0 +1,202 @@
+package packetstream
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/RajeshGottlieb/go/pcapng"
+)
+
+// dnsQuery is an Ethernet IPv4 UDP packet from 10.0.0.1:5353 to
+// 10.0.0.53:53.
+var dnsQuery = []byte{
+	0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x08, 0x00,
+	0x45, 0, 0, 28, 0, 0, 0, 0, 1, 17, 0, 0, 10, 0, 0, 1, 10, 0, 0, 53,
+	0x14, 0xe9, 0x00, 0x35, 0, 8, 0, 0,
+}
+
+// testCapture returns a capture of a DNS query and a packet of several
+// megabytes.
+func testCapture(t *testing.T) ([]byte, [][]byte) {
+	large := make([]byte, 5<<20)
+	for i := range large {
+		large[i] = byte(i)
+	}
+	packets := [][]byte{dnsQuery, large}
+
+	var buf bytes.Buffer
+	pw := pcapng.Writer(&buf)
+	if err := pw.Write(&pcapng.SectionBlock{MajorVersion: 1, SectionLength: -1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := pw.Write(&pcapng.InterfaceBlock{LinkType: 1}); err != nil {
+		t.Fatal(err)
+	}
+	for i, p := range packets {
+		if err := pw.WritePacket(pcapng.PacketMeta{Timestamp: time.Unix(int64(i), 0)}, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes(), packets
+}
+
+// connPair returns the two ends of a connection and a function closing
+// the server's sending side.
+func connPair() (client, server *Conn, closeServer func()) {
+	cr, sw := io.Pipe()
+	sr, cw := io.Pipe()
+	return NewConn(cr, cw), NewConn(sr, sw), func() { sw.Close() }
+}
+
+// readPackets returns the data of the packets in a capture.
+func readPackets(t *testing.T, capture []byte) [][]byte {
+	var packets [][]byte
+	pr := pcapng.Reader(bytes.NewReader(capture))
+	for {
+		b, err := pr.Read()
+		if err == io.EOF {
+			return packets
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if epb, ok := b.(*pcapng.EnhancedPacketBlock); ok {
+			packets = append(packets, epb.PacketData)
+		}
+	}
+}
+
+func TestUpload(t *testing.T) {
+
+	capture, packets := testCapture(t)
+	client, server, closeServer := connPair()
+
+	var archive bytes.Buffer
+	done := make(chan error, 1)
+	go func() {
+		err := ServeUpload(server, pcapng.Writer(&archive))
+		closeServer()
+		done <- err
+	}()
+
+	sent, err := Forward(pcapng.Reader(bytes.NewReader(capture)), "agent1", StreamSender(client))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum, err := CloseUpload(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if *sum != sent || sum.Packets != uint64(len(packets)) {
+		t.Errorf("archiver received %+v, sent %+v", *sum, sent)
+	}
+
+	got := readPackets(t, archive.Bytes())
+	if len(got) != len(packets) {
+		t.Fatalf("archived %v packets, want %v", len(got), len(packets))
+	}
+	for i := range got {
+		if !bytes.Equal(got[i], packets[i]) {
+			t.Errorf("packet %v of %v bytes archived as %v bytes that differ", i, len(packets[i]), len(got[i]))
+		}
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+
+	capture, packets := testCapture(t)
+
+	tests := []struct {
+		filter string
+		want   [][]byte
+		err    bool
+	}{
+		{"", packets, false},
+		{"udp.port == 53", packets[:1], false},
+		{"tcp", nil, false},
+		{"udp.port ==", nil, true},
+	}
+
+	for _, tt := range tests {
+		client, server, closeServer := connPair()
+		done := make(chan error, 1)
+		go func() {
+			err := ServeSubscribe(server, pcapng.Reader(bytes.NewReader(capture)))
+			closeServer()
+			done <- err
+		}()
+
+		r, err := Subscribe(client, &Subscription{Filter: tt.filter})
+		if err != nil {
+			t.Fatalf("%q: %v", tt.filter, err)
+		}
+		var out bytes.Buffer
+		if _, err := Archive(r, pcapng.Writer(&out)); err != nil {
+			t.Errorf("%q: %v", tt.filter, err)
+			continue
+		}
+		if err := <-done; (err != nil) != tt.err {
+			t.Errorf("%q: ServeSubscribe returned %v", tt.filter, err)
+		}
+
+		got := readPackets(t, out.Bytes())
+		if len(got) != len(tt.want) {
+			t.Errorf("%q: received %v packets, want %v", tt.filter, len(got), len(tt.want))
+			continue
+		}
+		for i := range got {
+			if !bytes.Equal(got[i], tt.want[i]) {
+				t.Errorf("%q: packet %v differs", tt.filter, i)
+			}
+		}
+	}
+}
+
+func TestMaxMessage(t *testing.T) {
+
+	capture, _ := testCapture(t)
+	var buf bytes.Buffer
+	if _, err := Forward(pcapng.Reader(bytes.NewReader(capture)), "", NewConn(nil, &buf)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		max      uint64
+		messages int
+	}{
+		{0, 3},
+		{5<<20 + 100, 3},
+		{1 << 20, 2},
+	}
+
+	for _, tt := range tests {
+		c := NewConn(bytes.NewReader(buf.Bytes()), nil)
+		c.MaxMessage = tt.max
+		n := 0
+		for {
+			_, err := c.Recv()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				if n == tt.messages {
+					break
+				}
+				t.Errorf("MaxMessage %v: message %v: %v", tt.max, n, err)
+				break
+			}
+			n++
+		}
+		if n != tt.messages {
+			t.Errorf("MaxMessage %v: read %v messages, want %v", tt.max, n, tt.messages)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package pcap
+
+import (
+	"io"
+
+	"github.com/RajeshGottlieb/go/pcapng"
+)
+
+// Bridge copies a pcap stream to pw as a single pcapng section with one
+// interface, flushing after every packet so a reader at the other end of a
+// pipe sees each packet as soon as it arrives. Timestamps keep their micro
+// or nano second resolution. It returns nil at the end of the stream.
+func Bridge(pr *PcapReader, pw *pcapng.PcapngWriter) error {
+
+	tsresol := uint8(6)
+	if pr.NanoSecond {
+		tsresol = 9
+	}
+	idb := &pcapng.InterfaceBlock{
+		LinkType: uint16(pr.Header.Network),
+		SnapLen:  pr.Header.Snaplen,
+	}
+	if tsresol != 6 {
+		idb.Options = append(idb.Options, &pcapng.If_Tsresol{Value: tsresol})
+	}
+
+	if err := pw.Write(&pcapng.SectionBlock{}); err != nil {
+		return err
+	}
+	if err := pw.Write(idb); err != nil {
+		return err
+	}
+	if err := pw.Flush(); err != nil {
+		return err
+	}
+
+	for {
+		rec, pkt, err := pr.ReadRecord()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		ticks := uint64(rec.TsSec)*pcapng.TicksPerSecond(tsresol) + uint64(rec.TsUsec)
+		epb := &pcapng.EnhancedPacketBlock{
+			TimestampHigh:        uint32(ticks >> 32),
+			TimestampLow:         uint32(ticks),
+			CapturedPacketLength: rec.InclLen,
+			OriginalPacketLength: rec.OrigLen,
+			PacketData:           pkt,
+		}
+		if err := pw.Write(epb); err != nil {
+			return err
+		}
+		if err := pw.Flush(); err != nil {
+			return err
+		}
+	}
+}
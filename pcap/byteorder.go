@@ -0,0 +1,28 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// ConvertByteOrder copies pr to fh writing the file header and every packet
+// header in endian.
+func ConvertByteOrder(pr *PcapReader, fh io.Writer, endian binary.ByteOrder) error {
+
+	pw, err := NewWriterByteOrder(fh, pr.Header, endian)
+	if err != nil {
+		return err
+	}
+
+	for {
+		header, pkt, err := pr.ReadRecord()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := pw.WriteRecord(header, pkt); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package pcap
+
+import (
+	"io"
+
+	"github.com/RajeshGottlieb/go/pcapng"
+)
+
+// FromPcapng writes the packets of pr as a pcap file to fh, with micro or
+// nano second timestamps depending on nano. A pcap file has one link type
+// and no options or other blocks, so the first interface decides the
+// header, interfaces of the same link type are collapsed into it and the
+// packets of the others are dropped. The report lists everything lost.
+func FromPcapng(pr *pcapng.PcapngReader, fh io.Writer, nano bool, rounding pcapng.Rounding) (*pcapng.FidelityReport, error) {
+
+	report := &pcapng.FidelityReport{}
+
+	tsresol := uint8(6)
+	if nano {
+		tsresol = 9
+	}
+
+	// the interface options a pcap file header or timestamp represents
+	kept := func(opt pcapng.Option) bool {
+		switch opt.(type) {
+		case *pcapng.If_Tsresol, *pcapng.If_Tsoffset:
+			return true
+		}
+		return false
+	}
+
+	var pw *PcapWriter
+	var interfaces []*pcapng.InterfaceBlock
+	sections := 0
+
+	for {
+		block, err := pr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return report, err
+		}
+
+		switch b := block.(type) {
+		case *pcapng.SectionBlock:
+			sections++
+			if sections > 1 {
+				report.Sections++
+			}
+			interfaces = nil
+			report.CountOptions(b.Options, nil)
+
+		case *pcapng.InterfaceBlock:
+			interfaces = append(interfaces, b)
+			report.CountOptions(b.Options, kept)
+			if pw == nil {
+				header := PcapHdr{
+					MagicNumber:  same_endian_usec_magic,
+					VersionMajor: 2,
+					VersionMinor: 4,
+					Snaplen:      b.SnapLen,
+					Network:      uint32(b.LinkType),
+				}
+				if nano {
+					header.MagicNumber = same_endian_nsec_magic
+				}
+				if header.Snaplen == 0 {
+					header.Snaplen = 262144
+				}
+				if pw, err = NewWriter(fh, header); err != nil {
+					return report, err
+				}
+				pw.Rounding = rounding
+			} else if uint32(b.LinkType) == pw.Header.Network {
+				report.Interfaces++
+			} else {
+				report.Notef("interface %v of section %v has link type %v, not %v: its packets are dropped",
+					len(interfaces)-1, sections-1, b.LinkType, pw.Header.Network)
+			}
+
+		case *pcapng.EnhancedPacketBlock:
+			if int(b.InterfaceID) >= len(interfaces) || pw == nil {
+				report.Packets++
+				continue
+			}
+			ifb := interfaces[b.InterfaceID]
+			if uint32(ifb.LinkType) != pw.Header.Network {
+				report.Packets++
+				continue
+			}
+			report.CountOptions(b.Options, nil)
+			if pcapng.LosesDigits(uint64(b.TimestampHigh)<<32|uint64(b.TimestampLow), ifb.Tsresol(), tsresol) {
+				report.Truncated++
+			}
+			meta := pcapng.PacketMeta{
+				Timestamp:      ifb.PacketTime(b.TimestampHigh, b.TimestampLow),
+				OriginalLength: int(b.OriginalPacketLength),
+			}
+			if err := pw.WritePacket(meta, b.PacketData); err != nil {
+				return report, err
+			}
+
+		case *pcapng.NameResolutionBlock:
+			report.NameResolution++
+		case *pcapng.InterfaceStatisticsBlock:
+			report.Statistics++
+		default:
+			report.OtherBlocks++
+		}
+	}
+
+	if pw == nil {
+		return report, &PcapError{"the pcapng input has no interface to take the link type from"}
+	}
+	return report, pw.Flush()
+}
@@ -0,0 +1,24 @@
+package pcap
+
+// Metadata locates the packet returned by the last Read or ReadRecord.
+// A pcap file is a single section in which every record is a packet, so
+// Section is always 0 and Block is Packet - 1.
+type Metadata struct {
+	Offset  int64 // of the packet header, counted from where the reader started
+	Block   int   // 0 based index of the record
+	Section int
+	Packet  int // 1 based packet number
+}
+
+// Metadata returns the position of the packet returned by the last Read
+// or ReadRecord.
+func (pr *PcapReader) Metadata() Metadata {
+	return pr.metadata
+}
+
+// advance records the packet with inclLen bytes of data just read.
+func (pr *PcapReader) advance(inclLen uint32) {
+	pr.metadata = Metadata{Offset: pr.offset, Block: pr.packets, Packet: pr.packets + 1}
+	pr.offset += 16 + int64(inclLen)
+	pr.packets++
+}
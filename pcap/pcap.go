@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"io"
 	"math"
+
+	"github.com/RajeshGottlieb/go/pcapng"
 )
 
 // PcapHdr is the libpcap defined header at the top of each libpcap file.
@@ -37,12 +39,19 @@ func (pe *PcapError) Error() string {
 	return pe.errorString
 }
 
+// ErrWrongFormat is returned by Reader for a pcapng file.
+var ErrWrongFormat = &PcapError{"the input is a pcapng file, not libpcap: read it with the pcapng package"}
+
 // PcapReader encapsulates all the pcap reading logic
 type PcapReader struct {
 	fh         io.Reader
 	Header     PcapHdr
 	Endian     binary.ByteOrder
-	NanoSecond bool // true if PcapRecHdr.TsUsec should be interpretted as nano seconds
+	NanoSecond bool     // true if PcapRecHdr.TsUsec should be interpretted as nano seconds
+	MaxPacket  uint32   // if not 0, packets with more captured bytes are an error
+	offset     int64    // of the next packet header
+	packets    int      // read so far
+	metadata   Metadata // of the last packet read
 }
 
 // PcapWriter encapsulates all the pcap reading logic
@@ -50,7 +59,8 @@ type PcapWriter struct {
 	fh         io.Writer
 	Header     PcapHdr
 	Endian     binary.ByteOrder
-	NanoSecond bool // true if PcapRecHdr.TsUsec should be interpretted as nano seconds
+	NanoSecond bool            // true if PcapRecHdr.TsUsec should be interpretted as nano seconds
+	Rounding   pcapng.Rounding // how Write and WritePacket drop digits beyond the resolution
 }
 
 // pcap magic numbers
@@ -72,6 +82,11 @@ func (pr *PcapReader) readFileHeader() (err error) {
 		return &PcapError{fmt.Sprintf("read %v file header bytes expected %v\n", count, len(buf))}
 	}
 
+	// the Section Header Block type reads the same in either byte order
+	if binary.LittleEndian.Uint32(buf[0:4]) == pcapng.SECTION_HEADER_BLOCK {
+		return ErrWrongFormat
+	}
+
 	// pcap files can be encoded in either little endian or big endian
 	// Most hosts will be little endian so let's go with that first.
 	pr.Endian = binary.LittleEndian
@@ -107,6 +122,7 @@ func (pr *PcapReader) readFileHeader() (err error) {
 		return &PcapError{fmt.Sprintf("invalid pcap magic number 0x%x", pr.Header.MagicNumber)}
 	}
 
+	pr.offset = int64(len(buf))
 	return nil
 }
 
@@ -127,29 +143,11 @@ func Reader(fh io.Reader) (pr *PcapReader, err error) {
 // If there are no more packets it returns nil, io.EOF
 func (pr *PcapReader) Read() (ts float64, pkt []byte, err error) {
 
-	buf := make([]byte, 16)
-	count, err := pr.fh.Read(buf) // read packet header
-
-	if err != nil {
-		return ts, nil, err
-	} else if count != len(buf) {
-		return ts, nil, &PcapError{fmt.Sprintf("read %v packet header bytes expected %v\n", count, len(buf))}
-	}
-
-	var header PcapRecHdr
-	err = binary.Read(bytes.NewBuffer(buf), pr.Endian, &header)
+	header, pkt, err := pr.ReadRecord()
 	if err != nil {
 		return ts, nil, err
 	}
 
-	pkt = make([]byte, header.InclLen)
-	count, err = pr.fh.Read(pkt) // read packet bytes
-	if err != nil {
-		return ts, nil, err
-	} else if uint32(count) != header.InclLen {
-		return ts, nil, &PcapError{fmt.Sprintf("read %v packet bytes expected %v\n", count, header.InclLen)}
-	}
-
 	if pr.NanoSecond {
 		ts = float64(header.TsSec) + float64(header.TsUsec)/1000000000
 	} else {
@@ -161,20 +159,44 @@ func (pr *PcapReader) Read() (ts float64, pkt []byte, err error) {
 // Creates a new pcap file for writing.
 func Writer(fh io.Writer) (pw *PcapWriter, err error) {
 
+	var header PcapHdr
+
+	header.MagicNumber = same_endian_usec_magic
+	header.VersionMajor = 2
+	header.VersionMinor = 4
+	header.Thiszone = 0
+	header.Sigfigs = 0
+	header.Snaplen = 65535
+	header.Network = 1
+
+	return NewWriter(fh, header)
+}
+
+// NewWriter creates a new pcap file for writing with the given file header
+// in the byte order of the host. Use NewWriterByteOrder to choose another.
+// A nano second magic number makes the timestamps nano seconds.
+func NewWriter(fh io.Writer, header PcapHdr) (pw *PcapWriter, err error) {
+	return NewWriterByteOrder(fh, header, pcapng.HostByteOrder())
+}
+
+// NewWriterByteOrder is NewWriter writing the file in the given byte order.
+func NewWriterByteOrder(fh io.Writer, header PcapHdr, endian binary.ByteOrder) (pw *PcapWriter, err error) {
+
 	pw = new(PcapWriter)
 	pw.fh = fh
-
-	pw.Header.MagicNumber = same_endian_usec_magic
-	pw.Header.VersionMajor = 2
-	pw.Header.VersionMinor = 4
-	pw.Header.Thiszone = 0
-	pw.Header.Sigfigs = 0
-	pw.Header.Snaplen = 65535
-	pw.Header.Network = 1
+	pw.Header = header
+
+	switch header.MagicNumber {
+	case same_endian_usec_magic:
+		pw.NanoSecond = false
+	case same_endian_nsec_magic:
+		pw.NanoSecond = true
+	default:
+		return nil, &PcapError{fmt.Sprintf("invalid pcap magic number 0x%x", header.MagicNumber)}
+	}
 
 	// pcap files can be encoded in either little endian or big endian
-	// Not sure what the host endianness is. Let's assume it's little endian.
-	pw.Endian = binary.LittleEndian // would prefer binary.hostEndian if it existed
+	pw.Endian = endian
 
 	err = binary.Write(pw.fh, pw.Endian, pw.Header)
 	if err != nil {
@@ -186,9 +208,20 @@ func Writer(fh io.Writer) (pw *PcapWriter, err error) {
 
 func (pw *PcapWriter) Write(ts float64, pkt []byte) (err error) {
 
+	perSecond := 1000000.0
+	if pw.NanoSecond {
+		perSecond = 1000000000
+	}
+
 	integer, fraction := math.Modf(ts)
+	frac := pw.Rounding.RoundFloat(fraction * perSecond)
+	if frac >= perSecond {
+		// rounded up to the next second
+		integer++
+		frac -= perSecond
+	}
 	tsSec := uint32(integer)
-	tsUsec := uint32(fraction * 1000000)
+	tsUsec := uint32(frac)
 
 	header := PcapRecHdr{
 		TsSec:   tsSec,
@@ -0,0 +1,82 @@
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/RajeshGottlieb/go/pcapng"
+)
+
+// pcap magic numbers selecting the timestamp resolution, same endian as the host
+const (
+	MagicMicroseconds = same_endian_usec_magic
+	MagicNanoseconds  = same_endian_nsec_magic
+)
+
+// ReadRecord reads the next packet without converting its timestamp.
+// If there are no more packets it returns io.EOF
+func (pr *PcapReader) ReadRecord() (header PcapRecHdr, pkt []byte, err error) {
+
+	buf := make([]byte, 16)
+	if count, err := io.ReadFull(pr.fh, buf); err != nil {
+		return header, nil, err
+	} else if count != len(buf) {
+		return header, nil, &PcapError{fmt.Sprintf("read %v packet header bytes expected %v\n", count, len(buf))}
+	}
+
+	if err := binary.Read(bytes.NewBuffer(buf), pr.Endian, &header); err != nil {
+		return header, nil, err
+	}
+
+	if pr.MaxPacket != 0 && header.InclLen > pr.MaxPacket {
+		return header, nil, &PcapError{fmt.Sprintf("packet of %v bytes is larger than the maximum of %v", header.InclLen, pr.MaxPacket)}
+	}
+
+	pkt = make([]byte, header.InclLen)
+	if count, err := io.ReadFull(pr.fh, pkt); err != nil {
+		return header, nil, err
+	} else if uint32(count) != header.InclLen {
+		return header, nil, &PcapError{fmt.Sprintf("read %v packet bytes expected %v\n", count, header.InclLen)}
+	}
+	pr.advance(header.InclLen)
+	return header, pkt, nil
+}
+
+// WriteRecord writes a packet with a header that is already in the
+// resolution of the file.
+func (pw *PcapWriter) WriteRecord(header PcapRecHdr, pkt []byte) (err error) {
+
+	if err := binary.Write(pw.fh, pw.Endian, header); err != nil {
+		return err
+	}
+
+	count, err := pw.fh.Write(pkt)
+	if err != nil {
+		return err
+	} else if count != len(pkt) {
+		return &PcapError{fmt.Sprintf("wrote %v packet bytes expected %v\n", count, len(pkt))}
+	}
+	return nil
+}
+
+// ConvertPrecision converts the timestamp of a packet header between micro
+// and nano seconds.
+func ConvertPrecision(header PcapRecHdr, fromNano, toNano bool, r pcapng.Rounding) PcapRecHdr {
+
+	from, to := uint8(6), uint8(6)
+	if fromNano {
+		from = 9
+	}
+	if toNano {
+		to = 9
+	}
+
+	ticks := uint64(header.TsSec)*pcapng.TicksPerSecond(from) + uint64(header.TsUsec)
+	ticks = pcapng.ConvertTicks(ticks, from, to, r)
+
+	header.TsSec = uint32(ticks / pcapng.TicksPerSecond(to))
+	header.TsUsec = uint32(ticks % pcapng.TicksPerSecond(to))
+	return header
+}
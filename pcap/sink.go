@@ -0,0 +1,65 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/RajeshGottlieb/go/pcapng"
+)
+
+// WritePacket writes a packet with the timestamp in micro or nano seconds
+// depending on NanoSecond, rounded as Rounding selects. The interface and
+// flags are not representable in a pcap file and are ignored.
+func (pw *PcapWriter) WritePacket(meta pcapng.PacketMeta, data []byte) error {
+
+	origLen := meta.OriginalLength
+	if origLen == 0 {
+		origLen = len(data)
+	}
+
+	tsresol := uint8(6)
+	if pw.NanoSecond {
+		tsresol = 9
+	}
+	ticks := uint64(meta.Timestamp.Unix())*1000000000 + uint64(meta.Timestamp.Nanosecond())
+	ticks = pcapng.ConvertTicks(ticks, 9, tsresol, pw.Rounding)
+
+	header := PcapRecHdr{
+		TsSec:   uint32(ticks / pcapng.TicksPerSecond(tsresol)),
+		TsUsec:  uint32(ticks % pcapng.TicksPerSecond(tsresol)),
+		InclLen: uint32(len(data)),
+		OrigLen: uint32(origLen),
+	}
+
+	if err := binary.Write(pw.fh, pw.Endian, header); err != nil {
+		return err
+	}
+
+	count, err := pw.fh.Write(data)
+	if err != nil {
+		return err
+	} else if uint32(count) != header.InclLen {
+		return &PcapError{fmt.Sprintf("wrote %v packet bytes expected %v\n", count, header.InclLen)}
+	}
+	return nil
+}
+
+// Flush flushes the underlying writer if it buffers, e.g. a *bufio.Writer.
+func (pw *PcapWriter) Flush() error {
+	if f, ok := pw.fh.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// Close flushes and closes the underlying writer if it is an io.Closer.
+func (pw *PcapWriter) Close() error {
+	if err := pw.Flush(); err != nil {
+		return err
+	}
+	if c, ok := pw.fh.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
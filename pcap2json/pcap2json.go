@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"github.com/RajeshGottlieb/go/geoip"
+	"github.com/RajeshGottlieb/go/oui"
+	"github.com/RajeshGottlieb/go/pcapjson"
+	"github.com/RajeshGottlieb/go/pcapng"
+	"os"
+	"strings"
+)
+
+func main() {
+
+	var opts pcapjson.Options
+	flag.BoolVar(&opts.PacketsOnly, "packets", false, "only write packet records, not sections and interfaces")
+	flag.BoolVar(&opts.NoData, "nodata", false, "leave out the base64 packet data")
+	flag.BoolVar(&opts.NoSummary, "nosummary", false, "leave out the decoded header fields")
+	geo := flag.String("geoip", "", "comma separated MaxMind DB files to look up the addresses in")
+	vendors := flag.Bool("vendors", false, "add the vendors of the MAC addresses from the built-in table")
+	ouiFiles := flag.String("oui", "", "comma separated manuf or IEEE OUI files to add to the built-in table, implies -vendors")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Printf("usage: %v [-packets] [-nodata] [-nosummary] [-geoip files] [-vendors] [-oui files] <input-pcapng>\n", os.Args[0])
+		return
+	}
+
+	if *geo != "" {
+		var err error
+		if opts.GeoIP, err = geoip.OpenSet(strings.Split(*geo, ",")...); err != nil {
+			panic(err)
+		}
+	}
+
+	if *ouiFiles != "" {
+		var err error
+		if opts.OUI, err = oui.Load(strings.Split(*ouiFiles, ",")...); err != nil {
+			panic(err)
+		}
+	} else if *vendors {
+		opts.OUI = oui.Builtin()
+	}
+
+	fh, err := os.Open(flag.Arg(0))
+	if err != nil {
+		panic(err)
+	}
+	defer fh.Close()
+
+	if err := pcapjson.Export(pcapng.Reader(bufio.NewReader(fh)), os.Stdout, opts); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"github.com/RajeshGottlieb/go/pcap"
+	"github.com/RajeshGottlieb/go/pcapng"
+	"os"
+)
+
+func main() {
+
+	buffer := flag.Int("buffer", 64*1024, "size in bytes of the input and output buffers")
+	flag.Parse()
+
+	if flag.NArg() != 0 || *buffer <= 0 {
+		fmt.Printf("usage: %v [-buffer bytes] < input-pcap > output-pcapng\n", os.Args[0])
+		return
+	}
+
+	pr, err := pcap.Reader(bufio.NewReaderSize(os.Stdin, *buffer))
+	if err != nil {
+		panic(err)
+	}
+
+	// Bridge flushes after every packet, the buffer only batches the writes of one block
+	pw := pcapng.Writer(bufio.NewWriterSize(os.Stdout, *buffer))
+
+	if err := pcap.Bridge(pr, pw); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/RajeshGottlieb/go/pcapng"
+	"github.com/RajeshGottlieb/go/transform"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// JobError is a bad job description or an error writing an output.
+type JobError struct {
+	errorString string
+}
+
+func (je *JobError) Error() string {
+	return je.errorString
+}
+
+// Job describes one run over a set of captures.
+type Job struct {
+	Inputs     []string // read one after the other, "-" is the standard input
+	Filter     string   // transform.Expr keeping the packets to process, all if empty
+	Transforms []string // specs for transform.New, applied in order
+	Outputs    []Output
+	Report     string // file to write the counters to, "-" for the standard output
+}
+
+// Output is one file the processed packets are split into.
+type Output struct {
+	Path   string // "-" is the standard output
+	Filter string // transform.Expr selecting the packets of this output, all if empty
+
+	expr    *transform.Expr
+	fh      *os.File
+	bw      *bufio.Writer
+	pw      *pcapng.PcapngWriter
+	packets int
+}
+
+// split is the last stage of a job. It writes every block other than
+// packets to each output and each packet to the outputs it matches, then
+// drops it so nothing reaches the writer of the pipeline.
+type split struct {
+	outputs []*Output
+	err     error
+}
+
+func (s *split) ApplyBlock(b pcapng.Block) bool {
+	for _, o := range s.outputs {
+		if err := o.pw.Write(b); err != nil && s.err == nil {
+			s.err = &JobError{fmt.Sprintf("%v: %v", o.Path, err)}
+		}
+	}
+	return false
+}
+
+func (s *split) Apply(p *transform.Packet) bool {
+	for _, o := range s.outputs {
+		if o.expr != nil && !o.expr.Match(p) {
+			continue
+		}
+		o.packets++
+		if err := o.pw.Write(p.Block); err != nil && s.err == nil {
+			s.err = &JobError{fmt.Sprintf("%v: %v", o.Path, err)}
+		}
+	}
+	return false
+}
+
+func main() {
+
+	check := flag.Bool("n", false, "only check the job description, do not run it")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "usage: %v [-n] <job-json|->\n", os.Args[0])
+		os.Exit(2)
+	}
+
+	job, err := readJob(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v: %v\n", flag.Arg(0), err)
+		os.Exit(2)
+	}
+
+	pl, s, err := build(job)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v: %v\n", flag.Arg(0), err)
+		os.Exit(2)
+	}
+	if *check {
+		return
+	}
+
+	for _, o := range s.outputs {
+		if o.fh, err = create(o.Path); err != nil {
+			fail("%v", err)
+		}
+		o.bw = bufio.NewWriter(o.fh)
+		o.pw = pcapng.Writer(o.bw)
+	}
+
+	// the split stage writes the outputs, the pipeline's own writer gets nothing
+	discard := pcapng.Writer(ioutil.Discard)
+	stats := make([]pcapng.ReadStats, len(job.Inputs))
+	for i, input := range job.Inputs {
+		rfh, err := open(input)
+		if err != nil {
+			fail("%v", err)
+		}
+		pr := pcapng.Reader(bufio.NewReader(rfh))
+		if err := pl.Run(pr, discard); err != nil {
+			fail("%v: %v", input, err)
+		}
+		stats[i] = pr.Stats()
+		if s.err != nil {
+			fail("%v", s.err)
+		}
+		rfh.Close()
+	}
+
+	for _, t := range pl.Stages {
+		// e.g. the subprocess of an exec transform
+		if c, ok := t.Transform.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				fail("%v: %v", t.Name, err)
+			}
+		}
+	}
+	for _, o := range s.outputs {
+		if err := o.bw.Flush(); err != nil {
+			fail("%v: %v", o.Path, err)
+		}
+		if err := o.fh.Close(); err != nil {
+			fail("%v: %v", o.Path, err)
+		}
+	}
+
+	if job.Report != "" {
+		if err := writeReport(job.Report, job.Inputs, stats, pl, s.outputs); err != nil {
+			fail("%v", err)
+		}
+	}
+}
+
+// readJob reads and decodes a job description in JSON or YAML, rejecting
+// unknown keys. A description starting with { is JSON.
+func readJob(name string) (*Job, error) {
+
+	fh, err := open(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(fh)
+	fh.Close()
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(strings.TrimSpace(string(data)), "{") {
+		if data, err = yamlToJSON(data); err != nil {
+			return nil, err
+		}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	job := &Job{}
+	if err := dec.Decode(job); err != nil {
+		return nil, err
+	}
+	if len(job.Inputs) == 0 {
+		return nil, &JobError{"the job has no inputs"}
+	}
+	if len(job.Outputs) == 0 {
+		return nil, &JobError{"the job has no outputs"}
+	}
+	stdin, stdout := name == "-", job.Report == "-"
+	for _, input := range job.Inputs {
+		if input == "-" && stdin {
+			return nil, &JobError{"the standard input can only be read once"}
+		}
+		stdin = stdin || input == "-"
+	}
+	for _, o := range job.Outputs {
+		if o.Path == "" {
+			return nil, &JobError{"an output has no path"}
+		}
+		if o.Path == "-" && stdout {
+			return nil, &JobError{"only one output or the report can go to the standard output"}
+		}
+		stdout = stdout || o.Path == "-"
+	}
+	return job, nil
+}
+
+// build compiles the filters and transforms of a job into a pipeline
+// ending with the split stage.
+func build(job *Job) (*transform.Pipeline, *split, error) {
+
+	pl := transform.NewPipeline()
+	if job.Filter != "" {
+		f, err := transform.ExprFilter(job.Filter)
+		if err != nil {
+			return nil, nil, err
+		}
+		pl.Add("filter", f)
+	}
+	for _, spec := range job.Transforms {
+		t, err := transform.New(spec)
+		if err != nil {
+			return nil, nil, err
+		}
+		pl.Add(spec, t)
+	}
+
+	s := &split{}
+	for i := range job.Outputs {
+		o := &job.Outputs[i]
+		if o.Filter != "" {
+			expr, err := transform.ParseExpr(o.Filter)
+			if err != nil {
+				return nil, nil, &JobError{fmt.Sprintf("%v: %v", o.Path, err)}
+			}
+			o.expr = expr
+		}
+		s.outputs = append(s.outputs, o)
+	}
+	pl.Add("split", s)
+	return pl, s, nil
+}
+
+// writeReport writes the anomalies found in each input, the counters of
+// each stage and the packets of each output.
+func writeReport(name string, inputs []string, stats []pcapng.ReadStats, pl *transform.Pipeline, outputs []*Output) error {
+
+	fh, err := create(name)
+	if err != nil {
+		return err
+	}
+
+	var sb strings.Builder
+	for i, input := range inputs {
+		fmt.Fprintf(&sb, "input %v: %v\n", input, stats[i])
+	}
+	for _, st := range pl.Stages[:len(pl.Stages)-1] {
+		fmt.Fprintf(&sb, "%v: %v packets in, %v dropped, %v out\n", st.Name, st.Packets, st.Dropped, st.Packets-st.Dropped)
+	}
+	for _, o := range outputs {
+		fmt.Fprintf(&sb, "output %v: %v packets\n", o.Path, o.packets)
+	}
+
+	if _, err := io.WriteString(fh, sb.String()); err != nil {
+		return err
+	}
+	return fh.Close()
+}
+
+// open opens an input file, "-" is the standard input.
+func open(name string) (*os.File, error) {
+	if name == "-" {
+		return os.Stdin, nil
+	}
+	return os.Open(name)
+}
+
+// create creates an output file, "-" is the standard output.
+func create(name string) (*os.File, error) {
+	if name == "-" {
+		return os.Stdout, nil
+	}
+	return os.Create(name)
+}
+
+// fail reports an error on the standard error and exits with status 1.
+func fail(format string, a ...interface{}) {
+	fmt.Fprintf(os.Stderr, "%v: %v\n", os.Args[0], fmt.Sprintf(format, a...))
+	os.Exit(1)
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// yamlLine is a line of a YAML description without its comment.
+type yamlLine struct {
+	n      int // line number
+	indent int
+	text   string
+}
+
+// yamlToJSON converts the subset of YAML a job needs to JSON, so that it
+// is decoded like a JSON description: block mappings and sequences,
+// including sequences of mappings, flow sequences of scalars like
+// [a, b], plain, single and double quoted scalars and comments. Every
+// scalar is a string, other than null and ~. Anchors, tags, block
+// scalars and flow mappings are errors.
+func yamlToJSON(data []byte) ([]byte, error) {
+
+	var lines []yamlLine
+	for i, text := range strings.Split(string(data), "\n") {
+		text = strings.TrimRight(stripComment(text), " \t\r")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || (i == 0 && trimmed == "---") {
+			continue
+		}
+		if trimmed == "..." {
+			break
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, &JobError{fmt.Sprintf("line %v: tabs cannot indent YAML", i+1)}
+		}
+		lines = append(lines, yamlLine{i + 1, len(text) - len(trimmed), trimmed})
+	}
+	if len(lines) == 0 {
+		return nil, &JobError{"the job description is empty"}
+	}
+
+	p := &yamlParser{lines: lines}
+	v, err := p.node(lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.i < len(lines) {
+		return nil, &JobError{fmt.Sprintf("line %v: unexpected indentation", lines[p.i].n)}
+	}
+	return json.Marshal(v)
+}
+
+// stripComment removes a # comment, which starts a line or follows a
+// space outside of quotes.
+func stripComment(text string) string {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || text[i-1] == ' ' || text[i-1] == '\t'):
+			return text[:i]
+		}
+	}
+	return text
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	i     int // the next line
+}
+
+// node parses the mapping or sequence starting at the current line.
+func (p *yamlParser) node(indent int) (interface{}, error) {
+	if isItem(p.lines[p.i].text) {
+		return p.sequence(indent)
+	}
+	return p.mapping(indent)
+}
+
+func isItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func (p *yamlParser) sequence(indent int) (interface{}, error) {
+
+	list := []interface{}{}
+	for p.i < len(p.lines) && p.lines[p.i].indent == indent && isItem(p.lines[p.i].text) {
+		line := p.lines[p.i]
+		rest := strings.TrimLeft(line.text[1:], " ")
+		if rest == "" {
+			p.i++
+			if p.i >= len(p.lines) || p.lines[p.i].indent <= indent {
+				list = append(list, nil)
+				continue
+			}
+			v, err := p.node(p.lines[p.i].indent)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+			continue
+		}
+		if _, _, ok := splitKey(rest); ok || isItem(rest) {
+			// the item is a mapping or sequence starting on the same line as the -
+			p.lines[p.i] = yamlLine{line.n, indent + len(line.text) - len(rest), rest}
+			v, err := p.node(p.lines[p.i].indent)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+			continue
+		}
+		v, err := scalar(line.n, rest)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+		p.i++
+	}
+	return list, nil
+}
+
+func (p *yamlParser) mapping(indent int) (interface{}, error) {
+
+	m := make(map[string]interface{})
+	for p.i < len(p.lines) && p.lines[p.i].indent == indent {
+		line := p.lines[p.i]
+		key, value, ok := splitKey(line.text)
+		if !ok {
+			return nil, &JobError{fmt.Sprintf("line %v: expected key: value, got %q", line.n, line.text)}
+		}
+		if _, dup := m[key]; dup {
+			return nil, &JobError{fmt.Sprintf("line %v: key %q given twice", line.n, key)}
+		}
+		p.i++
+
+		if value != "" {
+			v, err := scalar(line.n, value)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = v
+			continue
+		}
+		switch {
+		case p.i < len(p.lines) && p.lines[p.i].indent > indent:
+			v, err := p.node(p.lines[p.i].indent)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = v
+		case p.i < len(p.lines) && p.lines[p.i].indent == indent && isItem(p.lines[p.i].text):
+			// a sequence may be indented as much as its key
+			v, err := p.sequence(indent)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = v
+		default:
+			m[key] = nil
+		}
+	}
+	return m, nil
+}
+
+// splitKey splits "key: value" or "key:" outside of quotes.
+func splitKey(text string) (string, string, bool) {
+
+	if text[0] == '[' || text[0] == '{' {
+		return "", "", false
+	}
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			if i == 0 {
+				quote = c
+			}
+		case c == ':' && (i == len(text)-1 || text[i+1] == ' '):
+			raw := strings.TrimSpace(text[:i])
+			if raw == "" {
+				return "", "", false
+			}
+			key, err := scalar(0, raw)
+			s, isString := key.(string)
+			if err != nil || !isString {
+				return "", "", false
+			}
+			return s, strings.TrimSpace(text[i+1:]), true
+		}
+	}
+	return "", "", false
+}
+
+// scalar parses a scalar or a flow sequence of scalars.
+func scalar(n int, text string) (interface{}, error) {
+
+	if text == "" {
+		return nil, &JobError{fmt.Sprintf("line %v: missing value", n)}
+	}
+	switch text[0] {
+	case '[':
+		if !strings.HasSuffix(text, "]") {
+			return nil, &JobError{fmt.Sprintf("line %v: flow sequences must end on their line", n)}
+		}
+		list := []interface{}{}
+		inner := strings.TrimSpace(text[1 : len(text)-1])
+		if inner == "" {
+			return list, nil
+		}
+		for _, item := range splitFlow(inner) {
+			item = strings.TrimSpace(item)
+			if item == "" || item[0] == '[' {
+				return nil, &JobError{fmt.Sprintf("line %v: bad flow sequence %v", n, text)}
+			}
+			v, err := scalar(n, item)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	case '"':
+		s, err := strconv.Unquote(text)
+		if err != nil {
+			return nil, &JobError{fmt.Sprintf("line %v: bad double quoted string %v", n, text)}
+		}
+		return s, nil
+	case '\'':
+		if len(text) < 2 || !strings.HasSuffix(text, "'") {
+			return nil, &JobError{fmt.Sprintf("line %v: bad single quoted string %v", n, text)}
+		}
+		return strings.Replace(text[1:len(text)-1], "''", "'", -1), nil
+	case '{', '&', '*', '!', '|', '>':
+		return nil, &JobError{fmt.Sprintf("line %v: %v is not supported, use JSON for it", n, text)}
+	}
+	if text == ":" || strings.HasPrefix(text, ": ") {
+		return nil, &JobError{fmt.Sprintf("line %v: key missing before %v", n, text)}
+	}
+	if text == "null" || text == "~" {
+		return nil, nil
+	}
+	return text, nil
+}
+
+// splitFlow splits the items of a flow sequence at the commas outside of
+// quotes.
+func splitFlow(text string) []string {
+
+	var items []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ',':
+			items = append(items, text[start:i])
+			start = i + 1
+		}
+	}
+	return append(items, text[start:])
+}
@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+func TestYAMLToJSON(t *testing.T) {
+
+	tests := []struct {
+		yaml string
+		json string // "" for an error
+	}{
+		{"inputs: [a.pcapng, b.pcapng]\noutput: out.pcapng\n", `{"inputs":["a.pcapng","b.pcapng"],"output":"out.pcapng"}`},
+		{"inputs:\n- a.pcapng\n- 'b c.pcapng'\n", `{"inputs":["a.pcapng","b c.pcapng"]}`},
+		{"steps:\n  - name: slice\n    args: [\"-s\", 64]  # snap\n", `{"steps":[{"args":["-s","64"],"name":"slice"}]}`},
+		{"---\nkey: ~\n...\nignored: x\n", `{"key":null}`},
+		{"", ""},
+		{": x", ""},
+		{"- : x", ""},
+		{"a:\n  : x", ""},
+		{"a: 1\na: 2", ""},
+		{"a: &anchor x", ""},
+		{"a: [b", ""},
+		{"\ta: b", ""},
+		{"a: b\n  c: d", ""},
+	}
+
+	for _, tt := range tests {
+		got, err := yamlToJSON([]byte(tt.yaml))
+		if tt.json == "" {
+			if err == nil {
+				t.Errorf("%q: yamlToJSON = %s, want an error", tt.yaml, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tt.yaml, err)
+			continue
+		}
+		if string(got) != tt.json {
+			t.Errorf("%q: yamlToJSON = %s, want %s", tt.yaml, got, tt.json)
+		}
+	}
+}
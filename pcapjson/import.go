@@ -0,0 +1,163 @@
+package pcapjson
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/RajeshGottlieb/go/pcapng"
+)
+
+// ImportError
+type ImportError struct {
+	errorString string
+}
+
+func (ie *ImportError) Error() string {
+	return ie.errorString
+}
+
+// longest line Import accepts
+const maxLine = 64 << 20
+
+// maxPlaceholders bounds the interfaces Import makes up for packet records
+// without interface records, so a bad interface index cannot exhaust memory.
+const maxPlaceholders = 1024
+
+// Import reads records in the format written by Export and writes them to
+// pw, rebuilding the sections, interfaces and comments. The summary of
+// each packet is ignored, its data is what gets written.
+//
+// Input with only packet records, e.g. written with Options.PacketsOnly,
+// gets a section and nano second Ethernet interfaces as needed, up to
+// maxPlaceholders. In a section that declares its interfaces a packet on
+// any other is an error.
+func Import(r io.Reader, pw *pcapng.PcapngWriter) error {
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLine)
+
+	var tsresols []uint8 // of the interfaces of the current section
+	inSection := false
+	declared := false // the current section has interface records
+
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return &ImportError{fmt.Sprintf("line %v: %v", line, err)}
+		}
+
+		switch rec.Type {
+		case TypeSection:
+			shb := &pcapng.SectionBlock{Options: commentOptions(rec.Comments)}
+			if rec.Hardware != "" {
+				shb.Options = append(shb.Options, &pcapng.Shb_Hardware{Value: rec.Hardware})
+			}
+			if rec.OS != "" {
+				shb.Options = append(shb.Options, &pcapng.Shb_Os{Value: rec.OS})
+			}
+			if rec.UserAppl != "" {
+				shb.Options = append(shb.Options, &pcapng.Shb_Userappl{Value: rec.UserAppl})
+			}
+			if err := pw.Write(shb); err != nil {
+				return err
+			}
+			tsresols = nil
+			inSection = true
+			declared = false
+
+		case TypeInterface:
+			if !inSection {
+				if err := pw.Write(&pcapng.SectionBlock{}); err != nil {
+					return err
+				}
+				inSection = true
+			}
+			if int(rec.Interface) != len(tsresols) {
+				return &ImportError{fmt.Sprintf("line %v: interface %v follows %v interfaces", line, rec.Interface, len(tsresols))}
+			}
+			idb := &pcapng.InterfaceBlock{LinkType: rec.LinkType, SnapLen: rec.SnapLen, Options: commentOptions(rec.Comments)}
+			if rec.Name != "" {
+				idb.Options = append(idb.Options, &pcapng.If_Name{Value: rec.Name})
+			}
+			if rec.Description != "" {
+				idb.Options = append(idb.Options, &pcapng.If_Description{Value: rec.Description})
+			}
+			tsresol := uint8(pcapng.DefaultTsresol)
+			if rec.Tsresol != nil {
+				if !pcapng.ValidTsresol(*rec.Tsresol) {
+					return &ImportError{fmt.Sprintf("line %v: tsresol %v is out of range", line, *rec.Tsresol)}
+				}
+				tsresol = *rec.Tsresol
+			}
+			if tsresol != pcapng.DefaultTsresol {
+				idb.Options = append(idb.Options, &pcapng.If_Tsresol{Value: tsresol})
+			}
+			if err := pw.Write(idb); err != nil {
+				return err
+			}
+			tsresols = append(tsresols, tsresol)
+			declared = true
+
+		case TypePacket:
+			if rec.Timestamp == nil {
+				return &ImportError{fmt.Sprintf("line %v: packet without a timestamp", line)}
+			}
+			if !inSection {
+				if err := pw.Write(&pcapng.SectionBlock{}); err != nil {
+					return err
+				}
+				inSection = true
+			}
+			if int(rec.Interface) >= len(tsresols) && (declared || rec.Interface >= maxPlaceholders) {
+				return &ImportError{fmt.Sprintf("line %v: packet on interface %v, which was never declared", line, rec.Interface)}
+			}
+			// describe any interfaces the input left out
+			for int(rec.Interface) >= len(tsresols) {
+				idb := &pcapng.InterfaceBlock{LinkType: 1, Options: []pcapng.Option{&pcapng.If_Tsresol{Value: 9}}}
+				if err := pw.Write(idb); err != nil {
+					return err
+				}
+				tsresols = append(tsresols, 9)
+			}
+
+			high, low := pcapng.SplitTimestamp(*rec.Timestamp, tsresols[rec.Interface])
+			originalLength := rec.OriginalLength
+			if originalLength == 0 {
+				originalLength = uint32(len(rec.Data))
+			}
+			epb := &pcapng.EnhancedPacketBlock{
+				InterfaceID:          rec.Interface,
+				TimestampHigh:        high,
+				TimestampLow:         low,
+				CapturedPacketLength: uint32(len(rec.Data)),
+				OriginalPacketLength: originalLength,
+				PacketData:           rec.Data,
+				Options:              commentOptions(rec.Comments),
+			}
+			if rec.Flags != 0 {
+				epb.WithFlags(rec.Flags)
+			}
+			if err := pw.Write(epb); err != nil {
+				return err
+			}
+
+		default:
+			return &ImportError{fmt.Sprintf("line %v: unknown record type %q", line, rec.Type)}
+		}
+	}
+	return scanner.Err()
+}
+
+func commentOptions(comments []string) []pcapng.Option {
+	var options []pcapng.Option
+	for _, c := range comments {
+		options = append(options, &pcapng.Opt_Comment{Value: c})
+	}
+	return options
+}
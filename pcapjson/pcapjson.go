@@ -0,0 +1,254 @@
+// Package pcapjson converts pcapng files to newline delimited JSON, one
+// object per line, for loading captures into JSON based pipelines.
+package pcapjson
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/RajeshGottlieb/go/geoip"
+	"github.com/RajeshGottlieb/go/oui"
+	"github.com/RajeshGottlieb/go/packet"
+	"github.com/RajeshGottlieb/go/pcapng"
+)
+
+// Record types
+const (
+	TypeSection   = "section"
+	TypeInterface = "interface"
+	TypePacket    = "packet"
+)
+
+// Record is one line of the output. Type says which of the fields are used.
+type Record struct {
+	Type     string   `json:"type"`
+	Section  int      `json:"section"` // 0 based
+	Comments []string `json:"comments,omitempty"`
+
+	// section
+	Hardware string `json:"hardware,omitempty"`
+	OS       string `json:"os,omitempty"`
+	UserAppl string `json:"userappl,omitempty"`
+
+	// interface
+	LinkType    uint16 `json:"link_type,omitempty"`
+	SnapLen     uint32 `json:"snaplen,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	Tsresol     *uint8 `json:"tsresol,omitempty"` // if_tsresol, nil for the default
+
+	// interface and packet, 0 for sections
+	Interface uint32 `json:"interface"` // ID within the section
+
+	// packet
+	Number         int        `json:"number,omitempty"` // 1 based
+	Timestamp      *time.Time `json:"timestamp,omitempty"`
+	CapturedLength uint32     `json:"captured_length,omitempty"`
+	OriginalLength uint32     `json:"original_length,omitempty"`
+	Flags          uint32     `json:"flags,omitempty"`
+	Data           []byte     `json:"data,omitempty"` // base64
+	Summary        *Summary   `json:"summary,omitempty"`
+}
+
+// Summary holds the decoded L2 to L4 header fields of a packet. It is
+// written for convenience and ignored when importing.
+type Summary struct {
+	SrcMAC    string   `json:"src_mac,omitempty"`
+	DstMAC    string   `json:"dst_mac,omitempty"`
+	VLANs     []uint16 `json:"vlans,omitempty"`
+	EtherType uint16   `json:"ethertype,omitempty"`
+	IPVersion int      `json:"ip_version,omitempty"`
+	SrcIP     string   `json:"src_ip,omitempty"`
+	DstIP     string   `json:"dst_ip,omitempty"`
+	TTL       uint8    `json:"ttl,omitempty"`
+	Protocol  uint8    `json:"protocol,omitempty"`
+	SrcPort   uint16   `json:"src_port,omitempty"`
+	DstPort   uint16   `json:"dst_port,omitempty"`
+	TCPFlags  uint8    `json:"tcp_flags,omitempty"`
+	ICMPType  uint8    `json:"icmp_type,omitempty"`
+	ICMPCode  uint8    `json:"icmp_code,omitempty"`
+
+	// with Options.GeoIP, what the databases know of the addresses
+	SrcGeo *geoip.Info `json:"src_geo,omitempty"`
+	DstGeo *geoip.Info `json:"dst_geo,omitempty"`
+
+	// with Options.OUI, the vendors of the MAC addresses
+	SrcVendor string `json:"src_vendor,omitempty"`
+	DstVendor string `json:"dst_vendor,omitempty"`
+}
+
+// Options control Export.
+type Options struct {
+	PacketsOnly bool      // leave out the section and interface records
+	NoData      bool      // leave out the packet data
+	NoSummary   bool      // leave out the decoded header fields
+	GeoIP       geoip.Set // if set, the summary has the country and ASN of the addresses
+	OUI         *oui.DB   // if set, the summary has the vendors of the MAC addresses
+}
+
+func summarize(p *packet.Packet) *Summary {
+
+	s := &Summary{
+		VLANs:     p.VLANs,
+		EtherType: p.EtherType,
+		IPVersion: p.IPVersion,
+	}
+	if p.SrcMAC != nil {
+		s.SrcMAC = p.SrcMAC.String()
+		s.DstMAC = p.DstMAC.String()
+	}
+	if p.IPVersion != 0 {
+		s.SrcIP = p.SrcIP.String()
+		s.DstIP = p.DstIP.String()
+		s.TTL = p.TTL
+		s.Protocol = p.Protocol
+	}
+	if p.TransportOffset >= 0 {
+		switch p.Protocol {
+		case packet.ProtocolTCP:
+			s.SrcPort, s.DstPort, s.TCPFlags = p.SrcPort, p.DstPort, p.TCPFlags
+		case packet.ProtocolUDP:
+			s.SrcPort, s.DstPort = p.SrcPort, p.DstPort
+		case packet.ProtocolICMP, packet.ProtocolICMPv6:
+			s.ICMPType, s.ICMPCode = p.ICMPType, p.ICMPCode
+		}
+	}
+	return s
+}
+
+// locate adds what db knows of the addresses to the summary.
+func locate(s *Summary, p *packet.Packet, db geoip.Set) error {
+
+	if p.IPVersion == 0 {
+		return nil
+	}
+	src, err := db.Info(p.SrcIP)
+	if err != nil {
+		return err
+	}
+	dst, err := db.Info(p.DstIP)
+	if err != nil {
+		return err
+	}
+	if !src.Empty() {
+		s.SrcGeo = &src
+	}
+	if !dst.Empty() {
+		s.DstGeo = &dst
+	}
+	return nil
+}
+
+func comments(options []pcapng.Option) []string {
+	var c []string
+	for _, opt := range options {
+		if o, ok := opt.(*pcapng.Opt_Comment); ok {
+			c = append(c, o.Value)
+		}
+	}
+	return c
+}
+
+// Export writes every section, interface and packet of pr to w as one
+// JSON object per line.
+func Export(pr *pcapng.PcapngReader, w io.Writer, opts Options) error {
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+
+	var interfaces []*pcapng.InterfaceBlock
+	section, number := -1, 0
+
+	for {
+		block, err := pr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		var r *Record
+		switch b := block.(type) {
+		case *pcapng.SectionBlock:
+			section++
+			interfaces = nil
+			r = &Record{Type: TypeSection, Comments: comments(b.Options)}
+			for _, opt := range b.Options {
+				switch o := opt.(type) {
+				case *pcapng.Shb_Hardware:
+					r.Hardware = o.Value
+				case *pcapng.Shb_Os:
+					r.OS = o.Value
+				case *pcapng.Shb_Userappl:
+					r.UserAppl = o.Value
+				}
+			}
+		case *pcapng.InterfaceBlock:
+			r = &Record{
+				Type:      TypeInterface,
+				Interface: uint32(len(interfaces)),
+				LinkType:  b.LinkType,
+				SnapLen:   b.SnapLen,
+				Name:      b.Name(),
+				Comments:  comments(b.Options),
+			}
+			tsresol := b.Tsresol()
+			r.Tsresol = &tsresol
+			for _, opt := range b.Options {
+				if o, ok := opt.(*pcapng.If_Description); ok {
+					r.Description = o.Value
+				}
+			}
+			interfaces = append(interfaces, b)
+		case *pcapng.EnhancedPacketBlock:
+			number++
+			r = &Record{
+				Type:           TypePacket,
+				Interface:      b.InterfaceID,
+				Number:         number,
+				CapturedLength: b.CapturedPacketLength,
+				OriginalLength: b.OriginalPacketLength,
+				Comments:       comments(b.Options),
+			}
+			ts, linkType := pcapng.Timestamp(b.TimestampHigh, b.TimestampLow, pcapng.DefaultTsresol), uint16(0)
+			if int(b.InterfaceID) < len(interfaces) {
+				ts = interfaces[b.InterfaceID].PacketTime(b.TimestampHigh, b.TimestampLow)
+				linkType = interfaces[b.InterfaceID].LinkType
+			}
+			ts = ts.UTC()
+			r.Timestamp = &ts
+			for _, opt := range b.Options {
+				if o, ok := opt.(*pcapng.Epb_Flags); ok {
+					r.Flags = o.Value
+				}
+			}
+			if !opts.NoData {
+				r.Data = b.PacketData
+			}
+			if !opts.NoSummary {
+				p := packet.Decode(linkType, b.PacketData)
+				r.Summary = summarize(p)
+				if opts.OUI != nil && p.SrcMAC != nil {
+					r.Summary.SrcVendor = opts.OUI.Name(p.SrcMAC)
+					r.Summary.DstVendor = opts.OUI.Name(p.DstMAC)
+				}
+				if opts.GeoIP != nil {
+					if err := locate(r.Summary, p, opts.GeoIP); err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		if r == nil || (opts.PacketsOnly && r.Type != TypePacket) {
+			continue
+		}
+		r.Section = section
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
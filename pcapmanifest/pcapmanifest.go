@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"github.com/RajeshGottlieb/go/manifest"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+func main() {
+
+	algorithm := flag.String("algorithm", manifest.SHA256, "per packet digest, crc32 or sha256")
+	verify := flag.String("verify", "", "check the input against this manifest file")
+	embedded := flag.Bool("embedded", false, "check the input against the manifest it holds")
+	embed := flag.String("embed", "", "write a copy of the input with the manifest appended to this file")
+	pen := flag.Uint("pen", 0, "with -embed, Private Enterprise Number of the Custom Blocks")
+	sign := flag.String("sign", "", "with -embed, also sign the copy with the Ed25519 private key in this file")
+	signature := flag.String("signature", "", "check the signature of the input with the Ed25519 public key in this file")
+	genkey := flag.String("genkey", "", "write a new Ed25519 key pair to this file and this file with .pub appended")
+	flag.Parse()
+
+	if *genkey != "" {
+		pub, priv, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			panic(err)
+		}
+		if err := ioutil.WriteFile(*genkey, []byte(hex.EncodeToString(priv.Seed())+"\n"), 0600); err != nil {
+			panic(err)
+		}
+		if err := ioutil.WriteFile(*genkey+".pub", []byte(hex.EncodeToString(pub)+"\n"), 0644); err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	if flag.NArg() != 1 || (*embed != "" && *pen == 0) || (*sign != "" && *embed == "") {
+		fmt.Printf("usage: %v [-algorithm crc32|sha256] [-verify manifest.json | -embedded | -signature key.pub | -embed output.pcapng -pen n [-sign key]] <input-pcapng>\n", os.Args[0])
+		fmt.Printf("       %v -genkey key\n", os.Args[0])
+		return
+	}
+
+	fh, err := os.Open(flag.Arg(0))
+	if err != nil {
+		panic(err)
+	}
+	defer fh.Close()
+	r := bufio.NewReader(fh)
+
+	if *signature != "" {
+		key := readKey(*signature, ed25519.PublicKeySize)
+		if err := manifest.VerifySignature(r, ed25519.PublicKey(key)); err != nil {
+			fmt.Printf("%v: %v\n", flag.Arg(0), err)
+			os.Exit(1)
+		}
+		fmt.Printf("%v: signature ok\n", flag.Arg(0))
+		return
+	}
+
+	if *verify != "" || *embedded {
+		var m *manifest.Manifest
+		if *verify != "" {
+			mfh, err := os.Open(*verify)
+			if err != nil {
+				panic(err)
+			}
+			defer mfh.Close()
+			if m, err = manifest.ReadJSON(mfh); err != nil {
+				panic(err)
+			}
+		}
+		if err := manifest.Verify(r, m); err != nil {
+			fmt.Printf("%v: %v\n", flag.Arg(0), err)
+			os.Exit(1)
+		}
+		fmt.Printf("%v: ok\n", flag.Arg(0))
+		return
+	}
+
+	if *embed != "" {
+		wfh, err := os.Create(*embed)
+		if err != nil {
+			panic(err)
+		}
+		defer wfh.Close()
+		var key ed25519.PrivateKey
+		if *sign != "" {
+			key = ed25519.NewKeyFromSeed(readKey(*sign, ed25519.SeedSize))
+		}
+		bw := bufio.NewWriter(wfh)
+		_, err = manifest.Embed(bw, r, *algorithm, uint32(*pen), key)
+		if err == nil {
+			err = bw.Flush()
+		}
+		if err != nil {
+			// the problems only show once the input is copied, do not leave half a copy behind
+			wfh.Close()
+			os.Remove(*embed)
+			panic(err)
+		}
+		return
+	}
+
+	m, err := manifest.Build(r, *algorithm)
+	if err != nil {
+		panic(err)
+	}
+	if err := m.WriteJSON(os.Stdout); err != nil {
+		panic(err)
+	}
+}
+
+// readKey reads a hex encoded key of size bytes.
+func readKey(path string, size int) []byte {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		panic(err)
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		panic(err)
+	}
+	if len(key) != size {
+		panic(fmt.Sprintf("%v holds a key of %v bytes, expected %v", path, len(key), size))
+	}
+	return key
+}
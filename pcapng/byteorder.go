@@ -0,0 +1,57 @@
+package pcapng
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"unsafe"
+)
+
+// HostByteOrder returns the native byte order of the host, which writers
+// use unless told otherwise.
+func HostByteOrder() binary.ByteOrder {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}
+
+// OppositeByteOrder returns big endian for little endian and vice versa.
+func OppositeByteOrder(endian binary.ByteOrder) binary.ByteOrder {
+	if endian == binary.BigEndian {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}
+
+// ConvertByteOrder copies every block from pr to pw re-encoding each
+// section in endian, or in the opposite of the byte order it was read in if
+// endian is nil. Blocks the reader does not parse cannot be re-encoded and
+// cause an error.
+func ConvertByteOrder(pr *PcapngReader, pw *PcapngWriter, endian binary.ByteOrder) error {
+
+	for {
+		block, err := pr.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		switch b := block.(type) {
+		case *SectionBlock:
+			if endian != nil {
+				pw.Endian = endian
+			} else {
+				pw.Endian = OppositeByteOrder(pr.Endian)
+			}
+		case *GenericBlock:
+			return &PcapError{fmt.Sprintf("cannot change the byte order of block type 0x%08x", b.Type)}
+		}
+
+		if err := pw.Write(block.(Block)); err != nil {
+			return err
+		}
+	}
+}
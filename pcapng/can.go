@@ -0,0 +1,124 @@
+package pcapng
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// LINKTYPE_CAN_SOCKETCAN, CAN frames as Linux SocketCAN hands them over
+const LinkTypeCANSocketCAN = 227
+
+// flags in the CAN ID
+const (
+	CANFlagExtended = 0x80000000 // 29 bit ID
+	CANFlagRemote   = 0x40000000 // remote transmission request
+	CANFlagError    = 0x20000000 // error message frame
+)
+
+// CAN FD flags
+const (
+	CANFDFlagBRS = 0x01 // bit rate switch
+	CANFDFlagESI = 0x02 // error state indicator
+	CANFDFlagFDF = 0x04 // CAN FD frame
+	canXLFlagXLF = 0x80 // CAN XL frame, whose header differs
+)
+
+// CANFrame is a classic CAN or CAN FD frame.
+type CANFrame struct {
+	ID      uint32 // 11 or 29 bits, without the flags
+	Flags   uint32 // CANFlagExtended, CANFlagRemote, CANFlagError
+	FDFlags uint8  // CANFDFlagBRS, CANFDFlagESI, CANFDFlagFDF; 0 for classic CAN
+	DLC     uint8  // of a classic frame of 8 bytes, 9 to 15 if it says so, else 0
+	Data    []byte // up to 8 bytes, 64 for CAN FD
+}
+
+// canFDLengths are the data lengths a CAN FD frame can have.
+var canFDLengths = map[int]bool{0: true, 1: true, 2: true, 3: true, 4: true, 5: true, 6: true, 7: true, 8: true,
+	12: true, 16: true, 20: true, 24: true, 32: true, 48: true, 64: true}
+
+// Frame returns the frame as the packet data of a LINKTYPE_CAN_SOCKETCAN
+// interface, the CAN ID in network byte order.
+func (f *CANFrame) Frame() ([]byte, error) {
+
+	data := make([]byte, 8, 8+len(f.Data))
+	binary.BigEndian.PutUint32(data[0:4], f.ID|f.Flags)
+	data[4] = uint8(len(f.Data))
+	data[5] = f.FDFlags
+	if f.DLC > 8 && len(f.Data) == 8 && f.FDFlags == 0 {
+		data[7] = f.DLC
+	}
+	data = append(data, f.Data...)
+	return data, CheckCANFrame(data)
+}
+
+// ParseCANFrame parses the packet data of a LINKTYPE_CAN_SOCKETCAN
+// interface.
+func ParseCANFrame(data []byte) (*CANFrame, error) {
+
+	if err := CheckCANFrame(data); err != nil {
+		return nil, err
+	}
+	id := binary.BigEndian.Uint32(data[0:4])
+	f := &CANFrame{
+		ID:      id &^ (CANFlagExtended | CANFlagRemote | CANFlagError),
+		Flags:   id & (CANFlagExtended | CANFlagRemote | CANFlagError),
+		FDFlags: data[5],
+		Data:    data[8 : 8+int(data[4])],
+	}
+	if f.FDFlags == 0 && data[7] > 8 {
+		f.DLC = data[7]
+	}
+	return f, nil
+}
+
+// canFDMTU is the size of a Linux struct canfd_frame.
+const canFDMTU = 72
+
+// CheckCANFrame checks that data is a well formed SocketCAN frame: a
+// header whose length fits in the data, an 11 bit ID unless the extended
+// flag is set, and a length CAN or CAN FD allows. Linux captures pad the
+// data to the 16 byte CAN_MTU or the 72 byte CANFD_MTU, which is allowed.
+func CheckCANFrame(data []byte) error {
+
+	if len(data) < 8 {
+		return &PcapError{fmt.Sprintf("CAN frame of %v bytes is shorter than its header", len(data))}
+	}
+	if data[5]&canXLFlagXLF != 0 {
+		return nil // CAN XL is framed differently
+	}
+
+	id := binary.BigEndian.Uint32(data[0:4])
+	if id&CANFlagExtended == 0 && id&0x1ffff800 != 0 {
+		return &PcapError{fmt.Sprintf("CAN ID %#x does not fit in 11 bits without the extended flag", id&0x1fffffff)}
+	}
+
+	length := int(data[4])
+	// older kernels leave the FDF flag clear, the CANFD_MTU tells
+	fd := data[5]&CANFDFlagFDF != 0 || len(data) == canFDMTU
+	switch {
+	case fd && !canFDLengths[length]:
+		return &PcapError{fmt.Sprintf("CAN FD frame cannot carry %v bytes", length)}
+	case !fd && length > 8:
+		return &PcapError{fmt.Sprintf("classic CAN frame cannot carry %v bytes", length)}
+	case len(data) < 8+length:
+		return &PcapError{fmt.Sprintf("CAN frame says %v data bytes but has %v", length, len(data)-8)}
+	}
+	return nil
+}
+
+// checkPacket checks the data of a packet written to an interface of a
+// link type with a framing the writer knows.
+func (pw *PcapngWriter) checkPacket(b *EnhancedPacketBlock) error {
+
+	id := int(b.InterfaceID)
+	if id >= len(pw.interfaces) || pw.interfaces[id].LinkType != LinkTypeCANSocketCAN {
+		return nil
+	}
+	if b.CapturedPacketLength < b.OriginalPacketLength {
+		return nil // cut short, its length cannot match
+	}
+	if err := CheckCANFrame(b.PacketData); err != nil {
+		return &PcapError{fmt.Sprintf("packet on CAN interface %v: %v", id, err)}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package pcapng
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCheckCANFrame(t *testing.T) {
+
+	frame := func(id []byte, length, flags byte, size int) []byte {
+		data := make([]byte, size)
+		copy(data, id)
+		data[4], data[5] = length, flags
+		return data
+	}
+	std := []byte{0, 0, 0x01, 0x23}
+	ext := []byte{0x81, 0x23, 0x45, 0x67}
+
+	tests := []struct {
+		name string
+		data []byte
+		ok   bool
+	}{
+		{"empty", nil, false},
+		{"short header", make([]byte, 7), false},
+		{"classic", frame(std, 3, 0, 11), true},
+		{"classic padded", frame(std, 3, 0, 16), true},
+		{"classic too long", frame(std, 9, 0, 17), false},
+		{"data cut short", frame(std, 8, 0, 12), false},
+		{"11 bit overflow", frame([]byte{0, 0, 0x08, 0}, 0, 0, 8), false},
+		{"extended", frame(ext, 8, 0, 16), true},
+		{"FD", frame(ext, 12, CANFDFlagFDF, 20), true},
+		{"FD bad length", frame(ext, 13, CANFDFlagFDF, 21), false},
+		{"FD by MTU", frame(std, 64, 0, canFDMTU), true},
+		{"XL", frame(std, 0xff, canXLFlagXLF, 8), true},
+	}
+
+	for _, tt := range tests {
+		if err := CheckCANFrame(tt.data); (err == nil) != tt.ok {
+			t.Errorf("%v: CheckCANFrame = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestCANFrameRoundTrip(t *testing.T) {
+
+	tests := []CANFrame{
+		{ID: 0x123, Data: []byte{1, 2, 3}},
+		{ID: 0x123, Flags: CANFlagRemote},
+		{ID: 0x1234567, Flags: CANFlagExtended, FDFlags: CANFDFlagFDF | CANFDFlagBRS, Data: make([]byte, 12)},
+		{ID: 0x7ff, DLC: 12, Data: []byte{1, 2, 3, 4, 5, 6, 7, 8}},
+	}
+
+	for _, f := range tests {
+		data, err := f.Frame()
+		if err != nil {
+			t.Errorf("%+v: Frame: %v", f, err)
+			continue
+		}
+		g, err := ParseCANFrame(data)
+		if err != nil {
+			t.Errorf("%+v: ParseCANFrame: %v", f, err)
+			continue
+		}
+		if g.ID != f.ID || g.Flags != f.Flags || g.FDFlags != f.FDFlags || g.DLC != f.DLC || !bytes.Equal(g.Data, f.Data) {
+			t.Errorf("ParseCANFrame = %+v, want %+v", g, f)
+		}
+	}
+}
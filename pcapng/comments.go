@@ -0,0 +1,85 @@
+package pcapng
+
+import (
+	"io"
+)
+
+// BlockOptions returns the options of a block so they can be read or
+// edited, or nil for blocks without options.
+func BlockOptions(b interface{}) *[]Option {
+	switch b := b.(type) {
+	case *SectionBlock:
+		return &b.Options
+	case *InterfaceBlock:
+		return &b.Options
+	case *InterfaceStatisticsBlock:
+		return &b.Options
+	case *EnhancedPacketBlock:
+		return &b.Options
+	case *NameResolutionBlock:
+		return &b.Options
+	case *DecryptionSecretsBlock:
+		return &b.Options
+	}
+	return nil
+}
+
+// Comment is an opt_comment found by Comments.
+type Comment struct {
+	Offset    int64  // of the block in the file
+	Section   int    // 0 based
+	Block     int    // 0 based index of the block in the file
+	BlockType uint32 // SECTION_HEADER_BLOCK, INTERFACE_DESCRIPTION_BLOCK, ...
+	Interface int    // 0 based interface within the section, -1 for blocks not tied to one
+	Packet    int    // 1 based packet number for packet blocks, otherwise 0
+	Text      string
+}
+
+// Comments lists every opt_comment in the file in file order.
+func Comments(r io.Reader) ([]Comment, error) {
+
+	pr := Reader(r)
+
+	var comments []Comment
+	interfaces := 0
+
+	for {
+		b, err := pr.Read()
+		if err == io.EOF {
+			return comments, nil
+		} else if err != nil {
+			return comments, err
+		}
+
+		m := pr.Metadata()
+		c := Comment{Offset: m.Offset, Section: m.Section, Block: m.Block, Interface: -1, Packet: m.Packet}
+		switch b := b.(type) {
+		case *SectionBlock:
+			interfaces = 0
+			c.BlockType = SECTION_HEADER_BLOCK
+		case *InterfaceBlock:
+			c.BlockType = INTERFACE_DESCRIPTION_BLOCK
+			c.Interface = interfaces
+			interfaces++
+		case *InterfaceStatisticsBlock:
+			c.BlockType = INTERFACE_STATISTICS_BLOCK
+			c.Interface = int(b.InterfaceID)
+		case *EnhancedPacketBlock:
+			c.BlockType = ENHANCED_PACKET_BLOCK
+			c.Interface = int(b.InterfaceID)
+		case *NameResolutionBlock:
+			c.BlockType = NAME_RESOLUTION_BLOCK
+		case *DecryptionSecretsBlock:
+			c.BlockType = DECRYPTION_SECRETS_BLOCK
+		}
+
+		if options := BlockOptions(b); options != nil {
+			for _, opt := range *options {
+				if comment, ok := opt.(*Opt_Comment); ok {
+					c.Text = comment.Value
+					comments = append(comments, c)
+				}
+			}
+		}
+	}
+}
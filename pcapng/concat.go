@@ -0,0 +1,70 @@
+package pcapng
+
+import (
+	"fmt"
+	"io"
+)
+
+// Concat copies every input to pw one after the other. Each input section
+// stays a separate section unless merge is set, in which case everything
+// goes into one section under the first Section Header Block: interfaces
+// are renumbered in the order they are seen and the interface IDs of
+// packets and statistics are remapped to match.
+func Concat(pw *PcapngWriter, inputs []*PcapngReader, merge bool) error {
+
+	var mapping []uint32 // output interface ID of each interface of the current input section
+	next := uint32(0)    // next output interface ID when merging
+	wroteSection := false
+
+	for n, pr := range inputs {
+		for {
+			block, err := pr.Read()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return err
+			}
+
+			switch b := block.(type) {
+			case *SectionBlock:
+				mapping = nil
+				if merge && wroteSection {
+					continue
+				}
+				wroteSection = true
+			case *InterfaceBlock:
+				if merge {
+					mapping = append(mapping, next)
+					next++
+				} else {
+					mapping = append(mapping, uint32(len(mapping)))
+				}
+			case *EnhancedPacketBlock:
+				if b.InterfaceID, err = remap(mapping, b.InterfaceID, n); err != nil {
+					return err
+				}
+			case *InterfaceStatisticsBlock:
+				if b.InterfaceID, err = remap(mapping, b.InterfaceID, n); err != nil {
+					return err
+				}
+			case *GenericBlock:
+				// a Simple Packet Block implicitly belongs to interface 0
+				if merge && b.Type == SIMPLE_PACKET_BLOCK && len(mapping) > 0 && mapping[0] != 0 {
+					return &PcapError{fmt.Sprintf("input %v has Simple Packet Blocks, which cannot be moved to another interface", n)}
+				}
+			}
+
+			if err := pw.Write(block.(Block)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func remap(mapping []uint32, id uint32, input int) (uint32, error) {
+	if int(id) >= len(mapping) {
+		return 0, &PcapError{fmt.Sprintf("input %v references interface %v before describing it", input, id)}
+	}
+	return mapping[id], nil
+}
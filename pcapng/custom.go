@@ -0,0 +1,53 @@
+package pcapng
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// CustomBlock is a Custom Block. What follows the Private Enterprise
+// Number is defined by the organization owning it, so the reader leaves
+// the block as a GenericBlock for ParseCustomBlock.
+type CustomBlock struct {
+	Type        uint32 // CUSTOM_BLOCK, or CUSTOM_BLOCK_NOCOPY if tools changing a file must drop it
+	TotalLength uint32
+	PEN         uint32 // Private Enterprise Number
+	Data        []byte // custom data and options, padded to 32 bits when packed
+}
+
+func (b *CustomBlock) Pack(endian binary.ByteOrder) ([]byte, error) {
+
+	blockType := b.Type
+	if blockType == 0 {
+		blockType = CUSTOM_BLOCK
+	}
+
+	padding := (4 - (len(b.Data) & 3)) & 3
+	blockTotalLength := uint32(16 + len(b.Data) + padding)
+
+	buf := make([]byte, blockTotalLength)
+	endian.PutUint32(buf[0:], blockType)        // Block Type
+	endian.PutUint32(buf[4:], blockTotalLength) // Block Total Length
+	endian.PutUint32(buf[8:], b.PEN)            // Private Enterprise Number
+	copy(buf[12:], b.Data)                      // Custom Data, padding is already zero
+	endian.PutUint32(buf[blockTotalLength-4:], blockTotalLength)
+	return buf, nil
+}
+
+// ParseCustomBlock splits a Custom Block read as a GenericBlock in the
+// byte order of its section. Data keeps the padding.
+func ParseCustomBlock(b *GenericBlock, endian binary.ByteOrder) (*CustomBlock, error) {
+
+	if b.Type != CUSTOM_BLOCK && b.Type != CUSTOM_BLOCK_NOCOPY {
+		return nil, &PcapError{fmt.Sprintf("block type 0x%08x is not a Custom Block", b.Type)}
+	}
+	if len(b.Data) < 16 {
+		return nil, &PcapError{fmt.Sprintf("Custom Block of %v bytes is too short", len(b.Data))}
+	}
+	return &CustomBlock{
+		Type:        b.Type,
+		TotalLength: b.TotalLength,
+		PEN:         endian.Uint32(b.Data[8:12]),
+		Data:        b.Data[12 : len(b.Data)-4],
+	}, nil
+}
@@ -0,0 +1,356 @@
+package pcapng
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Difference is one way two captures differ, found by Diff.
+type Difference struct {
+	Block   int   // 0 based index of the block, the same in both captures
+	OffsetA int64 // of the block in the first capture, -1 if it has no such block
+	OffsetB int64 // in the second capture
+	Message string
+}
+
+func (d Difference) String() string {
+	return fmt.Sprintf("block %v (offset %v / %v): %v", d.Block, d.OffsetA, d.OffsetB, d.Message)
+}
+
+// fields of option values that are numbers, so they can be compared across
+// byte orders
+var numericOptions = map[uint32]map[uint16][]int{
+	INTERFACE_DESCRIPTION_BLOCK: {
+		IF_SPEED: {8}, IF_TZONE: {4}, IF_TSOFFSET: {8}, IF_TXSPEED: {8}, IF_RXSPEED: {8},
+	},
+	ENHANCED_PACKET_BLOCK: {
+		EPB_FLAGS: {4}, EPB_DROPCOUNT: {8}, EPB_PACKETID: {8}, EPB_QUEUE: {4}, EPB_PROCESSID_THREADID: {4, 4},
+	},
+	INTERFACE_STATISTICS_BLOCK: {
+		ISB_STARTTIME: {4, 4}, ISB_ENDTIME: {4, 4}, ISB_IFRECV: {8}, ISB_IFDROP: {8},
+		ISB_FILTERACCEPT: {8}, ISB_OSDROP: {8}, ISB_USRDELIV: {8},
+	},
+}
+
+// diffField is a named fixed field of a block.
+type diffField struct {
+	name  string
+	value uint64
+}
+
+// diffBlock is a raw block taken apart for comparison.
+type diffBlock struct {
+	blockType uint32
+	offset    int64
+	fields    []diffField
+	data      []byte // packet data, secrets or the body of blocks without a known layout
+	records   []TLV  // of a Name Resolution Block
+	options   []TLV
+}
+
+// Diff reads two pcapng captures block by block and reports how they
+// differ: blocks missing from one of them, a different block type, fixed
+// fields, packet data and options, unknown options included. Byte order
+// and padding are not differences. Blocks are compared by position, so a
+// block added early in one capture makes every later block differ. Diff
+// stops after max differences, or never if max is 0.
+func Diff(a, b io.Reader, max int) ([]Difference, error) {
+
+	pa, pb := Reader(a), Reader(b)
+
+	var diffs []Difference
+	for block := 0; max == 0 || len(diffs) < max; block++ {
+
+		ba, err := nextDiffBlock(pa)
+		if err != nil && err != io.EOF {
+			return diffs, err
+		}
+		bb, err := nextDiffBlock(pb)
+		if err != nil && err != io.EOF {
+			return diffs, err
+		}
+
+		switch {
+		case ba == nil && bb == nil:
+			return diffs, nil
+		case bb == nil:
+			diffs = append(diffs, Difference{block, ba.offset, -1, fmt.Sprintf("%v is missing from the second capture", blockName(ba.blockType))})
+			continue
+		case ba == nil:
+			diffs = append(diffs, Difference{block, -1, bb.offset, fmt.Sprintf("%v is missing from the first capture", blockName(bb.blockType))})
+			continue
+		}
+
+		for _, msg := range compareBlocks(ba, bb) {
+			diffs = append(diffs, Difference{block, ba.offset, bb.offset, msg})
+		}
+	}
+	if max > 0 && len(diffs) > max {
+		diffs = diffs[:max]
+	}
+	return diffs, nil
+}
+
+// compareBlocks returns the differences between two blocks.
+func compareBlocks(a, b *diffBlock) []string {
+
+	if a.blockType != b.blockType {
+		return []string{fmt.Sprintf("%v in the first capture, %v in the second", blockName(a.blockType), blockName(b.blockType))}
+	}
+
+	var msgs []string
+	for i := range a.fields {
+		if a.fields[i].value != b.fields[i].value {
+			msgs = append(msgs, fmt.Sprintf("%v is %v, was %v", a.fields[i].name, b.fields[i].value, a.fields[i].value))
+		}
+	}
+	if !bytes.Equal(a.data, b.data) {
+		msgs = append(msgs, dataDiff(a.data, b.data))
+	}
+	msgs = append(msgs, compareTlvs("record", a.records, b.records, nil)...)
+	msgs = append(msgs, compareTlvs("option", a.options, b.options, func(code uint16) string {
+		return optionName(a.blockType, code)
+	})...)
+	return msgs
+}
+
+// dataDiff describes where two byte strings differ.
+func dataDiff(a, b []byte) string {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	if len(a) != len(b) {
+		return fmt.Sprintf("data is %v bytes, was %v, first difference at byte %v", len(b), len(a), i)
+	}
+	return fmt.Sprintf("data differs from byte %v", i)
+}
+
+// compareTlvs compares options or records by type, the n'th of each type
+// in one block with the n'th of the same type in the other.
+func compareTlvs(kind string, a, b []TLV, name func(code uint16) string) []string {
+
+	byType := func(tlvs []TLV) (map[uint16][]TLV, []uint16) {
+		m := make(map[uint16][]TLV)
+		var order []uint16
+		for _, tlv := range tlvs {
+			if _, ok := m[tlv.Type]; !ok {
+				order = append(order, tlv.Type)
+			}
+			m[tlv.Type] = append(m[tlv.Type], tlv)
+		}
+		return m, order
+	}
+	ma, order := byType(a)
+	mb, orderB := byType(b)
+	for _, t := range orderB {
+		if _, ok := ma[t]; !ok {
+			order = append(order, t)
+		}
+	}
+
+	var msgs []string
+	for _, t := range order {
+		label := fmt.Sprintf("%v %v", kind, t)
+		if name != nil {
+			label = name(t)
+		}
+		as, bs := ma[t], mb[t]
+		for i := 0; i < len(as) || i < len(bs); i++ {
+			switch {
+			case i >= len(bs):
+				msgs = append(msgs, fmt.Sprintf("%v %x removed", label, as[i].Value))
+			case i >= len(as):
+				msgs = append(msgs, fmt.Sprintf("%v %x added", label, bs[i].Value))
+			case !bytes.Equal(as[i].Value, bs[i].Value):
+				msgs = append(msgs, fmt.Sprintf("%v is %x, was %x", label, bs[i].Value, as[i].Value))
+			}
+		}
+	}
+	return msgs
+}
+
+// optionName returns the name of an option code of a block type.
+func optionName(blockType uint32, code uint16) string {
+	if rule, ok := blockOptions[blockType][code]; ok {
+		return rule.name
+	}
+	if rule, ok := commonOptions[code]; ok {
+		return rule.name
+	}
+	return fmt.Sprintf("option %v", code)
+}
+
+// blockName returns the name of a block type.
+func blockName(blockType uint32) string {
+	switch blockType {
+	case SECTION_HEADER_BLOCK:
+		return "Section Header Block"
+	case INTERFACE_DESCRIPTION_BLOCK:
+		return "Interface Description Block"
+	case ENHANCED_PACKET_BLOCK:
+		return "Enhanced Packet Block"
+	case SIMPLE_PACKET_BLOCK:
+		return "Simple Packet Block"
+	case OBSOLETE_PACKET_BLOCK:
+		return "Packet Block"
+	case INTERFACE_STATISTICS_BLOCK:
+		return "Interface Statistics Block"
+	case NAME_RESOLUTION_BLOCK:
+		return "Name Resolution Block"
+	case DECRYPTION_SECRETS_BLOCK:
+		return "Decryption Secrets Block"
+	case CUSTOM_BLOCK, CUSTOM_BLOCK_NOCOPY:
+		return "Custom Block"
+	}
+	return fmt.Sprintf("block type %#x", blockType)
+}
+
+// nextDiffBlock reads the next block raw and takes it apart, returning nil
+// at the end of the capture.
+func nextDiffBlock(pr *PcapngReader) (*diffBlock, error) {
+
+	raw, err := pr.ReadRaw()
+	if err != nil {
+		return nil, err
+	}
+	e := pr.Endian
+	buf := raw.Data[:len(raw.Data)-4] // without the trailing length
+	db := &diffBlock{blockType: raw.Type, offset: pr.Metadata().Offset}
+
+	u16 := func(name string, off int) {
+		db.fields = append(db.fields, diffField{name, uint64(e.Uint16(buf[off:]))})
+	}
+	u32 := func(name string, off int) {
+		db.fields = append(db.fields, diffField{name, uint64(e.Uint32(buf[off:]))})
+	}
+	padded := func(n int) int { return (n + 3) &^ 3 }
+
+	short := &PcapError{fmt.Sprintf("%v at offset %v is too short", blockName(raw.Type), db.offset)}
+	var rest []byte
+	switch raw.Type {
+	case SECTION_HEADER_BLOCK:
+		if len(buf) < 24 {
+			return nil, short
+		}
+		u16("major version", 12)
+		u16("minor version", 14)
+		db.fields = append(db.fields, diffField{"section length", e.Uint64(buf[16:])})
+		rest = buf[24:]
+	case INTERFACE_DESCRIPTION_BLOCK:
+		if len(buf) < 16 {
+			return nil, short
+		}
+		u16("link type", 8)
+		u32("snap length", 12)
+		rest = buf[16:]
+	case ENHANCED_PACKET_BLOCK:
+		if len(buf) < 28 || len(buf) < 28+int(e.Uint32(buf[20:])) {
+			return nil, short
+		}
+		u32("interface", 8)
+		u32("timestamp high", 12)
+		u32("timestamp low", 16)
+		u32("captured length", 20)
+		u32("original length", 24)
+		n := int(e.Uint32(buf[20:]))
+		db.data = buf[28 : 28+n]
+		if 28+padded(n) < len(buf) {
+			rest = buf[28+padded(n):]
+		}
+	case INTERFACE_STATISTICS_BLOCK:
+		if len(buf) < 20 {
+			return nil, short
+		}
+		u32("interface", 8)
+		u32("timestamp high", 12)
+		u32("timestamp low", 16)
+		rest = buf[20:]
+	case DECRYPTION_SECRETS_BLOCK:
+		if len(buf) < 16 || len(buf) < 16+int(e.Uint32(buf[12:])) {
+			return nil, short
+		}
+		u32("secrets type", 8)
+		n := int(e.Uint32(buf[12:]))
+		db.data = buf[16 : 16+n]
+		if 16+padded(n) < len(buf) {
+			rest = buf[16+padded(n):]
+		}
+	case NAME_RESOLUTION_BLOCK:
+		if db.records, rest, err = splitTlvs(buf[8:], e); err != nil {
+			return nil, err
+		}
+	case SIMPLE_PACKET_BLOCK:
+		if len(buf) < 12 {
+			return nil, short
+		}
+		u32("original length", 8)
+		db.data = buf[12:]
+	default:
+		db.data = buf[8:]
+	}
+
+	if len(rest) > 0 {
+		if db.options, _, err = splitTlvs(rest, e); err != nil {
+			return nil, err
+		}
+		for i := range db.options {
+			db.options[i].Value = littleEndian(raw.Type, db.options[i], e)
+		}
+	}
+	return db, nil
+}
+
+// splitTlvs splits options or records up to opt_endofopt, returning what
+// follows it.
+func splitTlvs(buf []byte, endian binary.ByteOrder) ([]TLV, []byte, error) {
+
+	var tlvs []TLV
+	for len(buf) >= 4 {
+		tlv := TLV{Type: endian.Uint16(buf[0:]), Length: endian.Uint16(buf[2:])}
+		buf = buf[4:]
+		if tlv.Type == 0 {
+			return tlvs, buf, nil
+		}
+		n := int(tlv.Length)
+		if n > len(buf) {
+			return nil, nil, &PcapError{fmt.Sprintf("option %v of length %v runs past the end of its block", tlv.Type, n)}
+		}
+		tlv.Value = buf[:n]
+		if p := (n + 3) &^ 3; p < len(buf) {
+			buf = buf[p:]
+		} else {
+			buf = nil
+		}
+		tlvs = append(tlvs, tlv)
+	}
+	return tlvs, buf, nil
+}
+
+// littleEndian returns the value of a numeric option in little endian
+// order, so it compares equal with the same value from a big endian section.
+func littleEndian(blockType uint32, tlv TLV, endian binary.ByteOrder) []byte {
+
+	sizes, ok := numericOptions[blockType][tlv.Type]
+	if !ok || endian == binary.LittleEndian {
+		return tlv.Value
+	}
+	total := 0
+	for _, n := range sizes {
+		total += n
+	}
+	if total != len(tlv.Value) {
+		return tlv.Value
+	}
+
+	value := make([]byte, 0, total)
+	for b := tlv.Value; len(sizes) > 0; sizes = sizes[1:] {
+		for i := sizes[0] - 1; i >= 0; i-- {
+			value = append(value, b[i])
+		}
+		b = b[sizes[0]:]
+	}
+	return value
+}
@@ -0,0 +1,88 @@
+package pcapng
+
+import (
+	"encoding/binary"
+)
+
+// DraftVersion is the pcapng draft the options in this file follow. They
+// are newer than the rest of the package and may change with the draft.
+const DraftVersion = "draft-ietf-opsawg-pcapng-03"
+
+// epb_verdict types
+const (
+	VerdictHardware = 0 // hardware or firmware specific
+	VerdictTC       = 1 // Linux eBPF TC_ACT_ return code
+	VerdictXDP      = 2 // Linux eBPF XDP_ return code
+)
+
+// Epb_Verdict is the verdict of a filter on the packet. A packet can have
+// one verdict per filter.
+type Epb_Verdict struct {
+	Type  uint8 // VerdictHardware, VerdictTC or VerdictXDP
+	Value []byte
+}
+
+func (opt *Epb_Verdict) Pack(endian binary.ByteOrder) ([]byte, error) {
+	return packTlv(EPB_VERDICT, append([]byte{opt.Type}, opt.Value...), endian)
+}
+
+func (opt *Epb_Verdict) Code() uint16 {
+	return EPB_VERDICT
+}
+
+// Return returns the eBPF return code of a VerdictTC or VerdictXDP
+// verdict, false for other verdicts.
+func (opt *Epb_Verdict) Return(endian binary.ByteOrder) (uint64, bool) {
+	if (opt.Type != VerdictTC && opt.Type != VerdictXDP) || len(opt.Value) != 8 {
+		return 0, false
+	}
+	return endian.Uint64(opt.Value), true
+}
+
+// Epb_Processid_Threadid is the process and thread that sent or received
+// the packet.
+type Epb_Processid_Threadid struct {
+	ProcessID uint32
+	ThreadID  uint32
+}
+
+func (opt *Epb_Processid_Threadid) Pack(endian binary.ByteOrder) ([]byte, error) {
+	buf := make([]byte, 8)
+	endian.PutUint32(buf[0:4], opt.ProcessID)
+	endian.PutUint32(buf[4:8], opt.ThreadID)
+	return packTlv(EPB_PROCESSID_THREADID, buf, endian)
+}
+
+func (opt *Epb_Processid_Threadid) Code() uint16 {
+	return EPB_PROCESSID_THREADID
+}
+
+// WithVerdict adds an epb_verdict option.
+func (b *EnhancedPacketBlock) WithVerdict(verdictType uint8, value []byte) *EnhancedPacketBlock {
+	b.Options = append(b.Options, &Epb_Verdict{verdictType, value})
+	return b
+}
+
+// WithProcess sets the epb_processid_threadid option.
+func (b *EnhancedPacketBlock) WithProcess(processID uint32, threadID uint32) *EnhancedPacketBlock {
+	return b.setOption(&Epb_Processid_Threadid{processID, threadID})
+}
+
+// packetOption parses the EPB options of DraftVersion.
+// Custom Options go to customOption, other options it does not know or
+// that have a bad length give nil.
+func packetOption(tlv TLV, endian binary.ByteOrder) Option {
+
+	v := tlv.Value
+	switch tlv.Type {
+	case EPB_VERDICT:
+		if len(v) >= 1 {
+			return &Epb_Verdict{v[0], append([]byte(nil), v[1:]...)}
+		}
+	case EPB_PROCESSID_THREADID:
+		if len(v) == 8 {
+			return &Epb_Processid_Threadid{endian.Uint32(v[0:4]), endian.Uint32(v[4:8])}
+		}
+	}
+	return customOption(tlv, endian)
+}
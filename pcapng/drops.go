@@ -0,0 +1,97 @@
+package pcapng
+
+import (
+	"fmt"
+	"time"
+)
+
+// dropCounter holds the drops reported for an interface.
+type dropCounter struct {
+	reported uint64 // total reported so far
+	emitted  uint64 // total written as epb_dropcount
+	pending  bool   // reported since the last Interface Statistics Block
+}
+
+// ReportDrops records the total number of packets the kernel or driver has
+// dropped on an interface of the current section since the capture began.
+// The next packet written for the interface carries the drops since the
+// previous one as epb_dropcount, and WriteDropStatistics writes the total
+// as isb_ifdrop.
+func (pw *PcapngWriter) ReportDrops(interfaceID uint32, total uint64) error {
+
+	if int(interfaceID) >= len(pw.interfaces) {
+		return &PcapError{fmt.Sprintf("drops reported for interface %v but only %v have been written", interfaceID, len(pw.interfaces))}
+	}
+	for len(pw.drops) < len(pw.interfaces) {
+		pw.drops = append(pw.drops, dropCounter{})
+	}
+
+	d := &pw.drops[interfaceID]
+	if total < d.reported {
+		return &PcapError{fmt.Sprintf("drop count of interface %v went back from %v to %v", interfaceID, d.reported, total)}
+	}
+	if pw.Metrics != nil {
+		pw.Metrics.AddDrops(total - d.reported)
+	}
+	d.reported = total
+	d.pending = true
+	return nil
+}
+
+// withDropCount returns a copy of the packet with the drops reported for
+// its interface since the previous packet, unless it has epb_dropcount
+// already.
+func (pw *PcapngWriter) withDropCount(b *EnhancedPacketBlock) *EnhancedPacketBlock {
+
+	id := int(b.InterfaceID)
+	if id >= len(pw.drops) || pw.drops[id].reported == pw.drops[id].emitted {
+		return b
+	}
+	for _, opt := range b.Options {
+		if opt.Code() == EPB_DROPCOUNT {
+			return b
+		}
+	}
+
+	d := &pw.drops[id]
+	epb := *b
+	epb.Options = append([]Option(nil), b.Options...)
+	epb.WithDropCount(d.reported - d.emitted)
+	d.emitted = d.reported
+	return &epb
+}
+
+// WriteDropStatistics writes an Interface Statistics Block with isb_ifdrop
+// for every interface of the current section with drops reported since the
+// last call, after any blocks CompressTimestamps holds back. Close and the
+// next Section Header Block call it.
+func (pw *PcapngWriter) WriteDropStatistics() error {
+
+	if err := pw.release(); err != nil {
+		return err
+	}
+	now := time.Now()
+	for id := range pw.drops {
+		d := &pw.drops[id]
+		if !d.pending {
+			continue
+		}
+
+		high, low := pw.interfaces[id].SplitPacketTime(now, pw.Rounding)
+		high, low, err := pw.relative(uint32(id), high, low)
+		if err != nil {
+			return err
+		}
+		isb := &InterfaceStatisticsBlock{
+			InterfaceID:   uint32(id),
+			TimestampHigh: high,
+			TimestampLow:  low,
+			Options:       []Option{&Isb_Ifdrop{d.reported}},
+		}
+		if err := pw.write(isb); err != nil {
+			return err
+		}
+		d.pending = false
+	}
+	return nil
+}
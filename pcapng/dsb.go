@@ -0,0 +1,189 @@
+package pcapng
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Decryption Secrets Block secrets types
+const (
+	SECRETS_TYPE_TLS        = 0x544c534b // TLS Key Log
+	SECRETS_TYPE_SSH        = 0x5353484b // SSH Key Log
+	SECRETS_TYPE_WIREGUARD  = 0x57474b4c // WireGuard Key Log
+	SECRETS_TYPE_ZIGBEE_NWK = 0x5a4e574b // ZigBee NWK Key
+	SECRETS_TYPE_ZIGBEE_APS = 0x5a415053 // ZigBee APS Key
+	SECRETS_TYPE_OPCUA      = 0x55414b4c // OPC UA Key Log
+)
+
+type DecryptionSecretsBlock struct {
+	Type          uint32
+	TotalLength   uint32
+	SecretsType   uint32
+	SecretsLength uint32
+	SecretsData   []byte
+	Options       []Option
+}
+
+func (b *DecryptionSecretsBlock) Pack(endian binary.ByteOrder) ([]byte, error) {
+
+	options, err := packOptions(b.Options, endian)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+
+	if err := binary.Write(buf, endian, uint32(DECRYPTION_SECRETS_BLOCK)); err != nil { // Block Type
+		return nil, err
+	}
+
+	padding := (4 - (len(b.SecretsData) & 3)) & 3
+	blockTotalLength := uint32(20 + len(b.SecretsData) + padding + len(options))
+
+	if err := binary.Write(buf, endian, blockTotalLength); err != nil { // Block Total Length
+		return nil, err
+	}
+	if err := binary.Write(buf, endian, b.SecretsType); err != nil { // Secrets Type
+		return nil, err
+	}
+	if err := binary.Write(buf, endian, uint32(len(b.SecretsData))); err != nil { // Secrets Length
+		return nil, err
+	}
+	if _, err := buf.Write(b.SecretsData); err != nil { // Secrets Data
+		return nil, err
+	}
+	for i := 0; i < padding; i++ {
+		if err := binary.Write(buf, endian, uint8(0)); err != nil { // padding
+			return nil, err
+		}
+	}
+	if _, err := buf.Write(options); err != nil { // options
+		return nil, err
+	}
+	if err := binary.Write(buf, endian, blockTotalLength); err != nil { // Block Total Length
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// TLSKeyLogEntry is one line of an NSS key log file, e.g.
+// CLIENT_RANDOM <client random> <master secret>
+type TLSKeyLogEntry struct {
+	Label        string
+	ClientRandom []byte
+	Secret       []byte
+}
+
+// TLSKeyLog parses the secrets of a SECRETS_TYPE_TLS block.
+// Comments and lines that don't parse are skipped.
+func (b *DecryptionSecretsBlock) TLSKeyLog() ([]TLSKeyLogEntry, error) {
+	if b.SecretsType != SECRETS_TYPE_TLS {
+		return nil, &PcapError{"not a TLS key log"}
+	}
+
+	var entries []TLSKeyLogEntry
+
+	scanner := bufio.NewScanner(bytes.NewReader(b.SecretsData))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		clientRandom, err := hex.DecodeString(fields[1])
+		if err != nil {
+			continue
+		}
+		secret, err := hex.DecodeString(fields[2])
+		if err != nil {
+			continue
+		}
+		entries = append(entries, TLSKeyLogEntry{fields[0], clientRandom, secret})
+	}
+	return entries, scanner.Err()
+}
+
+// WireGuardKeyLogEntry is one line of a WireGuard key log, e.g.
+// LOCAL_STATIC_PRIVATE_KEY = <base64 key>
+type WireGuardKeyLogEntry struct {
+	Label string
+	Key   []byte
+}
+
+// WireGuardKeyLog parses the secrets of a SECRETS_TYPE_WIREGUARD block.
+// Comments and lines that don't parse are skipped.
+func (b *DecryptionSecretsBlock) WireGuardKeyLog() ([]WireGuardKeyLogEntry, error) {
+	if b.SecretsType != SECRETS_TYPE_WIREGUARD {
+		return nil, &PcapError{"not a WireGuard key log"}
+	}
+
+	var entries []WireGuardKeyLogEntry
+
+	scanner := bufio.NewScanner(bytes.NewReader(b.SecretsData))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 || fields[1] != "=" {
+			continue
+		}
+		key, err := base64.StdEncoding.DecodeString(fields[2])
+		if err != nil || len(key) != 32 {
+			continue
+		}
+		entries = append(entries, WireGuardKeyLogEntry{fields[0], key})
+	}
+	return entries, scanner.Err()
+}
+
+// ZigBeeNWKKey is the network key of a SECRETS_TYPE_ZIGBEE_NWK block.
+type ZigBeeNWKKey struct {
+	Key   [16]byte // AES-128 key
+	PANID uint16
+}
+
+// ZigBeeNWKKey returns the key of a SECRETS_TYPE_ZIGBEE_NWK block.
+// The secrets are the key followed by the little endian PAN ID.
+func (b *DecryptionSecretsBlock) ZigBeeNWKKey() (*ZigBeeNWKKey, error) {
+	if b.SecretsType != SECRETS_TYPE_ZIGBEE_NWK {
+		return nil, &PcapError{"not a ZigBee NWK key"}
+	}
+	if len(b.SecretsData) < 18 {
+		return nil, &PcapError{fmt.Sprintf("ZigBee NWK key is %v bytes expected 18", len(b.SecretsData))}
+	}
+
+	var k ZigBeeNWKKey
+	copy(k.Key[:], b.SecretsData[0:16])
+	k.PANID = binary.LittleEndian.Uint16(b.SecretsData[16:18])
+	return &k, nil
+}
+
+// ZigBeeAPSKey is the link key between two nodes of a SECRETS_TYPE_ZIGBEE_APS block.
+type ZigBeeAPSKey struct {
+	Key      [16]byte // AES-128 key
+	PANID    uint16
+	AddressA uint16 // short address of the first node
+	AddressB uint16 // short address of the second node
+}
+
+// ZigBeeAPSKey returns the key of a SECRETS_TYPE_ZIGBEE_APS block.
+// The secrets are the key followed by the little endian PAN ID and the short
+// addresses of the two nodes.
+func (b *DecryptionSecretsBlock) ZigBeeAPSKey() (*ZigBeeAPSKey, error) {
+	if b.SecretsType != SECRETS_TYPE_ZIGBEE_APS {
+		return nil, &PcapError{"not a ZigBee APS key"}
+	}
+	if len(b.SecretsData) < 22 {
+		return nil, &PcapError{fmt.Sprintf("ZigBee APS key is %v bytes expected 22", len(b.SecretsData))}
+	}
+
+	var k ZigBeeAPSKey
+	copy(k.Key[:], b.SecretsData[0:16])
+	k.PANID = binary.LittleEndian.Uint16(b.SecretsData[16:18])
+	k.AddressA = binary.LittleEndian.Uint16(b.SecretsData[18:20])
+	k.AddressB = binary.LittleEndian.Uint16(b.SecretsData[20:22])
+	return &k, nil
+}
@@ -0,0 +1,58 @@
+package pcapng
+
+// The With methods add an option to an Enhanced Packet Block and return the
+// block, so they can be chained:
+//
+//	b.WithFlags(DirectionOutbound).WithQueue(2).WithComment("retransmission")
+//
+// Options that may appear only once replace any the block already has.
+
+// WithFlags sets the epb_flags option, e.g. the DirectionInbound or
+// DirectionOutbound bits.
+func (b *EnhancedPacketBlock) WithFlags(flags uint32) *EnhancedPacketBlock {
+	return b.setOption(&Epb_Flags{flags})
+}
+
+// WithDropCount sets the epb_dropcount option, the packets lost between
+// this packet and the previous one.
+func (b *EnhancedPacketBlock) WithDropCount(count uint64) *EnhancedPacketBlock {
+	return b.setOption(&Epb_Dropcount{count})
+}
+
+// WithPacketID sets the epb_packetid option, which identifies the packet
+// across the interfaces it was captured on.
+func (b *EnhancedPacketBlock) WithPacketID(id uint64) *EnhancedPacketBlock {
+	return b.setOption(&Epb_Packetid{id})
+}
+
+// WithQueue sets the epb_queue option, the interface queue the packet
+// was received on.
+func (b *EnhancedPacketBlock) WithQueue(queue uint32) *EnhancedPacketBlock {
+	return b.setOption(&Epb_Queue{queue})
+}
+
+// WithHash adds an epb_hash option. The value starts with the hash
+// algorithm byte, see PacketHash.
+func (b *EnhancedPacketBlock) WithHash(value []byte) *EnhancedPacketBlock {
+	b.Options = append(b.Options, &Epb_Hash{value})
+	return b
+}
+
+// WithComment adds an opt_comment option.
+func (b *EnhancedPacketBlock) WithComment(comment string) *EnhancedPacketBlock {
+	b.Options = append(b.Options, &Opt_Comment{comment})
+	return b
+}
+
+// setOption replaces the first option with the code of opt, or appends
+// opt if there is none.
+func (b *EnhancedPacketBlock) setOption(opt Option) *EnhancedPacketBlock {
+	for i, o := range b.Options {
+		if o.Code() == opt.Code() {
+			b.Options[i] = opt
+			return b
+		}
+	}
+	b.Options = append(b.Options, opt)
+	return b
+}
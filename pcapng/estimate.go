@@ -0,0 +1,133 @@
+package pcapng
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// EstimateOptions describes the output of a copy or conversion.
+type EstimateOptions struct {
+	Format  string  // "pcapng", the default, or "pcap"
+	SnapLen uint32  // if not 0, packets are truncated to this many bytes
+	Ratio   float64 // compressed size over uncompressed size, e.g. 0.4, 0 if not compressed
+	Sample  int     // if not 0, scan only this many blocks and extrapolate to the whole input
+}
+
+// SizeEstimate is the predicted size of the output.
+type SizeEstimate struct {
+	Packets    int64
+	Bytes      int64 // uncompressed
+	Compressed int64 // Bytes times the Ratio, or Bytes if there is none
+	Exact      bool  // every block was scanned, so an uncompressed Bytes is exact
+}
+
+// EstimateSize predicts the size of copying the first size bytes of r to
+// the output opts describes. Only the block headers are read. Blocks other
+// than packets are counted as they are for pcapng and dropped for pcap.
+func EstimateSize(r io.ReaderAt, size int64, opts EstimateOptions) (*SizeEstimate, error) {
+
+	pcap := false
+	switch opts.Format {
+	case "", "pcapng":
+	case "pcap":
+		pcap = true
+	default:
+		return nil, &PcapError{fmt.Sprintf("unknown output format %q", opts.Format)}
+	}
+
+	est := &SizeEstimate{Exact: true}
+	var endian binary.ByteOrder = binary.LittleEndian
+	hdr := make([]byte, 28)
+	blocks := 0
+
+	offset := int64(0)
+	for ; offset < size; blocks++ {
+		if opts.Sample > 0 && blocks == opts.Sample {
+			est.Exact = false
+			break
+		}
+
+		n := int64(len(hdr))
+		if size-offset < n {
+			n = size - offset
+		}
+		if n < 12 {
+			return nil, &PcapError{fmt.Sprintf("truncated block at offset %v", offset)}
+		}
+		if _, err := r.ReadAt(hdr[:n], offset); err != nil {
+			return nil, err
+		}
+
+		blockType := endian.Uint32(hdr[0:4])
+		if blockType == SECTION_HEADER_BLOCK {
+			switch binary.LittleEndian.Uint32(hdr[8:12]) {
+			case MagicNumber:
+				endian = binary.LittleEndian
+			case SwapMagicNumber:
+				endian = binary.BigEndian
+			default:
+				return nil, &PcapError{fmt.Sprintf("Bad Magic Number at offset %v", offset)}
+			}
+		}
+		totalLength := int64(endian.Uint32(hdr[4:8]))
+		if totalLength < 12 || totalLength&3 != 0 {
+			return nil, &PcapError{fmt.Sprintf("bad block length %v at offset %v", totalLength, offset)}
+		}
+
+		captured, header := int64(-1), int64(0)
+		switch blockType {
+		case ENHANCED_PACKET_BLOCK, OBSOLETE_PACKET_BLOCK:
+			// both keep the Captured Packet Length at the same place
+			if totalLength >= 32 && n >= 24 {
+				captured, header = int64(endian.Uint32(hdr[20:24])), 32
+			}
+		case SIMPLE_PACKET_BLOCK:
+			if totalLength >= 16 && n >= 12 {
+				captured, header = totalLength-16, 16
+				if original := int64(endian.Uint32(hdr[8:12])); original < captured {
+					captured = original
+				}
+			}
+		}
+
+		if captured < 0 {
+			if !pcap {
+				est.Bytes += totalLength
+			}
+		} else {
+			est.Packets++
+			kept := captured
+			if opts.SnapLen != 0 && kept > int64(opts.SnapLen) {
+				kept = int64(opts.SnapLen)
+			}
+			if pcap {
+				est.Bytes += 16 + kept
+			} else {
+				// the options are copied along
+				options := totalLength - header - int64(pad4(int(captured)))
+				if options < 0 {
+					options = 0
+				}
+				est.Bytes += header + int64(pad4(int(kept))) + options
+			}
+		}
+		offset += totalLength
+	}
+
+	if !est.Exact && offset > 0 {
+		// in floating point, as the products overflow for inputs of a few GiB
+		scale := float64(size) / float64(offset)
+		est.Packets = int64(float64(est.Packets) * scale)
+		est.Bytes = int64(float64(est.Bytes) * scale)
+	}
+	if pcap {
+		est.Bytes += 24 // the file header
+	}
+
+	est.Compressed = est.Bytes
+	if opts.Ratio > 0 {
+		est.Compressed = int64(float64(est.Bytes) * opts.Ratio)
+	}
+	return est, nil
+}
@@ -0,0 +1,83 @@
+package pcapng
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FidelityReport counts what a conversion to a less capable format, or a
+// lossy transform, dropped or altered. The zero value is an empty report.
+type FidelityReport struct {
+	Comments       int // opt_comment options removed
+	Options        int // other options removed
+	NameResolution int // Name Resolution Blocks discarded
+	Statistics     int // Interface Statistics Blocks discarded
+	OtherBlocks    int // other blocks discarded, e.g. Decryption Secrets and Custom Blocks
+	Sections       int // sections merged into the first
+	Interfaces     int // interfaces collapsed into the first
+	Packets        int // packets dropped, e.g. of an interface with another link type
+	Truncated      int // timestamps that lost digits
+	Notes          []string
+}
+
+// Notef adds a note on a specific loss, e.g. the interface whose packets
+// were dropped.
+func (r *FidelityReport) Notef(format string, a ...interface{}) {
+	r.Notes = append(r.Notes, fmt.Sprintf(format, a...))
+}
+
+// Lossless tells if nothing was dropped or altered.
+func (r *FidelityReport) Lossless() bool {
+	return r.Comments == 0 && r.Options == 0 && r.NameResolution == 0 && r.Statistics == 0 &&
+		r.OtherBlocks == 0 && r.Sections == 0 && r.Interfaces == 0 && r.Packets == 0 &&
+		r.Truncated == 0 && len(r.Notes) == 0
+}
+
+// String lists the losses one per line, or says there were none.
+func (r *FidelityReport) String() string {
+
+	if r.Lossless() {
+		return "nothing dropped or altered\n"
+	}
+
+	var sb strings.Builder
+	line := func(count int, what string) {
+		if count != 0 {
+			fmt.Fprintf(&sb, "%v %v\n", count, what)
+		}
+	}
+	line(r.Comments, "comments removed")
+	line(r.Options, "other options removed")
+	line(r.NameResolution, "Name Resolution Blocks discarded")
+	line(r.Statistics, "Interface Statistics Blocks discarded")
+	line(r.OtherBlocks, "other blocks discarded")
+	line(r.Sections, "sections merged into the first")
+	line(r.Interfaces, "interfaces collapsed into the first")
+	line(r.Packets, "packets dropped")
+	line(r.Truncated, "timestamps truncated")
+	for _, note := range r.Notes {
+		fmt.Fprintf(&sb, "%v\n", note)
+	}
+	return sb.String()
+}
+
+// CountOptions adds the options of a block that a conversion removes to
+// the report, skipping those keep accepts. keep may be nil.
+func (r *FidelityReport) CountOptions(options []Option, keep func(Option) bool) {
+	for _, opt := range options {
+		if keep != nil && keep(opt) {
+			continue
+		}
+		if _, ok := opt.(*Opt_Comment); ok {
+			r.Comments++
+		} else {
+			r.Options++
+		}
+	}
+}
+
+// LosesDigits tells if converting ticks from one if_tsresol to another
+// drops digits.
+func LosesDigits(ticks uint64, from, to uint8) bool {
+	return ConvertTicks(ConvertTicks(ticks, from, to, Truncate), to, from, Truncate) != ticks
+}
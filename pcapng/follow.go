@@ -0,0 +1,107 @@
+package pcapng
+
+import (
+	"io"
+	"os"
+	"time"
+)
+
+// notifier waits for a file to change.
+type notifier interface {
+	wait(timeout time.Duration)
+	close() error
+}
+
+// Follower reads a capture file that is still being written, e.g. by
+// dumpcap or tcpdump, like tail -f. At the end of the file it waits for
+// more to be written instead of returning io.EOF. On Linux it is woken by
+// inotify, elsewhere it polls.
+type Follower struct {
+	Poll time.Duration // how often to look for more data without a wakeup, 1s if 0
+	Idle time.Duration // io.EOF once the file has not grown for this long, never if 0
+
+	f      *os.File
+	notify notifier
+	last   time.Time // the file last grew
+	done   chan struct{}
+}
+
+// Follow opens a file to follow.
+func Follow(name string) (*Follower, error) {
+
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	fl := &Follower{f: f, last: time.Now(), done: make(chan struct{})}
+	if fl.notify, err = newNotifier(name, fl.done); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return fl, nil
+}
+
+// Read reads the file, waiting at its end until more is written, Idle
+// passes or the Follower is closed.
+func (fl *Follower) Read(b []byte) (int, error) {
+	for {
+		n, err := fl.f.Read(b)
+		if n > 0 {
+			fl.last = time.Now()
+		}
+		if n > 0 || err != io.EOF {
+			return n, err
+		}
+
+		select {
+		case <-fl.done:
+			return 0, io.EOF
+		default:
+		}
+
+		timeout := fl.Poll
+		if timeout <= 0 {
+			timeout = time.Second
+		}
+		if fl.Idle > 0 {
+			left := fl.Idle - time.Since(fl.last)
+			if left <= 0 {
+				return 0, io.EOF
+			}
+			if left < timeout {
+				timeout = left
+			}
+		}
+		fl.notify.wait(timeout)
+	}
+}
+
+// Close stops following, a Read waiting for more data returns io.EOF.
+func (fl *Follower) Close() error {
+	select {
+	case <-fl.done:
+		return nil
+	default:
+		close(fl.done)
+	}
+	fl.notify.close()
+	return fl.f.Close()
+}
+
+// poller waits by sleeping.
+type poller struct {
+	done chan struct{}
+}
+
+func (p *poller) wait(timeout time.Duration) {
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-p.done:
+	}
+}
+
+func (p *poller) close() error {
+	return nil
+}
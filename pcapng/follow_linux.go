@@ -0,0 +1,38 @@
+package pcapng
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// inotify waits for the file to be written or closed.
+type inotify struct {
+	f   *os.File
+	buf []byte
+}
+
+// newNotifier watches the file with inotify, falling back to polling if
+// that fails, e.g. because the watch limit is reached.
+func newNotifier(name string, done chan struct{}) (notifier, error) {
+
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return &poller{done}, nil
+	}
+	if _, err := syscall.InotifyAddWatch(fd, name, syscall.IN_MODIFY|syscall.IN_CLOSE_WRITE); err != nil {
+		syscall.Close(fd)
+		return &poller{done}, nil
+	}
+	// non-blocking, so reads go through the runtime poller and honour deadlines
+	return &inotify{f: os.NewFile(uintptr(fd), "inotify"), buf: make([]byte, 4096)}, nil
+}
+
+func (in *inotify) wait(timeout time.Duration) {
+	in.f.SetReadDeadline(time.Now().Add(timeout))
+	in.f.Read(in.buf) // the events themselves do not matter
+}
+
+func (in *inotify) close() error {
+	return in.f.Close()
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+// +build !linux
+
+package pcapng
+
+func newNotifier(name string, done chan struct{}) (notifier, error) {
+	return &poller{done}, nil
+}
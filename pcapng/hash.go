@@ -0,0 +1,91 @@
+package pcapng
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// epb_hash algorithms, the first byte of the option
+const (
+	HashTwosComplement = 0
+	HashXOR            = 1
+	HashCRC32          = 2
+	HashMD5            = 3
+	HashSHA1           = 4
+	HashToeplitz       = 5
+)
+
+// PacketHash returns the epb_hash value of data, starting with the
+// algorithm byte, or false for the algorithms whose exact definition the
+// specification leaves open: two's complement, XOR and Toeplitz. CRC32 is
+// written in big endian.
+func PacketHash(algorithm uint8, data []byte) ([]byte, bool) {
+	value := []byte{algorithm}
+	switch algorithm {
+	case HashCRC32:
+		value = append(value, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(value[1:], crc32.ChecksumIEEE(data))
+	case HashMD5:
+		sum := md5.Sum(data)
+		value = append(value, sum[:]...)
+	case HashSHA1:
+		sum := sha1.Sum(data)
+		value = append(value, sum[:]...)
+	default:
+		return nil, false
+	}
+	return value, true
+}
+
+// VerifyHashes recomputes every epb_hash of the packet that PacketHash
+// can compute over the captured data and returns an error for the first
+// that differs. A CRC32 in either byte order matches, as capture cards
+// disagree.
+func (b *EnhancedPacketBlock) VerifyHashes() error {
+	for _, opt := range b.Options {
+		h, ok := opt.(*Epb_Hash)
+		if !ok || len(h.Value) == 0 {
+			continue
+		}
+		want, ok := PacketHash(h.Value[0], b.PacketData)
+		if !ok || bytes.Equal(h.Value, want) {
+			continue
+		}
+		if h.Value[0] == HashCRC32 && len(h.Value) == 5 &&
+			binary.LittleEndian.Uint32(h.Value[1:]) == binary.BigEndian.Uint32(want[1:]) {
+			continue
+		}
+		return &PcapError{fmt.Sprintf("epb_hash %x does not match the packet, which hashes to %x", h.Value, want)}
+	}
+	return nil
+}
+
+// CheckHashes reads r and reports the packets whose epb_hash does not
+// match their data.
+func CheckHashes(r io.Reader) ([]Problem, error) {
+
+	pr := Reader(r)
+	pr.Keep = PacketBlocks
+
+	var problems []Problem
+	for {
+		block, err := pr.Read()
+		if err == io.EOF {
+			return problems, nil
+		} else if err != nil {
+			return problems, err
+		}
+
+		if b, ok := block.(*EnhancedPacketBlock); ok {
+			if err := b.VerifyHashes(); err != nil {
+				m := pr.Metadata()
+				problems = append(problems, Problem{m.Offset, ENHANCED_PACKET_BLOCK, false, fmt.Sprintf("packet %v: %v", m.Packet, err)})
+			}
+		}
+	}
+}
@@ -0,0 +1,348 @@
+package pcapng
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+type If_Description struct {
+	Value string
+}
+
+func (opt *If_Description) Pack(endian binary.ByteOrder) ([]byte, error) {
+	return packTlv(IF_DESCRIPTION, []byte(opt.Value), endian)
+}
+
+func (opt *If_Description) Code() uint16 {
+	return IF_DESCRIPTION
+}
+
+type If_IPv4addr struct {
+	Address [4]byte
+	Netmask [4]byte
+}
+
+func (opt *If_IPv4addr) Pack(endian binary.ByteOrder) ([]byte, error) {
+	return packTlv(IF_IPV4ADDR, append(opt.Address[:], opt.Netmask[:]...), endian)
+}
+
+func (opt *If_IPv4addr) Code() uint16 {
+	return IF_IPV4ADDR
+}
+
+type If_IPv6addr struct {
+	Address      [16]byte
+	PrefixLength uint8
+}
+
+func (opt *If_IPv6addr) Pack(endian binary.ByteOrder) ([]byte, error) {
+	return packTlv(IF_IPV6ADDR, append(opt.Address[:], opt.PrefixLength), endian)
+}
+
+func (opt *If_IPv6addr) Code() uint16 {
+	return IF_IPV6ADDR
+}
+
+type If_MACaddr struct {
+	Value [6]byte
+}
+
+func (opt *If_MACaddr) Pack(endian binary.ByteOrder) ([]byte, error) {
+	return packTlv(IF_MACADDR, opt.Value[:], endian)
+}
+
+func (opt *If_MACaddr) Code() uint16 {
+	return IF_MACADDR
+}
+
+type If_EUIaddr struct {
+	Value [8]byte
+}
+
+func (opt *If_EUIaddr) Pack(endian binary.ByteOrder) ([]byte, error) {
+	return packTlv(IF_EUIADDR, opt.Value[:], endian)
+}
+
+func (opt *If_EUIaddr) Code() uint16 {
+	return IF_EUIADDR
+}
+
+type If_Speed struct {
+	Value uint64 // bits per second
+}
+
+func (opt *If_Speed) Pack(endian binary.ByteOrder) ([]byte, error) {
+	return packUint64(IF_SPEED, opt.Value, endian)
+}
+
+func (opt *If_Speed) Code() uint16 {
+	return IF_SPEED
+}
+
+type If_Tzone struct {
+	Value int32
+}
+
+func (opt *If_Tzone) Pack(endian binary.ByteOrder) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := binary.Write(buf, endian, opt.Value); err != nil {
+		return nil, err
+	}
+	return packTlv(IF_TZONE, buf.Bytes(), endian)
+}
+
+func (opt *If_Tzone) Code() uint16 {
+	return IF_TZONE
+}
+
+// if_filter types
+const (
+	FILTER_STRING = 0 // libpcap filter string
+	FILTER_BPF    = 1 // BPF program
+)
+
+type If_Filter struct {
+	Type  uint8
+	Value []byte
+}
+
+func (opt *If_Filter) Pack(endian binary.ByteOrder) ([]byte, error) {
+	return packTlv(IF_FILTER, append([]byte{opt.Type}, opt.Value...), endian)
+}
+
+func (opt *If_Filter) Code() uint16 {
+	return IF_FILTER
+}
+
+type If_Fcslen struct {
+	Value uint8
+}
+
+func (opt *If_Fcslen) Pack(endian binary.ByteOrder) ([]byte, error) {
+	return packTlv(IF_FCSLEN, []byte{opt.Value}, endian)
+}
+
+func (opt *If_Fcslen) Code() uint16 {
+	return IF_FCSLEN
+}
+
+type If_Tsoffset struct {
+	Value int64 // seconds
+}
+
+func (opt *If_Tsoffset) Pack(endian binary.ByteOrder) ([]byte, error) {
+	return packUint64(IF_TSOFFSET, uint64(opt.Value), endian)
+}
+
+func (opt *If_Tsoffset) Code() uint16 {
+	return IF_TSOFFSET
+}
+
+type If_Hardware struct {
+	Value string
+}
+
+func (opt *If_Hardware) Pack(endian binary.ByteOrder) ([]byte, error) {
+	return packTlv(IF_HARDWARE, []byte(opt.Value), endian)
+}
+
+func (opt *If_Hardware) Code() uint16 {
+	return IF_HARDWARE
+}
+
+type If_Txspeed struct {
+	Value uint64 // bits per second
+}
+
+func (opt *If_Txspeed) Pack(endian binary.ByteOrder) ([]byte, error) {
+	return packUint64(IF_TXSPEED, opt.Value, endian)
+}
+
+func (opt *If_Txspeed) Code() uint16 {
+	return IF_TXSPEED
+}
+
+type If_Rxspeed struct {
+	Value uint64 // bits per second
+}
+
+func (opt *If_Rxspeed) Pack(endian binary.ByteOrder) ([]byte, error) {
+	return packUint64(IF_RXSPEED, opt.Value, endian)
+}
+
+func (opt *If_Rxspeed) Code() uint16 {
+	return IF_RXSPEED
+}
+
+type If_Iana_Tzname struct {
+	Value string
+}
+
+func (opt *If_Iana_Tzname) Pack(endian binary.ByteOrder) ([]byte, error) {
+	return packTlv(IF_IANA_TZNAME, []byte(opt.Value), endian)
+}
+
+func (opt *If_Iana_Tzname) Code() uint16 {
+	return IF_IANA_TZNAME
+}
+
+func packUint64(tlvType int, value uint64, endian binary.ByteOrder) ([]byte, error) {
+	buf := make([]byte, 8)
+	endian.PutUint64(buf, value)
+	return packTlv(tlvType, buf, endian)
+}
+
+// badOption records a malformed option in Warnings, leaving it out of the
+// block, or returns it as an error if Strict is set.
+func (pr *PcapngReader) badOption(blockType uint32, tlv TLV, reason string) error {
+	err := &PcapError{fmt.Sprintf("offset 0x%08x: block type 0x%08x: option %v: %v", pr.metadata.Offset, blockType, tlv.Type, reason)}
+	if pr.Strict {
+		return err
+	}
+	pr.warn(err)
+	return nil
+}
+
+// interfaceOption parses the IDB options added after the original three.
+// Custom Options go to customOption, other options it does not know give
+// nil, and those it knows with a bad length nil and badLength.
+func interfaceOption(tlv TLV, endian binary.ByteOrder) (opt Option, badLength bool) {
+
+	v := tlv.Value
+	switch tlv.Type {
+	case IF_DESCRIPTION:
+		return &If_Description{string(v)}, false
+	case IF_IPV4ADDR:
+		if len(v) == 8 {
+			var opt If_IPv4addr
+			copy(opt.Address[:], v[0:4])
+			copy(opt.Netmask[:], v[4:8])
+			return &opt, false
+		}
+	case IF_IPV6ADDR:
+		if len(v) == 17 {
+			var opt If_IPv6addr
+			copy(opt.Address[:], v[0:16])
+			opt.PrefixLength = v[16]
+			return &opt, false
+		}
+	case IF_MACADDR:
+		if len(v) == 6 {
+			var opt If_MACaddr
+			copy(opt.Value[:], v)
+			return &opt, false
+		}
+	case IF_EUIADDR:
+		if len(v) == 8 {
+			var opt If_EUIaddr
+			copy(opt.Value[:], v)
+			return &opt, false
+		}
+	case IF_SPEED:
+		if len(v) == 8 {
+			return &If_Speed{endian.Uint64(v)}, false
+		}
+	case IF_TZONE:
+		if len(v) == 4 {
+			return &If_Tzone{int32(endian.Uint32(v))}, false
+		}
+	case IF_FILTER:
+		if len(v) >= 1 {
+			return &If_Filter{v[0], append([]byte(nil), v[1:]...)}, false
+		}
+	case IF_FCSLEN:
+		if len(v) == 1 {
+			return &If_Fcslen{v[0]}, false
+		}
+	case IF_TSOFFSET:
+		if len(v) == 8 {
+			return &If_Tsoffset{int64(endian.Uint64(v))}, false
+		}
+	case IF_HARDWARE:
+		return &If_Hardware{string(v)}, false
+	case IF_TXSPEED:
+		if len(v) == 8 {
+			return &If_Txspeed{endian.Uint64(v)}, false
+		}
+	case IF_RXSPEED:
+		if len(v) == 8 {
+			return &If_Rxspeed{endian.Uint64(v)}, false
+		}
+	case IF_IANA_TZNAME:
+		return &If_Iana_Tzname{string(v)}, false
+	default:
+		return customOption(tlv, endian), false
+	}
+	return nil, true
+}
+
+// Name returns if_name or "" if the interface has none.
+func (b *InterfaceBlock) Name() string {
+	for _, opt := range b.Options {
+		if o, ok := opt.(*If_Name); ok {
+			return o.Value
+		}
+	}
+	return ""
+}
+
+// SectionInterfaces reads pr to the end and returns the interfaces of each section.
+func SectionInterfaces(pr *PcapngReader) ([][]*InterfaceBlock, error) {
+
+	var sections [][]*InterfaceBlock
+	err := pr.Walk(Handlers{
+		OnSectionHeader: func(b *SectionBlock) error {
+			sections = append(sections, nil)
+			return nil
+		},
+		OnInterface: func(b *InterfaceBlock) error {
+			if len(sections) == 0 {
+				return &PcapError{"Interface Description Block before the Section Header Block"}
+			}
+			sections[len(sections)-1] = append(sections[len(sections)-1], b)
+			return nil
+		},
+	})
+	return sections, err
+}
+
+// LinkTypeName returns the LINKTYPE_ name of the common link types without
+// the prefix, e.g. "ETHERNET".
+func LinkTypeName(linkType uint16) string {
+	switch linkType {
+	case 0:
+		return "NULL"
+	case 1:
+		return "ETHERNET"
+	case 101:
+		return "RAW"
+	case 105:
+		return "IEEE802_11"
+	case 113:
+		return "LINUX_SLL"
+	case 127:
+		return "IEEE802_11_RADIOTAP"
+	case 147:
+		return "USER0"
+	case 192:
+		return "PPI"
+	case 195:
+		return "IEEE802_15_4_WITHFCS"
+	case 201:
+		return "BLUETOOTH_HCI_H4_WITH_PHDR"
+	case 227:
+		return "CAN_SOCKETCAN"
+	case 228:
+		return "IPV4"
+	case 229:
+		return "IPV6"
+	case 249:
+		return "USBPCAP"
+	case 253:
+		return "NETLINK"
+	case 276:
+		return "LINUX_SLL2"
+	}
+	return fmt.Sprintf("%v", linkType)
+}
@@ -0,0 +1,98 @@
+package pcapng
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// IndexEntry locates one block within a file.
+type IndexEntry struct {
+	Offset      int64
+	Type        uint32
+	TotalLength uint32
+	Section     int // 0 based index of the section the block belongs to
+}
+
+// Index is the list of blocks in a file, built by reading only the block
+// headers, so that blocks can later be read in any order.
+type Index struct {
+	Entries []IndexEntry
+	Endians []binary.ByteOrder // byte order of each section
+}
+
+// BuildIndex walks the block headers of the first size bytes of r.
+func BuildIndex(r io.ReaderAt, size int64) (*Index, error) {
+
+	idx := new(Index)
+	var endian binary.ByteOrder = binary.LittleEndian
+	hdr := make([]byte, 12)
+
+	for offset := int64(0); offset < size; {
+		if _, err := r.ReadAt(hdr, offset); err != nil {
+			return nil, err
+		}
+
+		blockType := endian.Uint32(hdr[0:4])
+		if blockType == SECTION_HEADER_BLOCK {
+			// the byte order magic decides the byte order of the section
+			switch binary.LittleEndian.Uint32(hdr[8:12]) {
+			case MagicNumber:
+				endian = binary.LittleEndian
+			case SwapMagicNumber:
+				endian = binary.BigEndian
+			default:
+				return nil, &PcapError{fmt.Sprintf("Bad Magic Number at offset %v", offset)}
+			}
+			idx.Endians = append(idx.Endians, endian)
+		} else if len(idx.Endians) == 0 {
+			return nil, &PcapError{"file does not start with a Section Header Block"}
+		}
+
+		totalLength := endian.Uint32(hdr[4:8])
+		if totalLength < 12 || totalLength&3 != 0 {
+			return nil, &PcapError{fmt.Sprintf("bad block length %v at offset %v", totalLength, offset)}
+		}
+
+		idx.Entries = append(idx.Entries, IndexEntry{offset, blockType, totalLength, len(idx.Endians) - 1})
+		offset += int64(totalLength)
+	}
+	return idx, nil
+}
+
+// ReadBlock reads and parses the i'th block of the index from r.
+func (idx *Index) ReadBlock(r io.ReaderAt, i int) (interface{}, error) {
+	e := idx.Entries[i]
+	pr := Reader(io.NewSectionReader(r, e.Offset, int64(e.TotalLength)))
+	pr.Endian = idx.Endians[e.Section]
+	return pr.Read()
+}
+
+// BuildSectionIndex indexes only the blocks of one section (0 based),
+// jumping over the sections before it when their Section Length is known.
+func BuildSectionIndex(r io.ReaderAt, size int64, section int) (*Index, error) {
+
+	sections, err := Sections(r, size)
+	if err != nil {
+		return nil, err
+	}
+	if section < 0 || section >= len(sections) {
+		return nil, &PcapError{fmt.Sprintf("section %v does not exist, there are %v", section, len(sections))}
+	}
+
+	s := sections[section]
+	idx, err := BuildIndex(io.NewSectionReader(r, s.Offset, s.Length), s.Length)
+	if err != nil {
+		return nil, err
+	}
+
+	idx.Endians = nil
+	for _, s := range sections {
+		idx.Endians = append(idx.Endians, s.Endian)
+	}
+	for i := range idx.Entries {
+		idx.Entries[i].Offset += s.Offset
+		idx.Entries[i].Section = section
+	}
+	return idx, nil
+}
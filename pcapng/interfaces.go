@@ -0,0 +1,56 @@
+package pcapng
+
+// GlobalInterface is an interface of any section of the input, with an ID
+// that is unique across sections.
+type GlobalInterface struct {
+	ID        int    // 0 based, in the order the interfaces were read
+	Section   int    // 0 based index of the section
+	SectionID uint32 // the Interface ID packets use within the section
+	*InterfaceBlock
+}
+
+// Interfaces returns the interfaces of all the sections read so far,
+// indexed by their global ID. Interfaces are registered even when Keep
+// rejects their Interface Description Blocks.
+func (pr *PcapngReader) Interfaces() []*GlobalInterface {
+	return pr.interfaces
+}
+
+// resolve registers the interface of an Interface Description Block and
+// records the interface of the block in the Metadata.
+func (pr *PcapngReader) resolve(blockType uint32, buf []byte, block interface{}) {
+
+	var id uint32
+	switch b := block.(type) {
+	case *InterfaceBlock:
+		gi := &GlobalInterface{
+			ID:             len(pr.interfaces),
+			Section:        pr.sections - 1,
+			SectionID:      uint32(len(pr.sectionInterfaces)),
+			InterfaceBlock: b,
+		}
+		pr.interfaces = append(pr.interfaces, gi)
+		pr.sectionInterfaces = append(pr.sectionInterfaces, gi)
+		pr.metadata.Interface = gi
+		return
+	case *EnhancedPacketBlock:
+		id = b.InterfaceID
+	case *InterfaceStatisticsBlock:
+		id = b.InterfaceID
+	default:
+		switch blockType {
+		case SIMPLE_PACKET_BLOCK:
+			id = 0 // the first interface of the section
+		case OBSOLETE_PACKET_BLOCK:
+			if len(buf) < 10 {
+				return
+			}
+			id = uint32(pr.Endian.Uint16(buf[8:10]))
+		default:
+			return
+		}
+	}
+	if int(id) < len(pr.sectionInterfaces) {
+		pr.metadata.Interface = pr.sectionInterfaces[id]
+	}
+}
@@ -0,0 +1,228 @@
+package pcapng
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// InterfaceStats are the statistics of one interface gathered from its
+// Interface Statistics Blocks. Interfaces of different sections with the
+// same if_name and link type are taken to be the same interface captured
+// again. Its counters go on across the sections like within one: a value
+// not below the one before continues the same count, as when a capture is
+// split into sections, and a lower one starts a new count that is added.
+type InterfaceStats struct {
+	Name       string
+	LinkType   uint16
+	Interfaces []*GlobalInterface // one per section the interface is in
+	Blocks     int                // Interface Statistics Blocks read
+
+	Received  uint64 // isb_ifrecv
+	Dropped   uint64 // isb_ifdrop
+	Accepted  uint64 // isb_filteraccept
+	OSDropped uint64 // isb_osdrop
+	Delivered uint64 // isb_usrdeliv
+
+	// the capture window, from isb_starttime and isb_endtime or, without
+	// them, the timestamps of the blocks
+	Start time.Time
+	End   time.Time
+
+	reported map[uint16]bool
+	counters map[uint16]*isbCounter // across the sections
+}
+
+// Has reports whether any block carried the counter with the option code,
+// e.g. ISB_IFDROP, telling a count of 0 from one never reported.
+func (s *InterfaceStats) Has(code uint16) bool {
+	return s.reported[code]
+}
+
+// DropRate returns Dropped over Received plus Dropped, or 0 if neither
+// was reported.
+func (s *InterfaceStats) DropRate() float64 {
+	if s.Received+s.Dropped == 0 {
+		return 0
+	}
+	return float64(s.Dropped) / float64(s.Received+s.Dropped)
+}
+
+func (s *InterfaceStats) String() string {
+
+	name := s.Name
+	if name == "" {
+		name = "-"
+	}
+	fields := []string{fmt.Sprintf("%v %v", name, LinkTypeName(s.LinkType))}
+	for _, c := range []struct {
+		code  uint16
+		name  string
+		value uint64
+	}{
+		{ISB_IFRECV, "received", s.Received},
+		{ISB_IFDROP, "dropped", s.Dropped},
+		{ISB_FILTERACCEPT, "accepted", s.Accepted},
+		{ISB_OSDROP, "os dropped", s.OSDropped},
+		{ISB_USRDELIV, "delivered", s.Delivered},
+	} {
+		if s.Has(c.code) {
+			fields = append(fields, fmt.Sprintf("%v %v", c.name, c.value))
+		}
+	}
+	if !s.Start.IsZero() {
+		fields = append(fields, fmt.Sprintf("from %v to %v", s.Start.UTC().Format(time.RFC3339Nano), s.End.UTC().Format(time.RFC3339Nano)))
+	}
+	return strings.Join(fields, ", ")
+}
+
+// isbCounter follows one counter of an interface. The counters count from
+// the start of the capture, so each block replaces the value of the one
+// before, unless it went back, when the capture is taken to have restarted
+// and the value before is kept.
+type isbCounter struct {
+	base uint64 // of the captures before a restart
+	last uint64
+}
+
+func (c *isbCounter) add(v uint64) {
+	if v < c.last {
+		c.base += c.last
+	}
+	c.last = v
+}
+
+func (c *isbCounter) total() uint64 {
+	return c.base + c.last
+}
+
+// sectionStats gathers the blocks of one interface of one section.
+type sectionStats struct {
+	gi       *GlobalInterface
+	counters map[uint16][]uint64 // the values of each counter in order
+	blocks   int
+	start    time.Time
+	end      time.Time
+}
+
+// InterfaceStatistics reads pr to the end and returns the statistics of
+// every interface with at least one Interface Statistics Block, in the
+// order of their first block.
+func InterfaceStatistics(pr *PcapngReader) ([]*InterfaceStats, error) {
+
+	var order []*sectionStats
+	bySection := map[*GlobalInterface]*sectionStats{}
+
+	err := pr.Walk(Handlers{
+		OnStatistics: func(b *InterfaceStatisticsBlock) error {
+			gi := pr.Metadata().Interface
+			if gi == nil {
+				return &PcapError{fmt.Sprintf("offset 0x%08x: statistics of undescribed interface %v", pr.Metadata().Offset, b.InterfaceID)}
+			}
+			ss := bySection[gi]
+			if ss == nil {
+				ss = &sectionStats{gi: gi, counters: map[uint16][]uint64{}}
+				bySection[gi] = ss
+				order = append(order, ss)
+			}
+			ss.blocks++
+			ss.add(b)
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var stats []*InterfaceStats
+	byName := map[string]*InterfaceStats{}
+	for _, ss := range order {
+		name := ss.gi.Name()
+		key := fmt.Sprintf("%v/%v", name, ss.gi.LinkType)
+		s := byName[key]
+		if s == nil || name == "" {
+			// interfaces without a name are only the same within a section
+			s = &InterfaceStats{Name: name, LinkType: ss.gi.LinkType, reported: map[uint16]bool{}, counters: map[uint16]*isbCounter{}}
+			byName[key] = s
+			stats = append(stats, s)
+		}
+		s.merge(ss)
+	}
+	return stats, nil
+}
+
+// add records the counters and times of an Interface Statistics Block.
+func (ss *sectionStats) add(b *InterfaceStatisticsBlock) {
+
+	ifb := ss.gi.InterfaceBlock
+	at := ifb.PacketTime(b.TimestampHigh, b.TimestampLow)
+	start, end := at, at
+
+	for _, opt := range b.Options {
+		var v uint64
+		switch o := opt.(type) {
+		case *Isb_Starttime:
+			start = ifb.PacketTime(o.TimestampHigh, o.TimestampLow)
+			continue
+		case *Isb_Endtime:
+			end = ifb.PacketTime(o.TimestampHigh, o.TimestampLow)
+			continue
+		case *Isb_Ifrecv:
+			v = o.Value
+		case *Isb_Ifdrop:
+			v = o.Value
+		case *Isb_Filteraccept:
+			v = o.Value
+		case *Isb_Osdrop:
+			v = o.Value
+		case *Isb_Usrdeliv:
+			v = o.Value
+		default:
+			continue
+		}
+		ss.counters[opt.Code()] = append(ss.counters[opt.Code()], v)
+	}
+
+	if ss.start.IsZero() || start.Before(ss.start) {
+		ss.start = start
+	}
+	if end.After(ss.end) {
+		ss.end = end
+	}
+}
+
+// merge goes on with the counts of one section, the sections in order.
+func (s *InterfaceStats) merge(ss *sectionStats) {
+
+	s.Interfaces = append(s.Interfaces, ss.gi)
+	s.Blocks += ss.blocks
+	for code, values := range ss.counters {
+		s.reported[code] = true
+		c := s.counters[code]
+		if c == nil {
+			c = &isbCounter{}
+			s.counters[code] = c
+		}
+		for _, v := range values {
+			c.add(v)
+		}
+		switch code {
+		case ISB_IFRECV:
+			s.Received = c.total()
+		case ISB_IFDROP:
+			s.Dropped = c.total()
+		case ISB_FILTERACCEPT:
+			s.Accepted = c.total()
+		case ISB_OSDROP:
+			s.OSDropped = c.total()
+		case ISB_USRDELIV:
+			s.Delivered = c.total()
+		}
+	}
+	if s.Start.IsZero() || ss.start.Before(s.Start) {
+		s.Start = ss.start
+	}
+	if ss.end.After(s.End) {
+		s.End = ss.end
+	}
+}
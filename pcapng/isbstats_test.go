@@ -0,0 +1,70 @@
+package pcapng
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestInterfaceStatistics(t *testing.T) {
+
+	// isbs writes a section for each list of isb_ifrecv values, on an
+	// interface named name
+	isbs := func(name string, sections ...[]uint64) []byte {
+		var buf bytes.Buffer
+		pw := Writer(&buf)
+		for _, values := range sections {
+			pw.Write(&SectionBlock{MajorVersion: 1, SectionLength: -1})
+			ifb := &InterfaceBlock{LinkType: 1}
+			if name != "" {
+				ifb.Options = []Option{&If_Name{Value: name}}
+			}
+			pw.Write(ifb)
+			for _, v := range values {
+				pw.Write(&InterfaceStatisticsBlock{Options: []Option{&Isb_Ifrecv{Value: v}}})
+			}
+		}
+		return buf.Bytes()
+	}
+
+	tests := []struct {
+		name     string
+		data     []byte
+		received []uint64 // of each interface
+	}{
+		{"one block", isbs("eth0", []uint64{10}), []uint64{10}},
+		{"growing", isbs("eth0", []uint64{10, 100}), []uint64{100}},
+		{"reset", isbs("eth0", []uint64{100, 5}), []uint64{105}},
+		{"continued in the next section", isbs("eth0", []uint64{10, 100}, []uint64{150, 200}), []uint64{200}},
+		{"restarted in the next section", isbs("eth0", []uint64{10, 100}, []uint64{150, 200}, []uint64{5, 50}), []uint64{250}},
+		{"unnamed", isbs("", []uint64{10, 100}, []uint64{150, 200}), []uint64{100, 200}},
+		{"no statistics", isbs("eth0", nil), nil},
+	}
+
+	for _, tt := range tests {
+		stats, err := InterfaceStatistics(Reader(bytes.NewReader(tt.data)))
+		if err != nil {
+			t.Errorf("%v: %v", tt.name, err)
+			continue
+		}
+		if len(stats) != len(tt.received) {
+			t.Errorf("%v: %v interfaces, want %v", tt.name, len(stats), len(tt.received))
+			continue
+		}
+		for i, s := range stats {
+			if s.Received != tt.received[i] {
+				t.Errorf("%v: interface %v received %v, want %v", tt.name, i, s.Received, tt.received[i])
+			}
+			if !s.Has(ISB_IFRECV) || s.Has(ISB_IFDROP) {
+				t.Errorf("%v: interface %v reports isb_ifrecv %v and isb_ifdrop %v", tt.name, i, s.Has(ISB_IFRECV), s.Has(ISB_IFDROP))
+			}
+		}
+	}
+}
+
+func TestInterfaceStatisticsUndescribed(t *testing.T) {
+
+	data := withSection(t, rawBlock(INTERFACE_STATISTICS_BLOCK, make([]byte, 12)))
+	if _, err := InterfaceStatistics(Reader(bytes.NewReader(data))); err == nil {
+		t.Error("statistics of an undescribed interface were not an error")
+	}
+}
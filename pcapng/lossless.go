@@ -0,0 +1,65 @@
+package pcapng
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+)
+
+// ReadRaw reads the next block without parsing it. The GenericBlock holds
+// the bytes of the block exactly as they were read, in the byte order of
+// its section, so writing it reproduces the input.
+func (pr *PcapngReader) ReadRaw() (*GenericBlock, error) {
+
+	blockType, blockTotalLength, buf, err := pr.nextBlock()
+	if err != nil {
+		pr.countError(err)
+		return nil, err
+	}
+	if blockType == SECTION_HEADER_BLOCK {
+		if err := pr.startRawSection(buf); err != nil {
+			pr.countError(err)
+			return nil, err
+		}
+	}
+	return &GenericBlock{blockType, blockTotalLength, buf}, nil
+}
+
+// CopyLossless copies the blocks of r to w byte for byte, keeping unknown
+// blocks and options, padding, byte order and section boundaries, and
+// returns the SHA-256 of the input. Input that ends part way through a
+// block is an error. To check what w received, read it back with
+// VerifyCopy.
+func CopyLossless(w io.Writer, r io.Reader) (sum []byte, err error) {
+
+	in := sha256.New()
+	pr := Reader(io.TeeReader(r, in))
+
+	for {
+		b, err := pr.ReadRaw()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if _, err := b.StreamTo(w, pr.Endian); err != nil {
+			return nil, err
+		}
+	}
+	return in.Sum(nil), nil
+}
+
+// VerifyCopy reads a copy back, e.g. the file CopyLossless wrote once it
+// is flushed and closed, and checks that its SHA-256 is sum.
+func VerifyCopy(r io.Reader, sum []byte) error {
+
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return err
+	}
+	if got := h.Sum(nil); !bytes.Equal(got, sum) {
+		return &PcapError{fmt.Sprintf("copy differs from the input: sha256 %x, expected %x", got, sum)}
+	}
+	return nil
+}
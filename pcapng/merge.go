@@ -0,0 +1,259 @@
+package pcapng
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"time"
+)
+
+// Clock corrects the timestamps of an input whose clock was off. Every
+// timestamp t becomes
+//
+//	t + Offset + (t - Reference) * PPM / 1e6
+//
+// as transform.TimeShift does. A zero Reference means the first timestamp
+// of the input.
+type Clock struct {
+	Offset    time.Duration
+	PPM       float64
+	Reference time.Time
+}
+
+// Correct returns t on the corrected clock.
+func (c *Clock) Correct(t time.Time) time.Time {
+	if c.Reference.IsZero() {
+		c.Reference = t
+	}
+	corrected := t.Add(c.Offset)
+	if c.PPM != 0 {
+		corrected = corrected.Add(time.Duration(float64(t.Sub(c.Reference)) * c.PPM / 1e6))
+	}
+	return corrected
+}
+
+// mergeInput is the state of one input of Merge.
+type mergeInput struct {
+	pr         *PcapngReader
+	clock      *Clock
+	interfaces []*InterfaceBlock // of the current section
+	mapping    []uint32          // output interface ID of each of them
+	next       *EnhancedPacketBlock
+	time       time.Time // corrected time of next
+	done       bool
+}
+
+// Merge writes the inputs to pw as one section in timestamp order, after
+// correcting the timestamps of each input with its clock. clocks is
+// indexed like inputs; a missing or nil clock leaves an input as it is.
+// The packets of each input must be in timestamp order. Interfaces are
+// renumbered in the order they are seen, as Concat with merge does, and
+// the blocks other than packets are written as they are met.
+func Merge(pw *PcapngWriter, inputs []*PcapngReader, clocks []*Clock) error {
+
+	state := make([]*mergeInput, len(inputs))
+	for i, pr := range inputs {
+		state[i] = &mergeInput{pr: pr}
+		if i < len(clocks) {
+			state[i].clock = clocks[i]
+		}
+	}
+
+	next := uint32(0)
+	wroteSection := false
+
+	// fill reads the blocks of an input up to its next packet
+	fill := func(n int) error {
+		in := state[n]
+		in.next = nil
+		for !in.done && in.next == nil {
+			block, err := in.pr.Read()
+			if err == io.EOF {
+				in.done = true
+				break
+			} else if err != nil {
+				return err
+			}
+
+			switch b := block.(type) {
+			case *SectionBlock:
+				in.interfaces, in.mapping = nil, nil
+				if wroteSection {
+					continue
+				}
+				wroteSection = true
+			case *InterfaceBlock:
+				in.interfaces = append(in.interfaces, b)
+				in.mapping = append(in.mapping, next)
+				next++
+			case *EnhancedPacketBlock:
+				id := b.InterfaceID
+				if b.InterfaceID, err = remap(in.mapping, id, n); err != nil {
+					return err
+				}
+				in.time = in.interfaces[id].PacketTime(b.TimestampHigh, b.TimestampLow)
+				if in.clock != nil {
+					in.time = in.clock.Correct(in.time)
+					b.TimestampHigh, b.TimestampLow = in.interfaces[id].SplitPacketTime(in.time, pw.Rounding)
+				}
+				in.next = b
+				continue
+			case *InterfaceStatisticsBlock:
+				id := b.InterfaceID
+				if b.InterfaceID, err = remap(in.mapping, id, n); err != nil {
+					return err
+				}
+				if in.clock != nil {
+					ifb := in.interfaces[id]
+					t := in.clock.Correct(ifb.PacketTime(b.TimestampHigh, b.TimestampLow))
+					b.TimestampHigh, b.TimestampLow = ifb.SplitPacketTime(t, pw.Rounding)
+				}
+			case *GenericBlock:
+				if b.Type == SIMPLE_PACKET_BLOCK {
+					return &PcapError{fmt.Sprintf("input %v has Simple Packet Blocks, which have no timestamp to merge by", n)}
+				}
+			}
+
+			if err := pw.Write(block.(Block)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for n := range state {
+		if err := fill(n); err != nil {
+			return err
+		}
+	}
+
+	for {
+		first := -1
+		for n, in := range state {
+			if in.next != nil && (first < 0 || in.time.Before(state[first].time)) {
+				first = n
+			}
+		}
+		if first < 0 {
+			return nil
+		}
+		if err := pw.Write(state[first].next); err != nil {
+			return err
+		}
+		if err := fill(first); err != nil {
+			return err
+		}
+	}
+}
+
+// ClockPair is the time the same packet was seen by a reference capture
+// and by another capture.
+type ClockPair struct {
+	Reference time.Time
+	Other     time.Time
+}
+
+// CommonPackets reads two captures and pairs the timestamps of packets
+// seen by both. key picks the part of a packet that is the same in both,
+// e.g. the IP payload; nil compares the whole captured data. Packets whose
+// key is not unique within either capture are not paired.
+func CommonPackets(reference, other *PcapngReader, key func(linkType uint16, data []byte) []byte) ([]ClockPair, error) {
+
+	// seen maps a key hash to its time, or to the zero time if it repeats
+	read := func(pr *PcapngReader) (map[uint64]time.Time, []uint64, error) {
+		seen := make(map[uint64]time.Time)
+		var order []uint64
+		var interfaces []*InterfaceBlock
+		for {
+			block, err := pr.Read()
+			if err == io.EOF {
+				return seen, order, nil
+			} else if err != nil {
+				return nil, nil, err
+			}
+			switch b := block.(type) {
+			case *SectionBlock:
+				interfaces = nil
+			case *InterfaceBlock:
+				interfaces = append(interfaces, b)
+			case *EnhancedPacketBlock:
+				if int(b.InterfaceID) >= len(interfaces) {
+					continue
+				}
+				ifb := interfaces[b.InterfaceID]
+				data := b.PacketData
+				if key != nil {
+					if data = key(ifb.LinkType, data); data == nil {
+						continue
+					}
+				}
+				h := fnv.New64a()
+				h.Write(data)
+				sum := h.Sum64()
+				if _, ok := seen[sum]; ok {
+					seen[sum] = time.Time{}
+					continue
+				}
+				seen[sum] = ifb.PacketTime(b.TimestampHigh, b.TimestampLow)
+				order = append(order, sum)
+			}
+		}
+	}
+
+	ref, order, err := read(reference)
+	if err != nil {
+		return nil, err
+	}
+	oth, _, err := read(other)
+	if err != nil {
+		return nil, err
+	}
+
+	var pairs []ClockPair
+	for _, sum := range order {
+		r, o := ref[sum], oth[sum]
+		if !r.IsZero() && !o.IsZero() {
+			pairs = append(pairs, ClockPair{r, o})
+		}
+	}
+	return pairs, nil
+}
+
+// EstimateClock fits the Clock that best moves the Other times of pairs
+// onto their Reference times, by least squares. With a single pair only
+// the Offset is estimated.
+func EstimateClock(pairs []ClockPair) (*Clock, error) {
+
+	if len(pairs) == 0 {
+		return nil, &PcapError{"no packets in common to estimate the clock from"}
+	}
+
+	c := &Clock{Reference: pairs[0].Other}
+	for _, p := range pairs {
+		if p.Other.Before(c.Reference) {
+			c.Reference = p.Other
+		}
+	}
+
+	// y = offset + x * ppm / 1e6, x and y in seconds
+	var sx, sy, sxx, sxy float64
+	n := float64(len(pairs))
+	for _, p := range pairs {
+		x := p.Other.Sub(c.Reference).Seconds()
+		y := p.Reference.Sub(p.Other).Seconds()
+		sx += x
+		sy += y
+		sxx += x * x
+		sxy += x * y
+	}
+
+	offset := sy / n
+	if d := n*sxx - sx*sx; len(pairs) > 1 && math.Abs(d) > 1e-12 {
+		slope := (n*sxy - sx*sy) / d
+		offset = (sy - slope*sx) / n
+		c.PPM = slope * 1e6
+	}
+	c.Offset = time.Duration(offset * float64(time.Second))
+	return c, nil
+}
@@ -0,0 +1,43 @@
+package pcapng
+
+// Metadata locates the block returned by the last Read.
+type Metadata struct {
+	Offset  int64 // of the block, counted from where the reader started
+	Block   int   // 0 based index of the block
+	Section int   // 0 based index of the section
+	Packet  int   // 1 based packet number for packet blocks, otherwise 0
+	// the interface of packet, statistics and Interface Description Blocks
+	// returned by Read, nil for other blocks or an undefined interface
+	Interface *GlobalInterface
+}
+
+// Metadata returns the position of the block returned by the last Read.
+// Blocks passed over by SkipSection are not counted.
+func (pr *PcapngReader) Metadata() Metadata {
+	return pr.metadata
+}
+
+// advance records the block of length bytes just read at offset.
+func (pr *PcapngReader) advance(blockType uint32, offset int64, length int) {
+
+	pr.offset = offset + int64(length)
+	if pr.Metrics != nil {
+		pr.Metrics.AddBytes(uint64(length))
+	}
+
+	if blockType == SECTION_HEADER_BLOCK {
+		pr.sections++
+		pr.sectionInterfaces = nil
+	}
+	pr.metadata = Metadata{Offset: offset, Block: pr.blocks, Section: pr.sections - 1}
+	pr.blocks++
+
+	switch blockType {
+	case ENHANCED_PACKET_BLOCK, SIMPLE_PACKET_BLOCK, OBSOLETE_PACKET_BLOCK:
+		pr.packets++
+		if pr.Metrics != nil {
+			pr.Metrics.AddPackets(1)
+		}
+		pr.metadata.Packet = pr.packets
+	}
+}
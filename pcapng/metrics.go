@@ -0,0 +1,70 @@
+package pcapng
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+// Metrics receives the counts of a reader or writer, e.g. to export them
+// for monitoring. A Metrics shared by several readers and writers must be
+// safe for concurrent use.
+type Metrics interface {
+	AddPackets(n uint64)     // packet blocks read or written
+	AddBytes(n uint64)       // block bytes read or written
+	AddParseErrors(n uint64) // reads that failed other than at the end of input
+	AddDrops(n uint64)       // packets reported dropped by the capture
+	AddRotations(n uint64)   // output files completed by rotation
+}
+
+// Counters is a Metrics keeping totals. It is safe for concurrent use.
+type Counters struct {
+	Packets     uint64
+	Bytes       uint64
+	ParseErrors uint64
+	Drops       uint64
+	Rotations   uint64
+}
+
+func (c *Counters) AddPackets(n uint64)     { atomic.AddUint64(&c.Packets, n) }
+func (c *Counters) AddBytes(n uint64)       { atomic.AddUint64(&c.Bytes, n) }
+func (c *Counters) AddParseErrors(n uint64) { atomic.AddUint64(&c.ParseErrors, n) }
+func (c *Counters) AddDrops(n uint64)       { atomic.AddUint64(&c.Drops, n) }
+func (c *Counters) AddRotations(n uint64)   { atomic.AddUint64(&c.Rotations, n) }
+
+// Snapshot returns a copy of the totals read atomically.
+func (c *Counters) Snapshot() Counters {
+	return Counters{
+		Packets:     atomic.LoadUint64(&c.Packets),
+		Bytes:       atomic.LoadUint64(&c.Bytes),
+		ParseErrors: atomic.LoadUint64(&c.ParseErrors),
+		Drops:       atomic.LoadUint64(&c.Drops),
+		Rotations:   atomic.LoadUint64(&c.Rotations),
+	}
+}
+
+// meteredWriter counts the bytes written through it.
+type meteredWriter struct {
+	w       io.Writer
+	metrics Metrics
+}
+
+func (mw *meteredWriter) Write(b []byte) (int, error) {
+	n, err := mw.w.Write(b)
+	mw.metrics.AddBytes(uint64(n))
+	return n, err
+}
+
+// out returns the writer blocks are written to.
+func (pw *PcapngWriter) out() io.Writer {
+	if pw.Metrics == nil {
+		return pw.fh
+	}
+	return &meteredWriter{pw.fh, pw.Metrics}
+}
+
+// countError counts err as a parse error unless it is the end of input.
+func (pr *PcapngReader) countError(err error) {
+	if err != nil && err != io.EOF && pr.Metrics != nil {
+		pr.Metrics.AddParseErrors(1)
+	}
+}
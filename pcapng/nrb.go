@@ -0,0 +1,138 @@
+package pcapng
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// NamePolicy decides which names survive when Name Resolution Blocks are
+// coalesced and an address is given different names.
+type NamePolicy int
+
+const (
+	NamesKeepAll    NamePolicy = iota // every name given for the address
+	NamesKeepFirst                    // the names of the first record for the address
+	NamesKeepLatest                   // the names of the last record for the address, dropping stale ones
+)
+
+// ParseNamePolicy parses "all", "first" or "latest".
+func ParseNamePolicy(s string) (NamePolicy, error) {
+	switch s {
+	case "all":
+		return NamesKeepAll, nil
+	case "first":
+		return NamesKeepFirst, nil
+	case "latest":
+		return NamesKeepLatest, nil
+	}
+	return NamesKeepAll, &PcapError{fmt.Sprintf("unknown name policy %q, expected all, first or latest", s)}
+}
+
+// splitNameRecord splits the value of an ipv4 or ipv6 record into the
+// address and its zero terminated names.
+func splitNameRecord(value []byte, size int) (string, []string) {
+	if len(value) < size {
+		return "", nil
+	}
+	var names []string
+	for _, name := range strings.Split(string(value[size:]), "\x00") {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return string(value[:size]), names
+}
+
+// MergeNameResolution returns one block holding the records of every
+// block, each address once, with no name repeated for an address and
+// conflicting names resolved by policy. Addresses keep the order they
+// were first seen in. Identical options are kept once.
+func MergeNameResolution(blocks []*NameResolutionBlock, policy NamePolicy) *NameResolutionBlock {
+
+	var order []string             // addresses, 4 or 16 bytes
+	names := map[string][]string{} // by address
+	seenOptions := map[string]bool{}
+	merged := &NameResolutionBlock{}
+
+	for _, b := range blocks {
+		for _, rec := range b.Records {
+			var address string
+			var recNames []string
+			switch r := rec.(type) {
+			case *Nrb_Record_ipv4:
+				address, recNames = splitNameRecord(r.Value, 4)
+			case *Nrb_Record_ipv6:
+				address, recNames = splitNameRecord(r.Value, 16)
+			}
+			if address == "" || len(recNames) == 0 {
+				continue
+			}
+
+			current, known := names[address]
+			if !known {
+				order = append(order, address)
+			}
+			switch {
+			case !known || policy == NamesKeepAll:
+				names[address] = appendNew(current, recNames)
+			case policy == NamesKeepLatest:
+				names[address] = appendNew(nil, recNames)
+			}
+		}
+
+		for _, opt := range b.Options {
+			// options are compared by their encoding
+			key, err := opt.Pack(binary.LittleEndian)
+			if err != nil || seenOptions[string(key)] {
+				continue
+			}
+			seenOptions[string(key)] = true
+			merged.Options = append(merged.Options, opt)
+		}
+	}
+
+	for _, address := range order {
+		value := []byte(address)
+		for _, name := range names[address] {
+			value = append(value, name...)
+			value = append(value, 0)
+		}
+		if len(address) == 4 {
+			merged.Records = append(merged.Records, &Nrb_Record_ipv4{value})
+		} else {
+			merged.Records = append(merged.Records, &Nrb_Record_ipv6{value})
+		}
+	}
+	return merged
+}
+
+// appendNew appends the names not already in list.
+func appendNew(list []string, names []string) []string {
+	for _, name := range names {
+		found := false
+		for _, n := range list {
+			if n == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, name)
+		}
+	}
+	return list
+}
+
+// WritePendingNames writes the Name Resolution Blocks held back by
+// CoalesceNames as one merged block. Write calls it before each Section
+// Header Block and Close calls it, so it only needs to be called directly
+// by writers that are not closed.
+func (pw *PcapngWriter) WritePendingNames() error {
+	if len(pw.pendingNames) == 0 {
+		return nil
+	}
+	merged := MergeNameResolution(pw.pendingNames, pw.NamePolicy)
+	pw.pendingNames = nil
+	return pw.write(merged)
+}
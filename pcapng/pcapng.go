@@ -6,6 +6,8 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"time"
 )
 
 // Block Types
@@ -14,6 +16,7 @@ const (
 	NAME_RESOLUTION_BLOCK       = 0x00000004
 	INTERFACE_STATISTICS_BLOCK  = 0x00000005
 	ENHANCED_PACKET_BLOCK       = 0x00000006
+	DECRYPTION_SECRETS_BLOCK    = 0x0000000A
 	SECTION_HEADER_BLOCK        = 0x0A0D0D0A
 )
 
@@ -30,6 +33,7 @@ type Packer interface {
 
 type Option interface {
 	Packer
+	Code() uint16 // the option code, e.g. OPT_COMMENT
 }
 
 type NbrRecord interface {
@@ -50,62 +54,66 @@ func (b *GenericBlock) Pack(endian binary.ByteOrder) ([]byte, error) {
 	return b.Data, nil
 }
 
-// Option types
+// Option codes
 const (
-	opt_endofopt = 0
-	opt_comment  = 1
+	OPT_ENDOFOPT = 0
+	OPT_COMMENT  = 1
 
 	// Section Header Block
-	shb_hardware = 2
-	shb_os       = 3
-	shb_userappl = 4
+	SHB_HARDWARE = 2
+	SHB_OS       = 3
+	SHB_USERAPPL = 4
 
 	// Interface Description Block
-	if_name        = 2
-	if_description = 3
-	if_IPv4addr    = 4
-	if_IPv6addr    = 5
-	if_MACaddr     = 6
-	if_EUIaddr     = 7
-	if_speed       = 8
-	if_tsresol     = 9
-	if_tzone       = 10
-	if_filter      = 11
-	if_os          = 12
-	if_fcslen      = 13
-	if_tsoffset    = 14
-	if_hardware    = 15
-	if_txspeed     = 16
-	if_rxspeed     = 17
+	IF_NAME        = 2
+	IF_DESCRIPTION = 3
+	IF_IPV4ADDR    = 4
+	IF_IPV6ADDR    = 5
+	IF_MACADDR     = 6
+	IF_EUIADDR     = 7
+	IF_SPEED       = 8
+	IF_TSRESOL     = 9
+	IF_TZONE       = 10
+	IF_FILTER      = 11
+	IF_OS          = 12
+	IF_FCSLEN      = 13
+	IF_TSOFFSET    = 14
+	IF_HARDWARE    = 15
+	IF_TXSPEED     = 16
+	IF_RXSPEED     = 17
+	IF_IANA_TZNAME = 18
 
 	// Interface Statistics Block
-	isb_starttime    = 2
-	isb_endtime      = 3
-	isb_ifrecv       = 4
-	isb_ifdrop       = 5
-	isb_filteraccept = 6
-	isb_osdrop       = 7
-	isb_usrdeliv     = 8
+	ISB_STARTTIME    = 2
+	ISB_ENDTIME      = 3
+	ISB_IFRECV       = 4
+	ISB_IFDROP       = 5
+	ISB_FILTERACCEPT = 6
+	ISB_OSDROP       = 7
+	ISB_USRDELIV     = 8
 
 	// Enhanced Packet Block
-	epb_flags     = 2
-	epb_hash      = 3
-	epb_dropcount = 4
-	epb_packetid  = 5
-	epb_queue     = 6
-	epb_verdict   = 7
+	EPB_FLAGS     = 2
+	EPB_HASH      = 3
+	EPB_DROPCOUNT = 4
+	EPB_PACKETID  = 5
+	EPB_QUEUE     = 6
+
+	// Enhanced Packet Block options of DraftVersion
+	EPB_VERDICT            = 7
+	EPB_PROCESSID_THREADID = 8
 
 	// Name Resolution Block
-	ns_dnsname    = 2
-	ns_dnsIP4addr = 3
-	ns_dnsIP6addr = 4
+	NS_DNSNAME    = 2
+	NS_DNSIP4ADDR = 3
+	NS_DNSIP6ADDR = 4
 )
 
 // Name Resolution Record types
 const (
-	nrb_record_end  = 0
-	nrb_record_ipv4 = 1
-	nrb_record_ipv6 = 2
+	NRB_RECORD_END  = 0
+	NRB_RECORD_IPV4 = 1
+	NRB_RECORD_IPV6 = 2
 )
 
 func packTlv(tlvType int, tlvValue []byte, endian binary.ByteOrder) ([]byte, error) {
@@ -135,7 +143,11 @@ type Opt_Comment struct {
 }
 
 func (opt *Opt_Comment) Pack(endian binary.ByteOrder) ([]byte, error) {
-	return packTlv(opt_comment, []byte(opt.Value), endian)
+	return packTlv(OPT_COMMENT, []byte(opt.Value), endian)
+}
+
+func (opt *Opt_Comment) Code() uint16 {
+	return OPT_COMMENT
 }
 
 type SectionBlock struct {
@@ -153,7 +165,11 @@ type Shb_Hardware struct {
 }
 
 func (opt *Shb_Hardware) Pack(endian binary.ByteOrder) ([]byte, error) {
-	return packTlv(shb_hardware, []byte(opt.Value), endian)
+	return packTlv(SHB_HARDWARE, []byte(opt.Value), endian)
+}
+
+func (opt *Shb_Hardware) Code() uint16 {
+	return SHB_HARDWARE
 }
 
 type Shb_Os struct {
@@ -161,7 +177,11 @@ type Shb_Os struct {
 }
 
 func (opt *Shb_Os) Pack(endian binary.ByteOrder) ([]byte, error) {
-	return packTlv(shb_os, []byte(opt.Value), endian)
+	return packTlv(SHB_OS, []byte(opt.Value), endian)
+}
+
+func (opt *Shb_Os) Code() uint16 {
+	return SHB_OS
 }
 
 type Shb_Userappl struct {
@@ -169,7 +189,11 @@ type Shb_Userappl struct {
 }
 
 func (opt *Shb_Userappl) Pack(endian binary.ByteOrder) ([]byte, error) {
-	return packTlv(shb_userappl, []byte(opt.Value), endian)
+	return packTlv(SHB_USERAPPL, []byte(opt.Value), endian)
+}
+
+func (opt *Shb_Userappl) Code() uint16 {
+	return SHB_USERAPPL
 }
 
 func packOptions(options []Option, endian binary.ByteOrder) ([]byte, error) {
@@ -186,7 +210,7 @@ func packOptions(options []Option, endian binary.ByteOrder) ([]byte, error) {
 			buf.Write(bytes)
 		}
 		// Code that writes pcapng files MUST put an opt_endofopt option at the end of an option list.
-		if err := binary.Write(buf, endian, uint16(opt_endofopt)); err != nil {
+		if err := binary.Write(buf, endian, uint16(OPT_ENDOFOPT)); err != nil {
 			return nil, err
 		}
 		if err := binary.Write(buf, endian, uint16(0)); err != nil {
@@ -209,7 +233,7 @@ func packRecords(records []NbrRecord, endian binary.ByteOrder) ([]byte, error) {
 	}
 	// An nrb_record_end MUST be added after the last Record, and
 	// MUST exist even if there are no other Records in the NRB.
-	if err := binary.Write(buf, endian, uint16(nrb_record_end)); err != nil {
+	if err := binary.Write(buf, endian, uint16(NRB_RECORD_END)); err != nil {
 		return nil, err
 	}
 	if err := binary.Write(buf, endian, uint16(0)); err != nil {
@@ -271,7 +295,11 @@ type If_Name struct {
 }
 
 func (opt *If_Name) Pack(endian binary.ByteOrder) ([]byte, error) {
-	return packTlv(if_name, []byte(opt.Value), endian)
+	return packTlv(IF_NAME, []byte(opt.Value), endian)
+}
+
+func (opt *If_Name) Code() uint16 {
+	return IF_NAME
 }
 
 type If_Tsresol struct {
@@ -281,7 +309,7 @@ type If_Tsresol struct {
 func (opt *If_Tsresol) Pack(endian binary.ByteOrder) ([]byte, error) {
 	buf := new(bytes.Buffer)
 
-	if err := binary.Write(buf, endian, uint16(if_tsresol)); err != nil { // Type
+	if err := binary.Write(buf, endian, uint16(IF_TSRESOL)); err != nil { // Type
 		return nil, err
 	}
 	if err := binary.Write(buf, endian, uint16(1)); err != nil { // Length
@@ -300,12 +328,20 @@ func (opt *If_Tsresol) Pack(endian binary.ByteOrder) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+func (opt *If_Tsresol) Code() uint16 {
+	return IF_TSRESOL
+}
+
 type If_Os struct {
 	Value string
 }
 
 func (opt *If_Os) Pack(endian binary.ByteOrder) ([]byte, error) {
-	return packTlv(if_os, []byte(opt.Value), endian)
+	return packTlv(IF_OS, []byte(opt.Value), endian)
+}
+
+func (opt *If_Os) Code() uint16 {
+	return IF_OS
 }
 
 func (b *InterfaceBlock) Pack(endian binary.ByteOrder) ([]byte, error) {
@@ -400,7 +436,11 @@ func (opt *Isb_Starttime) Pack(endian binary.ByteOrder) ([]byte, error) {
 	if err := binary.Write(buf, endian, opt); err != nil {
 		return nil, err
 	}
-	return packTlv(isb_starttime, buf.Bytes(), endian)
+	return packTlv(ISB_STARTTIME, buf.Bytes(), endian)
+}
+
+func (opt *Isb_Starttime) Code() uint16 {
+	return ISB_STARTTIME
 }
 
 type Isb_Endtime struct {
@@ -413,7 +453,11 @@ func (opt *Isb_Endtime) Pack(endian binary.ByteOrder) ([]byte, error) {
 	if err := binary.Write(buf, endian, opt); err != nil {
 		return nil, err
 	}
-	return packTlv(isb_endtime, buf.Bytes(), endian)
+	return packTlv(ISB_ENDTIME, buf.Bytes(), endian)
+}
+
+func (opt *Isb_Endtime) Code() uint16 {
+	return ISB_ENDTIME
 }
 
 type Isb_Ifrecv struct {
@@ -425,7 +469,11 @@ func (opt *Isb_Ifrecv) Pack(endian binary.ByteOrder) ([]byte, error) {
 	if err := binary.Write(buf, endian, opt); err != nil {
 		return nil, err
 	}
-	return packTlv(isb_ifrecv, buf.Bytes(), endian)
+	return packTlv(ISB_IFRECV, buf.Bytes(), endian)
+}
+
+func (opt *Isb_Ifrecv) Code() uint16 {
+	return ISB_IFRECV
 }
 
 type Isb_Ifdrop struct {
@@ -437,7 +485,11 @@ func (opt *Isb_Ifdrop) Pack(endian binary.ByteOrder) ([]byte, error) {
 	if err := binary.Write(buf, endian, opt); err != nil {
 		return nil, err
 	}
-	return packTlv(isb_ifdrop, buf.Bytes(), endian)
+	return packTlv(ISB_IFDROP, buf.Bytes(), endian)
+}
+
+func (opt *Isb_Ifdrop) Code() uint16 {
+	return ISB_IFDROP
 }
 
 type Isb_Filteraccept struct {
@@ -449,7 +501,11 @@ func (opt *Isb_Filteraccept) Pack(endian binary.ByteOrder) ([]byte, error) {
 	if err := binary.Write(buf, endian, opt); err != nil {
 		return nil, err
 	}
-	return packTlv(isb_filteraccept, buf.Bytes(), endian)
+	return packTlv(ISB_FILTERACCEPT, buf.Bytes(), endian)
+}
+
+func (opt *Isb_Filteraccept) Code() uint16 {
+	return ISB_FILTERACCEPT
 }
 
 type Isb_Osdrop struct {
@@ -461,7 +517,11 @@ func (opt *Isb_Osdrop) Pack(endian binary.ByteOrder) ([]byte, error) {
 	if err := binary.Write(buf, endian, opt); err != nil {
 		return nil, err
 	}
-	return packTlv(isb_osdrop, buf.Bytes(), endian)
+	return packTlv(ISB_OSDROP, buf.Bytes(), endian)
+}
+
+func (opt *Isb_Osdrop) Code() uint16 {
+	return ISB_OSDROP
 }
 
 type Isb_Usrdeliv struct {
@@ -473,7 +533,11 @@ func (opt *Isb_Usrdeliv) Pack(endian binary.ByteOrder) ([]byte, error) {
 	if err := binary.Write(buf, endian, opt); err != nil {
 		return nil, err
 	}
-	return packTlv(isb_usrdeliv, buf.Bytes(), endian)
+	return packTlv(ISB_USRDELIV, buf.Bytes(), endian)
+}
+
+func (opt *Isb_Usrdeliv) Code() uint16 {
+	return ISB_USRDELIV
 }
 
 type EnhancedPacketBlock struct {
@@ -552,7 +616,23 @@ func (opt *Epb_Flags) Pack(endian binary.ByteOrder) ([]byte, error) {
 	if err := binary.Write(buf, endian, opt); err != nil {
 		return nil, err
 	}
-	return packTlv(epb_flags, buf.Bytes(), endian)
+	return packTlv(EPB_FLAGS, buf.Bytes(), endian)
+}
+
+func (opt *Epb_Flags) Code() uint16 {
+	return EPB_FLAGS
+}
+
+// epb_flags packet direction, bits 0-1
+const (
+	DirectionUnknown  = 0
+	DirectionInbound  = 1
+	DirectionOutbound = 2
+)
+
+// Direction returns the packet direction bits of the flags.
+func (opt *Epb_Flags) Direction() uint32 {
+	return opt.Value & 3
 }
 
 type Epb_Hash struct {
@@ -560,7 +640,11 @@ type Epb_Hash struct {
 }
 
 func (opt *Epb_Hash) Pack(endian binary.ByteOrder) ([]byte, error) {
-	return packTlv(epb_hash, opt.Value, endian)
+	return packTlv(EPB_HASH, opt.Value, endian)
+}
+
+func (opt *Epb_Hash) Code() uint16 {
+	return EPB_HASH
 }
 
 type Epb_Dropcount struct {
@@ -572,7 +656,11 @@ func (opt *Epb_Dropcount) Pack(endian binary.ByteOrder) ([]byte, error) {
 	if err := binary.Write(buf, endian, opt); err != nil {
 		return nil, err
 	}
-	return packTlv(epb_hash, buf.Bytes(), endian)
+	return packTlv(EPB_DROPCOUNT, buf.Bytes(), endian)
+}
+
+func (opt *Epb_Dropcount) Code() uint16 {
+	return EPB_DROPCOUNT
 }
 
 type Epb_Packetid struct {
@@ -584,7 +672,11 @@ func (opt *Epb_Packetid) Pack(endian binary.ByteOrder) ([]byte, error) {
 	if err := binary.Write(buf, endian, opt); err != nil {
 		return nil, err
 	}
-	return packTlv(epb_hash, buf.Bytes(), endian)
+	return packTlv(EPB_PACKETID, buf.Bytes(), endian)
+}
+
+func (opt *Epb_Packetid) Code() uint16 {
+	return EPB_PACKETID
 }
 
 type Epb_Queue struct {
@@ -596,14 +688,12 @@ func (opt *Epb_Queue) Pack(endian binary.ByteOrder) ([]byte, error) {
 	if err := binary.Write(buf, endian, opt); err != nil {
 		return nil, err
 	}
-	return packTlv(epb_queue, buf.Bytes(), endian)
+	return packTlv(EPB_QUEUE, buf.Bytes(), endian)
 }
 
-/*
-type Epb_Verdict struct {
-	// TODO
+func (opt *Epb_Queue) Code() uint16 {
+	return EPB_QUEUE
 }
-*/
 
 type NameResolutionBlock struct {
 	Type                 uint32
@@ -660,11 +750,11 @@ type Nrb_Record_ipv6 struct {
 }
 
 func (rec *Nrb_Record_ipv4) Pack(endian binary.ByteOrder) ([]byte, error) {
-	return packTlv(nrb_record_ipv4, rec.Value, endian)
+	return packTlv(NRB_RECORD_IPV4, rec.Value, endian)
 }
 
 func (rec *Nrb_Record_ipv6) Pack(endian binary.ByteOrder) ([]byte, error) {
-	return packTlv(nrb_record_ipv6, rec.Value, endian)
+	return packTlv(NRB_RECORD_IPV6, rec.Value, endian)
 }
 
 type Ns_Dnsname struct {
@@ -672,7 +762,11 @@ type Ns_Dnsname struct {
 }
 
 func (opt *Ns_Dnsname) Pack(endian binary.ByteOrder) ([]byte, error) {
-	return packTlv(ns_dnsname, []byte(opt.Value), endian)
+	return packTlv(NS_DNSNAME, []byte(opt.Value), endian)
+}
+
+func (opt *Ns_Dnsname) Code() uint16 {
+	return NS_DNSNAME
 }
 
 type Ns_DnsIP4addr struct {
@@ -680,7 +774,11 @@ type Ns_DnsIP4addr struct {
 }
 
 func (opt *Ns_DnsIP4addr) Pack(endian binary.ByteOrder) ([]byte, error) {
-	return packTlv(ns_dnsIP4addr, opt.Value[:], endian)
+	return packTlv(NS_DNSIP4ADDR, opt.Value[:], endian)
+}
+
+func (opt *Ns_DnsIP4addr) Code() uint16 {
+	return NS_DNSIP4ADDR
 }
 
 type Ns_DnsIP6addr struct {
@@ -688,7 +786,11 @@ type Ns_DnsIP6addr struct {
 }
 
 func (opt *Ns_DnsIP6addr) Pack(endian binary.ByteOrder) ([]byte, error) {
-	return packTlv(ns_dnsIP6addr, opt.Value[:], endian)
+	return packTlv(NS_DNSIP6ADDR, opt.Value[:], endian)
+}
+
+func (opt *Ns_DnsIP6addr) Code() uint16 {
+	return NS_DNSIP6ADDR
 }
 
 // PcapError
@@ -700,11 +802,53 @@ func (pe *PcapError) Error() string {
 	return pe.errorString
 }
 
+// ErrWrongFormat is returned by the first Read of a libpcap file.
+var ErrWrongFormat = &PcapError{"the input is a libpcap file, not pcapng: read it with the pcap package"}
+
+// isPcapMagic tells if magic is one of the four libpcap file magic numbers
+// read as little endian.
+func isPcapMagic(magic uint32) bool {
+	switch magic {
+	case 0xa1b2c3d4, 0xd4c3b2a1, 0xa1b23c4d, 0x4d3cb2a1:
+		return true
+	}
+	return false
+}
+
 // PcapngReader encapsulates all the pcap reading logic
 type PcapngReader struct {
 	fh io.Reader
 	//Header     PcapHdr
-	Endian binary.ByteOrder
+	Endian     binary.ByteOrder
+	Strict     bool                        // return an error for problems that are otherwise only recorded in Warnings
+	Warnings   []error                     // problems found in blocks that could still be read, see MaxWarnings
+	sectionEnd int64                       // offset the current section ends at, -1 if unknown
+	Keep       func(blockType uint32) bool // if set, Read only returns the block types it accepts
+	MaxBlock   uint32                      // if not 0, longer blocks are an error rather than read into memory
+	Metrics    Metrics                     // if set, counts the packets, bytes and errors read
+	// VerifyHashes recomputes the epb_hash options Read can and records a
+	// mismatch in Warnings, or returns it as an error if Strict.
+	VerifyHashes bool
+	// AnyVersion reads sections of an unknown major version as if they
+	// were MajorVersion rather than failing, at the risk of misparsing.
+	AnyVersion bool
+	// MaxWarnings is the number of Warnings kept, 0 for DefaultMaxWarnings.
+	// Later ones are only counted in Stats, so reading a large damaged
+	// capture does not grow without bound.
+	MaxWarnings int
+	// Recover records blocks that cannot be read in Warnings and goes on
+	// with the next plausible block rather than returning an error.
+	Recover           bool
+	stats             ReadStats // see Stats
+	offset            int64     // of the next block
+	blocks            int       // read so far
+	sections          int
+	packets           int
+	metadata          Metadata           // of the last block read
+	interfaces        []*GlobalInterface // of all sections
+	sectionInterfaces []*GlobalInterface // of the current section
+	majorVersion      uint16             // of the current section
+	minorVersion      uint16
 	//NanoSecond bool // true if PcapRecHdr.TsUsec should be interpretted as nano seconds
 }
 
@@ -721,6 +865,7 @@ func Reader(fh io.Reader) (pr *PcapngReader) {
 	pr = new(PcapngReader)
 	pr.fh = fh
 	pr.Endian = binary.LittleEndian
+	pr.sectionEnd = -1
 	return pr
 }
 
@@ -729,6 +874,9 @@ func getTlvList(buf []byte, endian binary.ByteOrder) (remainingBuf []byte, tlvLi
 	for len(buf) > 0 {
 		var tlv TLV
 
+		if len(buf) < 4 {
+			return nil, nil, &PcapError{fmt.Sprintf("option header truncated to %v bytes", len(buf))}
+		}
 		if err := binary.Read(bytes.NewBuffer(buf[0:2]), endian, &tlv.Type); err != nil {
 			return nil, nil, err
 		}
@@ -743,11 +891,18 @@ func getTlvList(buf []byte, endian binary.ByteOrder) (remainingBuf []byte, tlvLi
 		}
 
 		length := int(tlv.Length)
+		if length > len(buf) {
+			return nil, nil, &PcapError{fmt.Sprintf("option %v length %v exceeds the %v bytes left", tlv.Type, length, len(buf))}
+		}
 
 		tlv.Value = buf[:length]
 		padding := (4 - (length & 3)) & 3
 		paddedLength := length + padding
 
+		// tolerate a last option missing its padding
+		if paddedLength > len(buf) {
+			paddedLength = len(buf)
+		}
 		buf = buf[paddedLength:]
 
 		tlvList = append(tlvList, tlv)
@@ -757,49 +912,116 @@ func getTlvList(buf []byte, endian binary.ByteOrder) (remainingBuf []byte, tlvLi
 	return buf, tlvList, nil
 }
 
-// Read reads the next block from the pcap file.
-// If there are no more packets it returns nil, io.EOF
-func (pr *PcapngReader) Read() (block interface{}, err error) {
+// readBlock reads the bytes of the next block, switching byte order at each
+// Section Header Block. The bytes of blocks Keep rejects are passed over
+// and returned as nil. If Recover is set, a block that cannot be framed is
+// recorded in Warnings and reading resumes at the next plausible block.
+func (pr *PcapngReader) readBlock() (blockType uint32, blockTotalLength uint32, buf []byte, err error) {
+	for {
+		blockType, blockTotalLength, buf, err = pr.readFramed()
+		c, ok := err.(*corruptBlock)
+		if !ok {
+			return blockType, blockTotalLength, buf, err
+		}
+		pr.stats.CorruptBlocks++
+		if c.err == io.ErrUnexpectedEOF {
+			pr.stats.TruncatedReads++
+		}
+		if !pr.Recover {
+			return 0, 0, nil, c.err
+		}
+		warning := c.err
+		if _, ok := warning.(*PcapError); !ok {
+			warning = &PcapError{fmt.Sprintf("offset 0x%08x: %v", c.offset, c.err)}
+		}
+		pr.warn(warning)
+		if err := pr.resync(c.offset+4, c.data); err != nil {
+			return 0, 0, nil, err
+		}
+	}
+}
+
+// readFramed reads the next block for readBlock. Problems with the framing
+// of the block are returned as a *corruptBlock.
+func (pr *PcapngReader) readFramed() (blockType uint32, blockTotalLength uint32, buf []byte, err error) {
 	// the minimum sized block is 12 bytes
-	buf := make([]byte, 12)
+	buf = make([]byte, 12)
+	offset := pr.offset
 
 	// read block type and block length
-	if count, err := pr.fh.Read(buf); err != nil {
-		return nil, err
+	if count, err := io.ReadFull(pr.fh, buf); err == io.ErrUnexpectedEOF {
+		return 0, 0, nil, &corruptBlock{offset, nil, err}
+	} else if err != nil {
+		return 0, 0, nil, err
 	} else if count != len(buf) {
-		return nil, &PcapError{fmt.Sprintf("read %v packet header bytes expected %v\n", count, len(buf))}
+		return 0, 0, nil, &PcapError{fmt.Sprintf("read %v packet header bytes expected %v\n", count, len(buf))}
 	}
 
-	var blockType uint32
-
 	if err := binary.Read(bytes.NewReader(buf[0:4]), pr.Endian, &blockType); err != nil {
-		return nil, err
+		return 0, 0, nil, err
 	}
 	//  fmt.Printf("blockType=0x%08x\n", blockType)
 
+	if offset == 0 && pr.blocks == 0 && isPcapMagic(binary.LittleEndian.Uint32(buf[0:4])) {
+		return 0, 0, nil, ErrWrongFormat
+	}
+
 	var byteOrderMagic uint32
 
 	if blockType == SECTION_HEADER_BLOCK {
 		// The endianness is indicated by the Section Header Block
 
-		if err := binary.Read(bytes.NewReader(buf[8:12]), pr.Endian, &byteOrderMagic); err != nil {
-			return nil, err
+		// read it the same way every time since each section can differ
+		if err := binary.Read(bytes.NewReader(buf[8:12]), binary.LittleEndian, &byteOrderMagic); err != nil {
+			return 0, 0, nil, err
 		}
 		//  fmt.Printf("byteOrderMagic=0x%x\n", byteOrderMagic)
 
+		endian := pr.Endian
 		if byteOrderMagic == SwapMagicNumber {
 			pr.Endian = binary.BigEndian // swap endianness
-		} else if byteOrderMagic != MagicNumber {
-			return nil, &PcapError{fmt.Sprintf("Bad Magic Number 0x%08x", byteOrderMagic)}
+		} else if byteOrderMagic == MagicNumber {
+			pr.Endian = binary.LittleEndian
+		} else {
+			return 0, 0, nil, &corruptBlock{offset, buf[4:], &PcapError{fmt.Sprintf("Bad Magic Number 0x%08x", byteOrderMagic)}}
+		}
+		if pr.sections > 0 && pr.Endian != endian {
+			pr.stats.EndianSwitches++
 		}
 	}
 
-	var blockTotalLength uint32
 	if err := binary.Read(bytes.NewReader(buf[4:8]), pr.Endian, &blockTotalLength); err != nil {
-		return nil, err
+		return 0, 0, nil, err
 	}
 	//fmt.Printf("blockTotalLength=%v\n", blockTotalLength)
 
+	if blockTotalLength < 12 {
+		return 0, 0, nil, &corruptBlock{offset, buf[4:], &PcapError{fmt.Sprintf("offset 0x%08x: block type 0x%08x has Block Total Length %v, less than 12", offset, blockType, blockTotalLength)}}
+	}
+	if pr.MaxBlock != 0 && blockTotalLength > pr.MaxBlock {
+		return 0, 0, nil, &corruptBlock{offset, buf[4:], &PcapError{fmt.Sprintf("offset 0x%08x: block type 0x%08x of %v bytes is longer than the maximum of %v", offset, blockType, blockTotalLength, pr.MaxBlock)}}
+	}
+	if blockTotalLength&3 != 0 {
+		err := &PcapError{fmt.Sprintf("offset 0x%08x: block type 0x%08x has Block Total Length %v, not a multiple of 4", offset, blockType, blockTotalLength)}
+		if pr.Strict || pr.Recover {
+			return 0, 0, nil, &corruptBlock{offset, buf[4:], err}
+		}
+		pr.warn(err)
+	}
+
+	// Interface Description Blocks are always read for the interface registry
+	if blockType != SECTION_HEADER_BLOCK && blockType != INTERFACE_DESCRIPTION_BLOCK && !pr.keep(blockType) && int(blockTotalLength) > len(buf) {
+		// pass over the block without holding it in memory
+		if _, err := io.CopyN(ioutil.Discard, pr.fh, int64(blockTotalLength)-int64(len(buf))); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, 0, nil, &corruptBlock{offset, nil, err}
+		}
+		pr.advance(blockType, offset, int(blockTotalLength))
+		return blockType, blockTotalLength, nil, nil
+	}
+
 	// read the rest of the block
 	if len(buf) < int(blockTotalLength) {
 		grow := make([]byte, blockTotalLength)
@@ -807,18 +1029,137 @@ func (pr *PcapngReader) Read() (block interface{}, err error) {
 		buf = grow
 
 		// read the rest of the block
-		if count, err := pr.fh.Read(buf[12:]); err != nil {
-			return nil, err
+		if count, err := io.ReadFull(pr.fh, buf[12:]); err != nil {
+			return 0, 0, nil, &corruptBlock{offset, buf[4 : 12+count], err}
 		} else if count != len(buf)-12 {
-			return nil, &PcapError{fmt.Sprintf("read %v bytes expected %v\n", count, len(buf)-12)}
+			return 0, 0, nil, &PcapError{fmt.Sprintf("read %v bytes expected %v\n", count, len(buf)-12)}
+		}
+	}
+
+	// the Block Total Length is repeated at the end of the block
+	var trailingErr error
+	if trailingLength := pr.Endian.Uint32(buf[len(buf)-4:]); trailingLength != blockTotalLength {
+		trailingErr = &PcapError{fmt.Sprintf("offset 0x%08x: block type 0x%08x has Block Total Length %v but trailing Block Total Length %v", offset, blockType, blockTotalLength, trailingLength)}
+		if pr.Recover {
+			return 0, 0, nil, &corruptBlock{offset, buf[4:], trailingErr}
+		}
+	}
+
+	pr.advance(blockType, offset, len(buf))
+
+	if trailingErr != nil {
+		if pr.Strict {
+			return 0, 0, nil, trailingErr
+		}
+		pr.warn(trailingErr)
+	}
+	return blockType, blockTotalLength, buf, nil
+}
+
+// keep reports whether Read returns blocks of the type.
+func (pr *PcapngReader) keep(blockType uint32) bool {
+	return pr.Keep == nil || pr.Keep(blockType)
+}
+
+// nextBlock reads blocks until one of a type Keep accepts. Section Header
+// Blocks that are not returned still start a new section.
+func (pr *PcapngReader) nextBlock() (blockType uint32, blockTotalLength uint32, buf []byte, err error) {
+	for {
+		blockType, blockTotalLength, buf, err = pr.readBlock()
+		if err != nil || pr.keep(blockType) {
+			return blockType, blockTotalLength, buf, err
+		}
+		if blockType == SECTION_HEADER_BLOCK {
+			if err := pr.startRawSection(buf); err != nil {
+				return 0, 0, nil, err
+			}
+		}
+		if blockType == INTERFACE_DESCRIPTION_BLOCK {
+			block, err := pr.parse(blockType, blockTotalLength, buf)
+			if err != nil {
+				if block, err = pr.recoverParse(blockType, blockTotalLength, buf, err); err != nil {
+					return 0, 0, nil, err
+				}
+			}
+			pr.resolve(blockType, buf, block)
 		}
 	}
+}
+
+// PacketBlocks is a Keep function for the blocks that carry packets.
+func PacketBlocks(blockType uint32) bool {
+	switch blockType {
+	case ENHANCED_PACKET_BLOCK, SIMPLE_PACKET_BLOCK, OBSOLETE_PACKET_BLOCK:
+		return true
+	}
+	return false
+}
+
+// MetadataBlocks is a Keep function for every block except those that
+// carry packets.
+func MetadataBlocks(blockType uint32) bool {
+	return !PacketBlocks(blockType)
+}
+
+// Read reads the next block from the pcap file.
+// If there are no more packets it returns nil, io.EOF
+func (pr *PcapngReader) Read() (block interface{}, err error) {
+	block, err = pr.read()
+	pr.countError(err)
+	return block, err
+}
+
+func (pr *PcapngReader) read() (block interface{}, err error) {
+
+	blockType, blockTotalLength, buf, err := pr.nextBlock()
+	if err != nil {
+		return nil, err
+	}
+	if block, err = pr.parse(blockType, blockTotalLength, buf); err != nil {
+		if block, err = pr.recoverParse(blockType, blockTotalLength, buf, err); err != nil {
+			return nil, err
+		}
+	}
+	pr.resolve(blockType, buf, block)
+	if b, ok := block.(*EnhancedPacketBlock); ok && pr.VerifyHashes {
+		if err := b.VerifyHashes(); err != nil {
+			err = &PcapError{fmt.Sprintf("offset 0x%08x: packet %v: %v", pr.metadata.Offset, pr.metadata.Packet, err)}
+			if pr.Strict {
+				return nil, err
+			}
+			pr.warn(err)
+		}
+	}
+	return block, nil
+}
+
+// fixedLength is the Block Total Length of each block type without options
+// or data, the bytes parse reads before it looks at any length field.
+var fixedLength = map[uint32]int{
+	SECTION_HEADER_BLOCK:        28,
+	INTERFACE_DESCRIPTION_BLOCK: 20,
+	ENHANCED_PACKET_BLOCK:       32,
+	INTERFACE_STATISTICS_BLOCK:  24,
+	SIMPLE_PACKET_BLOCK:         16,
+	NAME_RESOLUTION_BLOCK:       12,
+	DECRYPTION_SECRETS_BLOCK:    20,
+}
+
+// parse decodes the bytes of a block read by readBlock. A block whose
+// fields or options point outside of it is returned as a PcapError, so
+// recoverParse can pass over it.
+func (pr *PcapngReader) parse(blockType uint32, blockTotalLength uint32, buf []byte) (block interface{}, err error) {
+
+	if min, ok := fixedLength[blockType]; ok && (int(blockTotalLength) < min || len(buf) < min) {
+		return nil, &PcapError{fmt.Sprintf("offset 0x%08x: block type 0x%08x has Block Total Length %v, less than its fixed fields need (%v)", pr.metadata.Offset, blockType, blockTotalLength, min)}
+	}
 
 	if blockType == SECTION_HEADER_BLOCK {
 
 		var majorVersion uint16
 		var minorVersion uint16
 		var sectionLength int64
+		byteOrderMagic := binary.LittleEndian.Uint32(buf[8:12])
 
 		if err := binary.Read(bytes.NewBuffer(buf[12:14]), pr.Endian, &majorVersion); err != nil {
 			return nil, err
@@ -829,6 +1170,9 @@ func (pr *PcapngReader) Read() (block interface{}, err error) {
 	    if err := binary.Read(bytes.NewBuffer(buf[16:24]), pr.Endian, &sectionLength); err != nil {
 			return nil, err
 		}
+		if err := pr.checkVersion(majorVersion, minorVersion); err != nil {
+			return nil, err
+		}
 
 		optionLen := int(blockTotalLength) - 28
 		optionBuf := buf[24 : 24+optionLen]
@@ -841,14 +1185,20 @@ func (pr *PcapngReader) Read() (block interface{}, err error) {
 
 		for _, tlv := range tlvList {
 			switch tlv.Type {
-			case opt_comment:
+			case OPT_COMMENT:
 				options = append(options, &Opt_Comment{string(tlv.Value)})
-			case shb_hardware:
+			case SHB_HARDWARE:
 				options = append(options, &Shb_Hardware{string(tlv.Value)})
-			case shb_os:
+			case SHB_OS:
 				options = append(options, &Shb_Os{string(tlv.Value)})
-			case shb_userappl:
+			case SHB_USERAPPL:
 				options = append(options, &Shb_Userappl{string(tlv.Value)})
+			default:
+				if option := customOption(tlv, pr.Endian); option != nil {
+					options = append(options, option)
+				} else {
+					pr.stats.UnknownOptions++
+				}
 			}
 		}
 
@@ -861,6 +1211,7 @@ func (pr *PcapngReader) Read() (block interface{}, err error) {
 			sectionLength,
 			options,
 		}
+		pr.startSection(sectionLength)
 
 	} else if blockType == INTERFACE_DESCRIPTION_BLOCK {
 
@@ -886,14 +1237,35 @@ func (pr *PcapngReader) Read() (block interface{}, err error) {
 		for _, tlv := range tlvList {
 			//fmt.Printf(" tlv.Type=%v tlv.Length=%v tlv.Value=%x\n", tlv.Type, tlv.Length, tlv.Value)
 			switch tlv.Type {
-			case opt_comment:
+			case OPT_COMMENT:
 				options = append(options, &Opt_Comment{string(tlv.Value)})
-			case if_name:
+			case IF_NAME:
 				options = append(options, &If_Name{string(tlv.Value)})
-			case if_tsresol:
-				options = append(options, &If_Tsresol{uint8(tlv.Value[0])})
-			case if_os:
+			case IF_TSRESOL:
+				if len(tlv.Value) < 1 {
+					err = pr.badOption(blockType, tlv, "empty if_tsresol")
+				} else if !ValidTsresol(tlv.Value[0]) {
+					err = pr.badOption(blockType, tlv, fmt.Sprintf("if_tsresol 0x%02x is finer than a 64 bit timestamp can count", tlv.Value[0]))
+				} else {
+					options = append(options, &If_Tsresol{uint8(tlv.Value[0])})
+				}
+				if err != nil {
+					return nil, err
+				}
+			case IF_OS:
 				options = append(options, &If_Os{string(tlv.Value)})
+			default:
+				option, badLength := interfaceOption(tlv, pr.Endian)
+				switch {
+				case option != nil:
+					options = append(options, option)
+				case badLength:
+					if err := pr.badOption(blockType, tlv, fmt.Sprintf("bad length %v", len(tlv.Value))); err != nil {
+						return nil, err
+					}
+				default:
+					pr.stats.UnknownOptions++
+				}
 			}
 		}
 
@@ -934,50 +1306,52 @@ func (pr *PcapngReader) Read() (block interface{}, err error) {
 
 		for _, tlv := range tlvList {
 			switch tlv.Type {
-			case opt_comment:
+			case OPT_COMMENT:
 				options = append(options, &Opt_Comment{string(tlv.Value)})
-			case isb_starttime:
+			case ISB_STARTTIME:
 				var option Isb_Starttime
 				if err := binary.Read(bytes.NewBuffer(tlv.Value), pr.Endian, &option); err != nil {
 					return nil, err
 				}
 				options = append(options, &option)
-			case isb_endtime:
+			case ISB_ENDTIME:
 				var option Isb_Endtime
 				if err := binary.Read(bytes.NewBuffer(tlv.Value), pr.Endian, &option); err != nil {
 					return nil, err
 				}
 				options = append(options, &option)
-			case isb_ifrecv:
+			case ISB_IFRECV:
 				var option Isb_Ifrecv
 				if err := binary.Read(bytes.NewBuffer(tlv.Value), pr.Endian, &option); err != nil {
 					return nil, err
 				}
 				options = append(options, &option)
-			case isb_ifdrop:
+			case ISB_IFDROP:
 				var option Isb_Ifdrop
 				if err := binary.Read(bytes.NewBuffer(tlv.Value), pr.Endian, &option); err != nil {
 					return nil, err
 				}
 				options = append(options, &option)
-			case isb_filteraccept:
+			case ISB_FILTERACCEPT:
 				var option Isb_Filteraccept
 				if err := binary.Read(bytes.NewBuffer(tlv.Value), pr.Endian, &option); err != nil {
 					return nil, err
 				}
 				options = append(options, &option)
-			case isb_osdrop:
+			case ISB_OSDROP:
 				var option Isb_Osdrop
 				if err := binary.Read(bytes.NewBuffer(tlv.Value), pr.Endian, &option); err != nil {
 					return nil, err
 				}
 				options = append(options, &option)
-			case isb_usrdeliv:
+			case ISB_USRDELIV:
 				var option Isb_Usrdeliv
 				if err := binary.Read(bytes.NewBuffer(tlv.Value), pr.Endian, &option); err != nil {
 					return nil, err
 				}
 				options = append(options, &option)
+			default:
+				pr.stats.UnknownOptions++
 			}
 		}
 
@@ -1018,6 +1392,9 @@ func (pr *PcapngReader) Read() (block interface{}, err error) {
 		//fmt.Printf("timestampLow=%v\n", timestampLow)
 		//fmt.Printf("capturedPacketLength=%v\n", capturedPacketLength)
 
+		if blockTotalLength < 32 || capturedPacketLength > blockTotalLength-32 {
+			return nil, &PcapError{fmt.Sprintf("Enhanced Packet Block of %v bytes cannot hold %v packet bytes", blockTotalLength, capturedPacketLength)}
+		}
 		packetData := buf[28 : 28+capturedPacketLength]
 		//fmt.Printf("originalPacketLength=%v\n", originalPacketLength)
 		packetPadding := (4 - (len(packetData) & 3)) & 3
@@ -1025,6 +1402,9 @@ func (pr *PcapngReader) Read() (block interface{}, err error) {
 		//fmt.Printf("paddedPacketLen=%v\n", paddedPacketLen)
 
 		optionLen := int(blockTotalLength) - (32 + paddedPacketLen)
+		if optionLen < 0 {
+			optionLen = 0 // packet data missing its padding
+		}
 		//fmt.Printf("optionLen=%v\n", optionLen)
 		optionBuf := buf[28+paddedPacketLen : 28+paddedPacketLen+optionLen]
 		_, tlvList, err := getTlvList(optionBuf, pr.Endian)
@@ -1037,41 +1417,41 @@ func (pr *PcapngReader) Read() (block interface{}, err error) {
 		for _, tlv := range tlvList {
 			//fmt.Printf(" tlv.Type=%v tlv.Length=%v tlv.Value=%x\n", tlv.Type, tlv.Length, tlv.Value)
 			switch tlv.Type {
-			case opt_comment:
+			case OPT_COMMENT:
 				options = append(options, &Opt_Comment{string(tlv.Value)})
-			case epb_flags:
+			case EPB_FLAGS:
 				var option Epb_Flags
 				if err := binary.Read(bytes.NewBuffer(tlv.Value), pr.Endian, &option); err != nil {
 					return nil, err
 				}
 				options = append(options, &option)
-			case epb_hash:
-				var option Epb_Hash
-				if err := binary.Read(bytes.NewBuffer(tlv.Value), pr.Endian, &option); err != nil {
-					return nil, err
-				}
-				options = append(options, &option)
-			case epb_dropcount:
+			case EPB_HASH:
+				// binary.Read cannot fill a slice of unknown length
+				options = append(options, &Epb_Hash{append([]byte(nil), tlv.Value...)})
+			case EPB_DROPCOUNT:
 				var option Epb_Dropcount
 				if err := binary.Read(bytes.NewBuffer(tlv.Value), pr.Endian, &option); err != nil {
 					return nil, err
 				}
 				options = append(options, &option)
-			case epb_packetid:
+			case EPB_PACKETID:
 				var option Epb_Packetid
 				if err := binary.Read(bytes.NewBuffer(tlv.Value), pr.Endian, &option); err != nil {
 					return nil, err
 				}
 				options = append(options, &option)
-			case epb_queue:
+			case EPB_QUEUE:
 				var option Epb_Queue
 				if err := binary.Read(bytes.NewBuffer(tlv.Value), pr.Endian, &option); err != nil {
 					return nil, err
 				}
 				options = append(options, &option)
-				/*
-					case epb_verdict:
-				*/
+			default:
+				if option := packetOption(tlv, pr.Endian); option != nil {
+					options = append(options, option)
+				} else {
+					pr.stats.UnknownOptions++
+				}
 			}
 		}
 
@@ -1101,9 +1481,9 @@ func (pr *PcapngReader) Read() (block interface{}, err error) {
 		for _, tlv := range tlvList {
 			//fmt.Printf("tlv.Type=%v tlv.Length=%v tlv.Value=%x\n", tlv.Type, tlv.Length, tlv.Value)
 			switch tlv.Type {
-			case nrb_record_ipv4:
+			case NRB_RECORD_IPV4:
 				records = append(records, &Nrb_Record_ipv4{tlv.Value})
-			case nrb_record_ipv6:
+			case NRB_RECORD_IPV6:
 				records = append(records, &Nrb_Record_ipv6{tlv.Value})
 			}
 		}
@@ -1118,18 +1498,20 @@ func (pr *PcapngReader) Read() (block interface{}, err error) {
 		for _, tlv := range tlvList {
 			//fmt.Printf("tlv.Type=%v tlv.Length=%v tlv.Value=%x\n", tlv.Type, tlv.Length, tlv.Value)
 			switch tlv.Type {
-			case opt_comment:
+			case OPT_COMMENT:
 				options = append(options, &Opt_Comment{string(tlv.Value)})
-			case ns_dnsname:
+			case NS_DNSNAME:
 				options = append(options, &Ns_Dnsname{string(tlv.Value)})
-			case ns_dnsIP4addr:
+			case NS_DNSIP4ADDR:
 				var option Ns_DnsIP4addr
 				copy(option.Value[:], tlv.Value)
 				options = append(options, &option)
-			case ns_dnsIP6addr:
+			case NS_DNSIP6ADDR:
 				var option Ns_DnsIP6addr
 				copy(option.Value[:], tlv.Value)
 				options = append(options, &option)
+			default:
+				pr.stats.UnknownOptions++
 			}
 		}
 
@@ -1139,8 +1521,60 @@ func (pr *PcapngReader) Read() (block interface{}, err error) {
 			records,
 			options}
 
+	} else if blockType == DECRYPTION_SECRETS_BLOCK {
+
+		var secretsType uint32
+		var secretsLength uint32
+
+		if err := binary.Read(bytes.NewBuffer(buf[8:12]), pr.Endian, &secretsType); err != nil {
+			return nil, err
+		}
+		if err := binary.Read(bytes.NewBuffer(buf[12:16]), pr.Endian, &secretsLength); err != nil {
+			return nil, err
+		}
+
+		if uint64(16)+uint64(secretsLength) > uint64(len(buf)-4) {
+			return nil, &PcapError{fmt.Sprintf("secrets length %v exceeds the %v byte block", secretsLength, blockTotalLength)}
+		}
+		secretsData := buf[16 : 16+secretsLength]
+		secretsPadding := (4 - (len(secretsData) & 3)) & 3
+		paddedSecretsLen := len(secretsData) + secretsPadding
+
+		optionLen := int(blockTotalLength) - (20 + paddedSecretsLen)
+		if optionLen < 0 {
+			optionLen = 0 // secrets missing their padding
+		}
+		optionBuf := buf[16+paddedSecretsLen : 16+paddedSecretsLen+optionLen]
+		_, tlvList, err := getTlvList(optionBuf, pr.Endian)
+		if err != nil {
+			return nil, err
+		}
+
+		var options []Option
+
+		for _, tlv := range tlvList {
+			switch tlv.Type {
+			case OPT_COMMENT:
+				options = append(options, &Opt_Comment{string(tlv.Value)})
+			default:
+				pr.stats.UnknownOptions++
+			}
+		}
+
+		block = &DecryptionSecretsBlock{
+			blockType,
+			blockTotalLength,
+			secretsType,
+			secretsLength,
+			secretsData,
+			options}
+
+	} else if blockType == CUSTOM_BLOCK || blockType == CUSTOM_BLOCK_NOCOPY {
+		// left for ParseCustomBlock, or a Vendor
+		block = &GenericBlock{blockType, blockTotalLength, buf}
 	} else {
-		fmt.Printf("#### unhandled block type %v ####\n", blockType)
+		pr.warn(&PcapError{fmt.Sprintf("offset 0x%08x: unknown block type 0x%08x, returned as a GenericBlock", pr.metadata.Offset, blockType)})
+		pr.stats.UnknownBlocks++
 		block = &GenericBlock{blockType, blockTotalLength, buf}
 	}
 
@@ -1149,8 +1583,42 @@ func (pr *PcapngReader) Read() (block interface{}, err error) {
 
 // PcapngWriter encapsulates all the pcapng writing logic
 type PcapngWriter struct {
-	fh     io.Writer
-	Endian binary.ByteOrder
+	fh         io.Writer
+	Endian     binary.ByteOrder
+	Rounding   Rounding          // how WritePacket fits timestamps to the interface resolution
+	interfaces []*InterfaceBlock // interfaces written in the current section
+
+	// CompressTimestamps gives each interface without one an if_tsoffset of
+	// CaptureStart and writes the packet timestamps relative to it. If
+	// CaptureStart is zero the blocks are held back until every interface
+	// has a packet, or maxHeldPackets came, and the earliest of those
+	// packets is the start. An interface without a packet by then keeps
+	// absolute timestamps. Close, WriteDropStatistics and the next section
+	// write held blocks as they are if no packet came.
+	CompressTimestamps bool
+	CaptureStart       time.Time
+	offsets            []int64     // seconds taken off the packets of each interface
+	start              time.Time   // of the held packets, for a zero CaptureStart
+	held               []heldBlock // waiting for the capture start
+
+	drops []dropCounter // reported with ReportDrops, per interface
+
+	Metrics Metrics // if set, counts the packets, bytes and drops written
+
+	// CheckTimestamps, if set, rejects packets with a timestamp it flags,
+	// warnings included.
+	CheckTimestamps *TimestampChecker
+
+	// CoalesceNames holds back the Name Resolution Blocks of each section
+	// and writes them as one block at the end of the section, without
+	// duplicates and with conflicts resolved by NamePolicy.
+	CoalesceNames bool
+	NamePolicy    NamePolicy
+	pendingNames  []*NameResolutionBlock
+
+	// MaxBlock, if not 0, makes longer blocks an error rather than writing
+	// them, as the same limit on a PcapngReader would refuse them.
+	MaxBlock uint32
 }
 
 // Writer opens a pcap file for writing.
@@ -1159,13 +1627,18 @@ func Writer(fh io.Writer) (pw *PcapngWriter) {
 
 	pw = new(PcapngWriter)
 	pw.fh = fh
-	pw.Endian = binary.LittleEndian
+	pw.Endian = HostByteOrder() // set Endian before the first Write to change it
 	return pw
 }
 
 // Write a block to io.Writer
 func Write(fh io.Writer, b Block, endian binary.ByteOrder) (err error) {
 
+	if s, ok := b.(Streamer); ok {
+		_, err := s.StreamTo(fh, endian)
+		return err
+	}
+
 	buf, err := b.Pack(endian)
 	if err != nil {
 		return err
@@ -1181,5 +1654,96 @@ func Write(fh io.Writer, b Block, endian binary.ByteOrder) (err error) {
 
 // Write a block to the pcap file.
 func (pw *PcapngWriter) Write(b Block) (err error) {
-	return Write(pw.fh, b, pw.Endian)
+	if pw.hold(b) {
+		return nil
+	}
+	switch block := b.(type) {
+	case *SectionBlock:
+		if err := pw.WriteDropStatistics(); err != nil {
+			return err
+		}
+		if err := pw.WritePendingNames(); err != nil {
+			return err
+		}
+		pw.interfaces = nil
+		pw.offsets = nil
+		pw.drops = nil
+	case *InterfaceBlock:
+		pw.interfaces = append(pw.interfaces, block)
+		var offset int64
+		if pw.CompressTimestamps {
+			if pw.CaptureStart.IsZero() && pw.start.IsZero() {
+				pw.offsets = append(pw.offsets, 0)
+				pw.held = append(pw.held, heldBlock{b, len(pw.interfaces) - 1})
+				return nil
+			}
+			b, offset = pw.withTsoffset(block)
+		}
+		pw.offsets = append(pw.offsets, offset)
+	case *EnhancedPacketBlock:
+		if err := pw.checkPacket(block); err != nil {
+			return err
+		}
+		if err := pw.checkTimestamp(block); err != nil {
+			return err
+		}
+		if len(pw.held) > 0 {
+			return pw.holdPacket(block)
+		}
+		return pw.writePacket(block)
+	case *InterfaceStatisticsBlock:
+		high, low, err := pw.relative(block.InterfaceID, block.TimestampHigh, block.TimestampLow)
+		if err != nil {
+			return err
+		}
+		if high != block.TimestampHigh || low != block.TimestampLow {
+			isb := *block
+			isb.TimestampHigh, isb.TimestampLow = high, low
+			b = &isb
+		}
+	case *NameResolutionBlock:
+		if pw.CoalesceNames {
+			pw.pendingNames = append(pw.pendingNames, block)
+			return nil
+		}
+	}
+	return pw.write(b)
+}
+
+// write writes b to the output, refusing blocks longer than MaxBlock.
+func (pw *PcapngWriter) write(b Block) error {
+
+	if pw.MaxBlock == 0 {
+		return Write(pw.out(), b, pw.Endian)
+	}
+
+	if s, ok := b.(Streamer); ok {
+		// streaming to nowhere only adds up the lengths, the data is not copied
+		length, err := s.StreamTo(ioutil.Discard, pw.Endian)
+		if err != nil {
+			return err
+		}
+		if err := pw.checkLength(b, length); err != nil {
+			return err
+		}
+		_, err = s.StreamTo(pw.out(), pw.Endian)
+		return err
+	}
+
+	buf, err := b.Pack(pw.Endian)
+	if err != nil {
+		return err
+	}
+	if err := pw.checkLength(b, int64(len(buf))); err != nil {
+		return err
+	}
+	_, err = writeAll(pw.out(), buf)
+	return err
+}
+
+func (pw *PcapngWriter) checkLength(b Block, length int64) error {
+	if length > int64(pw.MaxBlock) {
+		return &PcapError{fmt.Sprintf("%T of %v bytes is longer than the maximum of %v", b, length, pw.MaxBlock)}
+	}
+	return nil
 }
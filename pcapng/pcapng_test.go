@@ -0,0 +1,152 @@
+package pcapng
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// rawBlock frames body as a little endian block of blockType.
+func rawBlock(blockType uint32, body []byte) []byte {
+	total := uint32(12 + len(body))
+	buf := make([]byte, 8, total)
+	binary.LittleEndian.PutUint32(buf[0:4], blockType)
+	binary.LittleEndian.PutUint32(buf[4:8], total)
+	buf = append(buf, body...)
+	return append(buf, byte(total), byte(total>>8), byte(total>>16), byte(total>>24))
+}
+
+// rawIDB is the body of an Ethernet Interface Description Block with the
+// options in options.
+func rawIDB(options ...byte) []byte {
+	return append([]byte{1, 0, 0, 0, 0, 0, 0, 0}, options...)
+}
+
+// withSection returns a little endian Section Header Block followed by
+// blocks.
+func withSection(t *testing.T, blocks ...[]byte) []byte {
+	var buf bytes.Buffer
+	pw := Writer(&buf)
+	pw.Endian = binary.LittleEndian
+	if err := pw.Write(&SectionBlock{MajorVersion: 1, SectionLength: -1}); err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range blocks {
+		buf.Write(b)
+	}
+	return buf.Bytes()
+}
+
+func TestReadMalformed(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		block   []byte
+		err     bool // lenient
+		warning bool // lenient
+		strict  bool // an error when Strict
+	}{
+		{"IDB", rawBlock(INTERFACE_DESCRIPTION_BLOCK, rawIDB(9, 0, 1, 0, 6, 0, 0, 0)), false, false, false},
+		{"empty if_tsresol", rawBlock(INTERFACE_DESCRIPTION_BLOCK, rawIDB(9, 0, 0, 0)), false, true, true},
+		{"if_tsresol too fine", rawBlock(INTERFACE_DESCRIPTION_BLOCK, rawIDB(9, 0, 1, 0, 0x14, 0, 0, 0)), false, true, true},
+		{"if_speed of 4 bytes", rawBlock(INTERFACE_DESCRIPTION_BLOCK, rawIDB(8, 0, 4, 0, 1, 2, 3, 4)), false, true, true},
+		{"option beyond block", rawBlock(INTERFACE_DESCRIPTION_BLOCK, rawIDB(2, 0, 100, 0, 'e', 't', 'h', '0')), true, false, true},
+		{"option without padding", rawBlock(INTERFACE_DESCRIPTION_BLOCK, rawIDB(2, 0, 3, 0, 'e', 't', 'h', 0)), false, false, false},
+		{"IDB without its fixed fields", rawBlock(INTERFACE_DESCRIPTION_BLOCK, []byte{1, 0, 0, 0}), true, false, true},
+		{"EPB without its fixed fields", rawBlock(ENHANCED_PACKET_BLOCK, make([]byte, 12)), true, false, true},
+		{"ISB without its fixed fields", rawBlock(INTERFACE_STATISTICS_BLOCK, make([]byte, 4)), true, false, true},
+		{"SPB without its fixed fields", rawBlock(SIMPLE_PACKET_BLOCK, nil), true, false, true},
+		{"EPB data without padding", rawBlock(ENHANCED_PACKET_BLOCK, []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 5, 0, 0, 0, 5, 0, 0, 0, 1, 2, 3, 4, 5}), false, true, true},
+		{"DSB", rawBlock(DECRYPTION_SECRETS_BLOCK, []byte{'K', 'S', 'L', 'T', 3, 0, 0, 0, 'a', 'b', 'c', 0}), false, false, false},
+		{"DSB secrets beyond block", rawBlock(DECRYPTION_SECRETS_BLOCK, []byte{'K', 'S', 'L', 'T', 0xff, 0xff, 0xff, 0xff, 'a', 'b', 'c', 0}), true, false, true},
+		{"DSB without secrets length", rawBlock(DECRYPTION_SECRETS_BLOCK, []byte{'K', 'S', 'L', 'T'}), true, false, true},
+		{"DSB option beyond block", rawBlock(DECRYPTION_SECRETS_BLOCK, []byte{'K', 'S', 'L', 'T', 0, 0, 0, 0, 1, 0, 8, 0}), true, false, true},
+	}
+
+	for _, tt := range tests {
+		data := withSection(t, tt.block)
+		for _, strict := range []bool{false, true} {
+			pr := Reader(bytes.NewReader(data))
+			pr.Strict = strict
+			if _, err := pr.Read(); err != nil {
+				t.Fatalf("%v: reading the Section Header Block: %v", tt.name, err)
+			}
+			_, err := pr.Read()
+			wantErr := tt.err
+			if strict {
+				wantErr = tt.strict
+			}
+			if (err != nil) != wantErr {
+				t.Errorf("%v: strict %v: Read error %v, want error %v", tt.name, strict, err, wantErr)
+			}
+			if !strict && (len(pr.Warnings) > 0) != tt.warning {
+				t.Errorf("%v: Warnings %v, want a warning %v", tt.name, pr.Warnings, tt.warning)
+			}
+		}
+	}
+}
+
+func TestReadBadSectionHeader(t *testing.T) {
+
+	shb := func(length uint32) []byte {
+		b := make([]byte, 28)
+		binary.LittleEndian.PutUint32(b[0:4], SECTION_HEADER_BLOCK)
+		binary.LittleEndian.PutUint32(b[4:8], length)
+		binary.LittleEndian.PutUint32(b[8:12], MagicNumber)
+		b[12] = 1
+		binary.LittleEndian.PutUint64(b[16:24], 0xffffffffffffffff)
+		binary.LittleEndian.PutUint32(b[24:28], length)
+		return b
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"length 0", shb(0)},
+		{"length 12", shb(12)},
+		{"length not a multiple of 4", shb(30)},
+		{"length beyond input", shb(64)},
+		{"truncated", shb(28)[:20]},
+	}
+
+	for _, tt := range tests {
+		for _, p := range []Preset{PresetLenient, PresetStrict, PresetForensic} {
+			pr := ReaderWithPreset(bytes.NewReader(tt.data), p)
+			if b, err := pr.Read(); err == nil {
+				t.Errorf("%v: %v: Read = %T, want an error", tt.name, p, b)
+			}
+		}
+	}
+}
+
+func TestGetTlvList(t *testing.T) {
+
+	tests := []struct {
+		name string
+		buf  []byte
+		n    int // options, or -1 for an error
+	}{
+		{"none", nil, 0},
+		{"end of options", []byte{0, 0, 0, 0}, 0},
+		{"one", []byte{1, 0, 2, 0, 'h', 'i', 0, 0}, 1},
+		{"one then end", []byte{1, 0, 2, 0, 'h', 'i', 0, 0, 0, 0, 0, 0}, 1},
+		{"empty value", []byte{1, 0, 0, 0, 2, 0, 0, 0}, 2},
+		{"last without padding", []byte{1, 0, 2, 0, 'h', 'i'}, 1},
+		{"header truncated", []byte{1, 0}, -1},
+		{"value truncated", []byte{1, 0, 8, 0, 'h', 'i', 0, 0}, -1},
+		{"length 0xffff", []byte{1, 0, 0xff, 0xff}, -1},
+	}
+
+	for _, tt := range tests {
+		_, tlvs, err := getTlvList(tt.buf, binary.LittleEndian)
+		switch {
+		case tt.n < 0 && err == nil:
+			t.Errorf("%v: got %v options, want an error", tt.name, len(tlvs))
+		case tt.n >= 0 && err != nil:
+			t.Errorf("%v: %v", tt.name, err)
+		case tt.n >= 0 && len(tlvs) != tt.n:
+			t.Errorf("%v: got %v options, want %v", tt.name, len(tlvs), tt.n)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package pcapng
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// prefetched is a raw block, or the bytes read before an error.
+type prefetched struct {
+	data []byte
+	err  error
+}
+
+// Prefetcher reads the blocks of a pcapng stream in a goroutine, up to a
+// number of blocks ahead of its consumer, so the disk or network reads
+// overlap with parsing. It passes the bytes through unchanged and can be
+// given to Reader, Validate or anything else that reads pcapng.
+type Prefetcher struct {
+	blocks chan prefetched
+	done   chan struct{}
+	buf    []byte // rest of the current block
+	err    error
+}
+
+// Prefetch starts reading r ahead, keeping up to depth blocks buffered.
+// Close stops the goroutine if the stream is not read to the end. Blocks
+// are read ahead up to the length Validate takes to be corrupt, see
+// PrefetchMax.
+func Prefetch(r io.Reader, depth int) *Prefetcher {
+	return PrefetchMax(r, depth, maxBlockLength)
+}
+
+// PrefetchMax is Prefetch reading blocks ahead only up to maxBlock bytes,
+// e.g. the MaxBlock of the PcapngReader it feeds. At a longer Block Total
+// Length, likely garbage in a corrupt file, it stops reading ahead and
+// passes the rest of the stream through as it is read, leaving the reader
+// to report the block.
+func PrefetchMax(r io.Reader, depth int, maxBlock uint32) *Prefetcher {
+	p := &Prefetcher{
+		blocks: make(chan prefetched, depth),
+		done:   make(chan struct{}),
+	}
+	go p.run(r, maxBlock)
+	return p
+}
+
+func (p *Prefetcher) run(r io.Reader, maxBlock uint32) {
+
+	defer close(p.blocks)
+
+	var endian binary.ByteOrder = binary.LittleEndian
+	for {
+		hdr := make([]byte, 12)
+		if n, err := io.ReadFull(r, hdr); err != nil {
+			p.send(prefetched{hdr[:n], err})
+			return
+		}
+
+		// the byte order is only known once the section starts
+		if binary.LittleEndian.Uint32(hdr[0:4]) == SECTION_HEADER_BLOCK {
+			if binary.LittleEndian.Uint32(hdr[8:12]) == SwapMagicNumber {
+				endian = binary.BigEndian
+			} else {
+				endian = binary.LittleEndian
+			}
+		}
+
+		block := hdr
+		if length := endian.Uint32(hdr[4:8]); length > maxBlock {
+			if p.send(prefetched{hdr, nil}) {
+				p.passThrough(r)
+			}
+			return
+		} else if length > 12 {
+			block = make([]byte, length)
+			copy(block, hdr)
+			if n, err := io.ReadFull(r, block[12:]); err != nil {
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				p.send(prefetched{block[:12+n], err})
+				return
+			}
+		}
+		if !p.send(prefetched{block, nil}) {
+			return
+		}
+	}
+}
+
+// passThrough queues the rest of r in the chunks it is read in.
+func (p *Prefetcher) passThrough(r io.Reader) {
+	for {
+		buf := make([]byte, 64<<10)
+		n, err := r.Read(buf)
+		if n > 0 && !p.send(prefetched{buf[:n], nil}) {
+			return
+		}
+		if err != nil {
+			p.send(prefetched{nil, err})
+			return
+		}
+	}
+}
+
+// send queues a block, returning false once the Prefetcher is closed.
+func (p *Prefetcher) send(b prefetched) bool {
+	select {
+	case p.blocks <- b:
+		return true
+	case <-p.done:
+		return false
+	}
+}
+
+// Read reads the prefetched bytes.
+func (p *Prefetcher) Read(b []byte) (int, error) {
+	for len(p.buf) == 0 {
+		if p.err != nil {
+			return 0, p.err
+		}
+		next, ok := <-p.blocks
+		if !ok {
+			p.err = io.EOF
+			continue
+		}
+		p.buf, p.err = next.data, next.err
+	}
+	n := copy(b, p.buf)
+	p.buf = p.buf[n:]
+	return n, nil
+}
+
+// Close stops reading ahead. It does not close the underlying reader.
+func (p *Prefetcher) Close() error {
+	select {
+	case <-p.done:
+	default:
+		close(p.done)
+	}
+	return nil
+}
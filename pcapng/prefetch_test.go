@@ -0,0 +1,56 @@
+package pcapng
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"testing"
+)
+
+func TestPrefetch(t *testing.T) {
+
+	var buf bytes.Buffer
+	pw := Writer(&buf)
+	pw.Endian = binary.LittleEndian
+	if err := pw.Write(&SectionBlock{MajorVersion: 1, SectionLength: -1}); err != nil {
+		t.Fatal(err)
+	}
+	idb := rawBlock(INTERFACE_DESCRIPTION_BLOCK, rawIDB())
+	for i := 0; i < 10; i++ {
+		buf.Write(idb)
+	}
+	good := buf.Bytes()
+
+	// a block claiming 4 GiB followed by a little data
+	huge := make([]byte, 12+100)
+	binary.LittleEndian.PutUint32(huge[0:4], ENHANCED_PACKET_BLOCK)
+	binary.LittleEndian.PutUint32(huge[4:8], 0xfffffff0)
+	corrupt := append(append([]byte(nil), good...), huge...)
+
+	tests := []struct {
+		name     string
+		data     []byte
+		maxBlock uint32
+		err      bool // the input ends in a block, read up to the error
+	}{
+		{"whole blocks", good, maxBlockLength, false},
+		{"past the maximum", good, 16, false},
+		{"corrupt length", corrupt, maxBlockLength, false},
+		{"truncated", good[:len(good)-3], maxBlockLength, true},
+		{"truncated past the maximum", good[:len(good)-3], 16, false},
+	}
+
+	for _, tt := range tests {
+		p := PrefetchMax(bytes.NewReader(tt.data), 4, tt.maxBlock)
+		got, err := ioutil.ReadAll(p)
+		p.Close()
+		if (err != nil) != tt.err || !bytes.Equal(got, tt.data) {
+			t.Errorf("%v: read %v of %v bytes, %v", tt.name, len(got), len(tt.data), err)
+		}
+	}
+
+	problems, err := Validate(Prefetch(bytes.NewReader(corrupt), 4))
+	if err != nil || len(problems) == 0 {
+		t.Errorf("Validate of the corrupt length through Prefetch: %v, %v", problems, err)
+	}
+}
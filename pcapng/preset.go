@@ -0,0 +1,74 @@
+package pcapng
+
+import (
+	"fmt"
+	"io"
+)
+
+// Preset is a named set of the reader options that decide how it treats
+// problems in its input.
+type Preset int
+
+const (
+	// PresetLenient is the default: problems with blocks that can still
+	// be read go to Warnings, anything else is an error.
+	PresetLenient Preset = iota
+	// PresetStrict holds the input to the specification: every problem is
+	// an error, including epb_hash mismatches and unknown versions.
+	PresetStrict
+	// PresetForensic gets as much out of a damaged file as it can and
+	// never stops before the end of it. Every problem goes to Warnings,
+	// blocks that cannot be decoded are returned as a GenericBlock and
+	// reading skips over garbage to the next plausible block.
+	PresetForensic
+)
+
+// ReaderWithPreset returns a Reader of fh with the options of p.
+func ReaderWithPreset(fh io.Reader, p Preset) *PcapngReader {
+	pr := Reader(fh)
+	pr.Apply(p)
+	return pr
+}
+
+// Apply sets Strict, VerifyHashes, AnyVersion, Recover and MaxBlock as p
+// selects. Keep, Metrics and MaxWarnings are left as they are.
+func (pr *PcapngReader) Apply(p Preset) {
+	pr.Strict, pr.VerifyHashes, pr.AnyVersion, pr.Recover, pr.MaxBlock = false, false, false, false, 0
+
+	switch p {
+	case PresetStrict:
+		pr.Strict = true
+		pr.VerifyHashes = true
+	case PresetForensic:
+		pr.VerifyHashes = true
+		pr.AnyVersion = true
+		pr.Recover = true
+		// a corrupt length is skipped rather than read into memory
+		pr.MaxBlock = maxBlockLength
+	}
+}
+
+func (p Preset) String() string {
+	switch p {
+	case PresetLenient:
+		return "lenient"
+	case PresetStrict:
+		return "strict"
+	case PresetForensic:
+		return "forensic"
+	}
+	return fmt.Sprintf("Preset(%d)", int(p))
+}
+
+// ParsePreset parses "strict", "lenient" or "forensic".
+func ParsePreset(s string) (Preset, error) {
+	switch s {
+	case "strict":
+		return PresetStrict, nil
+	case "lenient":
+		return PresetLenient, nil
+	case "forensic":
+		return PresetForensic, nil
+	}
+	return PresetLenient, &PcapError{fmt.Sprintf("unknown preset %q, expected strict, lenient or forensic", s)}
+}
@@ -0,0 +1,163 @@
+package pcapng
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// ReadStats counts the anomalies a reader has come across, as a measure of
+// the quality of a capture.
+type ReadStats struct {
+	UnknownBlocks  int   // of a block type the reader does not know
+	UnknownOptions int   // dropped from the blocks read
+	CorruptBlocks  int   // that could not be framed or decoded
+	SkippedBytes   int64 // passed over by Recover looking for the next block
+	EndianSwitches int   // sections in another byte order than the one before
+	TruncatedReads int   // blocks cut short by the end of the input
+	LostWarnings   int   // not kept in Warnings once MaxWarnings were
+}
+
+// DefaultMaxWarnings is the number of Warnings a reader keeps unless
+// MaxWarnings is set.
+const DefaultMaxWarnings = 1000
+
+// Stats returns the anomalies counted so far.
+func (pr *PcapngReader) Stats() ReadStats {
+	return pr.stats
+}
+
+// Clean reports whether no anomaly was counted.
+func (s ReadStats) Clean() bool {
+	return s == ReadStats{}
+}
+
+func (s ReadStats) String() string {
+	return fmt.Sprintf("%v unknown blocks, %v unknown options, %v corrupt blocks, %v bytes skipped, %v byte order switches, %v truncated reads, %v warnings not kept",
+		s.UnknownBlocks, s.UnknownOptions, s.CorruptBlocks, s.SkippedBytes, s.EndianSwitches, s.TruncatedReads, s.LostWarnings)
+}
+
+// warn records a problem in Warnings, or only counts it once MaxWarnings
+// are kept.
+func (pr *PcapngReader) warn(err error) {
+	max := pr.MaxWarnings
+	if max <= 0 {
+		max = DefaultMaxWarnings
+	}
+	if len(pr.Warnings) >= max {
+		pr.stats.LostWarnings++
+		return
+	}
+	pr.Warnings = append(pr.Warnings, err)
+}
+
+// corruptBlock is a block readBlock cannot frame. data holds the bytes of
+// the block already read after its first 4, where the search for the next
+// block starts.
+type corruptBlock struct {
+	offset int64
+	data   []byte
+	err    error
+}
+
+func (e *corruptBlock) Error() string {
+	return e.err.Error()
+}
+
+// resync searches for the next block after a corrupt one. Blocks are 32 bit
+// aligned, so it steps 4 bytes at a time through data, the bytes at pos
+// already read, and then the input. The bytes from the first plausible
+// block header on are read again by readBlock. It returns io.EOF if the
+// input ends first.
+func (pr *PcapngReader) resync(pos int64, data []byte) error {
+
+	start := pos
+	window := append([]byte(nil), data...)
+	chunk := make([]byte, 4096)
+
+	for {
+		for len(window) < 12 {
+			count, err := pr.fh.Read(chunk)
+			window = append(window, chunk[:count]...)
+			if err == io.EOF && len(window) < 12 {
+				skipped := pos - start + int64(len(window))
+				if skipped > 0 {
+					pr.warn(&PcapError{fmt.Sprintf("offset 0x%08x: no block in the last %v bytes", start, skipped)})
+				}
+				pr.stats.SkippedBytes += skipped
+				pr.offset = pos + int64(len(window))
+				return io.EOF
+			} else if err != nil && err != io.EOF {
+				return err
+			}
+		}
+		if pr.plausible(window[:12]) {
+			break
+		}
+		window = window[4:]
+		pos += 4
+	}
+
+	pr.stats.SkippedBytes += pos - start
+	if pos != start {
+		pr.warn(&PcapError{fmt.Sprintf("offset 0x%08x: skipped %v bytes to the next block", start, pos-start)})
+	}
+	// the input can no longer seek to the end of the section
+	pr.fh = io.MultiReader(bytes.NewReader(window), pr.fh)
+	pr.sectionEnd = -1
+	pr.offset = pos
+	return nil
+}
+
+// plausible reports whether the first 12 bytes of a block look like the
+// start of one: a known block type, a length in range and, for an Enhanced
+// Packet Block, an interface of the section.
+func (pr *PcapngReader) plausible(hdr []byte) bool {
+
+	endian := pr.Endian
+	blockType := endian.Uint32(hdr[0:4])
+
+	// the block type of a Section Header Block reads the same in either byte order
+	if binary.LittleEndian.Uint32(hdr[0:4]) == SECTION_HEADER_BLOCK {
+		switch binary.LittleEndian.Uint32(hdr[8:12]) {
+		case MagicNumber:
+			endian = binary.LittleEndian
+		case SwapMagicNumber:
+			endian = binary.BigEndian
+		default:
+			return false
+		}
+		blockType = SECTION_HEADER_BLOCK
+	}
+
+	min, ok := minBlockLength[blockType]
+	if !ok {
+		return false
+	}
+	limit := uint32(maxBlockLength)
+	if pr.MaxBlock != 0 && pr.MaxBlock < limit {
+		limit = pr.MaxBlock
+	}
+	length := endian.Uint32(hdr[4:8])
+	if int(length) < min || length&3 != 0 || length > limit {
+		return false
+	}
+
+	if blockType == ENHANCED_PACKET_BLOCK && int(endian.Uint32(hdr[8:12])) >= len(pr.sectionInterfaces) {
+		return false
+	}
+	return true
+}
+
+// recoverParse handles a block readBlock framed but parse could not decode.
+// If Recover is set the problem goes to Warnings and the block is returned
+// as a GenericBlock, otherwise the error is returned.
+func (pr *PcapngReader) recoverParse(blockType uint32, blockTotalLength uint32, buf []byte, err error) (interface{}, error) {
+	pr.stats.CorruptBlocks++
+	if !pr.Recover {
+		return nil, err
+	}
+	pr.warn(&PcapError{fmt.Sprintf("offset 0x%08x: block type 0x%08x: %v", pr.metadata.Offset, blockType, err)})
+	return &GenericBlock{blockType, blockTotalLength, buf}, nil
+}
@@ -0,0 +1,137 @@
+package pcapng
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+func TestForensicResync(t *testing.T) {
+
+	packet := func(b byte) []byte {
+		data := bytes.Repeat([]byte{b}, 60)
+		epb := &EnhancedPacketBlock{CapturedPacketLength: 60, OriginalPacketLength: 60, PacketData: data}
+		buf, err := epb.Pack(binary.LittleEndian)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return buf
+	}
+	idb := rawBlock(INTERFACE_DESCRIPTION_BLOCK, rawIDB())
+
+	// an Enhanced Packet Block whose trailing length is wrong
+	badTrailer := packet(3)
+	badTrailer[len(badTrailer)-1] = 0x7f
+	// one whose captured length exceeds the block
+	badCaptured := packet(4)
+	binary.LittleEndian.PutUint32(badCaptured[20:24], 1000)
+
+	tests := []struct {
+		name    string
+		corrupt []byte
+		packets int // read by the forensic preset
+		generic int
+		skipped bool
+		lenient bool // read to the end without Recover
+	}{
+		{"garbage", bytes.Repeat([]byte{0xee}, 16), 2, 0, true, false},
+		{"huge length", []byte{6, 0, 0, 0, 0xf0, 0xff, 0xff, 0xff, 0, 0, 0, 0}, 2, 0, true, false},
+		{"bad trailing length", badTrailer, 2, 0, true, true},
+		{"captured length beyond block", badCaptured, 2, 1, false, false},
+	}
+
+	for _, tt := range tests {
+		data := withSection(t, idb, packet(1), tt.corrupt, packet(2))
+
+		pr := ReaderWithPreset(bytes.NewReader(data), PresetForensic)
+		packets, generic := 0, 0
+		for {
+			b, err := pr.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%v: forensic Read: %v", tt.name, err)
+			}
+			switch b.(type) {
+			case *EnhancedPacketBlock:
+				packets++
+			case *GenericBlock:
+				generic++
+			}
+		}
+		stats := pr.Stats()
+		if packets != tt.packets || generic != tt.generic {
+			t.Errorf("%v: read %v packets and %v generic blocks, want %v and %v", tt.name, packets, generic, tt.packets, tt.generic)
+		}
+		if (stats.SkippedBytes > 0) != tt.skipped {
+			t.Errorf("%v: skipped %v bytes, want skipping %v", tt.name, stats.SkippedBytes, tt.skipped)
+		}
+		if len(pr.Warnings) == 0 || stats.Clean() {
+			t.Errorf("%v: nothing recorded, stats %v", tt.name, stats)
+		}
+
+		pr = Reader(bytes.NewReader(data))
+		pr.MaxBlock = 1 << 16
+		var err error
+		for err == nil {
+			_, err = pr.Read()
+		}
+		if (err == io.EOF) != tt.lenient {
+			t.Errorf("%v: lenient Read ended with %v, want reading to the end %v", tt.name, err, tt.lenient)
+		}
+	}
+}
+
+func TestForensicTruncated(t *testing.T) {
+
+	idb := rawBlock(INTERFACE_DESCRIPTION_BLOCK, rawIDB())
+	epb := rawBlock(ENHANCED_PACKET_BLOCK, make([]byte, 20))
+	data := withSection(t, idb, epb)
+
+	for cut := len(data) - len(epb); cut < len(data); cut++ {
+		pr := ReaderWithPreset(bytes.NewReader(data[:cut]), PresetForensic)
+		var err error
+		for err == nil {
+			_, err = pr.Read()
+		}
+		if err != io.EOF {
+			t.Errorf("cut at %v: %v, want io.EOF", cut, err)
+		}
+	}
+}
+
+func TestMaxWarnings(t *testing.T) {
+
+	var blocks [][]byte
+	for i := 0; i < 10; i++ {
+		blocks = append(blocks, rawBlock(0x7fff0000, nil)) // unknown, a warning each
+	}
+	data := withSection(t, blocks...)
+
+	tests := []struct {
+		max  int
+		kept int
+		lost int
+	}{
+		{0, 10, 0},
+		{3, 3, 7},
+		{10, 10, 0},
+	}
+
+	for _, tt := range tests {
+		pr := Reader(bytes.NewReader(data))
+		pr.MaxWarnings = tt.max
+		var err error
+		for err == nil {
+			_, err = pr.Read()
+		}
+		if err != io.EOF {
+			t.Fatalf("max %v: %v", tt.max, err)
+		}
+		if len(pr.Warnings) != tt.kept || pr.Stats().LostWarnings != tt.lost || pr.Stats().UnknownBlocks != 10 {
+			t.Errorf("max %v: kept %v warnings and lost %v, want %v and %v", tt.max, len(pr.Warnings), pr.Stats().LostWarnings, tt.kept, tt.lost)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package pcapng
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// startSection records where the section just read ends, if its Section
+// Length is known and the input can tell its position.
+func (pr *PcapngReader) startSection(sectionLength int64) {
+	pr.sectionEnd = -1
+	if sectionLength < 0 {
+		return
+	}
+	if s, ok := pr.fh.(io.Seeker); ok {
+		if pos, err := s.Seek(0, io.SeekCurrent); err == nil {
+			pr.sectionEnd = pos + sectionLength
+		}
+	}
+}
+
+// startRawSection starts a section from the bytes of its Section Header
+// Block. It returns the error of checkVersion.
+func (pr *PcapngReader) startRawSection(buf []byte) error {
+	if len(buf) < 24 {
+		return nil
+	}
+	if err := pr.checkVersion(pr.Endian.Uint16(buf[12:14]), pr.Endian.Uint16(buf[14:16])); err != nil {
+		return err
+	}
+	pr.startSection(int64(pr.Endian.Uint64(buf[16:24])))
+	return nil
+}
+
+// SkipSection seeks past the rest of the current section, so the next Read
+// returns the following Section Header Block or io.EOF. It needs a seekable
+// input and a Section Header Block with a Section Length other than -1;
+// otherwise it returns an error and the caller has to read the blocks.
+func (pr *PcapngReader) SkipSection() error {
+	if pr.sectionEnd < 0 {
+		return &PcapError{"the length of the current section is unknown or the input cannot seek"}
+	}
+	s := pr.fh.(io.Seeker)
+	pos, err := s.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := s.Seek(pr.sectionEnd, io.SeekStart); err != nil {
+		return err
+	}
+	pr.offset += pr.sectionEnd - pos
+	pr.sectionEnd = -1
+	return nil
+}
+
+// SectionEntry locates one section within a file.
+type SectionEntry struct {
+	Offset int64 // of the Section Header Block
+	Length int64 // from the Section Header Block to the end of the section
+	Endian binary.ByteOrder
+}
+
+// Sections lists the sections in the first size bytes of r. Sections with
+// a known Section Length are jumped over in one step, the others are walked
+// block by block.
+func Sections(r io.ReaderAt, size int64) ([]SectionEntry, error) {
+
+	var sections []SectionEntry
+	hdr := make([]byte, 24)
+
+	for offset := int64(0); offset < size; {
+		if _, err := r.ReadAt(hdr, offset); err != nil {
+			return nil, err
+		}
+
+		var endian binary.ByteOrder
+		switch binary.LittleEndian.Uint32(hdr[8:12]) {
+		case MagicNumber:
+			endian = binary.LittleEndian
+		case SwapMagicNumber:
+			endian = binary.BigEndian
+		default:
+			return nil, &PcapError{fmt.Sprintf("Bad Magic Number at offset %v", offset)}
+		}
+		if endian.Uint32(hdr[0:4]) != SECTION_HEADER_BLOCK {
+			return nil, &PcapError{fmt.Sprintf("expected a Section Header Block at offset %v", offset)}
+		}
+		shbLength := int64(endian.Uint32(hdr[4:8]))
+		sectionLength := int64(endian.Uint64(hdr[16:24]))
+		if shbLength < 28 || shbLength&3 != 0 {
+			return nil, &PcapError{fmt.Sprintf("bad Section Header Block length %v at offset %v", shbLength, offset)}
+		}
+
+		end := offset + shbLength + sectionLength
+		if sectionLength < 0 || end > size || end < offset {
+			// walk the blocks to the next Section Header Block
+			var err error
+			if end, err = nextSection(r, offset+shbLength, size, endian); err != nil {
+				return nil, err
+			}
+		}
+
+		if end <= offset {
+			return nil, &PcapError{fmt.Sprintf("section at offset %v does not advance", offset)}
+		}
+		sections = append(sections, SectionEntry{offset, end - offset, endian})
+		offset = end
+	}
+	return sections, nil
+}
+
+// Reader returns a PcapngReader over just this section of r. Offsets in
+// its Metadata are relative to the start of the section.
+func (s SectionEntry) Reader(r io.ReaderAt) *PcapngReader {
+	return Reader(io.NewSectionReader(r, s.Offset, s.Length))
+}
+
+// SectionReaders returns an independent reader for each section in the
+// first size bytes of r, so sections can be processed concurrently. The
+// readers share r, which must allow concurrent ReadAt calls as *os.File does.
+func SectionReaders(r io.ReaderAt, size int64) ([]*PcapngReader, error) {
+
+	sections, err := Sections(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	readers := make([]*PcapngReader, len(sections))
+	for i, s := range sections {
+		readers[i] = s.Reader(r)
+	}
+	return readers, nil
+}
+
+// nextSection returns the offset of the first Section Header Block at or
+// after offset, or size if there is none.
+func nextSection(r io.ReaderAt, offset int64, size int64, endian binary.ByteOrder) (int64, error) {
+
+	hdr := make([]byte, 8)
+	for offset < size {
+		if _, err := r.ReadAt(hdr, offset); err != nil {
+			return 0, err
+		}
+		// the block type of a Section Header Block reads the same in either byte order
+		if binary.LittleEndian.Uint32(hdr[0:4]) == SECTION_HEADER_BLOCK {
+			return offset, nil
+		}
+		totalLength := endian.Uint32(hdr[4:8])
+		if totalLength < 12 || totalLength&3 != 0 {
+			return 0, &PcapError{fmt.Sprintf("bad block length %v at offset %v", totalLength, offset)}
+		}
+		offset += int64(totalLength)
+	}
+	return size, nil
+}
+
+// SplitSections copies each section of the first size bytes of r, byte for
+// byte, to the writer create returns for it (0 based). The writers are
+// closed when their section has been copied.
+func SplitSections(r io.ReaderAt, size int64, create func(section int) (io.WriteCloser, error)) error {
+
+	sections, err := Sections(r, size)
+	if err != nil {
+		return err
+	}
+
+	for i, s := range sections {
+		w, err := create(i)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(w, io.NewSectionReader(r, s.Offset, s.Length)); err != nil {
+			w.Close()
+			return err
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package pcapng
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestSections(t *testing.T) {
+
+	idb := rawBlock(INTERFACE_DESCRIPTION_BLOCK, rawIDB())
+	section := func(endian binary.ByteOrder, length int64, blocks ...[]byte) []byte {
+		var buf bytes.Buffer
+		pw := Writer(&buf)
+		pw.Endian = endian
+		if err := pw.Write(&SectionBlock{MajorVersion: 1, SectionLength: length}); err != nil {
+			t.Fatal(err)
+		}
+		for _, b := range blocks {
+			buf.Write(b)
+		}
+		return buf.Bytes()
+	}
+	join := func(parts ...[]byte) []byte {
+		return bytes.Join(parts, nil)
+	}
+	le := section(binary.LittleEndian, -1, idb, idb)
+	known := section(binary.LittleEndian, int64(len(idb)), idb)
+	wrong := section(binary.LittleEndian, 1000, idb) // walked instead
+	be := section(binary.BigEndian, -1)
+	zero := append([]byte(nil), le...)
+	binary.LittleEndian.PutUint32(zero[4:8], 0)
+	badMagic := append([]byte(nil), le...)
+	binary.LittleEndian.PutUint32(badMagic[8:12], 0x01020304)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		lengths []int // of the sections, nil for an error
+	}{
+		{"one", le, []int{len(le)}},
+		{"known length", join(known, le), []int{len(known), len(le)}},
+		{"wrong length", join(wrong, known), []int{len(wrong), len(known)}},
+		{"byte orders", join(le, be, le), []int{len(le), len(be), len(le)}},
+		{"SHB length 0", zero, nil},
+		{"no SHB", idb, nil},
+		{"bad magic", join(le, badMagic), nil},
+		{"truncated", le[:20], nil},
+	}
+
+	for _, tt := range tests {
+		sections, err := Sections(bytes.NewReader(tt.data), int64(len(tt.data)))
+		if tt.lengths == nil {
+			if err == nil {
+				t.Errorf("%v: Sections = %v, want an error", tt.name, sections)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: %v", tt.name, err)
+			continue
+		}
+		if len(sections) != len(tt.lengths) {
+			t.Errorf("%v: %v sections, want %v", tt.name, len(sections), len(tt.lengths))
+			continue
+		}
+		offset := int64(0)
+		for i, s := range sections {
+			if s.Offset != offset || s.Length != int64(tt.lengths[i]) {
+				t.Errorf("%v: section %v at %v of %v bytes, want %v of %v", tt.name, i, s.Offset, s.Length, offset, tt.lengths[i])
+			}
+			offset += s.Length
+		}
+	}
+}
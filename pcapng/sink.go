@@ -0,0 +1,126 @@
+package pcapng
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// PacketMeta describes a packet independently of the file format.
+type PacketMeta struct {
+	Timestamp      time.Time
+	InterfaceID    uint32
+	OriginalLength int    // length on the wire, 0 means the captured length
+	Flags          uint32 // epb_flags, 0 if unknown
+}
+
+// Direction returns DirectionUnknown, DirectionInbound or DirectionOutbound.
+func (m PacketMeta) Direction() uint32 {
+	return m.Flags & 3
+}
+
+// PacketSink is implemented by everything packets can be written to, so
+// capture code does not depend on the persistence backend.
+type PacketSink interface {
+	WritePacket(meta PacketMeta, data []byte) error
+	Flush() error
+	Close() error
+}
+
+// WritePacket writes data as an Enhanced Packet Block. The interface must
+// already have been written in the current section.
+func (pw *PcapngWriter) WritePacket(meta PacketMeta, data []byte) error {
+
+	if int(meta.InterfaceID) >= len(pw.interfaces) {
+		return &PcapError{fmt.Sprintf("packet references interface %v but only %v have been written", meta.InterfaceID, len(pw.interfaces))}
+	}
+
+	originalLength := meta.OriginalLength
+	if originalLength == 0 {
+		originalLength = len(data)
+	}
+
+	ifb := pw.interfaces[meta.InterfaceID]
+	if pw.CheckTimestamps != nil && beforeTsoffset(ifb, meta.Timestamp) {
+		// it would wrap around to the far future
+		return &PcapError{fmt.Sprintf("interface %v: timestamp %v is before the if_tsoffset of the interface", meta.InterfaceID, meta.Timestamp.UTC())}
+	}
+	high, low := ifb.SplitPacketTime(meta.Timestamp, pw.Rounding)
+	b := &EnhancedPacketBlock{
+		InterfaceID:          meta.InterfaceID,
+		TimestampHigh:        high,
+		TimestampLow:         low,
+		CapturedPacketLength: uint32(len(data)),
+		OriginalPacketLength: uint32(originalLength),
+		PacketData:           data,
+	}
+	if meta.Flags != 0 {
+		b.WithFlags(meta.Flags)
+	}
+	return pw.Write(b)
+}
+
+// InterfaceID returns the ID of the interface written in the current
+// section with the if_name name.
+func (pw *PcapngWriter) InterfaceID(name string) (uint32, bool) {
+	for id, ifb := range pw.interfaces {
+		if ifb.Name() == name {
+			return uint32(id), true
+		}
+	}
+	return 0, false
+}
+
+// GetOrAddInterface returns the ID of the interface named name in the
+// current section, first writing an Interface Description Block with a nano
+// second resolution for it if there is none. The Section Header Block
+// must already have been written. An existing interface with another link
+// type is an error.
+func (pw *PcapngWriter) GetOrAddInterface(name string, linkType uint16, snapLen uint32) (uint32, error) {
+
+	if id, ok := pw.InterfaceID(name); ok {
+		if pw.interfaces[id].LinkType != linkType {
+			return 0, &PcapError{fmt.Sprintf("interface %v %q has link type %v, not %v", id, name, pw.interfaces[id].LinkType, linkType)}
+		}
+		return id, nil
+	}
+
+	ifb := &InterfaceBlock{
+		LinkType: linkType,
+		SnapLen:  snapLen,
+		Options: []Option{
+			&If_Name{name},
+			&If_Tsresol{9},
+		},
+	}
+	if err := pw.Write(ifb); err != nil {
+		return 0, err
+	}
+	return uint32(len(pw.interfaces) - 1), nil
+}
+
+// Flush flushes the underlying writer if it buffers, e.g. a *bufio.Writer.
+func (pw *PcapngWriter) Flush() error {
+	if f, ok := pw.fh.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// Close writes the drop statistics and any held back names, flushes and closes the underlying
+// writer if it is an io.Closer.
+func (pw *PcapngWriter) Close() error {
+	if err := pw.WriteDropStatistics(); err != nil {
+		return err
+	}
+	if err := pw.WritePendingNames(); err != nil {
+		return err
+	}
+	if err := pw.Flush(); err != nil {
+		return err
+	}
+	if c, ok := pw.fh.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package pcapng
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Streamer is implemented by blocks that can write themselves without
+// first packing the whole block into memory. Write uses it when available.
+type Streamer interface {
+	StreamTo(w io.Writer, endian binary.ByteOrder) (int64, error)
+}
+
+// blockWriterTo adapts a block to io.WriterTo.
+type blockWriterTo struct {
+	block  Block
+	endian binary.ByteOrder
+}
+
+func (bw *blockWriterTo) WriteTo(w io.Writer) (int64, error) {
+	if s, ok := bw.block.(Streamer); ok {
+		return s.StreamTo(w, bw.endian)
+	}
+	buf, err := bw.block.Pack(bw.endian)
+	if err != nil {
+		return 0, err
+	}
+	return writeAll(w, buf)
+}
+
+// WriterTo returns an io.WriterTo writing b in the given byte order, so
+// blocks can be passed to io.Copy and similar.
+func WriterTo(b Block, endian binary.ByteOrder) io.WriterTo {
+	return &blockWriterTo{b, endian}
+}
+
+// writeAll writes each buffer in turn and returns the number of bytes written.
+func writeAll(w io.Writer, bufs ...[]byte) (int64, error) {
+	var total int64
+	for _, buf := range bufs {
+		n, err := w.Write(buf)
+		total += int64(n)
+		if err != nil {
+			return total, err
+		} else if n != len(buf) {
+			return total, &PcapError{fmt.Sprintf("wrote %v bytes expected %v\n", n, len(buf))}
+		}
+	}
+	return total, nil
+}
+
+// tail returns the padding after a body of length bytes, the packed
+// options and the trailing Block Total Length.
+func tail(length int, options []byte, blockTotalLength uint32, endian binary.ByteOrder) []byte {
+	padding := (4 - (length & 3)) & 3
+	buf := make([]byte, padding+len(options)+4)
+	copy(buf[padding:], options)
+	endian.PutUint32(buf[padding+len(options):], blockTotalLength)
+	return buf
+}
+
+// StreamTo writes the block header, the packet data and the options
+// without copying the packet data.
+func (b *EnhancedPacketBlock) StreamTo(w io.Writer, endian binary.ByteOrder) (int64, error) {
+
+	options, err := packOptions(b.Options, endian)
+	if err != nil {
+		return 0, err
+	}
+
+	padding := (4 - (len(b.PacketData) & 3)) & 3
+	blockTotalLength := uint32(32 + len(b.PacketData) + padding + len(options))
+
+	hdr := make([]byte, 28)
+	endian.PutUint32(hdr[0:], ENHANCED_PACKET_BLOCK)      // Block Type
+	endian.PutUint32(hdr[4:], blockTotalLength)           // Block Total Length
+	endian.PutUint32(hdr[8:], b.InterfaceID)              // Interface ID
+	endian.PutUint32(hdr[12:], b.TimestampHigh)           // Timestamp (High)
+	endian.PutUint32(hdr[16:], b.TimestampLow)            // Timestamp (Low)
+	endian.PutUint32(hdr[20:], uint32(len(b.PacketData))) // Captured Packet Length
+	endian.PutUint32(hdr[24:], b.OriginalPacketLength)    // Original Packet Length
+
+	return writeAll(w, hdr, b.PacketData, tail(len(b.PacketData), options, blockTotalLength, endian))
+}
+
+// StreamTo writes the block header, the secrets and the options without
+// copying the secrets.
+func (b *DecryptionSecretsBlock) StreamTo(w io.Writer, endian binary.ByteOrder) (int64, error) {
+
+	options, err := packOptions(b.Options, endian)
+	if err != nil {
+		return 0, err
+	}
+
+	padding := (4 - (len(b.SecretsData) & 3)) & 3
+	blockTotalLength := uint32(20 + len(b.SecretsData) + padding + len(options))
+
+	hdr := make([]byte, 16)
+	endian.PutUint32(hdr[0:], DECRYPTION_SECRETS_BLOCK)    // Block Type
+	endian.PutUint32(hdr[4:], blockTotalLength)            // Block Total Length
+	endian.PutUint32(hdr[8:], b.SecretsType)               // Secrets Type
+	endian.PutUint32(hdr[12:], uint32(len(b.SecretsData))) // Secrets Length
+
+	return writeAll(w, hdr, b.SecretsData, tail(len(b.SecretsData), options, blockTotalLength, endian))
+}
+
+// StreamTo writes the raw bytes of the block.
+func (b *GenericBlock) StreamTo(w io.Writer, endian binary.ByteOrder) (int64, error) {
+	return writeAll(w, b.Data)
+}
@@ -0,0 +1,112 @@
+package pcapng
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// TimestampChecker flags packet timestamps that suggest a broken capture
+// source: timestamps that go backwards on an interface, that lie in the
+// far future, or that come before the interface's if_tsoffset.
+type TimestampChecker struct {
+	MaxFuture time.Duration    // how far past Now a timestamp may be
+	Now       func() time.Time // nil means time.Now
+
+	last map[*InterfaceBlock]time.Time
+}
+
+// NewTimestampChecker returns a TimestampChecker allowing timestamps up to
+// a day in the future, for clocks in other time zones gone wrong.
+func NewTimestampChecker() *TimestampChecker {
+	return &TimestampChecker{MaxFuture: 24 * time.Hour}
+}
+
+// beforeTsoffset reports whether t cannot be written for ifb because the
+// timestamps of the interface count from its if_tsoffset.
+func beforeTsoffset(ifb *InterfaceBlock, t time.Time) bool {
+	offset := ifb.Tsoffset()
+	return offset != 0 && t.Before(time.Unix(offset, 0))
+}
+
+// Check returns the problem with the timestamp t of a packet of ifb, or
+// nil if there is none. Going backwards is a warning, the others errors.
+func (tc *TimestampChecker) Check(ifb *InterfaceBlock, t time.Time) *Problem {
+
+	if tc.last == nil {
+		tc.last = make(map[*InterfaceBlock]time.Time)
+	}
+	now := time.Now
+	if tc.Now != nil {
+		now = tc.Now
+	}
+
+	p := &Problem{BlockType: ENHANCED_PACKET_BLOCK}
+	last, seen := tc.last[ifb]
+	switch {
+	case beforeTsoffset(ifb, t):
+		p.Message = fmt.Sprintf("timestamp %v is before the if_tsoffset of the interface", t.UTC())
+	case t.After(now().Add(tc.MaxFuture)):
+		p.Message = fmt.Sprintf("timestamp %v is in the future", t.UTC())
+	case seen && t.Before(last):
+		p.Warning = true
+		p.Message = fmt.Sprintf("timestamp %v goes back %v", t.UTC(), last.Sub(t))
+	default:
+		p = nil
+	}
+
+	if !seen || t.After(last) {
+		tc.last[ifb] = t
+	}
+	return p
+}
+
+// CheckTimestamps reads r and reports the problems tc finds with the
+// timestamps of its Enhanced Packet Blocks. A nil tc uses a new
+// TimestampChecker.
+func CheckTimestamps(r io.Reader, tc *TimestampChecker) ([]Problem, error) {
+
+	if tc == nil {
+		tc = NewTimestampChecker()
+	}
+	pr := Reader(r)
+	pr.Keep = PacketBlocks
+
+	var problems []Problem
+	for {
+		block, err := pr.Read()
+		if err == io.EOF {
+			return problems, nil
+		} else if err != nil {
+			return problems, err
+		}
+
+		b, ok := block.(*EnhancedPacketBlock)
+		if !ok {
+			continue
+		}
+		m := pr.Metadata()
+		if m.Interface == nil {
+			problems = append(problems, Problem{m.Offset, ENHANCED_PACKET_BLOCK, false, fmt.Sprintf("packet %v references undefined interface %v", m.Packet, b.InterfaceID)})
+			continue
+		}
+		ifb := m.Interface.InterfaceBlock
+		if p := tc.Check(ifb, ifb.PacketTime(b.TimestampHigh, b.TimestampLow)); p != nil {
+			p.Offset = m.Offset
+			p.Message = fmt.Sprintf("packet %v on interface %v: %v", m.Packet, b.InterfaceID, p.Message)
+			problems = append(problems, *p)
+		}
+	}
+}
+
+// checkTimestamp returns an error for a packet CheckTimestamps flags.
+func (pw *PcapngWriter) checkTimestamp(b *EnhancedPacketBlock) error {
+	if pw.CheckTimestamps == nil || int(b.InterfaceID) >= len(pw.interfaces) {
+		return nil
+	}
+	ifb := pw.interfaces[b.InterfaceID]
+	if p := pw.CheckTimestamps.Check(ifb, ifb.PacketTime(b.TimestampHigh, b.TimestampLow)); p != nil {
+		return &PcapError{fmt.Sprintf("interface %v: %v", b.InterfaceID, p.Message)}
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package pcapng
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"time"
+)
+
+// DefaultTsresol is the if_tsresol value assumed when an interface does not
+// carry the option: 10^-6 seconds.
+const DefaultTsresol = 6
+
+// Tsresol returns the if_tsresol option of the interface or DefaultTsresol
+// if the option is absent.
+func (b *InterfaceBlock) Tsresol() uint8 {
+	for _, opt := range b.Options {
+		if o, ok := opt.(*If_Tsresol); ok {
+			return o.Value
+		}
+	}
+	return DefaultTsresol
+}
+
+// MaxTsresol is the finest decimal if_tsresol whose ticks per second fit in
+// 64 bits.
+const MaxTsresol = 19
+
+// ValidTsresol reports whether TicksPerSecond can represent tsresol: up to
+// 10^-19 or 2^-63 seconds.
+func ValidTsresol(tsresol uint8) bool {
+	if tsresol&0x80 != 0 {
+		return tsresol&0x7f < 64
+	}
+	return tsresol <= MaxTsresol
+}
+
+// TicksPerSecond returns the number of timestamp units per second for an
+// if_tsresol value. If the most significant bit is set the remaining bits
+// are a negative power of 2, otherwise a negative power of 10. tsresol
+// must be valid, see ValidTsresol.
+func TicksPerSecond(tsresol uint8) uint64 {
+	if tsresol&0x80 != 0 {
+		return uint64(1) << (tsresol & 0x7f)
+	}
+	return uint64(math.Pow10(int(tsresol)))
+}
+
+// Timestamp converts the two 32 bit halves of a pcapng timestamp into a
+// time.Time, truncating resolutions finer than a nano second.
+func Timestamp(high, low uint32, tsresol uint8) time.Time {
+	return TimestampRounding(high, low, tsresol, Truncate)
+}
+
+// TimestampRounding is Timestamp rounding to the nearest nano second as r selects.
+func TimestampRounding(high, low uint32, tsresol uint8, r Rounding) time.Time {
+	ticks := uint64(high)<<32 | uint64(low)
+	perSecond := TicksPerSecond(tsresol)
+
+	sec := ticks / perSecond
+	frac := ticks % perSecond
+	return time.Unix(int64(sec), int64(scale(frac, 1000000000, perSecond, r)))
+}
+
+// SplitTimestamp converts a time.Time into the two 32 bit halves of a
+// pcapng timestamp, truncating to the resolution.
+func SplitTimestamp(t time.Time, tsresol uint8) (high, low uint32) {
+	return SplitTimestampRounding(t, tsresol, Truncate)
+}
+
+// SplitTimestampRounding is SplitTimestamp rounding to the resolution as r selects.
+func SplitTimestampRounding(t time.Time, tsresol uint8, r Rounding) (high, low uint32) {
+	perSecond := TicksPerSecond(tsresol)
+
+	// a fraction that rounds up to a whole second carries into the seconds
+	ticks := uint64(t.Unix())*perSecond + scale(uint64(t.Nanosecond()), perSecond, 1000000000, r)
+	return uint32(ticks >> 32), uint32(ticks)
+}
+
+// scale returns v*mul/div rounded as r selects without overflowing the
+// intermediate product. v must be less than div.
+func scale(v, mul, div uint64, r Rounding) uint64 {
+	hi, lo := bits.Mul64(v, mul)
+	quo, rem := bits.Div64(hi, lo, div)
+	return r.round(quo, rem, div)
+}
+
+// Rounding selects what happens to the digits that are lost when a
+// timestamp is converted to a coarser resolution.
+type Rounding int
+
+const (
+	Truncate      Rounding = iota // drop them
+	RoundHalfUp                   // round to the nearest value, halves away from zero
+	RoundHalfEven                 // round to the nearest value, halves to the even one
+)
+
+// round returns the quotient quo of a division by div with remainder rem
+// rounded as r selects.
+func (r Rounding) round(quo, rem, div uint64) uint64 {
+	switch r {
+	case RoundHalfUp:
+		if rem >= div-rem {
+			quo++
+		}
+	case RoundHalfEven:
+		if rem > div-rem || (rem == div-rem && quo&1 == 1) {
+			quo++
+		}
+	}
+	return quo
+}
+
+// RoundFloat rounds f to a whole number as r selects.
+func (r Rounding) RoundFloat(f float64) float64 {
+	switch r {
+	case RoundHalfUp:
+		return math.Round(f)
+	case RoundHalfEven:
+		return math.RoundToEven(f)
+	}
+	return math.Trunc(f)
+}
+
+// ParseRounding parses "truncate", "up" or "even".
+func ParseRounding(s string) (Rounding, error) {
+	switch s {
+	case "truncate":
+		return Truncate, nil
+	case "up":
+		return RoundHalfUp, nil
+	case "even":
+		return RoundHalfEven, nil
+	}
+	return Truncate, &PcapError{fmt.Sprintf("unknown rounding %q, expected truncate, up or even", s)}
+}
+
+// ConvertTicks converts a timestamp from one if_tsresol to another.
+func ConvertTicks(ticks uint64, from, to uint8, r Rounding) uint64 {
+
+	fromPerSecond := TicksPerSecond(from)
+	toPerSecond := TicksPerSecond(to)
+
+	sec := ticks / fromPerSecond
+	frac := ticks % fromPerSecond
+
+	hi, lo := bits.Mul64(frac, toPerSecond)
+	quo, rem := bits.Div64(hi, lo, fromPerSecond)
+	return sec*toPerSecond + r.round(quo, rem, fromPerSecond)
+}
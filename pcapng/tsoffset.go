@@ -0,0 +1,208 @@
+package pcapng
+
+import (
+	"fmt"
+	"time"
+)
+
+// Tsoffset returns the if_tsoffset option of the interface in seconds, or 0
+// if the option is absent.
+func (b *InterfaceBlock) Tsoffset() int64 {
+	for _, opt := range b.Options {
+		if o, ok := opt.(*If_Tsoffset); ok {
+			return o.Value
+		}
+	}
+	return 0
+}
+
+// PacketTime converts a packet timestamp of the interface into a
+// time.Time, applying both if_tsresol and if_tsoffset.
+func (b *InterfaceBlock) PacketTime(high, low uint32) time.Time {
+	return Timestamp(high, low, b.Tsresol()).Add(time.Duration(b.Tsoffset()) * time.Second)
+}
+
+// SplitPacketTime is the reverse of PacketTime, rounding to the resolution
+// as r selects.
+func (b *InterfaceBlock) SplitPacketTime(t time.Time, r Rounding) (high, low uint32) {
+	return SplitTimestampRounding(t.Add(-time.Duration(b.Tsoffset())*time.Second), b.Tsresol(), r)
+}
+
+// withTsoffset returns a copy of the interface with an if_tsoffset of the
+// capture start, and the seconds to take off its packet timestamps. An
+// interface that already has the option, or written before the start is
+// known, is returned as it is.
+func (pw *PcapngWriter) withTsoffset(b *InterfaceBlock) (*InterfaceBlock, int64) {
+	for _, opt := range b.Options {
+		if _, ok := opt.(*If_Tsoffset); ok {
+			return b, 0
+		}
+	}
+	start := pw.CaptureStart
+	if start.IsZero() {
+		start = pw.start
+	}
+	if start.Unix() <= 0 {
+		return b, 0
+	}
+
+	ifb := *b
+	ifb.Options = append(append([]Option(nil), b.Options...), &If_Tsoffset{start.Unix()})
+	return &ifb, start.Unix()
+}
+
+// maxHeldPackets is the number of packets CompressTimestamps holds back
+// at most while it waits for a packet of every interface.
+const maxHeldPackets = 1000
+
+// heldBlock is a block written before CompressTimestamps knew its capture
+// start.
+type heldBlock struct {
+	b     Block
+	iface int // the interface ID of an Interface Description Block, -1 for other blocks
+}
+
+// hold holds back the blocks after a held interface, other than those
+// that end the wait, so the order is kept.
+func (pw *PcapngWriter) hold(b Block) bool {
+	if len(pw.held) == 0 {
+		return false
+	}
+	switch b.(type) {
+	case *SectionBlock, *InterfaceBlock, *EnhancedPacketBlock:
+		return false
+	}
+	pw.held = append(pw.held, heldBlock{b, -1})
+	return true
+}
+
+// holdPacket holds back a copy of a checked packet, the caller may reuse
+// its data. Once every held interface has a packet, or maxHeldPackets are
+// held, the held blocks are written.
+func (pw *PcapngWriter) holdPacket(b *EnhancedPacketBlock) error {
+	epb := *b
+	epb.PacketData = append([]byte(nil), b.PacketData...)
+	epb.Options = append([]Option(nil), b.Options...)
+	pw.held = append(pw.held, heldBlock{&epb, -1})
+
+	packets := 0
+	seen := make(map[uint32]bool)
+	for _, h := range pw.held {
+		if epb, ok := h.b.(*EnhancedPacketBlock); ok {
+			packets++
+			seen[epb.InterfaceID] = true
+		}
+	}
+	if packets < maxHeldPackets {
+		for _, h := range pw.held {
+			if h.iface >= 0 && !seen[uint32(h.iface)] {
+				return nil
+			}
+		}
+	}
+	return pw.release()
+}
+
+// heldTimes returns the earliest time of the held packets and statistics
+// blocks of each interface.
+func (pw *PcapngWriter) heldTimes() map[uint32]time.Time {
+	times := make(map[uint32]time.Time)
+	at := func(id uint32, high, low uint32) {
+		if int(id) < len(pw.interfaces) {
+			t := pw.interfaces[id].PacketTime(high, low)
+			if first, ok := times[id]; !ok || t.Before(first) {
+				times[id] = t
+			}
+		}
+	}
+	for _, h := range pw.held {
+		switch b := h.b.(type) {
+		case *EnhancedPacketBlock:
+			at(b.InterfaceID, b.TimestampHigh, b.TimestampLow)
+		case *InterfaceStatisticsBlock:
+			at(b.InterfaceID, b.TimestampHigh, b.TimestampLow)
+		}
+	}
+	return times
+}
+
+// release writes the held blocks. The capture start is the earliest time
+// of all held packets and statistics blocks, interfaces without one get
+// no if_tsoffset so none of their timestamps can be before it.
+func (pw *PcapngWriter) release() error {
+	times := pw.heldTimes()
+	if pw.CaptureStart.IsZero() {
+		for _, t := range times {
+			if pw.start.IsZero() || t.Before(pw.start) {
+				pw.start = t
+			}
+		}
+	}
+
+	held := pw.held
+	pw.held = nil
+	for _, h := range held {
+		if h.iface < 0 {
+			var err error
+			if epb, ok := h.b.(*EnhancedPacketBlock); ok {
+				err = pw.writePacket(epb)
+			} else {
+				err = pw.Write(h.b)
+			}
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		b, offset := h.b.(*InterfaceBlock), int64(0)
+		if _, ok := times[uint32(h.iface)]; ok {
+			b, offset = pw.withTsoffset(b)
+		}
+		pw.offsets[h.iface] = offset
+		if err := pw.write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writePacket writes a checked packet.
+func (pw *PcapngWriter) writePacket(b *EnhancedPacketBlock) error {
+	b, err := pw.rebase(b)
+	if err != nil {
+		return err
+	}
+	if pw.Metrics != nil {
+		pw.Metrics.AddPackets(1)
+	}
+	return pw.write(pw.withDropCount(b))
+}
+
+// rebase returns a copy of the packet with its timestamp made relative to
+// the if_tsoffset the writer gave its interface.
+func (pw *PcapngWriter) rebase(b *EnhancedPacketBlock) (*EnhancedPacketBlock, error) {
+	high, low, err := pw.relative(b.InterfaceID, b.TimestampHigh, b.TimestampLow)
+	if err != nil || (high == b.TimestampHigh && low == b.TimestampLow) {
+		return b, err
+	}
+	epb := *b
+	epb.TimestampHigh, epb.TimestampLow = high, low
+	return &epb, nil
+}
+
+// relative makes a timestamp of the interface relative to the if_tsoffset
+// the writer gave it.
+func (pw *PcapngWriter) relative(interfaceID uint32, high, low uint32) (uint32, uint32, error) {
+	id := int(interfaceID)
+	if id >= len(pw.offsets) || pw.offsets[id] == 0 {
+		return high, low, nil
+	}
+
+	ticks := uint64(high)<<32 | uint64(low)
+	sub := uint64(pw.offsets[id]) * TicksPerSecond(pw.interfaces[id].Tsresol())
+	if ticks < sub {
+		return 0, 0, &PcapError{fmt.Sprintf("timestamp on interface %v is older than the capture start %v", id, time.Unix(pw.offsets[id], 0).UTC())}
+	}
+	ticks -= sub
+	return uint32(ticks >> 32), uint32(ticks), nil
+}
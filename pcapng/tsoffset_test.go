@@ -0,0 +1,95 @@
+package pcapng
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestCompressTimestamps(t *testing.T) {
+
+	start := time.Date(2026, 10, 16, 12, 0, 0, 500e6, time.UTC)
+	type pkt struct {
+		id uint32
+		at time.Duration // after start
+	}
+
+	tests := []struct {
+		name       string
+		interfaces int
+		packets    []pkt
+		offsets    []bool // whether each interface gets an if_tsoffset
+	}{
+		{"one interface", 1, []pkt{{0, 0}, {0, time.Second}}, []bool{true}},
+		{"other interface earlier", 2, []pkt{{0, 0}, {1, -5 * time.Second}, {0, time.Second}, {1, -4 * time.Second}}, []bool{true, true}},
+		{"interface without packets", 2, []pkt{{0, 0}}, []bool{true, false}},
+		{"no packets", 2, nil, []bool{false, false}},
+		{"interface after the hold", 2, append(make([]pkt, maxHeldPackets), pkt{1, -time.Hour}), []bool{true, false}},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		pw := Writer(&buf)
+		pw.Endian = binary.LittleEndian
+		pw.CompressTimestamps = true
+		if err := pw.Write(&SectionBlock{MajorVersion: 1, SectionLength: -1}); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < tt.interfaces; i++ {
+			if err := pw.Write(&InterfaceBlock{LinkType: 1}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		data := make([]byte, 60)
+		for i, p := range tt.packets {
+			data[0] = byte(i)
+			if err := pw.WritePacket(PacketMeta{Timestamp: start.Add(p.at), InterfaceID: p.id}, data); err != nil {
+				t.Fatalf("%v: packet %v: %v", tt.name, i, err)
+			}
+			data[0] = 0xff // reused by the caller
+		}
+		if err := pw.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		pr := Reader(bytes.NewReader(buf.Bytes()))
+		var interfaces []*InterfaceBlock
+		n := 0
+		for {
+			b, err := pr.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%v: %v", tt.name, err)
+			}
+			switch b := b.(type) {
+			case *InterfaceBlock:
+				interfaces = append(interfaces, b)
+			case *EnhancedPacketBlock:
+				want := tt.packets[n]
+				if got := interfaces[b.InterfaceID].PacketTime(b.TimestampHigh, b.TimestampLow); b.InterfaceID != want.id || !got.Equal(start.Add(want.at)) {
+					t.Errorf("%v: packet %v on interface %v at %v, want %v at %v", tt.name, n, b.InterfaceID, got, want.id, start.Add(want.at))
+				}
+				if b.PacketData[0] != byte(n) {
+					t.Errorf("%v: packet %v holds the data of packet %v", tt.name, n, b.PacketData[0])
+				}
+				n++
+			}
+		}
+		if n != len(tt.packets) {
+			t.Errorf("%v: read %v packets, want %v", tt.name, n, len(tt.packets))
+		}
+		if len(interfaces) != tt.interfaces {
+			t.Errorf("%v: read %v interfaces, want %v", tt.name, len(interfaces), tt.interfaces)
+			continue
+		}
+		for i, ifb := range interfaces {
+			if (ifb.Tsoffset() != 0) != tt.offsets[i] {
+				t.Errorf("%v: interface %v has if_tsoffset %v, want one %v", tt.name, i, ifb.Tsoffset(), tt.offsets[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,460 @@
+package pcapng
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Problem is a departure from the pcapng specification found by Validate.
+type Problem struct {
+	Offset    int64  // of the block
+	BlockType uint32 // 0 if the block type could not be read
+	Warning   bool   // false for errors
+	Message   string
+}
+
+func (p Problem) String() string {
+	severity := "error"
+	if p.Warning {
+		severity = "warning"
+	}
+	return fmt.Sprintf("offset 0x%08x block type 0x%08x: %v: %v", p.Offset, p.BlockType, severity, p.Message)
+}
+
+// block types Read returns as a GenericBlock
+const (
+	CUSTOM_BLOCK          = 0x00000BAD
+	CUSTOM_BLOCK_NOCOPY   = 0x40000BAD
+	SIMPLE_PACKET_BLOCK   = 0x00000003
+	OBSOLETE_PACKET_BLOCK = 0x00000002
+)
+
+// optionRule describes an option code that is legal in a block.
+type optionRule struct {
+	name   string
+	length int  // required length, -1 for any
+	single bool // may appear only once
+}
+
+// options legal in every block that has options
+var commonOptions = map[uint16]optionRule{
+	OPT_COMMENT: {"opt_comment", -1, false},
+	2988:        {"opt_custom", -1, false},
+	2989:        {"opt_custom", -1, false},
+	19372:       {"opt_custom", -1, false},
+	19373:       {"opt_custom", -1, false},
+}
+
+var blockOptions = map[uint32]map[uint16]optionRule{
+	SECTION_HEADER_BLOCK: {
+		2: {"shb_hardware", -1, true},
+		3: {"shb_os", -1, true},
+		4: {"shb_userappl", -1, true},
+	},
+	INTERFACE_DESCRIPTION_BLOCK: {
+		2:  {"if_name", -1, true},
+		3:  {"if_description", -1, true},
+		4:  {"if_IPv4addr", 8, false},
+		5:  {"if_IPv6addr", 17, false},
+		6:  {"if_MACaddr", 6, true},
+		7:  {"if_EUIaddr", 8, true},
+		8:  {"if_speed", 8, true},
+		9:  {"if_tsresol", 1, true},
+		10: {"if_tzone", 4, true},
+		11: {"if_filter", -1, true},
+		12: {"if_os", -1, true},
+		13: {"if_fcslen", 1, true},
+		14: {"if_tsoffset", 8, true},
+		15: {"if_hardware", -1, true},
+		16: {"if_txspeed", 8, true},
+		17: {"if_rxspeed", 8, true},
+		18: {"if_iana_tzname", -1, true},
+	},
+	ENHANCED_PACKET_BLOCK: {
+		2: {"epb_flags", 4, true},
+		3: {"epb_hash", -1, false},
+		4: {"epb_dropcount", 8, true},
+		5: {"epb_packetid", 8, true},
+		6: {"epb_queue", 4, true},
+		7: {"epb_verdict", -1, false},
+		8: {"epb_processid_threadid", 8, true},
+	},
+	OBSOLETE_PACKET_BLOCK: {
+		2: {"pack_flags", 4, true},
+		3: {"pack_hash", -1, false},
+	},
+	INTERFACE_STATISTICS_BLOCK: {
+		2: {"isb_starttime", 8, true},
+		3: {"isb_endtime", 8, true},
+		4: {"isb_ifrecv", 8, true},
+		5: {"isb_ifdrop", 8, true},
+		6: {"isb_filteraccept", 8, true},
+		7: {"isb_osdrop", 8, true},
+		8: {"isb_usrdeliv", 8, true},
+	},
+	NAME_RESOLUTION_BLOCK: {
+		2: {"ns_dnsname", -1, true},
+		3: {"ns_dnsIP4addr", 4, true},
+		4: {"ns_dnsIP6addr", 16, true},
+	},
+	DECRYPTION_SECRETS_BLOCK: {},
+}
+
+// minimum Block Total Length of each block type
+var minBlockLength = map[uint32]int{
+	SECTION_HEADER_BLOCK:        28,
+	INTERFACE_DESCRIPTION_BLOCK: 20,
+	OBSOLETE_PACKET_BLOCK:       32,
+	SIMPLE_PACKET_BLOCK:         16,
+	NAME_RESOLUTION_BLOCK:       16,
+	INTERFACE_STATISTICS_BLOCK:  24,
+	ENHANCED_PACKET_BLOCK:       32,
+	DECRYPTION_SECRETS_BLOCK:    20,
+	CUSTOM_BLOCK:                16,
+	CUSTOM_BLOCK_NOCOPY:         16,
+}
+
+// blocks larger than this are taken to be corrupt rather than read into memory
+const maxBlockLength = 1 << 28
+
+// validator holds the state of one Validate call.
+type validator struct {
+	problems []Problem
+	offset   int64
+	block    uint32
+	endian   binary.ByteOrder
+
+	sections     int
+	sectionEnd   int64    // where the current section must end, -1 if unknown
+	snapLens     []uint32 // of the interfaces of the current section
+	packetBlocks int      // in the current section
+}
+
+func (v *validator) errorf(format string, args ...interface{}) {
+	v.problems = append(v.problems, Problem{v.offset, v.block, false, fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) warnf(format string, args ...interface{}) {
+	v.problems = append(v.problems, Problem{v.offset, v.block, true, fmt.Sprintf(format, args...)})
+}
+
+// Validate checks a pcapng file against the specification: block lengths
+// and alignment, section headers, the order of blocks, interface
+// references and the legality, length and number of options. It returns
+// every problem found. The error is only for failures to read r.
+// Validation stops at the first problem that makes the following blocks
+// impossible to locate.
+func Validate(r io.Reader) ([]Problem, error) {
+
+	v := &validator{endian: binary.LittleEndian, sectionEnd: -1}
+	hdr := make([]byte, 12)
+
+	for {
+		count, err := io.ReadFull(r, hdr[:8])
+		if err == io.EOF {
+			break
+		} else if err == io.ErrUnexpectedEOF {
+			v.errorf("%v bytes of trailing garbage", count)
+			break
+		} else if err != nil {
+			return v.problems, err
+		}
+
+		v.block = v.endian.Uint32(hdr[0:4])
+		if v.block == SECTION_HEADER_BLOCK {
+			if _, err := io.ReadFull(r, hdr[8:12]); err != nil {
+				v.errorf("Section Header Block is truncated")
+				break
+			}
+			switch binary.LittleEndian.Uint32(hdr[8:12]) {
+			case MagicNumber:
+				v.endian = binary.LittleEndian
+			case SwapMagicNumber:
+				v.endian = binary.BigEndian
+			default:
+				v.errorf("bad Byte-Order Magic 0x%08x", binary.LittleEndian.Uint32(hdr[8:12]))
+				return v.problems, nil
+			}
+		} else if v.sections == 0 {
+			v.errorf("the file does not start with a Section Header Block")
+			return v.problems, nil
+		}
+
+		totalLength := v.endian.Uint32(hdr[4:8])
+		if totalLength < 12 || totalLength%4 != 0 {
+			v.errorf("Block Total Length %v is not a multiple of 4 of at least 12", totalLength)
+			return v.problems, nil
+		}
+		if totalLength > maxBlockLength {
+			v.errorf("Block Total Length %v is implausibly large", totalLength)
+			return v.problems, nil
+		}
+		if min, ok := minBlockLength[v.block]; ok && int(totalLength) < min {
+			v.errorf("Block Total Length %v is shorter than the minimum of %v for this block type", totalLength, min)
+			return v.problems, nil
+		}
+
+		buf := make([]byte, totalLength)
+		copy(buf, hdr)
+		start := 8
+		if v.block == SECTION_HEADER_BLOCK {
+			start = 12
+		}
+		if count, err := io.ReadFull(r, buf[start:]); err != nil {
+			v.errorf("block is truncated, %v of %v bytes present", start+count, totalLength)
+			break
+		}
+
+		if trailing := v.endian.Uint32(buf[totalLength-4:]); trailing != totalLength {
+			v.errorf("trailing Block Total Length %v does not match %v", trailing, totalLength)
+			return v.problems, nil
+		}
+
+		v.checkBlock(buf[8 : totalLength-4])
+		v.offset += int64(totalLength)
+	}
+
+	if v.sectionEnd >= 0 && v.sectionEnd != v.offset {
+		v.block = 0
+		v.errorf("the last section should end at offset 0x%08x but the file ends at 0x%08x", v.sectionEnd, v.offset)
+	}
+	return v.problems, nil
+}
+
+// checkBlock checks the body of the block, between the lengths.
+func (v *validator) checkBlock(body []byte) {
+
+	e := v.endian
+
+	switch v.block {
+	case SECTION_HEADER_BLOCK:
+		if v.sectionEnd >= 0 && v.sectionEnd != v.offset {
+			v.errorf("the previous section should have ended at offset 0x%08x", v.sectionEnd)
+		}
+		v.sections++
+		v.snapLens = nil
+		v.packetBlocks = 0
+
+		major, minor := e.Uint16(body[4:6]), e.Uint16(body[6:8])
+		if major != 1 {
+			v.errorf("unsupported version %v.%v", major, minor)
+		} else if minor != 0 && minor != 2 {
+			v.warnf("unusual version %v.%v", major, minor)
+		}
+
+		sectionLength := int64(e.Uint64(body[8:16]))
+		v.sectionEnd = -1
+		if sectionLength >= 0 {
+			if sectionLength%4 != 0 {
+				v.errorf("Section Length %v is not a multiple of 4", sectionLength)
+			}
+			v.sectionEnd = v.offset + int64(len(body)) + 12 + sectionLength
+		} else if sectionLength != -1 {
+			v.errorf("Section Length %v is negative but not -1", sectionLength)
+		}
+		v.checkOptions(body[16:])
+
+	case INTERFACE_DESCRIPTION_BLOCK:
+		if v.packetBlocks > 0 {
+			v.warnf("interface %v is described after packets of the section", len(v.snapLens))
+		}
+		if reserved := e.Uint16(body[2:4]); reserved != 0 {
+			v.warnf("reserved field is 0x%04x rather than 0", reserved)
+		}
+		v.snapLens = append(v.snapLens, e.Uint32(body[4:8]))
+		v.checkOptions(body[8:])
+
+	case ENHANCED_PACKET_BLOCK:
+		v.packetBlocks++
+		v.checkInterface(e.Uint32(body[0:4]))
+		captured, original := e.Uint32(body[12:16]), e.Uint32(body[16:20])
+		if !v.checkPacket(body[20:], captured, original, e.Uint32(body[0:4])) {
+			return
+		}
+		v.checkOptions(body[20+pad4(int(captured)):])
+
+	case OBSOLETE_PACKET_BLOCK:
+		v.packetBlocks++
+		v.warnf("the Packet Block is obsolete, use an Enhanced Packet Block")
+		v.checkInterface(uint32(e.Uint16(body[0:2])))
+		captured, original := e.Uint32(body[12:16]), e.Uint32(body[16:20])
+		if !v.checkPacket(body[20:], captured, original, uint32(e.Uint16(body[0:2]))) {
+			return
+		}
+		v.checkOptions(body[20+pad4(int(captured)):])
+
+	case SIMPLE_PACKET_BLOCK:
+		v.packetBlocks++
+		if len(v.snapLens) == 0 {
+			v.errorf("Simple Packet Block before any Interface Description Block")
+		}
+		original := e.Uint32(body[0:4])
+		if int(original) > len(body)-4 && (len(v.snapLens) == 0 || v.snapLens[0] == 0 || original <= v.snapLens[0]) {
+			v.errorf("Original Packet Length %v does not fit in the block and is not limited by the snap length", original)
+		}
+
+	case INTERFACE_STATISTICS_BLOCK:
+		v.checkInterface(e.Uint32(body[0:4]))
+		v.checkOptions(body[12:])
+
+	case NAME_RESOLUTION_BLOCK:
+		if rest, ok := v.checkRecords(body); ok {
+			v.checkOptions(rest)
+		}
+
+	case DECRYPTION_SECRETS_BLOCK:
+		length := int(e.Uint32(body[4:8]))
+		if 8+pad4(length) > len(body) {
+			v.errorf("Secrets Length %v does not fit in the block", length)
+			return
+		}
+		v.checkOptions(body[8+pad4(length):])
+
+	case CUSTOM_BLOCK, CUSTOM_BLOCK_NOCOPY:
+		// the body after the Private Enterprise Number belongs to its owner
+
+	default:
+		if v.block&0x80000000 != 0 {
+			// local use block types
+			return
+		}
+		v.warnf("unknown block type")
+	}
+}
+
+// checkInterface checks that id refers to an interface of the section.
+func (v *validator) checkInterface(id uint32) {
+	if int(id) >= len(v.snapLens) {
+		v.errorf("references interface %v but the section has %v", id, len(v.snapLens))
+	}
+}
+
+// checkPacket checks the packet lengths, returning false if the options
+// cannot be located.
+func (v *validator) checkPacket(rest []byte, captured uint32, original uint32, id uint32) bool {
+	if pad4(int(captured)) > len(rest) {
+		v.errorf("Captured Packet Length %v does not fit in the block", captured)
+		return false
+	}
+	if captured > original {
+		v.warnf("Captured Packet Length %v is larger than the Original Packet Length %v", captured, original)
+	}
+	if int(id) < len(v.snapLens) && v.snapLens[id] != 0 && captured > v.snapLens[id] {
+		v.warnf("Captured Packet Length %v exceeds the interface snap length %v", captured, v.snapLens[id])
+	}
+	return true
+}
+
+// checkRecords checks the records of a Name Resolution Block and returns
+// the options that follow them.
+func (v *validator) checkRecords(body []byte) ([]byte, bool) {
+
+	e := v.endian
+	for {
+		if len(body) < 4 {
+			v.errorf("the records are not terminated by nrb_record_end")
+			return nil, false
+		}
+		recordType, length := e.Uint16(body[0:2]), int(e.Uint16(body[2:4]))
+		if 4+pad4(length) > len(body) {
+			v.errorf("record of type %v with length %v overruns the block", recordType, length)
+			return nil, false
+		}
+		value := body[4 : 4+length]
+		body = body[4+pad4(length):]
+
+		switch recordType {
+		case NRB_RECORD_END:
+			if length != 0 {
+				v.errorf("nrb_record_end has length %v", length)
+			}
+			return body, true
+		case NRB_RECORD_IPV4:
+			v.checkNames("nrb_record_ipv4", value, 4)
+		case NRB_RECORD_IPV6:
+			v.checkNames("nrb_record_ipv6", value, 16)
+		default:
+			v.warnf("unknown record type %v", recordType)
+		}
+	}
+}
+
+// checkNames checks an address followed by zero terminated names.
+func (v *validator) checkNames(name string, value []byte, addressLength int) {
+	if len(value) < addressLength+2 {
+		v.errorf("%v of length %v is too short for an address and a name", name, len(value))
+	} else if value[len(value)-1] != 0 {
+		v.errorf("%v names are not zero terminated", name)
+	}
+}
+
+// checkOptions checks the options of the current block.
+func (v *validator) checkOptions(buf []byte) {
+
+	e := v.endian
+	rules := blockOptions[v.block]
+	seen := make(map[uint16]bool)
+
+	for len(buf) > 0 {
+		if len(buf) < 4 {
+			v.errorf("%v bytes after the last option", len(buf))
+			return
+		}
+		code, length := e.Uint16(buf[0:2]), int(e.Uint16(buf[2:4]))
+		if 4+pad4(length) > len(buf) {
+			v.errorf("option %v with length %v overruns the block", code, length)
+			return
+		}
+		value := buf[4 : 4+length]
+		for _, b := range buf[4+length : 4+pad4(length)] {
+			if b != 0 {
+				v.warnf("option %v padding is not zero", code)
+				break
+			}
+		}
+		buf = buf[4+pad4(length):]
+
+		if code == OPT_ENDOFOPT {
+			if length != 0 {
+				v.errorf("opt_endofopt has length %v", length)
+			}
+			if len(buf) != 0 {
+				v.errorf("%v bytes after opt_endofopt", len(buf))
+			}
+			return
+		}
+
+		rule, ok := commonOptions[code]
+		if !ok {
+			if rule, ok = rules[code]; !ok {
+				v.errorf("option code %v is not defined for this block type", code)
+				continue
+			}
+		}
+		if rule.length >= 0 && length != rule.length {
+			v.errorf("%v has length %v rather than %v", rule.name, length, rule.length)
+		}
+		if rule.single && seen[code] {
+			v.errorf("%v appears more than once", rule.name)
+		}
+		seen[code] = true
+
+		switch {
+		case v.block == INTERFACE_DESCRIPTION_BLOCK && code == IF_TSRESOL && length == 1:
+			if value[0]&0x80 == 0 && value[0] > 19 || value[0]&0x80 != 0 && value[0]&0x7f > 63 {
+				v.errorf("if_tsresol 0x%02x overflows a 64 bit timestamp", value[0])
+			}
+		case v.block == INTERFACE_DESCRIPTION_BLOCK && code == IF_FILTER && length == 0:
+			v.errorf("if_filter is empty, it must at least hold the filter type")
+		case (code == 2988 || code == 19372 || code == 2989 || code == 19373) && length < 4:
+			v.errorf("custom option is too short for a Private Enterprise Number")
+		}
+	}
+	// options may also end with the block, without opt_endofopt
+}
+
+// pad4 rounds n up to a multiple of 4.
+func pad4(n int) int {
+	return (n + 3) &^ 3
+}
@@ -0,0 +1,200 @@
+package pcapng
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Custom option codes, usable in every block with options
+const (
+	OPT_CUSTOM_STRING        = 2988
+	OPT_CUSTOM_BINARY        = 2989
+	OPT_CUSTOM_STRING_NOCOPY = 19372
+	OPT_CUSTOM_BINARY_NOCOPY = 19373
+)
+
+// Opt_Custom is a Custom Option. Value is what follows the Private
+// Enterprise Number, UTF-8 for the string codes.
+type Opt_Custom struct {
+	Type  uint16 // one of the OPT_CUSTOM codes
+	PEN   uint32 // Private Enterprise Number
+	Value []byte
+}
+
+func (opt *Opt_Custom) Pack(endian binary.ByteOrder) ([]byte, error) {
+	buf := make([]byte, 4+len(opt.Value))
+	endian.PutUint32(buf[0:4], opt.PEN)
+	copy(buf[4:], opt.Value)
+	return packTlv(int(opt.Type), buf, endian)
+}
+
+func (opt *Opt_Custom) Code() uint16 {
+	return opt.Type
+}
+
+// customOption parses a Custom Option of any block.
+// It returns nil for other options and for a value too short for the PEN.
+func customOption(tlv TLV, endian binary.ByteOrder) Option {
+	switch tlv.Type {
+	case OPT_CUSTOM_STRING, OPT_CUSTOM_BINARY, OPT_CUSTOM_STRING_NOCOPY, OPT_CUSTOM_BINARY_NOCOPY:
+		if len(tlv.Value) >= 4 {
+			return &Opt_Custom{tlv.Type, endian.Uint32(tlv.Value[0:4]), append([]byte(nil), tlv.Value[4:]...)}
+		}
+	}
+	return nil
+}
+
+// VendorError is returned for vendor metadata that cannot be encoded or
+// decoded.
+type VendorError struct {
+	errorString string
+}
+
+func (e *VendorError) Error() string {
+	return e.errorString
+}
+
+// VendorValue is one kind of structured metadata of a vendor. The tag
+// tells the kinds of a vendor apart and MarshalBinary gives the bytes the
+// decoder registered for the tag reads back.
+type VendorValue interface {
+	VendorTag() uint16
+	MarshalBinary() ([]byte, error)
+}
+
+// VendorDecoder turns the bytes of a VendorValue back into the value.
+type VendorDecoder func(data []byte) (VendorValue, error)
+
+// Vendor encodes the metadata of one Private Enterprise Number into
+// Custom Options of sections, interfaces and packets, or into Custom
+// Blocks, and decodes it back. Every value is its tag in network byte
+// order followed by its bytes. A Custom Block holds a list of values,
+// each with a 32 bit length after the tag.
+type Vendor struct {
+	PEN      uint32
+	NoCopy   bool // if set, tools changing a file must drop the options and blocks
+	decoders map[uint16]VendorDecoder
+}
+
+// NewVendor returns a Vendor for the Private Enterprise Number pen with no
+// codecs registered.
+func NewVendor(pen uint32) *Vendor {
+	return &Vendor{PEN: pen, decoders: make(map[uint16]VendorDecoder)}
+}
+
+// Register sets the decoder of the values with tag.
+func (v *Vendor) Register(tag uint16, decode VendorDecoder) {
+	v.decoders[tag] = decode
+}
+
+// Option encodes value as a binary Custom Option to add to the options of
+// a Section Header, Interface Description or Enhanced Packet Block.
+func (v *Vendor) Option(value VendorValue) (*Opt_Custom, error) {
+
+	data, err := value.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	if 4+2+len(data) > 0xffff {
+		return nil, &VendorError{fmt.Sprintf("vendor value with tag %v of %v bytes does not fit in an option", value.VendorTag(), len(data))}
+	}
+
+	opt := &Opt_Custom{Type: OPT_CUSTOM_BINARY, PEN: v.PEN}
+	if v.NoCopy {
+		opt.Type = OPT_CUSTOM_BINARY_NOCOPY
+	}
+	opt.Value = make([]byte, 2+len(data))
+	binary.BigEndian.PutUint16(opt.Value[0:2], value.VendorTag())
+	copy(opt.Value[2:], data)
+	return opt, nil
+}
+
+// Attach adds value as a Custom Option to options, for example
+// v.Attach(&epb.Options, value).
+func (v *Vendor) Attach(options *[]Option, value VendorValue) error {
+	opt, err := v.Option(value)
+	if err != nil {
+		return err
+	}
+	*options = append(*options, opt)
+	return nil
+}
+
+// Block encodes values into one Custom Block, for metadata that is too
+// large for an option or belongs to no single block.
+func (v *Vendor) Block(values ...VendorValue) (*CustomBlock, error) {
+
+	b := &CustomBlock{Type: CUSTOM_BLOCK, PEN: v.PEN}
+	if v.NoCopy {
+		b.Type = CUSTOM_BLOCK_NOCOPY
+	}
+	for _, value := range values {
+		data, err := value.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		var head [6]byte
+		binary.BigEndian.PutUint16(head[0:2], value.VendorTag())
+		binary.BigEndian.PutUint32(head[2:6], uint32(len(data)))
+		b.Data = append(b.Data, head[:]...)
+		b.Data = append(b.Data, data...)
+	}
+	return b, nil
+}
+
+// Decode returns the values of the vendor found in options, skipping the
+// Custom Options of other vendors and the string options. It returns an
+// error for a tag with no registered decoder.
+func (v *Vendor) Decode(options []Option) ([]VendorValue, error) {
+
+	var values []VendorValue
+	for _, o := range options {
+		opt, ok := o.(*Opt_Custom)
+		if !ok || opt.PEN != v.PEN || (opt.Type != OPT_CUSTOM_BINARY && opt.Type != OPT_CUSTOM_BINARY_NOCOPY) {
+			continue
+		}
+		if len(opt.Value) < 2 {
+			return values, &VendorError{fmt.Sprintf("custom option of PEN %v is too short for a tag", v.PEN)}
+		}
+		value, err := v.decode(binary.BigEndian.Uint16(opt.Value[0:2]), opt.Value[2:])
+		if err != nil {
+			return values, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// DecodeBlock returns the values of a Custom Block written by Block. The
+// padding that ParseCustomBlock leaves in Data is skipped.
+func (v *Vendor) DecodeBlock(b *CustomBlock) ([]VendorValue, error) {
+
+	if b.PEN != v.PEN {
+		return nil, &VendorError{fmt.Sprintf("Custom Block has PEN %v, not %v", b.PEN, v.PEN)}
+	}
+
+	var values []VendorValue
+	data := b.Data
+	for len(data) >= 6 {
+		tag := binary.BigEndian.Uint16(data[0:2])
+		length := binary.BigEndian.Uint32(data[2:6])
+		if uint64(length) > uint64(len(data)-6) {
+			return values, &VendorError{fmt.Sprintf("vendor value with tag %v of %v bytes overruns the Custom Block", tag, length)}
+		}
+		value, err := v.decode(tag, data[6:6+length])
+		if err != nil {
+			return values, err
+		}
+		values = append(values, value)
+		data = data[6+length:]
+	}
+	return values, nil
+}
+
+func (v *Vendor) decode(tag uint16, data []byte) (VendorValue, error) {
+	decode, ok := v.decoders[tag]
+	if !ok {
+		return nil, &VendorError{fmt.Sprintf("no decoder for vendor value with tag %v of PEN %v", tag, v.PEN)}
+	}
+	return decode(append([]byte(nil), data...))
+}
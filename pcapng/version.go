@@ -0,0 +1,37 @@
+package pcapng
+
+import (
+	"fmt"
+)
+
+// MajorVersion is the only major version of the format the reader knows.
+// Minor versions 0 and 2 are both in use for the same format.
+const MajorVersion = 1
+
+// SectionVersion returns the version of the section of the last block
+// read, 0.0 before the first Section Header Block.
+func (pr *PcapngReader) SectionVersion() (major uint16, minor uint16) {
+	return pr.majorVersion, pr.minorVersion
+}
+
+// checkVersion records the version of a section being read. A major
+// version other than MajorVersion may change the layout of every block
+// that follows, so it is an error unless AnyVersion is set, when it only
+// goes to Warnings. An unusual minor version is always only a warning.
+func (pr *PcapngReader) checkVersion(major uint16, minor uint16) error {
+
+	pr.majorVersion, pr.minorVersion = major, minor
+
+	if major != MajorVersion {
+		err := &PcapError{fmt.Sprintf("offset 0x%08x: section %v has version %v.%v, only %v.x can be read",
+			pr.metadata.Offset, pr.metadata.Section, major, minor, MajorVersion)}
+		if !pr.AnyVersion {
+			return err
+		}
+		pr.warn(err)
+	} else if minor != 0 && minor != 2 {
+		pr.warn(&PcapError{fmt.Sprintf("offset 0x%08x: section %v has unusual version %v.%v",
+			pr.metadata.Offset, pr.metadata.Section, major, minor)})
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package pcapng
+
+import (
+	"errors"
+	"io"
+)
+
+// StopWalk can be returned by a handler to end Walk early without an error.
+var StopWalk = errors.New("stop walk")
+
+// Handlers are the callbacks of Walk, one per kind of block. Blocks
+// without a handler are read and passed over. An error returned by a
+// handler ends the walk and is returned by Walk, except StopWalk.
+type Handlers struct {
+	OnSectionHeader     func(b *SectionBlock) error
+	OnInterface         func(b *InterfaceBlock) error
+	OnPacket            func(b *EnhancedPacketBlock, ifb *InterfaceBlock) error // ifb is nil for an undescribed interface
+	OnStatistics        func(b *InterfaceStatisticsBlock) error
+	OnNameResolution    func(b *NameResolutionBlock) error
+	OnDecryptionSecrets func(b *DecryptionSecretsBlock) error
+	OnUnknown           func(b *GenericBlock) error // blocks the reader does not parse
+}
+
+// Walk reads pr to the end and calls the handler for each block. Use
+// Metadata in a handler to find where the block is.
+func (pr *PcapngReader) Walk(h Handlers) error {
+
+	var interfaces []*InterfaceBlock // of the current section
+
+	for {
+		block, err := pr.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		switch b := block.(type) {
+		case *SectionBlock:
+			interfaces = nil
+			if h.OnSectionHeader != nil {
+				err = h.OnSectionHeader(b)
+			}
+		case *InterfaceBlock:
+			interfaces = append(interfaces, b)
+			if h.OnInterface != nil {
+				err = h.OnInterface(b)
+			}
+		case *EnhancedPacketBlock:
+			if h.OnPacket != nil {
+				var ifb *InterfaceBlock
+				if int(b.InterfaceID) < len(interfaces) {
+					ifb = interfaces[b.InterfaceID]
+				}
+				err = h.OnPacket(b, ifb)
+			}
+		case *InterfaceStatisticsBlock:
+			if h.OnStatistics != nil {
+				err = h.OnStatistics(b)
+			}
+		case *NameResolutionBlock:
+			if h.OnNameResolution != nil {
+				err = h.OnNameResolution(b)
+			}
+		case *DecryptionSecretsBlock:
+			if h.OnDecryptionSecrets != nil {
+				err = h.OnDecryptionSecrets(b)
+			}
+		case *GenericBlock:
+			if h.OnUnknown != nil {
+				err = h.OnUnknown(b)
+			}
+		}
+
+		if err == StopWalk {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
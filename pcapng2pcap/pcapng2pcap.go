@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"github.com/RajeshGottlieb/go/pcap"
+	"github.com/RajeshGottlieb/go/pcapng"
+	"io"
+	"os"
+)
+
+func main() {
+
+	precision := flag.String("precision", "usec", "write timestamps in usec or nsec")
+	roundMode := flag.String("rounding", "", "truncate, up or even, how to drop digits beyond the precision")
+	strict := flag.Bool("strict", false, "exit with status 1 if anything was dropped or altered")
+	flag.Parse()
+
+	if flag.NArg() != 2 || (*precision != "usec" && *precision != "nsec") {
+		fmt.Fprintf(os.Stderr, "usage: %v [-precision usec|nsec] [-rounding mode] [-strict] <input-pcapng|-> <output-pcap|->\n", os.Args[0])
+		os.Exit(2)
+	}
+
+	rounding := pcapng.Truncate
+	if *roundMode != "" {
+		r, err := pcapng.ParseRounding(*roundMode)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		rounding = r
+	}
+
+	var r io.Reader = os.Stdin
+	if flag.Arg(0) != "-" {
+		rfh, err := os.Open(flag.Arg(0))
+		if err != nil {
+			fail("%v", err)
+		}
+		defer rfh.Close()
+		r = rfh
+	}
+
+	// the report goes to the standard error when the pcap takes the standard output
+	wfh, info := os.Stdout, os.Stdout
+	if flag.Arg(1) != "-" {
+		var err error
+		if wfh, err = os.Create(flag.Arg(1)); err != nil {
+			fail("%v", err)
+		}
+	} else {
+		info = os.Stderr
+	}
+
+	bw := bufio.NewWriter(wfh)
+	report, err := pcap.FromPcapng(pcapng.Reader(bufio.NewReader(r)), bw, *precision == "nsec", rounding)
+	if err != nil {
+		fail("%v", err)
+	}
+	if err := wfh.Close(); err != nil {
+		fail("%v: %v", flag.Arg(1), err)
+	}
+
+	fmt.Fprint(info, report)
+	if *strict && !report.Lossless() {
+		os.Exit(1)
+	}
+}
+
+// fail reports an error on the standard error and exits with status 1.
+func fail(format string, a ...interface{}) {
+	fmt.Fprintf(os.Stderr, "%v: %v\n", os.Args[0], fmt.Sprintf(format, a...))
+	os.Exit(1)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/RajeshGottlieb/go/pcapng"
+	"github.com/RajeshGottlieb/go/sidecar"
+	"github.com/RajeshGottlieb/go/transform"
+	"os"
+)
+
+// blockName returns a short name for the block types that carry comments.
+func blockName(blockType uint32) string {
+	switch blockType {
+	case pcapng.SECTION_HEADER_BLOCK:
+		return "SHB"
+	case pcapng.INTERFACE_DESCRIPTION_BLOCK:
+		return "IDB"
+	case pcapng.INTERFACE_STATISTICS_BLOCK:
+		return "ISB"
+	case pcapng.ENHANCED_PACKET_BLOCK:
+		return "EPB"
+	case pcapng.NAME_RESOLUTION_BLOCK:
+		return "NRB"
+	case pcapng.DECRYPTION_SECRETS_BLOCK:
+		return "DSB"
+	}
+	return fmt.Sprintf("0x%08x", blockType)
+}
+
+func main() {
+
+	asJSON := flag.Bool("json", false, "write the comments as JSON")
+	strip := flag.String("strip", "", "write a copy of the input without any comments to this file")
+	export := flag.String("export", "", "write the packet comments to this sidecar file")
+	bake := flag.String("bake", "", "write a copy of the input with the annotations of -sidecar added as comments to this file")
+	sidecarFile := flag.String("sidecar", "", "with -bake, the sidecar file to read the annotations from")
+	flag.Parse()
+
+	if flag.NArg() != 1 || (*bake == "") != (*sidecarFile == "") {
+		fmt.Printf("usage: %v [-json] [-strip output-pcapng] [-export sidecar-jsonl] [-bake output-pcapng -sidecar sidecar-jsonl] <input-pcapng>\n", os.Args[0])
+		return
+	}
+
+	fh, err := os.Open(flag.Arg(0))
+	if err != nil {
+		panic(err)
+	}
+	defer fh.Close()
+
+	if *strip != "" {
+		wfh, err := os.Create(*strip)
+		if err != nil {
+			panic(err)
+		}
+		defer wfh.Close()
+
+		bw := bufio.NewWriter(wfh)
+		s := &transform.StripComments{}
+		if err := transform.Copy(pcapng.Reader(bufio.NewReader(fh)), pcapng.Writer(bw), s); err != nil {
+			panic(err)
+		}
+		if err := bw.Flush(); err != nil {
+			panic(err)
+		}
+		fmt.Printf("removed %v comments\n", s.Removed)
+		return
+	}
+
+	if *export != "" {
+		s, err := sidecar.FromComments(bufio.NewReader(fh))
+		if err != nil {
+			panic(err)
+		}
+		sfh, err := os.Create(*export)
+		if err != nil {
+			panic(err)
+		}
+		defer sfh.Close()
+		if err := s.Write(sfh); err != nil {
+			panic(err)
+		}
+		fmt.Printf("exported %v comments\n", len(s.Annotations()))
+		return
+	}
+
+	if *bake != "" {
+		sfh, err := os.Open(*sidecarFile)
+		if err != nil {
+			panic(err)
+		}
+		defer sfh.Close()
+		s, err := sidecar.Read(sfh)
+		if err != nil {
+			panic(err)
+		}
+
+		wfh, err := os.Create(*bake)
+		if err != nil {
+			panic(err)
+		}
+		defer wfh.Close()
+
+		bw := bufio.NewWriter(wfh)
+		added, err := sidecar.Bake(pcapng.Reader(bufio.NewReader(fh)), pcapng.Writer(bw), s)
+		if err != nil {
+			panic(err)
+		}
+		if err := bw.Flush(); err != nil {
+			panic(err)
+		}
+		fmt.Printf("added %v comments\n", added)
+		return
+	}
+
+	comments, err := pcapng.Comments(bufio.NewReader(fh))
+	if err != nil {
+		panic(err)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(comments); err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	for _, c := range comments {
+		location := fmt.Sprintf("section %v", c.Section)
+		if c.Packet > 0 {
+			location = fmt.Sprintf("packet %v", c.Packet)
+		} else if c.Interface >= 0 {
+			location = fmt.Sprintf("section %v interface %v", c.Section, c.Interface)
+		}
+		fmt.Printf("offset 0x%08x %v %v: %v\n", c.Offset, blockName(c.BlockType), location, c.Text)
+	}
+}
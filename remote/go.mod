@@ -0,0 +1,3 @@
+module github.com/RajeshGottlieb/go/remote
+
+go 1.15
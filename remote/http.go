@@ -0,0 +1,198 @@
+// Package remote reads captures stored on remote machines.
+package remote
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// RemoteError
+type RemoteError struct {
+	errorString string
+}
+
+func (re *RemoteError) Error() string {
+	return re.errorString
+}
+
+// DefaultChunkSize is the amount fetched by each range request.
+const DefaultChunkSize = 1 << 20
+
+// HTTPReaderAt reads a remote file with HTTP range requests.
+// It is safe for concurrent use.
+//
+// Only the chunks read are downloaded, but pcapng.BuildIndex reads the
+// header of every block and block headers are close enough together that
+// it touches every chunk: indexing a remote file downloads all of it.
+// pcapng.Sections jumps over sections whose Section Length is known, so
+// it and pcapng.BuildSectionIndex download little more than the section
+// indexed.
+type HTTPReaderAt struct {
+	URL         string
+	Client      *http.Client
+	ChunkSize   int64 // bytes fetched per request, more than 0
+	CacheChunks int   // number of chunks kept in memory, 0 for none
+
+	size     int64
+	mu       sync.Mutex
+	cache    map[int64][]byte        // chunk number -> data
+	order    []int64                 // chunk numbers, oldest first
+	inflight map[int64]*pendingChunk // chunk number -> request in progress
+}
+
+// pendingChunk is a chunk request in progress, which other readers of
+// the chunk wait for rather than requesting it again.
+type pendingChunk struct {
+	done chan struct{} // closed once data and err are set
+	data []byte
+	err  error
+}
+
+// OpenHTTP checks that the server supports range requests and returns a reader for url.
+// The size comes from a one byte range request rather than HEAD, as
+// presigned S3 and GCS URLs are signed for GET only.
+func OpenHTTP(url string) (*HTTPReaderAt, error) {
+
+	r := &HTTPReaderAt{
+		URL:         url,
+		Client:      http.DefaultClient,
+		ChunkSize:   DefaultChunkSize,
+		CacheChunks: 16,
+		cache:       make(map[int64][]byte),
+		inflight:    make(map[int64]*pendingChunk),
+	}
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", "bytes=0-0")
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent, http.StatusRequestedRangeNotSatisfiable:
+		// 416 for an empty file, whose Content-Range is "bytes */0"
+	case http.StatusOK:
+		return nil, &RemoteError{fmt.Sprintf("%v does not support range requests", url)}
+	default:
+		return nil, &RemoteError{fmt.Sprintf("GET %v range 0-0: %v", url, resp.Status)}
+	}
+
+	// e.g. "bytes 0-0/1234"
+	contentRange := resp.Header.Get("Content-Range")
+	i := strings.LastIndex(contentRange, "/")
+	if i < 0 {
+		return nil, &RemoteError{fmt.Sprintf("%v has no Content-Range", url)}
+	}
+	if r.size, err = strconv.ParseInt(contentRange[i+1:], 10, 64); err != nil || r.size < 0 {
+		return nil, &RemoteError{fmt.Sprintf("%v has no size in Content-Range %q", url, contentRange)}
+	}
+	return r, nil
+}
+
+// Size returns the size of the remote file.
+func (r *HTTPReaderAt) Size() int64 {
+	return r.size
+}
+
+// ReadAt reads len(p) bytes starting at off.
+func (r *HTTPReaderAt) ReadAt(p []byte, off int64) (int, error) {
+
+	if r.ChunkSize <= 0 {
+		return 0, &RemoteError{fmt.Sprintf("chunk size %v is not positive", r.ChunkSize)}
+	}
+
+	n := 0
+	for n < len(p) {
+		if off >= r.size {
+			return n, io.EOF
+		}
+		chunk, err := r.chunk(off / r.ChunkSize)
+		if err != nil {
+			return n, err
+		}
+		c := copy(p[n:], chunk[off%r.ChunkSize:])
+		n += c
+		off += int64(c)
+	}
+	return n, nil
+}
+
+// chunk returns the i'th chunk of the file from the cache or the server.
+// The lock is only held to look up and update the cache, so the requests
+// for different chunks run in parallel.
+func (r *HTTPReaderAt) chunk(i int64) ([]byte, error) {
+
+	r.mu.Lock()
+	if data, ok := r.cache[i]; ok {
+		r.mu.Unlock()
+		return data, nil
+	}
+	if f, ok := r.inflight[i]; ok {
+		r.mu.Unlock()
+		<-f.done
+		return f.data, f.err
+	}
+	f := &pendingChunk{done: make(chan struct{})}
+	r.inflight[i] = f
+	r.mu.Unlock()
+
+	f.data, f.err = r.fetch(i)
+
+	r.mu.Lock()
+	delete(r.inflight, i)
+	if f.err == nil && r.CacheChunks > 0 {
+		for len(r.order) >= r.CacheChunks {
+			delete(r.cache, r.order[0])
+			r.order = r.order[1:]
+		}
+		r.cache[i] = f.data
+		r.order = append(r.order, i)
+	}
+	r.mu.Unlock()
+	close(f.done)
+	return f.data, f.err
+}
+
+// fetch requests the i'th chunk from the server.
+func (r *HTTPReaderAt) fetch(i int64) ([]byte, error) {
+
+	start := i * r.ChunkSize
+	end := start + r.ChunkSize - 1
+	if end >= r.size {
+		end = r.size - 1
+	}
+
+	req, err := http.NewRequest("GET", r.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%v-%v", start, end))
+
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return nil, &RemoteError{fmt.Sprintf("GET %v range %v-%v: %v", r.URL, start, end, resp.Status)}
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) != end-start+1 {
+		return nil, &RemoteError{fmt.Sprintf("read %v bytes expected %v", len(data), end-start+1)}
+	}
+	return data, nil
+}
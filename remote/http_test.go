@@ -0,0 +1,89 @@
+package remote
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHTTPReaderAtConcurrent(t *testing.T) {
+
+	data := make([]byte, 4*1000+10)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+
+	// chunk requests wait until two are in progress at once
+	var mu sync.Mutex
+	requests := make(map[string]int)
+	inProgress := 0
+	both := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		rng := req.Header.Get("Range")
+		if rng != "bytes=0-0" {
+			mu.Lock()
+			requests[rng]++
+			inProgress++
+			if inProgress == 2 {
+				close(both)
+			}
+			mu.Unlock()
+			select {
+			case <-both:
+			case <-time.After(5 * time.Second):
+			}
+		}
+		http.ServeContent(w, req, "capture.pcapng", time.Time{}, bytes.NewReader(data))
+	}))
+	defer ts.Close()
+
+	r, err := OpenHTTP(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.ChunkSize = 1000
+	if r.Size() != int64(len(data)) {
+		t.Fatalf("Size is %v, want %v", r.Size(), len(data))
+	}
+
+	// readers of two chunks, several of each
+	var wg sync.WaitGroup
+	errs := make(chan string, 8)
+	start := time.Now()
+	for n := 0; n < 8; n++ {
+		off := int64(n%2) * 2000
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p := make([]byte, 1500)
+			if _, err := r.ReadAt(p, off); err != nil {
+				errs <- err.Error()
+			} else if !bytes.Equal(p, data[off:off+1500]) {
+				errs <- "wrong data"
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if time.Since(start) > 4*time.Second {
+		t.Errorf("chunk requests were not made in parallel")
+	}
+
+	var ranges []string
+	for rng, n := range requests {
+		ranges = append(ranges, rng)
+		if n != 1 {
+			t.Errorf("%v requested %v times", rng, n)
+		}
+	}
+	if len(ranges) != 4 {
+		t.Errorf("requested %v, want 4 chunks", strings.Join(ranges, ", "))
+	}
+}
@@ -0,0 +1,132 @@
+package remote
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// SSH runs commands on a remote probe with the ssh client, so its keys,
+// agent and ~/.ssh/config apply as they do on the command line.
+type SSH struct {
+	Host     string   // host or user@host
+	Port     int      // 22, or as configured, if 0
+	Identity string   // private key file, if not the default
+	Options  []string // more ssh arguments, e.g. "-o", "Compression=yes"
+	Sudo     bool     // run the remote command with sudo -n
+	Program  string   // the ssh client, "ssh" if empty
+}
+
+// NewSSH returns an SSH for host.
+func NewSSH(host string) *SSH {
+	return &SSH{Host: host}
+}
+
+// Open streams a remote file, e.g. a capture to read with pcapng.Reader,
+// without copying it first.
+func (s *SSH) Open(path string) (*SSHStream, error) {
+	return s.Start("cat", "--", path)
+}
+
+// Capture streams a live capture from a remote interface with tcpdump,
+// packet by packet, in pcap format. snapLen 0 is tcpdump's default.
+func (s *SSH) Capture(iface string, snapLen int, filter string) (*SSHStream, error) {
+
+	args := []string{"tcpdump", "-U", "-n", "-w", "-", "-i", iface}
+	if snapLen > 0 {
+		args = append(args, "-s", strconv.Itoa(snapLen))
+	}
+	if filter != "" {
+		args = append(args, filter)
+	}
+	return s.Start(args...)
+}
+
+// Start runs a remote command and streams its standard output.
+func (s *SSH) Start(command ...string) (*SSHStream, error) {
+
+	program := s.Program
+	if program == "" {
+		program = "ssh"
+	}
+	args := []string{"-T", "-o", "BatchMode=yes"}
+	if s.Port != 0 {
+		args = append(args, "-p", strconv.Itoa(s.Port))
+	}
+	if s.Identity != "" {
+		args = append(args, "-i", s.Identity)
+	}
+	args = append(args, s.Options...)
+	args = append(args, "--", s.Host)
+
+	// the remote shell splits the command again, so quote every word
+	var words []string
+	if s.Sudo {
+		words = append(words, "sudo", "-n")
+	}
+	for _, word := range command {
+		words = append(words, quote(word))
+	}
+	args = append(args, strings.Join(words, " "))
+
+	cmd := exec.Command(program, args...)
+	st := &SSHStream{cmd: cmd, host: s.Host}
+	cmd.Stderr = &st.stderr
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	st.out = out
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// quote quotes a word for a POSIX shell.
+func quote(word string) string {
+	return "'" + strings.Replace(word, "'", `'\''`, -1) + "'"
+}
+
+// SSHStream is the output of a remote command.
+type SSHStream struct {
+	cmd    *exec.Cmd
+	host   string
+	out    io.ReadCloser
+	stderr bytes.Buffer
+	once   sync.Once
+	err    error
+}
+
+// Read reads the output. At its end, the error is io.EOF if the command
+// succeeded, else a RemoteError with what it wrote to standard error.
+func (st *SSHStream) Read(b []byte) (int, error) {
+	n, err := st.out.Read(b)
+	if err == io.EOF {
+		if werr := st.wait(); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+// Close stops the command if it is still running.
+func (st *SSHStream) Close() error {
+	st.out.Close()
+	st.cmd.Process.Kill() // fails harmlessly if it has exited
+	st.wait()
+	return nil
+}
+
+func (st *SSHStream) wait() error {
+	st.once.Do(func() {
+		if err := st.cmd.Wait(); err != nil {
+			st.err = &RemoteError{fmt.Sprintf("%v: %v: %v", st.host, err, strings.TrimSpace(st.stderr.String()))}
+		}
+	})
+	return st.err
+}
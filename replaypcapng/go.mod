@@ -0,0 +1,12 @@
+module github.com/RajeshGottlieb/go/replaypcapng
+
+go 1.15
+
+require (
+	github.com/RajeshGottlieb/go/capture v0.0.0-00010101000000-000000000000
+	github.com/RajeshGottlieb/go/pcapng v0.0.0-00010101000000-000000000000
+)
+
+replace github.com/RajeshGottlieb/go/capture => ../capture
+
+replace github.com/RajeshGottlieb/go/pcapng => ../pcapng
@@ -0,0 +1,151 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/RajeshGottlieb/go/capture"
+	"github.com/RajeshGottlieb/go/pcapng"
+	"io"
+	"os"
+	"time"
+)
+
+// ReplayError is returned for captures that cannot be replayed.
+type ReplayError struct {
+	errorString string
+}
+
+func (e *ReplayError) Error() string {
+	return e.errorString
+}
+
+// replayer paces packets onto the wire.
+type replayer struct {
+	out     *capture.AFPacket
+	speed   float64
+	pps     float64
+	anyLink bool // send the packets of interfaces other than Ethernet as they are
+
+	start     time.Time // wall clock time of the first packet
+	first     time.Time // capture time of the first packet
+	last      time.Time // wall clock time the previous packet was sent
+	sent      int
+	sentBytes int
+}
+
+func (r *replayer) send(ts time.Time, data []byte) error {
+
+	now := time.Now()
+	if r.sent == 0 {
+		r.start = now
+		r.first = ts
+	} else {
+		var wait time.Duration
+		if r.speed > 0 {
+			due := r.start.Add(time.Duration(float64(ts.Sub(r.first)) / r.speed))
+			wait = due.Sub(now)
+		}
+		if r.pps > 0 {
+			due := r.last.Add(time.Duration(float64(time.Second) / r.pps))
+			if d := due.Sub(now); d > wait {
+				wait = d
+			}
+		}
+		if wait > 0 {
+			time.Sleep(wait)
+		}
+	}
+
+	if err := r.out.WritePacket(data); err != nil {
+		return err
+	}
+	r.last = time.Now()
+	r.sent++
+	r.sentBytes += len(data)
+	return nil
+}
+
+// replay sends every packet in one pass over the file.
+func (r *replayer) replay(fh io.Reader) error {
+
+	pr := pcapng.Reader(fh)
+	var interfaces []*pcapng.InterfaceBlock
+
+	// each pass starts its timing over
+	r.sent = 0
+
+	for {
+		block, err := pr.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		switch b := block.(type) {
+		case *pcapng.SectionBlock:
+			interfaces = nil
+		case *pcapng.InterfaceBlock:
+			// the socket sends Ethernet frames, anything else would go out as garbage
+			if b.LinkType != 1 && !r.anyLink {
+				return &ReplayError{fmt.Sprintf("interface %v has link type %v, not ETHERNET; use -anylink to send its packets anyway", len(interfaces), pcapng.LinkTypeName(b.LinkType))}
+			}
+			interfaces = append(interfaces, b)
+		case *pcapng.EnhancedPacketBlock:
+			ts := pcapng.Timestamp(b.TimestampHigh, b.TimestampLow, pcapng.DefaultTsresol)
+			if int(b.InterfaceID) < len(interfaces) {
+				ts = interfaces[b.InterfaceID].PacketTime(b.TimestampHigh, b.TimestampLow)
+			}
+			if err := r.send(ts, b.PacketData); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func main() {
+
+	device := flag.String("i", "", "network interface to transmit on")
+	speed := flag.Float64("speed", 1, "replay speed multiplier, 0 sends as fast as possible")
+	loop := flag.Int("loop", 1, "number of times to replay the capture, 0 loops forever")
+	pps := flag.Float64("pps", 0, "maximum packets per second, 0 for no limit")
+	anyLink := flag.Bool("anylink", false, "send the packets of interfaces that are not Ethernet as they are")
+	flag.Parse()
+
+	if *device == "" || flag.NArg() != 1 || *speed < 0 || *loop < 0 || *pps < 0 {
+		fmt.Printf("usage: %v -i <interface> [-speed multiplier] [-loop count] [-pps rate] [-anylink] <input-pcapng>\n", os.Args[0])
+		return
+	}
+
+	fh, err := os.Open(flag.Arg(0))
+	if err != nil {
+		panic(err)
+	}
+	defer fh.Close()
+
+	out, err := capture.OpenAFPacket(*device, 65535, false)
+	if err != nil {
+		panic(err)
+	}
+	defer out.Close()
+
+	r := &replayer{out: out, speed: *speed, pps: *pps, anyLink: *anyLink}
+	packets, bytes := 0, 0
+
+	for n := 0; *loop == 0 || n < *loop; n++ {
+		if _, err := fh.Seek(0, io.SeekStart); err != nil {
+			panic(err)
+		}
+		if err := r.replay(fh); err != nil {
+			panic(err)
+		}
+		packets += r.sent
+		bytes += r.sentBytes
+		r.sentBytes = 0
+	}
+
+	fmt.Printf("sent %v packets, %v bytes\n", packets, bytes)
+}
@@ -0,0 +1,241 @@
+package seekable
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// The predefined distributions of the literal length, match length and
+// offset codes, RFC 8878 section 3.1.1.3.2.2. -1 is a probability below
+// one state.
+var (
+	llDefault = []int16{4, 3, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1,
+		2, 2, 2, 2, 2, 2, 2, 2, 2, 3, 2, 1, 1, 1, 1, 1,
+		-1, -1, -1, -1}
+	mlDefault = []int16{1, 4, 3, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1,
+		-1, -1, -1, -1, -1}
+	ofDefault = []int16{1, 1, 1, 1, 1, 1, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -1}
+
+	llTable = newFSETable(llDefault, 6)
+	mlTable = newFSETable(mlDefault, 6)
+	ofTable = newFSETable(ofDefault, 5)
+)
+
+// The baselines and extra bits of the literal length and match length
+// codes. Literal lengths below 16 and match lengths below 35 have a code
+// of their own.
+var (
+	llBase = []uint32{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+		16, 18, 20, 22, 24, 28, 32, 40, 48, 64, 128, 256, 512, 1024, 2048, 4096,
+		8192, 16384, 32768, 65536}
+	llBits = []uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		1, 1, 1, 1, 2, 2, 3, 3, 4, 6, 7, 8, 9, 10, 11, 12,
+		13, 14, 15, 16}
+	mlBase = []uint32{3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18,
+		19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31, 32, 33, 34,
+		35, 37, 39, 41, 43, 47, 51, 59, 67, 83, 99, 131, 259, 515, 1027, 2051,
+		4099, 8195, 16387, 32771, 65539}
+	mlBits = []uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		1, 1, 1, 1, 2, 2, 3, 3, 4, 4, 5, 7, 8, 9, 10, 11,
+		12, 13, 14, 15, 16}
+)
+
+// code returns the code of value in a table of baselines.
+func code(base []uint32, value uint32) uint8 {
+	c := len(base) - 1
+	for base[c] > value {
+		c--
+	}
+	return uint8(c)
+}
+
+// fseTable is a finite state entropy table for both encoding and
+// decoding, built the way RFC 8878 section 4.1.1 describes.
+type fseTable struct {
+	log int
+
+	// decoding, by state
+	symbol []uint8
+	nbBits []uint8
+	base   []uint16
+
+	// encoding, states by symbol and the transforms of each symbol
+	states         []uint16
+	deltaNbBits    []uint32
+	deltaFindState []int32
+}
+
+func newFSETable(norm []int16, log int) *fseTable {
+
+	size := 1 << uint(log)
+	t := &fseTable{
+		log:            log,
+		symbol:         make([]uint8, size),
+		nbBits:         make([]uint8, size),
+		base:           make([]uint16, size),
+		states:         make([]uint16, size),
+		deltaNbBits:    make([]uint32, len(norm)),
+		deltaFindState: make([]int32, len(norm)),
+	}
+
+	// symbols below one state get the last states, the others are spread
+	count := make([]int, len(norm))
+	high := size - 1
+	for s, n := range norm {
+		count[s] = int(n)
+		if n == -1 {
+			count[s] = 1
+			t.symbol[high] = uint8(s)
+			high--
+		}
+	}
+	pos, step, mask := 0, size>>1+size>>3+3, size-1
+	for s, n := range norm {
+		for i := 0; i < int(n); i++ {
+			t.symbol[pos] = uint8(s)
+			pos = (pos + step) & mask
+			for pos > high {
+				pos = (pos + step) & mask
+			}
+		}
+	}
+
+	next := make([]int, len(norm))
+	copy(next, count)
+	for u := 0; u < size; u++ {
+		x := next[t.symbol[u]]
+		next[t.symbol[u]]++
+		nb := log - (bits.Len(uint(x)) - 1)
+		t.nbBits[u] = uint8(nb)
+		t.base[u] = uint16(x<<uint(nb) - size)
+	}
+
+	cumul := make([]int, len(norm)+1)
+	for s := range norm {
+		cumul[s+1] = cumul[s] + count[s]
+	}
+	for u := 0; u < size; u++ {
+		s := t.symbol[u]
+		t.states[cumul[s]] = uint16(size + u)
+		cumul[s]++
+	}
+	total := 0
+	for s, n := range count {
+		switch {
+		case n == 1:
+			t.deltaNbBits[s] = uint32(log<<16 - size)
+			t.deltaFindState[s] = int32(total - 1)
+			total++
+		case n > 1:
+			maxBitsOut := log - (bits.Len(uint(n-1)) - 1)
+			t.deltaNbBits[s] = uint32(maxBitsOut<<16 - n<<uint(maxBitsOut))
+			t.deltaFindState[s] = int32(total - n)
+			total += n
+		}
+	}
+	return t
+}
+
+// fseEncoder encodes symbols with a table, in reverse order.
+type fseEncoder struct {
+	t     *fseTable
+	state uint32
+}
+
+// init starts with the state of the last symbol.
+func (e *fseEncoder) init(t *fseTable, s uint8) {
+	e.t = t
+	nb := (t.deltaNbBits[s] + 1<<15) >> 16
+	e.state = uint32(t.states[int32((nb<<16-t.deltaNbBits[s])>>nb)+t.deltaFindState[s]])
+}
+
+func (e *fseEncoder) encode(bw *bitWriter, s uint8) {
+	nb := (e.state + e.t.deltaNbBits[s]) >> 16
+	bw.add(uint64(e.state), uint(nb))
+	e.state = uint32(e.t.states[int32(e.state>>nb)+e.t.deltaFindState[s]])
+}
+
+// flush writes the state the decoder starts with.
+func (e *fseEncoder) flush(bw *bitWriter) {
+	bw.add(uint64(e.state), uint(e.t.log))
+}
+
+// fseDecoder decodes symbols with a table.
+type fseDecoder struct {
+	t     *fseTable
+	state uint16
+}
+
+func (d *fseDecoder) init(t *fseTable, br *bitReader) {
+	d.t = t
+	d.state = uint16(br.read(uint(t.log)))
+}
+
+func (d *fseDecoder) symbol() uint8 {
+	return d.t.symbol[d.state]
+}
+
+func (d *fseDecoder) update(br *bitReader) {
+	d.state = d.t.base[d.state] + uint16(br.read(uint(d.t.nbBits[d.state])))
+}
+
+// bitWriter writes a bitstream from the least significant bit of each
+// byte up.
+type bitWriter struct {
+	buf   []byte
+	acc   uint64
+	count uint
+}
+
+func (bw *bitWriter) add(v uint64, n uint) {
+	bw.acc |= (v & (1<<n - 1)) << bw.count
+	bw.count += n
+	for bw.count >= 8 {
+		bw.buf = append(bw.buf, byte(bw.acc))
+		bw.acc >>= 8
+		bw.count -= 8
+	}
+}
+
+// close ends the stream with a 1 bit, so the reader finds where it starts
+// reading backwards.
+func (bw *bitWriter) close() []byte {
+	bw.add(1, 1)
+	if bw.count > 0 {
+		bw.buf = append(bw.buf, byte(bw.acc))
+	}
+	return bw.buf
+}
+
+// bitReader reads a bitstream written by bitWriter backwards, the last
+// bits written first.
+type bitReader struct {
+	buf      []byte
+	pos      int // bits left to read
+	overflow bool
+}
+
+func newBitReader(buf []byte) (*bitReader, error) {
+	if len(buf) == 0 || buf[len(buf)-1] == 0 {
+		return nil, &SeekableError{"zstd bitstream has no end mark"}
+	}
+	return &bitReader{buf: buf, pos: 8*(len(buf)-1) + bits.Len8(buf[len(buf)-1]) - 1}, nil
+}
+
+func (br *bitReader) read(n uint) uint64 {
+	if n == 0 {
+		return 0
+	}
+	if int(n) > br.pos {
+		br.overflow, br.pos = true, 0
+		return 0
+	}
+	br.pos -= int(n)
+	var word [8]byte
+	copy(word[:], br.buf[br.pos/8:])
+	return binary.LittleEndian.Uint64(word[:]) >> uint(br.pos%8) & (1<<n - 1)
+}
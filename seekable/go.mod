@@ -0,0 +1,3 @@
+module github.com/RajeshGottlieb/go/seekable
+
+go 1.15
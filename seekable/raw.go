@@ -0,0 +1,153 @@
+package seekable
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	frameMagic   = 0xFD2FB528
+	maxBlockSize = 128 << 10
+
+	blockRaw        = 0
+	blockRLE        = 1
+	blockCompressed = 2
+)
+
+// Raw stores data in zstd frames of raw blocks, uncompressed. The files
+// are valid zstd that any decoder reads, which makes them useful for
+// testing or where the seek table matters more than the size. As a
+// Decompressor it reads what Raw and Zstd write.
+type Raw struct{}
+
+// Compress writes src as a single segment frame with its content size.
+func (Raw) Compress(dst, src []byte) ([]byte, error) {
+
+	dst = appendFrameHeader(dst, len(src))
+	for {
+		n := len(src)
+		if n > maxBlockSize {
+			n = maxBlockSize
+		}
+		dst = appendBlockHeader(dst, blockRaw, n, n == len(src))
+		dst = append(dst, src[:n]...)
+		src = src[n:]
+		if len(src) == 0 {
+			return dst, nil
+		}
+	}
+}
+
+// Decompress reads a frame of raw, RLE and compressed blocks, the latter
+// only as Zstd writes them.
+func (Raw) Decompress(dst, frame []byte) ([]byte, error) {
+	return decompress(dst, frame)
+}
+
+// appendFrameHeader appends the header of a single segment frame with an
+// 8 byte content size and no checksum.
+func appendFrameHeader(dst []byte, size int) []byte {
+	hdr := make([]byte, 13)
+	binary.LittleEndian.PutUint32(hdr[0:], frameMagic)
+	hdr[4] = 3<<6 | 1<<5 // 8 byte content size, single segment
+	binary.LittleEndian.PutUint64(hdr[5:], uint64(size))
+	return append(dst, hdr...)
+}
+
+func appendBlockHeader(dst []byte, blockType int, size int, last bool) []byte {
+	bh := uint32(size)<<3 | uint32(blockType)<<1
+	if last {
+		bh |= 1
+	}
+	return append(dst, byte(bh), byte(bh>>8), byte(bh>>16))
+}
+
+// decompress reads a frame of raw, RLE and compressed blocks.
+func decompress(dst, frame []byte) ([]byte, error) {
+
+	if len(frame) < 5 || binary.LittleEndian.Uint32(frame) != frameMagic {
+		return nil, &SeekableError{"not a zstd frame"}
+	}
+	desc := frame[4]
+	single := desc>>5&1 == 1
+	hasChecksum := desc>>2&1 == 1
+
+	pos := 5
+	if !single {
+		pos++ // window descriptor
+	}
+	pos += []int{0, 1, 2, 4}[desc&3] // dictionary ID
+	fcsSize := []int{0, 2, 4, 8}[desc>>6]
+	if fcsSize == 0 && single {
+		fcsSize = 1
+	}
+	if pos+fcsSize > len(frame) {
+		return nil, &SeekableError{"zstd frame is truncated"}
+	}
+	contentSize := int64(-1)
+	switch fcsSize {
+	case 1:
+		contentSize = int64(frame[pos])
+	case 2:
+		contentSize = int64(binary.LittleEndian.Uint16(frame[pos:])) + 256
+	case 4:
+		contentSize = int64(binary.LittleEndian.Uint32(frame[pos:]))
+	case 8:
+		contentSize = int64(binary.LittleEndian.Uint64(frame[pos:]))
+	}
+	pos += fcsSize
+
+	start := len(dst)
+	rep := [3]int{1, 4, 8} // repeat offsets, kept across the blocks of a frame
+	for {
+		if pos+3 > len(frame) {
+			return nil, &SeekableError{"zstd frame is truncated"}
+		}
+		bh := uint32(frame[pos]) | uint32(frame[pos+1])<<8 | uint32(frame[pos+2])<<16
+		pos += 3
+		last := bh&1 == 1
+		size := int(bh >> 3)
+
+		switch bh >> 1 & 3 {
+		case blockRaw:
+			if pos+size > len(frame) {
+				return nil, &SeekableError{"zstd frame is truncated"}
+			}
+			dst = append(dst, frame[pos:pos+size]...)
+			pos += size
+		case blockRLE:
+			if pos+1 > len(frame) {
+				return nil, &SeekableError{"zstd frame is truncated"}
+			}
+			for i := 0; i < size; i++ {
+				dst = append(dst, frame[pos])
+			}
+			pos++
+		case blockCompressed:
+			if pos+size > len(frame) {
+				return nil, &SeekableError{"zstd frame is truncated"}
+			}
+			var err error
+			if dst, err = decompressBlock(dst, start, frame[pos:pos+size], &rep); err != nil {
+				return nil, err
+			}
+			pos += size
+		default:
+			return nil, &SeekableError{"reserved zstd block type"}
+		}
+		if last {
+			break
+		}
+	}
+
+	if hasChecksum {
+		pos += 4
+	}
+	if pos != len(frame) {
+		return nil, &SeekableError{fmt.Sprintf("%v bytes after the zstd frame", len(frame)-pos)}
+	}
+	if contentSize >= 0 && int64(len(dst)-start) != contentSize {
+		return nil, &SeekableError{fmt.Sprintf("zstd frame decompressed to %v bytes, its header says %v", len(dst)-start, contentSize)}
+	}
+	return dst, nil
+}
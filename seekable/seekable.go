@@ -0,0 +1,282 @@
+// Package seekable writes and reads the zstd seekable format: a capture
+// compressed as independent zstd frames followed by a seek table, so any
+// part of it can be decompressed without the frames before it, and
+// pcapng.BuildIndex and Index.ReadBlock work on compressed files.
+//
+// Zstd compresses with the predefined tables of the format and Raw
+// stores the data uncompressed, both in frames any zstd decoder reads.
+// For better compression give it another, e.g. that of
+// github.com/klauspost/compress/zstd.
+package seekable
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// SeekableError is returned for frames and seek tables that are corrupt or
+// cannot be written, and for writes to a closed Writer.
+type SeekableError struct {
+	errorString string
+}
+
+func (se *SeekableError) Error() string {
+	return se.errorString
+}
+
+const (
+	skippableMagic = 0x184D2A5E // skippable frame holding the seek table
+	seekableMagic  = 0x8F92EAB1 // last 4 bytes of the file
+	footerSize     = 9
+	entrySize      = 8 // without checksums
+)
+
+// DefaultFrameSize is the amount of data compressed into each frame.
+const DefaultFrameSize = 1 << 20
+
+// Compressor compresses src into one zstd frame appended to dst.
+type Compressor interface {
+	Compress(dst, src []byte) ([]byte, error)
+}
+
+// Decompressor decompresses one zstd frame appended to dst.
+type Decompressor interface {
+	Decompress(dst, frame []byte) ([]byte, error)
+}
+
+// Frame locates one frame in the compressed and the decompressed data.
+type Frame struct {
+	CompressedOffset   int64
+	CompressedSize     uint32
+	DecompressedOffset int64
+	DecompressedSize   uint32
+}
+
+// Writer compresses what is written to it into frames of about FrameSize.
+// A frame only ends after a Write, so given one pcapng block per Write,
+// as PcapngWriter does, no block is split across frames.
+type Writer struct {
+	FrameSize int
+
+	w      io.Writer
+	c      Compressor
+	buf    []byte
+	frames []Frame
+	offset int64 // compressed bytes written
+	size   int64 // decompressed bytes written
+	err    error
+}
+
+// NewWriter returns a Writer compressing to w with c.
+func NewWriter(w io.Writer, c Compressor) *Writer {
+	return &Writer{FrameSize: DefaultFrameSize, w: w, c: c}
+}
+
+// Write buffers b, compressing a frame once FrameSize is reached.
+func (sw *Writer) Write(b []byte) (int, error) {
+	if sw.err != nil {
+		return 0, sw.err
+	}
+	sw.buf = append(sw.buf, b...)
+	if len(sw.buf) >= sw.FrameSize {
+		if err := sw.Flush(); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// Flush ends the current frame.
+func (sw *Writer) Flush() error {
+
+	if sw.err != nil || len(sw.buf) == 0 {
+		return sw.err
+	}
+	if int64(len(sw.buf)) > 0xffffffff {
+		sw.err = &SeekableError{fmt.Sprintf("frame of %v bytes is too large", len(sw.buf))}
+		return sw.err
+	}
+
+	frame, err := sw.c.Compress(nil, sw.buf)
+	if err == nil && int64(len(frame)) > 0xffffffff {
+		err = &SeekableError{fmt.Sprintf("compressed frame of %v bytes is too large", len(frame))}
+	}
+	if err == nil {
+		_, err = sw.w.Write(frame)
+	}
+	if err != nil {
+		sw.err = err
+		return err
+	}
+
+	sw.frames = append(sw.frames, Frame{sw.offset, uint32(len(frame)), sw.size, uint32(len(sw.buf))})
+	sw.offset += int64(len(frame))
+	sw.size += int64(len(sw.buf))
+	sw.buf = sw.buf[:0]
+	return nil
+}
+
+// Frames returns the frames written so far.
+func (sw *Writer) Frames() []Frame {
+	return sw.frames
+}
+
+// Close ends the last frame and writes the seek table. It does not close
+// the underlying writer.
+func (sw *Writer) Close() error {
+
+	if err := sw.Flush(); err != nil {
+		return err
+	}
+
+	table := make([]byte, 8+len(sw.frames)*entrySize+footerSize)
+	binary.LittleEndian.PutUint32(table[0:], skippableMagic)
+	binary.LittleEndian.PutUint32(table[4:], uint32(len(table)-8))
+	b := table[8:]
+	for _, f := range sw.frames {
+		binary.LittleEndian.PutUint32(b[0:], f.CompressedSize)
+		binary.LittleEndian.PutUint32(b[4:], f.DecompressedSize)
+		b = b[entrySize:]
+	}
+	binary.LittleEndian.PutUint32(b[0:], uint32(len(sw.frames)))
+	b[4] = 0 // no checksums
+	binary.LittleEndian.PutUint32(b[5:], seekableMagic)
+
+	_, sw.err = sw.w.Write(table)
+	if sw.err == nil {
+		sw.err = &SeekableError{"writer is closed"}
+		return nil
+	}
+	return sw.err
+}
+
+// Reader reads the decompressed data of a seekable file at any offset,
+// decompressing only the frames needed. It is safe for concurrent use.
+type Reader struct {
+	Frames []Frame
+
+	r    io.ReaderAt
+	d    Decompressor
+	size int64
+
+	mu    sync.Mutex
+	last  int // frame held in data
+	data  []byte
+	frame []byte
+}
+
+// NewReader reads the seek table at the end of the first size bytes of r.
+func NewReader(r io.ReaderAt, size int64, d Decompressor) (*Reader, error) {
+
+	footer := make([]byte, footerSize)
+	if size < 8+footerSize {
+		return nil, &SeekableError{fmt.Sprintf("%v bytes is too short for a seek table", size)}
+	}
+	if _, err := r.ReadAt(footer, size-footerSize); err != nil {
+		return nil, err
+	}
+	if binary.LittleEndian.Uint32(footer[5:]) != seekableMagic {
+		return nil, &SeekableError{"no seek table, not in the seekable format"}
+	}
+	count := int64(binary.LittleEndian.Uint32(footer[0:]))
+	esize := int64(entrySize)
+	if footer[4]&0x80 != 0 {
+		esize += 4 // checksum, not verified
+	}
+	if footer[4]&0x7f != 0 {
+		return nil, &SeekableError{fmt.Sprintf("reserved bits set in seek table descriptor %#x", footer[4])}
+	}
+
+	tableSize := 8 + count*esize + footerSize
+	if tableSize > size {
+		return nil, &SeekableError{fmt.Sprintf("seek table of %v frames is larger than the file", count)}
+	}
+	table := make([]byte, tableSize)
+	if _, err := r.ReadAt(table, size-tableSize); err != nil {
+		return nil, err
+	}
+	if binary.LittleEndian.Uint32(table[0:]) != skippableMagic || int64(binary.LittleEndian.Uint32(table[4:])) != tableSize-8 {
+		return nil, &SeekableError{"seek table frame header is corrupt"}
+	}
+
+	sr := &Reader{r: r, d: d, last: -1}
+	var offset int64
+	b := table[8:]
+	for i := int64(0); i < count; i++ {
+		f := Frame{
+			CompressedOffset:   offset,
+			CompressedSize:     binary.LittleEndian.Uint32(b[0:]),
+			DecompressedOffset: sr.size,
+			DecompressedSize:   binary.LittleEndian.Uint32(b[4:]),
+		}
+		sr.Frames = append(sr.Frames, f)
+		offset += int64(f.CompressedSize)
+		sr.size += int64(f.DecompressedSize)
+		b = b[esize:]
+	}
+	if offset != size-tableSize {
+		return nil, &SeekableError{fmt.Sprintf("frames add up to %v bytes but the seek table starts at %v", offset, size-tableSize)}
+	}
+	return sr, nil
+}
+
+// Size returns the size of the decompressed data.
+func (sr *Reader) Size() int64 {
+	return sr.size
+}
+
+// ReadAt reads len(p) decompressed bytes starting at off.
+func (sr *Reader) ReadAt(p []byte, off int64) (int, error) {
+
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	n := 0
+	for n < len(p) {
+		if off >= sr.size {
+			return n, io.EOF
+		}
+		i := sort.Search(len(sr.Frames), func(i int) bool {
+			f := sr.Frames[i]
+			return f.DecompressedOffset+int64(f.DecompressedSize) > off
+		})
+		if err := sr.load(i); err != nil {
+			return n, err
+		}
+		c := copy(p[n:], sr.data[off-sr.Frames[i].DecompressedOffset:])
+		n += c
+		off += int64(c)
+	}
+	return n, nil
+}
+
+// load decompresses frame i unless it is the one already held.
+func (sr *Reader) load(i int) error {
+
+	if i == sr.last {
+		return nil
+	}
+	f := sr.Frames[i]
+	if cap(sr.frame) < int(f.CompressedSize) {
+		sr.frame = make([]byte, f.CompressedSize)
+	}
+	sr.frame = sr.frame[:f.CompressedSize]
+	if _, err := sr.r.ReadAt(sr.frame, f.CompressedOffset); err != nil {
+		return err
+	}
+
+	data, err := sr.d.Decompress(sr.data[:0], sr.frame)
+	if err != nil {
+		sr.last = -1
+		return err
+	}
+	if len(data) != int(f.DecompressedSize) {
+		sr.last = -1
+		return &SeekableError{fmt.Sprintf("frame %v decompressed to %v bytes, the seek table says %v", i, len(data), f.DecompressedSize)}
+	}
+	sr.data, sr.last = data, i
+	return nil
+}
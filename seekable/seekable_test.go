@@ -0,0 +1,249 @@
+package seekable
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+// testData returns inputs that exercise raw, compressed and mixed blocks.
+func testData() []struct {
+	name string
+	data []byte
+} {
+	rnd := rand.New(rand.NewSource(1))
+	random := func(n int) []byte {
+		b := make([]byte, n)
+		rnd.Read(b)
+		return b
+	}
+
+	// packets that differ only in a few bytes, like a capture of one flow
+	packets := make([]byte, 0, 300<<10)
+	packet := random(1500)
+	for len(packets) < 300<<10 {
+		packet[40], packet[41] = byte(rnd.Intn(256)), byte(rnd.Intn(256))
+		packets = append(packets, packet[:60+rnd.Intn(len(packet)-60)]...)
+	}
+
+	// a repeat far back, beyond the block it is in
+	far := random(200 << 10)
+	far = append(far, far[1000:50000]...)
+
+	// text with short literal runs between short matches
+	var text []byte
+	words := []string{"GET ", "HTTP/1.1", "Host: ", "example.com", "\r\n", "Accept: */*", "/index.html "}
+	for len(text) < 100<<10 {
+		text = append(text, words[rnd.Intn(len(words))]...)
+		text = append(text, byte('a'+rnd.Intn(26)))
+	}
+
+	return []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"one byte", []byte{7}},
+		{"shorter than a match", []byte("abc")},
+		{"random", random(100 << 10)},
+		{"zeros", make([]byte, 100<<10)},
+		{"packets", packets},
+		{"far repeat", far},
+		{"text", text},
+		{"one block", bytes.Repeat([]byte("0123456789abcdef"), maxBlockSize/16)},
+		{"one block and a byte", bytes.Repeat([]byte("x"), maxBlockSize+1)},
+		{"a byte short of two blocks", random(2*maxBlockSize - 1)},
+		{"two blocks", append(random(maxBlockSize), make([]byte, maxBlockSize)...)},
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+
+	codecs := []struct {
+		name string
+		c    Compressor
+		d    Decompressor
+	}{
+		{"Raw", Raw{}, Raw{}},
+		{"Zstd", Zstd{}, Zstd{}},
+	}
+
+	for _, codec := range codecs {
+		for _, tt := range testData() {
+			frame, err := codec.c.Compress([]byte("prefix"), tt.data)
+			if err != nil {
+				t.Errorf("%v %v: Compress: %v", codec.name, tt.name, err)
+				continue
+			}
+			if string(frame[:6]) != "prefix" {
+				t.Errorf("%v %v: Compress did not append to dst", codec.name, tt.name)
+				continue
+			}
+			got, err := codec.d.Decompress([]byte("prefix"), frame[6:])
+			if err != nil {
+				t.Errorf("%v %v: Decompress: %v", codec.name, tt.name, err)
+				continue
+			}
+			if string(got[:6]) != "prefix" || !bytes.Equal(got[6:], tt.data) {
+				t.Errorf("%v %v: round trip of %v bytes gave %v different bytes", codec.name, tt.name, len(tt.data), len(got)-6)
+			}
+		}
+	}
+}
+
+func TestZstdCompresses(t *testing.T) {
+
+	for _, tt := range testData() {
+		if len(tt.data) < 1000 || tt.name == "random" || tt.name == "a byte short of two blocks" {
+			continue
+		}
+		frame, err := Zstd{}.Compress(nil, tt.data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		limit := len(tt.data) * 3 / 4
+		if tt.name == "far repeat" {
+			limit = 200<<10 + 1000 // only the repeat shrinks
+		}
+		if len(frame) >= limit {
+			t.Errorf("%v: %v bytes compressed to %v", tt.name, len(tt.data), len(frame))
+		}
+	}
+
+	// data that does not shrink is stored, not expanded
+	random := testData()[3]
+	frame, err := Zstd{}.Compress(nil, random.data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frame) > len(random.data)+13+3 {
+		t.Errorf("random: %v bytes compressed to %v", len(random.data), len(frame))
+	}
+}
+
+func TestDecompressCorrupt(t *testing.T) {
+
+	frame, err := Zstd{}.Compress(nil, bytes.Repeat([]byte("corrupt me "), 1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		frame []byte
+	}{
+		{"empty", nil},
+		{"bad magic", append([]byte{0}, frame[1:]...)},
+		{"header only", frame[:13]},
+		{"truncated", frame[:len(frame)-1]},
+	}
+
+	for _, tt := range tests {
+		if _, err := (Zstd{}).Decompress(nil, tt.frame); err == nil {
+			t.Errorf("%v: no error", tt.name)
+		}
+	}
+}
+
+func TestReaderAt(t *testing.T) {
+
+	for _, tt := range testData() {
+		for _, c := range []Compressor{Raw{}, Zstd{}} {
+			var buf bytes.Buffer
+			sw := NewWriter(&buf, c)
+			sw.FrameSize = 10000
+			// uneven writes, a frame only ends after one
+			for b := tt.data; len(b) > 0; {
+				n := 3333
+				if n > len(b) {
+					n = len(b)
+				}
+				if _, err := sw.Write(b[:n]); err != nil {
+					t.Fatal(err)
+				}
+				b = b[n:]
+			}
+			if err := sw.Close(); err != nil {
+				t.Fatalf("%v: Close: %v", tt.name, err)
+			}
+			if _, err := sw.Write([]byte{1}); err == nil {
+				t.Errorf("%v: Write after Close succeeded", tt.name)
+			}
+
+			sr, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()), Zstd{})
+			if err != nil {
+				t.Fatalf("%v: NewReader: %v", tt.name, err)
+			}
+			if sr.Size() != int64(len(tt.data)) {
+				t.Errorf("%v: Size is %v, want %v", tt.name, sr.Size(), len(tt.data))
+				continue
+			}
+			if len(sr.Frames) != len(sw.Frames()) {
+				t.Errorf("%v: read %v frames, wrote %v", tt.name, len(sr.Frames), len(sw.Frames()))
+			}
+
+			// whole, across every frame boundary, and backwards
+			all := make([]byte, len(tt.data))
+			if _, err := sr.ReadAt(all, 0); err != nil || !bytes.Equal(all, tt.data) {
+				t.Errorf("%v: ReadAt of everything: %v", tt.name, err)
+			}
+			for i := len(sr.Frames) - 1; i > 0; i-- {
+				off := sr.Frames[i].DecompressedOffset - 5
+				p := make([]byte, 10)
+				n, err := sr.ReadAt(p, off)
+				if want := tt.data[off:]; len(want) > 10 {
+					want = want[:10]
+					if err != nil || !bytes.Equal(p, want) {
+						t.Errorf("%v: ReadAt across frame %v: %v", tt.name, i, err)
+					}
+				} else if err != io.EOF || !bytes.Equal(p[:n], want) {
+					t.Errorf("%v: ReadAt across the last frame: %v bytes, %v", tt.name, n, err)
+				}
+			}
+
+			// past the end
+			p := make([]byte, 10)
+			if n, err := sr.ReadAt(p, sr.Size()); n != 0 || err != io.EOF {
+				t.Errorf("%v: ReadAt at the end is %v, %v", tt.name, n, err)
+			}
+		}
+	}
+}
+
+func TestNewReaderCorrupt(t *testing.T) {
+
+	var buf bytes.Buffer
+	sw := NewWriter(&buf, Raw{})
+	sw.FrameSize = 100
+	for i := 0; i < 10; i++ {
+		sw.Write(bytes.Repeat([]byte{byte(i)}, 100))
+	}
+	if err := sw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	good := buf.Bytes()
+
+	corrupt := func(at int, v byte) []byte {
+		b := append([]byte(nil), good...)
+		b[at] = v
+		return b
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"no seek table", good[:len(good)-1]},
+		{"reserved bits", corrupt(len(good)-5, 1)},
+		{"too many frames", corrupt(len(good)-9, 0xff)},
+		{"frame sizes", corrupt(len(good)-footerSize-entrySize, 0)},
+	}
+
+	for _, tt := range tests {
+		if _, err := NewReader(bytes.NewReader(tt.data), int64(len(tt.data)), Raw{}); err == nil {
+			t.Errorf("%v: no error", tt.name)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package seekable
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+)
+
+const (
+	hashLog   = 16
+	minMatch  = 4
+	maxOffset = 1<<28 - 4 // the largest the predefined offset codes encode
+)
+
+// Zstd compresses data into zstd frames any decoder reads. It finds
+// repeats with a single hash probe and codes them with the predefined
+// tables of the format, leaving literals uncompressed, so it is fast but
+// compresses less than the zstd library; captures of similar packets
+// still shrink well. Blocks that do not shrink are stored raw. As a
+// Decompressor it reads what Raw and Zstd write, not the Huffman coded
+// literals and custom tables of other encoders.
+type Zstd struct{}
+
+// sequence is a run of literals followed by a match.
+type sequence struct {
+	literals int
+	match    int
+	offset   int
+}
+
+// Compress writes src as a single segment frame with its content size.
+func (Zstd) Compress(dst, src []byte) ([]byte, error) {
+
+	dst = appendFrameHeader(dst, len(src))
+	table := make([]int32, 1<<hashLog) // last position+1 of each hash
+	for start := 0; ; {
+		end := start + maxBlockSize
+		if end > len(src) {
+			end = len(src)
+		}
+		last := end == len(src)
+		if block := compressBlock(src, start, end, table); block != nil {
+			dst = appendBlockHeader(dst, blockCompressed, len(block), last)
+			dst = append(dst, block...)
+		} else {
+			dst = appendBlockHeader(dst, blockRaw, end-start, last)
+			dst = append(dst, src[start:end]...)
+		}
+		if last {
+			return dst, nil
+		}
+		start = end
+	}
+}
+
+// Decompress reads a frame of raw, RLE and compressed blocks, the latter
+// only as Zstd writes them.
+func (Zstd) Decompress(dst, frame []byte) ([]byte, error) {
+	return decompress(dst, frame)
+}
+
+func hash4(b []byte) uint32 {
+	return binary.LittleEndian.Uint32(b) * 2654435761 >> (32 - hashLog)
+}
+
+// compressBlock compresses src[start:end], matching against all of src
+// before end. It returns nil if the block does not shrink.
+func compressBlock(src []byte, start, end int, table []int32) []byte {
+
+	var literals []byte
+	var seqs []sequence
+	from := start // of the pending literals
+	for i := start; i+minMatch <= end; {
+		h := hash4(src[i:])
+		candidate := int(table[h]) - 1
+		table[h] = int32(i + 1)
+		if candidate < 0 || i-candidate > maxOffset || binary.LittleEndian.Uint32(src[candidate:]) != binary.LittleEndian.Uint32(src[i:]) {
+			i++
+			continue
+		}
+		n := minMatch
+		for i+n < end && src[candidate+n] == src[i+n] {
+			n++
+		}
+		literals = append(literals, src[from:i]...)
+		seqs = append(seqs, sequence{i - from, n, i - candidate})
+		i += n
+		from = i
+	}
+	if len(seqs) == 0 {
+		return nil
+	}
+	literals = append(literals, src[from:end]...)
+
+	// literals section, raw
+	var block []byte
+	switch n := len(literals); {
+	case n < 32:
+		block = append(block, byte(n<<3))
+	case n < 4096:
+		block = append(block, byte(1<<2|n<<4), byte(n>>4))
+	default:
+		block = append(block, byte(3<<2|n<<4), byte(n>>4), byte(n>>12))
+	}
+	block = append(block, literals...)
+
+	// sequences section header, all three codes with the predefined tables
+	switch n := len(seqs); {
+	case n < 128:
+		block = append(block, byte(n))
+	case n < 0x7f00:
+		block = append(block, byte(n>>8+128), byte(n))
+	default:
+		block = append(block, 255, byte(n-0x7f00), byte((n-0x7f00)>>8))
+	}
+	block = append(block, 0)
+
+	n := len(seqs)
+	llCodes, mlCodes, ofCodes := make([]uint8, n), make([]uint8, n), make([]uint8, n)
+	for k, s := range seqs {
+		llCodes[k] = code(llBase, uint32(s.literals))
+		mlCodes[k] = code(mlBase, uint32(s.match))
+		ofCodes[k] = uint8(bits.Len(uint(s.offset+3)) - 1)
+	}
+	extra := func(bw *bitWriter, k int) {
+		s := seqs[k]
+		bw.add(uint64(uint32(s.literals)-llBase[llCodes[k]]), uint(llBits[llCodes[k]]))
+		bw.add(uint64(uint32(s.match)-mlBase[mlCodes[k]]), uint(mlBits[mlCodes[k]]))
+		bw.add(uint64(s.offset+3), uint(ofCodes[k]))
+	}
+
+	// the decoder reads backwards, so the last sequence goes first
+	var bw bitWriter
+	var ll, ml, of fseEncoder
+	ml.init(mlTable, mlCodes[n-1])
+	of.init(ofTable, ofCodes[n-1])
+	ll.init(llTable, llCodes[n-1])
+	extra(&bw, n-1)
+	for k := n - 2; k >= 0; k-- {
+		of.encode(&bw, ofCodes[k])
+		ml.encode(&bw, mlCodes[k])
+		ll.encode(&bw, llCodes[k])
+		extra(&bw, k)
+	}
+	ml.flush(&bw)
+	of.flush(&bw)
+	ll.flush(&bw)
+	block = append(block, bw.close()...)
+
+	if len(block) >= end-start {
+		return nil
+	}
+	return block
+}
+
+// decompressBlock appends the content of a compressed block to dst, the
+// frame starting at start. rep holds the repeat offsets.
+func decompressBlock(dst []byte, start int, block []byte, rep *[3]int) ([]byte, error) {
+
+	if len(block) < 1 {
+		return nil, &SeekableError{"zstd block is truncated"}
+	}
+	literalsType := block[0] & 3
+	if literalsType > blockRLE {
+		return nil, &SeekableError{"zstd block has Huffman coded literals, which need the zstd library"}
+	}
+	var size, pos int
+	switch block[0] >> 2 & 3 {
+	case 0, 2:
+		size, pos = int(block[0]>>3), 1
+	case 1:
+		if len(block) < 2 {
+			return nil, &SeekableError{"zstd block is truncated"}
+		}
+		size, pos = int(block[0]>>4)|int(block[1])<<4, 2
+	case 3:
+		if len(block) < 3 {
+			return nil, &SeekableError{"zstd block is truncated"}
+		}
+		size, pos = int(block[0]>>4)|int(block[1])<<4|int(block[2])<<12, 3
+	}
+	var literals []byte
+	if literalsType == blockRaw {
+		if pos+size > len(block) {
+			return nil, &SeekableError{"zstd literals are truncated"}
+		}
+		literals = block[pos : pos+size]
+		pos += size
+	} else {
+		if pos+1 > len(block) {
+			return nil, &SeekableError{"zstd literals are truncated"}
+		}
+		literals = make([]byte, size)
+		for i := range literals {
+			literals[i] = block[pos]
+		}
+		pos++
+	}
+
+	if pos >= len(block) {
+		return nil, &SeekableError{"zstd block has no sequences section"}
+	}
+	n := int(block[pos])
+	switch {
+	case n == 0:
+		if pos+1 != len(block) {
+			return nil, &SeekableError{"zstd block has bytes after its literals"}
+		}
+		return append(dst, literals...), nil
+	case n < 128:
+		pos++
+	case n < 255:
+		if pos+2 > len(block) {
+			return nil, &SeekableError{"zstd block is truncated"}
+		}
+		n, pos = (n-128)<<8|int(block[pos+1]), pos+2
+	default:
+		if pos+3 > len(block) {
+			return nil, &SeekableError{"zstd block is truncated"}
+		}
+		n, pos = int(block[pos+1])|int(block[pos+2])<<8+0x7f00, pos+3
+	}
+	if pos >= len(block) {
+		return nil, &SeekableError{"zstd block is truncated"}
+	}
+	if block[pos] != 0 {
+		return nil, &SeekableError{fmt.Sprintf("zstd block has compression modes %#x, only the predefined tables are read", block[pos])}
+	}
+	br, err := newBitReader(block[pos+1:])
+	if err != nil {
+		return nil, err
+	}
+
+	var ll, ml, of fseDecoder
+	ll.init(llTable, br)
+	of.init(ofTable, br)
+	ml.init(mlTable, br)
+	for k := 0; k < n; k++ {
+		ofCode, mlCode, llCode := of.symbol(), ml.symbol(), ll.symbol()
+		offsetValue := 1<<ofCode + int(br.read(uint(ofCode)))
+		match := int(mlBase[mlCode]) + int(br.read(uint(mlBits[mlCode])))
+		literalLength := int(llBase[llCode]) + int(br.read(uint(llBits[llCode])))
+		if k != n-1 {
+			ll.update(br)
+			ml.update(br)
+			of.update(br)
+		}
+		if br.overflow {
+			return nil, &SeekableError{"zstd sequences are truncated"}
+		}
+
+		var offset int
+		if offsetValue > 3 {
+			offset = offsetValue - 3
+			rep[0], rep[1], rep[2] = offset, rep[0], rep[1]
+		} else {
+			i := offsetValue - 1
+			if literalLength == 0 {
+				i++
+			}
+			switch i {
+			case 0:
+				offset = rep[0]
+			case 1:
+				offset = rep[1]
+				rep[0], rep[1] = rep[1], rep[0]
+			case 2:
+				offset = rep[2]
+				rep[0], rep[1], rep[2] = rep[2], rep[0], rep[1]
+			case 3:
+				offset = rep[0] - 1
+				rep[0], rep[1], rep[2] = offset, rep[0], rep[1]
+			}
+		}
+
+		if literalLength > len(literals) {
+			return nil, &SeekableError{"zstd sequence has more literals than the block"}
+		}
+		dst = append(dst, literals[:literalLength]...)
+		literals = literals[literalLength:]
+		if offset <= 0 || offset > len(dst)-start {
+			return nil, &SeekableError{fmt.Sprintf("zstd match offset %v is outside the frame", offset)}
+		}
+		from := len(dst) - offset
+		for i := 0; i < match; i++ {
+			dst = append(dst, dst[from+i])
+		}
+	}
+	if br.pos != 0 {
+		return nil, &SeekableError{"zstd sequences have bits left over"}
+	}
+	return append(dst, literals...), nil
+}
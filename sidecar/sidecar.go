@@ -0,0 +1,211 @@
+// Package sidecar keeps analysis annotations of a capture in a file of its
+// own, so they can be added without rewriting the capture.
+package sidecar
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/RajeshGottlieb/go/pcapng"
+)
+
+// SidecarError
+type SidecarError struct {
+	errorString string
+}
+
+func (se *SidecarError) Error() string {
+	return se.errorString
+}
+
+// Annotation is one line of a sidecar file, a JSON object such as
+//
+//	{"packet":12,"offset":4096,"source":"ids","text":"ET SCAN nmap","data":{"sid":2000537}}
+//
+// Packet is the 1 based packet number the annotation belongs to. Offset is
+// that of the packet block in the capture, 0 if unknown; when set, joining
+// checks it, so a sidecar is not applied to another capture by mistake.
+type Annotation struct {
+	Packet int             `json:"packet"`
+	Offset int64           `json:"offset,omitempty"`
+	Source string          `json:"source,omitempty"` // the tool or analyst
+	Text   string          `json:"text"`
+	Data   json.RawMessage `json:"data,omitempty"` // anything else the source wants to keep
+}
+
+// Writer writes annotations as JSON Lines.
+type Writer struct {
+	enc *json.Encoder
+}
+
+// NewWriter returns a Writer writing to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{json.NewEncoder(w)}
+}
+
+// Write writes one annotation.
+func (w *Writer) Write(a Annotation) error {
+	if a.Packet < 1 {
+		return &SidecarError{fmt.Sprintf("annotation %q has no packet number", a.Text)}
+	}
+	return w.enc.Encode(&a)
+}
+
+// Sidecar holds the annotations of a capture by packet number.
+type Sidecar struct {
+	packets map[int][]Annotation
+}
+
+// New returns an empty Sidecar.
+func New() *Sidecar {
+	return &Sidecar{packets: make(map[int][]Annotation)}
+}
+
+// Add adds an annotation.
+func (s *Sidecar) Add(a Annotation) {
+	s.packets[a.Packet] = append(s.packets[a.Packet], a)
+}
+
+// Packet returns the annotations of a packet in the order they were added.
+func (s *Sidecar) Packet(number int) []Annotation {
+	return s.packets[number]
+}
+
+// Annotations returns every annotation ordered by packet number.
+func (s *Sidecar) Annotations() []Annotation {
+	var numbers []int
+	for n := range s.packets {
+		numbers = append(numbers, n)
+	}
+	sort.Ints(numbers)
+
+	var all []Annotation
+	for _, n := range numbers {
+		all = append(all, s.packets[n]...)
+	}
+	return all
+}
+
+// Read reads a sidecar file. Blank lines are skipped.
+func Read(r io.Reader) (*Sidecar, error) {
+
+	s := New()
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var a Annotation
+		if err := json.Unmarshal(scanner.Bytes(), &a); err != nil {
+			return nil, &SidecarError{fmt.Sprintf("line %v: %v", line, err)}
+		}
+		if a.Packet < 1 {
+			return nil, &SidecarError{fmt.Sprintf("line %v: no packet number", line)}
+		}
+		s.Add(a)
+	}
+	return s, scanner.Err()
+}
+
+// Write writes every annotation ordered by packet number.
+func (s *Sidecar) Write(w io.Writer) error {
+	sw := NewWriter(w)
+	for _, a := range s.Annotations() {
+		if err := sw.Write(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FromComments returns a Sidecar holding the opt_comment of every packet
+// of r, with source "comment", e.g. to move them out of the capture.
+func FromComments(r io.Reader) (*Sidecar, error) {
+	comments, err := pcapng.Comments(r)
+	if err != nil {
+		return nil, err
+	}
+	s := New()
+	for _, c := range comments {
+		if c.Packet > 0 {
+			s.Add(Annotation{Packet: c.Packet, Offset: c.Offset, Source: "comment", Text: c.Text})
+		}
+	}
+	return s, nil
+}
+
+// Reader joins the annotations of a Sidecar to the blocks of a capture
+// while reading it.
+type Reader struct {
+	pr *pcapng.PcapngReader
+	s  *Sidecar
+}
+
+// NewReader returns a Reader reading blocks from pr.
+func NewReader(pr *pcapng.PcapngReader, s *Sidecar) *Reader {
+	return &Reader{pr, s}
+}
+
+// Read returns the next block and, for packets, their annotations. An
+// annotation whose offset differs from that of its packet is an error.
+func (r *Reader) Read() (interface{}, []Annotation, error) {
+
+	block, err := r.pr.Read()
+	if err != nil {
+		return nil, nil, err
+	}
+	m := r.pr.Metadata()
+	if m.Packet == 0 {
+		return block, nil, nil
+	}
+
+	annotations := r.s.Packet(m.Packet)
+	for _, a := range annotations {
+		if a.Offset != 0 && a.Offset != m.Offset {
+			return nil, nil, &SidecarError{fmt.Sprintf("annotation %q of packet %v is for offset 0x%08x, the packet is at 0x%08x: the sidecar belongs to another capture",
+				a.Text, m.Packet, a.Offset, m.Offset)}
+		}
+	}
+	return block, annotations, nil
+}
+
+// Comment formats an annotation as the opt_comment Bake adds, the text
+// prefixed by the source if there is one.
+func Comment(a Annotation) string {
+	if a.Source == "" {
+		return a.Text
+	}
+	return a.Source + ": " + a.Text
+}
+
+// Bake copies the capture of pr to pw, adding each annotation of s to its
+// Enhanced Packet Block as an opt_comment. The annotations of Simple
+// Packet Blocks, which have no options, are left out. It returns the
+// number of comments added.
+func Bake(pr *pcapng.PcapngReader, pw *pcapng.PcapngWriter, s *Sidecar) (int, error) {
+
+	r := NewReader(pr, s)
+	added := 0
+	for {
+		block, annotations, err := r.Read()
+		if err == io.EOF {
+			return added, nil
+		} else if err != nil {
+			return added, err
+		}
+
+		if b, ok := block.(*pcapng.EnhancedPacketBlock); ok {
+			for _, a := range annotations {
+				b.WithComment(Comment(a))
+				added++
+			}
+		}
+		if err := pw.Write(block.(pcapng.Block)); err != nil {
+			return added, err
+		}
+	}
+}
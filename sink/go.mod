@@ -0,0 +1,12 @@
+module github.com/RajeshGottlieb/go/sink
+
+go 1.15
+
+require (
+	github.com/RajeshGottlieb/go/pcap v0.0.0-00010101000000-000000000000
+	github.com/RajeshGottlieb/go/pcapng v0.0.0-00010101000000-000000000000
+)
+
+replace github.com/RajeshGottlieb/go/pcap => ../pcap
+
+replace github.com/RajeshGottlieb/go/pcapng => ../pcapng
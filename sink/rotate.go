@@ -0,0 +1,351 @@
+package sink
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/RajeshGottlieb/go/pcapng"
+)
+
+// RotatingWriter writes packets to a series of pcapng files, starting a new
+// file when the current one reaches MaxSize bytes or when a packet crosses
+// a time boundary. Every file starts with the section header and the
+// interface blocks so it can be read on its own. Existing files are never
+// overwritten: a name already taken gets a -1, -2, ... suffix before its
+// extension.
+//
+// Boundaries are multiples of Interval counted from midnight in Location,
+// so time.Hour rotates at the top of every hour and 24*time.Hour at
+// midnight, also on days with a daylight saving change. Interval must
+// divide a day evenly, WritePacket fails otherwise. Packet timestamps decide
+// which file a packet belongs to, not the wall clock.
+//
+// MaxFiles and MaxBytes limit the files kept on disk. When a file is
+// closed the oldest files this writer wrote are deleted until there are at
+// most MaxFiles, counting the next one, and they take at most MaxBytes,
+// leaving room for a next file of MaxSize bytes.
+type RotatingWriter struct {
+	Template   string         // file name, see FormatName
+	MaxSize    int64          // bytes, 0 for no limit
+	Interval   time.Duration  // 0 for no time based rotation
+	Location   *time.Location // for boundaries and file names, nil means time.Local
+	Section    *pcapng.SectionBlock
+	Interfaces []*pcapng.InterfaceBlock
+
+	// OnRotate, if set, is called with the name of each file after it is closed.
+	OnRotate func(name string)
+
+	MaxFiles int   // 0 for no limit
+	MaxBytes int64 // 0 for no limit
+	// BeforeDelete, if set, is called with the name of each file retention
+	// is about to delete, e.g. to archive it.
+	BeforeDelete func(name string)
+
+	// Metrics, if set, counts what every file's writer writes and each rotation.
+	Metrics pcapng.Metrics
+
+	fh       *os.File
+	pw       *pcapng.PcapngWriter
+	name     string
+	size     int64
+	sequence int
+	boundary time.Time    // the current file ends here, zero if unset
+	closed   []closedFile // oldest first
+
+	// the name of the last file before any suffix, and its suffix
+	lastBase   string
+	lastSuffix int
+}
+
+// closedFile is a file written and closed, that retention may delete.
+type closedFile struct {
+	name string
+	size int64
+}
+
+// NewRotatingWriter returns a RotatingWriter writing the interfaces to
+// files named from template. The first file is created by the first packet.
+func NewRotatingWriter(template string, interfaces []*pcapng.InterfaceBlock) *RotatingWriter {
+	return &RotatingWriter{
+		Template:   template,
+		Section:    &pcapng.SectionBlock{},
+		Interfaces: interfaces,
+	}
+}
+
+func (rw *RotatingWriter) location() *time.Location {
+	if rw.Location == nil {
+		return time.Local
+	}
+	return rw.Location
+}
+
+// nextBoundary returns the first boundary after t. Boundaries are counted
+// in wall clock time, so on days with a daylight saving change they are
+// still at the same times of day and the files around the change are an
+// hour longer or shorter.
+func (rw *RotatingWriter) nextBoundary(t time.Time) time.Time {
+	t = t.In(rw.location())
+	y, m, d := t.Date()
+	wall := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second + time.Duration(t.Nanosecond())
+	for n := wall/rw.Interval + 1; ; n++ {
+		next := n * rw.Interval
+		var b time.Time
+		if next >= 24*time.Hour {
+			b = time.Date(y, m, d+1, 0, 0, 0, 0, t.Location())
+		} else {
+			b = time.Date(y, m, d, 0, 0, 0, int(next), t.Location())
+		}
+		if b.After(t) {
+			return b
+		}
+		// a time of day repeated by the clock going back resolves to its
+		// first occurrence, t may be in the second one
+		_, bOffset := b.Zone()
+		_, tOffset := t.Zone()
+		later := b.Add(time.Duration(bOffset-tOffset) * time.Second)
+		if later.After(t) && later.Hour() == b.Hour() && later.Minute() == b.Minute() &&
+			later.Second() == b.Second() && later.Nanosecond() == b.Nanosecond() {
+			return later
+		}
+		// otherwise it is a time of day skipped by the clock going
+		// forward, which normalizes to one that may not be after t
+	}
+}
+
+// WritePacket writes the packet to the current file, rotating first if needed.
+func (rw *RotatingWriter) WritePacket(meta pcapng.PacketMeta, data []byte) error {
+
+	if rw.Interval < 0 || rw.Interval > 0 && (24*time.Hour)%rw.Interval != 0 {
+		return &SinkError{fmt.Sprintf("rotation interval %v does not divide a day evenly", rw.Interval)}
+	}
+	if rw.pw != nil {
+		crossed := rw.Interval > 0 && !meta.Timestamp.Before(rw.boundary)
+		full := rw.MaxSize > 0 && rw.size >= rw.MaxSize
+		if crossed || full {
+			if err := rw.closeFile(); err != nil {
+				return err
+			}
+			if err := rw.retain(1); err != nil {
+				return err
+			}
+			if rw.Metrics != nil {
+				rw.Metrics.AddRotations(1)
+			}
+		}
+	}
+
+	if rw.pw == nil {
+		if err := rw.open(meta.Timestamp); err != nil {
+			return err
+		}
+	}
+
+	if err := rw.pw.WritePacket(meta, data); err != nil {
+		return err
+	}
+	// block header, timestamps, lengths and trailer, padded data
+	rw.size += int64(32 + (len(data)+3)&^3)
+	return nil
+}
+
+func (rw *RotatingWriter) open(t time.Time) error {
+
+	rw.sequence++
+	base := FormatName(rw.Template, t.In(rw.location()), rw.sequence)
+	first := 0
+	if base == rw.lastBase {
+		// keep the suffixes in order after retention frees earlier names
+		first = rw.lastSuffix + 1
+	}
+	fh, name, suffix, err := createUnique(base, first)
+	if err != nil {
+		return err
+	}
+	rw.lastBase, rw.lastSuffix = base, suffix
+
+	pw := pcapng.Writer(fh)
+	pw.Metrics = rw.Metrics
+	if err := pw.Write(rw.Section); err != nil {
+		fh.Close()
+		return err
+	}
+	for _, ifb := range rw.Interfaces {
+		if err := pw.Write(ifb); err != nil {
+			fh.Close()
+			return err
+		}
+	}
+
+	rw.fh, rw.pw, rw.name, rw.size = fh, pw, name, 0
+	if rw.Interval > 0 {
+		rw.boundary = rw.nextBoundary(t)
+	}
+	return nil
+}
+
+// maxCollisions bounds the suffixes createUnique tries.
+const maxCollisions = 1000
+
+// createUnique creates the file name, never truncating an existing one: if
+// name is taken, e.g. by two rotations within the resolution of the
+// template, it tries name-1.ext, name-2.ext and so on, starting at suffix
+// first. It returns the name created and its suffix, 0 for none.
+func createUnique(name string, first int) (*os.File, string, int, error) {
+
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for n := first; n <= first+maxCollisions; n++ {
+		try := name
+		if n > 0 {
+			try = fmt.Sprintf("%v-%v%v", base, n, ext)
+		}
+		fh, err := os.OpenFile(try, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+		if err == nil {
+			return fh, try, n, nil
+		}
+		if !os.IsExist(err) {
+			return nil, "", 0, err
+		}
+	}
+	return nil, "", 0, &os.PathError{Op: "create", Path: name, Err: os.ErrExist}
+}
+
+func (rw *RotatingWriter) closeFile() error {
+
+	size := rw.size
+	if info, err := rw.fh.Stat(); err == nil {
+		size = info.Size()
+	}
+	err := rw.fh.Close()
+	name := rw.name
+	rw.fh, rw.pw, rw.name = nil, nil, ""
+	if err != nil {
+		return err
+	}
+	// a name deleted behind our back and created again is tracked once
+	for i, f := range rw.closed {
+		if f.name == name {
+			rw.closed = append(rw.closed[:i], rw.closed[i+1:]...)
+			break
+		}
+	}
+	rw.closed = append(rw.closed, closedFile{name, size})
+	if rw.OnRotate != nil {
+		rw.OnRotate(name)
+	}
+	return nil
+}
+
+// retain deletes the oldest files until those closed plus next files to
+// come fit in MaxFiles and MaxBytes.
+func (rw *RotatingWriter) retain(next int) error {
+
+	var total int64
+	for _, f := range rw.closed {
+		total += f.size
+	}
+	reserve := int64(next) * rw.MaxSize
+
+	for len(rw.closed) > 0 {
+		tooMany := rw.MaxFiles > 0 && len(rw.closed)+next > rw.MaxFiles
+		tooLarge := rw.MaxBytes > 0 && total+reserve > rw.MaxBytes
+		if !tooMany && !tooLarge {
+			break
+		}
+		f := rw.closed[0]
+		if f.name == rw.name {
+			// never the file being written
+			rw.closed = rw.closed[1:]
+			total -= f.size
+			continue
+		}
+		if rw.BeforeDelete != nil {
+			rw.BeforeDelete(f.name)
+		}
+		if err := os.Remove(f.name); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		rw.closed = rw.closed[1:]
+		total -= f.size
+	}
+	return nil
+}
+
+// Name returns the name of the file being written, "" if none is open.
+func (rw *RotatingWriter) Name() string {
+	return rw.name
+}
+
+func (rw *RotatingWriter) Flush() error {
+	if rw.fh == nil {
+		return nil
+	}
+	return rw.fh.Sync()
+}
+
+// Close closes the current file and applies the retention limits.
+func (rw *RotatingWriter) Close() error {
+	if rw.fh == nil {
+		return nil
+	}
+	if err := rw.closeFile(); err != nil {
+		return err
+	}
+	return rw.retain(0)
+}
+
+// FormatName expands strftime style placeholders in template:
+//
+//	%Y year       %m month     %d day    %H hour   %M minute  %S second
+//	%y 2 digit year  %j day of year  %s unix seconds  %Z zone
+//	%i file sequence number, starting at 1   %% a literal %
+//
+// Anything else is copied unchanged.
+func FormatName(template string, t time.Time, sequence int) string {
+
+	var sb strings.Builder
+	for i := 0; i < len(template); i++ {
+		c := template[i]
+		if c != '%' || i+1 == len(template) {
+			sb.WriteByte(c)
+			continue
+		}
+		i++
+		switch template[i] {
+		case 'Y':
+			fmt.Fprintf(&sb, "%04d", t.Year())
+		case 'y':
+			fmt.Fprintf(&sb, "%02d", t.Year()%100)
+		case 'm':
+			fmt.Fprintf(&sb, "%02d", int(t.Month()))
+		case 'd':
+			fmt.Fprintf(&sb, "%02d", t.Day())
+		case 'H':
+			fmt.Fprintf(&sb, "%02d", t.Hour())
+		case 'M':
+			fmt.Fprintf(&sb, "%02d", t.Minute())
+		case 'S':
+			fmt.Fprintf(&sb, "%02d", t.Second())
+		case 'j':
+			fmt.Fprintf(&sb, "%03d", t.YearDay())
+		case 's':
+			fmt.Fprintf(&sb, "%d", t.Unix())
+		case 'Z':
+			zone, _ := t.Zone()
+			sb.WriteString(zone)
+		case 'i':
+			fmt.Fprintf(&sb, "%d", sequence)
+		case '%':
+			sb.WriteByte('%')
+		default:
+			sb.WriteByte('%')
+			sb.WriteByte(template[i])
+		}
+	}
+	return sb.String()
+}
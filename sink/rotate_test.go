@@ -0,0 +1,61 @@
+package sink
+
+import (
+	"testing"
+	"time"
+
+	"github.com/RajeshGottlieb/go/pcapng"
+)
+
+func TestNextBoundary(t *testing.T) {
+
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	at := func(s string) time.Time {
+		tm, err := time.ParseInLocation("2006-01-02 15:04 MST", s, ny)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+
+	tests := []struct {
+		interval time.Duration
+		t        string
+		want     string
+	}{
+		{24 * time.Hour, "2026-10-31 23:30 EDT", "2026-11-01 00:00 EDT"},
+		{24 * time.Hour, "2026-11-01 00:00 EDT", "2026-11-02 00:00 EST"}, // a 25 hour day
+		{24 * time.Hour, "2026-11-01 23:00 EST", "2026-11-02 00:00 EST"},
+		{24 * time.Hour, "2026-03-08 00:00 EST", "2026-03-09 00:00 EDT"}, // a 23 hour day
+		{time.Hour, "2026-11-01 01:30 EDT", "2026-11-01 02:00 EST"},
+		{time.Hour, "2026-11-01 01:30 EST", "2026-11-01 02:00 EST"},
+		{30 * time.Minute, "2026-11-01 01:10 EST", "2026-11-01 01:30 EST"},
+		{30 * time.Minute, "2026-11-01 01:40 EST", "2026-11-01 02:00 EST"},
+		{30 * time.Minute, "2026-11-01 01:10 EDT", "2026-11-01 01:30 EDT"},
+		{time.Hour, "2026-03-08 01:30 EST", "2026-03-08 03:00 EDT"},
+		{6 * time.Hour, "2026-03-08 05:00 EDT", "2026-03-08 06:00 EDT"},
+		{6 * time.Hour, "2026-11-01 18:00 EST", "2026-11-02 00:00 EST"},
+	}
+
+	for _, tt := range tests {
+		rw := &RotatingWriter{Interval: tt.interval, Location: ny}
+		got := rw.nextBoundary(at(tt.t))
+		if want := at(tt.want); !got.Equal(want) {
+			t.Errorf("%v after %v: got %v, want %v", tt.interval, tt.t, got.Format("2006-01-02 15:04 MST"), tt.want)
+		}
+	}
+}
+
+func TestRotatingWriterInterval(t *testing.T) {
+
+	for _, interval := range []time.Duration{-time.Hour, 7 * time.Hour, 25 * time.Hour, 48 * time.Hour} {
+		rw := NewRotatingWriter(t.TempDir()+"/c-%i.pcapng", nil)
+		rw.Interval = interval
+		if err := rw.WritePacket(pcapng.PacketMeta{Timestamp: time.Now()}, nil); err == nil {
+			t.Errorf("interval %v was accepted", interval)
+		}
+	}
+}
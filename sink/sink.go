@@ -0,0 +1,107 @@
+// Package sink holds example pcapng.PacketSink adapters.
+package sink
+
+import (
+	"net"
+
+	"github.com/RajeshGottlieb/go/pcap"
+	"github.com/RajeshGottlieb/go/pcapng"
+)
+
+// SinkError is returned for a sink that is set up wrong.
+type SinkError struct {
+	errorString string
+}
+
+func (e *SinkError) Error() string {
+	return e.errorString
+}
+
+// The file writers are sinks too.
+var (
+	_ pcapng.PacketSink = (*pcap.PcapWriter)(nil)
+	_ pcapng.PacketSink = (*pcapng.PcapngWriter)(nil)
+)
+
+// Packet is a packet held by a MemorySink.
+type Packet struct {
+	Meta pcapng.PacketMeta
+	Data []byte
+}
+
+// MemorySink collects packets in memory.
+type MemorySink struct {
+	Packets []Packet
+}
+
+// WritePacket keeps a copy of data.
+func (m *MemorySink) WritePacket(meta pcapng.PacketMeta, data []byte) error {
+	m.Packets = append(m.Packets, Packet{meta, append([]byte(nil), data...)})
+	return nil
+}
+
+func (m *MemorySink) Flush() error {
+	return nil
+}
+
+func (m *MemorySink) Close() error {
+	return nil
+}
+
+// UDPSink forwards the data of each packet as one UDP datagram.
+type UDPSink struct {
+	conn net.Conn
+}
+
+// DialUDP returns a UDPSink sending to address (host:port).
+func DialUDP(address string) (*UDPSink, error) {
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	return &UDPSink{conn}, nil
+}
+
+// WritePacket sends data. The metadata is not sent.
+func (u *UDPSink) WritePacket(meta pcapng.PacketMeta, data []byte) error {
+	_, err := u.conn.Write(data)
+	return err
+}
+
+func (u *UDPSink) Flush() error {
+	return nil
+}
+
+func (u *UDPSink) Close() error {
+	return u.conn.Close()
+}
+
+// MultiSink writes every packet to each of its sinks, stopping at the first error.
+type MultiSink []pcapng.PacketSink
+
+func (ms MultiSink) WritePacket(meta pcapng.PacketMeta, data []byte) error {
+	for _, s := range ms {
+		if err := s.WritePacket(meta, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ms MultiSink) Flush() error {
+	for _, s := range ms {
+		if err := s.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ms MultiSink) Close() error {
+	for _, s := range ms {
+		if err := s.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
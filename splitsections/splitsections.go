@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"github.com/RajeshGottlieb/go/pcapng"
+	"io"
+	"os"
+)
+
+func main() {
+
+	if len(os.Args) != 3 {
+		fmt.Printf("usage: %v <input-pcapng> <output-prefix>\n", os.Args[0])
+		return
+	}
+
+	fh, err := os.Open(os.Args[1])
+	if err != nil {
+		panic(err)
+	}
+	defer fh.Close()
+
+	fi, err := fh.Stat()
+	if err != nil {
+		panic(err)
+	}
+
+	err = pcapng.SplitSections(fh, fi.Size(), func(section int) (io.WriteCloser, error) {
+		name := fmt.Sprintf("%v-%v.pcapng", os.Args[2], section)
+		fmt.Println(name)
+		return os.Create(name)
+	})
+	if err != nil {
+		panic(err)
+	}
+}
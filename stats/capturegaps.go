@@ -0,0 +1,173 @@
+package stats
+
+import (
+	"sort"
+	"time"
+)
+
+// Interruption kinds
+const (
+	Interrupted = "interrupted" // a gap without reported drops
+	Overloaded  = "overloaded"  // a gap with reported drops
+	Dropped     = "dropped"     // drops reported without a gap
+)
+
+// Interruption is a period in which the capture of an interface likely
+// missed packets.
+type Interruption struct {
+	Section     int           // 0 based
+	InterfaceID uint32        // within the section
+	Kind        string        // Interrupted, Overloaded or Dropped
+	Start       time.Time     // the last packet before the period
+	End         time.Time     // the first packet after it, or the Interface Statistics Block reporting the drops
+	Expected    time.Duration // usual time between packets before the period
+	Drops       uint64        // packets reported dropped in the period
+}
+
+// Gap returns the length of the period.
+func (i *Interruption) Gap() time.Duration {
+	return i.End.Sub(i.Start)
+}
+
+// CaptureGaps finds gaps between packets much longer than the usual
+// spacing on their interface and correlates them with the drops reported
+// as epb_dropcount or, if a file has none, in Interface Statistics Blocks.
+type CaptureGaps struct {
+	Factor        float64       // a gap is suspicious if it is this many times the usual spacing
+	MinGap        time.Duration // and at least this long
+	Warmup        int           // packets seen on an interface before gaps are judged
+	Interruptions []Interruption
+
+	state map[gapKey]*captureGapState
+}
+
+// gapKey identifies an interface, whose IDs are only unique within a
+// section.
+type gapKey struct {
+	section int
+	id      uint32
+}
+
+type captureGapState struct {
+	packets      int
+	last         time.Time
+	mean         time.Duration // moving average of the time between packets
+	dropcounts   bool          // the interface has epb_dropcount options
+	isbDrops     uint64        // total from the last Interface Statistics Block
+	pendingDrops uint64        // reported by Interface Statistics Blocks since the last packet
+	pendingAt    time.Time     // of the last such block
+}
+
+// NewCaptureGaps returns a CaptureGaps analyzer flagging gaps of 20 times
+// the usual spacing and at least a second.
+func NewCaptureGaps() *CaptureGaps {
+	return &CaptureGaps{
+		Factor: 20,
+		MinGap: time.Second,
+		Warmup: 16,
+	}
+}
+
+func (c *CaptureGaps) interfaceState(key gapKey) *captureGapState {
+	if c.state == nil {
+		c.state = make(map[gapKey]*captureGapState)
+	}
+	s, ok := c.state[key]
+	if !ok {
+		s = &captureGapState{}
+		c.state[key] = s
+	}
+	return s
+}
+
+// Packet compares the time since the previous packet of the interface
+// with the usual spacing.
+func (c *CaptureGaps) Packet(p *Packet) {
+
+	s := c.interfaceState(gapKey{p.Section, p.InterfaceID})
+	if p.DropCount > 0 {
+		s.dropcounts = true
+	}
+	drops := p.DropCount
+	if !s.dropcounts {
+		drops = s.pendingDrops
+	}
+	s.pendingDrops = 0
+
+	if s.packets > 0 {
+		gap := p.Timestamp.Sub(s.last)
+		i := Interruption{Section: p.Section, InterfaceID: p.InterfaceID, Start: s.last, End: p.Timestamp, Expected: s.mean, Drops: drops}
+
+		suspicious := s.packets >= c.Warmup && gap >= c.MinGap && float64(gap) > c.Factor*float64(s.mean)
+		switch {
+		case suspicious && drops > 0:
+			i.Kind = Overloaded
+		case suspicious:
+			i.Kind = Interrupted
+		case drops > 0:
+			i.Kind = Dropped
+		}
+		if i.Kind != "" {
+			c.Interruptions = append(c.Interruptions, i)
+		}
+
+		// a suspicious gap does not count towards the usual spacing
+		if !suspicious && gap >= 0 {
+			if s.packets == 1 {
+				s.mean = gap
+			} else {
+				s.mean += (gap - s.mean) / 16
+			}
+		}
+	}
+
+	s.packets++
+	s.last = p.Timestamp
+}
+
+// Statistics records the drops an Interface Statistics Block reports
+// since the previous one.
+func (c *CaptureGaps) Statistics(st *Statistics) {
+	if !st.HasDrops {
+		return
+	}
+	s := c.interfaceState(gapKey{st.Section, st.InterfaceID})
+	if st.Drops > s.isbDrops {
+		s.pendingDrops += st.Drops - s.isbDrops
+		s.pendingAt = st.Timestamp
+	}
+	s.isbDrops = st.Drops
+}
+
+// Finish reports the drops of Interface Statistics Blocks after the last
+// packet of each interface.
+func (c *CaptureGaps) Finish() {
+
+	keys := make([]gapKey, 0, len(c.state))
+	for key := range c.state {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].section != keys[j].section {
+			return keys[i].section < keys[j].section
+		}
+		return keys[i].id < keys[j].id
+	})
+
+	for _, key := range keys {
+		s := c.state[key]
+		if s.dropcounts || s.pendingDrops == 0 {
+			continue
+		}
+		c.Interruptions = append(c.Interruptions, Interruption{
+			Section:     key.section,
+			InterfaceID: key.id,
+			Kind:        Dropped,
+			Start:       s.last,
+			End:         s.pendingAt,
+			Expected:    s.mean,
+			Drops:       s.pendingDrops,
+		})
+		s.pendingDrops = 0
+	}
+}
@@ -0,0 +1,91 @@
+package stats
+
+// DemuxPolicy decides what happens to a packet for a consumer whose queue is full.
+type DemuxPolicy int
+
+const (
+	DemuxBlock DemuxPolicy = iota // wait for room, which holds up every consumer
+	DemuxDrop                     // drop the packet for this consumer only
+)
+
+// Consumer is one analyzer fed by a Demux on its own goroutine.
+type Consumer struct {
+	Analyzer Analyzer
+	Filter   func(p *Packet) bool // nil accepts every packet
+	Policy   DemuxPolicy
+	Accepted int // packets the filter accepted, valid after Finish
+	Dropped  int // accepted packets dropped because the queue was full
+
+	queue chan *Packet
+	done  chan struct{}
+}
+
+func (c *Consumer) run() {
+	for p := range c.queue {
+		c.Analyzer.Packet(p)
+	}
+	c.Analyzer.Finish()
+	close(c.done)
+}
+
+// Demux delivers every packet to several consumers, each with its own
+// filter and queue, so slow analyzers run alongside fast ones in a single
+// pass over a capture. It is itself an Analyzer, to be handed to Scan.
+type Demux struct {
+	Consumers []*Consumer
+}
+
+// NewDemux returns a Demux without consumers.
+func NewDemux() *Demux {
+	return &Demux{}
+}
+
+// Add starts a consumer feeding a with the packets filter accepts through a
+// queue of queueLength packets. It must be called before the first packet.
+func (d *Demux) Add(a Analyzer, filter func(p *Packet) bool, queueLength int, policy DemuxPolicy) *Consumer {
+	c := &Consumer{
+		Analyzer: a,
+		Filter:   filter,
+		Policy:   policy,
+		queue:    make(chan *Packet, queueLength),
+		done:     make(chan struct{}),
+	}
+	go c.run()
+	d.Consumers = append(d.Consumers, c)
+	return c
+}
+
+// Packet queues the packet for each consumer that accepts it. Consumers
+// share the packet data and must not modify it.
+func (d *Demux) Packet(p *Packet) {
+
+	// the caller may reuse p once Packet returns
+	q := *p
+
+	for _, c := range d.Consumers {
+		if c.Filter != nil && !c.Filter(&q) {
+			continue
+		}
+		c.Accepted++
+
+		if c.Policy == DemuxDrop {
+			select {
+			case c.queue <- &q:
+			default:
+				c.Dropped++
+			}
+		} else {
+			c.queue <- &q
+		}
+	}
+}
+
+// Finish waits for every consumer to drain its queue and finish.
+func (d *Demux) Finish() {
+	for _, c := range d.Consumers {
+		close(c.queue)
+	}
+	for _, c := range d.Consumers {
+		<-c.done
+	}
+}
@@ -0,0 +1,231 @@
+package stats
+
+import (
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RajeshGottlieb/go/packet"
+	"github.com/RajeshGottlieb/go/pcapng"
+)
+
+// DNSTransaction is a DNS query and its response, if one was seen.
+type DNSTransaction struct {
+	Number    int // packet number of the query
+	Timestamp time.Time
+	Client    string // address:port
+	Server    string
+	Transport string // "udp" or "tcp"
+	ID        uint16
+	Name      string
+	Type      string
+	Answered  bool
+	Rcode     string
+	Answers   []string      // addresses of A and AAAA answers
+	Latency   time.Duration // 0 if not answered
+}
+
+type dnsKey struct {
+	flow packet.Flow // direction of the query
+	id   uint16
+}
+
+// DNS summarizes the DNS transactions over UDP and TCP port 53.
+// Responses without a query are ignored.
+type DNS struct {
+	Transactions []*DNSTransaction // in query order
+
+	// Names maps each name to the addresses it resolved to.
+	Names map[string][]net.IP
+
+	pending map[dnsKey]*DNSTransaction
+	tcp     map[packet.Flow]*dnsStream
+}
+
+// dnsStream collects the length prefixed messages of one TCP direction.
+type dnsStream struct {
+	reassembler packet.Reassembler
+	buf         []byte
+}
+
+// NewDNS returns a DNS analyzer.
+func NewDNS() *DNS {
+	return &DNS{
+		Names:   make(map[string][]net.IP),
+		pending: make(map[dnsKey]*DNSTransaction),
+		tcp:     make(map[packet.Flow]*dnsStream),
+	}
+}
+
+// Packet decodes the DNS messages in the packet.
+func (d *DNS) Packet(p *Packet) {
+
+	pkt := packet.Decode(p.LinkType, p.Data)
+	flow, ok := pkt.Flow()
+	if !ok || (flow.SrcPort != 53 && flow.DstPort != 53) {
+		return
+	}
+
+	switch pkt.Protocol {
+	case packet.ProtocolUDP:
+		if m, err := packet.DecodeDNS(pkt.Payload()); err == nil {
+			d.message(p, flow, "udp", m)
+		}
+	case packet.ProtocolTCP:
+		s, ok := d.tcp[flow]
+		if !ok {
+			s = &dnsStream{}
+			d.tcp[flow] = s
+		}
+		s.buf = append(s.buf, s.reassembler.Add(pkt.TCPSeq, pkt.TCPFlags&packet.TCPFlagSYN != 0, pkt.Payload())...)
+		for len(s.buf) >= 2 {
+			length := int(binary.BigEndian.Uint16(s.buf))
+			if len(s.buf) < 2+length {
+				break
+			}
+			if m, err := packet.DecodeDNS(s.buf[2 : 2+length]); err == nil {
+				d.message(p, flow, "tcp", m)
+			}
+			s.buf = s.buf[2+length:]
+		}
+	}
+}
+
+func (d *DNS) message(p *Packet, flow packet.Flow, transport string, m *packet.DNS) {
+
+	if !m.Response {
+		t := &DNSTransaction{
+			Number:    p.Number,
+			Timestamp: p.Timestamp,
+			Client:    endpoint(flow.SrcIP, flow.SrcPort),
+			Server:    endpoint(flow.DstIP, flow.DstPort),
+			Transport: transport,
+			ID:        m.ID,
+		}
+		if len(m.Questions) > 0 {
+			t.Name = m.Questions[0].Name
+			t.Type = packet.DNSTypeName(m.Questions[0].Type)
+		}
+		d.Transactions = append(d.Transactions, t)
+		d.pending[dnsKey{flow, m.ID}] = t
+		return
+	}
+
+	key := dnsKey{flow.Reverse(), m.ID}
+	t, ok := d.pending[key]
+	if !ok {
+		return
+	}
+	delete(d.pending, key)
+
+	t.Answered = true
+	t.Rcode = packet.DNSRcodeName(m.Rcode)
+	t.Latency = p.Timestamp.Sub(t.Timestamp)
+	for _, rr := range m.Answers {
+		if rr.IP == nil {
+			continue
+		}
+		t.Answers = append(t.Answers, rr.IP.String())
+		d.addName(strings.TrimSuffix(rr.Name, "."), rr.IP)
+	}
+}
+
+func (d *DNS) addName(name string, ip net.IP) {
+	for _, known := range d.Names[name] {
+		if known.Equal(ip) {
+			return
+		}
+	}
+	d.Names[name] = append(d.Names[name], ip)
+}
+
+// Finish does nothing, unanswered queries keep Answered false.
+func (d *DNS) Finish() {
+}
+
+// endpoint formats an address and port, unmapping IPv4 addresses.
+func endpoint(ip [16]byte, port uint16) string {
+	return net.JoinHostPort(net.IP(ip[:]).String(), strconv.Itoa(int(port)))
+}
+
+// WriteJSON writes the transactions as a JSON array.
+func (d *DNS) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(d.Transactions)
+}
+
+// WriteCSV writes the transactions with a header line. Answers are
+// separated by spaces and the latency is in seconds.
+func (d *DNS) WriteCSV(w io.Writer) error {
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"number", "timestamp", "client", "server", "transport", "id", "name", "type", "rcode", "answers", "latency"})
+
+	for _, t := range d.Transactions {
+		latency := ""
+		if t.Answered {
+			latency = strconv.FormatFloat(t.Latency.Seconds(), 'f', 6, 64)
+		}
+		cw.Write([]string{
+			strconv.Itoa(t.Number),
+			t.Timestamp.UTC().Format(time.RFC3339Nano),
+			t.Client,
+			t.Server,
+			t.Transport,
+			strconv.Itoa(int(t.ID)),
+			t.Name,
+			t.Type,
+			t.Rcode,
+			strings.Join(t.Answers, " "),
+			latency,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// NameResolutionBlock returns a Name Resolution Block holding every
+// address seen in an answer with the names that resolved to it.
+func (d *DNS) NameResolutionBlock() *pcapng.NameResolutionBlock {
+
+	names := make(map[string][]string) // address bytes to names
+	for name, ips := range d.Names {
+		for _, ip := range ips {
+			key := string(ip)
+			if v4 := ip.To4(); v4 != nil {
+				key = string(v4)
+			}
+			names[key] = append(names[key], name)
+		}
+	}
+
+	addresses := make([]string, 0, len(names))
+	for a := range names {
+		addresses = append(addresses, a)
+	}
+	sort.Strings(addresses)
+
+	nrb := &pcapng.NameResolutionBlock{}
+	for _, a := range addresses {
+		sort.Strings(names[a])
+		// the address followed by zero terminated names
+		value := []byte(a)
+		for _, name := range names[a] {
+			value = append(value, name...)
+			value = append(value, 0)
+		}
+		if len(a) == 4 {
+			nrb.Records = append(nrb.Records, &pcapng.Nrb_Record_ipv4{Value: value})
+		} else {
+			nrb.Records = append(nrb.Records, &pcapng.Nrb_Record_ipv6{Value: value})
+		}
+	}
+	return nrb
+}
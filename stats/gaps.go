@@ -0,0 +1,89 @@
+package stats
+
+import (
+	"sort"
+	"time"
+
+	"github.com/RajeshGottlieb/go/packet"
+)
+
+// FlowGaps holds the inter-arrival statistics of one flow.
+type FlowGaps struct {
+	Flow    packet.Flow
+	Packets int
+	Min     time.Duration
+	Max     time.Duration
+	Mean    time.Duration
+	Jitter  time.Duration // RFC 3550 style smoothed variation of the inter-arrival times
+
+	last   time.Time
+	total  time.Duration
+	gaps   []time.Duration
+	sorted bool
+}
+
+// Percentile returns the inter-arrival time below which p percent (0-100) of the gaps fall.
+func (g *FlowGaps) Percentile(p float64) time.Duration {
+	if !g.sorted {
+		sort.Slice(g.gaps, func(i, j int) bool { return g.gaps[i] < g.gaps[j] })
+		g.sorted = true
+	}
+	return percentile(g.gaps, p)
+}
+
+// Gaps computes inter-arrival time and jitter statistics per flow.
+// Packets that are not IP are ignored.
+type Gaps struct {
+	Flows map[packet.Flow]*FlowGaps
+}
+
+// NewGaps returns a Gaps analyzer.
+func NewGaps() *Gaps {
+	return &Gaps{Flows: make(map[packet.Flow]*FlowGaps)}
+}
+
+// Packet records the time since the previous packet of the same flow.
+func (g *Gaps) Packet(p *Packet) {
+
+	flow, ok := packet.Decode(p.LinkType, p.Data).Flow()
+	if !ok {
+		return
+	}
+
+	fg, ok := g.Flows[flow]
+	if !ok {
+		g.Flows[flow] = &FlowGaps{Flow: flow, Packets: 1, last: p.Timestamp}
+		return
+	}
+	fg.Packets++
+
+	gap := p.Timestamp.Sub(fg.last)
+	fg.last = p.Timestamp
+
+	if len(fg.gaps) == 0 || gap < fg.Min {
+		fg.Min = gap
+	}
+	if len(fg.gaps) == 0 || gap > fg.Max {
+		fg.Max = gap
+	}
+	if len(fg.gaps) > 0 {
+		d := gap - fg.gaps[len(fg.gaps)-1]
+		if d < 0 {
+			d = -d
+		}
+		fg.Jitter += (d - fg.Jitter) / 16
+	}
+
+	fg.total += gap
+	fg.gaps = append(fg.gaps, gap)
+	fg.sorted = false
+}
+
+// Finish computes the mean of each flow.
+func (g *Gaps) Finish() {
+	for _, fg := range g.Flows {
+		if len(fg.gaps) > 0 {
+			fg.Mean = fg.total / time.Duration(len(fg.gaps))
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package stats
+
+import (
+	"net"
+
+	"github.com/RajeshGottlieb/go/geoip"
+	"github.com/RajeshGottlieb/go/packet"
+)
+
+// GeoCount is the traffic of one country or autonomous system.
+type GeoCount struct {
+	Packets int
+	Bytes   int // on the wire
+}
+
+func (c *GeoCount) add(p *Packet) {
+	c.Packets++
+	c.Bytes += int(p.OriginalLength)
+}
+
+// Geo counts the IP packets from or to each country and autonomous system
+// the databases place the addresses in. A packet between two addresses of
+// the same country counts once for it.
+type Geo struct {
+	DB        geoip.Set
+	Countries map[string]*GeoCount // by ISO code
+	ASNs      map[uint32]*GeoCount
+	Orgs      map[uint32]string // the name of each ASN
+	Unknown   GeoCount          // packets with neither address in the databases
+	Err       error             // the first lookup error, after which nothing more is counted
+}
+
+// NewGeo returns a Geo analyzer looking addresses up in db.
+func NewGeo(db geoip.Set) *Geo {
+	return &Geo{
+		DB:        db,
+		Countries: make(map[string]*GeoCount),
+		ASNs:      make(map[uint32]*GeoCount),
+		Orgs:      make(map[uint32]string),
+	}
+}
+
+// Packet counts the packet for the countries and autonomous systems of its
+// addresses.
+func (g *Geo) Packet(p *Packet) {
+
+	d := packet.Decode(p.LinkType, p.Data)
+	if d.IPVersion == 0 || g.Err != nil {
+		return
+	}
+
+	var infos [2]geoip.Info
+	for i, ip := range []net.IP{d.SrcIP, d.DstIP} {
+		if infos[i], g.Err = g.DB.Info(ip); g.Err != nil {
+			return
+		}
+	}
+	if infos[0].Empty() && infos[1].Empty() {
+		g.Unknown.add(p)
+		return
+	}
+
+	for i, info := range infos {
+		if info.Country != "" && (i == 0 || info.Country != infos[0].Country) {
+			c := g.Countries[info.Country]
+			if c == nil {
+				c = &GeoCount{}
+				g.Countries[info.Country] = c
+			}
+			c.add(p)
+		}
+		if info.ASN != 0 && (i == 0 || info.ASN != infos[0].ASN) {
+			c := g.ASNs[info.ASN]
+			if c == nil {
+				c = &GeoCount{}
+				g.ASNs[info.ASN] = c
+				g.Orgs[info.ASN] = info.Org
+			}
+			c.add(p)
+		}
+	}
+}
+
+// Finish does nothing; the counts are complete after the last packet.
+func (g *Geo) Finish() {
+}
@@ -0,0 +1,181 @@
+package stats
+
+import (
+	"encoding/binary"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/RajeshGottlieb/go/packet"
+)
+
+// Kinds of request/response pairs matched by Latency.
+const (
+	LatencyDNS  = "dns"  // DNS query and response
+	LatencyICMP = "icmp" // ICMP or ICMPv6 echo request and reply
+	LatencyTCP  = "tcp"  // TCP SYN and SYN/ACK
+)
+
+// PeerKey identifies the responder of one kind of exchange.
+type PeerKey struct {
+	Kind string
+	Peer string // address of the responder
+}
+
+// PeerLatency holds the latency distribution of one responder.
+type PeerLatency struct {
+	Count     int // matched pairs
+	Unmatched int // requests without a response
+	Min       time.Duration
+	Max       time.Duration
+	Mean      time.Duration
+
+	total     time.Duration
+	latencies []time.Duration
+	sorted    bool
+}
+
+// Percentile returns the latency below which p percent (0-100) of the pairs fall.
+func (pl *PeerLatency) Percentile(p float64) time.Duration {
+	if !pl.sorted {
+		sort.Slice(pl.latencies, func(i, j int) bool { return pl.latencies[i] < pl.latencies[j] })
+		pl.sorted = true
+	}
+	return percentile(pl.latencies, p)
+}
+
+// percentile returns element p percent (0-100) of the way through sorted.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(p / 100 * float64(len(sorted)-1))
+	if i < 0 {
+		i = 0
+	} else if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
+
+// requestKey identifies an outstanding request. The flow is the
+// direction of the request.
+type requestKey struct {
+	kind string
+	flow packet.Flow
+	id   uint32 // DNS ID, ICMP identifier and sequence, or TCP ISN
+}
+
+// Latency pairs requests with their responses in one pass and records the
+// latency per responder. Retransmitted requests restart the clock.
+type Latency struct {
+	Peers map[PeerKey]*PeerLatency
+
+	pending map[requestKey]time.Time
+}
+
+// NewLatency returns a Latency analyzer.
+func NewLatency() *Latency {
+	return &Latency{
+		Peers:   make(map[PeerKey]*PeerLatency),
+		pending: make(map[requestKey]time.Time),
+	}
+}
+
+// Packet matches the packet as a request or a response.
+func (l *Latency) Packet(p *Packet) {
+
+	pkt := packet.Decode(p.LinkType, p.Data)
+	flow, ok := pkt.Flow()
+	if !ok || pkt.Fragment {
+		return
+	}
+
+	switch pkt.Protocol {
+	case packet.ProtocolUDP:
+		if flow.SrcPort != 53 && flow.DstPort != 53 {
+			return
+		}
+		m, err := packet.DecodeDNS(pkt.Payload())
+		if err != nil {
+			return
+		}
+		l.match(LatencyDNS, flow, uint32(m.ID), m.Response, p.Timestamp)
+
+	case packet.ProtocolICMP, packet.ProtocolICMPv6:
+		// echo request and reply are 8 and 0 for ICMP, 128 and 129 for ICMPv6
+		request, reply := uint8(8), uint8(0)
+		if pkt.Protocol == packet.ProtocolICMPv6 {
+			request, reply = 128, 129
+		}
+		if pkt.ICMPType != request && pkt.ICMPType != reply {
+			return
+		}
+		// the identifier and sequence follow the type, code and checksum
+		if pkt.TransportOffset < 0 || len(pkt.Data) < pkt.TransportOffset+8 {
+			return
+		}
+		id := binary.BigEndian.Uint32(pkt.Data[pkt.TransportOffset+4:])
+		l.match(LatencyICMP, flow, id, pkt.ICMPType == reply, p.Timestamp)
+
+	case packet.ProtocolTCP:
+		switch pkt.TCPFlags & (packet.TCPFlagSYN | packet.TCPFlagACK) {
+		case packet.TCPFlagSYN:
+			l.match(LatencyTCP, flow, pkt.TCPSeq, false, p.Timestamp)
+		case packet.TCPFlagSYN | packet.TCPFlagACK:
+			l.match(LatencyTCP, flow, pkt.TCPAck-1, true, p.Timestamp)
+		}
+	}
+}
+
+func (l *Latency) match(kind string, flow packet.Flow, id uint32, response bool, ts time.Time) {
+
+	if !response {
+		l.pending[requestKey{kind, flow, id}] = ts
+		return
+	}
+
+	key := requestKey{kind, flow.Reverse(), id}
+	start, ok := l.pending[key]
+	if !ok {
+		return
+	}
+	delete(l.pending, key)
+
+	pl := l.peer(kind, flow.SrcIP)
+	latency := ts.Sub(start)
+	if pl.Count == 0 || latency < pl.Min {
+		pl.Min = latency
+	}
+	if pl.Count == 0 || latency > pl.Max {
+		pl.Max = latency
+	}
+	pl.Count++
+	pl.total += latency
+	pl.latencies = append(pl.latencies, latency)
+	pl.sorted = false
+}
+
+func (l *Latency) peer(kind string, ip [16]byte) *PeerLatency {
+	key := PeerKey{kind, net.IP(ip[:]).String()}
+	pl, ok := l.Peers[key]
+	if !ok {
+		pl = &PeerLatency{}
+		l.Peers[key] = pl
+	}
+	return pl
+}
+
+// Finish computes the means and counts the requests left unanswered.
+func (l *Latency) Finish() {
+	for key := range l.pending {
+		l.peer(key.kind, key.flow.DstIP).Unmatched++
+	}
+	l.pending = make(map[requestKey]time.Time)
+
+	for _, pl := range l.Peers {
+		if pl.Count > 0 {
+			pl.Mean = pl.total / time.Duration(pl.Count)
+		}
+	}
+}
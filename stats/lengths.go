@@ -0,0 +1,90 @@
+package stats
+
+// DefaultLengthEdges are the bucket edges Wireshark uses for its packet lengths statistics.
+var DefaultLengthEdges = []int{20, 40, 80, 160, 320, 640, 1280, 2560, 5120}
+
+// LengthKey selects one histogram of a Lengths analyzer.
+type LengthKey struct {
+	Section     int    // 0 based section of the interface
+	InterfaceID uint32 // within the section
+	Direction   uint32 // pcapng.DirectionUnknown, DirectionInbound or DirectionOutbound
+}
+
+// LengthHistogram counts packets by original length.
+// Counts[i] holds packets with Edges[i-1] <= length < Edges[i], with the
+// first bucket starting at 0 and the last one unbounded.
+type LengthHistogram struct {
+	Edges   []int
+	Counts  []int
+	Packets int
+	Bytes   int
+	Min     int
+	Max     int
+}
+
+// Mean returns the average packet length.
+func (h *LengthHistogram) Mean() float64 {
+	if h.Packets == 0 {
+		return 0
+	}
+	return float64(h.Bytes) / float64(h.Packets)
+}
+
+func (h *LengthHistogram) add(length int) {
+	i := 0
+	for i < len(h.Edges) && length >= h.Edges[i] {
+		i++
+	}
+	h.Counts[i]++
+
+	if h.Packets == 0 || length < h.Min {
+		h.Min = length
+	}
+	if length > h.Max {
+		h.Max = length
+	}
+	h.Packets++
+	h.Bytes += length
+}
+
+// Lengths builds packet length histograms per interface of each section
+// and direction, as well as one over all packets.
+type Lengths struct {
+	Edges      []int // ascending bucket edges
+	Total      *LengthHistogram
+	Histograms map[LengthKey]*LengthHistogram
+}
+
+// NewLengths returns a Lengths analyzer. If edges is nil DefaultLengthEdges is used.
+func NewLengths(edges []int) *Lengths {
+	if edges == nil {
+		edges = DefaultLengthEdges
+	}
+	return &Lengths{
+		Edges:      edges,
+		Total:      newLengthHistogram(edges),
+		Histograms: make(map[LengthKey]*LengthHistogram),
+	}
+}
+
+func newLengthHistogram(edges []int) *LengthHistogram {
+	return &LengthHistogram{Edges: edges, Counts: make([]int, len(edges)+1)}
+}
+
+// Packet adds the packet length to its histograms.
+func (l *Lengths) Packet(p *Packet) {
+
+	key := LengthKey{p.Section, p.InterfaceID, p.Flags & 3} // epb_flags direction bits
+
+	h, ok := l.Histograms[key]
+	if !ok {
+		h = newLengthHistogram(l.Edges)
+		l.Histograms[key] = h
+	}
+	h.add(int(p.OriginalLength))
+	l.Total.add(int(p.OriginalLength))
+}
+
+// Finish does nothing; the histograms are complete after the last packet.
+func (l *Lengths) Finish() {
+}
@@ -0,0 +1,58 @@
+package stats
+
+import "testing"
+
+func TestLengths(t *testing.T) {
+
+	packets := []Packet{
+		{Section: 0, InterfaceID: 0, OriginalLength: 60, Flags: 1},
+		{Section: 0, InterfaceID: 0, OriginalLength: 1500, Flags: 1},
+		{Section: 0, InterfaceID: 0, OriginalLength: 100, Flags: 2},
+		{Section: 1, InterfaceID: 0, OriginalLength: 20, Flags: 1},
+		{Section: 0, InterfaceID: 1, OriginalLength: 9000},
+	}
+
+	tests := []struct {
+		key     LengthKey
+		packets int
+		min     int
+		max     int
+	}{
+		{LengthKey{0, 0, 1}, 2, 60, 1500},
+		{LengthKey{0, 0, 2}, 1, 100, 100},
+		{LengthKey{1, 0, 1}, 1, 20, 20},
+		{LengthKey{0, 1, 0}, 1, 9000, 9000},
+	}
+
+	l := NewLengths(nil)
+	for i := range packets {
+		l.Packet(&packets[i])
+	}
+	l.Finish()
+
+	if len(l.Histograms) != len(tests) {
+		t.Errorf("%v histograms, want %v", len(l.Histograms), len(tests))
+	}
+	for _, tt := range tests {
+		h := l.Histograms[tt.key]
+		if h == nil {
+			t.Errorf("%+v: no histogram", tt.key)
+			continue
+		}
+		if h.Packets != tt.packets || h.Min != tt.min || h.Max != tt.max {
+			t.Errorf("%+v: %v packets from %v to %v, want %v from %v to %v", tt.key, h.Packets, h.Min, h.Max, tt.packets, tt.min, tt.max)
+		}
+	}
+
+	// 20 <= 20 < 40, 40 <= 60 < 80, 80 <= 100 < 160, 1280 <= 1500 < 2560, 9000 >= 5120
+	want := []int{0, 1, 1, 1, 0, 0, 0, 1, 0, 1}
+	for i, n := range l.Total.Counts {
+		if n != want[i] {
+			t.Errorf("Total.Counts = %v, want %v", l.Total.Counts, want)
+			break
+		}
+	}
+	if l.Total.Packets != 5 || l.Total.Mean() != float64(60+1500+100+20+9000)/5 {
+		t.Errorf("Total has %v packets of mean %v", l.Total.Packets, l.Total.Mean())
+	}
+}
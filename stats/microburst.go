@@ -0,0 +1,143 @@
+package stats
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// MicroburstError is returned for an unusable bucket size.
+type MicroburstError struct {
+	errorString string
+}
+
+func (me *MicroburstError) Error() string {
+	return me.errorString
+}
+
+// Burst is a run of consecutive buckets whose rate exceeded the threshold.
+type Burst struct {
+	Section     int           // 0 based section of the interface
+	InterfaceID uint32        // within the section
+	Start       time.Time     // start of the first bucket
+	Duration    time.Duration // number of buckets times the bucket size
+	Packets     int
+	Bytes       int
+	PeakRate    float64 // highest bucket rate in bits per second
+}
+
+// Microburst finds bursts of traffic that exceed a rate threshold when
+// measured over very small buckets of time. Bursts are tracked per interface
+// of each section.
+type Microburst struct {
+	BucketSize time.Duration // granularity of the rate measurement
+	Threshold  float64       // rate in bits per second above which a bucket is part of a burst
+	Bursts     []Burst       // bursts found so far in the order they ended
+
+	state map[burstKey]*microburstState
+}
+
+// burstKey identifies an interface, whose IDs are only unique within a
+// section.
+type burstKey struct {
+	section int
+	id      uint32
+}
+
+type microburstState struct {
+	bucket  int64 // index of the current bucket
+	packets int   // packets in the current bucket
+	bytes   int   // bytes in the current bucket
+	burst   *Burst
+}
+
+// NewMicroburst returns a Microburst analyzer. The bucket size must be positive.
+func NewMicroburst(bucketSize time.Duration, threshold float64) (*Microburst, error) {
+	if bucketSize <= 0 {
+		return nil, &MicroburstError{fmt.Sprintf("bucket size %v is not positive", bucketSize)}
+	}
+	return &Microburst{
+		BucketSize: bucketSize,
+		Threshold:  threshold,
+		state:      make(map[burstKey]*microburstState),
+	}, nil
+}
+
+// Packet adds a packet to the bucket its timestamp falls in.
+func (m *Microburst) Packet(p *Packet) {
+
+	bucket := p.Timestamp.UnixNano() / int64(m.BucketSize)
+
+	key := burstKey{p.Section, p.InterfaceID}
+	s, ok := m.state[key]
+	if !ok {
+		s = &microburstState{bucket: bucket}
+		m.state[key] = s
+	}
+
+	// packets that go back in time are counted in the current bucket
+	if bucket > s.bucket {
+		m.closeBucket(key, s)
+		if bucket > s.bucket+1 && s.burst != nil {
+			// an empty bucket ends the burst
+			m.Bursts = append(m.Bursts, *s.burst)
+			s.burst = nil
+		}
+		s.bucket = bucket
+	}
+
+	s.packets++
+	s.bytes += int(p.OriginalLength)
+}
+
+// Finish closes the last bucket of every interface.
+func (m *Microburst) Finish() {
+	// in interface order, so the bursts come out the same every run
+	keys := make([]burstKey, 0, len(m.state))
+	for key := range m.state {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].section != keys[j].section {
+			return keys[i].section < keys[j].section
+		}
+		return keys[i].id < keys[j].id
+	})
+
+	for _, key := range keys {
+		s := m.state[key]
+		m.closeBucket(key, s)
+		if s.burst != nil {
+			m.Bursts = append(m.Bursts, *s.burst)
+			s.burst = nil
+		}
+	}
+}
+
+// closeBucket decides whether the current bucket starts, extends or ends a burst.
+func (m *Microburst) closeBucket(key burstKey, s *microburstState) {
+
+	rate := float64(s.bytes*8) / m.BucketSize.Seconds()
+
+	if rate > m.Threshold {
+		if s.burst == nil {
+			s.burst = &Burst{
+				Section:     key.section,
+				InterfaceID: key.id,
+				Start:       time.Unix(0, s.bucket*int64(m.BucketSize)),
+			}
+		}
+		s.burst.Duration += m.BucketSize
+		s.burst.Packets += s.packets
+		s.burst.Bytes += s.bytes
+		if rate > s.burst.PeakRate {
+			s.burst.PeakRate = rate
+		}
+	} else if s.burst != nil {
+		m.Bursts = append(m.Bursts, *s.burst)
+		s.burst = nil
+	}
+
+	s.packets = 0
+	s.bytes = 0
+}
@@ -0,0 +1,79 @@
+package stats
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMicroburst(t *testing.T) {
+
+	type pkt struct {
+		section int
+		id      uint32
+		at      time.Duration // after the epoch
+		length  uint32
+	}
+	type burst struct {
+		section int
+		id      uint32
+		start   time.Duration
+		buckets int
+		packets int
+	}
+
+	// 1 ms buckets, a bucket of more than 100 bytes is part of a burst
+	tests := []struct {
+		name    string
+		packets []pkt
+		bursts  []burst
+	}{
+		{"quiet", []pkt{{0, 0, 0, 60}, {0, 0, time.Millisecond, 60}}, nil},
+		{"one bucket", []pkt{{0, 0, 0, 60}, {0, 0, 500 * time.Microsecond, 60}, {0, 0, time.Millisecond, 60}},
+			[]burst{{0, 0, 0, 1, 2}}},
+		{"two buckets", []pkt{{0, 0, 0, 200}, {0, 0, time.Millisecond, 200}, {0, 0, 2 * time.Millisecond, 60}},
+			[]burst{{0, 0, 0, 2, 2}}},
+		{"ended by an empty bucket", []pkt{{0, 0, 0, 200}, {0, 0, 2 * time.Millisecond, 200}},
+			[]burst{{0, 0, 0, 1, 1}, {0, 0, 2 * time.Millisecond, 1, 1}}},
+		{"open at the end", []pkt{{0, 0, 0, 60}, {0, 0, time.Millisecond, 200}},
+			[]burst{{0, 0, time.Millisecond, 1, 1}}},
+		{"back in time", []pkt{{0, 0, time.Millisecond, 60}, {0, 0, 0, 60}},
+			[]burst{{0, 0, time.Millisecond, 1, 2}}},
+		{"interfaces apart", []pkt{{0, 1, 0, 60}, {0, 0, 0, 60}, {0, 1, 0, 60}},
+			[]burst{{0, 1, 0, 1, 2}}},
+		{"sections apart", []pkt{{0, 0, 0, 60}, {1, 0, 0, 60}, {1, 0, 0, 60}, {0, 0, 5 * time.Millisecond, 60}},
+			[]burst{{1, 0, 0, 1, 2}}},
+		{"finished in order", []pkt{{1, 0, 0, 200}, {0, 1, 0, 200}, {0, 0, 0, 200}},
+			[]burst{{0, 0, 0, 1, 1}, {0, 1, 0, 1, 1}, {1, 0, 0, 1, 1}}},
+	}
+
+	for _, tt := range tests {
+		m, err := NewMicroburst(time.Millisecond, 800e3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range tt.packets {
+			m.Packet(&Packet{Section: p.section, InterfaceID: p.id, Timestamp: time.Unix(0, int64(p.at)), OriginalLength: p.length})
+		}
+		m.Finish()
+
+		if len(m.Bursts) != len(tt.bursts) {
+			t.Errorf("%v: %v bursts, want %v: %+v", tt.name, len(m.Bursts), len(tt.bursts), m.Bursts)
+			continue
+		}
+		for i, b := range m.Bursts {
+			want := tt.bursts[i]
+			if b.Section != want.section || b.InterfaceID != want.id || !b.Start.Equal(time.Unix(0, int64(want.start))) ||
+				b.Duration != time.Duration(want.buckets)*time.Millisecond || b.Packets != want.packets {
+				t.Errorf("%v: burst %v is %+v, want %+v", tt.name, i, b, want)
+			}
+		}
+	}
+}
+
+func TestMicroburstBucketSize(t *testing.T) {
+	for _, size := range []time.Duration{0, -time.Millisecond} {
+		if _, err := NewMicroburst(size, 800e3); err == nil {
+			t.Errorf("bucket size %v: no error", size)
+		}
+	}
+}
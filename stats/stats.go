@@ -0,0 +1,158 @@
+// Package stats computes statistics over the packets of a pcapng file.
+package stats
+
+import (
+	"io"
+	"time"
+
+	"github.com/RajeshGottlieb/go/pcapng"
+)
+
+// Packet is what every Analyzer is handed for each packet in the file.
+type Packet struct {
+	Number         int    // 1 based packet number within the file
+	Section        int    // 0 based section the packet is in
+	InterfaceID    uint32 // interface ID within the section
+	LinkType       uint16 // link type of the interface
+	Timestamp      time.Time
+	CapturedLength uint32
+	OriginalLength uint32
+	Flags          uint32 // epb_flags or 0 if absent
+	DropCount      uint64 // epb_dropcount, packets lost since the previous one, or 0 if absent
+	Comments       []string
+	Data           []byte
+}
+
+// Analyzer is implemented by each statistic.
+type Analyzer interface {
+	// Packet is called for every packet in file order.
+	Packet(p *Packet)
+	// Finish is called once after the last packet.
+	Finish()
+}
+
+// Statistics is what an Interface Statistics Block says about drops.
+type Statistics struct {
+	Section     int // 0 based
+	InterfaceID uint32
+	Timestamp   time.Time
+	Drops       uint64 // isb_ifdrop plus isb_osdrop since the capture began
+	HasDrops    bool   // the block has either option
+}
+
+// StatisticsAnalyzer is implemented by analyzers that also want the
+// Interface Statistics Blocks, in file order among the packets.
+type StatisticsAnalyzer interface {
+	Statistics(s *Statistics)
+}
+
+// Scan reads every block from pr and feeds the packets to each analyzer.
+func Scan(pr *pcapng.PcapngReader, analyzers ...Analyzer) error {
+	return ScanSections(pr, nil, analyzers...)
+}
+
+// ScanSections is Scan limited to the sections (0 based) for which keep
+// returns true, or all of them if keep is nil. Other sections are skipped
+// with a single seek when possible. Packet numbers count skipped packets
+// only if they had to be read.
+func ScanSections(pr *pcapng.PcapngReader, keep func(section int) bool, analyzers ...Analyzer) error {
+
+	var interfaces []*pcapng.InterfaceBlock
+	number := 0
+	section := -1
+	skipping := false
+
+	for {
+		block, err := pr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		if _, ok := block.(*pcapng.SectionBlock); ok {
+			section++
+			skipping = keep != nil && !keep(section)
+			if skipping && pr.SkipSection() == nil {
+				continue
+			}
+		}
+
+		switch b := block.(type) {
+		case *pcapng.SectionBlock:
+			// interface IDs are only unique within a section
+			interfaces = nil
+		case *pcapng.InterfaceBlock:
+			interfaces = append(interfaces, b)
+		case *pcapng.EnhancedPacketBlock:
+			number++
+			if skipping {
+				continue
+			}
+
+			p := Packet{
+				Number:         number,
+				Section:        section,
+				InterfaceID:    b.InterfaceID,
+				CapturedLength: b.CapturedPacketLength,
+				OriginalLength: b.OriginalPacketLength,
+				Data:           b.PacketData,
+			}
+
+			if int(b.InterfaceID) < len(interfaces) {
+				ifb := interfaces[b.InterfaceID]
+				p.LinkType = ifb.LinkType
+				p.Timestamp = ifb.PacketTime(b.TimestampHigh, b.TimestampLow)
+			} else {
+				p.Timestamp = pcapng.Timestamp(b.TimestampHigh, b.TimestampLow, pcapng.DefaultTsresol)
+			}
+
+			for _, opt := range b.Options {
+				switch o := opt.(type) {
+				case *pcapng.Epb_Flags:
+					p.Flags = o.Value
+				case *pcapng.Epb_Dropcount:
+					p.DropCount = o.Value
+				case *pcapng.Opt_Comment:
+					p.Comments = append(p.Comments, o.Value)
+				}
+			}
+
+			for _, a := range analyzers {
+				a.Packet(&p)
+			}
+		case *pcapng.InterfaceStatisticsBlock:
+			if skipping {
+				continue
+			}
+
+			s := Statistics{Section: section, InterfaceID: b.InterfaceID}
+			if int(b.InterfaceID) < len(interfaces) {
+				s.Timestamp = interfaces[b.InterfaceID].PacketTime(b.TimestampHigh, b.TimestampLow)
+			} else {
+				s.Timestamp = pcapng.Timestamp(b.TimestampHigh, b.TimestampLow, pcapng.DefaultTsresol)
+			}
+			for _, opt := range b.Options {
+				switch o := opt.(type) {
+				case *pcapng.Isb_Ifdrop:
+					s.Drops += o.Value
+					s.HasDrops = true
+				case *pcapng.Isb_Osdrop:
+					s.Drops += o.Value
+					s.HasDrops = true
+				}
+			}
+
+			for _, a := range analyzers {
+				if sa, ok := a.(StatisticsAnalyzer); ok {
+					sa.Statistics(&s)
+				}
+			}
+		}
+	}
+
+	for _, a := range analyzers {
+		a.Finish()
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/RajeshGottlieb/go/geoip"
+	"github.com/RajeshGottlieb/go/oui"
+	"github.com/RajeshGottlieb/go/packet"
+	"github.com/RajeshGottlieb/go/pcapng"
+)
+
+// TemplateError
+type TemplateError struct {
+	errorString string
+}
+
+func (te *TemplateError) Error() string {
+	return te.errorString
+}
+
+// Fields are the values of a packet a Template can refer to, e.g.
+// {{.Number}} or {{.Time.Format "15:04:05"}}.
+type Fields struct {
+	Number         int
+	Time           time.Time
+	Relative       time.Duration // since the first packet
+	Delta          time.Duration // since the previous packet
+	Interface      uint32
+	Protocol       string // e.g. "tcp", empty if the packet is not IP
+	Src            string // address and port if there is one, else the MAC address with its vendor
+	Dst            string
+	SrcIP          string
+	DstIP          string
+	SrcPort        uint16
+	DstPort        uint16
+	Length         uint32 // on the wire
+	CapturedLength uint32
+	Direction      string // "in", "out" or empty if unknown
+	Comments       []string
+	Radiotap       *packet.Radiotap // of radiotap captures, else nil
+	USB            *packet.USBPcap  // of USBPcap captures, else nil
+	Netlink        *packet.Netlink  // of netlink captures, else nil
+	SrcGeo         geoip.Info       // with Template.GeoIP, what it knows of SrcIP
+	DstGeo         geoip.Info
+	SrcMAC         string // empty if the link layer has none
+	DstMAC         string
+	SrcVendor      string // of SrcMAC, from Template.OUI
+	DstVendor      string
+}
+
+// fieldNames maps the names FieldsTemplate accepts to Fields.
+var fieldNames = map[string]string{
+	"number":     "{{.Number}}",
+	"time":       `{{.Time.UTC.Format "2006-01-02T15:04:05.000000000Z"}}`,
+	"relative":   "{{printf \"%.9f\" .Relative.Seconds}}",
+	"delta":      "{{printf \"%.9f\" .Delta.Seconds}}",
+	"interface":  "{{.Interface}}",
+	"protocol":   "{{.Protocol}}",
+	"src":        "{{.Src}}",
+	"dst":        "{{.Dst}}",
+	"srcip":      "{{.SrcIP}}",
+	"dstip":      "{{.DstIP}}",
+	"srcport":    "{{.SrcPort}}",
+	"dstport":    "{{.DstPort}}",
+	"length":     "{{.Length}}",
+	"caplen":     "{{.CapturedLength}}",
+	"direction":  "{{.Direction}}",
+	"comments":   `{{join .Comments ","}}`,
+	"channel":    `{{with .Radiotap}}{{if .Has 3}}{{.ChannelFreq}}{{end}}{{end}}`,
+	"signal":     `{{with .Radiotap}}{{if .Has 5}}{{.Signal}}{{end}}{{end}}`,
+	"mcs":        `{{with .Radiotap}}{{if .Has 19}}{{.MCS}}{{end}}{{end}}`,
+	"transfer":   `{{with .USB}}{{.Transfer}}{{end}}`,
+	"irp":        `{{with .USB}}{{printf "%#x" .IRPID}}{{end}}`,
+	"status":     `{{with .USB}}{{printf "%#x" .Status}}{{end}}`,
+	"family":     `{{with .Netlink}}{{.Family}}{{end}}`,
+	"nltype":     `{{with .Netlink}}{{.Type}}{{end}}`,
+	"nlseq":      `{{with .Netlink}}{{.Seq}}{{end}}`,
+	"nlpid":      `{{with .Netlink}}{{.PID}}{{end}}`,
+	"srccountry": "{{.SrcGeo.Country}}",
+	"dstcountry": "{{.DstGeo.Country}}",
+	"srcasn":     "{{with .SrcGeo.ASN}}{{.}}{{end}}",
+	"dstasn":     "{{with .DstGeo.ASN}}{{.}}{{end}}",
+	"srcorg":     "{{.SrcGeo.Org}}",
+	"dstorg":     "{{.DstGeo.Org}}",
+	"srcmac":     "{{.SrcMAC}}",
+	"dstmac":     "{{.DstMAC}}",
+	"srcvendor":  "{{.SrcVendor}}",
+	"dstvendor":  "{{.DstVendor}}",
+}
+
+// FieldsTemplate returns a template writing the named fields, like
+// tshark -T fields -e. The names are those of Fields in lower case, with
+// caplen for CapturedLength, and srccountry, srcasn, srcorg and their dst
+// counterparts for the GeoIP fields.
+func FieldsTemplate(names []string, separator string) (string, error) {
+
+	var parts []string
+	for _, name := range names {
+		part, ok := fieldNames[strings.ToLower(name)]
+		if !ok {
+			return "", &TemplateError{fmt.Sprintf("unknown field %q", name)}
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, separator), nil
+}
+
+// Template writes a line per packet by executing a text/template over its
+// Fields. The template can also call join, strings.Join.
+type Template struct {
+	Err   error     // the first error writing, after which nothing more is written
+	GeoIP geoip.Set // if set, fills SrcGeo and DstGeo
+	OUI   *oui.DB   // the vendors of MAC addresses, oui.Builtin() if nil
+
+	w     io.Writer
+	t     *template.Template
+	first time.Time
+	prev  time.Time
+}
+
+// NewTemplate parses text. A newline is added after each packet unless
+// the text ends with one.
+func NewTemplate(w io.Writer, text string) (*Template, error) {
+
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	t, err := template.New("packet").Funcs(template.FuncMap{"join": strings.Join}).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &Template{w: w, t: t}, nil
+}
+
+// Packet writes the packet.
+func (t *Template) Packet(p *Packet) {
+
+	if t.Err != nil {
+		return
+	}
+	if t.first.IsZero() {
+		t.first, t.prev = p.Timestamp, p.Timestamp
+	}
+
+	f := Fields{
+		Number:         p.Number,
+		Time:           p.Timestamp,
+		Relative:       p.Timestamp.Sub(t.first),
+		Delta:          p.Timestamp.Sub(t.prev),
+		Interface:      p.InterfaceID,
+		Length:         p.OriginalLength,
+		CapturedLength: p.CapturedLength,
+		Comments:       p.Comments,
+	}
+	t.prev = p.Timestamp
+
+	switch p.Flags & 3 {
+	case pcapng.DirectionInbound:
+		f.Direction = "in"
+	case pcapng.DirectionOutbound:
+		f.Direction = "out"
+	}
+
+	pkt := packet.Decode(p.LinkType, p.Data)
+	f.Radiotap = pkt.Radiotap
+	f.Netlink = pkt.Netlink
+	if u := pkt.USB; u != nil {
+		// like Wireshark, the device address and "host"
+		f.USB = u
+		f.Src, f.Dst = "host", u.Address()
+		if u.FromDevice() {
+			f.Src, f.Dst = f.Dst, f.Src
+		}
+	}
+	if f.Direction == "" && pkt.HCI != nil {
+		// btmon keeps the direction in the pseudo-header
+		f.Direction = "out"
+		if pkt.HCI.Received {
+			f.Direction = "in"
+		}
+	}
+	if pkt.SrcMAC != nil {
+		db := t.OUI
+		if db == nil {
+			db = oui.Builtin()
+		}
+		f.SrcMAC, f.DstMAC = pkt.SrcMAC.String(), pkt.DstMAC.String()
+		f.SrcVendor, f.DstVendor = db.Name(pkt.SrcMAC), db.Name(pkt.DstMAC)
+		f.Src, f.Dst = db.Identity(pkt.SrcMAC), db.Identity(pkt.DstMAC)
+	}
+	if flow, ok := pkt.Flow(); ok {
+		f.Protocol = packet.ProtocolName(flow.Protocol)
+		f.SrcIP = net.IP(flow.SrcIP[:]).String()
+		f.DstIP = net.IP(flow.DstIP[:]).String()
+		f.SrcPort, f.DstPort = flow.SrcPort, flow.DstPort
+		f.Src, f.Dst = f.SrcIP, f.DstIP
+		if flow.Protocol == packet.ProtocolTCP || flow.Protocol == packet.ProtocolUDP {
+			f.Src = net.JoinHostPort(f.SrcIP, strconv.Itoa(int(f.SrcPort)))
+			f.Dst = net.JoinHostPort(f.DstIP, strconv.Itoa(int(f.DstPort)))
+		}
+	}
+	if t.GeoIP != nil && pkt.IPVersion != 0 {
+		if f.SrcGeo, t.Err = t.GeoIP.Info(pkt.SrcIP); t.Err != nil {
+			return
+		}
+		if f.DstGeo, t.Err = t.GeoIP.Info(pkt.DstIP); t.Err != nil {
+			return
+		}
+	}
+
+	t.Err = t.t.Execute(t.w, &f)
+}
+
+// Finish does nothing.
+func (t *Template) Finish() {
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"github.com/RajeshGottlieb/go/pcap"
+	"github.com/RajeshGottlieb/go/pcapng"
+	"os"
+)
+
+func main() {
+
+	order := flag.String("order", "", "write big, little or host endian instead of the opposite of the input")
+	flag.Parse()
+
+	var endian binary.ByteOrder
+	switch *order {
+	case "":
+	case "big":
+		endian = binary.BigEndian
+	case "little":
+		endian = binary.LittleEndian
+	case "host":
+		endian = pcapng.HostByteOrder()
+	default:
+		flag.Usage()
+		return
+	}
+
+	if flag.NArg() != 2 {
+		fmt.Printf("usage: %v [-order big|little|host] <input-pcap|pcapng> <output>\n", os.Args[0])
+		return
+	}
+
+	rfh, err := os.Open(flag.Arg(0))
+	if err != nil {
+		panic(err)
+	}
+	defer rfh.Close()
+
+	wfh, err := os.Create(flag.Arg(1))
+	if err != nil {
+		panic(err)
+	}
+	defer wfh.Close()
+
+	br := bufio.NewReader(rfh)
+	bw := bufio.NewWriter(wfh)
+
+	magic, err := br.Peek(4)
+	if err != nil {
+		panic(err)
+	}
+
+	if binary.LittleEndian.Uint32(magic) == pcapng.SECTION_HEADER_BLOCK {
+		err = pcapng.ConvertByteOrder(pcapng.Reader(br), pcapng.Writer(bw), endian)
+	} else {
+		var pr *pcap.PcapReader
+		if pr, err = pcap.Reader(br); err == nil {
+			if endian == nil {
+				endian = pcapng.OppositeByteOrder(pr.Endian)
+			}
+			err = pcap.ConvertByteOrder(pr, bw, endian)
+		}
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	if err := bw.Flush(); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,223 @@
+package tlsdecrypt
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"math/bits"
+)
+
+// chacha20Poly1305 is the ChaCha20-Poly1305 AEAD of RFC 8439, which is not
+// in the standard library. It is written for clarity rather than speed.
+type chacha20Poly1305 struct {
+	key [8]uint32
+}
+
+func newChaCha20Poly1305(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, &DecryptError{"ChaCha20-Poly1305 key is not 32 bytes"}
+	}
+	c := new(chacha20Poly1305)
+	for i := range c.key {
+		c.key[i] = binary.LittleEndian.Uint32(key[4*i:])
+	}
+	return c, nil
+}
+
+func (c *chacha20Poly1305) NonceSize() int { return 12 }
+func (c *chacha20Poly1305) Overhead() int  { return 16 }
+
+func (c *chacha20Poly1305) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	ret, out := sliceForAppend(dst, len(plaintext)+16)
+	var polyKey [64]byte
+	c.block(&polyKey, nonce, 0)
+	c.xorKeyStream(out, plaintext, nonce)
+	tag := poly1305(polyKey[:32], macData(additionalData, out[:len(plaintext)]))
+	copy(out[len(plaintext):], tag[:])
+	return ret
+}
+
+func (c *chacha20Poly1305) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(ciphertext) < 16 {
+		return nil, &DecryptError{"ChaCha20-Poly1305 ciphertext is too short"}
+	}
+	n := len(ciphertext) - 16
+	var polyKey [64]byte
+	c.block(&polyKey, nonce, 0)
+	tag := poly1305(polyKey[:32], macData(additionalData, ciphertext[:n]))
+	if subtle.ConstantTimeCompare(tag[:], ciphertext[n:]) != 1 {
+		return nil, &DecryptError{"ChaCha20-Poly1305 message authentication failed"}
+	}
+	ret, out := sliceForAppend(dst, n)
+	c.xorKeyStream(out, ciphertext[:n], nonce)
+	return ret, nil
+}
+
+// sliceForAppend extends in by n bytes, returning the whole and the new part.
+func sliceForAppend(in []byte, n int) (head, tail []byte) {
+	if total := len(in) + n; cap(in) >= total {
+		head = in[:total]
+	} else {
+		head = make([]byte, total)
+		copy(head, in)
+	}
+	return head, head[len(in):]
+}
+
+// macData returns what Poly1305 authenticates: the additional data and the
+// ciphertext, each padded to 16 bytes, followed by their lengths.
+func macData(additionalData, ciphertext []byte) []byte {
+	padded := func(n int) int { return (n + 15) &^ 15 }
+	m := make([]byte, padded(len(additionalData))+padded(len(ciphertext))+16)
+	copy(m, additionalData)
+	copy(m[padded(len(additionalData)):], ciphertext)
+	binary.LittleEndian.PutUint64(m[len(m)-16:], uint64(len(additionalData)))
+	binary.LittleEndian.PutUint64(m[len(m)-8:], uint64(len(ciphertext)))
+	return m
+}
+
+// xorKeyStream encrypts or decrypts src into dst with the key stream
+// starting at block counter 1, block 0 being the Poly1305 key.
+func (c *chacha20Poly1305) xorKeyStream(dst, src, nonce []byte) {
+	var ks [64]byte
+	for counter := uint32(1); len(src) > 0; counter++ {
+		c.block(&ks, nonce, counter)
+		n := copy(dst, src)
+		if n > len(ks) {
+			n = len(ks)
+		}
+		for i := 0; i < n; i++ {
+			dst[i] = src[i] ^ ks[i]
+		}
+		dst, src = dst[n:], src[n:]
+	}
+}
+
+func quarterRound(a, b, c, d uint32) (uint32, uint32, uint32, uint32) {
+	a += b
+	d = bits.RotateLeft32(d^a, 16)
+	c += d
+	b = bits.RotateLeft32(b^c, 12)
+	a += b
+	d = bits.RotateLeft32(d^a, 8)
+	c += d
+	b = bits.RotateLeft32(b^c, 7)
+	return a, b, c, d
+}
+
+// block computes the ChaCha20 block of counter (RFC 8439 section 2.3).
+func (c *chacha20Poly1305) block(out *[64]byte, nonce []byte, counter uint32) {
+
+	var s [16]uint32
+	s[0], s[1], s[2], s[3] = 0x61707865, 0x3320646e, 0x79622d32, 0x6b206574
+	copy(s[4:12], c.key[:])
+	s[12] = counter
+	s[13] = binary.LittleEndian.Uint32(nonce[0:])
+	s[14] = binary.LittleEndian.Uint32(nonce[4:])
+	s[15] = binary.LittleEndian.Uint32(nonce[8:])
+
+	x := s
+	for i := 0; i < 10; i++ {
+		x[0], x[4], x[8], x[12] = quarterRound(x[0], x[4], x[8], x[12])
+		x[1], x[5], x[9], x[13] = quarterRound(x[1], x[5], x[9], x[13])
+		x[2], x[6], x[10], x[14] = quarterRound(x[2], x[6], x[10], x[14])
+		x[3], x[7], x[11], x[15] = quarterRound(x[3], x[7], x[11], x[15])
+		x[0], x[5], x[10], x[15] = quarterRound(x[0], x[5], x[10], x[15])
+		x[1], x[6], x[11], x[12] = quarterRound(x[1], x[6], x[11], x[12])
+		x[2], x[7], x[8], x[13] = quarterRound(x[2], x[7], x[8], x[13])
+		x[3], x[4], x[9], x[14] = quarterRound(x[3], x[4], x[9], x[14])
+	}
+	for i := range x {
+		binary.LittleEndian.PutUint32(out[4*i:], x[i]+s[i])
+	}
+}
+
+// poly1305 returns the Poly1305 tag of msg, whose length is a multiple of
+// 16 as macData pads it, under a one time key (RFC 8439 section 2.5).
+// The accumulator is kept in five 26 bit limbs.
+func poly1305(key []byte, msg []byte) [16]byte {
+
+	const mask = 1<<26 - 1
+	le := binary.LittleEndian
+
+	// r clamped
+	r0 := uint64(le.Uint32(key[0:]) & 0x3ffffff)
+	r1 := uint64(le.Uint32(key[3:]) >> 2 & 0x3ffff03)
+	r2 := uint64(le.Uint32(key[6:]) >> 4 & 0x3ffc0ff)
+	r3 := uint64(le.Uint32(key[9:]) >> 6 & 0x3f03fff)
+	r4 := uint64(le.Uint32(key[12:]) >> 8 & 0x00fffff)
+	s1, s2, s3, s4 := r1*5, r2*5, r3*5, r4*5
+
+	var h0, h1, h2, h3, h4 uint64
+	for ; len(msg) >= 16; msg = msg[16:] {
+		// add the block with its high bit
+		h0 += uint64(le.Uint32(msg[0:]) & mask)
+		h1 += uint64(le.Uint32(msg[3:]) >> 2 & mask)
+		h2 += uint64(le.Uint32(msg[6:]) >> 4 & mask)
+		h3 += uint64(le.Uint32(msg[9:]) >> 6 & mask)
+		h4 += uint64(le.Uint32(msg[12:])>>8 | 1<<24)
+
+		// multiply by r modulo 2^130 - 5
+		d0 := h0*r0 + h1*s4 + h2*s3 + h3*s2 + h4*s1
+		d1 := h0*r1 + h1*r0 + h2*s4 + h3*s3 + h4*s2
+		d2 := h0*r2 + h1*r1 + h2*r0 + h3*s4 + h4*s3
+		d3 := h0*r3 + h1*r2 + h2*r1 + h3*r0 + h4*s4
+		d4 := h0*r4 + h1*r3 + h2*r2 + h3*r1 + h4*r0
+
+		d1 += d0 >> 26
+		h0 = d0 & mask
+		d2 += d1 >> 26
+		h1 = d1 & mask
+		d3 += d2 >> 26
+		h2 = d2 & mask
+		d4 += d3 >> 26
+		h3 = d3 & mask
+		h0 += (d4 >> 26) * 5
+		h4 = d4 & mask
+		h1 += h0 >> 26
+		h0 &= mask
+	}
+
+	// carry fully
+	h2 += h1 >> 26
+	h1 &= mask
+	h3 += h2 >> 26
+	h2 &= mask
+	h4 += h3 >> 26
+	h3 &= mask
+	h0 += (h4 >> 26) * 5
+	h4 &= mask
+	h1 += h0 >> 26
+	h0 &= mask
+
+	// h - p, used if it is not negative
+	g0 := h0 + 5
+	g1 := h1 + g0>>26
+	g0 &= mask
+	g2 := h2 + g1>>26
+	g1 &= mask
+	g3 := h3 + g2>>26
+	g2 &= mask
+	g4 := h4 + g3>>26 - 1<<26
+	g3 &= mask
+	if g4>>63 == 0 {
+		h0, h1, h2, h3, h4 = g0, g1, g2, g3, g4
+	}
+
+	// h + s modulo 2^128
+	f0 := (h0 | h1<<26) & 0xffffffff
+	f1 := (h1>>6 | h2<<20) & 0xffffffff
+	f2 := (h2>>12 | h3<<14) & 0xffffffff
+	f3 := (h3>>18 | h4<<8) & 0xffffffff
+
+	var tag [16]byte
+	f := f0 + uint64(le.Uint32(key[16:]))
+	le.PutUint32(tag[0:], uint32(f))
+	f = f1 + uint64(le.Uint32(key[20:])) + f>>32
+	le.PutUint32(tag[4:], uint32(f))
+	f = f2 + uint64(le.Uint32(key[24:])) + f>>32
+	le.PutUint32(tag[8:], uint32(f))
+	f = f3 + uint64(le.Uint32(key[28:])) + f>>32
+	le.PutUint32(tag[12:], uint32(f))
+	return tag
+}
@@ -0,0 +1,58 @@
+package tlsdecrypt
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestChaCha20Poly1305(t *testing.T) {
+
+	unhex := func(s string) []byte {
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	// RFC 8439 section 2.8.2
+	key := unhex("808182838485868788898a8b8c8d8e8f909192939495969798999a9b9c9d9e9f")
+	nonce := unhex("070000004041424344454647")
+	aad := unhex("50515253c0c1c2c3c4c5c6c7")
+	plaintext := []byte("Ladies and Gentlemen of the class of '99: If I could offer you only one tip for the future, sunscreen would be it.")
+	sealed := unhex("d31a8d34648e60db7b86afbc53ef7ec2a4aded51296e08fea9e2b5a736ee62d63dbea45e8ca9671282fafb69da92728b1a71de0a9e060b2905d6a5b67ecd3b3692ddbd7f2d778b8c9803aee328091b58fab324e4fad675945585808b4831d7bc3ff4def08e4b7a9de576d26586cec64b6116" +
+		"1ae10b594f09e26a7e902ecbd0600691")
+
+	aead, err := newChaCha20Poly1305(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := aead.Seal(nil, nonce, plaintext, aad); !bytes.Equal(got, sealed) {
+		t.Errorf("Seal:\n%x\nwant\n%x", got, sealed)
+	}
+	got, err := aead.Open([]byte("prefix"), nonce, sealed, aad)
+	if err != nil || !bytes.Equal(got, append([]byte("prefix"), plaintext...)) {
+		t.Errorf("Open: %q, %v", got, err)
+	}
+
+	for i := range sealed {
+		tampered := append([]byte(nil), sealed...)
+		tampered[i] ^= 0x80
+		if _, err := aead.Open(nil, nonce, tampered, aad); err == nil {
+			t.Errorf("Open accepted a flip of byte %v", i)
+		}
+	}
+	if _, err := aead.Open(nil, nonce, sealed, aad[1:]); err == nil {
+		t.Errorf("Open accepted other additional data")
+	}
+	if _, err := aead.Open(nil, nonce, sealed[:15], aad); err == nil {
+		t.Errorf("Open accepted a ciphertext shorter than the tag")
+	}
+
+	// empty plaintext and additional data, only the tag
+	empty := aead.Seal(nil, nonce, nil, nil)
+	if got, err := aead.Open(nil, nonce, empty, nil); len(empty) != 16 || err != nil || len(got) != 0 {
+		t.Errorf("empty: sealed to %v bytes, opened to %q, %v", len(empty), got, err)
+	}
+}
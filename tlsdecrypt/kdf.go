@@ -0,0 +1,56 @@
+package tlsdecrypt
+
+import (
+	"crypto/hmac"
+	"encoding/binary"
+	"hash"
+)
+
+// prf12 is the TLS 1.2 pseudo random function (RFC 5246 section 5).
+func prf12(h func() hash.Hash, secret []byte, label string, seed []byte, length int) []byte {
+
+	seed = append([]byte(label), seed...)
+	out := make([]byte, 0, length)
+
+	mac := hmac.New(h, secret)
+	mac.Write(seed)
+	a := mac.Sum(nil)
+
+	for len(out) < length {
+		mac.Reset()
+		mac.Write(a)
+		mac.Write(seed)
+		out = mac.Sum(out)
+
+		mac.Reset()
+		mac.Write(a)
+		a = mac.Sum(nil)
+	}
+	return out[:length]
+}
+
+// expandLabel is HKDF-Expand-Label from TLS 1.3 (RFC 8446 section 7.1)
+// with an empty context.
+func expandLabel(h func() hash.Hash, secret []byte, label string, length int) []byte {
+
+	full := "tls13 " + label
+	info := make([]byte, 2, 4+len(full))
+	binary.BigEndian.PutUint16(info, uint16(length))
+	info = append(info, byte(len(full)))
+	info = append(info, full...)
+	info = append(info, 0) // context
+
+	// HKDF-Expand (RFC 5869)
+	out := make([]byte, 0, length)
+	mac := hmac.New(h, secret)
+	var t []byte
+	for i := byte(1); len(out) < length; i++ {
+		mac.Reset()
+		mac.Write(t)
+		mac.Write(info)
+		mac.Write([]byte{i})
+		t = mac.Sum(nil)
+		out = append(out, t...)
+	}
+	return out[:length]
+}
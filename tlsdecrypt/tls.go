@@ -0,0 +1,411 @@
+package tlsdecrypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+
+	"github.com/RajeshGottlieb/go/packet"
+)
+
+// TLS record content types
+const (
+	recordChangeCipherSpec = 20
+	recordAlert            = 21
+	recordHandshake        = 22
+	recordApplicationData  = 23
+)
+
+// TLS handshake message types
+const (
+	handshakeClientHello = 1
+	handshakeServerHello = 2
+	handshakeFinished    = 20
+	handshakeKeyUpdate   = 24
+)
+
+const (
+	versionTLS12 = 0x0303
+	versionTLS13 = 0x0304
+)
+
+// helloRetryRequest is the random of a ServerHello that is a TLS 1.3
+// HelloRetryRequest, the SHA-256 of "HelloRetryRequest" (RFC 8446 4.1.3).
+var helloRetryRequest = []byte{
+	0xCF, 0x21, 0xAD, 0x74, 0xE5, 0x9A, 0x61, 0x11, 0xBE, 0x1D, 0x8C, 0x02, 0x1E, 0x65, 0xB8, 0x91,
+	0xC2, 0xA2, 0x11, 0x16, 0x7A, 0xBB, 0x8C, 0x5E, 0x07, 0x9E, 0x09, 0xE2, 0xC8, 0xA8, 0x33, 0x9C,
+}
+
+// suite describes the parts of a cipher suite needed for decryption.
+type suite struct {
+	keyLen int
+	hash   func() hash.Hash
+	aead   func(key []byte) (cipher.AEAD, error)
+	// ivLen is the length of the TLS 1.2 IV. AES-GCM records prepend an
+	// 8 byte explicit nonce to its 4, ChaCha20-Poly1305 ones use its 12
+	// like TLS 1.3 does.
+	ivLen int
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+var suites = map[uint16]suite{
+	0x1301: {16, sha256.New, newGCM, 4},               // TLS_AES_128_GCM_SHA256
+	0x1302: {32, sha512.New384, newGCM, 4},            // TLS_AES_256_GCM_SHA384
+	0x1303: {32, sha256.New, newChaCha20Poly1305, 12}, // TLS_CHACHA20_POLY1305_SHA256
+	0x009C: {16, sha256.New, newGCM, 4},               // TLS_RSA_WITH_AES_128_GCM_SHA256
+	0x009D: {32, sha512.New384, newGCM, 4},            // TLS_RSA_WITH_AES_256_GCM_SHA384
+	0x009E: {16, sha256.New, newGCM, 4},               // TLS_DHE_RSA_WITH_AES_128_GCM_SHA256
+	0x009F: {32, sha512.New384, newGCM, 4},            // TLS_DHE_RSA_WITH_AES_256_GCM_SHA384
+	0xC02B: {16, sha256.New, newGCM, 4},               // TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256
+	0xC02C: {32, sha512.New384, newGCM, 4},            // TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384
+	0xC02F: {16, sha256.New, newGCM, 4},               // TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256
+	0xC030: {32, sha512.New384, newGCM, 4},            // TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384
+	0xCCA8: {32, sha256.New, newChaCha20Poly1305, 12}, // TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256
+	0xCCA9: {32, sha256.New, newChaCha20Poly1305, 12}, // TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256
+	0xCCAA: {32, sha256.New, newChaCha20Poly1305, 12}, // TLS_DHE_RSA_WITH_CHACHA20_POLY1305_SHA256
+}
+
+// conn is the state of one TCP connection.
+type conn struct {
+	d      *Decrypter
+	dirs   map[packet.Flow]*direction
+	notTLS bool
+
+	stream       *Stream
+	client       *direction
+	server       *direction
+	clientRandom []byte
+	serverRandom []byte
+	suite        suite
+}
+
+// direction is the state of one direction of a connection.
+type direction struct {
+	flow      packet.Flow
+	tcp       packet.Reassembler
+	records   []byte // reassembled bytes not yet parsed into records
+	handshake []byte // plaintext handshake bytes not yet parsed into messages
+
+	aead   cipher.AEAD // nil until the direction is encrypted
+	iv     []byte
+	seq    uint64
+	secret []byte // TLS 1.3 traffic secret in use
+	failed bool
+}
+
+// data handles reassembled bytes of one direction.
+func (c *conn) data(dir *direction, data []byte) {
+
+	if c.stream == nil && len(dir.records) == 0 {
+		// the first bytes of a TLS connection are a handshake record
+		if data[0] != recordHandshake || (len(data) > 1 && data[1] != 3) {
+			c.notTLS = true
+			return
+		}
+	}
+
+	dir.records = append(dir.records, data...)
+
+	for len(dir.records) >= 5 && !dir.failed {
+		length := int(binary.BigEndian.Uint16(dir.records[3:5]))
+		if len(dir.records) < 5+length {
+			break
+		}
+		record := dir.records[:5+length]
+		dir.records = dir.records[5+length:]
+
+		if err := c.record(dir, record); err != nil {
+			dir.failed = true
+			if c.stream != nil && c.stream.Err == nil {
+				c.stream.Err = err
+			}
+		}
+	}
+}
+
+// record handles one complete TLS record.
+func (c *conn) record(dir *direction, record []byte) error {
+
+	contentType := record[0]
+	body := record[5:]
+
+	if dir.aead == nil {
+		switch contentType {
+		case recordHandshake:
+			return c.handshake(dir, body)
+		case recordChangeCipherSpec:
+			if c.stream != nil && c.stream.Version == versionTLS12 {
+				return c.startTLS12(dir)
+			}
+		}
+		return nil
+	}
+
+	if c.stream.Version == versionTLS13 {
+		if contentType == recordChangeCipherSpec {
+			return nil // sent unencrypted for middlebox compatibility
+		}
+		plaintext, err := dir.open(record[:5], body)
+		if err != nil {
+			return err
+		}
+		// strip the padding, the last non zero byte is the real content type
+		i := len(plaintext) - 1
+		for i >= 0 && plaintext[i] == 0 {
+			i--
+		}
+		if i < 0 {
+			return &DecryptError{"TLS 1.3 record without content type"}
+		}
+		contentType, plaintext = plaintext[i], plaintext[:i]
+		return c.plaintext(dir, contentType, plaintext)
+	}
+
+	// TLS 1.2 AES-GCM records start with an 8 byte explicit nonce
+	explicit := 12 - c.suite.ivLen
+	if len(body) < explicit+16 {
+		return &DecryptError{fmt.Sprintf("short TLS 1.2 record of %v bytes", len(body))}
+	}
+	aad := make([]byte, 13)
+	binary.BigEndian.PutUint64(aad, dir.seq)
+	copy(aad[8:11], record[:3])
+	binary.BigEndian.PutUint16(aad[11:], uint16(len(body)-explicit-16))
+
+	var nonce []byte
+	if explicit > 0 {
+		nonce = append(append([]byte(nil), dir.iv...), body[:explicit]...)
+	} else {
+		nonce = dir.nonce()
+	}
+	plaintext, err := dir.aead.Open(nil, nonce, body[explicit:], aad)
+	if err != nil {
+		return &DecryptError{fmt.Sprintf("TLS 1.2 record %v failed to decrypt", dir.seq)}
+	}
+	dir.seq++
+	return c.plaintext(dir, contentType, plaintext)
+}
+
+// plaintext handles the decrypted content of a record.
+func (c *conn) plaintext(dir *direction, contentType byte, plaintext []byte) error {
+	switch contentType {
+	case recordApplicationData:
+		if dir == c.client {
+			c.stream.ClientData = append(c.stream.ClientData, plaintext...)
+		} else {
+			c.stream.ServerData = append(c.stream.ServerData, plaintext...)
+		}
+	case recordHandshake:
+		return c.handshake(dir, plaintext)
+	}
+	return nil
+}
+
+// handshake parses the handshake messages of a direction.
+func (c *conn) handshake(dir *direction, body []byte) error {
+
+	dir.handshake = append(dir.handshake, body...)
+
+	for len(dir.handshake) >= 4 {
+		length := int(dir.handshake[1])<<16 | int(dir.handshake[2])<<8 | int(dir.handshake[3])
+		if len(dir.handshake) < 4+length {
+			break
+		}
+		msgType := dir.handshake[0]
+		msg := dir.handshake[4 : 4+length]
+		dir.handshake = dir.handshake[4+length:]
+
+		var err error
+		switch msgType {
+		case handshakeClientHello:
+			err = c.clientHello(dir, msg)
+		case handshakeServerHello:
+			err = c.serverHello(dir, msg)
+		case handshakeFinished:
+			if c.stream != nil && c.stream.Version == versionTLS13 {
+				// the handshake is over, switch to the application traffic secret
+				label := "SERVER_TRAFFIC_SECRET_0"
+				if dir == c.client {
+					label = "CLIENT_TRAFFIC_SECRET_0"
+				}
+				err = c.startTLS13(dir, label)
+			}
+		case handshakeKeyUpdate:
+			if c.stream != nil && c.stream.Version == versionTLS13 {
+				err = dir.setTLS13Secret(c.suite, expandLabel(c.suite.hash, dir.secret, "traffic upd", c.suite.hash().Size()))
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *conn) clientHello(dir *direction, msg []byte) error {
+	if len(msg) < 34 {
+		return &DecryptError{"short ClientHello"}
+	}
+	c.client = dir
+	if c.stream != nil && bytes.Equal(c.clientRandom, msg[2:34]) {
+		return nil // the second ClientHello after a HelloRetryRequest
+	}
+	c.clientRandom = append([]byte(nil), msg[2:34]...)
+	c.stream = &Stream{Client: dir.flow}
+	c.d.streams = append(c.d.streams, c.stream)
+	return nil
+}
+
+func (c *conn) serverHello(dir *direction, msg []byte) error {
+	if c.stream == nil {
+		return &DecryptError{"ServerHello without ClientHello"}
+	}
+	if len(msg) < 35 {
+		return &DecryptError{"short ServerHello"}
+	}
+	c.server = dir
+	c.stream.Version = binary.BigEndian.Uint16(msg[0:2])
+	c.serverRandom = append([]byte(nil), msg[2:34]...)
+
+	rest := msg[34:]
+	sessionIDLen := int(rest[0])
+	if len(rest) < 1+sessionIDLen+3 {
+		return &DecryptError{"short ServerHello"}
+	}
+	rest = rest[1+sessionIDLen:]
+	c.stream.CipherSuite = binary.BigEndian.Uint16(rest[0:2])
+	rest = rest[3:] // cipher suite and compression method
+
+	// the supported_versions extension carries the real TLS 1.3 version
+	if len(rest) >= 2 {
+		exts := rest[2:]
+		for len(exts) >= 4 {
+			extType := binary.BigEndian.Uint16(exts[0:2])
+			extLen := int(binary.BigEndian.Uint16(exts[2:4]))
+			if len(exts) < 4+extLen {
+				break
+			}
+			if extType == 43 && extLen == 2 {
+				c.stream.Version = binary.BigEndian.Uint16(exts[4:6])
+			}
+			exts = exts[4+extLen:]
+		}
+	}
+
+	s, ok := suites[c.stream.CipherSuite]
+	if !ok {
+		return &DecryptError{fmt.Sprintf("unsupported cipher suite 0x%04x", c.stream.CipherSuite)}
+	}
+	c.suite = s
+
+	if bytes.Equal(c.serverRandom, helloRetryRequest) {
+		return nil // the keys come with the ServerHello answering the second ClientHello
+	}
+
+	switch c.stream.Version {
+	case versionTLS12:
+		return nil // keys are switched on by ChangeCipherSpec
+	case versionTLS13:
+		// everything after the ServerHello is encrypted
+		if err := c.startTLS13(c.server, "SERVER_HANDSHAKE_TRAFFIC_SECRET"); err != nil {
+			return err
+		}
+		return c.startTLS13(c.client, "CLIENT_HANDSHAKE_TRAFFIC_SECRET")
+	}
+	return &DecryptError{fmt.Sprintf("unsupported TLS version 0x%04x", c.stream.Version)}
+}
+
+// secret looks up a secret of this connection's client random.
+func (c *conn) secret(label string) ([]byte, error) {
+	secret, ok := c.d.secrets[hex.EncodeToString(c.clientRandom)][label]
+	if !ok {
+		return nil, &DecryptError{fmt.Sprintf("no %v for client random %x", label, c.clientRandom)}
+	}
+	return secret, nil
+}
+
+// startTLS13 switches a direction to the keys derived from a traffic secret.
+func (c *conn) startTLS13(dir *direction, label string) error {
+	if dir == nil {
+		return &DecryptError{"TLS 1.3 handshake from unknown direction"}
+	}
+	secret, err := c.secret(label)
+	if err != nil {
+		return err
+	}
+	return dir.setTLS13Secret(c.suite, secret)
+}
+
+func (dir *direction) setTLS13Secret(s suite, secret []byte) error {
+	var err error
+	if dir.aead, err = s.aead(expandLabel(s.hash, secret, "key", s.keyLen)); err != nil {
+		return err
+	}
+	dir.iv = expandLabel(s.hash, secret, "iv", 12)
+	dir.secret = secret
+	dir.seq = 0
+	return nil
+}
+
+// startTLS12 switches a direction to the keys derived from the master secret.
+func (c *conn) startTLS12(dir *direction) error {
+
+	master, err := c.secret("CLIENT_RANDOM")
+	if err != nil {
+		return err
+	}
+
+	seed := append(append([]byte(nil), c.serverRandom...), c.clientRandom...)
+	keyLen, ivLen := c.suite.keyLen, c.suite.ivLen
+	keyBlock := prf12(c.suite.hash, master, "key expansion", seed, 2*keyLen+2*ivLen)
+
+	clientKey := keyBlock[:keyLen]
+	serverKey := keyBlock[keyLen : 2*keyLen]
+	clientIV := keyBlock[2*keyLen : 2*keyLen+ivLen]
+	serverIV := keyBlock[2*keyLen+ivLen:]
+
+	key, iv := serverKey, serverIV
+	if dir == c.client {
+		key, iv = clientKey, clientIV
+	}
+
+	if dir.aead, err = c.suite.aead(key); err != nil {
+		return err
+	}
+	dir.iv = iv
+	dir.seq = 0
+	return nil
+}
+
+// nonce returns the 12 byte IV xored with the sequence number, the nonce
+// of TLS 1.3 and of TLS 1.2 ChaCha20-Poly1305 records.
+func (dir *direction) nonce() []byte {
+	nonce := append([]byte(nil), dir.iv...)
+	for i := 0; i < 8; i++ {
+		nonce[len(nonce)-1-i] ^= byte(dir.seq >> (8 * i))
+	}
+	return nonce
+}
+
+// open decrypts a TLS 1.3 record.
+func (dir *direction) open(header, body []byte) ([]byte, error) {
+
+	plaintext, err := dir.aead.Open(nil, dir.nonce(), body, header)
+	if err != nil {
+		return nil, &DecryptError{fmt.Sprintf("TLS 1.3 record %v failed to decrypt", dir.seq)}
+	}
+	dir.seq++
+	return plaintext, nil
+}
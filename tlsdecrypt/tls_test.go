@@ -0,0 +1,172 @@
+package tlsdecrypt
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"io"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RajeshGottlieb/go/packet"
+	"github.com/RajeshGottlieb/go/pcapng"
+)
+
+// recorder keeps what both ends of a connection write, in order.
+type recorder struct {
+	mu     sync.Mutex
+	writes []recorded
+}
+
+type recorded struct {
+	client bool
+	data   []byte
+}
+
+type recordingConn struct {
+	net.Conn
+	r      *recorder
+	client bool
+}
+
+func (rc *recordingConn) Write(b []byte) (int, error) {
+	rc.r.mu.Lock()
+	rc.r.writes = append(rc.r.writes, recorded{rc.client, append([]byte(nil), b...)})
+	rc.r.mu.Unlock()
+	return rc.Conn.Write(b)
+}
+
+func testCertificate(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		DNSNames:     []string{"example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// parseKeyLog parses the NSS key log format crypto/tls writes.
+func parseKeyLog(t *testing.T, log string) []pcapng.TLSKeyLogEntry {
+	var entries []pcapng.TLSKeyLogEntry
+	s := bufio.NewScanner(strings.NewReader(log))
+	for s.Scan() {
+		f := strings.Fields(s.Text())
+		if len(f) != 3 {
+			continue
+		}
+		random, err1 := hex.DecodeString(f[1])
+		secret, err2 := hex.DecodeString(f[2])
+		if err1 != nil || err2 != nil {
+			t.Fatalf("bad key log line %q", s.Text())
+		}
+		entries = append(entries, pcapng.TLSKeyLogEntry{Label: f[0], ClientRandom: random, Secret: secret})
+	}
+	return entries
+}
+
+// handshake runs a connection between crypto/tls ends that exchange a
+// request and a response, returning the bytes written and the key log.
+func handshake(t *testing.T, version uint16, suite uint16) (*recorder, string) {
+
+	cert := testCertificate(t)
+	var keyLog bytes.Buffer
+	client := &tls.Config{
+		InsecureSkipVerify: true,
+		MinVersion:         version,
+		MaxVersion:         version,
+		KeyLogWriter:       &keyLog,
+	}
+	if suite != 0 {
+		client.CipherSuites = []uint16{suite}
+	}
+	server := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: version, MaxVersion: version}
+
+	r := new(recorder)
+	c, s := net.Pipe()
+	tc := tls.Client(&recordingConn{c, r, true}, client)
+	ts := tls.Server(&recordingConn{s, r, false}, server)
+
+	done := make(chan error, 1)
+	go func() {
+		request := make([]byte, len("request"))
+		if _, err := io.ReadFull(ts, request); err != nil {
+			done <- err
+			return
+		}
+		_, err := ts.Write([]byte("response"))
+		done <- err
+	}()
+	if _, err := tc.Write([]byte("request")); err != nil {
+		t.Fatal(err)
+	}
+	response := make([]byte, len("response"))
+	if _, err := io.ReadFull(tc, response); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if got := tc.ConnectionState().CipherSuite; suite != 0 && got != suite {
+		t.Fatalf("negotiated cipher suite 0x%04x, not 0x%04x", got, suite)
+	}
+	c.Close()
+	s.Close()
+	return r, keyLog.String()
+}
+
+func TestDecryptSuites(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		version uint16
+		suite   uint16
+	}{
+		{"TLS 1.2 AES-128-GCM", tls.VersionTLS12, tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256},
+		{"TLS 1.2 AES-256-GCM", tls.VersionTLS12, tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384},
+		{"TLS 1.2 ChaCha20-Poly1305", tls.VersionTLS12, tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256},
+		{"TLS 1.3", tls.VersionTLS13, 0}, // crypto/tls picks the suite, ChaCha20-Poly1305 with GODEBUG=cpu.aes=off
+	}
+
+	for _, tt := range tests {
+		r, keyLog := handshake(t, tt.version, tt.suite)
+
+		d := NewDecrypter()
+		d.AddKeyLog(parseKeyLog(t, keyLog))
+		c := &conn{d: d, dirs: make(map[packet.Flow]*direction)}
+		client, server := &direction{}, &direction{}
+		for _, w := range r.writes {
+			if w.client {
+				c.data(client, w.data)
+			} else {
+				c.data(server, w.data)
+			}
+		}
+
+		if len(d.streams) != 1 {
+			t.Errorf("%v: %v streams", tt.name, len(d.streams))
+			continue
+		}
+		st := d.streams[0]
+		if st.Err != nil || string(st.ClientData) != "request" || string(st.ServerData) != "response" {
+			t.Errorf("%v: suite 0x%04x decrypted %q and %q, %v", tt.name, st.CipherSuite, st.ClientData, st.ServerData, st.Err)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+// Package tlsdecrypt decrypts TLS connections in pcapng files using the
+// key logs stored in Decryption Secrets Blocks.
+package tlsdecrypt
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"github.com/RajeshGottlieb/go/packet"
+	"github.com/RajeshGottlieb/go/pcapng"
+)
+
+// DecryptError
+type DecryptError struct {
+	errorString string
+}
+
+func (de *DecryptError) Error() string {
+	return de.errorString
+}
+
+// Stream is the decrypted content of one TLS connection.
+type Stream struct {
+	Client      packet.Flow // the client to server direction
+	Version     uint16      // negotiated version, 0x0303 for TLS 1.2 and 0x0304 for TLS 1.3
+	CipherSuite uint16
+	ClientData  []byte // application data sent by the client
+	ServerData  []byte // application data sent by the server
+	Err         error  // why decryption stopped, nil if it didn't
+}
+
+// Decrypter reassembles TCP connections and decrypts the TLS ones.
+type Decrypter struct {
+	secrets map[string]map[string][]byte // client random (hex) -> label -> secret
+	conns   map[packet.Flow]*conn        // keyed by canonical flow
+	streams []*Stream
+}
+
+// NewDecrypter returns a Decrypter without any secrets.
+func NewDecrypter() *Decrypter {
+	return &Decrypter{
+		secrets: make(map[string]map[string][]byte),
+		conns:   make(map[packet.Flow]*conn),
+	}
+}
+
+// AddKeyLog adds TLS secrets. Secrets must be added before the packets of
+// the connections they belong to.
+func (d *Decrypter) AddKeyLog(entries []pcapng.TLSKeyLogEntry) {
+	for _, e := range entries {
+		random := hex.EncodeToString(e.ClientRandom)
+		if d.secrets[random] == nil {
+			d.secrets[random] = make(map[string][]byte)
+		}
+		d.secrets[random][e.Label] = e.Secret
+	}
+}
+
+// Streams returns the TLS connections seen so far in the order they started.
+func (d *Decrypter) Streams() []*Stream {
+	return d.streams
+}
+
+// Packet feeds a decoded packet to the decrypter. Packets that are not TCP are ignored.
+func (d *Decrypter) Packet(p *packet.Packet) {
+
+	if p.Protocol != packet.ProtocolTCP || p.PayloadOffset < 0 {
+		return
+	}
+	flow, _ := p.Flow()
+
+	c, ok := d.conns[flow.Canonical()]
+	if !ok {
+		c = &conn{d: d, dirs: make(map[packet.Flow]*direction)}
+		d.conns[flow.Canonical()] = c
+	}
+	if c.notTLS {
+		return
+	}
+
+	dir, ok := c.dirs[flow]
+	if !ok {
+		dir = &direction{flow: flow}
+		c.dirs[flow] = dir
+	}
+
+	payload := p.Payload()
+	if end := p.NetworkEnd(); end >= p.PayloadOffset && end < len(p.Data) {
+		payload = p.Data[p.PayloadOffset:end] // drop Ethernet padding
+	}
+
+	data := dir.tcp.Add(p.TCPSeq, p.TCPFlags&packet.TCPFlagSYN != 0, payload)
+	if len(data) > 0 {
+		c.data(dir, data)
+	}
+}
+
+// Decrypt reads a whole pcapng file, using the TLS secrets in its
+// Decryption Secrets Blocks to decrypt its TLS connections.
+func Decrypt(pr *pcapng.PcapngReader) ([]*Stream, error) {
+
+	d := NewDecrypter()
+	var interfaces []*pcapng.InterfaceBlock
+
+	for {
+		block, err := pr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		switch b := block.(type) {
+		case *pcapng.SectionBlock:
+			interfaces = nil
+		case *pcapng.InterfaceBlock:
+			interfaces = append(interfaces, b)
+		case *pcapng.DecryptionSecretsBlock:
+			if b.SecretsType == pcapng.SECRETS_TYPE_TLS {
+				entries, err := b.TLSKeyLog()
+				if err != nil {
+					return nil, err
+				}
+				d.AddKeyLog(entries)
+			}
+		case *pcapng.EnhancedPacketBlock:
+			if int(b.InterfaceID) >= len(interfaces) {
+				return nil, &DecryptError{fmt.Sprintf("packet references unknown interface %v", b.InterfaceID)}
+			}
+			d.Packet(packet.Decode(interfaces[b.InterfaceID].LinkType, b.PacketData))
+		}
+	}
+	return d.Streams(), nil
+}
@@ -0,0 +1,15 @@
+package transform
+
+// Annotate adds the opt_comment Comment returns for each packet, nothing
+// if it returns "".
+type Annotate struct {
+	Comment func(p *Packet) string
+}
+
+// Apply adds the comment. It never drops packets.
+func (a *Annotate) Apply(p *Packet) bool {
+	if comment := a.Comment(p); comment != "" {
+		p.Block.WithComment(comment)
+	}
+	return true
+}
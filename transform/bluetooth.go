@@ -0,0 +1,42 @@
+package transform
+
+import (
+	"github.com/RajeshGottlieb/go/packet"
+	"github.com/RajeshGottlieb/go/pcapng"
+)
+
+// HCIDirection copies the direction of the pseudo-header of Bluetooth HCI
+// packets into epb_flags, for tools that only look at the flags. Packets
+// that have a direction already are left alone, and the pseudo-header
+// always is.
+type HCIDirection struct {
+}
+
+// Apply sets the direction. It never drops packets.
+func (h *HCIDirection) Apply(p *Packet) bool {
+
+	if p.LinkType() != packet.LinkTypeBluetoothHCIH4WithPHDR {
+		return true
+	}
+	hci := p.Decode().HCI
+	if hci == nil {
+		return true
+	}
+
+	var flags uint32
+	for _, opt := range p.Block.Options {
+		if o, ok := opt.(*pcapng.Epb_Flags); ok {
+			flags = o.Value
+		}
+	}
+	if flags&3 != pcapng.DirectionUnknown {
+		return true
+	}
+	if hci.Received {
+		flags |= pcapng.DirectionInbound
+	} else {
+		flags |= pcapng.DirectionOutbound
+	}
+	p.Block.WithFlags(flags)
+	return true
+}
@@ -0,0 +1,41 @@
+package transform
+
+import (
+	"github.com/RajeshGottlieb/go/pcapng"
+)
+
+// StripComments removes every opt_comment, from packets and from the
+// section, interface and other blocks.
+type StripComments struct {
+	Removed int // number of comments removed so far
+}
+
+// Apply removes the comments of a packet. It never drops packets.
+func (s *StripComments) Apply(p *Packet) bool {
+	s.strip(p.Block)
+	return true
+}
+
+// ApplyBlock removes the comments of any other block.
+func (s *StripComments) ApplyBlock(b pcapng.Block) bool {
+	s.strip(b)
+	return true
+}
+
+func (s *StripComments) strip(b interface{}) {
+
+	options := pcapng.BlockOptions(b)
+	if options == nil {
+		return
+	}
+
+	kept := (*options)[:0]
+	for _, opt := range *options {
+		if _, ok := opt.(*pcapng.Opt_Comment); ok {
+			s.Removed++
+			continue
+		}
+		kept = append(kept, opt)
+	}
+	*options = kept
+}
@@ -0,0 +1,116 @@
+package transform
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/RajeshGottlieb/go/packet"
+)
+
+// Decap replaces each tunnelled packet by the packet it carries, so that
+// captures of overlay networks can be analyzed per tenant. It knows GRE,
+// IP in IP, VXLAN, GENEVE and ERSPAN, and removes nested tunnels one after
+// another. The ERSPAN session ID, and the hardware timestamp of type III,
+// are kept in an opt_comment. The carried packet is framed for the link type of the
+// interface, reusing the outer link header, so the interfaces are left
+// alone; packets that cannot be, e.g. an Ethernet frame that is not IP on
+// a raw IP interface, are left as they are.
+type Decap struct {
+	KeepOuter    bool // record the removed headers in an opt_comment
+	Decapsulated int  // number of tunnel headers removed so far
+}
+
+// Apply removes the tunnel headers. It never drops packets.
+func (d *Decap) Apply(p *Packet) bool {
+
+	for {
+		pkt := p.Decode()
+		t, ok := pkt.Tunnel()
+		if !ok {
+			return true
+		}
+		data := pkt.Data
+		framed, ok := reframe(p.LinkType(), data, data[t.Inner:], t.InnerType)
+		if !ok {
+			return true
+		}
+
+		if d.KeepOuter {
+			id := ""
+			if t.ID != 0 {
+				id = fmt.Sprintf(" id %v", t.ID)
+			}
+			p.Block.WithComment(fmt.Sprintf("%v %v > %v%v outer %x", t.Kind, pkt.SrcIP, pkt.DstIP, id, data[:t.Inner]))
+		}
+		switch t.Kind {
+		case "erspan2":
+			p.Block.WithComment(fmt.Sprintf("erspan session %v", t.ID))
+		case "erspan3":
+			p.Block.WithComment(fmt.Sprintf("erspan session %v timestamp %v granularity %v", t.ID, t.Timestamp, t.Granularity))
+		}
+		setData(p, framed)
+		d.Decapsulated++
+	}
+}
+
+// setData replaces the packet data, changing the original length by as
+// much as the captured length changes.
+func setData(p *Packet, data []byte) {
+	b := p.Block
+	removed := len(b.PacketData) - len(data)
+	if int(b.OriginalPacketLength) > removed {
+		b.OriginalPacketLength = uint32(int(b.OriginalPacketLength) - removed)
+	} else {
+		b.OriginalPacketLength = uint32(len(data))
+	}
+	b.PacketData = data
+	b.CapturedPacketLength = uint32(len(data))
+}
+
+// reframe frames inner, a packet of EtherType innerType, for linkType,
+// copying what it can of the link header of outer. It returns false if
+// the link type cannot carry the packet.
+func reframe(linkType uint16, outer, inner []byte, innerType uint16) ([]byte, bool) {
+
+	if innerType == packet.EtherTypeTEB {
+		if linkType == packet.LinkTypeEthernet {
+			return inner, true
+		}
+		// keep the IP packet of the frame
+		eth := packet.Decode(packet.LinkTypeEthernet, inner)
+		if eth.NetworkOffset < 0 {
+			return nil, false
+		}
+		inner, innerType = inner[eth.NetworkOffset:], eth.EtherType
+	}
+	if innerType != packet.EtherTypeIPv4 && innerType != packet.EtherTypeIPv6 {
+		return nil, false
+	}
+
+	// header bytes to copy from outer and where its EtherType goes
+	var length, typeOffset int
+	switch linkType {
+	case packet.LinkTypeEthernet:
+		length, typeOffset = 14, 12 // without the VLAN tags
+	case packet.LinkTypeLinuxSLL:
+		length, typeOffset = 16, 14
+	case packet.LinkTypeLinuxSLL2:
+		length, typeOffset = 20, 0
+	case packet.LinkTypeRaw:
+		return inner, true
+	case packet.LinkTypeIPv4:
+		return inner, innerType == packet.EtherTypeIPv4
+	case packet.LinkTypeIPv6:
+		return inner, innerType == packet.EtherTypeIPv6
+	default:
+		return nil, false
+	}
+	if len(outer) < length {
+		return nil, false
+	}
+
+	framed := make([]byte, length, length+len(inner))
+	copy(framed, outer)
+	binary.BigEndian.PutUint16(framed[typeOffset:], innerType)
+	return append(framed, inner...), true
+}
@@ -0,0 +1,42 @@
+package transform
+
+import (
+	"github.com/RajeshGottlieb/go/packet"
+)
+
+// Decimate keeps one packet in N, the first of every N, so that enormous
+// captures can be thinned while still covering the whole time span.
+// Timestamps are left alone. With PerFlow the packets are counted per
+// conversation, so that quiet flows are thinned as much as busy ones;
+// packets that are not IP are counted together.
+type Decimate struct {
+	N       int
+	PerFlow bool
+
+	count int
+	flows map[packet.Flow]int
+}
+
+// Apply drops all but every N'th packet.
+func (d *Decimate) Apply(p *Packet) bool {
+
+	if d.N <= 1 {
+		return true
+	}
+
+	if d.PerFlow {
+		if flow, ok := p.Decode().Flow(); ok {
+			if d.flows == nil {
+				d.flows = make(map[packet.Flow]int)
+			}
+			flow = flow.Canonical()
+			n := d.flows[flow]
+			d.flows[flow] = n + 1
+			return n%d.N == 0
+		}
+	}
+
+	keep := d.count%d.N == 0
+	d.count++
+	return keep
+}
@@ -0,0 +1,40 @@
+package transform
+
+import (
+	"hash/fnv"
+)
+
+// Dedupe drops packets whose data repeats one of the Window packets before
+// them, like editcap -d, e.g. the copies a SPAN port sends of traffic
+// between two monitored VLANs.
+type Dedupe struct {
+	Window  int // packets remembered, 5 if 0
+	Removed int // number of duplicates dropped so far
+
+	recent []uint64 // hashes of the last packets, oldest first
+}
+
+// Apply drops the packet if its data was seen within the window.
+func (d *Dedupe) Apply(p *Packet) bool {
+
+	h := fnv.New64a()
+	h.Write(p.Block.PacketData)
+	sum := h.Sum64()
+
+	for _, seen := range d.recent {
+		if seen == sum {
+			d.Removed++
+			return false
+		}
+	}
+
+	window := d.Window
+	if window <= 0 {
+		window = 5
+	}
+	d.recent = append(d.recent, sum)
+	if len(d.recent) > window {
+		d.recent = d.recent[len(d.recent)-window:]
+	}
+	return true
+}
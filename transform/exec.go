@@ -0,0 +1,141 @@
+package transform
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+)
+
+// execDrop is the reply length of a packet the subprocess drops.
+const execDrop = 0xffffffff
+
+// Exec passes every packet through a subprocess, so transforms can be
+// written in any language and shipped apart from this repository. For each
+// packet the subprocess reads from its standard input, all numbers big
+// endian,
+//
+//	uint32 length of the rest of the request
+//	uint32 packet number
+//	uint16 link type
+//	int64  timestamp in nano seconds since 1970
+//	uint32 original length
+//	the packet data
+//
+// and writes to its standard output either uint32 0xffffffff to drop the
+// packet, or a uint32 length followed by that many bytes to replace the
+// packet data. The timestamp and the other blocks are not changed. The
+// standard error of the subprocess is passed through.
+type Exec struct {
+	Command []string // program and arguments
+
+	Err error // the first error talking to the subprocess, the packets are then kept unchanged
+
+	cmd *exec.Cmd
+	in  io.WriteCloser
+	w   *bufio.Writer
+	r   *bufio.Reader
+}
+
+// start starts the subprocess.
+func (e *Exec) start() error {
+
+	cmd := exec.Command(e.Command[0], e.Command[1:]...)
+	cmd.Stderr = os.Stderr
+
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	e.cmd = cmd
+	e.in = in
+	e.w = bufio.NewWriter(in)
+	e.r = bufio.NewReader(out)
+	return nil
+}
+
+// Apply sends the packet to the subprocess and applies its reply.
+func (e *Exec) Apply(p *Packet) bool {
+
+	if e.Err != nil {
+		return true
+	}
+	if e.cmd == nil {
+		if err := e.start(); err != nil {
+			e.Err = err
+			return true
+		}
+	}
+
+	data, drop, err := e.roundTrip(p)
+	if err != nil {
+		e.Err = &TransformError{fmt.Sprintf("%v: packet %v: %v", e.Command[0], p.Number, err)}
+		return true
+	}
+	if drop {
+		return false
+	}
+	p.Block.PacketData = data
+	p.Block.CapturedPacketLength = uint32(len(data))
+	if p.Block.OriginalPacketLength < p.Block.CapturedPacketLength {
+		p.Block.OriginalPacketLength = p.Block.CapturedPacketLength
+	}
+	return true
+}
+
+// roundTrip writes one request and reads its reply.
+func (e *Exec) roundTrip(p *Packet) (data []byte, drop bool, err error) {
+
+	header := make([]byte, 22)
+	binary.BigEndian.PutUint32(header[0:4], uint32(18+len(p.Block.PacketData)))
+	binary.BigEndian.PutUint32(header[4:8], uint32(p.Number))
+	binary.BigEndian.PutUint16(header[8:10], p.LinkType())
+	binary.BigEndian.PutUint64(header[10:18], uint64(packetTime(p.Interface, p.Block).UnixNano()))
+	binary.BigEndian.PutUint32(header[18:22], p.Block.OriginalPacketLength)
+
+	if _, err := e.w.Write(header); err != nil {
+		return nil, false, err
+	}
+	if _, err := e.w.Write(p.Block.PacketData); err != nil {
+		return nil, false, err
+	}
+	if err := e.w.Flush(); err != nil {
+		return nil, false, err
+	}
+
+	var reply [4]byte
+	if _, err := io.ReadFull(e.r, reply[:]); err != nil {
+		return nil, false, err
+	}
+	length := binary.BigEndian.Uint32(reply[:])
+	if length == execDrop {
+		return nil, true, nil
+	}
+	data = make([]byte, length)
+	if _, err := io.ReadFull(e.r, data); err != nil {
+		return nil, false, err
+	}
+	return data, false, nil
+}
+
+// Close ends the input of the subprocess and waits for it to exit.
+func (e *Exec) Close() error {
+	if e.cmd == nil {
+		return e.Err
+	}
+	e.in.Close()
+	if err := e.cmd.Wait(); err != nil && e.Err == nil {
+		e.Err = err
+	}
+	e.cmd = nil
+	return e.Err
+}
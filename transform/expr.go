@@ -0,0 +1,754 @@
+package transform
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/RajeshGottlieb/go/packet"
+)
+
+// Expr is a filter expression over the decoded fields of a packet, e.g.
+//
+//	ip.src == 10.0.0.0/8 && tcp.dstport == 443
+//	(udp.port == 53 || tcp.port == 53) and not vlan
+//
+// Fields are named like Wireshark's, see ExprFields. A field alone tests
+// that the packet has it, e.g. tcp or vlan. Comparisons take numbers,
+// IPv4 and IPv6 addresses, CIDR prefixes, which == matches the addresses
+// within, and MAC addresses. A comparison with a field the packet lacks is
+// false, and one with a field of several values, ip.addr, tcp.port,
+// udp.port, ipv6.nxt and ipv6.exthdr, is true if any value matches. &&
+// || and ! may be written and, or and not.
+//
+// ip.proto and the tcp, udp and icmp fields look past IPv6 extension
+// headers. ipv6.nxt is every Next Header of the chain, so ipv6.nxt == 6
+// matches TCP behind a Hop-by-Hop Options header, ipv6.exthdr only the
+// extension headers, e.g. ipv6.exthdr == 44 for fragments.
+type Expr struct {
+	text string
+	root exprNode
+}
+
+// ExprFields lists the field names an Expr knows, with those a Set can
+// assign marked by a trailing "=".
+func ExprFields() []string {
+	var names []string
+	for _, f := range exprFieldList {
+		name := f.name
+		if f.set != nil {
+			name += "="
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// ParseExpr compiles an expression.
+func ParseExpr(s string) (*Expr, error) {
+	p := &exprParser{tokens: exprTokens(s)}
+	root, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, &TransformError{fmt.Sprintf("unexpected %q in %q", p.tokens[p.pos], s)}
+	}
+	return &Expr{s, root}, nil
+}
+
+// Match tells if the packet satisfies the expression.
+func (e *Expr) Match(p *Packet) bool {
+	return e.root.eval(&exprPacket{p, p.Decode()})
+}
+
+func (e *Expr) String() string {
+	return e.text
+}
+
+// ExprFilter returns a Filter keeping the packets matching the expression.
+func ExprFilter(s string) (*Filter, error) {
+	e, err := ParseExpr(s)
+	if err != nil {
+		return nil, err
+	}
+	return &Filter{Match: e.Match}, nil
+}
+
+// Set assigns fields of the packets matching When, e.g. from
+//
+//	ip.ttl = 64, tcp.dstport = 8443 if tcp.dstport == 443
+//
+// and then fixes up the checksums. The fields that can be assigned are the
+// addresses, ports, VLAN ID, TTL and TOS.
+type Set struct {
+	When        *Expr // nil selects every packet
+	Assignments []Assignment
+	Changed     int // number of packets changed so far
+}
+
+// Assignment is one field = value of a Set.
+type Assignment struct {
+	Field string
+	value exprValue
+	set   func(d *packet.Packet, v exprValue) bool
+}
+
+// ParseSet parses a comma separated list of assignments, optionally
+// followed by if and the expression selecting the packets. A value must
+// be of the kind the field holds and fit in it, e.g. ip.ttl takes a number
+// up to 255 and ip.src an address.
+func ParseSet(s string) (*Set, error) {
+
+	set := &Set{}
+	assignments := s
+	if i := strings.Index(s, " if "); i >= 0 {
+		when, err := ParseExpr(s[i+4:])
+		if err != nil {
+			return nil, err
+		}
+		set.When, assignments = when, s[:i]
+	}
+
+	for _, a := range strings.Split(assignments, ",") {
+		parts := strings.SplitN(a, "=", 2)
+		if len(parts) != 2 {
+			return nil, &TransformError{fmt.Sprintf("assignment %q is not field = value", strings.TrimSpace(a))}
+		}
+		name, literal := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		f, ok := exprFieldMap[name]
+		if !ok || f.set == nil {
+			return nil, &TransformError{fmt.Sprintf("field %q cannot be assigned", name)}
+		}
+		v, err := parseExprValue(literal)
+		if err != nil {
+			return nil, err
+		}
+		if v.kind() != f.set.kind {
+			return nil, &TransformError{fmt.Sprintf("%v takes %v, not %q", name, f.set.kind, literal)}
+		}
+		if f.set.kind == exprNumber && v.n > f.set.max {
+			return nil, &TransformError{fmt.Sprintf("%v is at most %v, not %v", name, f.set.max, v.n)}
+		}
+		set.Assignments = append(set.Assignments, Assignment{name, v, f.set.set})
+	}
+	return set, nil
+}
+
+// Apply assigns the fields of a matching packet. It never drops packets.
+func (s *Set) Apply(p *Packet) bool {
+
+	if s.When != nil && !s.When.Match(p) {
+		return true
+	}
+
+	d := p.Decode()
+	changed := false
+	for _, a := range s.Assignments {
+		if a.set(d, a.value) {
+			changed = true
+		}
+	}
+	if changed {
+		if d.IPVersion != 0 {
+			d.FixChecksums()
+		}
+		s.Changed++
+	}
+	return true
+}
+
+// exprValue is a literal or the value of a field.
+type exprValue struct {
+	n      uint64
+	ip     net.IP
+	prefix *net.IPNet
+	mac    net.HardwareAddr
+}
+
+// exprKind is the kind of an exprValue.
+type exprKind int
+
+const (
+	exprNumber exprKind = iota
+	exprIP
+	exprPrefix
+	exprMAC
+)
+
+func (k exprKind) String() string {
+	switch k {
+	case exprIP:
+		return "an address"
+	case exprPrefix:
+		return "a prefix"
+	case exprMAC:
+		return "a MAC address"
+	}
+	return "a number"
+}
+
+func (v exprValue) kind() exprKind {
+	switch {
+	case v.ip != nil:
+		return exprIP
+	case v.prefix != nil:
+		return exprPrefix
+	case v.mac != nil:
+		return exprMAC
+	}
+	return exprNumber
+}
+
+func parseExprValue(s string) (exprValue, error) {
+	if n, err := strconv.ParseUint(s, 0, 64); err == nil {
+		return exprValue{n: n}, nil
+	}
+	if _, prefix, err := net.ParseCIDR(s); err == nil {
+		return exprValue{prefix: prefix}, nil
+	}
+	if ip := net.ParseIP(s); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+		}
+		return exprValue{ip: ip}, nil
+	}
+	if mac, err := net.ParseMAC(s); err == nil {
+		return exprValue{mac: mac}, nil
+	}
+	return exprValue{}, &TransformError{fmt.Sprintf("%q is not a field, number, address or prefix", s)}
+}
+
+// compare compares a field value with a literal, returning -2 for values
+// of different kinds.
+func (v exprValue) compare(lit exprValue) int {
+	switch {
+	case lit.prefix != nil && v.ip != nil:
+		if lit.prefix.Contains(v.ip) {
+			return 0
+		}
+		return 1
+	case lit.ip != nil && v.ip != nil:
+		a, b := v.ip, lit.ip
+		if a4, b4 := a.To4(), b.To4(); a4 != nil && b4 != nil {
+			a, b = a4, b4
+		}
+		return bytes.Compare(a, b)
+	case lit.mac != nil && v.mac != nil:
+		return bytes.Compare(v.mac, lit.mac)
+	case lit.ip == nil && lit.prefix == nil && lit.mac == nil && v.ip == nil && v.mac == nil:
+		switch {
+		case v.n < lit.n:
+			return -1
+		case v.n > lit.n:
+			return 1
+		}
+		return 0
+	}
+	return -2
+}
+
+// exprPacket is a packet being evaluated.
+type exprPacket struct {
+	p *Packet
+	d *packet.Packet
+}
+
+type exprField struct {
+	name string
+	get  func(e *exprPacket) []exprValue
+	set  *exprSetter // nil if the field cannot be assigned
+}
+
+// exprSetter assigns a field the values of one kind, ParseSet checking
+// the kind and, for numbers, that they are at most max.
+type exprSetter struct {
+	kind exprKind
+	max  uint64
+	set  func(d *packet.Packet, v exprValue) bool
+}
+
+func num(n uint64) []exprValue {
+	return []exprValue{{n: n}}
+}
+
+func has(ok bool) []exprValue {
+	if ok {
+		return num(1)
+	}
+	return nil
+}
+
+func ips(list ...net.IP) []exprValue {
+	var values []exprValue
+	for _, ip := range list {
+		if ip != nil {
+			values = append(values, exprValue{ip: ip})
+		}
+	}
+	return values
+}
+
+// ipv6s returns the addresses of an IPv6 packet, none for other packets.
+func ipv6s(d *packet.Packet, list ...net.IP) []exprValue {
+	if d.IPVersion != 6 {
+		return nil
+	}
+	return ips(list...)
+}
+
+// transportHas tells if the packet has a decoded transport header of the protocol.
+func transportHas(d *packet.Packet, protocol uint8) bool {
+	return d.IPVersion != 0 && d.Protocol == protocol && d.TransportOffset >= 0
+}
+
+func ports(d *packet.Packet, protocol uint8, src, dst bool) []exprValue {
+	if !transportHas(d, protocol) {
+		return nil
+	}
+	var values []exprValue
+	if src {
+		values = append(values, exprValue{n: uint64(d.SrcPort)})
+	}
+	if dst {
+		values = append(values, exprValue{n: uint64(d.DstPort)})
+	}
+	return values
+}
+
+// setPort sets the source (offset 0) or destination (offset 2) port of a
+// TCP or UDP header.
+func setPort(protocol uint8, offset int) *exprSetter {
+	return &exprSetter{exprNumber, 0xffff, func(d *packet.Packet, v exprValue) bool {
+		if !transportHas(d, protocol) {
+			return false
+		}
+		binary.BigEndian.PutUint16(d.Data[d.TransportOffset+offset:], uint16(v.n))
+		return true
+	}}
+}
+
+// setIP overwrites an address of the same family in place.
+func setIP(src bool) *exprSetter {
+	return &exprSetter{exprIP, 0, func(d *packet.Packet, v exprValue) bool {
+		ip := d.DstIP
+		if src {
+			ip = d.SrcIP
+		}
+		to := v.ip
+		if to == nil || ip == nil {
+			return false
+		}
+		if len(ip) == 16 {
+			to = to.To16()
+		}
+		if len(to) != len(ip) {
+			return false
+		}
+		copy(ip, to)
+		return true
+	}}
+}
+
+// setMAC overwrites a MAC address in place.
+func setMAC(src bool) *exprSetter {
+	return &exprSetter{exprMAC, 0, func(d *packet.Packet, v exprValue) bool {
+		mac := d.DstMAC
+		if src {
+			mac = d.SrcMAC
+		}
+		if mac == nil || len(v.mac) != len(mac) {
+			return false
+		}
+		copy(mac, v.mac)
+		return true
+	}}
+}
+
+var exprFieldList = []exprField{
+	{"frame.number", func(e *exprPacket) []exprValue { return num(uint64(e.p.Number)) }, nil},
+	{"frame.len", func(e *exprPacket) []exprValue { return num(uint64(e.p.Block.OriginalPacketLength)) }, nil},
+	{"frame.cap_len", func(e *exprPacket) []exprValue { return num(uint64(len(e.p.Block.PacketData))) }, nil},
+	{"frame.interface", func(e *exprPacket) []exprValue { return num(uint64(e.p.Block.InterfaceID)) }, nil},
+	{"eth", func(e *exprPacket) []exprValue { return has(e.d.SrcMAC != nil) }, nil},
+	{"eth.src", func(e *exprPacket) []exprValue {
+		if e.d.SrcMAC == nil {
+			return nil
+		}
+		return []exprValue{{mac: e.d.SrcMAC}}
+	}, setMAC(true)},
+	{"eth.dst", func(e *exprPacket) []exprValue {
+		if e.d.DstMAC == nil {
+			return nil
+		}
+		return []exprValue{{mac: e.d.DstMAC}}
+	}, setMAC(false)},
+	{"eth.type", func(e *exprPacket) []exprValue {
+		if e.d.EtherType == 0 {
+			return nil
+		}
+		return num(uint64(e.d.EtherType))
+	}, nil},
+	{"vlan", func(e *exprPacket) []exprValue { return has(len(e.d.VLANs) > 0) }, nil},
+	{"vlan.id", func(e *exprPacket) []exprValue {
+		if len(e.d.VLANs) == 0 {
+			return nil
+		}
+		return num(uint64(e.d.VLANs[0]))
+	}, &exprSetter{exprNumber, 0xfff, func(d *packet.Packet, v exprValue) bool {
+		if len(d.VLANs) == 0 {
+			return false
+		}
+		d.SetVLAN(0, uint16(v.n))
+		return true
+	}}},
+	{"ip", func(e *exprPacket) []exprValue { return has(e.d.IPVersion == 4) }, nil},
+	{"ipv6", func(e *exprPacket) []exprValue { return has(e.d.IPVersion == 6) }, nil},
+	{"ip.version", func(e *exprPacket) []exprValue {
+		if e.d.IPVersion == 0 {
+			return nil
+		}
+		return num(uint64(e.d.IPVersion))
+	}, nil},
+	{"ip.src", func(e *exprPacket) []exprValue { return ips(e.d.SrcIP) }, setIP(true)},
+	{"ip.dst", func(e *exprPacket) []exprValue { return ips(e.d.DstIP) }, setIP(false)},
+	{"ip.addr", func(e *exprPacket) []exprValue { return ips(e.d.SrcIP, e.d.DstIP) }, nil},
+	{"ip.ttl", func(e *exprPacket) []exprValue {
+		if e.d.IPVersion == 0 {
+			return nil
+		}
+		return num(uint64(e.d.TTL))
+	}, &exprSetter{exprNumber, 0xff, func(d *packet.Packet, v exprValue) bool {
+		if d.IPVersion == 0 {
+			return false
+		}
+		d.SetTTL(uint8(v.n))
+		return true
+	}}},
+	{"ip.tos", func(e *exprPacket) []exprValue {
+		if e.d.IPVersion == 0 {
+			return nil
+		}
+		return num(uint64(e.d.TOS))
+	}, &exprSetter{exprNumber, 0xff, func(d *packet.Packet, v exprValue) bool {
+		if d.IPVersion == 0 {
+			return false
+		}
+		d.SetTOS(uint8(v.n))
+		return true
+	}}},
+	{"ip.proto", func(e *exprPacket) []exprValue {
+		if e.d.IPVersion == 0 {
+			return nil
+		}
+		return num(uint64(e.d.Protocol))
+	}, nil},
+	{"ipv6.src", func(e *exprPacket) []exprValue { return ipv6s(e.d, e.d.SrcIP) }, nil},
+	{"ipv6.dst", func(e *exprPacket) []exprValue { return ipv6s(e.d, e.d.DstIP) }, nil},
+	{"ipv6.addr", func(e *exprPacket) []exprValue { return ipv6s(e.d, e.d.SrcIP, e.d.DstIP) }, nil},
+	{"ipv6.flow", func(e *exprPacket) []exprValue {
+		if e.d.IPVersion != 6 {
+			return nil
+		}
+		return num(uint64(e.d.FlowLabel))
+	}, nil},
+	{"ipv6.tclass", func(e *exprPacket) []exprValue {
+		if e.d.IPVersion != 6 {
+			return nil
+		}
+		return num(uint64(e.d.TOS))
+	}, nil},
+	{"ipv6.dscp", func(e *exprPacket) []exprValue {
+		if e.d.IPVersion != 6 {
+			return nil
+		}
+		return num(uint64(e.d.TOS >> 2))
+	}, nil},
+	{"ipv6.hlim", func(e *exprPacket) []exprValue {
+		if e.d.IPVersion != 6 {
+			return nil
+		}
+		return num(uint64(e.d.TTL))
+	}, nil},
+	{"ipv6.nxt", func(e *exprPacket) []exprValue {
+		if e.d.IPVersion != 6 {
+			return nil
+		}
+		var values []exprValue
+		for _, h := range nextHeaders(e.d) {
+			values = append(values, exprValue{n: uint64(h)})
+		}
+		return values
+	}, nil},
+	{"ipv6.exthdr", func(e *exprPacket) []exprValue {
+		var values []exprValue
+		for _, h := range e.d.ExtHeaders {
+			values = append(values, exprValue{n: uint64(h)})
+		}
+		return values
+	}, nil},
+	{"ipv6.frag", func(e *exprPacket) []exprValue {
+		for _, h := range e.d.ExtHeaders {
+			if h == packet.ProtocolFragment {
+				return num(1)
+			}
+		}
+		return nil
+	}, nil},
+	{"tcp", func(e *exprPacket) []exprValue { return has(transportHas(e.d, packet.ProtocolTCP)) }, nil},
+	{"tcp.srcport", func(e *exprPacket) []exprValue { return ports(e.d, packet.ProtocolTCP, true, false) }, setPort(packet.ProtocolTCP, 0)},
+	{"tcp.dstport", func(e *exprPacket) []exprValue { return ports(e.d, packet.ProtocolTCP, false, true) }, setPort(packet.ProtocolTCP, 2)},
+	{"tcp.port", func(e *exprPacket) []exprValue { return ports(e.d, packet.ProtocolTCP, true, true) }, nil},
+	{"tcp.flags", func(e *exprPacket) []exprValue {
+		if !transportHas(e.d, packet.ProtocolTCP) {
+			return nil
+		}
+		return num(uint64(e.d.TCPFlags))
+	}, nil},
+	{"tcp.seq", func(e *exprPacket) []exprValue {
+		if !transportHas(e.d, packet.ProtocolTCP) {
+			return nil
+		}
+		return num(uint64(e.d.TCPSeq))
+	}, nil},
+	{"tcp.ack", func(e *exprPacket) []exprValue {
+		if !transportHas(e.d, packet.ProtocolTCP) {
+			return nil
+		}
+		return num(uint64(e.d.TCPAck))
+	}, nil},
+	{"udp", func(e *exprPacket) []exprValue { return has(transportHas(e.d, packet.ProtocolUDP)) }, nil},
+	{"udp.srcport", func(e *exprPacket) []exprValue { return ports(e.d, packet.ProtocolUDP, true, false) }, setPort(packet.ProtocolUDP, 0)},
+	{"udp.dstport", func(e *exprPacket) []exprValue { return ports(e.d, packet.ProtocolUDP, false, true) }, setPort(packet.ProtocolUDP, 2)},
+	{"udp.port", func(e *exprPacket) []exprValue { return ports(e.d, packet.ProtocolUDP, true, true) }, nil},
+	{"icmp", func(e *exprPacket) []exprValue {
+		return has(transportHas(e.d, packet.ProtocolICMP) || transportHas(e.d, packet.ProtocolICMPv6))
+	}, nil},
+	{"icmp.type", func(e *exprPacket) []exprValue {
+		if !transportHas(e.d, packet.ProtocolICMP) && !transportHas(e.d, packet.ProtocolICMPv6) {
+			return nil
+		}
+		return num(uint64(e.d.ICMPType))
+	}, nil},
+	{"icmp.code", func(e *exprPacket) []exprValue {
+		if !transportHas(e.d, packet.ProtocolICMP) && !transportHas(e.d, packet.ProtocolICMPv6) {
+			return nil
+		}
+		return num(uint64(e.d.ICMPCode))
+	}, nil},
+}
+
+var exprFieldMap = make(map[string]*exprField)
+
+func init() {
+	for i := range exprFieldList {
+		exprFieldMap[exprFieldList[i].name] = &exprFieldList[i]
+	}
+}
+
+// exprNode is a node of a parsed expression.
+type exprNode interface {
+	eval(e *exprPacket) bool
+}
+
+type exprOr struct{ a, b exprNode }
+type exprAnd struct{ a, b exprNode }
+type exprNot struct{ a exprNode }
+type exprHas struct{ field *exprField }
+type exprCompare struct {
+	field *exprField
+	op    string
+	lit   exprValue
+}
+
+func (n *exprOr) eval(e *exprPacket) bool  { return n.a.eval(e) || n.b.eval(e) }
+func (n *exprAnd) eval(e *exprPacket) bool { return n.a.eval(e) && n.b.eval(e) }
+func (n *exprNot) eval(e *exprPacket) bool { return !n.a.eval(e) }
+
+func (n *exprHas) eval(e *exprPacket) bool {
+	return len(n.field.get(e)) > 0
+}
+
+func (n *exprCompare) eval(e *exprPacket) bool {
+	values := n.field.get(e)
+	if n.op == "!=" {
+		// true if no value equals, like !(field == literal)
+		for _, v := range values {
+			if v.compare(n.lit) == 0 {
+				return false
+			}
+		}
+		return len(values) > 0
+	}
+	for _, v := range values {
+		c := v.compare(n.lit)
+		if c == -2 {
+			continue
+		}
+		switch n.op {
+		case "==":
+			if c == 0 {
+				return true
+			}
+		case "<":
+			if c < 0 {
+				return true
+			}
+		case "<=":
+			if c <= 0 {
+				return true
+			}
+		case ">":
+			if c > 0 {
+				return true
+			}
+		case ">=":
+			if c >= 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// exprTokens splits an expression into operators, parentheses and words.
+func exprTokens(s string) []string {
+	var tokens []string
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, s[i:i+1])
+			i++
+		case strings.HasPrefix(s[i:], "&&") || strings.HasPrefix(s[i:], "||") ||
+			strings.HasPrefix(s[i:], "==") || strings.HasPrefix(s[i:], "!=") ||
+			strings.HasPrefix(s[i:], "<=") || strings.HasPrefix(s[i:], ">="):
+			tokens = append(tokens, s[i:i+2])
+			i += 2
+		case c == '!' || c == '<' || c == '>':
+			tokens = append(tokens, s[i:i+1])
+			i++
+		default:
+			j := i
+			for j < len(s) && !strings.ContainsRune(" \t\n()!<>=&|", rune(s[j])) {
+				j++
+			}
+			if j == i {
+				// a lone = & or |
+				j++
+			}
+			tokens = append(tokens, s[i:j])
+			i = j
+		}
+	}
+	return tokens
+}
+
+type exprParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *exprParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *exprParser) or() (exprNode, error) {
+	a, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "||" || p.peek() == "or" {
+		p.pos++
+		b, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		a = &exprOr{a, b}
+	}
+	return a, nil
+}
+
+func (p *exprParser) and() (exprNode, error) {
+	a, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "&&" || p.peek() == "and" {
+		p.pos++
+		b, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		a = &exprAnd{a, b}
+	}
+	return a, nil
+}
+
+func (p *exprParser) not() (exprNode, error) {
+	if p.peek() == "!" || p.peek() == "not" {
+		p.pos++
+		a, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return &exprNot{a}, nil
+	}
+	return p.primary()
+}
+
+func (p *exprParser) primary() (exprNode, error) {
+
+	token := p.peek()
+	switch token {
+	case "":
+		return nil, &TransformError{"expression ends too early"}
+	case "(":
+		p.pos++
+		a, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, &TransformError{"missing )"}
+		}
+		p.pos++
+		return a, nil
+	}
+
+	field, ok := exprFieldMap[token]
+	if !ok {
+		return nil, &TransformError{fmt.Sprintf("unknown field %q", token)}
+	}
+	p.pos++
+
+	op := p.peek()
+	switch op {
+	case "==", "!=", "<", "<=", ">", ">=":
+	default:
+		return &exprHas{field}, nil
+	}
+	p.pos++
+
+	literal := p.peek()
+	if literal == "" {
+		return nil, &TransformError{fmt.Sprintf("%v %v needs a value", token, op)}
+	}
+	p.pos++
+	lit, err := parseExprValue(literal)
+	if err != nil {
+		return nil, err
+	}
+	if lit.prefix != nil && op != "==" && op != "!=" {
+		return nil, &TransformError{fmt.Sprintf("prefix %v can only be compared with == or !=", literal)}
+	}
+	return &exprCompare{field, op, lit}, nil
+}
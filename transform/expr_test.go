@@ -0,0 +1,185 @@
+package transform
+
+import (
+	"testing"
+
+	"github.com/RajeshGottlieb/go/pcapng"
+)
+
+// tcpSYN is a raw IPv4 TCP SYN from 10.1.2.3:40000 to 192.168.0.9:443.
+var tcpSYN = []byte{
+	0x45, 0, 0, 40, 0, 0, 0, 0, 64, 6, 0, 0, 10, 1, 2, 3, 192, 168, 0, 9,
+	0x9c, 0x40, 0x01, 0xbb, 0, 0, 0, 1, 0, 0, 0, 0, 0x50, 0x02, 0xff, 0xff, 0, 0, 0, 0,
+}
+
+// dnsQuery is an Ethernet IPv4 UDP packet from 00:11:22:33:44:55
+// 10.0.0.1:5353 to 10.0.0.53:53.
+var dnsQuery = []byte{
+	0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x08, 0x00,
+	0x45, 0, 0, 28, 0, 0, 0, 0, 1, 17, 0, 0, 10, 0, 0, 1, 10, 0, 0, 53,
+	0x14, 0xe9, 0x00, 0x35, 0, 8, 0, 0,
+}
+
+func rawPacket(linkType uint16, data []byte) *Packet {
+	b := &pcapng.EnhancedPacketBlock{PacketData: data, CapturedPacketLength: uint32(len(data)), OriginalPacketLength: uint32(len(data))}
+	return &Packet{Number: 1, Block: b, Interface: &pcapng.InterfaceBlock{LinkType: linkType}}
+}
+
+func TestExprMatch(t *testing.T) {
+
+	syn := rawPacket(101, tcpSYN)
+	dns := rawPacket(1, dnsQuery)
+
+	tests := []struct {
+		expr string
+		p    *Packet
+		want bool
+	}{
+		{"ip.src == 10.0.0.0/8 && tcp.dstport == 443", syn, true},
+		{"ip.src == 10.0.0.0/8 && tcp.dstport == 80", syn, false},
+		{"(udp.port == 53 or tcp.port == 443) and not vlan", syn, true},
+		{"(udp.port == 53 or tcp.port == 443) and not vlan", dns, true},
+		{"ip.addr == 192.168.0.9", syn, true},
+		{"ip.dst != 192.168.0.9", syn, false},
+		{"ip.ttl >= 64 && tcp.flags == 0x02", syn, true},
+		{"ip.ttl >= 64", dns, false},
+		{"udp", syn, false},
+		{"!udp", syn, true},
+		{"udp", dns, true},
+		{"frame.len < 41", syn, true},
+		{"eth.src == 00:11:22:33:44:55", syn, false},
+		{"eth.src == 00:11:22:33:44:55", dns, true},
+		{"udp.srcport == 5353 && udp.dstport == 53", dns, true},
+		{"not (tcp or udp)", dns, false},
+	}
+
+	for _, tt := range tests {
+		e, err := ParseExpr(tt.expr)
+		if err != nil {
+			t.Errorf("%q: %v", tt.expr, err)
+			continue
+		}
+		if got := e.Match(tt.p); got != tt.want {
+			t.Errorf("%q: Match = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestExprTruncated(t *testing.T) {
+
+	// every field on every prefix of the packets, none may panic
+	var exprs []*Expr
+	for _, field := range []string{"tcp.port == 443", "tcp.flags == 2", "tcp.seq == 1", "udp.port == 53",
+		"icmp.type == 8", "ip.ttl == 64", "ip.src == 10.0.0.0/8", "eth.src == 00:11:22:33:44:55", "frame.len > 0"} {
+		e, err := ParseExpr(field)
+		if err != nil {
+			t.Fatalf("%q: %v", field, err)
+		}
+		exprs = append(exprs, e)
+	}
+	for _, full := range []*Packet{rawPacket(101, tcpSYN), rawPacket(1, dnsQuery)} {
+		data := full.Block.PacketData
+		for n := 0; n < len(data); n++ {
+			p := rawPacket(full.LinkType(), data[:n])
+			for _, e := range exprs {
+				e.Match(p)
+			}
+		}
+	}
+	// a header length beyond the data
+	long := append([]byte(nil), tcpSYN...)
+	long[0] = 0x4f
+	for _, e := range exprs {
+		e.Match(rawPacket(101, long))
+	}
+}
+
+func TestParseExprErrors(t *testing.T) {
+
+	tests := []string{
+		"",
+		"ip.src ==",
+		"bogus == 1",
+		"(tcp",
+		"tcp)",
+		"tcp.port < 10.0.0.0/8",
+		"tcp.port == 443 &&",
+		"not",
+	}
+
+	for _, s := range tests {
+		if e, err := ParseExpr(s); err == nil {
+			t.Errorf("%q: ParseExpr = %v, want an error", s, e)
+		}
+	}
+}
+
+func TestSetApply(t *testing.T) {
+
+	tests := []struct {
+		set     string
+		p       *Packet
+		changed bool
+	}{
+		{"ip.ttl = 3, ip.dst = 172.16.0.1, tcp.dstport = 8443 if tcp.dstport == 443", rawPacket(101, tcpSYN), true},
+		{"tcp.dstport = 8443 if tcp.dstport == 80", rawPacket(101, tcpSYN), false},
+		{"udp.dstport = 5300", rawPacket(1, dnsQuery), true},
+	}
+
+	for _, tt := range tests {
+		s, err := ParseSet(tt.set)
+		if err != nil {
+			t.Errorf("%q: %v", tt.set, err)
+			continue
+		}
+		// leave the shared packet data as it is
+		tt.p.Block.PacketData = append([]byte(nil), tt.p.Block.PacketData...)
+		if !s.Apply(tt.p) {
+			t.Errorf("%q: Apply dropped the packet", tt.set)
+		}
+		if (s.Changed == 1) != tt.changed {
+			t.Errorf("%q: Changed = %v, want changing %v", tt.set, s.Changed, tt.changed)
+		}
+	}
+
+	if _, err := ParseSet("ip.addr = 1.2.3.4"); err == nil {
+		t.Error("ip.addr was assigned")
+	}
+}
+
+func TestParseSetValues(t *testing.T) {
+
+	tests := []struct {
+		set string
+		ok  bool
+	}{
+		{"ip.ttl = 255", true},
+		{"ip.tos = 0xb8", true},
+		{"vlan.id = 4095", true},
+		{"tcp.srcport = 65535", true},
+		{"ip.src = 2001:db8::1", true},
+		{"eth.dst = 00:11:22:33:44:55", true},
+		{"ip.ttl = 10.0.0.1", false},
+		{"ip.ttl = 300", false},
+		{"ip.tos = 256", false},
+		{"vlan.id = 4096", false},
+		{"udp.dstport = 10.0.0.0/8", false},
+		{"tcp.srcport = 65536", false},
+		{"ip.src = 5", false},
+		{"ip.dst = 10.0.0.0/8", false},
+		{"eth.src = 10.0.0.1", false},
+		{"ip.ttl = 64, udp.dstport = 70000", false},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseSet(tt.set)
+		if tt.ok && err != nil {
+			t.Errorf("%q: %v", tt.set, err)
+		}
+		if !tt.ok {
+			if _, ok := err.(*TransformError); !ok {
+				t.Errorf("%q: ParseSet returned %v, want a TransformError", tt.set, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package transform
+
+import (
+	"github.com/RajeshGottlieb/go/packet"
+)
+
+// FieldEdit sets IP header fields of the packets selected by Match so that
+// captures can be normalized before comparing them. Fields set to -1 are
+// left unchanged.
+type FieldEdit struct {
+	Match        func(d *packet.Packet) bool // nil selects every IP packet
+	TTL          int                         // IPv4 TTL
+	DSCP         int                         // IPv4 DSCP, the ECN bits are kept
+	HopLimit     int                         // IPv6 hop limit
+	TrafficClass int                         // IPv6 traffic class
+}
+
+// NewFieldEdit returns a FieldEdit that changes nothing.
+func NewFieldEdit() *FieldEdit {
+	return &FieldEdit{TTL: -1, DSCP: -1, HopLimit: -1, TrafficClass: -1}
+}
+
+// Apply edits the packet in place. It never drops packets.
+func (fe *FieldEdit) Apply(p *Packet) bool {
+
+	d := p.Decode()
+	if d.IPVersion == 0 || (fe.Match != nil && !fe.Match(d)) {
+		return true
+	}
+
+	if d.IPVersion == 4 {
+		if fe.TTL >= 0 {
+			d.SetTTL(uint8(fe.TTL))
+		}
+		if fe.DSCP >= 0 {
+			d.SetTOS(uint8(fe.DSCP)<<2 | d.TOS&3)
+		}
+		d.FixChecksums()
+	} else {
+		if fe.HopLimit >= 0 {
+			d.SetTTL(uint8(fe.HopLimit))
+		}
+		if fe.TrafficClass >= 0 {
+			d.SetTOS(uint8(fe.TrafficClass))
+		}
+	}
+	return true
+}
@@ -0,0 +1,27 @@
+package transform
+
+import (
+	"github.com/RajeshGottlieb/go/packet"
+)
+
+// Filter keeps the packets Match accepts, or those it rejects if Invert
+// is set.
+type Filter struct {
+	Match  func(p *Packet) bool
+	Invert bool
+}
+
+// Apply drops the packet unless Match and Invert agree to keep it.
+func (f *Filter) Apply(p *Packet) bool {
+	return f.Match(p) != f.Invert
+}
+
+// FlowFilter returns a Filter keeping both directions of the conversation
+// of flow.
+func FlowFilter(flow packet.Flow) *Filter {
+	want := flow.Canonical()
+	return &Filter{Match: func(p *Packet) bool {
+		f, ok := p.Decode().Flow()
+		return ok && f.Canonical() == want
+	}}
+}
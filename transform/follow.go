@@ -0,0 +1,95 @@
+package transform
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/RajeshGottlieb/go/packet"
+	"github.com/RajeshGottlieb/go/pcapng"
+)
+
+// Follow keeps only the packets of one conversation, in both directions.
+// If Client or Server are set the payload sent in that direction is written
+// to them, reassembled for TCP and datagram by datagram otherwise.
+// Client receives the payload of packets matching Flow, Server the reverse.
+type Follow struct {
+	Flow   packet.Flow
+	Client io.Writer
+	Server io.Writer
+
+	Err error // the first write error, the packets are still filtered
+
+	client packet.Reassembler
+	server packet.Reassembler
+}
+
+// Apply drops packets of other conversations.
+func (f *Follow) Apply(p *Packet) bool {
+
+	d := p.Decode()
+	flow, ok := d.Flow()
+	if !ok {
+		return false
+	}
+
+	var w io.Writer
+	var r *packet.Reassembler
+	switch flow {
+	case f.Flow:
+		w, r = f.Client, &f.client
+	case f.Flow.Reverse():
+		w, r = f.Server, &f.server
+	default:
+		return false
+	}
+
+	if w == nil || f.Err != nil {
+		return true
+	}
+	payload := d.Payload()
+	if d.Protocol == packet.ProtocolTCP {
+		payload = r.Add(d.TCPSeq, d.TCPFlags&packet.TCPFlagSYN != 0, payload)
+	}
+	if len(payload) > 0 {
+		_, f.Err = w.Write(payload)
+	}
+	return true
+}
+
+// FindFlow returns the flow of packet number (1 based) in pr, in the
+// direction that packet was sent.
+func FindFlow(pr *pcapng.PcapngReader, number int) (packet.Flow, error) {
+
+	var interfaces []*pcapng.InterfaceBlock
+	n := 0
+
+	for {
+		block, err := pr.Read()
+		if err == io.EOF {
+			return packet.Flow{}, &TransformError{fmt.Sprintf("there are only %v packets", n)}
+		} else if err != nil {
+			return packet.Flow{}, err
+		}
+
+		switch b := block.(type) {
+		case *pcapng.SectionBlock:
+			interfaces = nil
+		case *pcapng.InterfaceBlock:
+			interfaces = append(interfaces, b)
+		case *pcapng.EnhancedPacketBlock:
+			n++
+			if n != number {
+				continue
+			}
+			p := Packet{Number: n, Block: b}
+			if int(b.InterfaceID) < len(interfaces) {
+				p.Interface = interfaces[b.InterfaceID]
+			}
+			flow, ok := p.Decode().Flow()
+			if !ok {
+				return flow, &TransformError{fmt.Sprintf("packet %v is not an IP packet", number)}
+			}
+			return flow, nil
+		}
+	}
+}
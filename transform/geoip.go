@@ -0,0 +1,121 @@
+package transform
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/RajeshGottlieb/go/geoip"
+	"github.com/RajeshGottlieb/go/pcapng"
+)
+
+// GeoTag is the vendor tag of a GeoAnnotation in the Custom Options of a
+// GeoIP transform.
+const GeoTag = 0x4749
+
+// GeoAnnotation is what GeoIP found about the addresses of a packet.
+type GeoAnnotation struct {
+	Src geoip.Info `json:"src"`
+	Dst geoip.Info `json:"dst"`
+}
+
+func (a *GeoAnnotation) VendorTag() uint16 {
+	return GeoTag
+}
+
+// MarshalBinary encodes the annotation as JSON.
+func (a *GeoAnnotation) MarshalBinary() ([]byte, error) {
+	return json.Marshal(a)
+}
+
+func (a *GeoAnnotation) String() string {
+	var parts []string
+	if !a.Src.Empty() {
+		parts = append(parts, "src "+a.Src.String())
+	}
+	if !a.Dst.Empty() {
+		parts = append(parts, "dst "+a.Dst.String())
+	}
+	return "geoip " + strings.Join(parts, ", ")
+}
+
+// DecodeGeoAnnotation is the VendorDecoder of GeoTag, e.g.
+// v.Register(transform.GeoTag, transform.DecodeGeoAnnotation).
+func DecodeGeoAnnotation(data []byte) (pcapng.VendorValue, error) {
+	a := &GeoAnnotation{}
+	if err := json.Unmarshal(data, a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// GeoIP looks up the source and destination address of each IP packet in
+// DB and adds what it finds as an opt_comment, e.g.
+// "geoip src US AS15169 Google LLC, dst DE", or, if Vendor is set, as a
+// Custom Option holding a GeoAnnotation. Packets with neither address in
+// DB are left alone.
+type GeoIP struct {
+	DB     geoip.Set
+	Vendor *pcapng.Vendor
+	Found  int   // packets annotated
+	Err    error // the first error, after which nothing more is looked up
+}
+
+// Apply annotates the packet. It never drops packets.
+func (g *GeoIP) Apply(p *Packet) bool {
+
+	d := p.Decode()
+	if d.IPVersion == 0 || g.Err != nil {
+		return true
+	}
+
+	var a GeoAnnotation
+	if a.Src, g.Err = g.DB.Info(d.SrcIP); g.Err != nil {
+		return true
+	}
+	if a.Dst, g.Err = g.DB.Info(d.DstIP); g.Err != nil {
+		return true
+	}
+	if a.Src.Empty() && a.Dst.Empty() {
+		return true
+	}
+
+	if g.Vendor != nil {
+		if g.Err = g.Vendor.Attach(&p.Block.Options, &a); g.Err != nil {
+			return true
+		}
+	} else {
+		p.Block.WithComment(a.String())
+	}
+	g.Found++
+	return true
+}
+
+// newGeoIP creates a GeoIP from "[pen=N,]file[,file...]", the files being
+// opened as a geoip.Set.
+func newGeoIP(args string) (Transform, error) {
+
+	g := &GeoIP{}
+	var files []string
+	for _, arg := range strings.Split(args, ",") {
+		if strings.HasPrefix(arg, "pen=") {
+			pen, err := strconv.ParseUint(arg[len("pen="):], 10, 32)
+			if err != nil {
+				return nil, &TransformError{fmt.Sprintf("geoip takes a Private Enterprise Number, not %q", arg)}
+			}
+			g.Vendor = pcapng.NewVendor(uint32(pen))
+		} else if arg != "" {
+			files = append(files, arg)
+		}
+	}
+	if len(files) == 0 {
+		return nil, &TransformError{"geoip needs a MaxMind DB file, e.g. geoip:GeoLite2-Country.mmdb"}
+	}
+
+	var err error
+	if g.DB, err = geoip.OpenSet(files...); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
@@ -0,0 +1,61 @@
+package transform
+
+import (
+	"net"
+
+	"github.com/RajeshGottlieb/go/packet"
+)
+
+// PrefixFilter returns a Filter keeping the packets with a source or
+// destination address within prefix, which may be IPv4 or IPv6.
+func PrefixFilter(prefix *net.IPNet) *Filter {
+	return &Filter{Match: func(p *Packet) bool {
+		d := p.Decode()
+		return d.IPVersion != 0 && (prefix.Contains(d.SrcIP) || prefix.Contains(d.DstIP))
+	}}
+}
+
+// FlowLabelFilter returns a Filter keeping the IPv6 packets with the flow
+// label.
+func FlowLabelFilter(label uint32) *Filter {
+	return &Filter{Match: func(p *Packet) bool {
+		d := p.Decode()
+		return d.IPVersion == 6 && d.FlowLabel == label
+	}}
+}
+
+// TrafficClassFilter returns a Filter keeping the IPv6 packets whose
+// traffic class has the bits of class within mask, e.g. class 46<<2 and
+// mask 0xfc for the DSCP EF.
+func TrafficClassFilter(class uint8, mask uint8) *Filter {
+	return &Filter{Match: func(p *Packet) bool {
+		d := p.Decode()
+		return d.IPVersion == 6 && d.TOS&mask == class&mask
+	}}
+}
+
+// NextHeaderFilter returns a Filter keeping the IP packets with a header
+// of the protocol anywhere in their chain: an IPv6 extension header, e.g.
+// packet.ProtocolFragment, or the protocol after the extension headers,
+// e.g. packet.ProtocolTCP behind a Hop-by-Hop Options header. For IPv4 it
+// is the protocol of the IP header.
+func NextHeaderFilter(protocol uint8) *Filter {
+	return &Filter{Match: func(p *Packet) bool {
+		d := p.Decode()
+		if d.IPVersion == 0 {
+			return false
+		}
+		for _, h := range nextHeaders(d) {
+			if h == protocol {
+				return true
+			}
+		}
+		return false
+	}}
+}
+
+// nextHeaders returns the headers of the chain of an IP packet in order,
+// the extension headers followed by the protocol after them.
+func nextHeaders(d *packet.Packet) []uint8 {
+	return append(append([]uint8(nil), d.ExtHeaders...), d.Protocol)
+}
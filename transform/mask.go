@@ -0,0 +1,89 @@
+package transform
+
+import (
+	"bytes"
+	"regexp"
+)
+
+// Commonly masked payload contents.
+var (
+	// CreditCardPattern matches 13 to 16 digit card numbers optionally grouped with spaces or dashes.
+	CreditCardPattern = regexp.MustCompile(`\b\d(?:[ -]?\d){12,15}\b`)
+	// PasswordPattern matches the argument of FTP, POP3 and IMAP style password commands.
+	PasswordPattern = regexp.MustCompile(`(?i)(?:\bPASS|\bLOGIN \S+) (\S+)`)
+)
+
+// Mask overwrites matching parts of the packet payload with a fill byte,
+// keeping the packet length, then fixes up the checksums.
+type Mask struct {
+	Patterns [][]byte         // literal byte patterns
+	Regexps  []*regexp.Regexp // if an expression has subexpressions only those are masked
+	Fill     byte
+}
+
+// AddPattern adds a literal byte pattern to mask.
+func (m *Mask) AddPattern(pattern []byte) {
+	m.Patterns = append(m.Patterns, pattern)
+}
+
+// AddRegexp adds a regular expression to mask.
+func (m *Mask) AddRegexp(expr string) error {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return err
+	}
+	m.Regexps = append(m.Regexps, re)
+	return nil
+}
+
+// Apply masks the payload in place. It never drops packets.
+func (m *Mask) Apply(p *Packet) bool {
+
+	d := p.Decode()
+	payload := d.Payload()
+	if len(payload) == 0 {
+		return true
+	}
+
+	masked := false
+
+	for _, pattern := range m.Patterns {
+		if len(pattern) == 0 {
+			continue
+		}
+		for i := 0; ; {
+			n := bytes.Index(payload[i:], pattern)
+			if n < 0 {
+				break
+			}
+			m.fill(payload[i+n : i+n+len(pattern)])
+			i += n + len(pattern)
+			masked = true
+		}
+	}
+
+	for _, re := range m.Regexps {
+		for _, loc := range re.FindAllSubmatchIndex(payload, -1) {
+			if len(loc) == 2 {
+				m.fill(payload[loc[0]:loc[1]])
+			}
+			for i := 2; i < len(loc); i += 2 {
+				if loc[i] >= 0 {
+					m.fill(payload[loc[i]:loc[i+1]])
+				}
+			}
+			masked = true
+		}
+	}
+
+	if masked {
+		d.FixChecksums()
+	}
+	return true
+}
+
+func (m *Mask) fill(b []byte) {
+	for i := range b {
+		b[i] = m.Fill
+	}
+}
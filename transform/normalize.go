@@ -0,0 +1,83 @@
+package transform
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/RajeshGottlieb/go/pcapng"
+)
+
+// Normalize copies pr to pw in a canonical form, so that captures of the
+// same traffic written by different tools can be compared byte for byte:
+//
+//   - little endian, whatever the byte order of the input
+//   - Section Header Blocks without options, e.g. shb_hardware and shb_os
+//   - no opt_comment, Interface Statistics or Name Resolution Blocks
+//   - no blocks the reader does not parse, as their byte order is unknown
+//   - options of every block in order of their code
+//   - interfaces numbered in the order packets first use them, each
+//     written just before its first packet, and those without packets
+//     left out
+func Normalize(pr *pcapng.PcapngReader, pw *pcapng.PcapngWriter) error {
+
+	pw.Endian = binary.LittleEndian
+
+	var interfaces []*pcapng.InterfaceBlock // of the input section
+	ids := make(map[uint32]uint32)          // input interface ID -> output
+
+	for {
+		block, err := pr.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		switch b := block.(type) {
+		case *pcapng.SectionBlock:
+			interfaces = nil
+			ids = make(map[uint32]uint32)
+			err = pw.Write(&pcapng.SectionBlock{MajorVersion: 1, SectionLength: -1})
+		case *pcapng.InterfaceBlock:
+			normalizeOptions(&b.Options)
+			interfaces = append(interfaces, b)
+		case *pcapng.EnhancedPacketBlock:
+			id, ok := ids[b.InterfaceID]
+			if !ok {
+				if int(b.InterfaceID) >= len(interfaces) {
+					return &TransformError{fmt.Sprintf("packet %v references unknown interface %v", pr.Metadata().Packet, b.InterfaceID)}
+				}
+				id = uint32(len(ids))
+				ids[b.InterfaceID] = id
+				if err := pw.Write(interfaces[b.InterfaceID]); err != nil {
+					return err
+				}
+			}
+			b.InterfaceID = id
+			normalizeOptions(&b.Options)
+			err = pw.Write(b)
+		case *pcapng.DecryptionSecretsBlock:
+			normalizeOptions(&b.Options)
+			err = pw.Write(b)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// normalizeOptions drops the comments and sorts the other options by code,
+// keeping the order of options with the same code.
+func normalizeOptions(options *[]pcapng.Option) {
+
+	kept := (*options)[:0]
+	for _, opt := range *options {
+		if opt.Code() != pcapng.OPT_COMMENT {
+			kept = append(kept, opt)
+		}
+	}
+	sort.SliceStable(kept, func(i, j int) bool { return kept[i].Code() < kept[j].Code() })
+	*options = kept
+}
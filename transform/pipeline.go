@@ -0,0 +1,102 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/RajeshGottlieb/go/pcapng"
+)
+
+// Stage is one named transform of a Pipeline with the counts of what it
+// was handed and what it dropped.
+type Stage struct {
+	Name          string
+	Transform     Transform
+	Packets       int // packets handed to the stage
+	Dropped       int // packets the stage dropped
+	Blocks        int // other blocks handed to a BlockTransform
+	BlocksDropped int // other blocks the stage dropped
+}
+
+// Apply counts the packet and passes it to the transform of the stage.
+func (s *Stage) Apply(p *Packet) bool {
+	s.Packets++
+	if !s.Transform.Apply(p) {
+		s.Dropped++
+		return false
+	}
+	return true
+}
+
+// ApplyBlock counts the block and passes it on if the transform of the
+// stage is a BlockTransform.
+func (s *Stage) ApplyBlock(b pcapng.Block) bool {
+	bt, ok := s.Transform.(BlockTransform)
+	if !ok {
+		return true
+	}
+	s.Blocks++
+	if !bt.ApplyBlock(b) {
+		s.BlocksDropped++
+		return false
+	}
+	return true
+}
+
+// Pipeline chains transforms between a reader and a writer, counting the
+// packets going through each stage.
+//
+//	pl := transform.NewPipeline().
+//		Add("filter", transform.FlowFilter(flow)).
+//		Add("dedupe", &transform.Dedupe{}).
+//		Add("anonymize", rw)
+//	err := pl.Run(pr, pw)
+//	fmt.Print(pl)
+type Pipeline struct {
+	Stages     []*Stage
+	Injections []Injection // packets to insert, in timestamp order
+}
+
+// NewPipeline returns a Pipeline with no stages.
+func NewPipeline() *Pipeline {
+	return &Pipeline{}
+}
+
+// Add appends a stage running t and returns the pipeline, so calls can be
+// chained.
+func (pl *Pipeline) Add(name string, t Transform) *Pipeline {
+	pl.Stages = append(pl.Stages, &Stage{Name: name, Transform: t})
+	return pl
+}
+
+// Stage returns the first stage called name, nil if there is none.
+func (pl *Pipeline) Stage(name string) *Stage {
+	for _, s := range pl.Stages {
+		if s.Name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// Run copies every block from pr to pw through the stages in order.
+func (pl *Pipeline) Run(pr *pcapng.PcapngReader, pw *pcapng.PcapngWriter) error {
+	c := Copier{Injections: pl.Injections}
+	for _, s := range pl.Stages {
+		c.Transforms = append(c.Transforms, s)
+	}
+	return c.Copy(pr, pw)
+}
+
+// String lists the counters of each stage, one per line.
+func (pl *Pipeline) String() string {
+	var sb strings.Builder
+	for _, s := range pl.Stages {
+		fmt.Fprintf(&sb, "%v: %v packets in, %v dropped, %v out", s.Name, s.Packets, s.Dropped, s.Packets-s.Dropped)
+		if s.Blocks > 0 {
+			fmt.Fprintf(&sb, ", %v other blocks, %v dropped", s.Blocks, s.BlocksDropped)
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
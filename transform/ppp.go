@@ -0,0 +1,63 @@
+package transform
+
+import (
+	"github.com/RajeshGottlieb/go/packet"
+	"github.com/RajeshGottlieb/go/pcapng"
+)
+
+// StripPPP reduces the packets of broadband access captures to the IP
+// packets they carry: PPPoE sessions, L2TPv2 data messages and PPP frames
+// lose their link, PPP and tunnel headers, and other IP packets their link
+// header. The interfaces become raw IP (link type 101). Packets that carry
+// no IP, e.g. PPP LCP and PPPoE discovery, are dropped.
+type StripPPP struct {
+	Dropped int // number of packets dropped so far
+
+	linkTypes map[*pcapng.InterfaceBlock]uint16 // of the input interfaces changed to raw IP
+}
+
+// ApplyBlock changes the link type of the interfaces it can strip. It
+// never drops blocks.
+func (s *StripPPP) ApplyBlock(b pcapng.Block) bool {
+
+	switch ifb := b.(type) {
+	case *pcapng.SectionBlock:
+		s.linkTypes = nil
+	case *pcapng.InterfaceBlock:
+		switch ifb.LinkType {
+		case packet.LinkTypeEthernet, packet.LinkTypeLinuxSLL, packet.LinkTypeLinuxSLL2, packet.LinkTypePPP,
+			packet.LinkTypeRaw, packet.LinkTypeIPv4, packet.LinkTypeIPv6:
+			if s.linkTypes == nil {
+				s.linkTypes = make(map[*pcapng.InterfaceBlock]uint16)
+			}
+			s.linkTypes[ifb] = ifb.LinkType
+			ifb.LinkType = packet.LinkTypeRaw
+		}
+	}
+	return true
+}
+
+// Apply strips the packet down to IP, or drops it.
+func (s *StripPPP) Apply(p *Packet) bool {
+
+	linkType, ok := s.linkTypes[p.Interface]
+	if !ok {
+		return true // an interface left as it was
+	}
+
+	pkt := packet.Decode(linkType, p.Block.PacketData)
+	offset := pkt.NetworkOffset
+	if ppp, ok := pkt.PPP(); ok {
+		offset = -1
+		if ppp.Protocol == packet.PPPProtocolIPv4 || ppp.Protocol == packet.PPPProtocolIPv6 {
+			offset = ppp.Inner
+		}
+	}
+	if offset < 0 || offset >= len(p.Block.PacketData) {
+		s.Dropped++
+		return false
+	}
+
+	setData(p, p.Block.PacketData[offset:])
+	return true
+}
@@ -0,0 +1,64 @@
+package transform
+
+import (
+	"github.com/RajeshGottlieb/go/pcapng"
+)
+
+// Precision changes the timestamp resolution of every interface to Tsresol
+// and re-encodes the timestamps of the packets and statistics blocks.
+type Precision struct {
+	Tsresol  uint8 // if_tsresol value, e.g. 6 for micro and 9 for nano seconds
+	Rounding pcapng.Rounding
+	Report   *pcapng.FidelityReport // if set, counts the timestamps that lose digits
+
+	from []uint8 // original resolution of each interface in the section
+}
+
+// ApplyBlock changes the resolution of interfaces and re-encodes statistics timestamps.
+func (pr *Precision) ApplyBlock(b pcapng.Block) bool {
+
+	switch block := b.(type) {
+	case *pcapng.SectionBlock:
+		pr.from = nil
+	case *pcapng.InterfaceBlock:
+		pr.from = append(pr.from, block.Tsresol())
+
+		options := []pcapng.Option{&pcapng.If_Tsresol{Value: pr.Tsresol}}
+		for _, opt := range block.Options {
+			if _, ok := opt.(*pcapng.If_Tsresol); !ok {
+				options = append(options, opt)
+			}
+		}
+		block.Options = options
+	case *pcapng.InterfaceStatisticsBlock:
+		block.TimestampHigh, block.TimestampLow = pr.convert(block.InterfaceID, block.TimestampHigh, block.TimestampLow)
+		for _, opt := range block.Options {
+			switch o := opt.(type) {
+			case *pcapng.Isb_Starttime:
+				o.TimestampHigh, o.TimestampLow = pr.convert(block.InterfaceID, o.TimestampHigh, o.TimestampLow)
+			case *pcapng.Isb_Endtime:
+				o.TimestampHigh, o.TimestampLow = pr.convert(block.InterfaceID, o.TimestampHigh, o.TimestampLow)
+			}
+		}
+	}
+	return true
+}
+
+// Apply re-encodes the packet timestamp. It never drops packets.
+func (pr *Precision) Apply(p *Packet) bool {
+	p.Block.TimestampHigh, p.Block.TimestampLow = pr.convert(p.Block.InterfaceID, p.Block.TimestampHigh, p.Block.TimestampLow)
+	return true
+}
+
+func (pr *Precision) convert(interfaceID uint32, high, low uint32) (uint32, uint32) {
+	from := uint8(pcapng.DefaultTsresol)
+	if int(interfaceID) < len(pr.from) {
+		from = pr.from[interfaceID]
+	}
+	ticks := uint64(high)<<32 | uint64(low)
+	if pr.Report != nil && pcapng.LosesDigits(ticks, from, pr.Tsresol) {
+		pr.Report.Truncated++
+	}
+	ticks = pcapng.ConvertTicks(ticks, from, pr.Tsresol, pr.Rounding)
+	return uint32(ticks >> 32), uint32(ticks)
+}
@@ -0,0 +1,117 @@
+package transform
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Factory creates a transform from the argument string given after its
+// name, "" if there was none.
+type Factory func(args string) (Transform, error)
+
+var (
+	registryMu sync.Mutex
+	registry   = make(map[string]Factory)
+)
+
+// Register makes a transform available by name to New, and so to the
+// commands taking -transform. Packages shipping their own transforms call
+// it from init, like database/sql drivers. It panics if the name is taken.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := registry[name]; ok {
+		panic("transform: Register called twice for " + name)
+	}
+	registry[name] = factory
+}
+
+// New creates the registered transform of a spec "name" or "name:args".
+func New(spec string) (Transform, error) {
+
+	name, args := spec, ""
+	if i := strings.Index(spec, ":"); i >= 0 {
+		name, args = spec[:i], spec[i+1:]
+	}
+
+	registryMu.Lock()
+	factory, ok := registry[name]
+	registryMu.Unlock()
+	if !ok {
+		return nil, &TransformError{fmt.Sprintf("unknown transform %q, registered are %v", name, strings.Join(Names(), ", "))}
+	}
+	return factory(args)
+}
+
+// Names returns the names of the registered transforms, sorted.
+func Names() []string {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	var names []string
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// the transforms of this package that take at most one number or string
+func init() {
+	Register("trim", func(args string) (Transform, error) {
+		return &Trim{}, nil
+	})
+	Register("comments", func(args string) (Transform, error) {
+		return &StripComments{}, nil
+	})
+	Register("decap", func(args string) (Transform, error) {
+		return &Decap{}, nil
+	})
+	Register("ppp", func(args string) (Transform, error) {
+		return &StripPPP{}, nil
+	})
+	Register("slice", func(args string) (Transform, error) {
+		if args == "" {
+			return nil, &TransformError{"slice needs a length, e.g. slice:128"}
+		}
+		n, err := intArg("slice", args, 0)
+		return &Slice{Length: n}, err
+	})
+	Register("dedupe", func(args string) (Transform, error) {
+		n, err := intArg("dedupe", args, 0)
+		return &Dedupe{Window: n}, err
+	})
+	Register("decimate", func(args string) (Transform, error) {
+		n, err := intArg("decimate", args, 1)
+		return &Decimate{N: n}, err
+	})
+	Register("filter", func(args string) (Transform, error) {
+		return ExprFilter(args)
+	})
+	Register("set", func(args string) (Transform, error) {
+		return ParseSet(args)
+	})
+	Register("exec", func(args string) (Transform, error) {
+		command := strings.Fields(args)
+		if len(command) == 0 {
+			return nil, &TransformError{"exec needs a command, e.g. exec:./mytransform -v"}
+		}
+		return &Exec{Command: command}, nil
+	})
+	Register("geoip", newGeoIP)
+}
+
+// intArg parses the argument of a transform taking one number, def if there
+// is none.
+func intArg(name string, args string, def int) (int, error) {
+	if args == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(args)
+	if err != nil {
+		return def, &TransformError{fmt.Sprintf("%v takes a number, not %q", name, args)}
+	}
+	return n, nil
+}
@@ -0,0 +1,99 @@
+package transform
+
+import (
+	"net"
+)
+
+// PrefixMap maps addresses within From to the same host bits within To.
+type PrefixMap struct {
+	From *net.IPNet
+	To   *net.IPNet
+}
+
+// Rewrite maps IP addresses, MAC addresses and VLAN IDs while copying a
+// capture, the way tcprewrite does, then fixes up the checksums.
+type Rewrite struct {
+	Prefixes []PrefixMap                 // first matching prefix wins
+	MACs     map[string]net.HardwareAddr // keyed by the old address as formatted by net.HardwareAddr.String
+	VLANs    map[uint16]uint16
+}
+
+// AddPrefix adds a mapping from one CIDR prefix to another of the same address family.
+func (rw *Rewrite) AddPrefix(from, to string) error {
+	_, fromNet, err := net.ParseCIDR(from)
+	if err != nil {
+		return err
+	}
+	_, toNet, err := net.ParseCIDR(to)
+	if err != nil {
+		return err
+	}
+	if len(fromNet.IP) != len(toNet.IP) {
+		return &TransformError{"prefixes " + from + " and " + to + " are different address families"}
+	}
+	rw.Prefixes = append(rw.Prefixes, PrefixMap{fromNet, toNet})
+	return nil
+}
+
+// AddMAC adds a mapping from one MAC address to another.
+func (rw *Rewrite) AddMAC(from, to string) error {
+	fromMAC, err := net.ParseMAC(from)
+	if err != nil {
+		return err
+	}
+	toMAC, err := net.ParseMAC(to)
+	if err != nil {
+		return err
+	}
+	if rw.MACs == nil {
+		rw.MACs = make(map[string]net.HardwareAddr)
+	}
+	rw.MACs[fromMAC.String()] = toMAC
+	return nil
+}
+
+// AddVLAN adds a mapping from one VLAN ID to another.
+func (rw *Rewrite) AddVLAN(from, to uint16) {
+	if rw.VLANs == nil {
+		rw.VLANs = make(map[uint16]uint16)
+	}
+	rw.VLANs[from] = to
+}
+
+// Apply rewrites the packet in place. It never drops packets.
+func (rw *Rewrite) Apply(p *Packet) bool {
+
+	d := p.Decode()
+
+	for _, mac := range []net.HardwareAddr{d.SrcMAC, d.DstMAC} {
+		if to, ok := rw.MACs[mac.String()]; ok && mac != nil {
+			copy(mac, to)
+		}
+	}
+
+	for i, id := range d.VLANs {
+		if to, ok := rw.VLANs[id]; ok {
+			d.SetVLAN(i, to)
+		}
+	}
+
+	if d.IPVersion != 0 {
+		rw.mapIP(d.SrcIP)
+		rw.mapIP(d.DstIP)
+		d.FixChecksums()
+	}
+	return true
+}
+
+// mapIP rewrites ip in place using the first matching prefix.
+func (rw *Rewrite) mapIP(ip net.IP) {
+	for _, m := range rw.Prefixes {
+		if len(ip) != len(m.From.IP) || !m.From.Contains(ip) {
+			continue
+		}
+		for i := range ip {
+			ip[i] = m.To.IP[i] | ip[i]&^m.To.Mask[i]
+		}
+		return
+	}
+}
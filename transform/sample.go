@@ -0,0 +1,50 @@
+package transform
+
+import (
+	"math/rand"
+
+	"github.com/RajeshGottlieb/go/pcapng"
+)
+
+// Sample keeps each packet with the given probability. The same Seed
+// always selects the same packets of the same input. If Complement is set
+// the packets that are not kept are written to it, together with a copy of
+// every other block, so the two outputs split the input between them.
+// Sample must then be the last transform: those after it would change or
+// drop only the packets kept, and those before it drop packets from both
+// outputs.
+type Sample struct {
+	Probability float64 // 0 to 1
+	Seed        int64
+	Complement  *pcapng.PcapngWriter
+
+	Err error // the first error writing to Complement
+
+	rand *rand.Rand
+}
+
+// Apply keeps the packet with the given probability.
+func (s *Sample) Apply(p *Packet) bool {
+
+	if s.rand == nil {
+		s.rand = rand.New(rand.NewSource(s.Seed))
+	}
+
+	if s.rand.Float64() < s.Probability {
+		return true
+	}
+	s.write(p.Block)
+	return false
+}
+
+// ApplyBlock copies every block other than packets to Complement.
+func (s *Sample) ApplyBlock(b pcapng.Block) bool {
+	s.write(b)
+	return true
+}
+
+func (s *Sample) write(b pcapng.Block) {
+	if s.Complement != nil && s.Err == nil {
+		s.Err = s.Complement.Write(b)
+	}
+}
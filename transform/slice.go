@@ -0,0 +1,16 @@
+package transform
+
+// Slice cuts every packet to at most Length captured bytes, like
+// editcap -s. The original packet length is left alone.
+type Slice struct {
+	Length int
+}
+
+// Apply cuts the packet data. It never drops packets.
+func (s *Slice) Apply(p *Packet) bool {
+	if s.Length >= 0 && s.Length < len(p.Block.PacketData) {
+		p.Block.PacketData = p.Block.PacketData[:s.Length]
+		p.Block.CapturedPacketLength = uint32(s.Length)
+	}
+	return true
+}
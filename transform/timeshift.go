@@ -0,0 +1,71 @@
+package transform
+
+import (
+	"time"
+
+	"github.com/RajeshGottlieb/go/pcapng"
+)
+
+// TimeShift corrects the timestamps of a capture taken with a wrong clock.
+// Every timestamp t becomes
+//
+//	t + Offset + (t - Reference) * PPM / 1e6
+//
+// so Offset alone shifts the whole capture like editcap -t, and PPM
+// corrects a clock that drifted by that many parts per million. A clock
+// that ran fast needs a negative PPM. A zero Reference means the timestamp
+// of the first packet.
+type TimeShift struct {
+	Offset    time.Duration
+	PPM       float64
+	Reference time.Time
+
+	tsresol []uint8 // resolution of each interface in the section
+}
+
+// ApplyBlock shifts the timestamps of statistics blocks.
+func (ts *TimeShift) ApplyBlock(b pcapng.Block) bool {
+
+	switch block := b.(type) {
+	case *pcapng.SectionBlock:
+		ts.tsresol = nil
+	case *pcapng.InterfaceBlock:
+		ts.tsresol = append(ts.tsresol, block.Tsresol())
+	case *pcapng.InterfaceStatisticsBlock:
+		block.TimestampHigh, block.TimestampLow = ts.shift(block.InterfaceID, block.TimestampHigh, block.TimestampLow)
+		for _, opt := range block.Options {
+			switch o := opt.(type) {
+			case *pcapng.Isb_Starttime:
+				o.TimestampHigh, o.TimestampLow = ts.shift(block.InterfaceID, o.TimestampHigh, o.TimestampLow)
+			case *pcapng.Isb_Endtime:
+				o.TimestampHigh, o.TimestampLow = ts.shift(block.InterfaceID, o.TimestampHigh, o.TimestampLow)
+			}
+		}
+	}
+	return true
+}
+
+// Apply shifts the packet timestamp. It never drops packets.
+func (ts *TimeShift) Apply(p *Packet) bool {
+	p.Block.TimestampHigh, p.Block.TimestampLow = ts.shift(p.Block.InterfaceID, p.Block.TimestampHigh, p.Block.TimestampLow)
+	return true
+}
+
+func (ts *TimeShift) shift(interfaceID uint32, high, low uint32) (uint32, uint32) {
+
+	tsresol := uint8(pcapng.DefaultTsresol)
+	if int(interfaceID) < len(ts.tsresol) {
+		tsresol = ts.tsresol[interfaceID]
+	}
+
+	t := pcapng.Timestamp(high, low, tsresol)
+	if ts.Reference.IsZero() {
+		ts.Reference = t
+	}
+
+	shifted := t.Add(ts.Offset)
+	if ts.PPM != 0 {
+		shifted = shifted.Add(time.Duration(float64(t.Sub(ts.Reference)) * ts.PPM / 1e6))
+	}
+	return pcapng.SplitTimestamp(shifted, tsresol)
+}
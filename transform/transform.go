@@ -0,0 +1,189 @@
+// Package transform edits the packets of a pcapng file while copying it.
+package transform
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/RajeshGottlieb/go/packet"
+	"github.com/RajeshGottlieb/go/pcapng"
+)
+
+// Packet is what a Transform is handed for each Enhanced Packet Block.
+type Packet struct {
+	Number    int // 1 based packet number within the input
+	Block     *pcapng.EnhancedPacketBlock
+	Interface *pcapng.InterfaceBlock // nil if the block references an unknown interface
+}
+
+// LinkType returns the link type of the packet's interface.
+func (p *Packet) LinkType() uint16 {
+	if p.Interface == nil {
+		return 0
+	}
+	return p.Interface.LinkType
+}
+
+// Decode decodes the headers of the packet data as it is now.
+func (p *Packet) Decode() *packet.Packet {
+	return packet.Decode(p.LinkType(), p.Block.PacketData)
+}
+
+// TransformError
+type TransformError struct {
+	errorString string
+}
+
+func (te *TransformError) Error() string {
+	return te.errorString
+}
+
+// Transform is implemented by each packet edit.
+type Transform interface {
+	// Apply edits the packet in place. It returns false to drop the packet.
+	Apply(p *Packet) bool
+}
+
+// BlockTransform is implemented by transforms that also need to see the
+// blocks that are not packets, e.g. to edit interfaces.
+type BlockTransform interface {
+	// ApplyBlock edits the block in place. It returns false to drop the block.
+	ApplyBlock(b pcapng.Block) bool
+}
+
+// TransformFunc adapts an ordinary function to the Transform interface.
+type TransformFunc func(p *Packet) bool
+
+func (f TransformFunc) Apply(p *Packet) bool {
+	return f(p)
+}
+
+// Copier copies a pcapng file, passing each packet through its transforms.
+type Copier struct {
+	Transforms []Transform
+	Injections []Injection // packets to insert, in timestamp order
+}
+
+// Copy copies every block from pr to pw, passing each packet through the transforms in order.
+func Copy(pr *pcapng.PcapngReader, pw *pcapng.PcapngWriter, transforms ...Transform) error {
+	c := Copier{Transforms: transforms}
+	return c.Copy(pr, pw)
+}
+
+// Copy copies every block from pr to pw.
+func (c *Copier) Copy(pr *pcapng.PcapngReader, pw *pcapng.PcapngWriter) error {
+
+	var interfaces []*pcapng.InterfaceBlock
+	number := 0
+	pending := c.Injections
+
+	for {
+		block, err := pr.Read()
+		if err == io.EOF {
+			// whatever is left goes after the last packet
+			return inject(pw, interfaces, pending)
+		} else if err != nil {
+			return err
+		}
+
+		if _, ok := block.(*pcapng.EnhancedPacketBlock); !ok && !applyBlock(block.(pcapng.Block), c.Transforms) {
+			continue
+		}
+
+		switch b := block.(type) {
+		case *pcapng.SectionBlock:
+			// interface IDs are only unique within a section
+			interfaces = nil
+		case *pcapng.InterfaceBlock:
+			interfaces = append(interfaces, b)
+		case *pcapng.EnhancedPacketBlock:
+			number++
+
+			p := Packet{Number: number, Block: b}
+			if int(b.InterfaceID) < len(interfaces) {
+				p.Interface = interfaces[b.InterfaceID]
+			}
+
+			if len(pending) > 0 {
+				ts := packetTime(p.Interface, b)
+				n := 0
+				for n < len(pending) && !pending[n].Timestamp.After(ts) {
+					n++
+				}
+				if err := inject(pw, interfaces, pending[:n]); err != nil {
+					return err
+				}
+				pending = pending[n:]
+			}
+
+			if !apply(&p, c.Transforms) {
+				continue
+			}
+		}
+
+		if err := pw.Write(block.(pcapng.Block)); err != nil {
+			return err
+		}
+	}
+}
+
+// apply runs the transforms in order, stopping at the first that drops the packet.
+func apply(p *Packet, transforms []Transform) bool {
+	for _, t := range transforms {
+		if !t.Apply(p) {
+			return false
+		}
+	}
+	return true
+}
+
+// applyBlock runs the block transforms in order, stopping at the first that drops the block.
+func applyBlock(b pcapng.Block, transforms []Transform) bool {
+	for _, t := range transforms {
+		if bt, ok := t.(BlockTransform); ok && !bt.ApplyBlock(b) {
+			return false
+		}
+	}
+	return true
+}
+
+// Injection is a caller crafted packet to insert into the output of a Copier.
+type Injection struct {
+	Timestamp   time.Time
+	InterfaceID uint32 // interface within the current section
+	Data        []byte
+	Options     []pcapng.Option
+}
+
+// inject writes the injections as Enhanced Packet Blocks.
+func inject(pw *pcapng.PcapngWriter, interfaces []*pcapng.InterfaceBlock, injections []Injection) error {
+	for _, inj := range injections {
+		if int(inj.InterfaceID) >= len(interfaces) {
+			return &TransformError{fmt.Sprintf("injected packet at %v references unknown interface %v", inj.Timestamp, inj.InterfaceID)}
+		}
+		high, low := interfaces[inj.InterfaceID].SplitPacketTime(inj.Timestamp, pcapng.Truncate)
+
+		b := &pcapng.EnhancedPacketBlock{
+			InterfaceID:          inj.InterfaceID,
+			TimestampHigh:        high,
+			TimestampLow:         low,
+			CapturedPacketLength: uint32(len(inj.Data)),
+			OriginalPacketLength: uint32(len(inj.Data)),
+			PacketData:           inj.Data,
+			Options:              inj.Options,
+		}
+		if err := pw.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// packetTime returns the time of a packet whose interface may be nil.
+func packetTime(ifb *pcapng.InterfaceBlock, b *pcapng.EnhancedPacketBlock) time.Time {
+	if ifb == nil {
+		return pcapng.Timestamp(b.TimestampHigh, b.TimestampLow, pcapng.DefaultTsresol)
+	}
+	return ifb.PacketTime(b.TimestampHigh, b.TimestampLow)
+}
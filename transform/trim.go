@@ -0,0 +1,19 @@
+package transform
+
+// Trim keeps only the link, network and transport headers of each packet
+// and drops the application payload so captures can be shared without
+// exposing their contents. The original packet length is left alone, and so
+// are packets of link types whose headers are not decoded.
+type Trim struct {
+}
+
+// Apply cuts the packet data after the last decoded header. It never drops packets.
+func (t *Trim) Apply(p *Packet) bool {
+
+	length := p.Decode().HeaderLength()
+	if length > 0 && length < len(p.Block.PacketData) {
+		p.Block.PacketData = p.Block.PacketData[:length]
+		p.Block.CapturedPacketLength = uint32(length)
+	}
+	return true
+}
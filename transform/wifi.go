@@ -0,0 +1,72 @@
+package transform
+
+import (
+	"github.com/RajeshGottlieb/go/packet"
+	"github.com/RajeshGottlieb/go/pcapng"
+)
+
+// WifiToEthernet converts the 802.11 data frames of radiotap and 802.11
+// captures to Ethernet frames, for tools that only understand wired
+// captures. The interfaces become Ethernet. Management, control and
+// protected frames, and data frames without an LLC/SNAP header, are
+// dropped.
+type WifiToEthernet struct {
+	KeepRadiotap bool // record the channel, signal and MCS of each packet in an opt_comment
+	Dropped      int  // number of packets dropped so far
+
+	linkTypes map[*pcapng.InterfaceBlock]uint16 // of the input interfaces changed to Ethernet
+}
+
+// ApplyBlock changes the link type of 802.11 interfaces. It never drops
+// blocks.
+func (w *WifiToEthernet) ApplyBlock(b pcapng.Block) bool {
+
+	switch ifb := b.(type) {
+	case *pcapng.SectionBlock:
+		w.linkTypes = nil
+	case *pcapng.InterfaceBlock:
+		if ifb.LinkType == packet.LinkTypeRadiotap || ifb.LinkType == packet.LinkTypeIEEE80211 {
+			if w.linkTypes == nil {
+				w.linkTypes = make(map[*pcapng.InterfaceBlock]uint16)
+			}
+			w.linkTypes[ifb] = ifb.LinkType
+			ifb.LinkType = packet.LinkTypeEthernet
+		}
+	}
+	return true
+}
+
+// Apply converts the frame, or drops it.
+func (w *WifiToEthernet) Apply(p *Packet) bool {
+
+	linkType, ok := w.linkTypes[p.Interface]
+	if !ok {
+		return true // not an 802.11 interface
+	}
+
+	pkt := packet.Decode(linkType, p.Block.PacketData)
+	d, ok := pkt.Dot11()
+	llc := []byte(nil)
+	if ok {
+		llc = pkt.Data[d.Payload:d.End]
+	}
+	if len(llc) < 8 || llc[0] != 0xaa || llc[1] != 0xaa || llc[2] != 0x03 {
+		w.Dropped++
+		return false
+	}
+
+	frame := make([]byte, 14, 14+len(llc)-8)
+	copy(frame[0:6], d.DstMAC)
+	copy(frame[6:12], d.SrcMAC)
+	copy(frame[12:14], llc[6:8])
+	frame = append(frame, llc[8:]...)
+
+	if w.KeepRadiotap && pkt.Radiotap != nil {
+		if s := pkt.Radiotap.String(); s != "" {
+			p.Block.WithComment(s)
+		}
+	}
+
+	setData(p, frame)
+	return true
+}
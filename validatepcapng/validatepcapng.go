@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"github.com/RajeshGottlieb/go/pcapng"
+	"io"
+	"os"
+	"time"
+)
+
+func main() {
+
+	quiet := flag.Bool("q", false, "only report errors, not warnings")
+	prefetch := flag.Int("prefetch", 0, "read this many blocks ahead in the background")
+	timestamps := flag.Bool("timestamps", false, "also check for timestamps that go backwards, lie in the future or before the if_tsoffset")
+	hashes := flag.Bool("hashes", false, "also check the epb_hash of every packet against its data")
+	future := flag.Duration("future", 24*time.Hour, "with -timestamps, how far in the future a timestamp may be")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Printf("usage: %v [-q] [-prefetch blocks] [-timestamps [-future duration]] [-hashes] <input-pcapng>\n", os.Args[0])
+		os.Exit(2)
+	}
+
+	fh, err := os.Open(flag.Arg(0))
+	if err != nil {
+		panic(err)
+	}
+	defer fh.Close()
+
+	var r io.Reader = bufio.NewReader(fh)
+	if *prefetch > 0 {
+		p := pcapng.Prefetch(r, *prefetch)
+		defer p.Close()
+		r = p
+	}
+
+	problems, err := pcapng.Validate(r)
+	if err != nil {
+		panic(err)
+	}
+
+	if *timestamps {
+		if _, err := fh.Seek(0, io.SeekStart); err != nil {
+			panic(err)
+		}
+		tc := pcapng.NewTimestampChecker()
+		tc.MaxFuture = *future
+		more, err := pcapng.CheckTimestamps(bufio.NewReader(fh), tc)
+		problems = append(problems, more...)
+		if err != nil && len(problems) == 0 {
+			panic(err)
+		}
+	}
+
+	if *hashes {
+		if _, err := fh.Seek(0, io.SeekStart); err != nil {
+			panic(err)
+		}
+		more, err := pcapng.CheckHashes(bufio.NewReader(fh))
+		problems = append(problems, more...)
+		if err != nil && len(problems) == 0 {
+			panic(err)
+		}
+	}
+
+	errors, warnings := 0, 0
+	for _, p := range problems {
+		if p.Warning {
+			warnings++
+			if *quiet {
+				continue
+			}
+		} else {
+			errors++
+		}
+		fmt.Println(p)
+	}
+
+	fmt.Printf("%v: %v errors, %v warnings\n", flag.Arg(0), errors, warnings)
+	if errors > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,264 @@
+// Package watch processes the capture files rotated into a directory, e.g.
+// by dumpcap -b or tcpdump -G, once they are complete.
+package watch
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/RajeshGottlieb/go/pcapng"
+	"github.com/RajeshGottlieb/go/transform"
+)
+
+// WatchError
+type WatchError struct {
+	errorString string
+}
+
+func (we *WatchError) Error() string {
+	return we.errorString
+}
+
+// Processor processes a completed capture file.
+type Processor func(path string) error
+
+// file is what a Watcher knows of a file in the directory.
+type file struct {
+	size    int64
+	modTime time.Time
+	since   time.Time // the size and modification time were first seen
+	done    bool      // processed, or failed to
+}
+
+// Watcher looks for files in a directory that have stopped changing and
+// processes each once. A file counts as complete when its size and
+// modification time have not changed for Quiet.
+type Watcher struct {
+	Dir     string
+	Pattern string        // filepath.Match pattern for the file names, all files but .idx if empty
+	Quiet   time.Duration // 10s if 0
+	Poll    time.Duration // how often the directory is listed, 1s if 0
+	Process Processor
+
+	Delete     bool   // remove each file once processed
+	ArchiveDir string // else move it here, if not empty
+
+	// Errors is called when processing a file fails, and the file is left
+	// where it is. If nil, Run returns the error.
+	Errors func(path string, err error)
+
+	files map[string]*file
+}
+
+// New returns a Watcher processing the files in dir whose names match
+// pattern.
+func New(dir, pattern string, process Processor) *Watcher {
+	return &Watcher{Dir: dir, Pattern: pattern, Process: process}
+}
+
+// Run scans the directory until stop is closed.
+func (w *Watcher) Run(stop <-chan struct{}) error {
+
+	poll := w.Poll
+	if poll <= 0 {
+		poll = time.Second
+	}
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+
+	for {
+		if err := w.Scan(); err != nil {
+			return err
+		}
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Scan lists the directory once, processing in name order the files that
+// have been quiet long enough.
+func (w *Watcher) Scan() error {
+
+	if w.files == nil {
+		w.files = make(map[string]*file)
+	}
+	quiet := w.Quiet
+	if quiet <= 0 {
+		quiet = 10 * time.Second
+	}
+
+	infos, err := ioutil.ReadDir(w.Dir)
+	if err != nil {
+		return err
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+
+	now := time.Now()
+	present := make(map[string]bool)
+	for _, info := range infos {
+		if !info.Mode().IsRegular() || strings.HasSuffix(info.Name(), indexSuffix) {
+			continue // not a capture, or an index Index wrote
+		}
+		if w.Pattern != "" {
+			if ok, err := filepath.Match(w.Pattern, info.Name()); err != nil {
+				return err
+			} else if !ok {
+				continue
+			}
+		}
+		present[info.Name()] = true
+
+		f, ok := w.files[info.Name()]
+		if !ok || f.size != info.Size() || !f.modTime.Equal(info.ModTime()) {
+			// new or still being written
+			w.files[info.Name()] = &file{size: info.Size(), modTime: info.ModTime(), since: now}
+			continue
+		}
+		if f.done || now.Sub(f.since) < quiet {
+			continue
+		}
+
+		f.done = true
+		if err := w.process(filepath.Join(w.Dir, info.Name())); err != nil {
+			if w.Errors == nil {
+				return err
+			}
+			w.Errors(filepath.Join(w.Dir, info.Name()), err)
+		}
+	}
+
+	// forget the files that are gone, whether moved by us or not
+	for name := range w.files {
+		if !present[name] {
+			delete(w.files, name)
+		}
+	}
+	return nil
+}
+
+// process processes a file then deletes or archives it, together with
+// the index Index wrote next to it.
+func (w *Watcher) process(path string) error {
+
+	if w.Process != nil {
+		if err := w.Process(path); err != nil {
+			return err
+		}
+	}
+
+	for _, p := range []string{path, path + indexSuffix} {
+		if p != path {
+			if _, err := os.Stat(p); os.IsNotExist(err) {
+				continue
+			}
+		}
+		if w.Delete {
+			if err := os.Remove(p); err != nil {
+				return err
+			}
+		} else if w.ArchiveDir != "" {
+			if err := os.Rename(p, filepath.Join(w.ArchiveDir, filepath.Base(p))); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Chain returns a Processor running each of processors in turn, stopping
+// at the first error.
+func Chain(processors ...Processor) Processor {
+	return func(path string) error {
+		for _, p := range processors {
+			if err := p(path); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// Transform returns a Processor copying each pcapng file into dir under
+// the same name, through the transforms, e.g. to filter or anonymize it.
+func Transform(dir string, transforms ...transform.Transform) Processor {
+	return func(path string) error {
+
+		out := filepath.Join(dir, filepath.Base(path))
+		if filepath.Clean(out) == filepath.Clean(path) {
+			return &WatchError{fmt.Sprintf("%v would be overwritten by its own copy", path)}
+		}
+
+		rfh, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer rfh.Close()
+
+		wfh, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		bw := bufio.NewWriter(wfh)
+		err = transform.Copy(pcapng.Reader(bufio.NewReader(rfh)), pcapng.Writer(bw), transforms...)
+		if err == nil {
+			err = bw.Flush()
+		}
+		if cerr := wfh.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(out)
+		}
+		return err
+	}
+}
+
+// indexSuffix is added to the name of a file for that of its index.
+const indexSuffix = ".idx"
+
+// Index returns a Processor writing the blocks of each pcapng file next to
+// it in path + ".idx", a line per block with its offset, type, length and
+// section. The Watcher never picks up the index as a capture and deletes
+// or archives it with its file.
+func Index() Processor {
+	return func(path string) error {
+
+		fh, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer fh.Close()
+
+		info, err := fh.Stat()
+		if err != nil {
+			return err
+		}
+		idx, err := pcapng.BuildIndex(fh, info.Size())
+		if err != nil {
+			return err
+		}
+
+		out, err := os.Create(path + indexSuffix)
+		if err != nil {
+			return err
+		}
+		bw := bufio.NewWriter(out)
+		for _, e := range idx.Entries {
+			fmt.Fprintf(bw, "%v %#x %v %v\n", e.Offset, e.Type, e.TotalLength, e.Section)
+		}
+		err = bw.Flush()
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+		return err
+	}
+}